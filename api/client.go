@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Client consumes the JSON API of a running BOSH exporter.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	username   string
+	password   string
+}
+
+// NewClient returns a client of the exporter listening at baseURL, for
+// example `http://localhost:9190`. A nil httpClient uses the default one.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// WithBasicAuth sets the `web.auth.*` credentials of the exporter.
+func (c *Client) WithBasicAuth(username string, password string) *Client {
+	c.username = username
+	c.password = password
+	return c
+}
+
+func (c *Client) StatusConfig() (StatusConfig, error) {
+	var response StatusConfigResponse
+	err := c.do(http.MethodGet, "/api/v1/status/config", nil, &response)
+	return response.Data, err
+}
+
+// ScanDeployment queues a BOSH Director problem scan of a deployment. An empty
+// environment scans the first environment watching the deployment.
+func (c *Client) ScanDeployment(environment string, deployment string) (DeploymentScan, error) {
+	path := "/api/v1/deployments/" + url.PathEscape(deployment) + "/scan"
+	if environment != "" {
+		path += "?" + url.Values{"environment": []string{environment}}.Encode()
+	}
+
+	var response DeploymentScanResponse
+	err := c.do(http.MethodPost, path, nil, &response)
+	return response.Data, err
+}
+
+func (c *Client) HTTPDebug() (HTTPDebug, error) {
+	var response HTTPDebugResponse
+	err := c.do(http.MethodGet, "/api/v1/debug/http", nil, &response)
+	return response.Data, err
+}
+
+func (c *Client) SetHTTPDebug(enabled bool) (HTTPDebug, error) {
+	form := url.Values{"enabled": []string{strconv.FormatBool(enabled)}}
+
+	var response HTTPDebugResponse
+	err := c.do(http.MethodPost, "/api/v1/debug/http", form, &response)
+	return response.Data, err
+}
+
+func (c *Client) ExplainFilters(query FiltersQuery) ([]EnvironmentFilters, error) {
+	values := url.Values{}
+	for name, value := range map[string]string{
+		"deployment": query.Deployment,
+		"job":        query.Job,
+		"az":         query.AZ,
+		"process":    query.Process,
+		"ip":         query.IP,
+	} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+
+	var response FiltersResponse
+	err := c.do(http.MethodGet, "/debug/filters?"+values.Encode(), nil, &response)
+	return response.Data, err
+}
+
+// ServiceDiscoveryDiff previews the Service Discovery targets the next write
+// would add and remove, optionally for a single environment and with another
+// filter expression.
+func (c *Client) ServiceDiscoveryDiff(environment string, expression string) ([]EnvironmentServiceDiscoveryDiff, error) {
+	values := url.Values{}
+	if environment != "" {
+		values.Set("environment", environment)
+	}
+	if expression != "" {
+		values.Set("expression", expression)
+	}
+
+	var response ServiceDiscoveryDiffResponse
+	err := c.do(http.MethodGet, "/debug/sd-diff?"+values.Encode(), nil, &response)
+	return response.Data, err
+}
+
+// Refresh makes the exporter query the BOSH Directors right away.
+func (c *Client) Refresh() error {
+	return c.do(http.MethodPost, "/-/refresh", nil, nil)
+}
+
+// Reload makes the exporter reload its configuration and returns what
+// changed since the last load.
+func (c *Client) Reload() ([]EnvironmentConfigChanges, error) {
+	var response ConfigReloadResponse
+	err := c.do(http.MethodPost, "/-/reload", nil, &response)
+	return response.Data, err
+}
+
+func (c *Client) do(method string, path string, form url.Values, response interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error creating request to `%s`: %v", path, err))
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error requesting `%s`: %v", path, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return errors.New(fmt.Sprintf("Error requesting `%s`: unexpected status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(message))))
+	}
+
+	if response == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return errors.New(fmt.Sprintf("Error decoding response from `%s`: %v", path, err))
+	}
+
+	return nil
+}
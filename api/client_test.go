@@ -0,0 +1,185 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/api"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server   *httptest.Server
+		requests []*http.Request
+		status   int
+		body     string
+		client   *Client
+	)
+
+	BeforeEach(func() {
+		requests = []*http.Request{}
+		status = http.StatusOK
+		body = `{"status":"success","data":{}}`
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			requests = append(requests, r)
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+		client = NewClient(server.URL+"/", nil)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("StatusConfig", func() {
+		BeforeEach(func() {
+			body = `{"status":"success","data":{"flags":{"bosh.url":"https://10.0.0.6:25555"},"filters":{"azs":["z1"]}}}`
+		})
+
+		It("returns the running configuration", func() {
+			statusConfig, err := client.StatusConfig()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(statusConfig.Flags).To(Equal(map[string]string{"bosh.url": "https://10.0.0.6:25555"}))
+			Expect(statusConfig.Filters).To(Equal(map[string][]string{"azs": []string{"z1"}}))
+			Expect(requests[0].Method).To(Equal(http.MethodGet))
+			Expect(requests[0].URL.Path).To(Equal("/api/v1/status/config"))
+		})
+
+		Context("when basic auth credentials are set", func() {
+			BeforeEach(func() {
+				client = client.WithBasicAuth("username", "password")
+			})
+
+			It("sends the credentials", func() {
+				_, err := client.StatusConfig()
+				Expect(err).ToNot(HaveOccurred())
+				username, password, ok := requests[0].BasicAuth()
+				Expect(ok).To(BeTrue())
+				Expect(username).To(Equal("username"))
+				Expect(password).To(Equal("password"))
+			})
+		})
+
+		Context("when the exporter rejects the request", func() {
+			BeforeEach(func() {
+				status = http.StatusUnauthorized
+				body = "Invalid username or password\n"
+			})
+
+			It("returns an error", func() {
+				_, err := client.StatusConfig()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("unexpected status 401: Invalid username or password"))
+			})
+		})
+	})
+
+	Describe("ScanDeployment", func() {
+		BeforeEach(func() {
+			status = http.StatusAccepted
+			body = `{"status":"success","data":{"environment":"prod","deployment":"cf"}}`
+		})
+
+		It("queues a problem scan of the deployment", func() {
+			scan, err := client.ScanDeployment("prod", "cf")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(scan).To(Equal(DeploymentScan{Environment: "prod", Deployment: "cf"}))
+			Expect(requests[0].Method).To(Equal(http.MethodPost))
+			Expect(requests[0].URL.Path).To(Equal("/api/v1/deployments/cf/scan"))
+			Expect(requests[0].URL.Query().Get("environment")).To(Equal("prod"))
+		})
+	})
+
+	Describe("SetHTTPDebug", func() {
+		BeforeEach(func() {
+			body = `{"status":"success","data":{"enabled":true}}`
+		})
+
+		It("enables the HTTP debug logging", func() {
+			httpDebug, err := client.SetHTTPDebug(true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(httpDebug.Enabled).To(BeTrue())
+			Expect(requests[0].Method).To(Equal(http.MethodPost))
+			Expect(requests[0].PostForm.Get("enabled")).To(Equal("true"))
+		})
+	})
+
+	Describe("ExplainFilters", func() {
+		BeforeEach(func() {
+			body = `{"status":"success","data":[{"environment":"prod","bosh_name":"bosh","accepted":false,"filters":[{"filter":"azs","value":"z1","accepted":false,"reason":"not in z2"}]}]}`
+		})
+
+		It("returns the filters explanation", func() {
+			explanations, err := client.ExplainFilters(FiltersQuery{AZ: "z1"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(explanations).To(Equal([]EnvironmentFilters{
+				{
+					Environment: "prod",
+					BoshName:    "bosh",
+					Filters:     []FilterResult{{Filter: "azs", Value: "z1", Reason: "not in z2"}},
+				},
+			}))
+			Expect(requests[0].URL.Path).To(Equal("/debug/filters"))
+			Expect(requests[0].URL.RawQuery).To(Equal("az=z1"))
+		})
+	})
+
+	Describe("ServiceDiscoveryDiff", func() {
+		BeforeEach(func() {
+			body = `{"status":"success","data":[{"environment":"prod","added":[{"target":"1.2.3.4","labels":{"__meta_bosh_deployment":"cf"}}],"removed":[],"unchanged":3}]}`
+		})
+
+		It("returns the Service Discovery diff", func() {
+			diffs, err := client.ServiceDiscoveryDiff("prod", `az == "z1"`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diffs).To(Equal([]EnvironmentServiceDiscoveryDiff{
+				{
+					Environment: "prod",
+					Added:       []ServiceDiscoveryTarget{{Target: "1.2.3.4", Labels: map[string]string{"__meta_bosh_deployment": "cf"}}},
+					Removed:     []ServiceDiscoveryTarget{},
+					Unchanged:   3,
+				},
+			}))
+			Expect(requests[0].URL.Path).To(Equal("/debug/sd-diff"))
+			Expect(requests[0].URL.Query().Get("expression")).To(Equal(`az == "z1"`))
+		})
+	})
+
+	Describe("Refresh", func() {
+		BeforeEach(func() {
+			body = "OK\n"
+		})
+
+		It("asks for a refresh", func() {
+			Expect(client.Refresh()).To(Succeed())
+			Expect(requests[0].Method).To(Equal(http.MethodPost))
+			Expect(requests[0].URL.Path).To(Equal("/-/refresh"))
+		})
+	})
+
+	Describe("Reload", func() {
+		BeforeEach(func() {
+			body = `{"status":"success","data":[{"environment":"prod","url":"https://10.0.0.6:25555","filters_added":{"deployments":["cf"]},"credentials_rotated":true}]}`
+		})
+
+		It("returns the config changes", func() {
+			changes, err := client.Reload()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changes).To(Equal([]EnvironmentConfigChanges{
+				{
+					Environment:        "prod",
+					URL:                "https://10.0.0.6:25555",
+					FiltersAdded:       map[string][]string{"deployments": {"cf"}},
+					CredentialsRotated: true,
+				},
+			}))
+			Expect(requests[0].Method).To(Equal(http.MethodPost))
+			Expect(requests[0].URL.Path).To(Equal("/-/reload"))
+		})
+	})
+})
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/log"
+)
+
+type operation struct {
+	method      string
+	path        string
+	summary     string
+	parameters  []parameter
+	formFields  []parameter
+	status      int
+	response    interface{}
+	contentType string
+}
+
+type parameter struct {
+	name        string
+	in          string
+	description string
+	required    bool
+	schemaType  string
+}
+
+var operations = []operation{
+	{
+		method:   http.MethodGet,
+		path:     "/api/v1/status/config",
+		summary:  "Running configuration: flag values (secrets redacted) and effective filters",
+		status:   http.StatusOK,
+		response: StatusConfigResponse{},
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/api/v1/deployments/{deployment}/scan",
+		summary: "Queue a BOSH Director problem scan of a deployment",
+		parameters: []parameter{
+			{name: "deployment", in: "path", description: "Name of the deployment", required: true, schemaType: "string"},
+			{name: "environment", in: "query", description: "Environment watching the deployment, the first one when empty", schemaType: "string"},
+		},
+		status:   http.StatusAccepted,
+		response: DeploymentScanResponse{},
+	},
+	{
+		method:   http.MethodGet,
+		path:     "/api/v1/debug/http",
+		summary:  "Whether the BOSH HTTP debug logging is enabled",
+		status:   http.StatusOK,
+		response: HTTPDebugResponse{},
+	},
+	{
+		method:  http.MethodPost,
+		path:    "/api/v1/debug/http",
+		summary: "Enable or disable the BOSH HTTP debug logging",
+		formFields: []parameter{
+			{name: "enabled", description: "Whether to log the BOSH HTTP requests and responses", required: true, schemaType: "boolean"},
+		},
+		status:   http.StatusOK,
+		response: HTTPDebugResponse{},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/debug/filters",
+		summary: "Explain whether the configured filters accept a deployment, job, AZ, process or IP (at least one is required)",
+		parameters: []parameter{
+			{name: "deployment", in: "query", description: "Deployment name", schemaType: "string"},
+			{name: "job", in: "query", description: "Job name, only used by filter expressions", schemaType: "string"},
+			{name: "az", in: "query", description: "AZ name, its cloud properties being given as `az_cloud_properties.<property>` parameters", schemaType: "string"},
+			{name: "process", in: "query", description: "Process name", schemaType: "string"},
+			{name: "ip", in: "query", description: "Instance IP", schemaType: "string"},
+		},
+		status:   http.StatusOK,
+		response: FiltersResponse{},
+	},
+	{
+		method:  http.MethodGet,
+		path:    "/debug/sd-diff",
+		summary: "Preview the Service Discovery targets the next write would add and remove, without writing them",
+		parameters: []parameter{
+			{name: "environment", in: "query", description: "Environment to preview, all of them when empty", schemaType: "string"},
+			{name: "expression", in: "query", description: "Filter expression to preview instead of the configured one", schemaType: "string"},
+		},
+		status:   http.StatusOK,
+		response: ServiceDiscoveryDiffResponse{},
+	},
+	{
+		method:      http.MethodPost,
+		path:        "/-/refresh",
+		summary:     "Query the BOSH Directors right away",
+		status:      http.StatusOK,
+		contentType: "text/plain",
+	},
+	{
+		method:   http.MethodPost,
+		path:     "/-/reload",
+		summary:  "Reload and validate the configuration, and report what changed since the last load",
+		status:   http.StatusOK,
+		response: ConfigReloadResponse{},
+	},
+}
+
+// OpenAPIDocument returns the OpenAPI 3 description of the exporter JSON API.
+// The schemas are generated from the response types of this package, so the
+// document and the client cannot drift apart.
+func OpenAPIDocument(version string) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+
+	for _, op := range operations {
+		pathItem, ok := paths[op.path].(map[string]interface{})
+		if !ok {
+			pathItem = map[string]interface{}{}
+			paths[op.path] = pathItem
+		}
+
+		operationObject := map[string]interface{}{
+			"summary":  op.summary,
+			"security": []interface{}{map[string]interface{}{}, map[string]interface{}{"basicAuth": []interface{}{}}},
+		}
+
+		if len(op.parameters) > 0 {
+			parameters := []interface{}{}
+			for _, p := range op.parameters {
+				parameters = append(parameters, map[string]interface{}{
+					"name":        p.name,
+					"in":          p.in,
+					"description": p.description,
+					"required":    p.required,
+					"schema":      map[string]interface{}{"type": p.schemaType},
+				})
+			}
+			operationObject["parameters"] = parameters
+		}
+
+		if len(op.formFields) > 0 {
+			properties := map[string]interface{}{}
+			required := []string{}
+			for _, field := range op.formFields {
+				properties[field.name] = map[string]interface{}{"type": field.schemaType, "description": field.description}
+				if field.required {
+					required = append(required, field.name)
+				}
+			}
+			operationObject["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/x-www-form-urlencoded": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "object", "properties": properties, "required": required},
+					},
+				},
+			}
+		}
+
+		content := map[string]interface{}{}
+		if op.response != nil {
+			content["application/json"] = map[string]interface{}{"schema": schemaFor(reflect.TypeOf(op.response), schemas)}
+		} else {
+			content[op.contentType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+		}
+		operationObject["responses"] = map[string]interface{}{
+			strconv.Itoa(op.status): map[string]interface{}{
+				"description": http.StatusText(op.status),
+				"content":     content,
+			},
+		}
+
+		pathItem[strings.ToLower(op.method)] = operationObject
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "BOSH Exporter API",
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"basicAuth": map[string]interface{}{"type": "http", "scheme": "basic"},
+			},
+		},
+	}
+}
+
+// OpenAPIHandler serves the OpenAPI document.
+func OpenAPIHandler(version string) http.Handler {
+	document, err := json.Marshal(OpenAPIDocument(version))
+	if err != nil {
+		log.Errorf("Error encoding the OpenAPI document: %v", err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	})
+}
+
+// schemaFor returns the JSON schema of a type, registering the structs as
+// named component schemas.
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = nil
+			properties := map[string]interface{}{}
+			required := []string{}
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				name, omitEmpty := jsonFieldName(field)
+				if name == "" {
+					continue
+				}
+				properties[name] = schemaFor(field.Type, schemas)
+				if !omitEmpty {
+					required = append(required, name)
+				}
+			}
+			schemas[t.Name()] = map[string]interface{}{"type": "object", "properties": properties, "required": required}
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	omitEmpty := false
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+
+	return name, omitEmpty
+}
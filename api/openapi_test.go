@@ -0,0 +1,57 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/api"
+)
+
+var _ = Describe("OpenAPI", func() {
+	var (
+		document map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		recorder := httptest.NewRecorder()
+		OpenAPIHandler("1.2.3").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &document)).To(Succeed())
+	})
+
+	It("describes the exporter API", func() {
+		Expect(document["openapi"]).To(Equal("3.0.3"))
+		Expect(document["info"]).To(HaveKeyWithValue("version", "1.2.3"))
+		Expect(document["paths"]).To(HaveKey("/api/v1/status/config"))
+		Expect(document["paths"]).To(HaveKey("/api/v1/deployments/{deployment}/scan"))
+		Expect(document["paths"]).To(HaveKey("/api/v1/debug/http"))
+		Expect(document["paths"]).To(HaveKey("/debug/filters"))
+		Expect(document["paths"]).To(HaveKey("/debug/sd-diff"))
+		Expect(document["paths"]).To(HaveKey("/-/refresh"))
+		Expect(document["paths"]).To(HaveKey("/-/reload"))
+		Expect(document["paths"].(map[string]interface{})["/api/v1/debug/http"]).To(And(HaveKey("get"), HaveKey("post")))
+	})
+
+	It("generates the schemas from the response types", func() {
+		schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+		Expect(schemas).To(HaveKey("StatusConfigResponse"))
+		Expect(schemas["EnvironmentFilters"]).To(Equal(map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"environment": map[string]interface{}{"type": "string"},
+				"bosh_name":   map[string]interface{}{"type": "string"},
+				"accepted":    map[string]interface{}{"type": "boolean"},
+				"filters": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"$ref": "#/components/schemas/FilterResult"},
+				},
+			},
+			"required": []interface{}{"environment", "bosh_name", "accepted", "filters"},
+		}))
+		Expect(schemas["FiltersResponse"].(map[string]interface{})["required"]).To(Equal([]interface{}{"status"}))
+	})
+})
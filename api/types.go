@@ -0,0 +1,107 @@
+package api
+
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+)
+
+type StatusConfigResponse struct {
+	Status string       `json:"status"`
+	Data   StatusConfig `json:"data"`
+}
+
+type StatusConfig struct {
+	Flags   map[string]string   `json:"flags"`
+	Filters map[string][]string `json:"filters"`
+}
+
+type DeploymentScanResponse struct {
+	Status string         `json:"status"`
+	Data   DeploymentScan `json:"data"`
+}
+
+type DeploymentScan struct {
+	Environment string `json:"environment"`
+	Deployment  string `json:"deployment"`
+}
+
+type HTTPDebugResponse struct {
+	Status string    `json:"status"`
+	Data   HTTPDebug `json:"data"`
+}
+
+type HTTPDebug struct {
+	Enabled bool `json:"enabled"`
+}
+
+type FiltersResponse struct {
+	Status string               `json:"status"`
+	Data   []EnvironmentFilters `json:"data,omitempty"`
+	Error  string               `json:"error,omitempty"`
+}
+
+type EnvironmentFilters struct {
+	Environment string         `json:"environment"`
+	BoshName    string         `json:"bosh_name"`
+	Accepted    bool           `json:"accepted"`
+	Filters     []FilterResult `json:"filters"`
+}
+
+type FilterResult struct {
+	Filter   string `json:"filter"`
+	Value    string `json:"value"`
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason"`
+}
+
+// FiltersQuery holds the values explained by the filters debug endpoint,
+// empty values are not sent.
+type FiltersQuery struct {
+	Deployment string
+	Job        string
+	AZ         string
+	Process    string
+	IP         string
+}
+
+type ServiceDiscoveryDiffResponse struct {
+	Status string                            `json:"status"`
+	Data   []EnvironmentServiceDiscoveryDiff `json:"data,omitempty"`
+	Error  string                            `json:"error,omitempty"`
+}
+
+type EnvironmentServiceDiscoveryDiff struct {
+	Environment string                   `json:"environment"`
+	Added       []ServiceDiscoveryTarget `json:"added"`
+	Removed     []ServiceDiscoveryTarget `json:"removed"`
+	Unchanged   int                      `json:"unchanged"`
+	Error       string                   `json:"error,omitempty"`
+}
+
+type ServiceDiscoveryTarget struct {
+	Target string            `json:"target"`
+	Labels map[string]string `json:"labels"`
+}
+
+// ConfigReloadResponse reports the changes of the reloaded configuration from
+// the running one. They are pending until the exporter restarts.
+type ConfigReloadResponse struct {
+	Status  string                     `json:"status"`
+	Pending bool                       `json:"pending"`
+	Data    []EnvironmentConfigChanges `json:"data,omitempty"`
+	Error   string                     `json:"error,omitempty"`
+}
+
+// EnvironmentConfigChanges are the changes of an environment found by a
+// configuration reload, from the running configuration. Credentials are never reported, only whether any of
+// them changed.
+type EnvironmentConfigChanges struct {
+	Environment        string              `json:"environment"`
+	URL                string              `json:"url"`
+	Added              bool                `json:"added,omitempty"`
+	Removed            bool                `json:"removed,omitempty"`
+	FiltersAdded       map[string][]string `json:"filters_added,omitempty"`
+	FiltersRemoved     map[string][]string `json:"filters_removed,omitempty"`
+	CredentialsRotated bool                `json:"credentials_rotated"`
+	Changed            []string            `json:"changed,omitempty"`
+}
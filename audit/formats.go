@@ -0,0 +1,202 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+)
+
+const (
+	FormatECS = "ecs"
+	FormatCEF = "cef"
+)
+
+// Source identifies the BOSH Director the events come from.
+type Source struct {
+	Environment string
+	BoshName    string
+	BoshUUID    string
+	BoshVersion string
+}
+
+// Record is a formatted event ready to be shipped.
+type Record struct {
+	Time    time.Time
+	Failed  bool
+	Payload []byte
+}
+
+type ecsDocument struct {
+	Timestamp string            `json:"@timestamp"`
+	Message   string            `json:"message"`
+	Event     ecsEvent          `json:"event"`
+	User      ecsUser           `json:"user"`
+	Observer  ecsObserver       `json:"observer"`
+	Labels    map[string]string `json:"labels"`
+	Error     *ecsError         `json:"error,omitempty"`
+	Bosh      ecsBosh           `json:"bosh"`
+}
+
+type ecsEvent struct {
+	ID       string   `json:"id"`
+	Kind     string   `json:"kind"`
+	Category []string `json:"category"`
+	Action   string   `json:"action"`
+	Outcome  string   `json:"outcome"`
+	Dataset  string   `json:"dataset"`
+}
+
+type ecsUser struct {
+	Name string `json:"name"`
+}
+
+type ecsObserver struct {
+	Vendor  string `json:"vendor"`
+	Product string `json:"product"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type ecsError struct {
+	Message string `json:"message"`
+}
+
+type ecsBosh struct {
+	DirectorUUID string                 `json:"director_uuid"`
+	ParentID     string                 `json:"parent_id,omitempty"`
+	ObjectType   string                 `json:"object_type"`
+	ObjectName   string                 `json:"object_name"`
+	TaskID       string                 `json:"task_id,omitempty"`
+	Deployment   string                 `json:"deployment,omitempty"`
+	Instance     string                 `json:"instance,omitempty"`
+	Context      map[string]interface{} `json:"context,omitempty"`
+}
+
+// FormatEvent formats a Director event in the ECS (Elastic Common Schema)
+// JSON or the CEF (ArcSight Common Event Format) format.
+func FormatEvent(format string, source Source, event director.Event) (Record, error) {
+	record := Record{Time: event.Timestamp(), Failed: event.Error() != ""}
+
+	switch format {
+	case FormatECS:
+		payload, err := formatECS(source, event)
+		if err != nil {
+			return Record{}, errors.New(fmt.Sprintf("Error formatting BOSH event `%s`: %v", event.ID(), err))
+		}
+		record.Payload = payload
+	case FormatCEF:
+		record.Payload = formatCEF(source, event)
+	default:
+		return Record{}, errors.New(fmt.Sprintf("Unknown audit format `%s`", format))
+	}
+
+	return record, nil
+}
+
+func formatECS(source Source, event director.Event) ([]byte, error) {
+	outcome := "success"
+	var ecsErr *ecsError
+	if event.Error() != "" {
+		outcome = "failure"
+		ecsErr = &ecsError{Message: event.Error()}
+	}
+
+	return json.Marshal(ecsDocument{
+		Timestamp: event.Timestamp().UTC().Format(time.RFC3339),
+		Message:   eventMessage(event),
+		Event: ecsEvent{
+			ID:       event.ID(),
+			Kind:     "event",
+			Category: []string{"configuration"},
+			Action:   event.Action(),
+			Outcome:  outcome,
+			Dataset:  "bosh.audit",
+		},
+		User: ecsUser{Name: event.User()},
+		Observer: ecsObserver{
+			Vendor:  "BOSH",
+			Product: "Director",
+			Name:    source.BoshName,
+			Version: source.BoshVersion,
+		},
+		Labels: map[string]string{"environment": source.Environment},
+		Error:  ecsErr,
+		Bosh: ecsBosh{
+			DirectorUUID: source.BoshUUID,
+			ParentID:     event.ParentID(),
+			ObjectType:   event.ObjectType(),
+			ObjectName:   event.ObjectName(),
+			TaskID:       event.TaskID(),
+			Deployment:   event.DeploymentName(),
+			Instance:     event.Instance(),
+			Context:      event.Context(),
+		},
+	})
+}
+
+func formatCEF(source Source, event director.Event) []byte {
+	severity := "3"
+	if event.Error() != "" {
+		severity = "7"
+	}
+
+	header := []string{
+		"CEF:0",
+		cefHeaderEscaper.Replace("BOSH"),
+		cefHeaderEscaper.Replace("Director"),
+		cefHeaderEscaper.Replace(source.BoshVersion),
+		cefHeaderEscaper.Replace(event.ObjectType() + ":" + event.Action()),
+		cefHeaderEscaper.Replace(eventMessage(event)),
+		severity,
+	}
+
+	extensions := []struct {
+		key   string
+		label string
+		value string
+	}{
+		{key: "rt", value: strconv.FormatInt(event.Timestamp().UnixNano()/int64(time.Millisecond), 10)},
+		{key: "externalId", value: event.ID()},
+		{key: "act", value: event.Action()},
+		{key: "suser", value: event.User()},
+		{key: "dvchost", value: source.BoshName},
+		{key: "cs1", label: "environment", value: source.Environment},
+		{key: "cs2", label: "deployment", value: event.DeploymentName()},
+		{key: "cs3", label: "instance", value: event.Instance()},
+		{key: "cs4", label: "object", value: event.ObjectType() + " " + event.ObjectName()},
+		{key: "cs5", label: "task", value: event.TaskID()},
+		{key: "outcome", value: map[bool]string{true: "failure", false: "success"}[event.Error() != ""]},
+		{key: "msg", value: event.Error()},
+	}
+
+	pairs := []string{}
+	for _, extension := range extensions {
+		if extension.value == "" {
+			continue
+		}
+		if extension.label != "" {
+			pairs = append(pairs, extension.key+"Label="+extension.label)
+		}
+		pairs = append(pairs, extension.key+"="+cefExtensionEscaper.Replace(extension.value))
+	}
+
+	return []byte(strings.Join(header, "|") + "|" + strings.Join(pairs, " "))
+}
+
+func eventMessage(event director.Event) string {
+	message := fmt.Sprintf("BOSH %s %s %s", event.Action(), event.ObjectType(), event.ObjectName())
+	if event.User() != "" {
+		message += " by " + event.User()
+	}
+
+	return message
+}
+
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+
+var cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
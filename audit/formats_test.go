@@ -0,0 +1,90 @@
+package audit_test
+
+import (
+	"time"
+
+	. "github.com/benjamintf1/unmarshalledmatchers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/director/directorfakes"
+
+	. "github.com/bosh-prometheus/bosh_exporter/audit"
+)
+
+func fakeEvent(id string, timestamp time.Time, eventError string) director.Event {
+	event := &directorfakes.FakeEvent{}
+	event.IDReturns(id)
+	event.TimestampReturns(timestamp)
+	event.UserReturns("admin")
+	event.ActionReturns("update")
+	event.ObjectTypeReturns("deployment")
+	event.ObjectNameReturns("cf")
+	event.TaskIDReturns("42")
+	event.DeploymentNameReturns("cf")
+	event.ContextReturns(map[string]interface{}{"new name": "cf"})
+	event.ErrorReturns(eventError)
+	return event
+}
+
+var _ = Describe("FormatEvent", func() {
+	var (
+		source    Source
+		timestamp time.Time
+	)
+
+	BeforeEach(func() {
+		source = Source{Environment: "prod", BoshName: "bosh", BoshUUID: "uuid", BoshVersion: "270.1.0"}
+		timestamp = time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC)
+	})
+
+	Context("with the ECS format", func() {
+		It("returns an ECS document", func() {
+			record, err := FormatEvent(FormatECS, source, fakeEvent("1234", timestamp, ""))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(record.Time).To(Equal(timestamp))
+			Expect(record.Failed).To(BeFalse())
+			Expect(string(record.Payload)).To(MatchUnorderedJSON(`{
+				"@timestamp": "2026-10-16T12:30:00Z",
+				"message": "BOSH update deployment cf by admin",
+				"event": {"id": "1234", "kind": "event", "category": ["configuration"], "action": "update", "outcome": "success", "dataset": "bosh.audit"},
+				"user": {"name": "admin"},
+				"observer": {"vendor": "BOSH", "product": "Director", "name": "bosh", "version": "270.1.0"},
+				"labels": {"environment": "prod"},
+				"bosh": {"director_uuid": "uuid", "object_type": "deployment", "object_name": "cf", "task_id": "42", "deployment": "cf", "context": {"new name": "cf"}}
+			}`))
+		})
+
+		It("reports the error of a failed event", func() {
+			record, err := FormatEvent(FormatECS, source, fakeEvent("1234", timestamp, "Timed out"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(record.Failed).To(BeTrue())
+			Expect(string(record.Payload)).To(ContainSubstring(`"outcome":"failure"`))
+			Expect(string(record.Payload)).To(ContainSubstring(`"error":{"message":"Timed out"}`))
+		})
+	})
+
+	Context("with the CEF format", func() {
+		It("returns a CEF line", func() {
+			record, err := FormatEvent(FormatCEF, source, fakeEvent("1234", timestamp, ""))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(record.Payload)).To(Equal(
+				"CEF:0|BOSH|Director|270.1.0|deployment:update|BOSH update deployment cf by admin|3|" +
+					"rt=1792153800000 externalId=1234 act=update suser=admin dvchost=bosh cs1Label=environment cs1=prod " +
+					"cs2Label=deployment cs2=cf cs4Label=object cs4=deployment cf cs5Label=task cs5=42 outcome=success",
+			))
+		})
+
+		It("escapes the special characters", func() {
+			record, err := FormatEvent(FormatCEF, source, fakeEvent("1234", timestamp, "a=b\nc|d"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(record.Payload)).To(HaveSuffix(`|7|rt=1792153800000 externalId=1234 act=update suser=admin dvchost=bosh cs1Label=environment cs1=prod cs2Label=deployment cs2=cf cs4Label=object cs4=deployment cf cs5Label=task cs5=42 outcome=failure msg=a\=b\nc|d`))
+		})
+	})
+
+	It("returns an error for an unknown format", func() {
+		_, err := FormatEvent("leef", source, fakeEvent("1234", timestamp, ""))
+		Expect(err).To(MatchError("Unknown audit format `leef`"))
+	})
+})
@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+const auditEventsMaxPages = 10
+
+// Forwarder polls the events of a BOSH Director and ships the new ones,
+// oldest first, to a SIEM. Only the events recorded after the forwarder was
+// created are shipped, and events failing to ship are retried at the next
+// poll.
+type Forwarder struct {
+	boshClient director.Director
+	source     Source
+	format     string
+	shipper    Shipper
+	interval   time.Duration
+	after      time.Time
+	lastID     int64
+
+	forwardedEventsMetric prometheus.Counter
+	forwardErrorsMetric   prometheus.Counter
+	mu                    *sync.Mutex
+}
+
+func NewForwarder(
+	namespace string,
+	source Source,
+	boshClient director.Director,
+	format string,
+	shipper Shipper,
+	interval time.Duration,
+) *Forwarder {
+	constLabels := prometheus.Labels{
+		"environment": source.Environment,
+		"bosh_name":   source.BoshName,
+		"bosh_uuid":   source.BoshUUID,
+	}
+
+	forwardedEventsMetric := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "exporter",
+			Name:        "audit_events_forwarded_total",
+			Help:        "Total number of BOSH Director events forwarded to the audit endpoint.",
+			ConstLabels: constLabels,
+		},
+	)
+
+	forwardErrorsMetric := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "exporter",
+			Name:        "audit_forward_errors_total",
+			Help:        "Total number of errors reading the BOSH Director events or forwarding them to the audit endpoint.",
+			ConstLabels: constLabels,
+		},
+	)
+
+	return &Forwarder{
+		boshClient:            boshClient,
+		source:                source,
+		format:                format,
+		shipper:               shipper,
+		interval:              interval,
+		after:                 time.Now(),
+		forwardedEventsMetric: forwardedEventsMetric,
+		forwardErrorsMetric:   forwardErrorsMetric,
+		mu:                    &sync.Mutex{},
+	}
+}
+
+// Run forwards the events every interval until stop is closed.
+func (f *Forwarder) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := f.Forward(); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+// Forward ships the events recorded since the last forwarded one.
+func (f *Forwarder) Forward() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	filter := director.EventsFilter{After: f.after.UTC().Format(time.RFC3339)}
+	events, err := fetcher.ReadEvents(f.boshClient, filter, auditEventsMaxPages)
+	if err != nil {
+		f.forwardErrorsMetric.Inc()
+		return err
+	}
+	if len(events) == auditEventsMaxPages*fetcher.EventsPageSize {
+		log.Warnf("More than %d events recorded by BOSH Director `%s` since the last poll, the oldest ones are not forwarded", len(events), f.source.BoshName)
+	}
+
+	newEvents := []identifiedEvent{}
+	for _, event := range events {
+		id, err := strconv.ParseInt(event.ID(), 10, 64)
+		if err != nil || id <= f.lastID {
+			continue
+		}
+		newEvents = append(newEvents, identifiedEvent{id: id, event: event})
+	}
+	if len(newEvents) == 0 {
+		return nil
+	}
+	sort.Slice(newEvents, func(i, j int) bool {
+		return newEvents[i].id < newEvents[j].id
+	})
+
+	records := make([]Record, 0, len(newEvents))
+	for _, newEvent := range newEvents {
+		record, err := FormatEvent(f.format, f.source, newEvent.event)
+		if err != nil {
+			f.forwardErrorsMetric.Inc()
+			return err
+		}
+		records = append(records, record)
+	}
+
+	if err := f.shipper.Ship(records); err != nil {
+		f.forwardErrorsMetric.Inc()
+		return err
+	}
+	f.forwardedEventsMetric.Add(float64(len(records)))
+
+	lastEvent := newEvents[len(newEvents)-1]
+	f.lastID = lastEvent.id
+	// The Director filters the events by second, so events recorded in the
+	// same second as the last one are read again and skipped by ID.
+	if lastEvent.event.Timestamp().After(f.after) {
+		f.after = lastEvent.event.Timestamp().Add(-time.Second)
+	}
+
+	return nil
+}
+
+type identifiedEvent struct {
+	id    int64
+	event director.Event
+}
+
+func (f *Forwarder) Describe(ch chan<- *prometheus.Desc) {
+	f.forwardedEventsMetric.Describe(ch)
+	f.forwardErrorsMetric.Describe(ch)
+}
+
+func (f *Forwarder) Collect(ch chan<- prometheus.Metric) {
+	f.forwardedEventsMetric.Collect(ch)
+	f.forwardErrorsMetric.Collect(ch)
+}
@@ -0,0 +1,138 @@
+package audit_test
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/director/directorfakes"
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/bosh-prometheus/bosh_exporter/audit"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+var externalID = regexp.MustCompile(`externalId=(\d+)`)
+
+type fakeShipper struct {
+	shipped []Record
+	err     error
+}
+
+func (s *fakeShipper) Ship(records []Record) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.shipped = append(s.shipped, records...)
+	return nil
+}
+
+var _ = Describe("Forwarder", func() {
+	var (
+		source     Source
+		boshClient *directorfakes.FakeDirector
+		shipper    *fakeShipper
+		forwarder  *Forwarder
+		now        time.Time
+
+		forwardedEventsMetric prometheus.Counter
+		forwardErrorsMetric   prometheus.Counter
+	)
+
+	shippedIDs := func() []string {
+		ids := []string{}
+		for _, record := range shipper.shipped {
+			ids = append(ids, externalID.FindStringSubmatch(string(record.Payload))[1])
+		}
+		return ids
+	}
+
+	BeforeEach(func() {
+		source = Source{Environment: "test_environment", BoshName: "test_bosh_name", BoshUUID: "test_bosh_uuid"}
+		boshClient = &directorfakes.FakeDirector{}
+		shipper = &fakeShipper{}
+		now = time.Now().Truncate(time.Second)
+
+		constLabels := prometheus.Labels{"environment": "test_environment", "bosh_name": "test_bosh_name", "bosh_uuid": "test_bosh_uuid"}
+		forwardedEventsMetric = prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   "test_exporter",
+			Subsystem:   "exporter",
+			Name:        "audit_events_forwarded_total",
+			Help:        "Total number of BOSH Director events forwarded to the audit endpoint.",
+			ConstLabels: constLabels,
+		})
+		forwardErrorsMetric = prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   "test_exporter",
+			Subsystem:   "exporter",
+			Name:        "audit_forward_errors_total",
+			Help:        "Total number of errors reading the BOSH Director events or forwarding them to the audit endpoint.",
+			ConstLabels: constLabels,
+		})
+	})
+
+	JustBeforeEach(func() {
+		forwarder = NewForwarder("test_exporter", source, boshClient, FormatCEF, shipper, time.Minute)
+	})
+
+	It("ships the new events oldest first", func() {
+		boshClient.EventsReturns([]director.Event{fakeEvent("1002", now, ""), fakeEvent("1001", now, "")}, nil)
+
+		Expect(forwarder.Forward()).To(Succeed())
+		Expect(shippedIDs()).To(Equal([]string{"1001", "1002"}))
+	})
+
+	It("only reads the events recorded since it was created", func() {
+		Expect(forwarder.Forward()).To(Succeed())
+		after, err := time.Parse(time.RFC3339, boshClient.EventsArgsForCall(0).After)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(after).To(BeTemporally("~", time.Now(), 2*time.Second))
+	})
+
+	It("does not ship the same event twice", func() {
+		later := now.Add(time.Minute)
+		boshClient.EventsReturnsOnCall(0, []director.Event{fakeEvent("1001", later, "")}, nil)
+		boshClient.EventsReturnsOnCall(1, []director.Event{fakeEvent("1002", later, ""), fakeEvent("1001", later, "")}, nil)
+
+		Expect(forwarder.Forward()).To(Succeed())
+		Expect(forwarder.Forward()).To(Succeed())
+		Expect(shippedIDs()).To(Equal([]string{"1001", "1002"}))
+		Expect(boshClient.EventsArgsForCall(1).After).To(Equal(later.Add(-time.Second).UTC().Format(time.RFC3339)))
+	})
+
+	It("returns an audit_events_forwarded_total metric", func() {
+		boshClient.EventsReturns([]director.Event{fakeEvent("1001", now, "")}, nil)
+		Expect(forwarder.Forward()).To(Succeed())
+
+		metrics := make(chan prometheus.Metric)
+		go forwarder.Collect(metrics)
+		forwardedEventsMetric.Inc()
+		Eventually(metrics).Should(Receive(PrometheusMetric(forwardedEventsMetric)))
+	})
+
+	Context("when shipping fails", func() {
+		BeforeEach(func() {
+			boshClient.EventsReturns([]director.Event{fakeEvent("1001", now, "")}, nil)
+			shipper.err = errors.New("no SIEM")
+		})
+
+		It("ships the events again at the next poll", func() {
+			Expect(forwarder.Forward()).To(MatchError("no SIEM"))
+			shipper.err = nil
+			Expect(forwarder.Forward()).To(Succeed())
+			Expect(shippedIDs()).To(Equal([]string{"1001"}))
+		})
+
+		It("returns an audit_forward_errors_total metric", func() {
+			forwarder.Forward()
+
+			metrics := make(chan prometheus.Metric)
+			go forwarder.Collect(metrics)
+			forwardErrorsMetric.Inc()
+			Eventually(metrics).Should(Receive(PrometheusMetric(forwardErrorsMetric)))
+		})
+	})
+})
@@ -0,0 +1,177 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+const (
+	syslogFacilityLogAudit = 13
+	syslogSeverityWarning  = 4
+	syslogSeverityInfo     = 6
+	syslogAppName          = "bosh_exporter"
+	syslogMsgID            = "bosh-audit"
+	shipTimeout            = 30 * time.Second
+)
+
+// Shipper sends formatted events to a SIEM.
+type Shipper interface {
+	Ship(records []Record) error
+}
+
+// NewShipper returns the shipper of an endpoint URL: `syslog+udp://host:port`
+// or `syslog+tcp://host:port` for RFC 5424 syslog, `http(s)://...` to POST
+// the records newline delimited.
+func NewShipper(endpoint string, httpClient *http.Client) (Shipper, error) {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing audit endpoint `%s`: %v", endpoint, err))
+	}
+
+	switch endpointURL.Scheme {
+	case "syslog+udp", "syslog+tcp":
+		if endpointURL.Host == "" {
+			return nil, errors.New(fmt.Sprintf("Audit endpoint `%s` has no host", endpoint))
+		}
+		return NewSyslogShipper(strings.TrimPrefix(endpointURL.Scheme, "syslog+"), endpointURL.Host), nil
+	case "http", "https":
+		return NewHTTPShipper(endpointURL, httpClient), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unsupported audit endpoint scheme `%s`, must be syslog+udp, syslog+tcp, http or https", endpointURL.Scheme))
+	}
+}
+
+// SyslogShipper sends every record as an RFC 5424 message of the log audit
+// facility, one datagram per message over UDP and octet counted (RFC 6587)
+// over TCP.
+type SyslogShipper struct {
+	network  string
+	address  string
+	hostname string
+	conn     net.Conn
+	mu       *sync.Mutex
+}
+
+func NewSyslogShipper(network string, address string) *SyslogShipper {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &SyslogShipper{network: network, address: address, hostname: hostname, mu: &sync.Mutex{}}
+}
+
+func (s *SyslogShipper) Ship(records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		if err := s.send(s.message(record)); err != nil {
+			return errors.New(fmt.Sprintf("Error sending audit events to syslog `%s`: %v", s.address, err))
+		}
+	}
+
+	return nil
+}
+
+func (s *SyslogShipper) message(record Record) []byte {
+	severity := syslogSeverityInfo
+	if record.Failed {
+		severity = syslogSeverityWarning
+	}
+
+	message := fmt.Sprintf("<%d>1 %s %s %s - %s - %s",
+		syslogFacilityLogAudit*8+severity,
+		record.Time.UTC().Format(time.RFC3339),
+		s.hostname,
+		syslogAppName,
+		syslogMsgID,
+		record.Payload,
+	)
+	if s.network == "tcp" {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+
+	return []byte(message)
+}
+
+// Close closes the connection to the syslog server.
+func (s *SyslogShipper) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+
+	return err
+}
+
+// send writes a message, reconnecting once when the connection was lost.
+func (s *SyslogShipper) send(message []byte) error {
+	for attempt := 0; ; attempt++ {
+		if s.conn == nil {
+			conn, err := net.DialTimeout(s.network, s.address, shipTimeout)
+			if err != nil {
+				return err
+			}
+			s.conn = conn
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(shipTimeout))
+		_, err := s.conn.Write(message)
+		if err == nil {
+			return nil
+		}
+
+		s.conn.Close()
+		s.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+// HTTPShipper POSTs the records newline delimited in a single request.
+type HTTPShipper struct {
+	url        *url.URL
+	httpClient *http.Client
+}
+
+func NewHTTPShipper(url *url.URL, httpClient *http.Client) *HTTPShipper {
+	return &HTTPShipper{url: url, httpClient: httpClient}
+}
+
+func (s *HTTPShipper) Ship(records []Record) error {
+	body := &bytes.Buffer{}
+	for _, record := range records {
+		body.Write(record.Payload)
+		body.WriteByte('\n')
+	}
+
+	resp, err := s.httpClient.Post(s.url.String(), "application/x-ndjson", body)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error sending audit events to `%s`: %v", fetcher.RedactURL(s.url), err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return errors.New(fmt.Sprintf("Error sending audit events to `%s`: %s: %s", fetcher.RedactURL(s.url), resp.Status, strings.TrimSpace(string(message))))
+	}
+
+	return nil
+}
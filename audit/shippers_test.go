@@ -0,0 +1,131 @@
+package audit_test
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/audit"
+)
+
+var _ = Describe("NewShipper", func() {
+	It("returns a syslog shipper for syslog URLs", func() {
+		shipper, err := NewShipper("syslog+tcp://siem:601", http.DefaultClient)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shipper).To(BeAssignableToTypeOf(&SyslogShipper{}))
+	})
+
+	It("returns an HTTP shipper for HTTP URLs", func() {
+		shipper, err := NewShipper("https://siem/ingest", http.DefaultClient)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shipper).To(BeAssignableToTypeOf(&HTTPShipper{}))
+	})
+
+	It("returns an error for other schemes", func() {
+		_, err := NewShipper("kafka://siem:9092", http.DefaultClient)
+		Expect(err).To(MatchError(ContainSubstring("Unsupported audit endpoint scheme `kafka`")))
+	})
+})
+
+var _ = Describe("SyslogShipper", func() {
+	var (
+		records []Record
+	)
+
+	BeforeEach(func() {
+		records = []Record{
+			{Time: time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC), Payload: []byte(`{"id":1}`)},
+			{Time: time.Date(2026, 10, 16, 12, 31, 0, 0, time.UTC), Failed: true, Payload: []byte(`{"id":2}`)},
+		}
+	})
+
+	It("sends an octet counted RFC 5424 message per record over TCP", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer listener.Close()
+
+		received := make(chan string, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			content, _ := ioutil.ReadAll(bufio.NewReader(conn))
+			received <- string(content)
+		}()
+
+		shipper := NewSyslogShipper("tcp", listener.Addr().String())
+		Expect(shipper.Ship(records)).To(Succeed())
+		Expect(shipper.Close()).To(Succeed())
+
+		var content string
+		Eventually(received).Should(Receive(&content))
+		Expect(content).To(MatchRegexp(`^\d+ <110>1 2026-10-16T12:30:00Z \S+ bosh_exporter - bosh-audit - \{"id":1\}\d+ <108>1 2026-10-16T12:31:00Z \S+ bosh_exporter - bosh-audit - \{"id":2\}$`))
+	})
+
+	It("sends a datagram per record over UDP", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		shipper := NewSyslogShipper("udp", conn.LocalAddr().String())
+		Expect(shipper.Ship(records[:1])).To(Succeed())
+
+		buffer := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buffer)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(buffer[:n])).To(MatchRegexp(`^<110>1 2026-10-16T12:30:00Z \S+ bosh_exporter - bosh-audit - \{"id":1\}$`))
+	})
+})
+
+var _ = Describe("HTTPShipper", func() {
+	var (
+		server     *httptest.Server
+		statusCode int
+		bodies     []string
+	)
+
+	BeforeEach(func() {
+		statusCode = http.StatusOK
+		bodies = []string{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, r.Header.Get("Content-Type")+" "+string(body))
+			w.WriteHeader(statusCode)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("posts the records newline delimited", func() {
+		shipper, err := NewShipper(server.URL, http.DefaultClient)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shipper.Ship([]Record{{Payload: []byte(`{"id":1}`)}, {Payload: []byte(`{"id":2}`)}})).To(Succeed())
+		Expect(bodies).To(Equal([]string{"application/x-ndjson {\"id\":1}\n{\"id\":2}\n"}))
+	})
+
+	Context("when the endpoint fails", func() {
+		BeforeEach(func() {
+			statusCode = http.StatusServiceUnavailable
+		})
+
+		It("returns an error without the URL password", func() {
+			shipper, err := NewShipper("http://user:secret@"+server.Listener.Addr().String(), http.DefaultClient)
+			Expect(err).ToNot(HaveOccurred())
+			err = shipper.Ship([]Record{{Payload: []byte(`{"id":1}`)}})
+			Expect(err).To(MatchError(ContainSubstring("503 Service Unavailable")))
+			Expect(err.Error()).ToNot(ContainSubstring("secret"))
+		})
+	})
+})
@@ -0,0 +1,5 @@
+package authenticators
+
+type Authenticator interface {
+	TokenFunc(retried bool) (string, error)
+}
@@ -0,0 +1,195 @@
+package authenticators
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OIDCClientCredentialsGrant = "client_credentials"
+	OIDCTokenExchangeGrant     = "token_exchange"
+
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	jwtTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+	accessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+	tokenExpiryLeeway      = 30 * time.Second
+	defaultTokenLifetime   = 5 * time.Minute
+)
+
+type OIDCConfig struct {
+	IssuerURL        string
+	ClientID         string
+	ClientSecret     string
+	Audience         string
+	Scopes           []string
+	GrantType        string
+	SubjectTokenFile string
+}
+
+type OIDCAuthenticator struct {
+	config        OIDCConfig
+	httpClient    *http.Client
+	now           func() time.Time
+	tokenEndpoint string
+	token         string
+	tokenExpiry   time.Time
+	mu            *sync.Mutex
+}
+
+type oidcDiscovery struct {
+	TokenEndpoint string `json:"token_endpoint"`
+}
+
+type oidcToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func NewOIDCAuthenticator(config OIDCConfig, httpClient *http.Client) (*OIDCAuthenticator, error) {
+	config.IssuerURL = strings.TrimSuffix(config.IssuerURL, "/")
+	if config.GrantType == "" {
+		config.GrantType = OIDCClientCredentialsGrant
+	}
+
+	switch config.GrantType {
+	case OIDCClientCredentialsGrant:
+		if config.ClientID == "" || config.ClientSecret == "" {
+			return nil, errors.New("OIDC client credentials grant requires a client ID and a client secret")
+		}
+	case OIDCTokenExchangeGrant:
+		if config.SubjectTokenFile == "" {
+			return nil, errors.New("OIDC token exchange grant requires a subject token file")
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("OIDC grant type `%s` is not supported, must be `%s` or `%s`", config.GrantType, OIDCClientCredentialsGrant, OIDCTokenExchangeGrant))
+	}
+
+	return &OIDCAuthenticator{config: config, httpClient: httpClient, now: time.Now, mu: &sync.Mutex{}}, nil
+}
+
+func (a *OIDCAuthenticator) TokenFunc(retried bool) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if retried || a.token == "" || !a.now().Add(tokenExpiryLeeway).Before(a.tokenExpiry) {
+		if err := a.refreshToken(); err != nil {
+			return "", err
+		}
+	}
+
+	return "Bearer " + a.token, nil
+}
+
+func (a *OIDCAuthenticator) refreshToken() error {
+	if a.tokenEndpoint == "" {
+		tokenEndpoint, err := a.discoverTokenEndpoint()
+		if err != nil {
+			return err
+		}
+		a.tokenEndpoint = tokenEndpoint
+	}
+
+	form, err := a.tokenRequestForm()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", a.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while requesting OIDC token: %v", err))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if a.config.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(a.config.ClientID), url.QueryEscape(a.config.ClientSecret))
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while requesting OIDC token from `%s`: %v", a.tokenEndpoint, err))
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New(fmt.Sprintf("Error while requesting OIDC token from `%s`: %s: %s", a.tokenEndpoint, resp.Status, string(body)))
+	}
+
+	var token oidcToken
+	if err := json.Unmarshal(body, &token); err != nil {
+		return errors.New(fmt.Sprintf("Error while parsing OIDC token from `%s`: %v", a.tokenEndpoint, err))
+	}
+	if token.AccessToken == "" {
+		return errors.New(fmt.Sprintf("OIDC token response from `%s` does not contain an access token", a.tokenEndpoint))
+	}
+
+	lifetime := time.Duration(token.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = defaultTokenLifetime
+	}
+
+	a.token = token.AccessToken
+	a.tokenExpiry = a.now().Add(lifetime)
+
+	return nil
+}
+
+func (a *OIDCAuthenticator) tokenRequestForm() (url.Values, error) {
+	form := url.Values{}
+	if a.config.Audience != "" {
+		form.Set("audience", a.config.Audience)
+	}
+	if len(a.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.config.Scopes, " "))
+	}
+
+	if a.config.GrantType == OIDCClientCredentialsGrant {
+		form.Set("grant_type", "client_credentials")
+		return form, nil
+	}
+
+	subjectToken, err := ioutil.ReadFile(a.config.SubjectTokenFile)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error reading OIDC subject token file `%s`: %v", a.config.SubjectTokenFile, err))
+	}
+
+	form.Set("grant_type", tokenExchangeGrantType)
+	form.Set("subject_token", strings.TrimSpace(string(subjectToken)))
+	form.Set("subject_token_type", jwtTokenType)
+	form.Set("requested_token_type", accessTokenType)
+
+	return form, nil
+}
+
+func (a *OIDCAuthenticator) discoverTokenEndpoint() (string, error) {
+	discoveryURL := a.config.IssuerURL + "/.well-known/openid-configuration"
+
+	resp, err := a.httpClient.Get(discoveryURL)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Error while reading OIDC discovery document `%s`: %v", discoveryURL, err))
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", errors.New(fmt.Sprintf("Error while reading OIDC discovery document `%s`: %s", discoveryURL, resp.Status))
+	}
+
+	var discovery oidcDiscovery
+	if err := json.Unmarshal(body, &discovery); err != nil {
+		return "", errors.New(fmt.Sprintf("Error while parsing OIDC discovery document `%s`: %v", discoveryURL, err))
+	}
+	if discovery.TokenEndpoint == "" {
+		return "", errors.New(fmt.Sprintf("OIDC discovery document `%s` does not contain a token endpoint", discoveryURL))
+	}
+
+	return discovery.TokenEndpoint, nil
+}
@@ -0,0 +1,172 @@
+package authenticators_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/authenticators"
+)
+
+type tokenRequest struct {
+	Auth string
+	Form url.Values
+}
+
+var _ = Describe("OIDCAuthenticator", func() {
+	var (
+		err               error
+		server            *httptest.Server
+		tokenRequests     chan tokenRequest
+		tokenResponseCode int
+		config            OIDCConfig
+		oidcAuthenticator *OIDCAuthenticator
+	)
+
+	BeforeEach(func() {
+		tokenRequests = make(chan tokenRequest, 10)
+		tokenResponseCode = http.StatusOK
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"issuer":"` + server.URL + `","token_endpoint":"` + server.URL + `/oauth/token"}`))
+		})
+		mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			tokenRequests <- tokenRequest{Auth: r.Header.Get("Authorization"), Form: r.PostForm}
+			w.WriteHeader(tokenResponseCode)
+			w.Write([]byte(`{"access_token":"fake-access-token","token_type":"bearer","expires_in":3600}`))
+		})
+		server = httptest.NewServer(mux)
+
+		config = OIDCConfig{
+			IssuerURL:    server.URL + "/",
+			ClientID:     "bosh_exporter",
+			ClientSecret: "secret",
+			Audience:     "bosh",
+			Scopes:       []string{"bosh.read", "openid"},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		oidcAuthenticator, err = NewOIDCAuthenticator(config, http.DefaultClient)
+	})
+
+	Describe("NewOIDCAuthenticator", func() {
+		Context("when the grant type is not supported", func() {
+			BeforeEach(func() {
+				config.GrantType = "password"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("is not supported"))
+			})
+		})
+
+		Context("when the client credentials are missing", func() {
+			BeforeEach(func() {
+				config.ClientSecret = ""
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("TokenFunc", func() {
+		It("requests a token with the client credentials grant", func() {
+			Expect(err).ToNot(HaveOccurred())
+
+			authHeader, err := oidcAuthenticator.TokenFunc(false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(authHeader).To(Equal("Bearer fake-access-token"))
+
+			var request tokenRequest
+			Eventually(tokenRequests).Should(Receive(&request))
+			Expect(request.Auth).To(HavePrefix("Basic "))
+			Expect(request.Form.Get("grant_type")).To(Equal("client_credentials"))
+			Expect(request.Form.Get("audience")).To(Equal("bosh"))
+			Expect(request.Form.Get("scope")).To(Equal("bosh.read openid"))
+		})
+
+		It("reuses the token until it expires", func() {
+			_, err := oidcAuthenticator.TokenFunc(false)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = oidcAuthenticator.TokenFunc(false)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(tokenRequests).Should(Receive())
+			Consistently(tokenRequests).ShouldNot(Receive())
+		})
+
+		It("requests a new token when the request is retried", func() {
+			_, err := oidcAuthenticator.TokenFunc(false)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = oidcAuthenticator.TokenFunc(true)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(tokenRequests).Should(Receive())
+			Eventually(tokenRequests).Should(Receive())
+		})
+
+		Context("when the token request fails", func() {
+			BeforeEach(func() {
+				tokenResponseCode = http.StatusUnauthorized
+			})
+
+			It("returns an error", func() {
+				_, err := oidcAuthenticator.TokenFunc(false)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("401 Unauthorized"))
+			})
+		})
+
+		Context("when using the token exchange grant", func() {
+			var (
+				subjectTokenFile *os.File
+			)
+
+			BeforeEach(func() {
+				subjectTokenFile, err = ioutil.TempFile("", "oidc_authenticator_test_")
+				Expect(err).ToNot(HaveOccurred())
+				subjectTokenFile.Write([]byte("fake-subject-token\n"))
+				subjectTokenFile.Close()
+
+				config.GrantType = OIDCTokenExchangeGrant
+				config.ClientID = ""
+				config.ClientSecret = ""
+				config.SubjectTokenFile = subjectTokenFile.Name()
+			})
+
+			AfterEach(func() {
+				os.Remove(subjectTokenFile.Name())
+			})
+
+			It("exchanges the subject token", func() {
+				Expect(err).ToNot(HaveOccurred())
+
+				authHeader, err := oidcAuthenticator.TokenFunc(false)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(authHeader).To(Equal("Bearer fake-access-token"))
+
+				var request tokenRequest
+				Eventually(tokenRequests).Should(Receive(&request))
+				Expect(request.Auth).To(BeEmpty())
+				Expect(request.Form.Get("grant_type")).To(Equal("urn:ietf:params:oauth:grant-type:token-exchange"))
+				Expect(request.Form.Get("subject_token")).To(Equal("fake-subject-token"))
+				Expect(request.Form.Get("subject_token_type")).To(Equal("urn:ietf:params:oauth:token-type:jwt"))
+			})
+		})
+	})
+})
@@ -1,30 +1,128 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cloudfoundry/bosh-cli/director"
 	"github.com/cloudfoundry/bosh-cli/uaa"
 	"github.com/cloudfoundry/bosh-utils/logger"
-	"github.com/cloudfoundry/bosh-utils/system"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/log"
 	"github.com/prometheus/common/version"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 
+	"github.com/bosh-prometheus/bosh_exporter/api"
+	"github.com/bosh-prometheus/bosh_exporter/audit"
+	"github.com/bosh-prometheus/bosh_exporter/authenticators"
 	"github.com/bosh-prometheus/bosh_exporter/collectors"
 	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/environments"
+	"github.com/bosh-prometheus/bosh_exporter/exposition"
+	"github.com/bosh-prometheus/bosh_exporter/features"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
 	"github.com/bosh-prometheus/bosh_exporter/filters"
+	"github.com/bosh-prometheus/bosh_exporter/instrumentation"
+	"github.com/bosh-prometheus/bosh_exporter/profiles"
+	"github.com/bosh-prometheus/bosh_exporter/proxies"
+	"github.com/bosh-prometheus/bosh_exporter/publishers"
+	"github.com/bosh-prometheus/bosh_exporter/pushers"
+	"github.com/bosh-prometheus/bosh_exporter/sanitizers"
+	"github.com/bosh-prometheus/bosh_exporter/sinks"
+	"github.com/bosh-prometheus/bosh_exporter/tlspolicy"
+	"github.com/bosh-prometheus/bosh_exporter/tracing"
+	"github.com/bosh-prometheus/bosh_exporter/updates"
 )
 
 var (
+	serveCommand = kingpin.Command("serve", "Serve the BOSH metrics and Service Discovery output (default)").Default()
+
+	snapshotCommand = kingpin.Command("snapshot", "Read every BOSH Director once and save the data to a snapshot file")
+
+	snapshotOutput = snapshotCommand.Flag(
+		"output", "Snapshot file to write, gzipped when ending with .gz",
+	).Required().String()
+
+	fetchCommand = kingpin.Command("fetch", "Read every BOSH Director once and print the data to the standard output")
+
+	fetchOutput = fetchCommand.Flag(
+		"output", "Output format",
+	).Default("json").Enum("json")
+
+	sdCommand = kingpin.Command("sd", "Only write the Service Discovery output, without serving metrics")
+
+	sdWriteOnce = sdCommand.Flag(
+		"write-once", "Write the Service Discovery output once and exit, e.g. from cron",
+	).Default("false").Bool()
+
+	sdWriteInterval = sdCommand.Flag(
+		"interval", "Interval between writes of the Service Discovery output, unless write-once is set",
+	).Default("1m").Duration()
+
+	versionCommand = kingpin.Command("version", "Print the version and exit")
+
+	synthCommand = kingpin.Command("synth", "Serve the metrics and Service Discovery output of synthetic BOSH Directors, for scale testing")
+
+	synthDeployments = synthCommand.Flag(
+		"deployments", "Number of deployments per synthetic BOSH Director",
+	).Default("10").Int()
+
+	synthInstances = synthCommand.Flag(
+		"instances", "Number of instances per synthetic deployment",
+	).Default("10").Int()
+
+	synthProcesses = synthCommand.Flag(
+		"processes", "Number of processes per synthetic instance",
+	).Default("5").Int()
+
+	synthEnvironments = synthCommand.Flag(
+		"environments", "Number of synthetic BOSH Directors",
+	).Default("1").Int()
+
+	synthSeed = synthCommand.Flag(
+		"seed", "Seed of the synthetic data, the same seed always generates the same data",
+	).Default("1").Int64()
+
+	synthOutput = synthCommand.Flag(
+		"output", "Save the synthetic data to a snapshot file, gzipped when ending with .gz, instead of serving it",
+	).Default("").String()
+
+	migrateRulesCommand = kingpin.Command("migrate-rules", "Rewrite Prometheus rule files using the metric names of metrics.compat-version to the current names")
+
+	migrateRulesFiles = migrateRulesCommand.Arg(
+		"files", "Prometheus rule files to rewrite",
+	).Required().ExistingFiles()
+
+	migrateRulesWrite = migrateRulesCommand.Flag(
+		"write", "Rewrite the files in place instead of printing them to the standard output",
+	).Default("false").Bool()
+
+	enableFeatures = kingpin.Flag(
+		"enable-feature", "Comma separated experimental features to enable, can be repeated ($BOSH_EXPORTER_ENABLE_FEATURE)",
+	).Envar("BOSH_EXPORTER_ENABLE_FEATURE").Strings()
+
+	replayFile = kingpin.Flag(
+		"replay", "Serve the metrics and Service Discovery output from a snapshot file instead of querying BOSH ($BOSH_EXPORTER_REPLAY)",
+	).Envar("BOSH_EXPORTER_REPLAY").Default("").String()
+
 	boshURL = kingpin.Flag(
-		"bosh.url", "BOSH URL ($BOSH_EXPORTER_BOSH_URL)",
-	).Envar("BOSH_EXPORTER_BOSH_URL").Required().String()
+		"bosh.url", "BOSH URL, optionally with a port and a path prefix ($BOSH_EXPORTER_BOSH_URL)",
+	).Envar("BOSH_EXPORTER_BOSH_URL").String()
 
 	boshUsername = kingpin.Flag(
 		"bosh.username", "BOSH Username ($BOSH_EXPORTER_BOSH_USERNAME)",
@@ -42,54 +140,594 @@ var (
 		"bosh.uaa.client-secret", "BOSH UAA Client Secret ($BOSH_EXPORTER_BOSH_UAA_CLIENT_SECRET)",
 	).Envar("BOSH_EXPORTER_BOSH_UAA_CLIENT_SECRET").String()
 
+	boshTLSCertificatesCheckInterval = kingpin.Flag(
+		"bosh.tls-certificates-check-interval", "Interval between checks of the BOSH Director and UAA TLS certificates expiry, 0 to disable ($BOSH_EXPORTER_BOSH_TLS_CERTIFICATES_CHECK_INTERVAL)",
+	).Envar("BOSH_EXPORTER_BOSH_TLS_CERTIFICATES_CHECK_INTERVAL").Default("1h").Duration()
+
+	boshStartupRetry = kingpin.Flag(
+		"bosh.startup-retry", "Keep retrying to reach the BOSH Directors at startup, serving bosh_exporter_up 0 meanwhile, instead of exiting ($BOSH_EXPORTER_BOSH_STARTUP_RETRY)",
+	).Envar("BOSH_EXPORTER_BOSH_STARTUP_RETRY").Default("false").Bool()
+
+	boshStartupRetryMaxInterval = kingpin.Flag(
+		"bosh.startup-retry-max-interval", "Max interval between the retries to reach the BOSH Directors at startup ($BOSH_EXPORTER_BOSH_STARTUP_RETRY_MAX_INTERVAL)",
+	).Envar("BOSH_EXPORTER_BOSH_STARTUP_RETRY_MAX_INTERVAL").Default("5m").Duration()
+
+	boshBackupsCheckInterval = kingpin.Flag(
+		"bosh.backups-check-interval", "Interval between reads of the BOSH Director SSH events to detect BBR deployment backups, 0 to disable ($BOSH_EXPORTER_BOSH_BACKUPS_CHECK_INTERVAL)",
+	).Envar("BOSH_EXPORTER_BOSH_BACKUPS_CHECK_INTERVAL").Default("0").Duration()
+
+	boshBackupsWindow = kingpin.Flag(
+		"bosh.backups-window", "How far back to read the BOSH Director SSH events to detect BBR deployment backups ($BOSH_EXPORTER_BOSH_BACKUPS_WINDOW)",
+	).Envar("BOSH_EXPORTER_BOSH_BACKUPS_WINDOW").Default("168h").Duration()
+
+	boshBackupsSSHUserPrefix = kingpin.Flag(
+		"bosh.backups-ssh-user-prefix", "Prefix of the SSH users created by BBR ($BOSH_EXPORTER_BOSH_BACKUPS_SSH_USER_PREFIX)",
+	).Envar("BOSH_EXPORTER_BOSH_BACKUPS_SSH_USER_PREFIX").Default("bbr-").String()
+
+	boshDeploysCheckInterval = kingpin.Flag(
+		"bosh.deploys-check-interval", "Interval between reads of the BOSH Director deployment events to track the deploys of every deployment, 0 to disable ($BOSH_EXPORTER_BOSH_DEPLOYS_CHECK_INTERVAL)",
+	).Envar("BOSH_EXPORTER_BOSH_DEPLOYS_CHECK_INTERVAL").Default("0").Duration()
+
+	boshBlobstoreCheckInterval = kingpin.Flag(
+		"bosh.blobstore-check-interval", "Interval between reads of the BOSH Director releases and stemcells to track the blobstore usage, 0 to disable ($BOSH_EXPORTER_BOSH_BLOBSTORE_CHECK_INTERVAL)",
+	).Envar("BOSH_EXPORTER_BOSH_BLOBSTORE_CHECK_INTERVAL").Default("0").Duration()
+
+	auditEndpoint = kingpin.Flag(
+		"audit.endpoint", "Endpoint the BOSH Director events are forwarded to for auditing: syslog+udp://host:port, syslog+tcp://host:port or an HTTP(S) URL ($BOSH_EXPORTER_AUDIT_ENDPOINT)",
+	).Envar("BOSH_EXPORTER_AUDIT_ENDPOINT").Default("").String()
+
+	auditFormat = kingpin.Flag(
+		"audit.format", "Format of the forwarded BOSH Director events: 'ecs' (Elastic Common Schema JSON) or 'cef' (Common Event Format) ($BOSH_EXPORTER_AUDIT_FORMAT)",
+	).Envar("BOSH_EXPORTER_AUDIT_FORMAT").Default(audit.FormatECS).Enum(audit.FormatECS, audit.FormatCEF)
+
+	auditPollInterval = kingpin.Flag(
+		"audit.poll-interval", "Interval between reads of the BOSH Director events to forward ($BOSH_EXPORTER_AUDIT_POLL_INTERVAL)",
+	).Envar("BOSH_EXPORTER_AUDIT_POLL_INTERVAL").Default("1m").Duration()
+
+	boshProxyURL = kingpin.Flag(
+		"bosh.proxy-url", "Proxy URL of the BOSH Director, UAA and OIDC requests, overriding $HTTPS_PROXY and $HTTP_PROXY, `direct` to ignore them ($BOSH_EXPORTER_BOSH_PROXY_URL)",
+	).Envar("BOSH_EXPORTER_BOSH_PROXY_URL").Default("").String()
+
+	boshNoProxy = kingpin.Flag(
+		"bosh.no-proxy", "Comma separated list of hosts, domains and CIDRs of the BOSH Director, UAA and OIDC requests not sent through the proxy, overriding $NO_PROXY ($BOSH_EXPORTER_BOSH_NO_PROXY)",
+	).Envar("BOSH_EXPORTER_BOSH_NO_PROXY").Default("").String()
+
 	boshLogLevel = kingpin.Flag(
 		"bosh.log-level", "BOSH Log Level ($BOSH_EXPORTER_BOSH_LOG_LEVEL)",
 	).Envar("BOSH_EXPORTER_BOSH_LOG_LEVEL").Default("ERROR").String()
 
-	boshCACertFile = kingpin.Flag(
-		"bosh.ca-cert-file", "BOSH CA Certificate file ($BOSH_EXPORTER_BOSH_CA_CERT_FILE)",
-	).Envar("BOSH_EXPORTER_BOSH_CA_CERT_FILE").Required().ExistingFile()
+	boshOIDCIssuerURL = kingpin.Flag(
+		"bosh.oidc.issuer-url", "OIDC Issuer URL used to obtain BOSH Director tokens instead of UAA ($BOSH_EXPORTER_BOSH_OIDC_ISSUER_URL)",
+	).Envar("BOSH_EXPORTER_BOSH_OIDC_ISSUER_URL").String()
+
+	boshOIDCClientID = kingpin.Flag(
+		"bosh.oidc.client-id", "OIDC Client ID ($BOSH_EXPORTER_BOSH_OIDC_CLIENT_ID)",
+	).Envar("BOSH_EXPORTER_BOSH_OIDC_CLIENT_ID").String()
+
+	boshOIDCClientSecret = kingpin.Flag(
+		"bosh.oidc.client-secret", "OIDC Client Secret ($BOSH_EXPORTER_BOSH_OIDC_CLIENT_SECRET)",
+	).Envar("BOSH_EXPORTER_BOSH_OIDC_CLIENT_SECRET").String()
+
+	boshOIDCAudience = kingpin.Flag(
+		"bosh.oidc.audience", "OIDC Audience of the requested tokens ($BOSH_EXPORTER_BOSH_OIDC_AUDIENCE)",
+	).Envar("BOSH_EXPORTER_BOSH_OIDC_AUDIENCE").String()
+
+	boshOIDCScopes = kingpin.Flag(
+		"bosh.oidc.scopes", "Comma separated OIDC Scopes of the requested tokens ($BOSH_EXPORTER_BOSH_OIDC_SCOPES)",
+	).Envar("BOSH_EXPORTER_BOSH_OIDC_SCOPES").Default("").String()
+
+	boshOIDCGrantType = kingpin.Flag(
+		"bosh.oidc.grant-type", "OIDC Grant Type (client_credentials, token_exchange) ($BOSH_EXPORTER_BOSH_OIDC_GRANT_TYPE)",
+	).Envar("BOSH_EXPORTER_BOSH_OIDC_GRANT_TYPE").Default("client_credentials").String()
+
+	boshOIDCSubjectTokenFile = kingpin.Flag(
+		"bosh.oidc.subject-token-file", "Path to a file containing the subject token exchanged with the token_exchange grant ($BOSH_EXPORTER_BOSH_OIDC_SUBJECT_TOKEN_FILE)",
+	).Envar("BOSH_EXPORTER_BOSH_OIDC_SUBJECT_TOKEN_FILE").String()
+
+	boshCACertFiles = kingpin.Flag(
+		"bosh.ca-cert-file", "BOSH CA Certificate file, can be repeated to trust several CAs ($BOSH_EXPORTER_BOSH_CA_CERT_FILE)",
+	).Envar("BOSH_EXPORTER_BOSH_CA_CERT_FILE").ExistingFiles()
+
+	boshUseSystemCAs = kingpin.Flag(
+		"bosh.use-system-cas", "Trust the system CA certificates in addition to the BOSH CA Certificate files ($BOSH_EXPORTER_BOSH_USE_SYSTEM_CAS)",
+	).Envar("BOSH_EXPORTER_BOSH_USE_SYSTEM_CAS").Default("false").Bool()
+
+	boshCACertReloadInterval = kingpin.Flag(
+		"bosh.ca-cert-reload-interval", "Interval to check the BOSH CA Certificate files for changes, 0 disables reloading ($BOSH_EXPORTER_BOSH_CA_CERT_RELOAD_INTERVAL)",
+	).Envar("BOSH_EXPORTER_BOSH_CA_CERT_RELOAD_INTERVAL").Default("30s").Duration()
+
+	tlsPolicyName = kingpin.Flag(
+		"tls.policy", "TLS policy of the connections to the BOSH Director and of the web server: 'default' or 'fips' (TLS 1.2 with FIPS-approved cipher suites only) ($BOSH_EXPORTER_TLS_POLICY)",
+	).Envar("BOSH_EXPORTER_TLS_POLICY").Default(tlspolicy.DefaultPolicy).Enum(tlspolicy.PolicyDefault, tlspolicy.PolicyFIPS)
+
+	boshCRLFiles = kingpin.Flag(
+		"bosh.crl-file", "CRL file (PEM or DER) the BOSH Director certificate is checked against, can be repeated ($BOSH_EXPORTER_BOSH_CRL_FILE)",
+	).Envar("BOSH_EXPORTER_BOSH_CRL_FILE").ExistingFiles()
+
+	boshCertPinsSHA256 = kingpin.Flag(
+		"bosh.cert-pin-sha256", "SHA-256 fingerprint (hex, or base64 optionally prefixed by sha256//) of a BOSH Director certificate or public key to pin, can be repeated ($BOSH_EXPORTER_BOSH_CERT_PIN_SHA256)",
+	).Envar("BOSH_EXPORTER_BOSH_CERT_PIN_SHA256").Strings()
+
+	boshOCSPStaple = kingpin.Flag(
+		"bosh.ocsp-staple", "Verification of the OCSP response stapled by the BOSH Director: 'off', 'verify' when present or 'require' ($BOSH_EXPORTER_BOSH_OCSP_STAPLE)",
+	).Envar("BOSH_EXPORTER_BOSH_OCSP_STAPLE").Default(fetcher.OCSPStapleOff).Enum(fetcher.OCSPStapleOff, fetcher.OCSPStapleVerify, fetcher.OCSPStapleRequire)
+
+	boshDebugHTTP = kingpin.Flag(
+		"bosh.debug-http", "Log the BOSH Director and UAA requests and truncated responses at debug level, with secrets redacted, can be toggled at runtime through the admin API ($BOSH_EXPORTER_BOSH_DEBUG_HTTP)",
+	).Envar("BOSH_EXPORTER_BOSH_DEBUG_HTTP").Default("false").Bool()
+
+	boshDebugHTTPMaxBodyBytes = kingpin.Flag(
+		"bosh.debug-http.max-body-bytes", "Maximum number of bytes of each request and response body logged by bosh.debug-http ($BOSH_EXPORTER_BOSH_DEBUG_HTTP_MAX_BODY_BYTES)",
+	).Envar("BOSH_EXPORTER_BOSH_DEBUG_HTTP_MAX_BODY_BYTES").Default("4096").Int()
+
+	boshConditionalRequests = kingpin.Flag(
+		"bosh.conditional-requests", "Cache the BOSH Director responses carrying an ETag or Last-Modified header and revalidate them with conditional requests ($BOSH_EXPORTER_BOSH_CONDITIONAL_REQUESTS)",
+	).Envar("BOSH_EXPORTER_BOSH_CONDITIONAL_REQUESTS").Default("false").Bool()
+
+	boshConditionalRequestsMaxBodyBytes = kingpin.Flag(
+		"bosh.conditional-requests.max-body-bytes", "Maximum size in bytes of each BOSH Director response cached by bosh.conditional-requests ($BOSH_EXPORTER_BOSH_CONDITIONAL_REQUESTS_MAX_BODY_BYTES)",
+	).Envar("BOSH_EXPORTER_BOSH_CONDITIONAL_REQUESTS_MAX_BODY_BYTES").Default("8388608").Int64()
+
+	boshTaskWatchdogDeadline = kingpin.Flag(
+		"bosh.task-watchdog-deadline", "Cancel the VM stats BOSH Director tasks started by the exporter still queued or processing after this deadline, 0 disables the watchdog ($BOSH_EXPORTER_BOSH_TASK_WATCHDOG_DEADLINE)",
+	).Envar("BOSH_EXPORTER_BOSH_TASK_WATCHDOG_DEADLINE").Default("0").Duration()
+
+	boshTaskWatchdogInterval = kingpin.Flag(
+		"bosh.task-watchdog-interval", "Interval between checks of the task watchdog ($BOSH_EXPORTER_BOSH_TASK_WATCHDOG_INTERVAL)",
+	).Envar("BOSH_EXPORTER_BOSH_TASK_WATCHDOG_INTERVAL").Default("1m").Duration()
+
+	boshProblemsScanInterval = kingpin.Flag(
+		"bosh.problems-scan-interval", "Interval between BOSH Director problem scans (as `bosh cck --report`) of every deployment, 0 disables scanning ($BOSH_EXPORTER_BOSH_PROBLEMS_SCAN_INTERVAL)",
+	).Envar("BOSH_EXPORTER_BOSH_PROBLEMS_SCAN_INTERVAL").Default("0").Duration()
+
+	boshFetchSpread = kingpin.Flag(
+		"bosh.fetch-spread", "Spread the deployment fetches over this period, fetching every deployment once per period at its own slot and reusing its last fetched data in between, 0 to fetch every deployment on every scrape ($BOSH_EXPORTER_BOSH_FETCH_SPREAD)",
+	).Envar("BOSH_EXPORTER_BOSH_FETCH_SPREAD").Default("0").Duration()
+
+	boshStreamDeployments = kingpin.Flag(
+		"bosh.stream-deployments", "Fetch the deployments one at a time and stream them to the collectors instead of holding every instance in memory, ignored with scrape pause windows or publishers ($BOSH_EXPORTER_BOSH_STREAM_DEPLOYMENTS)",
+	).Envar("BOSH_EXPORTER_BOSH_STREAM_DEPLOYMENTS").Default("false").Bool()
+
+	environmentsConfig = kingpin.Flag(
+		"environments.config", "Path to a YAML file describing several BOSH Directors to scrape, overrides the bosh.* flags ($BOSH_EXPORTER_ENVIRONMENTS_CONFIG)",
+	).Envar("BOSH_EXPORTER_ENVIRONMENTS_CONFIG").ExistingFile()
 
 	filterDeployments = kingpin.Flag(
 		"filter.deployments", "Comma separated deployments to filter ($BOSH_EXPORTER_FILTER_DEPLOYMENTS)",
 	).Envar("BOSH_EXPORTER_FILTER_DEPLOYMENTS").Default("").String()
 
 	filterAZs = kingpin.Flag(
-		"filter.azs", "Comma separated AZs to filter ($BOSH_EXPORTER_FILTER_AZS)",
+		"filter.azs", "Comma separated AZs, or AZ cloud properties as <property>=<value>, to filter ($BOSH_EXPORTER_FILTER_AZS)",
 	).Envar("BOSH_EXPORTER_FILTER_AZS").Default("").String()
 
 	filterCollectors = kingpin.Flag(
-		"filter.collectors", "Comma separated collectors to filter (Deployments,Jobs,ServiceDiscovery) ($BOSH_EXPORTER_FILTER_COLLECTORS)",
+		"filter.collectors", "Comma separated collectors to filter (Deployments,Jobs,ServiceDiscovery,Tasks) ($BOSH_EXPORTER_FILTER_COLLECTORS)",
 	).Envar("BOSH_EXPORTER_FILTER_COLLECTORS").Default("").String()
 
 	filterCIDRs = kingpin.Flag(
 		"filter.cidrs", "Comma separated CIDR to filter available instance IPs ($BOSH_EXPORTER_FILTER_CIDRS)",
 	).Envar("BOSH_EXPORTER_FILTER_CIDRS").Default("0.0.0.0/0").String()
 
+	filterDeploymentProcesses = kingpin.Flag(
+		"filter.deployment-processes", "Semicolon separated list of <deployment>:<process regexp>[,<process regexp>] allowing job processes per deployment, `*` matches unlisted deployments ($BOSH_EXPORTER_FILTER_DEPLOYMENT_PROCESSES)",
+	).Envar("BOSH_EXPORTER_FILTER_DEPLOYMENT_PROCESSES").Default("").String()
+
+	filterExpression = kingpin.Flag(
+		"filter.expression", "Filter expression on deployment, job, az, process and ip, e.g. `deployment =~ \"cf-.*\" && process not in (metron_agent)` ($BOSH_EXPORTER_FILTER_EXPRESSION)",
+	).Envar("BOSH_EXPORTER_FILTER_EXPRESSION").Default("").String()
+
+	filterProfile = kingpin.Flag(
+		"filter.profile", "Filter profile excluding BOSH system processes and collectors (minimal, standard, full) ($BOSH_EXPORTER_FILTER_PROFILE)",
+	).Envar("BOSH_EXPORTER_FILTER_PROFILE").Default(filters.FullProfile).String()
+
 	metricsNamespace = kingpin.Flag(
 		"metrics.namespace", "Metrics Namespace ($BOSH_EXPORTER_METRICS_NAMESPACE)",
 	).Envar("BOSH_EXPORTER_METRICS_NAMESPACE").Default("bosh").String()
 
 	metricsEnvironment = kingpin.Flag(
 		"metrics.environment", "Environment label to be attached to metrics ($BOSH_EXPORTER_METRICS_ENVIRONMENT)",
-	).Envar("BOSH_EXPORTER_METRICS_ENVIRONMENT").Required().String()
+	).Envar("BOSH_EXPORTER_METRICS_ENVIRONMENT").String()
+
+	metricsStemcellVersionsThreshold = kingpin.Flag(
+		"metrics.stemcell-versions-threshold", "Number of newer uploaded Stemcell versions tolerated before a deployed Stemcell is reported as outdated ($BOSH_EXPORTER_METRICS_STEMCELL_VERSIONS_THRESHOLD)",
+	).Envar("BOSH_EXPORTER_METRICS_STEMCELL_VERSIONS_THRESHOLD").Default("0").Int()
+
+	metricsStemcellDaysThreshold = kingpin.Flag(
+		"metrics.stemcell-days-threshold", "Number of days a deployed Stemcell may stay behind a newer uploaded version before it is reported as outdated, 0 to only use the versions threshold ($BOSH_EXPORTER_METRICS_STEMCELL_DAYS_THRESHOLD)",
+	).Envar("BOSH_EXPORTER_METRICS_STEMCELL_DAYS_THRESHOLD").Default("0").Int()
+
+	metricsFailedTasksWindow = kingpin.Flag(
+		"metrics.failed-tasks-window", "Period during which the failed Tasks are remembered after they finished, so they are counted once; Tasks first seen later are not counted ($BOSH_EXPORTER_METRICS_FAILED_TASKS_WINDOW)",
+	).Envar("BOSH_EXPORTER_METRICS_FAILED_TASKS_WINDOW").Default("24h").Duration()
+
+	metricsTimestamps = kingpin.Flag(
+		"metrics.timestamps", "Attach the time BOSH was read at to the exported metrics instead of the scrape time ($BOSH_EXPORTER_METRICS_TIMESTAMPS)",
+	).Envar("BOSH_EXPORTER_METRICS_TIMESTAMPS").Default("false").Bool()
+
+	metricsTimestampsMaxAge = kingpin.Flag(
+		"metrics.timestamps-max-age", "Do not attach timestamps older than this age to the exported metrics, 0 to disable ($BOSH_EXPORTER_METRICS_TIMESTAMPS_MAX_AGE)",
+	).Envar("BOSH_EXPORTER_METRICS_TIMESTAMPS_MAX_AGE").Default("5m").Duration()
+
+	metricsStoppedDeployments = kingpin.Flag(
+		"metrics.stopped-deployments", "How to report deployments whose instances were all stopped on purpose: `include` them, `exclude` them from the metrics and Service Discovery, or `label` their healthy metrics with bosh_job_intentionally_stopped ($BOSH_EXPORTER_METRICS_STOPPED_DEPLOYMENTS)",
+	).Envar("BOSH_EXPORTER_METRICS_STOPPED_DEPLOYMENTS").Default(collectors.StoppedDeploymentsInclude).Enum(collectors.StoppedDeploymentsInclude, collectors.StoppedDeploymentsExclude, collectors.StoppedDeploymentsLabel)
+
+	labelsSanitizeConfigFile = kingpin.Flag(
+		"labels.sanitize.config-file", "Path to a YAML file with the rules normalizing the label values of the metrics and Service Discovery output ($BOSH_EXPORTER_LABELS_SANITIZE_CONFIG_FILE)",
+	).Envar("BOSH_EXPORTER_LABELS_SANITIZE_CONFIG_FILE").ExistingFile()
+
+	metricsMaxSeries = kingpin.Flag(
+		"metrics.max-series", "Max series returned by the collectors before aggregating instance metrics by instance group, 0 to disable ($BOSH_EXPORTER_METRICS_MAX_SERIES)",
+	).Envar("BOSH_EXPORTER_METRICS_MAX_SERIES").Default("0").Int()
+
+	metricsProcessThresholdsFile = kingpin.Flag(
+		"metrics.process-thresholds-file", "YAML file of the process CPU and memory thresholds evaluated by the exporter into the job_process_threshold_breached metric ($BOSH_EXPORTER_METRICS_PROCESS_THRESHOLDS_FILE)",
+	).Envar("BOSH_EXPORTER_METRICS_PROCESS_THRESHOLDS_FILE").ExistingFile()
+
+	metricsKBSeries = kingpin.Flag(
+		"metrics.kb-series", "Expose the deprecated *_kb memory metrics alongside the *_bytes ones ($BOSH_EXPORTER_METRICS_KB_SERIES)",
+	).Envar("BOSH_EXPORTER_METRICS_KB_SERIES").Default("true").Bool()
+
+	metricsAliases = kingpin.Flag(
+		"metrics.aliases", "Additionally emit metrics under other names and labels: `healthwatch` for the TAS Healthwatch names, or the path of an alias table YAML file ($BOSH_EXPORTER_METRICS_ALIASES)",
+	).Envar("BOSH_EXPORTER_METRICS_ALIASES").Default("").String()
+
+	metricsCompatVersion = kingpin.Flag(
+		"metrics.compat-version", "Additionally emit the renamed metrics under their names and labels of this naming version, the current version to disable ($BOSH_EXPORTER_METRICS_COMPAT_VERSION)",
+	).Envar("BOSH_EXPORTER_METRICS_COMPAT_VERSION").Default("1").Int()
+
+	tracingOTLPEndpoint = kingpin.Flag(
+		"tracing.otlp-endpoint", "OTLP/HTTP endpoint to export fetch, collector and BOSH Director request spans to, empty to disable tracing ($BOSH_EXPORTER_TRACING_OTLP_ENDPOINT)",
+	).Envar("BOSH_EXPORTER_TRACING_OTLP_ENDPOINT").Default("").String()
+
+	tracingOTLPHeaders = kingpin.Flag(
+		"tracing.otlp-headers", "Comma separated list of <name>=<value> headers to send to the OTLP endpoint ($BOSH_EXPORTER_TRACING_OTLP_HEADERS)",
+	).Envar("BOSH_EXPORTER_TRACING_OTLP_HEADERS").Default("").String()
+
+	tracingServiceName = kingpin.Flag(
+		"tracing.service-name", "Service name attached to the exported spans ($BOSH_EXPORTER_TRACING_SERVICE_NAME)",
+	).Envar("BOSH_EXPORTER_TRACING_SERVICE_NAME").Default("bosh_exporter").String()
+
+	updateCheckEnabled = kingpin.Flag(
+		"update-check.enabled", "Periodically check for newer exporter releases and expose the result as a metric ($BOSH_EXPORTER_UPDATE_CHECK_ENABLED)",
+	).Envar("BOSH_EXPORTER_UPDATE_CHECK_ENABLED").Default("false").Bool()
+
+	updateCheckURL = kingpin.Flag(
+		"update-check.url", "Releases endpoint returning the latest exporter release ($BOSH_EXPORTER_UPDATE_CHECK_URL)",
+	).Envar("BOSH_EXPORTER_UPDATE_CHECK_URL").Default(updates.DefaultReleasesURL).String()
+
+	updateCheckInterval = kingpin.Flag(
+		"update-check.interval", "Interval between checks for newer exporter releases ($BOSH_EXPORTER_UPDATE_CHECK_INTERVAL)",
+	).Envar("BOSH_EXPORTER_UPDATE_CHECK_INTERVAL").Default("24h").Duration()
+
+	scrapePauseCron = kingpin.Flag(
+		"scrape.pause-cron", "Semicolon separated pause windows formatted as <minute> <hour> <day of month> <month> <day of week> <duration> during which BOSH is not queried and cached data is served ($BOSH_EXPORTER_SCRAPE_PAUSE_CRON)",
+	).Envar("BOSH_EXPORTER_SCRAPE_PAUSE_CRON").Default("").String()
+
+	refreshOnSIGUSR1 = kingpin.Flag(
+		"scrape.refresh-on-sigusr1", "Fetch BOSH and rewrite the Service Discovery file immediately when the exporter receives a SIGUSR1 signal ($BOSH_EXPORTER_SCRAPE_REFRESH_ON_SIGUSR1)",
+	).Envar("BOSH_EXPORTER_SCRAPE_REFRESH_ON_SIGUSR1").Default("false").Bool()
+
+	metricsInstanceAttributes = kingpin.Flag(
+		"metrics.instance-attributes", "Comma separated list of instance attributes (e.g. vm_type, stemcell, cloud_properties.instance_type) exported as labels of a job_attributes_info metric ($BOSH_EXPORTER_METRICS_INSTANCE_ATTRIBUTES)",
+	).Envar("BOSH_EXPORTER_METRICS_INSTANCE_ATTRIBUTES").Default("").String()
+
+	metricsPersistentDiskGrowthWindow = kingpin.Flag(
+		"metrics.persistent-disk-growth-window", "Period over which the persistent disk usage growth rate is computed, 0 to disable ($BOSH_EXPORTER_METRICS_PERSISTENT_DISK_GROWTH_WINDOW)",
+	).Envar("BOSH_EXPORTER_METRICS_PERSISTENT_DISK_GROWTH_WINDOW").Default("6h").Duration()
+
+	metricsSLOObjective = kingpin.Flag(
+		"metrics.slo-objective", "Objective of the ratio of running processes of every deployment (e.g. 0.99), 0 to disable ($BOSH_EXPORTER_METRICS_SLO_OBJECTIVE)",
+	).Envar("BOSH_EXPORTER_METRICS_SLO_OBJECTIVE").Default("0").Float64()
+
+	metricsSLODeploymentObjectives = kingpin.Flag(
+		"metrics.slo-deployment-objectives", "Comma separated list of per deployment objectives of the ratio of running processes, as <deployment>=<ratio> ($BOSH_EXPORTER_METRICS_SLO_DEPLOYMENT_OBJECTIVES)",
+	).Envar("BOSH_EXPORTER_METRICS_SLO_DEPLOYMENT_OBJECTIVES").Default("").String()
+
+	metricsSLOWindow = kingpin.Flag(
+		"metrics.slo-window", "Period over which the SLO error budget is computed ($BOSH_EXPORTER_METRICS_SLO_WINDOW)",
+	).Envar("BOSH_EXPORTER_METRICS_SLO_WINDOW").Default("24h").Duration()
 
 	sdFilename = kingpin.Flag(
 		"sd.filename", "Full path to the Service Discovery output file ($BOSH_EXPORTER_SD_FILENAME)",
 	).Envar("BOSH_EXPORTER_SD_FILENAME").Default("bosh_target_groups.json").String()
 
+	sdTmpDir = kingpin.Flag(
+		"sd.tmp-dir", "Directory where the Service Discovery output is written before being moved in place, defaults to the output file directory ($BOSH_EXPORTER_SD_TMP_DIR)",
+	).Envar("BOSH_EXPORTER_SD_TMP_DIR").Default("").String()
+
+	sdFsync = kingpin.Flag(
+		"sd.fsync", "Sync the Service Discovery output to disk before moving it in place, disable for tmpfs-backed directories ($BOSH_EXPORTER_SD_FSYNC)",
+	).Envar("BOSH_EXPORTER_SD_FSYNC").Default("true").Bool()
+
+	sdKeepBackups = kingpin.Flag(
+		"sd.keep-backups", "Number of previous versions of the Service Discovery output file to keep as <sd.filename>.1 to <sd.filename>.N, 0 to disable ($BOSH_EXPORTER_SD_KEEP_BACKUPS)",
+	).Envar("BOSH_EXPORTER_SD_KEEP_BACKUPS").Default("0").Int()
+
+	sdRefuseEmptyOutput = kingpin.Flag(
+		"sd.refuse-empty-output", "Do not replace a non-empty Service Discovery output with an empty one unless BOSH reported zero deployments ($BOSH_EXPORTER_SD_REFUSE_EMPTY_OUTPUT)",
+	).Envar("BOSH_EXPORTER_SD_REFUSE_EMPTY_OUTPUT").Default("false").Bool()
+
+	sdS3Bucket = kingpin.Flag(
+		"sd.s3.bucket", "S3 bucket where the Service Discovery output will be uploaded ($BOSH_EXPORTER_SD_S3_BUCKET)",
+	).Envar("BOSH_EXPORTER_SD_S3_BUCKET").Default("").String()
+
+	sdS3Key = kingpin.Flag(
+		"sd.s3.key", "S3 object key of the Service Discovery output ($BOSH_EXPORTER_SD_S3_KEY)",
+	).Envar("BOSH_EXPORTER_SD_S3_KEY").Default("bosh_target_groups.json").String()
+
+	sdS3Region = kingpin.Flag(
+		"sd.s3.region", "S3 region ($BOSH_EXPORTER_SD_S3_REGION)",
+	).Envar("BOSH_EXPORTER_SD_S3_REGION").Default("us-east-1").String()
+
+	sdS3Endpoint = kingpin.Flag(
+		"sd.s3.endpoint", "S3 compatible endpoint URL, defaults to the AWS regional endpoint ($BOSH_EXPORTER_SD_S3_ENDPOINT)",
+	).Envar("BOSH_EXPORTER_SD_S3_ENDPOINT").Default("").String()
+
+	sdS3AccessKeyID = kingpin.Flag(
+		"sd.s3.access-key-id", "S3 Access Key ID ($BOSH_EXPORTER_SD_S3_ACCESS_KEY_ID)",
+	).Envar("BOSH_EXPORTER_SD_S3_ACCESS_KEY_ID").Default("").String()
+
+	sdS3SecretAccessKey = kingpin.Flag(
+		"sd.s3.secret-access-key", "S3 Secret Access Key ($BOSH_EXPORTER_SD_S3_SECRET_ACCESS_KEY)",
+	).Envar("BOSH_EXPORTER_SD_S3_SECRET_ACCESS_KEY").Default("").String()
+
+	sdS3SessionToken = kingpin.Flag(
+		"sd.s3.session-token", "S3 Session Token ($BOSH_EXPORTER_SD_S3_SESSION_TOKEN)",
+	).Envar("BOSH_EXPORTER_SD_S3_SESSION_TOKEN").Default("").String()
+
+	sdS3Versioned = kingpin.Flag(
+		"sd.s3.versioned", "Also upload the Service Discovery output to a timestamped S3 key ($BOSH_EXPORTER_SD_S3_VERSIONED)",
+	).Envar("BOSH_EXPORTER_SD_S3_VERSIONED").Default("false").Bool()
+
+	sdAzureBlobURL = kingpin.Flag(
+		"sd.azure.blob-url", "Azure Storage blob URL, including a SAS token, where the Service Discovery output will be uploaded ($BOSH_EXPORTER_SD_AZURE_BLOB_URL)",
+	).Envar("BOSH_EXPORTER_SD_AZURE_BLOB_URL").Default("").String()
+
+	sdUploadTimeout = kingpin.Flag(
+		"sd.upload-timeout", "Timeout of the Service Discovery output uploads to S3 and Azure Blob storage ($BOSH_EXPORTER_SD_UPLOAD_TIMEOUT)",
+	).Envar("BOSH_EXPORTER_SD_UPLOAD_TIMEOUT").Default("30s").Duration()
+
+	sdKubernetesConfigMap = kingpin.Flag(
+		"sd.kubernetes.configmap", "Kubernetes ConfigMap, as name or namespace/name, where the Service Discovery output will be written using the in-cluster service account ($BOSH_EXPORTER_SD_KUBERNETES_CONFIGMAP)",
+	).Envar("BOSH_EXPORTER_SD_KUBERNETES_CONFIGMAP").Default("").String()
+
+	sdKubernetesKey = kingpin.Flag(
+		"sd.kubernetes.key", "Kubernetes ConfigMap data key of the Service Discovery output ($BOSH_EXPORTER_SD_KUBERNETES_KEY)",
+	).Envar("BOSH_EXPORTER_SD_KUBERNETES_KEY").Default("bosh_target_groups.json").String()
+
+	sdKubernetesNamespaces = kingpin.Flag(
+		"sd.kubernetes.namespaces", "Comma separated list of Kubernetes namespaces where the Service Discovery ConfigMap will be written, each one independently ($BOSH_EXPORTER_SD_KUBERNETES_NAMESPACES)",
+	).Envar("BOSH_EXPORTER_SD_KUBERNETES_NAMESPACES").Default("").String()
+
+	sdKubernetesWatch = kingpin.Flag(
+		"sd.kubernetes.watch", "Watch the Kubernetes ConfigMap and restore the Service Discovery output when it is modified or deleted outside of the exporter ($BOSH_EXPORTER_SD_KUBERNETES_WATCH)",
+	).Envar("BOSH_EXPORTER_SD_KUBERNETES_WATCH").Default("false").Bool()
+
+	kubernetesEvents = kingpin.Flag(
+		"kubernetes.events", "Emit Kubernetes Events when the BOSH Director becomes unreachable, authentication fails or the Service Discovery output cannot be written, and when they recover ($BOSH_EXPORTER_KUBERNETES_EVENTS)",
+	).Envar("BOSH_EXPORTER_KUBERNETES_EVENTS").Default("false").Bool()
+
+	kubernetesProxyURL = kingpin.Flag(
+		"kubernetes.proxy-url", "Proxy URL of the Kubernetes API requests, overriding $HTTPS_PROXY and $HTTP_PROXY, `direct` to ignore them ($BOSH_EXPORTER_KUBERNETES_PROXY_URL)",
+	).Envar("BOSH_EXPORTER_KUBERNETES_PROXY_URL").Default("").String()
+
+	kubernetesNoProxy = kingpin.Flag(
+		"kubernetes.no-proxy", "Comma separated list of hosts, domains and CIDRs of the Kubernetes API requests not sent through the proxy, overriding $NO_PROXY ($BOSH_EXPORTER_KUBERNETES_NO_PROXY)",
+	).Envar("BOSH_EXPORTER_KUBERNETES_NO_PROXY").Default("").String()
+
+	kubernetesQPS = kingpin.Flag(
+		"kubernetes.qps", "Maximum number of requests per second sent to the Kubernetes API, 0 for no client-side throttling ($BOSH_EXPORTER_KUBERNETES_QPS)",
+	).Envar("BOSH_EXPORTER_KUBERNETES_QPS").Default("0").Float64()
+
+	kubernetesBurst = kingpin.Flag(
+		"kubernetes.burst", "Maximum number of requests sent at once to the Kubernetes API when kubernetes.qps is set ($BOSH_EXPORTER_KUBERNETES_BURST)",
+	).Envar("BOSH_EXPORTER_KUBERNETES_BURST").Default("10").Int()
+
+	kubernetesEventsObject = kingpin.Flag(
+		"kubernetes.events.object", "Kubernetes object the Events are emitted on: the exporter 'pod' (named after $POD_NAME or the hostname) or the Service Discovery 'configmap' ($BOSH_EXPORTER_KUBERNETES_EVENTS_OBJECT)",
+	).Envar("BOSH_EXPORTER_KUBERNETES_EVENTS_OBJECT").Default("pod").Enum("pod", "configmap")
+
+	sdMetadata = kingpin.Flag(
+		"sd.metadata", "Wrap the Service Discovery output with generation metadata (not readable by Prometheus file_sd) ($BOSH_EXPORTER_SD_METADATA)",
+	).Envar("BOSH_EXPORTER_SD_METADATA").Default("false").Bool()
+
+	sdSigningKeyFile = kingpin.Flag(
+		"sd.signing-key-file", "Path to a file containing the key used to sign the Service Discovery output with HMAC-SHA256 ($BOSH_EXPORTER_SD_SIGNING_KEY_FILE)",
+	).Envar("BOSH_EXPORTER_SD_SIGNING_KEY_FILE").ExistingFile()
+
+	sdTargetTTL = kingpin.Flag(
+		"sd.target-ttl", "Keep targets that disappeared from BOSH in the Service Discovery output during this period, labeled with __meta_bosh_stale=\"true\", 0 to disable ($BOSH_EXPORTER_SD_TARGET_TTL)",
+	).Envar("BOSH_EXPORTER_SD_TARGET_TTL").Default("0s").Duration()
+
+	sdInstanceAttributes = kingpin.Flag(
+		"sd.instance-attributes", "Comma separated list of instance attributes exported as __meta_bosh_job_attribute_<attribute> Service Discovery labels ($BOSH_EXPORTER_SD_INSTANCE_ATTRIBUTES)",
+	).Envar("BOSH_EXPORTER_SD_INSTANCE_ATTRIBUTES").Default("").String()
+
+	sdProcessAnnotationsFile = kingpin.Flag(
+		"sd.process-annotations-file", "YAML file of annotations added to the Service Discovery targets of the matching processes as __meta_bosh_annotation_<name> labels ($BOSH_EXPORTER_SD_PROCESS_ANNOTATIONS_FILE)",
+	).Envar("BOSH_EXPORTER_SD_PROCESS_ANNOTATIONS_FILE").Default("").String()
+
+	sdProcessPorts = kingpin.Flag(
+		"sd.process-ports", "Comma separated list of <process>=<port> used as Service Discovery target ports when BOSH does not report the process listening ports ($BOSH_EXPORTER_SD_PROCESS_PORTS)",
+	).Envar("BOSH_EXPORTER_SD_PROCESS_PORTS").Default("").String()
+
+	sdProbePorts = kingpin.Flag(
+		"sd.probe-ports", "Only write the Service Discovery process targets whose port answers a TCP connection ($BOSH_EXPORTER_SD_PROBE_PORTS)",
+	).Envar("BOSH_EXPORTER_SD_PROBE_PORTS").Default("false").Bool()
+
+	sdProbeTimeout = kingpin.Flag(
+		"sd.probe-timeout", "Timeout of the Service Discovery target port probes ($BOSH_EXPORTER_SD_PROBE_TIMEOUT)",
+	).Envar("BOSH_EXPORTER_SD_PROBE_TIMEOUT").Default("1s").Duration()
+
+	sdProbeConcurrency = kingpin.Flag(
+		"sd.probe-concurrency", "Maximum number of Service Discovery target ports probed at once ($BOSH_EXPORTER_SD_PROBE_CONCURRENCY)",
+	).Envar("BOSH_EXPORTER_SD_PROBE_CONCURRENCY").Default("32").Int()
+
+	sdProbeInterval = kingpin.Flag(
+		"sd.probe-interval", "Interval between the Service Discovery target port probes when serving, new targets being probed as soon as they are seen ($BOSH_EXPORTER_SD_PROBE_INTERVAL)",
+	).Envar("BOSH_EXPORTER_SD_PROBE_INTERVAL").Default("30s").Duration()
+
+	sdIPFallbackTTL = kingpin.Flag(
+		"sd.ip-fallback-ttl", "Keep using the last known IPs of an instance reported without IPs by BOSH for this period, 0 to disable ($BOSH_EXPORTER_SD_IP_FALLBACK_TTL)",
+	).Envar("BOSH_EXPORTER_SD_IP_FALLBACK_TTL").Default("0").Duration()
+
+	sdTargetMode = kingpin.Flag(
+		"sd.target-mode", "Service Discovery targets to write: `process` for one target per process, `instance` for a single target per instance listing its processes ($BOSH_EXPORTER_SD_TARGET_MODE)",
+	).Envar("BOSH_EXPORTER_SD_TARGET_MODE").Default(collectors.ServiceDiscoveryTargetModeProcess).Enum(collectors.ServiceDiscoveryTargetModeProcess, collectors.ServiceDiscoveryTargetModeInstance)
+
+	sdSkippedInstancesLogInterval = kingpin.Flag(
+		"sd.skipped-instances-log-interval", "Log at most one instance left out of the Service Discovery targets per skip reason during this interval, 0 to disable ($BOSH_EXPORTER_SD_SKIPPED_INSTANCES_LOG_INTERVAL)",
+	).Envar("BOSH_EXPORTER_SD_SKIPPED_INSTANCES_LOG_INTERVAL").Default("0").Duration()
+
 	sdProcessesRegexp = kingpin.Flag(
 		"sd.processes_regexp", "Regexp to filter Service Discovery processes names ($BOSH_EXPORTER_SD_PROCESSES_REGEXP)",
 	).Envar("BOSH_EXPORTER_SD_PROCESSES_REGEXP").Default("").String()
 
+	zabbixServer = kingpin.Flag(
+		"zabbix.server", "Zabbix server or proxy address (host:port) where health indicators will be pushed using the sender protocol ($BOSH_EXPORTER_ZABBIX_SERVER)",
+	).Envar("BOSH_EXPORTER_ZABBIX_SERVER").Default("").String()
+
+	zabbixHost = kingpin.Flag(
+		"zabbix.host", "Zabbix host the pushed items belong to, defaults to the BOSH Director name ($BOSH_EXPORTER_ZABBIX_HOST)",
+	).Envar("BOSH_EXPORTER_ZABBIX_HOST").Default("").String()
+
+	zabbixTimeout = kingpin.Flag(
+		"zabbix.timeout", "Timeout of the Zabbix sender connection ($BOSH_EXPORTER_ZABBIX_TIMEOUT)",
+	).Envar("BOSH_EXPORTER_ZABBIX_TIMEOUT").Default("10s").Duration()
+
+	icinga2APIURL = kingpin.Flag(
+		"icinga2.api-url", "Icinga2 API URL where per deployment passive check results will be submitted ($BOSH_EXPORTER_ICINGA2_API_URL)",
+	).Envar("BOSH_EXPORTER_ICINGA2_API_URL").Default("").String()
+
+	icinga2Username = kingpin.Flag(
+		"icinga2.username", "Icinga2 API Username ($BOSH_EXPORTER_ICINGA2_USERNAME)",
+	).Envar("BOSH_EXPORTER_ICINGA2_USERNAME").Default("").String()
+
+	icinga2Password = kingpin.Flag(
+		"icinga2.password", "Icinga2 API Password ($BOSH_EXPORTER_ICINGA2_PASSWORD)",
+	).Envar("BOSH_EXPORTER_ICINGA2_PASSWORD").Default("").String()
+
+	icinga2CACertFile = kingpin.Flag(
+		"icinga2.ca-cert-file", "Icinga2 API CA Certificate file ($BOSH_EXPORTER_ICINGA2_CA_CERT_FILE)",
+	).Envar("BOSH_EXPORTER_ICINGA2_CA_CERT_FILE").ExistingFile()
+
+	icinga2Timeout = kingpin.Flag(
+		"icinga2.timeout", "Timeout of the Icinga2 API requests ($BOSH_EXPORTER_ICINGA2_TIMEOUT)",
+	).Envar("BOSH_EXPORTER_ICINGA2_TIMEOUT").Default("10s").Duration()
+
+	icinga2Host = kingpin.Flag(
+		"icinga2.host", "Icinga2 host of the deployment services, defaults to the BOSH Director name ($BOSH_EXPORTER_ICINGA2_HOST)",
+	).Envar("BOSH_EXPORTER_ICINGA2_HOST").Default("").String()
+
+	icinga2Thresholds = kingpin.Flag(
+		"icinga2.thresholds", "Number of failing processes, as <warning>:<critical>, that turn a deployment check result WARNING or CRITICAL ($BOSH_EXPORTER_ICINGA2_THRESHOLDS)",
+	).Envar("BOSH_EXPORTER_ICINGA2_THRESHOLDS").Default("1:3").String()
+
+	icinga2DeploymentThresholds = kingpin.Flag(
+		"icinga2.deployment-thresholds", "Comma separated list of per deployment thresholds, as <deployment>=<warning>:<critical> ($BOSH_EXPORTER_ICINGA2_DEPLOYMENT_THRESHOLDS)",
+	).Envar("BOSH_EXPORTER_ICINGA2_DEPLOYMENT_THRESHOLDS").Default("").String()
+
+	pushInterval = kingpin.Flag(
+		"push.interval", "Interval at which the collected metrics are pushed to the push.* destinations ($BOSH_EXPORTER_PUSH_INTERVAL)",
+	).Envar("BOSH_EXPORTER_PUSH_INTERVAL").Default("1m").Duration()
+
+	pushTimeout = kingpin.Flag(
+		"push.timeout", "Timeout of the push.* destinations connections ($BOSH_EXPORTER_PUSH_TIMEOUT)",
+	).Envar("BOSH_EXPORTER_PUSH_TIMEOUT").Default("10s").Duration()
+
+	pushPrefix = kingpin.Flag(
+		"push.prefix", "Prefix prepended to the Graphite and statsd metric paths ($BOSH_EXPORTER_PUSH_PREFIX)",
+	).Envar("BOSH_EXPORTER_PUSH_PREFIX").Default("").String()
+
+	pushGraphiteAddress = kingpin.Flag(
+		"push.graphite.address", "Graphite address (host:port) where the collected metrics will be pushed using the plaintext protocol ($BOSH_EXPORTER_PUSH_GRAPHITE_ADDRESS)",
+	).Envar("BOSH_EXPORTER_PUSH_GRAPHITE_ADDRESS").Default("").String()
+
+	pushStatsdAddress = kingpin.Flag(
+		"push.statsd.address", "statsd address (host:port) where the collected metrics will be pushed as gauges ($BOSH_EXPORTER_PUSH_STATSD_ADDRESS)",
+	).Envar("BOSH_EXPORTER_PUSH_STATSD_ADDRESS").Default("").String()
+
+	pushInfluxURL = kingpin.Flag(
+		"push.influx.url", "InfluxDB URL where the collected metrics will be written using the line protocol ($BOSH_EXPORTER_PUSH_INFLUX_URL)",
+	).Envar("BOSH_EXPORTER_PUSH_INFLUX_URL").Default("").String()
+
+	pushInfluxDatabase = kingpin.Flag(
+		"push.influx.database", "InfluxDB database, when using the v1 API ($BOSH_EXPORTER_PUSH_INFLUX_DATABASE)",
+	).Envar("BOSH_EXPORTER_PUSH_INFLUX_DATABASE").Default("").String()
+
+	pushInfluxUsername = kingpin.Flag(
+		"push.influx.username", "InfluxDB Username, when using the v1 API ($BOSH_EXPORTER_PUSH_INFLUX_USERNAME)",
+	).Envar("BOSH_EXPORTER_PUSH_INFLUX_USERNAME").Default("").String()
+
+	pushInfluxPassword = kingpin.Flag(
+		"push.influx.password", "InfluxDB Password, when using the v1 API ($BOSH_EXPORTER_PUSH_INFLUX_PASSWORD)",
+	).Envar("BOSH_EXPORTER_PUSH_INFLUX_PASSWORD").Default("").String()
+
+	pushInfluxOrg = kingpin.Flag(
+		"push.influx.org", "InfluxDB organization, when using the v2 API ($BOSH_EXPORTER_PUSH_INFLUX_ORG)",
+	).Envar("BOSH_EXPORTER_PUSH_INFLUX_ORG").Default("").String()
+
+	pushInfluxBucket = kingpin.Flag(
+		"push.influx.bucket", "InfluxDB bucket, when using the v2 API ($BOSH_EXPORTER_PUSH_INFLUX_BUCKET)",
+	).Envar("BOSH_EXPORTER_PUSH_INFLUX_BUCKET").Default("").String()
+
+	pushInfluxToken = kingpin.Flag(
+		"push.influx.token", "InfluxDB API Token ($BOSH_EXPORTER_PUSH_INFLUX_TOKEN)",
+	).Envar("BOSH_EXPORTER_PUSH_INFLUX_TOKEN").Default("").String()
+
 	listenAddress = kingpin.Flag(
-		"web.listen-address", "Address to listen on for web interface and telemetry ($BOSH_EXPORTER_WEB_LISTEN_ADDRESS)",
+		"web.listen-address", "Address to listen on for web interface and telemetry, use unix:<path> to listen on a Unix domain socket ($BOSH_EXPORTER_WEB_LISTEN_ADDRESS)",
 	).Envar("BOSH_EXPORTER_WEB_LISTEN_ADDRESS").Default(":9190").String()
 
+	systemdSocket = kingpin.Flag(
+		"web.systemd-socket", "Use the socket passed by systemd socket activation instead of web.listen-address ($BOSH_EXPORTER_WEB_SYSTEMD_SOCKET)",
+	).Envar("BOSH_EXPORTER_WEB_SYSTEMD_SOCKET").Default("false").Bool()
+
 	metricsPath = kingpin.Flag(
 		"web.telemetry-path", "Path under which to expose Prometheus metrics ($BOSH_EXPORTER_WEB_TELEMETRY_PATH)",
 	).Envar("BOSH_EXPORTER_WEB_TELEMETRY_PATH").Default("/metrics").String()
 
+	webProfilesConfigFile = kingpin.Flag(
+		"web.profiles-config-file", "Path to a YAML file with the named scrape profiles served as filtered views of the metrics under <web.telemetry-path>/profiles/<name> ($BOSH_EXPORTER_WEB_PROFILES_CONFIG_FILE)",
+	).Envar("BOSH_EXPORTER_WEB_PROFILES_CONFIG_FILE").ExistingFile()
+
+	internalMetricsPath = kingpin.Flag(
+		"web.telemetry-internal-path", "Path under which to expose the exporter internal telemetry (go_*, process_* and exporter metrics) apart from the BOSH metrics ($BOSH_EXPORTER_WEB_TELEMETRY_INTERNAL_PATH)",
+	).Envar("BOSH_EXPORTER_WEB_TELEMETRY_INTERNAL_PATH").Default("").String()
+
+	internalListenAddress = kingpin.Flag(
+		"web.telemetry-internal-listen-address", "Address to listen on for the exporter internal telemetry, instead of web.listen-address ($BOSH_EXPORTER_WEB_TELEMETRY_INTERNAL_LISTEN_ADDRESS)",
+	).Envar("BOSH_EXPORTER_WEB_TELEMETRY_INTERNAL_LISTEN_ADDRESS").Default("").String()
+
+	webErrorMode = kingpin.Flag(
+		"web.error-mode", "How to serve metrics when BOSH cannot be fetched: `degraded` returns 200 with bosh_exporter_up 0, `strict` returns 500 ($BOSH_EXPORTER_WEB_ERROR_MODE)",
+	).Envar("BOSH_EXPORTER_WEB_ERROR_MODE").Default(collectors.ErrorModeDegraded).Enum(collectors.ErrorModeDegraded, collectors.ErrorModeStrict)
+
+	webMaxScrapeSeries = kingpin.Flag(
+		"web.max-scrape-series", "Max series served by a metrics scrape, scrapes above it are refused, 0 to disable ($BOSH_EXPORTER_WEB_MAX_SCRAPE_SERIES)",
+	).Envar("BOSH_EXPORTER_WEB_MAX_SCRAPE_SERIES").Default("0").Int()
+
+	webAccessLogFile = kingpin.Flag(
+		"web.access-log-file", "File to write an access log of the HTTP requests to, `-` for the standard output, empty to disable ($BOSH_EXPORTER_WEB_ACCESS_LOG_FILE)",
+	).Envar("BOSH_EXPORTER_WEB_ACCESS_LOG_FILE").Default("").String()
+
+	webCompression = kingpin.Flag(
+		"web.compression", "Compression of the metrics responses: `gzip` when accepted by the client, or `none` ($BOSH_EXPORTER_WEB_COMPRESSION)",
+	).Envar("BOSH_EXPORTER_WEB_COMPRESSION").Default(exposition.CompressionGzip).Enum(exposition.CompressionGzip, exposition.CompressionNone)
+
+	webExpositionCompatibility = kingpin.Flag(
+		"web.exposition-compatibility", "Rewrite or drop the metrics failing `promtool check metrics` and the OpenMetrics strict parsing, for Prometheus Agent mode and strict scrapers ($BOSH_EXPORTER_WEB_EXPOSITION_COMPATIBILITY)",
+	).Envar("BOSH_EXPORTER_WEB_EXPOSITION_COMPATIBILITY").Default("false").Bool()
+
+	webEnableAdminAPI = kingpin.Flag(
+		"web.enable-admin-api", "Enable the admin API to queue BOSH Director problem scans, requires web.auth.username and web.auth.password ($BOSH_EXPORTER_WEB_ENABLE_ADMIN_API)",
+	).Envar("BOSH_EXPORTER_WEB_ENABLE_ADMIN_API").Default("false").Bool()
+
+	webEnableLifecycle = kingpin.Flag(
+		"web.enable-lifecycle", "Enable the endpoints to refresh the exporter on demand, to reload its configuration and to preview the Service Discovery diff, requires web.auth.username and web.auth.password ($BOSH_EXPORTER_WEB_ENABLE_LIFECYCLE)",
+	).Envar("BOSH_EXPORTER_WEB_ENABLE_LIFECYCLE").Default("false").Bool()
+
 	authUsername = kingpin.Flag(
 		"web.auth.username", "Username for web interface basic auth ($BOSH_EXPORTER_WEB_AUTH_USERNAME)",
 	).Envar("BOSH_EXPORTER_WEB_AUTH_USERNAME").String()
@@ -107,6 +745,16 @@ var (
 	).Envar("BOSH_EXPORTER_WEB_TLS_KEYFILE").ExistingFile()
 )
 
+// featureGates are the experimental features enabled with --enable-feature.
+var featureGates *features.Gates
+
+// kubernetesClientInstrumenter throttles and measures the requests of all the
+// Kubernetes clients, and is registered with the first of them.
+var (
+	kubernetesClientInstrumenter     *instrumentation.KubernetesClientInstrumenter
+	kubernetesClientInstrumenterOnce sync.Once
+)
+
 func init() {
 	prometheus.MustRegister(version.NewCollector(*metricsNamespace))
 }
@@ -138,12 +786,28 @@ func (cu boshConfigUpdater) Save() error {
 	return nil
 }
 
-func prometheusHandler() http.Handler {
-	handler := promhttp.Handler()
+func prometheusHandler(name string, gatherer prometheus.Gatherer) http.Handler {
+	if *webExpositionCompatibility {
+		gatherer = exposition.CompatibleGatherer(gatherer)
+	}
+
+	handler := exposition.NewHandler(
+		*metricsNamespace,
+		name,
+		gatherer,
+		*webMaxScrapeSeries,
+		*webCompression,
+		*webErrorMode != collectors.ErrorModeStrict,
+	)
+	prometheus.MustRegister(handler)
 
+	return authHandler(promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, handler))
+}
+
+func authHandler(handler http.Handler) http.Handler {
 	if *authUsername != "" && *authPassword != "" {
-		handler = &basicAuthHandler{
-			handler:  promhttp.Handler().ServeHTTP,
+		return &basicAuthHandler{
+			handler:  handler.ServeHTTP,
 			username: *authUsername,
 			password: *authPassword,
 		}
@@ -152,203 +816,2018 @@ func prometheusHandler() http.Handler {
 	return handler
 }
 
-func readCACert(CACertFile string, logger logger.Logger) (string, error) {
-	if CACertFile != "" {
-		fs := system.NewOsFileSystem(logger)
-
-		CACertFileFullPath, err := fs.ExpandPath(CACertFile)
-		if err != nil {
-			return "", err
-		}
-
-		CACert, err := fs.ReadFileString(CACertFileFullPath)
-		if err != nil {
-			return "", err
-		}
+func isSecretFlag(name string) bool {
+	return strings.Contains(name, "password") || strings.Contains(name, "secret") ||
+		strings.Contains(name, "token") || name == "sd.azure.blob-url" || name == "tracing.otlp-headers"
+}
 
-		return CACert, nil
+// redactFlagValue hides the values of the secret flags and the credentials of
+// the URLs (e.g. `bosh.proxy-url`, `audit.endpoint` or `push.influx.url`).
+func redactFlagValue(name string, value string) string {
+	if value == "" {
+		return value
+	}
+	if isSecretFlag(name) {
+		return "<secret>"
 	}
 
-	return "", nil
-}
-
-func buildBOSHClient() (director.Director, error) {
-	logLevel, err := logger.Levelify(*boshLogLevel)
+	if !strings.Contains(value, "@") {
+		return value
+	}
+	valueURL, err := url.Parse(value)
 	if err != nil {
-		return nil, err
+		return "<secret>"
+	}
+	if valueURL.User != nil {
+		valueURL.User = nil
+		return valueURL.String()
+	}
+	// Without a scheme, `user:password@host` parses as the opaque part of a
+	// `user:` URL.
+	if strings.Contains(valueURL.Opaque, "@") {
+		return "<secret>"
 	}
 
-	logger := logger.NewLogger(logLevel)
+	return value
+}
 
-	directorConfig, err := director.NewConfigFromURL(*boshURL)
-	if err != nil {
-		return nil, err
+func statusConfigHandler(filtersConfig map[string][]string) http.Handler {
+	flags := map[string]string{}
+	for _, flag := range kingpin.CommandLine.Model().Flags {
+		flags[flag.Name] = redactFlagValue(flag.Name, flag.String())
 	}
 
-	boshCACert, err := readCACert(*boshCACertFile, logger)
-	if err != nil {
-		return nil, err
+	response := api.StatusConfigResponse{
+		Status: api.StatusSuccess,
+		Data: api.StatusConfig{
+			Flags:   flags,
+			Filters: filtersConfig,
+		},
 	}
-	directorConfig.CACert = boshCACert
 
-	anonymousDirector, err := director.NewFactory(logger).New(directorConfig, nil, nil)
-	if err != nil {
-		return nil, err
-	}
+	return authHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Errorf("Error encoding config status: %v", err)
+		}
+	}))
+}
 
-	boshInfo, err := anonymousDirector.Info()
-	if err != nil {
-		return nil, err
+type boshRefresher struct {
+	boshCollectors []*collectors.BoshCollector
+	mu             *sync.Mutex
+}
+
+func (r *boshRefresher) Refresh() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failed := 0
+	for _, boshCollector := range r.boshCollectors {
+		if err := boshCollector.Refresh(); err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.New(fmt.Sprintf("Error refreshing %d of %d BOSH Directors", failed, len(r.boshCollectors)))
 	}
 
-	if boshInfo.Auth.Type != "uaa" {
-		directorConfig.Client = *boshUsername
-		directorConfig.ClientSecret = *boshPassword
-	} else {
-		uaaURL := boshInfo.Auth.Options["url"]
-		uaaURLStr, ok := uaaURL.(string)
-		if !ok {
-			return nil, fmt.Errorf("Expected UAA URL '%s' to be a string", uaaURL)
+	return nil
+}
+
+func refreshHandler(refresher *boshRefresher) http.Handler {
+	return authHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
 		}
 
-		uaaConfig, err := uaa.NewConfigFromURL(uaaURLStr)
-		if err != nil {
-			return nil, err
+		log.Infof("Refresh requested from `%s`", r.RemoteAddr)
+		if err := refresher.Refresh(); err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+		w.Write([]byte("OK\n"))
+	}))
+}
 
-		uaaConfig.CACert = boshCACert
+type deploymentProblemsScanner struct {
+	environment   string
+	boshCollector *collectors.BoshCollector
+}
 
-		if *boshUAAClientID != "" && *boshUAAClientSecret != "" {
-			uaaConfig.Client = *boshUAAClientID
-			uaaConfig.ClientSecret = *boshUAAClientSecret
-		} else {
-			uaaConfig.Client = "bosh_cli"
+func deploymentScanHandler(scanners []deploymentProblemsScanner) http.Handler {
+	return authHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/deployments/"), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] != "scan" {
+			http.NotFound(w, r)
+			return
 		}
+		deploymentName := parts[0]
 
-		uaaFactory := uaa.NewFactory(logger)
-		uaaClient, err := uaaFactory.New(uaaConfig)
-		if err != nil {
-			return nil, err
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
 		}
 
-		if *boshUAAClientID != "" && *boshUAAClientSecret != "" {
-			directorConfig.TokenFunc = uaa.NewClientTokenSession(uaaClient).TokenFunc
-		} else {
-			answers := []uaa.PromptAnswer{
-				uaa.PromptAnswer{
-					Key:   "username",
-					Value: *boshUsername,
-				},
-				uaa.PromptAnswer{
-					Key:   "password",
-					Value: *boshPassword,
-				},
+		environment := r.URL.Query().Get("environment")
+		for _, scanner := range scanners {
+			if environment != "" && scanner.environment != environment {
+				continue
+			}
+
+			err := scanner.boshCollector.ScanDeploymentProblems(deploymentName)
+			if err == deployments.ErrDeploymentNotFound {
+				continue
+			}
+			if err == deployments.ErrProblemsScanInProgress {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
 			}
-			accessToken, err := uaaClient.OwnerPasswordCredentialsGrant(answers)
 			if err != nil {
-				return nil, err
+				log.Errorf("Error queuing a problem scan of deployment `%s`: %v", deploymentName, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
 			}
 
-			refreshToken := ""
-			if refreshableToken, ok := accessToken.(uaa.RefreshableAccessToken); ok {
-				refreshToken = refreshableToken.RefreshValue()
+			log.Infof("Problem scan of deployment `%s` in `%s` requested from `%s`", deploymentName, scanner.environment, r.RemoteAddr)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			response := api.DeploymentScanResponse{
+				Status: api.StatusSuccess,
+				Data:   api.DeploymentScan{Environment: scanner.environment, Deployment: deploymentName},
+			}
+			if err := json.NewEncoder(w).Encode(response); err != nil {
+				log.Errorf("Error encoding problem scan response: %v", err)
 			}
+			return
+		}
 
-			origToken := uaa.NewRefreshableAccessToken(accessToken.Type(), accessToken.Value(), refreshToken)
-			directorConfig.TokenFunc = uaa.NewAccessTokenSession(uaaClient, origToken, boshConfigUpdater{}, "").TokenFunc
+		http.Error(w, fmt.Sprintf("Deployment `%s` not found", deploymentName), http.StatusNotFound)
+	}))
+}
+
+type environmentFilters struct {
+	environment               string
+	boshName                  string
+	deploymentsFilter         *filters.DeploymentsFilter
+	azsFilter                 *filters.AZsFilter
+	processesFilter           *filters.RegexpFilter
+	deploymentProcessesFilter *filters.DeploymentProcessesFilter
+	expressionFilter          *filters.ExpressionFilter
+	cidrsFilter               *filters.CidrFilter
+}
+
+func (f environmentFilters) explain(query url.Values) api.EnvironmentFilters {
+	results := []filters.FilterResult{}
+	if deployment, ok := query["deployment"]; ok {
+		results = append(results, f.deploymentsFilter.Explain(deployment[0]))
+	}
+	if az, ok := query["az"]; ok {
+		azAttributes := map[string]string{}
+		for name, value := range query {
+			if strings.HasPrefix(name, filters.AZCloudPropertiesAttributePrefix) {
+				azAttributes[name] = value[0]
+			}
+		}
+		results = append(results, f.azsFilter.Explain(az[0], azAttributes))
+	}
+	if process, ok := query["process"]; ok {
+		results = append(results, f.processesFilter.Explain("processes", process[0]))
+		if deployment, ok := query["deployment"]; ok {
+			results = append(results, f.deploymentProcessesFilter.Explain(deployment[0], process[0]))
 		}
 	}
+	if ip, ok := query["ip"]; ok {
+		results = append(results, f.cidrsFilter.Explain(ip[0]))
+	}
 
-	boshFactory := director.NewFactory(logger)
-	boshClient, err := boshFactory.New(directorConfig, director.NewNoopTaskReporter(), director.NewNoopFileReporter())
-	if err != nil {
-		return nil, err
+	expressionFields := map[string]string{}
+	for _, field := range []string{"deployment", "job", "az", "process", "ip"} {
+		if value, ok := query[field]; ok {
+			expressionFields[field] = value[0]
+		}
+	}
+	if len(expressionFields) > 0 {
+		results = append(results, f.expressionFilter.Explain(expressionFields))
+	}
+
+	explanation := api.EnvironmentFilters{
+		Environment: f.environment,
+		BoshName:    f.boshName,
+		Accepted:    true,
+		Filters:     []api.FilterResult{},
+	}
+	for _, result := range results {
+		explanation.Filters = append(explanation.Filters, api.FilterResult(result))
+		explanation.Accepted = explanation.Accepted && result.Accepted
 	}
 
-	return boshClient, nil
+	return explanation
 }
 
-func main() {
-	log.AddFlags(kingpin.CommandLine)
-	kingpin.Version(version.Print("fbosh_exporter"))
-	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+func debugHTTPHandler(httpDebugger *fetcher.HTTPDebugger) http.Handler {
+	return authHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+			if err != nil {
+				http.Error(w, "The enabled parameter must be true or false", http.StatusBadRequest)
+				return
+			}
+			httpDebugger.SetEnabled(enabled)
+			log.Infof("BOSH HTTP debug logging %s from `%s`", map[bool]string{true: "enabled", false: "disabled"}[enabled], r.RemoteAddr)
+		default:
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+			http.Error(w, "Only GET and POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := api.HTTPDebugResponse{Status: api.StatusSuccess, Data: api.HTTPDebug{Enabled: httpDebugger.Enabled()}}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Errorf("Error encoding HTTP debug response: %v", err)
+		}
+	}))
+}
+
+func debugFiltersHandler(boshFilters []*environmentFilters) http.Handler {
+	return authHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+		response := api.FiltersResponse{Status: api.StatusSuccess, Data: []api.EnvironmentFilters{}}
+		if query.Get("deployment") == "" && query.Get("az") == "" && query.Get("process") == "" && query.Get("ip") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			response = api.FiltersResponse{
+				Status: api.StatusError,
+				Error:  "At least one of the `deployment`, `az`, `process` or `ip` parameters is required",
+			}
+		} else {
+			for _, boshFilter := range boshFilters {
+				response.Data = append(response.Data, boshFilter.explain(query))
+			}
+		}
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Errorf("Error encoding filters debug: %v", err)
+		}
+	}))
+}
+
+type serviceDiscoveryPreviewer struct {
+	environment   string
+	boshCollector *collectors.BoshCollector
+}
+
+func debugServiceDiscoveryDiffHandler(previewers []serviceDiscoveryPreviewer) http.Handler {
+	return authHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var expressionFilter *filters.ExpressionFilter
+		if expression := r.URL.Query().Get("expression"); expression != "" {
+			var err error
+			expressionFilter, err = filters.NewExpressionFilter(expression)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				response := api.ServiceDiscoveryDiffResponse{Status: api.StatusError, Error: err.Error()}
+				if err := json.NewEncoder(w).Encode(response); err != nil {
+					log.Errorf("Error encoding Service Discovery diff: %v", err)
+				}
+				return
+			}
+		}
+
+		environment := r.URL.Query().Get("environment")
+		response := api.ServiceDiscoveryDiffResponse{Status: api.StatusSuccess, Data: []api.EnvironmentServiceDiscoveryDiff{}}
+		for _, previewer := range previewers {
+			if environment != "" && previewer.environment != environment {
+				continue
+			}
+
+			environmentDiff := api.EnvironmentServiceDiscoveryDiff{
+				Environment: previewer.environment,
+				Added:       []api.ServiceDiscoveryTarget{},
+				Removed:     []api.ServiceDiscoveryTarget{},
+			}
+			diff, err := previewer.boshCollector.PreviewServiceDiscovery(expressionFilter)
+			if err != nil {
+				environmentDiff.Error = err.Error()
+			}
+			for _, target := range diff.Added {
+				environmentDiff.Added = append(environmentDiff.Added, serviceDiscoveryTarget(target))
+			}
+			for _, target := range diff.Removed {
+				environmentDiff.Removed = append(environmentDiff.Removed, serviceDiscoveryTarget(target))
+			}
+			environmentDiff.Unchanged = diff.Unchanged
+			response.Data = append(response.Data, environmentDiff)
+		}
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Errorf("Error encoding Service Discovery diff: %v", err)
+		}
+	}))
+}
+
+func serviceDiscoveryTarget(target collectors.ServiceDiscoveryTarget) api.ServiceDiscoveryTarget {
+	labels := map[string]string{}
+	for name, value := range target.Labels {
+		labels[string(name)] = string(value)
+	}
+
+	return api.ServiceDiscoveryTarget{Target: target.Target, Labels: labels}
+}
+
+func internalTelemetryPath() string {
+	if *internalMetricsPath == "" && *internalListenAddress != "" {
+		return "/metrics"
+	}
+	return *internalMetricsPath
+}
+
+func validateInternalTelemetry() error {
+	if *internalListenAddress == "" && *internalMetricsPath != "" && *internalMetricsPath == *metricsPath {
+		return errors.New(fmt.Sprintf("web.telemetry-internal-path `%s` must differ from web.telemetry-path unless web.telemetry-internal-listen-address is set", *internalMetricsPath))
+	}
+	if *internalListenAddress != "" && *internalListenAddress == *listenAddress && !*systemdSocket {
+		return errors.New(fmt.Sprintf("web.telemetry-internal-listen-address `%s` must differ from web.listen-address", *internalListenAddress))
+	}
+	return nil
+}
+
+func listen() (net.Listener, error) {
+	if *systemdSocket {
+		return systemdListener()
+	}
+
+	if strings.HasPrefix(*listenAddress, "unix:") {
+		socketPath := strings.TrimPrefix(*listenAddress, "unix:")
+		if fileInfo, err := os.Stat(socketPath); err == nil && fileInfo.Mode()&os.ModeSocket != 0 {
+			os.Remove(socketPath)
+		}
+		return net.Listen("unix", socketPath)
+	}
+
+	return net.Listen("tcp", *listenAddress)
+}
+
+func systemdListener() (net.Listener, error) {
+	const listenFDsStart = 3
+
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errors.New("No socket passed by systemd socket activation")
+	}
+
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, errors.New("No socket passed by systemd socket activation")
+	}
+	if fds > 1 {
+		log.Warnf("systemd passed %d sockets, only the first one will be used", fds)
+	}
+
+	file := os.NewFile(uintptr(listenFDsStart), "LISTEN_FD_3")
+	defer file.Close()
+
+	return net.FileListener(file)
+}
+
+func buildTLSPolicy() (tlspolicy.Policy, error) {
+	tlsPolicy, err := tlspolicy.New(*tlsPolicyName)
+	if err != nil {
+		return tlspolicy.Policy{}, err
+	}
+	if err := tlsPolicy.Validate(); err != nil {
+		return tlspolicy.Policy{}, err
+	}
+	if *tlsCertFile != "" && *tlsKeyFile != "" {
+		if err := tlsPolicy.ValidateKeyPair(*tlsCertFile, *tlsKeyFile); err != nil {
+			return tlspolicy.Policy{}, err
+		}
+	}
+
+	return tlsPolicy, nil
+}
+
+func buildRevocationChecker() (*fetcher.RevocationChecker, error) {
+	if len(*boshCRLFiles) == 0 && *boshOCSPStaple == fetcher.OCSPStapleOff {
+		return nil, nil
+	}
+
+	return fetcher.NewRevocationChecker(*boshCRLFiles, *boshOCSPStaple)
+}
+
+func buildCertificatePinner() (*fetcher.CertificatePinner, error) {
+	if len(*boshCertPinsSHA256) == 0 {
+		return nil, nil
+	}
+
+	return fetcher.NewCertificatePinner(*boshCertPinsSHA256)
+}
+
+func boshProxyConfig() proxies.Config {
+	return proxies.FromEnvironment().WithOverrides(*boshProxyURL, *boshNoProxy)
+}
+
+func kubernetesProxyConfig() proxies.Config {
+	return proxies.FromEnvironment().WithOverrides(*kubernetesProxyURL, *kubernetesNoProxy)
+}
+
+// buildBOSHClient watches the CA certificate files until stop is closed, once
+// the client is built. One-shot commands pass a nil stop to not watch them.
+func buildBOSHClient(environment environments.Environment, tlsPolicy tlspolicy.Policy, revocationChecker *fetcher.RevocationChecker, certificatePinner *fetcher.CertificatePinner, httpDebugger *fetcher.HTTPDebugger, tracer *tracing.Tracer, stop <-chan struct{}) (director.Director, environments.DirectorURL, *fetcher.DirectorSession, error) {
+	logLevel, err := logger.Levelify(*boshLogLevel)
+	if err != nil {
+		return nil, environments.DirectorURL{}, nil, err
+	}
+
+	logger := logger.NewLogger(logLevel)
+
+	directorURL, err := environments.ParseDirectorURL(environment.URL)
+	if err != nil {
+		return nil, environments.DirectorURL{}, nil, err
+	}
+
+	caBundle, err := fetcher.NewCABundle(environment.CACerts(), environment.UseSystemCAs)
+	if err != nil {
+		return nil, environments.DirectorURL{}, nil, err
+	}
+	if revocationChecker != nil {
+		caBundle.SetRevocationChecker(revocationChecker)
+	}
+
+	proxy, err := boshProxyConfig().ProxyFunc()
+	if err != nil {
+		return nil, environments.DirectorURL{}, nil, err
+	}
+
+	verifiers := []fetcher.ChainVerifier{}
+	if certificatePinner != nil {
+		verifiers = append(verifiers, certificatePinner.Check)
+	}
+	directorTLSConfig := caBundle.TLSConfig(directorURL.Host, verifiers...)
+	tlsPolicy.Apply(directorTLSConfig)
+	session := fetcher.NewDirectorSession(directorTLSConfig, proxy, tracer)
+	session.HTTPClient().Transport = httpDebugger.Wrap(session.HTTPClient().Transport)
+	if *boshConditionalRequests {
+		session.CacheResponses(*boshConditionalRequestsMaxBodyBytes)
+	}
+
+	directorURL, err = environments.ResolveDirectorURL(directorURL, session.HTTPClient())
+	if err != nil {
+		return nil, environments.DirectorURL{}, nil, err
+	}
+	if directorURL.String() != environment.URL {
+		log.Debugf("Using BOSH Director URL `%s` for `%s`", directorURL, environment.URL)
+	}
+
+	directorConfig := director.FactoryConfig{Host: directorURL.Host, Port: directorURL.Port}
+
+	if environment.OIDCIssuerURL != "" {
+		oidcAuthenticator, err := buildOIDCAuthenticator(environment, caBundle, tlsPolicy, proxy, httpDebugger)
+		if err != nil {
+			return nil, environments.DirectorURL{}, nil, err
+		}
+		directorConfig.TokenFunc = oidcAuthenticator.TokenFunc
+	} else if err := configureUAAAuth(&directorConfig, directorURL.PathPrefix, session.HTTPClient(), environment, caBundle, tlsPolicy, proxy, httpDebugger, logger); err != nil {
+		return nil, environments.DirectorURL{}, nil, err
+	}
+
+	boshClient, err := fetcher.NewDirector(directorConfig, directorURL.PathPrefix, session.HTTPClient(), logger)
+	if err != nil {
+		return nil, environments.DirectorURL{}, nil, err
+	}
+
+	if stop != nil && *boshCACertReloadInterval > 0 && len(environment.CACerts()) > 0 {
+		go caBundle.Watch(*boshCACertReloadInterval, stop)
+	}
+
+	return boshClient, directorURL, session, nil
+}
+
+func configureUAAAuth(directorConfig *director.FactoryConfig, pathPrefix string, httpClient *http.Client, environment environments.Environment, caBundle *fetcher.CABundle, tlsPolicy tlspolicy.Policy, proxy func(*http.Request) (*url.URL, error), httpDebugger *fetcher.HTTPDebugger, logger logger.Logger) error {
+	anonymousDirector, err := fetcher.NewDirector(*directorConfig, pathPrefix, httpClient, logger)
+	if err != nil {
+		return err
+	}
+
+	boshInfo, err := anonymousDirector.Info()
+	if err != nil {
+		return err
+	}
+
+	if boshInfo.Auth.Type != "uaa" {
+		directorConfig.Client = environment.Username
+		directorConfig.ClientSecret = environment.Password
+	} else {
+		uaaURL := boshInfo.Auth.Options["url"]
+		uaaURLStr, ok := uaaURL.(string)
+		if !ok {
+			return fmt.Errorf("Expected UAA URL '%s' to be a string", uaaURL)
+		}
+
+		uaaConfig, err := uaa.NewConfigFromURL(uaaURLStr)
+		if err != nil {
+			return err
+		}
+
+		uaaTLSConfig := caBundle.TLSConfig(uaaConfig.Host)
+		tlsPolicy.Apply(uaaTLSConfig)
+		uaaHTTPClient := fetcher.NewTLSClient(uaaTLSConfig, proxy)
+		uaaHTTPClient.Transport = httpDebugger.Wrap(uaaHTTPClient.Transport)
+
+		if environment.UAAClientID != "" && environment.UAAClientSecret != "" {
+			uaaConfig.Client = environment.UAAClientID
+			uaaConfig.ClientSecret = environment.UAAClientSecret
+		} else {
+			uaaConfig.Client = "bosh_cli"
+		}
+
+		uaaClient, err := fetcher.NewUAA(uaaConfig, uaaHTTPClient, logger)
+		if err != nil {
+			return err
+		}
+
+		if environment.UAAClientID != "" && environment.UAAClientSecret != "" {
+			directorConfig.TokenFunc = uaa.NewClientTokenSession(uaaClient).TokenFunc
+		} else {
+			answers := []uaa.PromptAnswer{
+				uaa.PromptAnswer{
+					Key:   "username",
+					Value: environment.Username,
+				},
+				uaa.PromptAnswer{
+					Key:   "password",
+					Value: environment.Password,
+				},
+			}
+			accessToken, err := uaaClient.OwnerPasswordCredentialsGrant(answers)
+			if err != nil {
+				return err
+			}
+
+			refreshToken := ""
+			if refreshableToken, ok := accessToken.(uaa.RefreshableAccessToken); ok {
+				refreshToken = refreshableToken.RefreshValue()
+			}
+
+			origToken := uaa.NewRefreshableAccessToken(accessToken.Type(), accessToken.Value(), refreshToken)
+			directorConfig.TokenFunc = uaa.NewAccessTokenSession(uaaClient, origToken, boshConfigUpdater{}, "").TokenFunc
+		}
+	}
+
+	return nil
+}
+
+func buildOIDCAuthenticator(environment environments.Environment, caBundle *fetcher.CABundle, tlsPolicy tlspolicy.Policy, proxy func(*http.Request) (*url.URL, error), httpDebugger *fetcher.HTTPDebugger) (*authenticators.OIDCAuthenticator, error) {
+	issuerURL, err := url.Parse(environment.OIDCIssuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcTLSConfig := caBundle.TLSConfig(issuerURL.Hostname())
+	tlsPolicy.Apply(oidcTLSConfig)
+	httpClient := fetcher.NewTLSClient(oidcTLSConfig, proxy)
+	httpClient.Transport = httpDebugger.Wrap(httpClient.Transport)
+	httpClient.Timeout = 30 * time.Second
+
+	return authenticators.NewOIDCAuthenticator(
+		authenticators.OIDCConfig{
+			IssuerURL:        environment.OIDCIssuerURL,
+			ClientID:         environment.OIDCClientID,
+			ClientSecret:     environment.OIDCClientSecret,
+			Audience:         environment.OIDCAudience,
+			Scopes:           environment.OIDCScopes,
+			GrantType:        environment.OIDCGrantType,
+			SubjectTokenFile: environment.OIDCSubjectTokenFile,
+		},
+		httpClient,
+	)
+}
+
+func tlsEndpoints(directorURL environments.DirectorURL, boshInfo director.Info) []collectors.TLSEndpoint {
+	endpoints := []collectors.TLSEndpoint{
+		{Name: "director", Address: net.JoinHostPort(directorURL.Host, strconv.Itoa(directorURL.Port))},
+	}
+
+	if boshInfo.Auth.Type != "uaa" {
+		return endpoints
+	}
+
+	uaaURL, ok := boshInfo.Auth.Options["url"].(string)
+	if !ok {
+		return endpoints
+	}
+	parsedUAAURL, err := url.Parse(uaaURL)
+	if err != nil || parsedUAAURL.Hostname() == "" {
+		log.Errorf("Error parsing UAA URL `%s`, its TLS certificate will not be checked", uaaURL)
+		return endpoints
+	}
+
+	uaaPort := parsedUAAURL.Port()
+	if uaaPort == "" {
+		uaaPort = "443"
+	}
+
+	return append(endpoints, collectors.TLSEndpoint{Name: "uaa", Address: net.JoinHostPort(parsedUAAURL.Hostname(), uaaPort)})
+}
+
+func splitFilter(filter string) []string {
+	if filter == "" {
+		return nil
+	}
+
+	return strings.Split(filter, ",")
+}
+
+func flagsFilters() environments.Filters {
+	return environments.Filters{
+		Deployments:         splitFilter(*filterDeployments),
+		AZs:                 splitFilter(*filterAZs),
+		Collectors:          splitFilter(*filterCollectors),
+		CIDRs:               splitFilter(*filterCIDRs),
+		ProcessesRegexp:     *sdProcessesRegexp,
+		DeploymentProcesses: *filterDeploymentProcesses,
+		Expression:          *filterExpression,
+		Profile:             *filterProfile,
+	}
+}
+
+func applyFilterProfile(environmentFilters environments.Filters) (environments.Filters, error) {
+	profile, err := filters.NewFilterProfile(environmentFilters.Profile)
+	if err != nil {
+		return environmentFilters, err
+	}
+
+	environmentFilters.Expression = profile.Expression(environmentFilters.Expression)
+	environmentFilters.Collectors = profile.Collectors(environmentFilters.Collectors)
+
+	return environmentFilters, nil
+}
+
+func loadEnvironments() ([]environments.Environment, error) {
+	if *environmentsConfig == "" {
+		if *boshURL == "" || (len(*boshCACertFiles) == 0 && !*boshUseSystemCAs) || *metricsEnvironment == "" {
+			return nil, errors.New("Flags --bosh.url, --bosh.ca-cert-file (or --bosh.use-system-cas) and --metrics.environment are required unless --environments.config is set")
+		}
+
+		environmentFilters, err := applyFilterProfile(flagsFilters())
+		if err != nil {
+			return nil, err
+		}
+
+		return []environments.Environment{
+			{
+				Environment:          *metricsEnvironment,
+				URL:                  *boshURL,
+				Username:             *boshUsername,
+				Password:             *boshPassword,
+				UAAClientID:          *boshUAAClientID,
+				UAAClientSecret:      *boshUAAClientSecret,
+				OIDCIssuerURL:        *boshOIDCIssuerURL,
+				OIDCClientID:         *boshOIDCClientID,
+				OIDCClientSecret:     *boshOIDCClientSecret,
+				OIDCAudience:         *boshOIDCAudience,
+				OIDCScopes:           splitFilter(*boshOIDCScopes),
+				OIDCGrantType:        *boshOIDCGrantType,
+				OIDCSubjectTokenFile: *boshOIDCSubjectTokenFile,
+				CACertFiles:          *boshCACertFiles,
+				UseSystemCAs:         *boshUseSystemCAs,
+				SDFilename:           *sdFilename,
+				Filters:              environmentFilters,
+			},
+		}, nil
+	}
+
+	config, err := environments.LoadConfig(*environmentsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	boshEnvironments := []environments.Environment{}
+	for _, environment := range config.Environments {
+		if environment.Environment == "" {
+			environment.Environment = *metricsEnvironment
+		}
+		if environment.Environment == "" {
+			return nil, fmt.Errorf("Environment `%s` does not have an `environment` label and --metrics.environment is not set", environment.URL)
+		}
+		environment.Filters, err = applyFilterProfile(environment.Filters.WithDefaults(flagsFilters()))
+		if err != nil {
+			return nil, fmt.Errorf("Environment `%s`: %v", environment.URL, err)
+		}
+		environment.UseSystemCAs = environment.UseSystemCAs || *boshUseSystemCAs
+		boshEnvironments = append(boshEnvironments, environment)
+	}
+
+	return boshEnvironments, nil
+}
+
+func buildBoshFetcher(
+	environment environments.Environment,
+	boshClient director.Director,
+	directorSession *fetcher.DirectorSession,
+	replaySnapshot *fetcher.Snapshot,
+) (fetcher.SnapshotFetcher, *filters.DeploymentsFilter, *filters.ExpressionFilter, error) {
+	deploymentsFilter := filters.NewDeploymentsFilter(environment.Filters.Deployments, boshClient)
+
+	expressionFilter, err := filters.NewExpressionFilter(environment.Filters.Expression)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if replaySnapshot != nil {
+		return fetcher.NewReplayFetcher(*replaySnapshot, deploymentsFilter, expressionFilter), deploymentsFilter, expressionFilter, nil
+	}
+
+	fetchAZCloudProperties := filters.NewAZsFilter(environment.Filters.AZs).CloudPropertiesRequired()
+	for _, attribute := range append(splitFilter(*metricsInstanceAttributes), splitFilter(*sdInstanceAttributes)...) {
+		if strings.HasPrefix(attribute, filters.AZCloudPropertiesAttributePrefix) {
+			fetchAZCloudProperties = true
+		}
+	}
+
+	collectorsFilter, err := filters.NewCollectorsFilter(environment.Filters.Collectors)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	deploymentsFetcher := deployments.NewFetcher(*deploymentsFilter, expressionFilter, boshClient, *boshProblemsScanInterval, fetchAZCloudProperties)
+	deploymentsFetcher.SetFetchSpread(*boshFetchSpread)
+	boshFetcher := fetcher.NewFetcher(deploymentsFetcher, boshClient)
+	boshFetcher.SetFetchTasks(collectorsFilter.Enabled(filters.TasksCollector))
+	deploymentsFetcher.SetCompareLatestVersions(collectorsFilter.Enabled(filters.DeploymentsCollector))
+	if directorSession != nil {
+		deploymentsFetcher.SetVMInfoExtensions(directorSession.VMInfoExtensions())
+		boshFetcher.SetDirectorInfoExtensions(directorSession.DirectorInfoExtensions())
+	}
+	return boshFetcher, deploymentsFilter, expressionFilter, nil
+}
+
+func buildTaskWatchdog(environment environments.Environment, boshInfo director.Info, boshClient director.Director, directorSession *fetcher.DirectorSession) *fetcher.TaskWatchdog {
+	return fetcher.NewTaskWatchdog(
+		*metricsNamespace,
+		environment.Environment,
+		boshInfo.Name,
+		boshInfo.UUID,
+		boshClient,
+		directorSession.StartedTasks(),
+		*boshTaskWatchdogDeadline,
+	)
+}
+
+func buildKubernetesEventRecorder() (*sinks.KubernetesEventRecorder, error) {
+	var namespace, name, kind string
+	switch *kubernetesEventsObject {
+	case "configmap":
+		if *sdKubernetesConfigMap == "" {
+			return nil, errors.New("Flag `sd.kubernetes.configmap` must be set to emit Kubernetes Events on the Service Discovery ConfigMap")
+		}
+		if parts := strings.SplitN(*sdKubernetesConfigMap, "/", 2); len(parts) == 2 {
+			namespace, name = parts[0], parts[1]
+		} else {
+			name = parts[0]
+		}
+		kind = "ConfigMap"
+	default:
+		name = os.Getenv("POD_NAME")
+		if name == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return nil, fmt.Errorf("Error while getting the exporter Pod name: %v", err)
+			}
+			name = hostname
+		}
+		kind = "Pod"
+	}
+
+	kubernetesConfig, kubernetesClient, err := inClusterKubernetesConfig(namespace, name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return sinks.NewKubernetesEventRecorder(kubernetesConfig, kind, kubernetesClient), nil
+}
+
+// inClusterKubernetesConfig loads the in-cluster Kubernetes configuration,
+// with a client going through the Kubernetes proxy and the Kubernetes client
+// instrumenter shared by all the Kubernetes clients.
+func inClusterKubernetesConfig(namespace string, name string, key string) (sinks.KubernetesConfig, *http.Client, error) {
+	proxy, err := kubernetesProxyConfig().ProxyFunc()
+	if err != nil {
+		return sinks.KubernetesConfig{}, nil, err
+	}
+
+	kubernetesConfig, kubernetesClient, err := sinks.InClusterKubernetesConfig(namespace, name, key, proxy)
+	if err != nil {
+		return sinks.KubernetesConfig{}, nil, err
+	}
+
+	kubernetesClientInstrumenterOnce.Do(func() {
+		kubernetesClientInstrumenter = instrumentation.NewKubernetesClientInstrumenter(*metricsNamespace, *kubernetesQPS, *kubernetesBurst)
+		prometheus.MustRegister(kubernetesClientInstrumenter)
+	})
+	kubernetesClient.Transport = kubernetesClientInstrumenter.Wrap(kubernetesClient.Transport)
+
+	return kubernetesConfig, kubernetesClient, nil
+}
+
+func buildBoshCollector(
+	environment environments.Environment,
+	boshInfo director.Info,
+	boshClient director.Director,
+	directorSession *fetcher.DirectorSession,
+	replaySnapshot *fetcher.Snapshot,
+	serviceDiscoverySinks []sinks.Sink,
+	serviceDiscoverySigningKey []byte,
+	snapshotPublishers []publishers.Publisher,
+	tracer *tracing.Tracer,
+	eventRecorder collectors.EventRecorder,
+	labelSanitizer *sanitizers.LabelSanitizer,
+) (*collectors.BoshCollector, *environmentFilters, map[string][]string, error) {
+	boshFetcher, deploymentsFilter, expressionFilter, err := buildBoshFetcher(environment, boshClient, directorSession, replaySnapshot)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	azsFilter := filters.NewAZsFilter(environment.Filters.AZs)
+
+	collectorsFilter, err := filters.NewCollectorsFilter(environment.Filters.Collectors)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cidrsFilter, err := filters.NewCidrFilter(environment.Filters.CIDRs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var processesFilters []string
+	if environment.Filters.ProcessesRegexp != "" {
+		processesFilters = []string{environment.Filters.ProcessesRegexp}
+	}
+	processesFilter, err := filters.NewRegexpFilter(processesFilters)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Error processing Processes Regexp: %v", err)
+	}
+
+	deploymentProcessesFilter, err := filters.NewDeploymentProcessesFilter(environment.Filters.DeploymentProcesses)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	sloObjectives, err := collectors.ParseSLOObjectives(*metricsSLOObjective, splitFilter(*metricsSLODeploymentObjectives))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	processPorts, err := collectors.ParseProcessPorts(splitFilter(*sdProcessPorts))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var processAnnotations []collectors.ProcessAnnotation
+	if *sdProcessAnnotationsFile != "" {
+		processAnnotations, err = collectors.LoadProcessAnnotations(*sdProcessAnnotationsFile)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	var processThresholds []collectors.ProcessThreshold
+	if *metricsProcessThresholdsFile != "" {
+		processThresholds, err = collectors.LoadProcessThresholds(*metricsProcessThresholdsFile)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	pauseWindows, err := fetcher.ParsePauseWindows(*scrapePauseCron)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	streamDeployments := *boshStreamDeployments || featureGates.Enabled(features.StreamDeployments)
+	if streamDeployments && (len(pauseWindows) > 0 || len(snapshotPublishers) > 0) {
+		log.Warnf("Not streaming the deployments of BOSH Director `%s`: scrape pause windows and publishers need them all at once", boshInfo.Name)
+	} else if streamDeployments && *boshFetchSpread > 0 {
+		log.Warnf("Not spreading the deployment fetches of BOSH Director `%s`: streamed deployments are fetched on every scrape", boshInfo.Name)
+	}
+
+	boshCollector := collectors.NewBoshCollector(
+		*metricsNamespace,
+		environment.Environment,
+		boshInfo.Name,
+		boshInfo.UUID,
+		*metricsStemcellVersionsThreshold,
+		*metricsStemcellDaysThreshold,
+		*metricsFailedTasksWindow,
+		splitFilter(*metricsInstanceAttributes),
+		*metricsPersistentDiskGrowthWindow,
+		sloObjectives,
+		*metricsSLOWindow,
+		*metricsTimestamps,
+		*metricsTimestampsMaxAge,
+		*webErrorMode,
+		*metricsMaxSeries,
+		featureGates.Enabled(features.VitalsHistograms),
+		*metricsKBSeries,
+		processThresholds,
+		*metricsStoppedDeployments,
+		streamDeployments,
+		tracer,
+		eventRecorder,
+		environment.SDFilename,
+		*sdTmpDir,
+		*sdFsync,
+		*sdKeepBackups,
+		*sdRefuseEmptyOutput,
+		serviceDiscoverySinks,
+		*sdMetadata,
+		serviceDiscoverySigningKey,
+		*sdTargetTTL,
+		*sdIPFallbackTTL,
+		splitFilter(*sdInstanceAttributes),
+		processPorts,
+		processAnnotations,
+		*sdTargetMode,
+		*sdSkippedInstancesLogInterval,
+		labelSanitizer,
+		snapshotPublishers,
+		pauseWindows,
+		boshFetcher,
+		collectorsFilter,
+		azsFilter,
+		processesFilter,
+		deploymentProcessesFilter,
+		expressionFilter,
+		cidrsFilter,
+	)
+	enabledCollectors := []string{}
+	for _, collectorName := range []string{filters.DeploymentsCollector, filters.JobsCollector, filters.ServiceDiscoveryCollector, filters.TasksCollector} {
+		if collectorsFilter.Enabled(collectorName) {
+			enabledCollectors = append(enabledCollectors, collectorName)
+		}
+	}
+	filtersConfig := map[string][]string{
+		"deployments": environment.Filters.Deployments,
+		"azs":         environment.Filters.AZs,
+		"collectors":  enabledCollectors,
+		"cidrs":       environment.Filters.CIDRs,
+		"processes":   processesFilters,
+	}
+	if environment.Filters.DeploymentProcesses != "" {
+		filtersConfig["deployment_processes"] = strings.Split(environment.Filters.DeploymentProcesses, ";")
+	}
+	if environment.Filters.Expression != "" {
+		filtersConfig["expression"] = []string{environment.Filters.Expression}
+	}
+
+	debugFilters := &environmentFilters{
+		environment:               environment.Environment,
+		boshName:                  boshInfo.Name,
+		deploymentsFilter:         deploymentsFilter,
+		azsFilter:                 azsFilter,
+		processesFilter:           processesFilter,
+		deploymentProcessesFilter: deploymentProcessesFilter,
+		expressionFilter:          expressionFilter,
+		cidrsFilter:               cidrsFilter,
+	}
+
+	return boshCollector, debugFilters, filtersConfig, nil
+}
+
+func buildIcinga2Publisher() (*publishers.Icinga2Publisher, error) {
+	thresholds, err := publishers.ParseIcinga2Thresholds(*icinga2Thresholds)
+	if err != nil {
+		return nil, err
+	}
+
+	deploymentsThresholds, err := publishers.ParseIcinga2DeploymentsThresholds(splitFilter(*icinga2DeploymentThresholds))
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{Timeout: *icinga2Timeout}
+	if *icinga2CACertFile != "" {
+		caCert, err := ioutil.ReadFile(*icinga2CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading Icinga2 CA Certificate file: %v", err)
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("Error parsing Icinga2 CA Certificate file")
+		}
+		httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: rootCAs},
+		}
+	}
+
+	return publishers.NewIcinga2Publisher(
+		publishers.Icinga2Config{
+			APIURL:                *icinga2APIURL,
+			Username:              *icinga2Username,
+			Password:              *icinga2Password,
+			Host:                  *icinga2Host,
+			Thresholds:            thresholds,
+			DeploymentsThresholds: deploymentsThresholds,
+		},
+		httpClient,
+	), nil
+}
+
+func buildPublishers(boshEnvironmentsCount int, stop <-chan struct{}) ([]publishers.Publisher, error) {
+	snapshotPublishers := []publishers.Publisher{}
+
+	if *zabbixServer != "" {
+		if *zabbixHost != "" && boshEnvironmentsCount > 1 {
+			return nil, errors.New("A single Zabbix host is not supported with more than one BOSH Director")
+		}
+		snapshotPublishers = append(snapshotPublishers, publishers.NewZabbixPublisher(*zabbixServer, *zabbixHost, *zabbixTimeout))
+	}
+
+	if *icinga2APIURL != "" {
+		if *icinga2Host != "" && boshEnvironmentsCount > 1 {
+			return nil, errors.New("A single Icinga2 host is not supported with more than one BOSH Director")
+		}
+		icinga2Publisher, err := buildIcinga2Publisher()
+		if err != nil {
+			return nil, err
+		}
+		asyncIcinga2Publisher := publishers.NewAsyncPublisher(icinga2Publisher)
+		go asyncIcinga2Publisher.Run(stop)
+		snapshotPublishers = append(snapshotPublishers, asyncIcinga2Publisher)
+	}
+
+	return snapshotPublishers, nil
+}
+
+func buildPushers() ([]pushers.Pusher, error) {
+	metricsPushers := []pushers.Pusher{}
+
+	if *pushGraphiteAddress != "" {
+		graphitePusher, err := pushers.NewGraphitePusher(pushers.GraphiteProtocol, *pushGraphiteAddress, *pushPrefix, *pushTimeout)
+		if err != nil {
+			return nil, err
+		}
+		metricsPushers = append(metricsPushers, graphitePusher)
+	}
+
+	if *pushStatsdAddress != "" {
+		statsdPusher, err := pushers.NewGraphitePusher(pushers.StatsdProtocol, *pushStatsdAddress, *pushPrefix, *pushTimeout)
+		if err != nil {
+			return nil, err
+		}
+		metricsPushers = append(metricsPushers, statsdPusher)
+	}
+
+	if *pushInfluxURL != "" {
+		influxDBPusher, err := pushers.NewInfluxDBPusher(
+			pushers.InfluxDBConfig{
+				URL:          *pushInfluxURL,
+				Database:     *pushInfluxDatabase,
+				Username:     *pushInfluxUsername,
+				Password:     *pushInfluxPassword,
+				Organization: *pushInfluxOrg,
+				Bucket:       *pushInfluxBucket,
+				Token:        *pushInfluxToken,
+			},
+			&http.Client{Timeout: *pushTimeout},
+		)
+		if err != nil {
+			return nil, err
+		}
+		metricsPushers = append(metricsPushers, influxDBPusher)
+	}
+
+	return metricsPushers, nil
+}
+
+func replayEnvironments(snapshots []fetcher.EnvironmentSnapshot) []environments.Environment {
+	boshEnvironments := []environments.Environment{}
+	for _, snapshot := range snapshots {
+		boshEnvironments = append(boshEnvironments, environments.Environment{
+			Environment: snapshot.Environment,
+			Filters:     flagsFilters(),
+		})
+	}
+
+	return boshEnvironments
+}
+
+func buildHTTPInstrumenter() (*instrumentation.HTTPInstrumenter, error) {
+	switch *webAccessLogFile {
+	case "":
+		return instrumentation.NewHTTPInstrumenter(*metricsNamespace, nil), nil
+	case "-":
+		return instrumentation.NewHTTPInstrumenter(*metricsNamespace, os.Stdout), nil
+	}
+
+	accessLog, err := os.OpenFile(*webAccessLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error opening access log file `%s`: %v", *webAccessLogFile, err))
+	}
+
+	return instrumentation.NewHTTPInstrumenter(*metricsNamespace, accessLog), nil
+}
+
+func buildLabelSanitizer() (*sanitizers.LabelSanitizer, error) {
+	if *labelsSanitizeConfigFile == "" {
+		return nil, nil
+	}
+
+	config, err := sanitizers.LoadConfig(*labelsSanitizeConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return sanitizers.NewLabelSanitizer(*config)
+}
+
+func synthSnapshots() []fetcher.EnvironmentSnapshot {
+	now := time.Now()
+	snapshots := []fetcher.EnvironmentSnapshot{}
+	for i := 0; i < *synthEnvironments; i++ {
+		environment := fmt.Sprintf("synthetic-%d", i)
+		snapshots = append(snapshots, fetcher.EnvironmentSnapshot{
+			Environment: environment,
+			Snapshot: fetcher.SyntheticSnapshot(fetcher.SyntheticOptions{
+				Environment: environment,
+				Deployments: *synthDeployments,
+				Instances:   *synthInstances,
+				Processes:   *synthProcesses,
+				Seed:        *synthSeed + int64(i),
+			}, now),
+		})
+	}
+
+	return snapshots
+}
+
+func buildServiceDiscoverySigningKey() ([]byte, error) {
+	if *sdSigningKeyFile == "" {
+		return nil, nil
+	}
+
+	signingKey, err := ioutil.ReadFile(*sdSigningKeyFile)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error reading Service Discovery signing key: %v", err))
+	}
+
+	return []byte(strings.TrimSpace(string(signingKey))), nil
+}
+
+// fetchSnapshots reads every BOSH Director once.
+func fetchSnapshots() ([]fetcher.EnvironmentSnapshot, error) {
+	boshEnvironments, err := loadEnvironments()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsPolicy, err := buildTLSPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	revocationChecker, err := buildRevocationChecker()
+	if err != nil {
+		return nil, err
+	}
+	certificatePinner, err := buildCertificatePinner()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []fetcher.EnvironmentSnapshot{}
+	for _, environment := range boshEnvironments {
+		boshClient, _, directorSession, err := buildBOSHClient(environment, tlsPolicy, revocationChecker, certificatePinner, fetcher.NewHTTPDebugger(*boshDebugHTTP, *boshDebugHTTPMaxBodyBytes), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating BOSH Client for `%s`: %v", environment.URL, err)
+		}
+
+		boshFetcher, _, _, err := buildBoshFetcher(environment, boshClient, directorSession, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot, err := boshFetcher.Fetch(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("Error reading BOSH Director `%s`: %v", environment.URL, err)
+		}
+		log.Infof("Read %d deployments from BOSH Director `%s` (%s)", len(snapshot.Deployments), snapshot.Director.Name, snapshot.Director.UUID)
+
+		snapshots = append(snapshots, fetcher.EnvironmentSnapshot{Environment: environment.Environment, Snapshot: snapshot})
+	}
+
+	return snapshots, nil
+}
+
+func writeSnapshot(filename string) error {
+	snapshots, err := fetchSnapshots()
+	if err != nil {
+		return err
+	}
+
+	if err := fetcher.WriteSnapshotFile(filename, snapshots); err != nil {
+		return err
+	}
+	log.Infof("Snapshot written to `%s`", filename)
+
+	return nil
+}
+
+// printSnapshot reads every BOSH Director once and prints the data in the
+// snapshot file format, so it can be saved and replayed with --replay.
+func printSnapshot() error {
+	snapshots, err := fetchSnapshots()
+	if err != nil {
+		return err
+	}
+
+	return fetcher.WriteSnapshot(os.Stdout, snapshots)
+}
+
+// writeServiceDiscovery writes the Service Discovery output of every BOSH
+// Director to its file, once or on every interval, with the same flags and
+// environments as when serving. Uploads and publishers are disabled.
+func writeServiceDiscovery(interval time.Duration, once bool) error {
+	boshEnvironments, err := loadEnvironments()
+	if err != nil {
+		return err
+	}
+
+	tlsPolicy, err := buildTLSPolicy()
+	if err != nil {
+		return err
+	}
+
+	revocationChecker, err := buildRevocationChecker()
+	if err != nil {
+		return err
+	}
+	certificatePinner, err := buildCertificatePinner()
+	if err != nil {
+		return err
+	}
+
+	serviceDiscoverySigningKey, err := buildServiceDiscoverySigningKey()
+	if err != nil {
+		return err
+	}
+
+	labelSanitizer, err := buildLabelSanitizer()
+	if err != nil {
+		return err
+	}
+
+	var stop chan struct{}
+	if !once {
+		stop = make(chan struct{})
+		defer close(stop)
+	}
+
+	boshCollectors := []*collectors.BoshCollector{}
+	sdFilenames := []string{}
+	for _, environment := range boshEnvironments {
+		boshClient, _, directorSession, err := buildBOSHClient(environment, tlsPolicy, revocationChecker, certificatePinner, fetcher.NewHTTPDebugger(*boshDebugHTTP, *boshDebugHTTPMaxBodyBytes), nil, stop)
+		if err != nil {
+			return fmt.Errorf("Error creating BOSH Client for `%s`: %v", environment.URL, err)
+		}
+
+		boshInfo, err := boshClient.Info()
+		if err != nil {
+			return fmt.Errorf("Error reading BOSH Info for `%s`: %v", environment.URL, err)
+		}
+
+		if environment.SDFilename == "" {
+			sdDir, sdBase := path.Split(*sdFilename)
+			environment.SDFilename = path.Join(sdDir, boshInfo.Name+"_"+sdBase)
+		}
+		environment.Filters.Collectors = []string{filters.ServiceDiscoveryCollector}
+
+		boshCollector, _, _, err := buildBoshCollector(environment, boshInfo, boshClient, directorSession, nil, nil, serviceDiscoverySigningKey, nil, nil, nil, labelSanitizer)
+		if err != nil {
+			return err
+		}
+		if *sdProbePorts {
+			boshCollector.SetServiceDiscoveryPortProber(collectors.NewPortProber(*sdProbeTimeout, *sdProbeConcurrency))
+		}
+		boshCollectors = append(boshCollectors, boshCollector)
+		sdFilenames = append(sdFilenames, environment.SDFilename)
+	}
+
+	if err := environments.ValidateSDFilenames(sdFilenames); err != nil {
+		return err
+	}
+
+	refresher := &boshRefresher{boshCollectors: boshCollectors, mu: &sync.Mutex{}}
+	if once {
+		if err := refresher.Refresh(); err != nil {
+			return err
+		}
+		log.Infof("Service Discovery output written to %s", strings.Join(sdFilenames, ", "))
+		return nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := refresher.Refresh(); err != nil {
+			log.Error(err)
+		}
+		<-ticker.C
+	}
+}
+
+func buildScrapeProfiles() ([]*profiles.Profile, error) {
+	if *webProfilesConfigFile == "" {
+		return nil, nil
+	}
+
+	config, err := profiles.LoadConfig(*webProfilesConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return profiles.NewProfiles(*config)
+}
+
+func migrateRules(filenames []string, write bool) error {
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error reading rule file `%s`: %v", filename, err))
+		}
+
+		migrated, changes, err := exposition.MigrateRules(data, *metricsNamespace, *metricsCompatVersion)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			log.Infof("Renamed `%s` in rule file `%s`", change, filename)
+		}
+
+		if !write {
+			fmt.Print(string(migrated))
+			continue
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if err := ioutil.WriteFile(filename, migrated, 0644); err != nil {
+			return errors.New(fmt.Sprintf("Error writing rule file `%s`: %v", filename, err))
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	log.AddFlags(kingpin.CommandLine)
+	kingpin.Version(version.Print("bosh_exporter"))
+	kingpin.HelpFlag.Short('h')
+	command := kingpin.Parse()
+
+	if command == versionCommand.FullCommand() {
+		fmt.Println(version.Print("bosh_exporter"))
+		return
+	}
+
+	if command == fetchCommand.FullCommand() {
+		if err := printSnapshot(); err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == sdCommand.FullCommand() {
+		if err := writeServiceDiscovery(*sdWriteInterval, *sdWriteOnce); err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == snapshotCommand.FullCommand() {
+		if err := writeSnapshot(*snapshotOutput); err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == migrateRulesCommand.FullCommand() {
+		if err := migrateRules(*migrateRulesFiles, *migrateRulesWrite); err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == synthCommand.FullCommand() && *synthOutput != "" {
+		if err := fetcher.WriteSnapshotFile(*synthOutput, synthSnapshots()); err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	log.Infoln("Starting bosh_exporter", version.Info())
 	log.Infoln("Build context", version.BuildContext())
 
-	boshClient, err := buildBOSHClient()
+	gates, err := features.NewGates(features.Known, *enableFeatures)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	featureGates = gates
+	for _, feature := range featureGates.EnabledFeatures() {
+		log.Infof("Enabled experimental feature `%s`", feature)
+	}
+
+	if *webEnableAdminAPI && (*authUsername == "" || *authPassword == "") {
+		log.Error("The admin API requires web.auth.username and web.auth.password")
+		os.Exit(1)
+	}
+	if *webEnableLifecycle && (*authUsername == "" || *authPassword == "") {
+		log.Error("The lifecycle endpoints require web.auth.username and web.auth.password")
+		os.Exit(1)
+	}
+
+	tlsPolicy, err := buildTLSPolicy()
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	if err := validateInternalTelemetry(); err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: *metricsNamespace,
+			Subsystem: "exporter",
+			Name:      "tls_policy_info",
+			Help:      "Active TLS policy of the connections to the BOSH Director and of the web server, with a constant '1' value.",
+			ConstLabels: prometheus.Labels{
+				"policy":          tlsPolicy.Name,
+				"min_tls_version": tlsPolicy.MinVersionName(),
+				"fips_build":      strconv.FormatBool(tlspolicy.FIPSBuild),
+			},
+		},
+		func() float64 { return 1 },
+	))
+
+	proxyInfo := proxies.NewInfo(*metricsNamespace)
+	proxyInfo.Set("bosh", boshProxyConfig())
+	if *sdKubernetesConfigMap != "" || *kubernetesEvents {
+		proxyInfo.Set("kubernetes", kubernetesProxyConfig())
+	}
+	prometheus.MustRegister(proxyInfo)
+	prometheus.MustRegister(features.NewInfo(*metricsNamespace, featureGates))
+
+	shutdown := make(chan struct{})
+
+	var boshEnvironments []environments.Environment
+	var replaySnapshots []fetcher.EnvironmentSnapshot
+	if *replayFile != "" {
+		replaySnapshots, err = fetcher.ReadSnapshotFile(*replayFile)
+		boshEnvironments = replayEnvironments(replaySnapshots)
+	} else if command == synthCommand.FullCommand() {
+		replaySnapshots = synthSnapshots()
+		boshEnvironments = replayEnvironments(replaySnapshots)
+	} else {
+		boshEnvironments, err = loadEnvironments()
+	}
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	if replaySnapshots != nil && *replayFile != "" {
+		log.Infof("Replaying snapshot file `%s`, Service Discovery uploads and snapshot publishers are disabled", *replayFile)
+	} else if replaySnapshots != nil {
+		log.Infof("Serving %d synthetic BOSH Directors with %d deployments of %d instances of %d processes each, Service Discovery uploads and snapshot publishers are disabled", *synthEnvironments, *synthDeployments, *synthInstances, *synthProcesses)
+	}
+
+	serviceDiscoverySinks := []sinks.Sink{}
+	if *sdS3Bucket != "" && replaySnapshots == nil {
+		s3Sink := sinks.NewAsyncSink(sinks.NewS3Sink(
+			sinks.S3Config{
+				Endpoint:        *sdS3Endpoint,
+				Region:          *sdS3Region,
+				Bucket:          *sdS3Bucket,
+				Key:             *sdS3Key,
+				AccessKeyID:     *sdS3AccessKeyID,
+				SecretAccessKey: *sdS3SecretAccessKey,
+				SessionToken:    *sdS3SessionToken,
+				Versioned:       *sdS3Versioned,
+			},
+			&http.Client{Timeout: *sdUploadTimeout},
+		))
+		go s3Sink.Run(shutdown)
+		serviceDiscoverySinks = append(serviceDiscoverySinks, s3Sink)
+	}
+	if *sdAzureBlobURL != "" && replaySnapshots == nil {
+		azureBlobSink := sinks.NewAsyncSink(sinks.NewAzureBlobSink(*sdAzureBlobURL, &http.Client{Timeout: *sdUploadTimeout}))
+		go azureBlobSink.Run(shutdown)
+		serviceDiscoverySinks = append(serviceDiscoverySinks, azureBlobSink)
+	}
+	if *sdKubernetesConfigMap != "" && replaySnapshots == nil {
+		var namespace, name string
+		if parts := strings.SplitN(*sdKubernetesConfigMap, "/", 2); len(parts) == 2 {
+			namespace, name = parts[0], parts[1]
+		} else {
+			name = parts[0]
+		}
+		kubernetesNamespaces := splitFilter(*sdKubernetesNamespaces)
+		if len(kubernetesNamespaces) > 0 && namespace != "" {
+			log.Error("Flag `sd.kubernetes.configmap` must be a ConfigMap name when `sd.kubernetes.namespaces` is set")
+			os.Exit(1)
+		}
+		kubernetesConfig, kubernetesClient, err := inClusterKubernetesConfig(namespace, name, *sdKubernetesKey)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		kubernetesConfigMapSinks := []*sinks.KubernetesConfigMapSink{}
+		if len(kubernetesNamespaces) > 0 {
+			kubernetesNamespacesSink := sinks.NewKubernetesNamespacesSink(kubernetesConfig, kubernetesNamespaces, kubernetesClient)
+			serviceDiscoverySinks = append(serviceDiscoverySinks, kubernetesNamespacesSink)
+			kubernetesConfigMapSinks = kubernetesNamespacesSink.Sinks()
+		} else {
+			kubernetesConfigMapSink := sinks.NewKubernetesConfigMapSink(kubernetesConfig, kubernetesClient)
+			serviceDiscoverySinks = append(serviceDiscoverySinks, kubernetesConfigMapSink)
+			kubernetesConfigMapSinks = append(kubernetesConfigMapSinks, kubernetesConfigMapSink)
+		}
+
+		for _, kubernetesConfigMapSink := range kubernetesConfigMapSinks {
+			kubernetesConfigMapSink := kubernetesConfigMapSink
+			prometheus.MustRegister(prometheus.NewCounterFunc(
+				prometheus.CounterOpts{
+					Namespace:   *metricsNamespace,
+					Subsystem:   "exporter",
+					Name:        "sd_configmap_write_errors_total",
+					Help:        "Total number of errors writing the Service Discovery Kubernetes ConfigMap.",
+					ConstLabels: prometheus.Labels{"namespace": kubernetesConfigMapSink.Namespace()},
+				},
+				func() float64 { return float64(kubernetesConfigMapSink.WriteErrors()) },
+			))
+		}
+
+		if *sdKubernetesWatch {
+			prometheus.MustRegister(prometheus.NewCounterFunc(
+				prometheus.CounterOpts{
+					Namespace: *metricsNamespace,
+					Subsystem: "exporter",
+					Name:      "sd_configmap_tampered_total",
+					Help:      "Total number of times the Service Discovery Kubernetes ConfigMap was modified or deleted outside of the exporter and restored.",
+				},
+				func() float64 {
+					var tampered uint64
+					for _, kubernetesConfigMapSink := range kubernetesConfigMapSinks {
+						tampered += kubernetesConfigMapSink.Tampered()
+					}
+					return float64(tampered)
+				},
+			))
+			for _, kubernetesConfigMapSink := range kubernetesConfigMapSinks {
+				go kubernetesConfigMapSink.Watch(shutdown)
+			}
+		}
+	}
+	if len(serviceDiscoverySinks) > 0 && len(boshEnvironments) > 1 {
+		log.Error("Service Discovery uploads are not supported with more than one BOSH Director")
+		os.Exit(1)
+	}
+
+	snapshotPublishers := []publishers.Publisher{}
+	if replaySnapshots == nil {
+		snapshotPublishers, err = buildPublishers(len(boshEnvironments), shutdown)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+	}
+
+	serviceDiscoverySigningKey, err := buildServiceDiscoverySigningKey()
 	if err != nil {
-		log.Errorf("Error creating BOSH Client: %s", err.Error())
+		log.Error(err)
 		os.Exit(1)
 	}
 
-	boshInfo, err := boshClient.Info()
+	revocationChecker, err := buildRevocationChecker()
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	certificatePinner, err := buildCertificatePinner()
 	if err != nil {
-		log.Errorf("Error reading BOSH Info: %s", err.Error())
+		log.Error(err)
 		os.Exit(1)
 	}
-	log.Infof("Using BOSH Director `%s` (%s)", boshInfo.Name, boshInfo.UUID)
+	if certificatePinner != nil {
+		prometheus.MustRegister(prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Namespace: *metricsNamespace,
+				Subsystem: "exporter",
+				Name:      "director_tls_pin_failures_total",
+				Help:      "Total number of BOSH Director TLS connections refused because the certificates match no certificate pin.",
+			},
+			func() float64 { return float64(certificatePinner.Failures()) },
+		))
+	}
+	httpDebugger := fetcher.NewHTTPDebugger(*boshDebugHTTP, *boshDebugHTTPMaxBodyBytes)
+	if revocationChecker != nil {
+		for _, reason := range fetcher.RevocationFailureReasons {
+			reason := reason
+			prometheus.MustRegister(prometheus.NewCounterFunc(
+				prometheus.CounterOpts{
+					Namespace:   *metricsNamespace,
+					Subsystem:   "exporter",
+					Name:        "director_tls_revocation_failures_total",
+					Help:        "Total number of BOSH Director TLS connections refused because the certificate is revoked or its revocation status could not be established.",
+					ConstLabels: prometheus.Labels{"reason": reason},
+				},
+				func() float64 { return float64(revocationChecker.Failures(reason)) },
+			))
+		}
+		if *boshCACertReloadInterval > 0 && len(*boshCRLFiles) > 0 {
+			go revocationChecker.Watch(*boshCACertReloadInterval, shutdown)
+		}
+	}
+
+	var traceExporter *tracing.OTLPExporter
+	if *tracingOTLPEndpoint != "" {
+		traceHeaders, err := tracing.ParseHeaders(splitFilter(*tracingOTLPHeaders))
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		traceExporter = tracing.NewOTLPExporter(*tracingOTLPEndpoint, traceHeaders, 10*time.Second)
+	}
 
-	var deploymentsFilters []string
-	if *filterDeployments != "" {
-		deploymentsFilters = strings.Split(*filterDeployments, ",")
+	labelSanitizer, err := buildLabelSanitizer()
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
 	}
-	deploymentsFilter := filters.NewDeploymentsFilter(deploymentsFilters, boshClient)
-	deploymentsFetcher := deployments.NewFetcher(*deploymentsFilter)
 
-	var azsFilters []string
-	if *filterAZs != "" {
-		azsFilters = strings.Split(*filterAZs, ",")
+	var eventRecorder collectors.EventRecorder
+	if *kubernetesEvents && replaySnapshots == nil {
+		kubernetesEventRecorder, err := buildKubernetesEventRecorder()
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		eventRecorder = kubernetesEventRecorder
 	}
-	azsFilter := filters.NewAZsFilter(azsFilters)
 
-	var collectorsFilters []string
-	if *filterCollectors != "" {
-		collectorsFilters = strings.Split(*filterCollectors, ",")
+	var auditShipper audit.Shipper
+	if *auditEndpoint != "" {
+		auditShipper, err = audit.NewShipper(*auditEndpoint, &http.Client{Timeout: 30 * time.Second})
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
 	}
-	collectorsFilter, err := filters.NewCollectorsFilter(collectorsFilters)
+
+	listener, err := listen()
 	if err != nil {
+		log.Errorf("Error listening on `%s`: %v", *listenAddress, err)
+		os.Exit(1)
+	}
+
+	var startup *startupHandler
+	serveErrors := make(chan error, 1)
+	taskWatchdogs := &sync.WaitGroup{}
+	if *boshStartupRetry && replaySnapshots == nil {
+		startup = newStartupHandler(*metricsNamespace, *metricsPath, boshEnvironments)
+		go func() {
+			serveErrors <- serve(listener, startup, tlsPolicy)
+		}()
+	}
+
+	boshCollectors := []*collectors.BoshCollector{}
+	deploymentProblemsScanners := []deploymentProblemsScanner{}
+	serviceDiscoveryPreviewers := []serviceDiscoveryPreviewer{}
+	tlsCertificatesCollectors := []*collectors.TLSCertificatesCollector{}
+	backupsCollectors := []*collectors.BackupsCollector{}
+	deploysCollectors := []*collectors.DeploysCollector{}
+	blobstoreCollectors := []*collectors.BlobstoreCollector{}
+	directorSessionCollectors := []*collectors.DirectorSessionCollector{}
+	boshFilters := []*environmentFilters{}
+	labelSets := []environments.LabelSet{}
+	sdFilenames := []string{}
+	filtersConfig := map[string][]string{}
+	for i, environment := range boshEnvironments {
+		var boshClient director.Director
+		var boshInfo director.Info
+		var directorURL environments.DirectorURL
+		var directorSession *fetcher.DirectorSession
+		var replaySnapshot *fetcher.Snapshot
+		var tracer *tracing.Tracer
+		if traceExporter != nil {
+			tracer = tracing.NewTracer(traceExporter, map[string]string{
+				"service.name":     *tracingServiceName,
+				"bosh.environment": environment.Environment,
+				"bosh.url":         environment.URL,
+			})
+			prometheus.MustRegister(prometheus.NewCounterFunc(
+				prometheus.CounterOpts{
+					Namespace:   *metricsNamespace,
+					Subsystem:   "exporter",
+					Name:        "tracing_dropped_spans_total",
+					Help:        "Total number of spans dropped because the OTLP endpoint could not keep up.",
+					ConstLabels: prometheus.Labels{"environment": environment.Environment},
+				},
+				func() float64 { return float64(tracer.DroppedSpans()) },
+			))
+			go tracer.Run(5*time.Second, shutdown)
+		}
+		if replaySnapshots != nil {
+			replaySnapshot = &replaySnapshots[i].Snapshot
+			boshInfo = director.Info{
+				Name:    replaySnapshot.Director.Name,
+				UUID:    replaySnapshot.Director.UUID,
+				Version: replaySnapshot.Director.Version,
+			}
+		} else {
+			connect := func() error {
+				var err error
+				boshClient, directorURL, directorSession, err = buildBOSHClient(environment, tlsPolicy, revocationChecker, certificatePinner, httpDebugger, tracer, shutdown)
+				if err != nil {
+					return errors.New(fmt.Sprintf("Error creating BOSH Client for `%s`: %s", environment.URL, err.Error()))
+				}
+
+				boshInfo, err = boshClient.Info()
+				if err != nil {
+					return errors.New(fmt.Sprintf("Error reading BOSH Info for `%s`: %s", environment.URL, err.Error()))
+				}
+				return nil
+			}
+
+			if startup != nil {
+				if err := startup.connect(environment.Environment, connect, fetcher.NewBackoff(5*time.Second, *boshStartupRetryMaxInterval), shutdown); err != nil {
+					log.Error(err)
+					os.Exit(1)
+				}
+			} else if err := connect(); err != nil {
+				log.Error(err)
+				os.Exit(1)
+			}
+		}
+		log.Infof("Using BOSH Director `%s` (%s)", boshInfo.Name, boshInfo.UUID)
+
+		if environment.SDFilename == "" {
+			sdDir, sdBase := path.Split(*sdFilename)
+			environment.SDFilename = path.Join(sdDir, boshInfo.Name+"_"+sdBase)
+		}
+
+		boshCollector, debugFilters, environmentFiltersConfig, err := buildBoshCollector(
+			environment,
+			boshInfo,
+			boshClient,
+			directorSession,
+			replaySnapshot,
+			serviceDiscoverySinks,
+			serviceDiscoverySigningKey,
+			snapshotPublishers,
+			tracer,
+			eventRecorder,
+			labelSanitizer,
+		)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		if *sdProbePorts {
+			portProber := collectors.NewBackgroundPortProber(*sdProbeTimeout, *sdProbeConcurrency)
+			boshCollector.SetServiceDiscoveryPortProber(portProber)
+			go portProber.Run(*sdProbeInterval, shutdown)
+		}
+
+		boshCollectors = append(boshCollectors, boshCollector)
+		deploymentProblemsScanners = append(deploymentProblemsScanners, deploymentProblemsScanner{environment: environment.Environment, boshCollector: boshCollector})
+		serviceDiscoveryPreviewers = append(serviceDiscoveryPreviewers, serviceDiscoveryPreviewer{environment: environment.Environment, boshCollector: boshCollector})
+		if directorSession != nil {
+			directorSessionCollectors = append(directorSessionCollectors, collectors.NewDirectorSessionCollector(
+				*metricsNamespace,
+				environment.Environment,
+				boshInfo.Name,
+				boshInfo.UUID,
+				directorSession,
+			))
+		}
+		if replaySnapshot == nil && *boshTaskWatchdogDeadline > 0 {
+			taskWatchdog := buildTaskWatchdog(environment, boshInfo, boshClient, directorSession)
+			prometheus.MustRegister(taskWatchdog)
+			taskWatchdogs.Add(1)
+			go func() {
+				defer taskWatchdogs.Done()
+				taskWatchdog.Run(*boshTaskWatchdogInterval, shutdown)
+			}()
+		}
+		if replaySnapshot == nil && *boshTLSCertificatesCheckInterval > 0 {
+			tlsCertificatesCollectors = append(tlsCertificatesCollectors, collectors.NewTLSCertificatesCollector(
+				*metricsNamespace,
+				environment.Environment,
+				boshInfo.Name,
+				boshInfo.UUID,
+				tlsEndpoints(directorURL, boshInfo),
+				10*time.Second,
+				*boshTLSCertificatesCheckInterval,
+			))
+		}
+		if replaySnapshot == nil && auditShipper != nil {
+			auditForwarder := audit.NewForwarder(
+				*metricsNamespace,
+				audit.Source{
+					Environment: environment.Environment,
+					BoshName:    boshInfo.Name,
+					BoshUUID:    boshInfo.UUID,
+					BoshVersion: boshInfo.Version,
+				},
+				boshClient,
+				*auditFormat,
+				auditShipper,
+				*auditPollInterval,
+			)
+			prometheus.MustRegister(auditForwarder)
+			go auditForwarder.Run(shutdown)
+		}
+		if replaySnapshot == nil && *boshBackupsCheckInterval > 0 {
+			backupsCollectors = append(backupsCollectors, collectors.NewBackupsCollector(
+				*metricsNamespace,
+				environment.Environment,
+				boshInfo.Name,
+				boshInfo.UUID,
+				boshClient,
+				*boshBackupsSSHUserPrefix,
+				*boshBackupsWindow,
+				*boshBackupsCheckInterval,
+			))
+		}
+		if replaySnapshot == nil && *boshDeploysCheckInterval > 0 {
+			deploysCollectors = append(deploysCollectors, collectors.NewDeploysCollector(
+				*metricsNamespace,
+				environment.Environment,
+				boshInfo.Name,
+				boshInfo.UUID,
+				boshClient,
+				*boshDeploysCheckInterval,
+			))
+		}
+		if replaySnapshot == nil && *boshBlobstoreCheckInterval > 0 {
+			blobstoreCollectors = append(blobstoreCollectors, collectors.NewBlobstoreCollector(
+				*metricsNamespace,
+				environment.Environment,
+				boshInfo.Name,
+				boshInfo.UUID,
+				boshClient,
+				*boshBlobstoreCheckInterval,
+			))
+		}
+		boshFilters = append(boshFilters, debugFilters)
+		labelSets = append(labelSets, environments.LabelSet{
+			Environment: environment.Environment,
+			BoshName:    boshInfo.Name,
+			BoshUUID:    boshInfo.UUID,
+		})
+		sdFilenames = append(sdFilenames, environment.SDFilename)
+		for filterName, filterValues := range environmentFiltersConfig {
+			if len(boshEnvironments) > 1 {
+				filterName = boshInfo.Name + "." + filterName
+			}
+			filtersConfig[filterName] = filterValues
+		}
+	}
+
+	if err := environments.ValidateLabelSets(labelSets); err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+
+	if err := environments.ValidateSDFilenames(sdFilenames); err != nil {
 		log.Error(err)
 		os.Exit(1)
 	}
 
-	var cidrFilters []string
-	if *filterCIDRs != "" {
-		cidrFilters = strings.Split(*filterCIDRs, ",")
+	boshRegistry := prometheus.NewRegistry()
+	for _, boshCollector := range boshCollectors {
+		boshRegistry.MustRegister(boshCollector)
+	}
+	for _, tlsCertificatesCollector := range tlsCertificatesCollectors {
+		boshRegistry.MustRegister(tlsCertificatesCollector)
+	}
+	for _, backupsCollector := range backupsCollectors {
+		boshRegistry.MustRegister(backupsCollector)
+	}
+	for _, deploysCollector := range deploysCollectors {
+		boshRegistry.MustRegister(deploysCollector)
+	}
+	for _, blobstoreCollector := range blobstoreCollectors {
+		boshRegistry.MustRegister(blobstoreCollector)
+	}
+	for _, directorSessionCollector := range directorSessionCollectors {
+		boshRegistry.MustRegister(directorSessionCollector)
+	}
+	aliases, err := exposition.CompatAliases(*metricsNamespace, *metricsCompatVersion)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	if *metricsAliases != "" {
+		tableAliases, err := exposition.LoadAliases(*metricsAliases)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		aliases = append(aliases, tableAliases...)
 	}
-	cidrsFilter, err := filters.NewCidrFilter(cidrFilters)
+	boshGatherer := exposition.AliasGatherer(labelSanitizer.Gatherer(boshRegistry), *metricsNamespace, aliases)
+	scrapeProfiles, err := buildScrapeProfiles()
 	if err != nil {
 		log.Error(err)
 		os.Exit(1)
 	}
+	internalGatherer := labelSanitizer.Gatherer(prometheus.DefaultGatherer)
+	allGatherers := prometheus.Gatherers{internalGatherer, boshGatherer}
 
-	var processesFilters []string
-	if *sdProcessesRegexp != "" {
-		processesFilters = []string{*sdProcessesRegexp}
+	if *updateCheckEnabled {
+		updateChecker := updates.NewChecker(*metricsNamespace, *updateCheckURL, version.Version, 30*time.Second)
+		prometheus.MustRegister(updateChecker)
+		go updateChecker.Run(*updateCheckInterval, shutdown)
 	}
-	processesFilter, err := filters.NewRegexpFilter(processesFilters)
+
+	refresher := &boshRefresher{boshCollectors: boshCollectors, mu: &sync.Mutex{}}
+	if *refreshOnSIGUSR1 {
+		refreshOnSignal(refresher)
+	}
+
+	var reloader *configReloader
+	if replaySnapshots == nil {
+		reloader = newConfigReloader(*metricsNamespace, boshEnvironments, loadEnvironments)
+		prometheus.MustRegister(reloader)
+		reloadOnSignal(reloader)
+	}
+
+	metricsPushers, err := buildPushers()
 	if err != nil {
-		log.Errorf("Error processing Processes Regexp: %v", err)
+		log.Error(err)
 		os.Exit(1)
 	}
+	if len(metricsPushers) > 0 {
+		pushLoop := pushers.NewLoop(allGatherers, metricsPushers, *pushInterval, *metricsNamespace+"_")
+		go pushLoop.Run(shutdown)
+	}
 
-	boshCollector := collectors.NewBoshCollector(
-		*metricsNamespace,
-		*metricsEnvironment,
-		boshInfo.Name,
-		boshInfo.UUID,
-		*sdFilename,
-		deploymentsFetcher,
-		collectorsFilter,
-		azsFilter,
-		processesFilter,
-		cidrsFilter,
-	)
-	prometheus.MustRegister(boshCollector)
+	httpInstrumenter, err := buildHTTPInstrumenter()
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	prometheus.MustRegister(httpInstrumenter)
+	handle := func(mux *http.ServeMux, pattern string, handler http.Handler) {
+		mux.Handle(pattern, httpInstrumenter.Handler(pattern, handler))
+	}
 
-	http.Handle(*metricsPath, prometheusHandler())
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	if internalTelemetryPath() == "" {
+		handle(http.DefaultServeMux, *metricsPath, prometheusHandler("metrics", allGatherers))
+	} else {
+		handle(http.DefaultServeMux, *metricsPath, prometheusHandler("metrics", boshGatherer))
+		if *internalListenAddress == "" {
+			handle(http.DefaultServeMux, internalTelemetryPath(), prometheusHandler("internal", internalGatherer))
+		}
+	}
+	for _, profile := range scrapeProfiles {
+		profileGatherer := exposition.AliasGatherer(labelSanitizer.Gatherer(profile.Gatherer(boshRegistry)), *metricsNamespace, aliases)
+		handle(http.DefaultServeMux, *metricsPath+"/profiles/"+profile.Name, prometheusHandler("profile_"+profile.Name, profileGatherer))
+	}
+	handle(http.DefaultServeMux, "/api/v1/status/config", statusConfigHandler(filtersConfig))
+	handle(http.DefaultServeMux, "/api/v1/openapi.json", authHandler(api.OpenAPIHandler(version.Version)))
+	handle(http.DefaultServeMux, "/debug/filters", debugFiltersHandler(boshFilters))
+	if *webEnableLifecycle {
+		handle(http.DefaultServeMux, "/debug/sd-diff", debugServiceDiscoveryDiffHandler(serviceDiscoveryPreviewers))
+		handle(http.DefaultServeMux, "/-/refresh", refreshHandler(refresher))
+	}
+	if *webEnableLifecycle && reloader != nil {
+		handle(http.DefaultServeMux, "/-/reload", configReloadHandler(reloader))
+	}
+	if *webEnableAdminAPI {
+		handle(http.DefaultServeMux, "/api/v1/deployments/", deploymentScanHandler(deploymentProblemsScanners))
+		handle(http.DefaultServeMux, "/api/v1/debug/http", debugHTTPHandler(httpDebugger))
+	}
+	handle(http.DefaultServeMux, "/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
              <head><title>BOSH Exporter</title></head>
              <body>
@@ -356,13 +2835,48 @@ func main() {
              <p><a href='` + *metricsPath + `'>Metrics</a></p>
              </body>
              </html>`))
-	})
+	}))
 
-	if *tlsCertFile != "" && *tlsKeyFile != "" {
-		log.Infoln("Listening TLS on", *listenAddress)
-		log.Fatal(http.ListenAndServeTLS(*listenAddress, *tlsCertFile, *tlsKeyFile, nil))
+	if *internalListenAddress != "" {
+		internalListener, err := net.Listen("tcp", *internalListenAddress)
+		if err != nil {
+			log.Errorf("Error listening on `%s`: %v", *internalListenAddress, err)
+			os.Exit(1)
+		}
+		internalMux := http.NewServeMux()
+		handle(internalMux, internalTelemetryPath(), prometheusHandler("internal", internalGatherer))
+		go func() {
+			log.Fatal(serve(internalListener, internalMux, tlsPolicy))
+		}()
+	}
+
+	if startup != nil {
+		startup.ready(http.DefaultServeMux)
 	} else {
-		log.Infoln("Listening on", *listenAddress)
-		log.Fatal(http.ListenAndServe(*listenAddress, nil))
+		go func() {
+			serveErrors <- serve(listener, nil, tlsPolicy)
+		}()
+	}
+
+	select {
+	case err := <-serveErrors:
+		log.Fatal(err)
+	case sig := <-shutdownSignals():
+		log.Infof("Shutting down on %s", sig)
+		close(shutdown)
+		taskWatchdogs.Wait()
+	}
+}
+
+func serve(listener net.Listener, handler http.Handler, tlsPolicy tlspolicy.Policy) error {
+	server := &http.Server{Handler: handler}
+	if *tlsCertFile != "" && *tlsKeyFile != "" {
+		log.Infoln("Listening TLS on", listener.Addr())
+		server.TLSConfig = &tls.Config{}
+		tlsPolicy.Apply(server.TLSConfig)
+		return server.ServeTLS(listener, *tlsCertFile, *tlsKeyFile)
 	}
+
+	log.Infoln("Listening on", listener.Addr())
+	return server.Serve(listener)
 }
@@ -0,0 +1,52 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time to the collectors and sinks, so their timestamps and
+// refresh intervals can be tested with a FakeClock instead of sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Real is the Clock of the system.
+var Real Clock = realClock{}
+
+// FakeClock is a Clock only moving when set or advanced.
+type FakeClock struct {
+	now time.Time
+	mu  *sync.Mutex
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, mu: &sync.Mutex{}}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+}
+
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,42 @@
+package clock_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/clock"
+)
+
+var _ = Describe("Real", func() {
+	It("returns the system time", func() {
+		Expect(Real.Now()).To(BeTemporally("~", time.Now(), time.Second))
+	})
+})
+
+var _ = Describe("FakeClock", func() {
+	var (
+		now       time.Time
+		fakeClock *FakeClock
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)
+		fakeClock = NewFakeClock(now)
+	})
+
+	It("returns the time it was created with", func() {
+		Expect(fakeClock.Now()).To(Equal(now))
+	})
+
+	It("is advanced", func() {
+		fakeClock.Advance(time.Minute)
+		Expect(fakeClock.Now()).To(Equal(now.Add(time.Minute)))
+	})
+
+	It("is set", func() {
+		fakeClock.Set(now.Add(time.Hour))
+		Expect(fakeClock.Now()).To(Equal(now.Add(time.Hour)))
+	})
+})
@@ -0,0 +1,237 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+const backupEventsMaxPages = 10
+
+// BackupRun is a BBR run against a deployment, reconstructed from the SSH
+// sessions it opened through the BOSH Director.
+type BackupRun struct {
+	Deployment string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Succeeded  bool
+}
+
+// BackupsCollector reports the last BBR backup of every deployment. BBR opens
+// SSH sessions to the instances through the Director with a dedicated user,
+// so a run spans from the first `setup ssh` event of that user to its last
+// `cleanup ssh` event.
+type BackupsCollector struct {
+	boshClient      director.Director
+	sshUserPrefix   string
+	window          time.Duration
+	refreshInterval time.Duration
+	lastRefresh     time.Time
+	clock           clock.Clock
+	lastRuns        map[string]BackupRun
+
+	lastBackupTimestampMetric *prometheus.GaugeVec
+	lastBackupDurationMetric  *prometheus.GaugeVec
+	lastBackupSuccessMetric   *prometheus.GaugeVec
+	mu                        *sync.Mutex
+}
+
+func NewBackupsCollector(
+	namespace string,
+	environment string,
+	boshName string,
+	boshUUID string,
+	boshClient director.Director,
+	sshUserPrefix string,
+	window time.Duration,
+	refreshInterval time.Duration,
+) *BackupsCollector {
+	constLabels := prometheus.Labels{
+		"environment": environment,
+		"bosh_name":   boshName,
+		"bosh_uuid":   boshUUID,
+	}
+
+	lastBackupTimestampMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "director",
+			Name:        "last_backup_timestamp_seconds",
+			Help:        "Number of seconds since 1970 since the last BBR backup of a BOSH deployment finished.",
+			ConstLabels: constLabels,
+		},
+		[]string{"bosh_deployment"},
+	)
+
+	lastBackupDurationMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "director",
+			Name:        "last_backup_duration_seconds",
+			Help:        "Duration in seconds of the last BBR backup of a BOSH deployment.",
+			ConstLabels: constLabels,
+		},
+		[]string{"bosh_deployment"},
+	)
+
+	lastBackupSuccessMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "director",
+			Name:        "last_backup_success",
+			Help:        "Whether the SSH sessions of the last BBR backup of a BOSH deployment were set up and cleaned up without error (1 for success, 0 for failure).",
+			ConstLabels: constLabels,
+		},
+		[]string{"bosh_deployment"},
+	)
+
+	return &BackupsCollector{
+		boshClient:                boshClient,
+		sshUserPrefix:             sshUserPrefix,
+		window:                    window,
+		refreshInterval:           refreshInterval,
+		clock:                     clock.Real,
+		lastRuns:                  map[string]BackupRun{},
+		lastBackupTimestampMetric: lastBackupTimestampMetric,
+		lastBackupDurationMetric:  lastBackupDurationMetric,
+		lastBackupSuccessMetric:   lastBackupSuccessMetric,
+		mu:                        &sync.Mutex{},
+	}
+}
+
+// SetClock replaces the clock telling when to refresh and how far back the
+// window starts.
+func (c *BackupsCollector) SetClock(clk clock.Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clk
+}
+
+func (c *BackupsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lastRefresh.IsZero() || c.clock.Now().Sub(c.lastRefresh) >= c.refreshInterval {
+		if err := c.refresh(); err != nil {
+			log.Error(err)
+		}
+		c.lastRefresh = c.clock.Now()
+	}
+
+	c.lastBackupTimestampMetric.Collect(ch)
+	c.lastBackupDurationMetric.Collect(ch)
+	c.lastBackupSuccessMetric.Collect(ch)
+}
+
+func (c *BackupsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.lastBackupTimestampMetric.Describe(ch)
+	c.lastBackupDurationMetric.Describe(ch)
+	c.lastBackupSuccessMetric.Describe(ch)
+}
+
+// refresh reads the SSH events of the window and keeps the last finished run
+// of every deployment, runs older than the window are remembered.
+func (c *BackupsCollector) refresh() error {
+	after := c.clock.Now().Add(-c.window)
+
+	setupEvents, err := c.sshEvents("setup ssh", after)
+	if err != nil {
+		return err
+	}
+	cleanupEvents, err := c.sshEvents("cleanup ssh", after)
+	if err != nil {
+		return err
+	}
+
+	for _, run := range BackupRuns(append(setupEvents, cleanupEvents...), c.sshUserPrefix) {
+		if lastRun, ok := c.lastRuns[run.Deployment]; ok && !run.FinishedAt.After(lastRun.FinishedAt) {
+			continue
+		}
+		c.lastRuns[run.Deployment] = run
+	}
+
+	for deployment, run := range c.lastRuns {
+		c.lastBackupTimestampMetric.WithLabelValues(deployment).Set(float64(run.FinishedAt.Unix()))
+		c.lastBackupDurationMetric.WithLabelValues(deployment).Set(run.FinishedAt.Sub(run.StartedAt).Seconds())
+		if run.Succeeded {
+			c.lastBackupSuccessMetric.WithLabelValues(deployment).Set(float64(1))
+		} else {
+			c.lastBackupSuccessMetric.WithLabelValues(deployment).Set(float64(0))
+		}
+	}
+
+	return nil
+}
+
+func (c *BackupsCollector) sshEvents(action string, after time.Time) ([]director.Event, error) {
+	filter := director.EventsFilter{
+		Action: action,
+		After:  after.UTC().Format(time.RFC3339),
+	}
+
+	events, err := fetcher.ReadEvents(c.boshClient, filter, backupEventsMaxPages)
+	if err != nil {
+		return events, errors.New(fmt.Sprintf("Error while reading `%s` events: %v", action, err))
+	}
+
+	return events, nil
+}
+
+// BackupRuns groups the SSH events of the users starting with sshUserPrefix
+// by deployment and user, ignoring the runs not cleaned up yet.
+func BackupRuns(events []director.Event, sshUserPrefix string) []BackupRun {
+	runs := map[string]*BackupRun{}
+	cleanedUp := map[string]bool{}
+	keys := []string{}
+
+	for _, event := range events {
+		sshUser, _ := event.Context()["user"].(string)
+		sshUser = strings.TrimPrefix(sshUser, "^")
+		if event.DeploymentName() == "" || !strings.HasPrefix(sshUser, sshUserPrefix) {
+			continue
+		}
+
+		key := event.DeploymentName() + "/" + sshUser
+		run, ok := runs[key]
+		if !ok {
+			run = &BackupRun{Deployment: event.DeploymentName(), Succeeded: true}
+			runs[key] = run
+			keys = append(keys, key)
+		}
+
+		switch event.Action() {
+		case "setup ssh":
+			if run.StartedAt.IsZero() || event.Timestamp().Before(run.StartedAt) {
+				run.StartedAt = event.Timestamp()
+			}
+		case "cleanup ssh":
+			cleanedUp[key] = true
+			if event.Timestamp().After(run.FinishedAt) {
+				run.FinishedAt = event.Timestamp()
+			}
+		}
+		if event.Error() != "" {
+			run.Succeeded = false
+		}
+	}
+
+	backupRuns := []BackupRun{}
+	for _, key := range keys {
+		run := runs[key]
+		if !cleanedUp[key] || run.StartedAt.IsZero() {
+			continue
+		}
+		backupRuns = append(backupRuns, *run)
+	}
+
+	return backupRuns
+}
@@ -0,0 +1,251 @@
+package collectors_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/director/directorfakes"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+func sshEvent(action string, deployment string, sshUser string, timestamp time.Time, eventError string) director.Event {
+	event := &directorfakes.FakeEvent{}
+	event.ActionReturns(action)
+	event.DeploymentNameReturns(deployment)
+	event.ContextReturns(map[string]interface{}{"user": sshUser})
+	event.TimestampReturns(timestamp)
+	event.ErrorReturns(eventError)
+	return event
+}
+
+var _ = Describe("BackupsCollector", func() {
+	var (
+		namespace     string
+		environment   string
+		boshName      string
+		boshUUID      string
+		boshClient    *directorfakes.FakeDirector
+		setupEvents   []director.Event
+		cleanupEvents []director.Event
+		startedAt     time.Time
+		fakeClock     *clock.FakeClock
+
+		backupsCollector *BackupsCollector
+
+		lastBackupTimestampMetric *prometheus.GaugeVec
+		lastBackupDurationMetric  *prometheus.GaugeVec
+		lastBackupSuccessMetric   *prometheus.GaugeVec
+	)
+
+	BeforeEach(func() {
+		namespace = "test_exporter"
+		environment = "test_environment"
+		boshName = "test_bosh_name"
+		boshUUID = "test_bosh_uuid"
+		startedAt = time.Now().Add(-time.Hour).Truncate(time.Second)
+		fakeClock = clock.NewFakeClock(time.Now())
+
+		setupEvents = []director.Event{
+			sshEvent("setup ssh", "fake-deployment", "bbr-1234", startedAt, ""),
+			sshEvent("setup ssh", "fake-deployment", "bbr-1234", startedAt.Add(time.Minute), ""),
+			sshEvent("setup ssh", "fake-deployment", "operator", startedAt, ""),
+		}
+		cleanupEvents = []director.Event{
+			sshEvent("cleanup ssh", "fake-deployment", "^bbr-1234", startedAt.Add(10*time.Minute), ""),
+			sshEvent("cleanup ssh", "fake-deployment", "^operator", startedAt.Add(20*time.Minute), ""),
+		}
+
+		boshClient = &directorfakes.FakeDirector{}
+		boshClient.EventsStub = func(filter director.EventsFilter) ([]director.Event, error) {
+			if filter.Action == "setup ssh" {
+				return setupEvents, nil
+			}
+			return cleanupEvents, nil
+		}
+
+		constLabels := prometheus.Labels{
+			"environment": environment,
+			"bosh_name":   boshName,
+			"bosh_uuid":   boshUUID,
+		}
+
+		lastBackupTimestampMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "director",
+				Name:        "last_backup_timestamp_seconds",
+				Help:        "Number of seconds since 1970 since the last BBR backup of a BOSH deployment finished.",
+				ConstLabels: constLabels,
+			},
+			[]string{"bosh_deployment"},
+		)
+
+		lastBackupDurationMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "director",
+				Name:        "last_backup_duration_seconds",
+				Help:        "Duration in seconds of the last BBR backup of a BOSH deployment.",
+				ConstLabels: constLabels,
+			},
+			[]string{"bosh_deployment"},
+		)
+
+		lastBackupSuccessMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "director",
+				Name:        "last_backup_success",
+				Help:        "Whether the SSH sessions of the last BBR backup of a BOSH deployment were set up and cleaned up without error (1 for success, 0 for failure).",
+				ConstLabels: constLabels,
+			},
+			[]string{"bosh_deployment"},
+		)
+	})
+
+	JustBeforeEach(func() {
+		backupsCollector = NewBackupsCollector(namespace, environment, boshName, boshUUID, boshClient, "bbr-", 7*24*time.Hour, time.Hour)
+		backupsCollector.SetClock(fakeClock)
+	})
+
+	Describe("Describe", func() {
+		var (
+			descriptions chan *prometheus.Desc
+		)
+
+		BeforeEach(func() {
+			descriptions = make(chan *prometheus.Desc)
+		})
+
+		JustBeforeEach(func() {
+			go backupsCollector.Describe(descriptions)
+		})
+
+		It("returns a director_last_backup_timestamp_seconds metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(lastBackupTimestampMetric.WithLabelValues("fake-deployment").Desc())))
+		})
+
+		It("returns a director_last_backup_duration_seconds metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(lastBackupDurationMetric.WithLabelValues("fake-deployment").Desc())))
+		})
+
+		It("returns a director_last_backup_success metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(lastBackupSuccessMetric.WithLabelValues("fake-deployment").Desc())))
+		})
+	})
+
+	Describe("Collect", func() {
+		var (
+			metrics   chan prometheus.Metric
+			collected chan struct{}
+		)
+
+		BeforeEach(func() {
+			metrics = make(chan prometheus.Metric)
+			lastBackupTimestampMetric.WithLabelValues("fake-deployment").Set(float64(startedAt.Add(10 * time.Minute).Unix()))
+			lastBackupDurationMetric.WithLabelValues("fake-deployment").Set(float64(600))
+			lastBackupSuccessMetric.WithLabelValues("fake-deployment").Set(float64(1))
+		})
+
+		JustBeforeEach(func() {
+			collected = make(chan struct{})
+			go func() {
+				backupsCollector.Collect(metrics)
+				close(collected)
+			}()
+		})
+
+		// The collection reads the events of the test, so it must be over
+		// before the next test replaces them.
+		AfterEach(func() {
+			for {
+				select {
+				case <-metrics:
+				case <-collected:
+					return
+				}
+			}
+		})
+
+		It("returns a director_last_backup_timestamp_seconds metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(lastBackupTimestampMetric.WithLabelValues("fake-deployment"))))
+		})
+
+		It("returns a director_last_backup_duration_seconds metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(lastBackupDurationMetric.WithLabelValues("fake-deployment"))))
+		})
+
+		It("returns a director_last_backup_success metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(lastBackupSuccessMetric.WithLabelValues("fake-deployment"))))
+		})
+
+		It("reads the setup and cleanup SSH events of the window", func() {
+			Eventually(boshClient.EventsCallCount).Should(Equal(2))
+			Expect(boshClient.EventsArgsForCall(0).Action).To(Equal("setup ssh"))
+			Expect(boshClient.EventsArgsForCall(1).Action).To(Equal("cleanup ssh"))
+			after, err := time.Parse(time.RFC3339, boshClient.EventsArgsForCall(0).After)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(after).To(BeTemporally("~", time.Now().Add(-7*24*time.Hour), time.Minute))
+		})
+
+		It("reads the events again once the refresh interval elapsed", func() {
+			for i := 0; i < 3; i++ {
+				Eventually(metrics).Should(Receive())
+			}
+
+			go backupsCollector.Collect(metrics)
+			for i := 0; i < 3; i++ {
+				Eventually(metrics).Should(Receive())
+			}
+			Expect(boshClient.EventsCallCount()).To(Equal(2))
+
+			fakeClock.Advance(time.Hour)
+			go backupsCollector.Collect(metrics)
+			for i := 0; i < 3; i++ {
+				Eventually(metrics).Should(Receive())
+			}
+			Expect(boshClient.EventsCallCount()).To(Equal(4))
+		})
+
+		Context("when a SSH session failed", func() {
+			BeforeEach(func() {
+				cleanupEvents[0] = sshEvent("cleanup ssh", "fake-deployment", "^bbr-1234", startedAt.Add(10*time.Minute), "Timed out")
+				lastBackupSuccessMetric.WithLabelValues("fake-deployment").Set(float64(0))
+			})
+
+			It("returns a failed director_last_backup_success metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(lastBackupSuccessMetric.WithLabelValues("fake-deployment"))))
+			})
+		})
+
+		Context("when the backup is still running", func() {
+			BeforeEach(func() {
+				cleanupEvents = []director.Event{}
+			})
+
+			It("does not return a metric", func() {
+				Consistently(metrics).ShouldNot(Receive())
+			})
+		})
+
+		Context("when the events cannot be read", func() {
+			BeforeEach(func() {
+				boshClient.EventsStub = nil
+				boshClient.EventsReturns(nil, errors.New("no events"))
+			})
+
+			It("does not return a metric", func() {
+				Consistently(metrics).ShouldNot(Receive())
+			})
+		})
+	})
+})
@@ -0,0 +1,201 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+)
+
+// BlobstoreUsage is the number of release versions and distinct package blobs
+// of every release, and of versions of every stemcell, uploaded to a BOSH
+// Director.
+type BlobstoreUsage struct {
+	ReleaseVersions      map[string]int
+	SourcePackageBlobs   map[string]int
+	CompiledPackageBlobs map[string]int
+	StemcellVersions     map[string]int
+}
+
+// BlobstoreCollector reports the releases and stemcells stored by the BOSH
+// Director. The Director does not expose the size of its blobstore, so the
+// number of blobs is reported instead to track its growth.
+type BlobstoreCollector struct {
+	boshClient      director.Director
+	refreshInterval time.Duration
+	lastRefresh     time.Time
+	clock           clock.Clock
+
+	releaseVersionsMetric  *prometheus.GaugeVec
+	packageBlobsMetric     *prometheus.GaugeVec
+	stemcellVersionsMetric *prometheus.GaugeVec
+	mu                     *sync.Mutex
+}
+
+func NewBlobstoreCollector(
+	namespace string,
+	environment string,
+	boshName string,
+	boshUUID string,
+	boshClient director.Director,
+	refreshInterval time.Duration,
+) *BlobstoreCollector {
+	constLabels := prometheus.Labels{
+		"environment": environment,
+		"bosh_name":   boshName,
+		"bosh_uuid":   boshUUID,
+	}
+
+	releaseVersionsMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "director",
+			Name:        "release_versions",
+			Help:        "Number of versions of a BOSH release uploaded to the BOSH Director.",
+			ConstLabels: constLabels,
+		},
+		[]string{"bosh_release_name"},
+	)
+
+	packageBlobsMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "director",
+			Name:        "blobstore_package_blobs",
+			Help:        "Number of distinct source or compiled package blobs of a BOSH release stored in the BOSH Director blobstore.",
+			ConstLabels: constLabels,
+		},
+		[]string{"bosh_release_name", "type"},
+	)
+
+	stemcellVersionsMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "director",
+			Name:        "stemcell_versions",
+			Help:        "Number of versions of a BOSH stemcell uploaded to the BOSH Director.",
+			ConstLabels: constLabels,
+		},
+		[]string{"bosh_stemcell_name"},
+	)
+
+	return &BlobstoreCollector{
+		boshClient:             boshClient,
+		refreshInterval:        refreshInterval,
+		clock:                  clock.Real,
+		releaseVersionsMetric:  releaseVersionsMetric,
+		packageBlobsMetric:     packageBlobsMetric,
+		stemcellVersionsMetric: stemcellVersionsMetric,
+		mu:                     &sync.Mutex{},
+	}
+}
+
+// SetClock replaces the clock telling when to refresh.
+func (c *BlobstoreCollector) SetClock(clk clock.Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clk
+}
+
+func (c *BlobstoreCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lastRefresh.IsZero() || c.clock.Now().Sub(c.lastRefresh) >= c.refreshInterval {
+		if err := c.refresh(); err != nil {
+			log.Error(err)
+		}
+		c.lastRefresh = c.clock.Now()
+	}
+
+	c.releaseVersionsMetric.Collect(ch)
+	c.packageBlobsMetric.Collect(ch)
+	c.stemcellVersionsMetric.Collect(ch)
+}
+
+func (c *BlobstoreCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.releaseVersionsMetric.Describe(ch)
+	c.packageBlobsMetric.Describe(ch)
+	c.stemcellVersionsMetric.Describe(ch)
+}
+
+// refresh replaces the metrics with the current usage, keeping the previous
+// ones when the usage cannot be read.
+func (c *BlobstoreCollector) refresh() error {
+	usage, err := ReadBlobstoreUsage(c.boshClient)
+	if err != nil {
+		return err
+	}
+
+	c.releaseVersionsMetric.Reset()
+	c.packageBlobsMetric.Reset()
+	c.stemcellVersionsMetric.Reset()
+
+	for release, versions := range usage.ReleaseVersions {
+		c.releaseVersionsMetric.WithLabelValues(release).Set(float64(versions))
+		c.packageBlobsMetric.WithLabelValues(release, "source").Set(float64(usage.SourcePackageBlobs[release]))
+		c.packageBlobsMetric.WithLabelValues(release, "compiled").Set(float64(usage.CompiledPackageBlobs[release]))
+	}
+	for stemcell, versions := range usage.StemcellVersions {
+		c.stemcellVersionsMetric.WithLabelValues(stemcell).Set(float64(versions))
+	}
+
+	return nil
+}
+
+// ReadBlobstoreUsage lists the releases and stemcells of the Director and the
+// packages of every release version. Versions of a release sharing a package
+// share its blob, so blobs are counted once per release.
+func ReadBlobstoreUsage(boshClient director.Director) (BlobstoreUsage, error) {
+	usage := BlobstoreUsage{
+		ReleaseVersions:      map[string]int{},
+		SourcePackageBlobs:   map[string]int{},
+		CompiledPackageBlobs: map[string]int{},
+		StemcellVersions:     map[string]int{},
+	}
+
+	releases, err := boshClient.Releases()
+	if err != nil {
+		return usage, errors.New(fmt.Sprintf("Error while reading releases: %v", err))
+	}
+
+	blobs := map[string]bool{}
+	for _, release := range releases {
+		usage.ReleaseVersions[release.Name()]++
+
+		packages, err := release.Packages()
+		if err != nil {
+			return usage, errors.New(fmt.Sprintf("Error while reading packages of release `%s/%s`: %v", release.Name(), release.Version(), err))
+		}
+
+		for _, pkg := range packages {
+			if pkg.BlobstoreID != "" && !blobs[pkg.BlobstoreID] {
+				blobs[pkg.BlobstoreID] = true
+				usage.SourcePackageBlobs[release.Name()]++
+			}
+			for _, compiledPackage := range pkg.CompiledPackages {
+				if compiledPackage.BlobstoreID != "" && !blobs[compiledPackage.BlobstoreID] {
+					blobs[compiledPackage.BlobstoreID] = true
+					usage.CompiledPackageBlobs[release.Name()]++
+				}
+			}
+		}
+	}
+
+	stemcells, err := boshClient.Stemcells()
+	if err != nil {
+		return usage, errors.New(fmt.Sprintf("Error while reading stemcells: %v", err))
+	}
+
+	for _, stemcell := range stemcells {
+		usage.StemcellVersions[stemcell.Name()]++
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,209 @@
+package collectors_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/director/directorfakes"
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+func fakeRelease(name string, packages []director.Package) director.Release {
+	release := &directorfakes.FakeRelease{}
+	release.NameReturns(name)
+	release.PackagesReturns(packages, nil)
+	return release
+}
+
+func fakeStemcell(name string) director.Stemcell {
+	stemcell := &directorfakes.FakeStemcell{}
+	stemcell.NameReturns(name)
+	return stemcell
+}
+
+var _ = Describe("BlobstoreCollector", func() {
+	var (
+		namespace   string
+		environment string
+		boshName    string
+		boshUUID    string
+		boshClient  *directorfakes.FakeDirector
+		releases    []director.Release
+		stemcells   []director.Stemcell
+
+		blobstoreCollector *BlobstoreCollector
+
+		releaseVersionsMetric  *prometheus.GaugeVec
+		packageBlobsMetric     *prometheus.GaugeVec
+		stemcellVersionsMetric *prometheus.GaugeVec
+	)
+
+	BeforeEach(func() {
+		namespace = "test_exporter"
+		environment = "test_environment"
+		boshName = "test_bosh_name"
+		boshUUID = "test_bosh_uuid"
+
+		compiledPackages := []director.CompiledPackage{
+			{BlobstoreID: "compiled-golang-1"},
+			{BlobstoreID: "compiled-golang-2"},
+		}
+		releases = []director.Release{
+			fakeRelease("fake-release", []director.Package{
+				{Name: "golang", BlobstoreID: "golang-1", CompiledPackages: compiledPackages},
+				{Name: "server", BlobstoreID: "server-1"},
+			}),
+			fakeRelease("fake-release", []director.Package{
+				{Name: "golang", BlobstoreID: "golang-1", CompiledPackages: compiledPackages},
+				{Name: "server", BlobstoreID: "server-2", CompiledPackages: []director.CompiledPackage{{BlobstoreID: "compiled-server-2"}}},
+			}),
+		}
+		stemcells = []director.Stemcell{
+			fakeStemcell("bosh-warden-boshlite-ubuntu-jammy-go_agent"),
+			fakeStemcell("bosh-warden-boshlite-ubuntu-jammy-go_agent"),
+		}
+
+		boshClient = &directorfakes.FakeDirector{}
+		boshClient.ReleasesStub = func() ([]director.Release, error) {
+			return releases, nil
+		}
+		boshClient.StemcellsStub = func() ([]director.Stemcell, error) {
+			return stemcells, nil
+		}
+
+		constLabels := prometheus.Labels{
+			"environment": environment,
+			"bosh_name":   boshName,
+			"bosh_uuid":   boshUUID,
+		}
+
+		releaseVersionsMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "director",
+				Name:        "release_versions",
+				Help:        "Number of versions of a BOSH release uploaded to the BOSH Director.",
+				ConstLabels: constLabels,
+			},
+			[]string{"bosh_release_name"},
+		)
+
+		packageBlobsMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "director",
+				Name:        "blobstore_package_blobs",
+				Help:        "Number of distinct source or compiled package blobs of a BOSH release stored in the BOSH Director blobstore.",
+				ConstLabels: constLabels,
+			},
+			[]string{"bosh_release_name", "type"},
+		)
+
+		stemcellVersionsMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "director",
+				Name:        "stemcell_versions",
+				Help:        "Number of versions of a BOSH stemcell uploaded to the BOSH Director.",
+				ConstLabels: constLabels,
+			},
+			[]string{"bosh_stemcell_name"},
+		)
+	})
+
+	JustBeforeEach(func() {
+		blobstoreCollector = NewBlobstoreCollector(namespace, environment, boshName, boshUUID, boshClient, time.Hour)
+	})
+
+	Describe("Describe", func() {
+		var (
+			descriptions chan *prometheus.Desc
+		)
+
+		BeforeEach(func() {
+			descriptions = make(chan *prometheus.Desc)
+		})
+
+		JustBeforeEach(func() {
+			go blobstoreCollector.Describe(descriptions)
+		})
+
+		It("returns a director_release_versions metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(releaseVersionsMetric.WithLabelValues("fake-release").Desc())))
+		})
+
+		It("returns a director_blobstore_package_blobs metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(packageBlobsMetric.WithLabelValues("fake-release", "source").Desc())))
+		})
+
+		It("returns a director_stemcell_versions metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(stemcellVersionsMetric.WithLabelValues("fake-stemcell").Desc())))
+		})
+	})
+
+	Describe("Collect", func() {
+		var (
+			metrics chan prometheus.Metric
+		)
+
+		BeforeEach(func() {
+			metrics = make(chan prometheus.Metric)
+			releaseVersionsMetric.WithLabelValues("fake-release").Set(float64(2))
+			packageBlobsMetric.WithLabelValues("fake-release", "source").Set(float64(3))
+			packageBlobsMetric.WithLabelValues("fake-release", "compiled").Set(float64(3))
+			stemcellVersionsMetric.WithLabelValues("bosh-warden-boshlite-ubuntu-jammy-go_agent").Set(float64(2))
+		})
+
+		JustBeforeEach(func() {
+			go blobstoreCollector.Collect(metrics)
+		})
+
+		It("returns a director_release_versions metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(releaseVersionsMetric.WithLabelValues("fake-release"))))
+		})
+
+		It("returns a director_blobstore_package_blobs metric for the source packages", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(packageBlobsMetric.WithLabelValues("fake-release", "source"))))
+		})
+
+		It("returns a director_blobstore_package_blobs metric for the compiled packages", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(packageBlobsMetric.WithLabelValues("fake-release", "compiled"))))
+		})
+
+		It("returns a director_stemcell_versions metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(stemcellVersionsMetric.WithLabelValues("bosh-warden-boshlite-ubuntu-jammy-go_agent"))))
+		})
+
+		Context("when the releases cannot be read", func() {
+			BeforeEach(func() {
+				boshClient.ReleasesStub = func() ([]director.Release, error) {
+					return nil, errors.New("no releases")
+				}
+			})
+
+			It("does not return a metric", func() {
+				Consistently(metrics).ShouldNot(Receive())
+			})
+		})
+	})
+})
+
+var _ = Describe("ReadBlobstoreUsage", func() {
+	It("returns an error when the packages of a release cannot be read", func() {
+		release := &directorfakes.FakeRelease{}
+		release.NameReturns("fake-release")
+		release.PackagesReturns(nil, errors.New("no packages"))
+		boshClient := &directorfakes.FakeDirector{}
+		boshClient.ReleasesReturns([]director.Release{release}, nil)
+
+		_, err := ReadBlobstoreUsage(boshClient)
+		Expect(err).To(MatchError(ContainSubstring("no packages")))
+	})
+})
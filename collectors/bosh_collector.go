@@ -1,6 +1,11 @@
 package collectors
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -8,17 +13,48 @@ import (
 	"github.com/prometheus/common/log"
 
 	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
 	"github.com/bosh-prometheus/bosh_exporter/filters"
+	"github.com/bosh-prometheus/bosh_exporter/publishers"
+	"github.com/bosh-prometheus/bosh_exporter/sanitizers"
+	"github.com/bosh-prometheus/bosh_exporter/sinks"
+	"github.com/bosh-prometheus/bosh_exporter/tracing"
 )
 
 type BoshCollector struct {
-	enabledCollectors                   []Collector
-	deploymentsFetcher                  *deployments.Fetcher
+	enabledCollectors                   map[string]Collector
+	snapshotPublishers                  []publishers.Publisher
+	boshFetcher                         fetcher.SnapshotFetcher
+	pauseWindows                        fetcher.PauseWindows
+	lastSnapshot                        *fetcher.Snapshot
+	singleFlight                        *fetcher.SingleFlight
+	metricsTimestamps                   bool
+	metricsTimestampsMaxAge             time.Duration
+	errorMode                           string
+	maxSeries                           int
+	stoppedDeployments                  string
+	streamDeployments                   bool
+	middlewares                         []Middleware
+	tracer                              *tracing.Tracer
+	eventRecorder                       EventRecorder
+	environment                         string
+	boshName                            string
 	totalBoshScrapesMetric              prometheus.Counter
+	coalescedScrapesMetric              prometheus.Counter
 	totalBoshScrapeErrorsMetric         prometheus.Counter
 	lastBoshScrapeErrorMetric           prometheus.Gauge
 	lastBoshScrapeTimestampMetric       prometheus.Gauge
 	lastBoshScrapeDurationSecondsMetric prometheus.Gauge
+	collectorLastSuccessTimestampMetric *prometheus.GaugeVec
+	scrapePausedMetric                  prometheus.Gauge
+	visibleDeploymentsMetric            prometheus.Gauge
+	hiddenDeploymentsMetric             prometheus.Gauge
+	upMetric                            prometheus.Gauge
+	collectionErrorsMetric              *prometheus.CounterVec
+	collectedSeriesMetric               prometheus.Gauge
+	cardinalityLimitedMetric            prometheus.Gauge
+	lastHiddenDeployments               string
+	mu                                  *sync.Mutex
 }
 
 func NewBoshCollector(
@@ -26,23 +62,70 @@ func NewBoshCollector(
 	environment string,
 	boshName string,
 	boshUUID string,
+	stemcellVersionsThreshold int,
+	stemcellDaysThreshold int,
+	failedTasksWindow time.Duration,
+	instanceAttributes []string,
+	persistentDiskGrowthWindow time.Duration,
+	sloObjectives SLOObjectives,
+	sloWindow time.Duration,
+	metricsTimestamps bool,
+	metricsTimestampsMaxAge time.Duration,
+	errorMode string,
+	maxSeries int,
+	vitalsHistograms bool,
+	kbSeries bool,
+	processThresholds []ProcessThreshold,
+	stoppedDeployments string,
+	streamDeployments bool,
+	tracer *tracing.Tracer,
+	eventRecorder EventRecorder,
 	serviceDiscoveryFilename string,
-	deploymentsFetcher *deployments.Fetcher,
+	serviceDiscoveryTmpDir string,
+	serviceDiscoveryFsync bool,
+	serviceDiscoveryKeepBackups int,
+	serviceDiscoveryRefuseEmptyOutput bool,
+	serviceDiscoverySinks []sinks.Sink,
+	serviceDiscoveryMetadata bool,
+	serviceDiscoverySigningKey []byte,
+	serviceDiscoveryTargetTTL time.Duration,
+	serviceDiscoveryIPFallbackTTL time.Duration,
+	serviceDiscoveryInstanceAttributes []string,
+	serviceDiscoveryProcessPorts map[string]int,
+	serviceDiscoveryProcessAnnotations []ProcessAnnotation,
+	serviceDiscoveryTargetMode string,
+	serviceDiscoverySkippedInstancesLogInterval time.Duration,
+	serviceDiscoveryLabelSanitizer *sanitizers.LabelSanitizer,
+	snapshotPublishers []publishers.Publisher,
+	pauseWindows fetcher.PauseWindows,
+	boshFetcher fetcher.SnapshotFetcher,
 	collectorsFilter *filters.CollectorsFilter,
 	azsFilter *filters.AZsFilter,
 	processesFilter *filters.RegexpFilter,
+	deploymentProcessesFilter *filters.DeploymentProcessesFilter,
+	expressionFilter *filters.ExpressionFilter,
 	cidrsFilter *filters.CidrFilter,
 ) *BoshCollector {
-	enabledCollectors := []Collector{}
+	enabledCollectors := map[string]Collector{}
 
 	if collectorsFilter.Enabled(filters.DeploymentsCollector) {
-		deploymentsCollector := NewDeploymentsCollector(namespace, environment, boshName, boshUUID)
-		enabledCollectors = append(enabledCollectors, deploymentsCollector)
+		deploymentsCollector := NewDeploymentsCollector(namespace, environment, boshName, boshUUID, stemcellVersionsThreshold, stemcellDaysThreshold, sloObjectives, sloWindow, kbSeries)
+		enabledCollectors[filters.DeploymentsCollector] = deploymentsCollector
 	}
 
 	if collectorsFilter.Enabled(filters.JobsCollector) {
-		jobsCollector := NewJobsCollector(namespace, environment, boshName, boshUUID, azsFilter, cidrsFilter)
-		enabledCollectors = append(enabledCollectors, jobsCollector)
+		jobsCollector := NewJobsCollector(namespace, environment, boshName, boshUUID, instanceAttributes, persistentDiskGrowthWindow, kbSeries, stoppedDeployments == StoppedDeploymentsLabel, azsFilter, deploymentProcessesFilter, expressionFilter, cidrsFilter)
+		enabledCollectors[filters.JobsCollector] = jobsCollector
+
+		if vitalsHistograms {
+			vitalsHistogramsCollector := NewVitalsHistogramsCollector(namespace, environment, boshName, boshUUID, kbSeries, azsFilter, deploymentProcessesFilter, expressionFilter, cidrsFilter)
+			enabledCollectors[vitalsHistogramsCollectorName] = vitalsHistogramsCollector
+		}
+
+		if len(processThresholds) > 0 {
+			processThresholdsCollector := NewProcessThresholdsCollector(namespace, environment, boshName, boshUUID, processThresholds, azsFilter, deploymentProcessesFilter, expressionFilter, cidrsFilter)
+			enabledCollectors[processThresholdsCollectorName] = processThresholdsCollector
+		}
 	}
 
 	if collectorsFilter.Enabled(filters.ServiceDiscoveryCollector) {
@@ -52,11 +135,34 @@ func NewBoshCollector(
 			boshName,
 			boshUUID,
 			serviceDiscoveryFilename,
+			serviceDiscoveryTmpDir,
+			serviceDiscoveryFsync,
+			serviceDiscoveryKeepBackups,
+			serviceDiscoveryRefuseEmptyOutput,
+			serviceDiscoverySinks,
+			serviceDiscoveryMetadata,
+			serviceDiscoverySigningKey,
+			serviceDiscoveryTargetTTL,
+			serviceDiscoveryIPFallbackTTL,
+			serviceDiscoveryInstanceAttributes,
+			serviceDiscoveryProcessPorts,
+			serviceDiscoveryProcessAnnotations,
+			serviceDiscoveryTargetMode,
+			serviceDiscoverySkippedInstancesLogInterval,
+			eventRecorder,
+			serviceDiscoveryLabelSanitizer,
 			azsFilter,
 			processesFilter,
+			deploymentProcessesFilter,
+			expressionFilter,
 			cidrsFilter,
 		)
-		enabledCollectors = append(enabledCollectors, serviceDiscoveryCollector)
+		enabledCollectors[filters.ServiceDiscoveryCollector] = serviceDiscoveryCollector
+	}
+
+	if collectorsFilter.Enabled(filters.TasksCollector) {
+		tasksCollector := NewTasksCollector(namespace, environment, boshName, boshUUID, failedTasksWindow)
+		enabledCollectors[filters.TasksCollector] = tasksCollector
 	}
 
 	totalBoshScrapesMetric := prometheus.NewCounter(
@@ -73,6 +179,20 @@ func NewBoshCollector(
 		},
 	)
 
+	coalescedScrapesMetric := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "",
+			Name:      "coalesced_scrapes_total",
+			Help:      "Total number of scrapes that reused the BOSH Director fetch of a concurrent scrape instead of starting their own.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
 	totalBoshScrapeErrorsMetric := prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Namespace: namespace,
@@ -129,14 +249,151 @@ func NewBoshCollector(
 		},
 	)
 
+	collectorLastSuccessTimestampMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "collector_last_success_timestamp_seconds",
+			Help:      "Number of seconds since 1970 since the last successful run of a collector.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"collector"},
+	)
+
+	scrapePausedMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "",
+			Name:      "scrape_paused",
+			Help:      "Whether BOSH fetching is paused by a pause window and cached data is served (1 for paused, 0 for not paused).",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
+	visibleDeploymentsMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "visible_deployments",
+			Help:      "Number of BOSH deployments visible to the exporter credentials, before filtering.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
+	hiddenDeploymentsMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "hidden_deployments",
+			Help:      "Number of BOSH deployments referenced by recent tasks but not visible to the exporter credentials.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
+	upMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "up",
+			Help:      "Whether the last collection could fetch the BOSH Director (1 for up, 0 for down).",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
+	collectionErrorsMetric := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "collection_errors_total",
+			Help:      "Total number of BOSH collection errors, by error class.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"class"},
+	)
+
+	collectedSeriesMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "collected_series",
+			Help:      "Number of series returned by the collectors during the last collection, before any cardinality limit.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
+	cardinalityLimitedMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "cardinality_limited",
+			Help:      "Whether the last collection exceeded the max series and instance metrics were aggregated by instance group (1 for limited, 0 for not limited).",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
 	return &BoshCollector{
 		enabledCollectors:                   enabledCollectors,
-		deploymentsFetcher:                  deploymentsFetcher,
+		snapshotPublishers:                  snapshotPublishers,
+		boshFetcher:                         boshFetcher,
+		pauseWindows:                        pauseWindows,
+		singleFlight:                        fetcher.NewSingleFlight(),
+		metricsTimestamps:                   metricsTimestamps,
+		metricsTimestampsMaxAge:             metricsTimestampsMaxAge,
+		errorMode:                           errorMode,
+		maxSeries:                           maxSeries,
+		stoppedDeployments:                  stoppedDeployments,
+		streamDeployments:                   streamDeployments,
+		tracer:                              tracer,
+		eventRecorder:                       eventRecorder,
+		environment:                         environment,
+		boshName:                            boshName,
 		totalBoshScrapesMetric:              totalBoshScrapesMetric,
+		coalescedScrapesMetric:              coalescedScrapesMetric,
 		totalBoshScrapeErrorsMetric:         totalBoshScrapeErrorsMetric,
 		lastBoshScrapeErrorMetric:           lastBoshScrapeErrorMetric,
 		lastBoshScrapeTimestampMetric:       lastBoshScrapeTimestampMetric,
 		lastBoshScrapeDurationSecondsMetric: lastBoshScrapeDurationSecondsMetric,
+		collectorLastSuccessTimestampMetric: collectorLastSuccessTimestampMetric,
+		scrapePausedMetric:                  scrapePausedMetric,
+		visibleDeploymentsMetric:            visibleDeploymentsMetric,
+		hiddenDeploymentsMetric:             hiddenDeploymentsMetric,
+		upMetric:                            upMetric,
+		collectionErrorsMetric:              collectionErrorsMetric,
+		collectedSeriesMetric:               collectedSeriesMetric,
+		cardinalityLimitedMetric:            cardinalityLimitedMetric,
+		mu:                                  &sync.Mutex{},
 	}
 }
 
@@ -153,31 +410,143 @@ func (c *BoshCollector) Describe(ch chan<- *prometheus.Desc) {
 	wg.Wait()
 
 	c.totalBoshScrapesMetric.Describe(ch)
+	c.coalescedScrapesMetric.Describe(ch)
 	c.totalBoshScrapeErrorsMetric.Describe(ch)
 	c.lastBoshScrapeErrorMetric.Describe(ch)
 	c.lastBoshScrapeTimestampMetric.Describe(ch)
 	c.lastBoshScrapeDurationSecondsMetric.Describe(ch)
+	c.collectorLastSuccessTimestampMetric.Describe(ch)
+	c.scrapePausedMetric.Describe(ch)
+	c.visibleDeploymentsMetric.Describe(ch)
+	c.hiddenDeploymentsMetric.Describe(ch)
+	c.upMetric.Describe(ch)
+	c.collectionErrorsMetric.Describe(ch)
+	c.collectedSeriesMetric.Describe(ch)
+	c.cardinalityLimitedMetric.Describe(ch)
+}
+
+// Use appends middlewares to the stages every collector runs through, after
+// the tracing, timing and timestamping ones. It must be called before the
+// first collection.
+func (c *BoshCollector) Use(middlewares ...Middleware) {
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+// SetServiceDiscoveryPortProber makes the Service Discovery collector, when
+// enabled, only write the process targets whose port answers the prober. It
+// must be called before the first collection.
+func (c *BoshCollector) SetServiceDiscoveryPortProber(prober *PortProber) {
+	if serviceDiscoveryCollector, ok := c.enabledCollectors[filters.ServiceDiscoveryCollector].(*ServiceDiscoveryCollector); ok {
+		serviceDiscoveryCollector.SetPortProber(prober)
+	}
 }
 
 func (c *BoshCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collect(ch, false)
+}
+
+// Refresh fetches BOSH out of band, ignoring pause windows, and runs every
+// collector so the service discovery file and publishers are updated at once.
+func (c *BoshCollector) Refresh() error {
+	ch := make(chan prometheus.Metric)
+	drained := make(chan bool)
+	go func() {
+		for range ch {
+		}
+		close(drained)
+	}()
+
+	err := c.collect(ch, true)
+	close(ch)
+	<-drained
+
+	return err
+}
+
+func (c *BoshCollector) ScanDeploymentProblems(deploymentName string) error {
+	problemsScanner, ok := c.boshFetcher.(fetcher.ProblemsScanner)
+	if !ok {
+		return errors.New("Problem scans are not supported by this BOSH fetcher")
+	}
+
+	return problemsScanner.ScanProblems(deploymentName)
+}
+
+// PreviewServiceDiscovery fetches BOSH and compares the Service Discovery
+// output currently served with the one the next write would produce, without
+// writing it. A non-nil expressionFilter replaces the configured one.
+func (c *BoshCollector) PreviewServiceDiscovery(expressionFilter *filters.ExpressionFilter) (ServiceDiscoveryDiff, error) {
+	serviceDiscoveryCollector, ok := c.enabledCollectors[filters.ServiceDiscoveryCollector].(*ServiceDiscoveryCollector)
+	if !ok {
+		return ServiceDiscoveryDiff{}, errors.New("The Service Discovery collector is not enabled")
+	}
+
+	snapshot, _, err := c.singleFlight.Fetch(context.Background(), func() (fetcher.Snapshot, error) {
+		return c.boshFetcher.Fetch(context.Background())
+	})
+	if err != nil {
+		return ServiceDiscoveryDiff{}, err
+	}
+	if c.stoppedDeployments == StoppedDeploymentsExclude {
+		snapshot = excludeStoppedDeployments(snapshot)
+	}
+
+	current, next, err := serviceDiscoveryCollector.PreviewTargetGroups(snapshot, expressionFilter)
+	if err != nil {
+		return ServiceDiscoveryDiff{}, err
+	}
+
+	return DiffTargetGroups(current, next), nil
+}
+
+func (c *BoshCollector) collect(ch chan<- prometheus.Metric, force bool) error {
 	var begun = time.Now()
 
+	span := c.tracer.Start(nil, "bosh.collect")
+	defer span.Finish()
+
 	scrapeError := 0
 	c.totalBoshScrapesMetric.Inc()
-	deployments, err := c.deploymentsFetcher.Deployments()
+	snapshot, eachDeployment, paused, err := c.tracedFetch(span, begun, force)
 	if err != nil {
+		span.RecordError(err)
 		log.Error(err)
 		scrapeError = 1
 		c.totalBoshScrapeErrorsMetric.Inc()
+		c.collectionErrorsMetric.WithLabelValues(ErrorClass(err)).Inc()
+		c.upMetric.Set(0)
+		c.recordDirectorState(err)
+		if c.errorMode == ErrorModeStrict {
+			ch <- prometheus.NewInvalidMetric(c.upMetric.Desc(), err)
+		}
 	} else {
-		if err := c.executeCollectors(deployments, ch); err != nil {
+		c.upMetric.Set(1)
+		if !paused {
+			c.recordDirectorState(nil)
+		}
+		if c.stoppedDeployments == StoppedDeploymentsExclude {
+			snapshot = excludeStoppedDeployments(snapshot)
+			if eachDeployment != nil {
+				eachDeployment = excludeStoppedDeploymentsStream(eachDeployment)
+			}
+		}
+		if err = c.executeLimitedCollectors(span, snapshot, eachDeployment, ch); err != nil {
+			span.RecordError(err)
 			log.Error(err)
 			scrapeError = 1
 			c.totalBoshScrapeErrorsMetric.Inc()
+			c.collectionErrorsMetric.WithLabelValues(ErrorClassCollector).Inc()
+		}
+		if !paused {
+			c.publish(snapshot)
+		}
+		if snapshot.VisibleDeployments != nil {
+			c.reportDeploymentsVisibility(snapshot, ch)
 		}
 	}
 
 	c.totalBoshScrapesMetric.Collect(ch)
+	c.coalescedScrapesMetric.Collect(ch)
 
 	c.totalBoshScrapeErrorsMetric.Collect(ch)
 
@@ -189,34 +558,317 @@ func (c *BoshCollector) Collect(ch chan<- prometheus.Metric) {
 
 	c.lastBoshScrapeDurationSecondsMetric.Set(time.Since(begun).Seconds())
 	c.lastBoshScrapeDurationSecondsMetric.Collect(ch)
+
+	c.collectorLastSuccessTimestampMetric.Collect(ch)
+
+	if paused {
+		c.scrapePausedMetric.Set(1)
+	} else {
+		c.scrapePausedMetric.Set(0)
+	}
+	c.scrapePausedMetric.Collect(ch)
+
+	c.upMetric.Collect(ch)
+	c.collectionErrorsMetric.Collect(ch)
+
+	if c.maxSeries > 0 {
+		c.collectedSeriesMetric.Collect(ch)
+		c.cardinalityLimitedMetric.Collect(ch)
+	}
+
+	return err
+}
+
+func (c *BoshCollector) recordDirectorState(err error) {
+	if c.eventRecorder == nil {
+		return
+	}
+
+	key := c.environment + "/director"
+	if err == nil {
+		c.eventRecorder.Transition(key, EventTypeNormal, EventReasonDirectorReachable, fmt.Sprintf("BOSH Director `%s` is reachable", c.boshName))
+		return
+	}
+
+	switch ErrorClass(err) {
+	case ErrorClassAuth:
+		c.eventRecorder.Transition(key, EventTypeWarning, EventReasonDirectorAuthFailed, fmt.Sprintf("Authentication against BOSH Director `%s` failed: %v", c.boshName, err))
+	case ErrorClassConnection, ErrorClassTimeout, ErrorClassTLS:
+		c.eventRecorder.Transition(key, EventTypeWarning, EventReasonDirectorUnreachable, fmt.Sprintf("BOSH Director `%s` is unreachable: %v", c.boshName, err))
+	}
 }
 
-func (c *BoshCollector) executeCollectors(deployments []deployments.DeploymentInfo, ch chan<- prometheus.Metric) error {
+// tracedFetch makes the fetch span the parent of the BOSH Director requests.
+// When the deployments are streamed, the returned iterator reads them and the
+// snapshot has none.
+func (c *BoshCollector) tracedFetch(parent *tracing.Span, now time.Time, force bool) (fetcher.Snapshot, fetcher.DeploymentsIterator, bool, error) {
+	span := c.tracer.Start(parent, "bosh.fetch")
+	defer span.Finish()
+
+	c.tracer.SetActive(span)
+	defer c.tracer.SetActive(nil)
+
+	if streamingFetcher, ok := c.boshFetcher.(fetcher.StreamingFetcher); ok && c.streaming() {
+		span.SetAttribute("bosh.streamed", "true")
+		snapshot, eachDeployment, err := streamingFetcher.FetchStream(context.Background())
+		span.RecordError(err)
+		return snapshot, eachDeployment, false, err
+	}
+
+	snapshot, paused, err := c.fetch(now, force)
+	span.RecordError(err)
+	if paused {
+		span.SetAttribute("bosh.paused", "true")
+	}
+
+	return snapshot, nil, paused, err
+}
+
+// streaming tells whether the deployments can be streamed to the collectors,
+// which is not the case when the whole snapshot must be kept for pause
+// windows or publishers.
+func (c *BoshCollector) streaming() bool {
+	return c.streamDeployments && len(c.pauseWindows) == 0 && len(c.snapshotPublishers) == 0
+}
+
+// fetch serves the last snapshot while a pause window is active, so the director
+// is only queried again once the window ends or a refresh is forced.
+func (c *BoshCollector) fetch(now time.Time, force bool) (fetcher.Snapshot, bool, error) {
+	if len(c.pauseWindows) == 0 {
+		snapshot, err := c.sharedFetch()
+		return snapshot, false, err
+	}
+
+	c.mu.Lock()
+	if !force && c.lastSnapshot != nil && c.pauseWindows.Paused(now) {
+		defer c.mu.Unlock()
+		return *c.lastSnapshot, true, nil
+	}
+	c.mu.Unlock()
+
+	snapshot, err := c.sharedFetch()
+	if err != nil {
+		return snapshot, false, err
+	}
+
+	c.mu.Lock()
+	c.lastSnapshot = &snapshot
+	c.mu.Unlock()
+
+	return snapshot, false, nil
+}
+
+// sharedFetch coalesces the fetches of concurrent scrapes, for example from
+// several Prometheus servers, into a single fetch cycle against the director.
+// Streamed fetches are not coalesced, as their deployments are read while
+// collecting.
+func (c *BoshCollector) sharedFetch() (fetcher.Snapshot, error) {
+	snapshot, shared, err := c.singleFlight.Fetch(context.Background(), func() (fetcher.Snapshot, error) {
+		return c.boshFetcher.Fetch(context.Background())
+	})
+	if shared {
+		c.coalescedScrapesMetric.Inc()
+	}
+
+	return snapshot, err
+}
+
+func (c *BoshCollector) executeCollectors(span *tracing.Span, snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
 	var wg = &sync.WaitGroup{}
 
-	doneChannel := make(chan bool, 1)
-	errChannel := make(chan error, 1)
+	// Every collector and the deployments fan out can fail, so none of them
+	// blocks on reporting its error.
+	errChannel := make(chan error, len(c.enabledCollectors)+1)
 
-	for _, collector := range c.enabledCollectors {
+	middlewares := []Middleware{
+		TracingMiddleware(c.tracer, span),
+		LastSuccessMiddleware(c.collectorLastSuccessTimestampMetric),
+	}
+	if c.metricsTimestamps {
+		middlewares = append(middlewares, TimestampsMiddleware(c.metricsTimestampsMaxAge))
+	}
+	middlewares = append(middlewares, c.middlewares...)
+
+	var streams map[string]chan deployments.DeploymentInfo
+	if eachDeployment != nil {
+		streams = make(map[string]chan deployments.DeploymentInfo, len(c.enabledCollectors))
+		for name := range c.enabledCollectors {
+			streams[name] = make(chan deployments.DeploymentInfo)
+		}
 		wg.Add(1)
-		go func(collector Collector) {
+		go func() {
 			defer wg.Done()
-			if err := collector.Collect(deployments, ch); err != nil {
+			if err := fanOutDeployments(eachDeployment, streams); err != nil {
 				errChannel <- err
 			}
-		}(collector)
+		}()
 	}
 
+	for name, collector := range c.enabledCollectors {
+		wg.Add(1)
+		go func(name string, collect CollectFunc) {
+			defer wg.Done()
+			var err error
+			if streams != nil {
+				err = collectStream(collect, snapshot, streams[name], ch)
+			} else {
+				err = collect(snapshot, nil, ch)
+			}
+			if err != nil {
+				errChannel <- err
+			}
+		}(name, Pipeline(name, collector, middlewares...))
+	}
+
+	// The collectors write to ch until they return, so wait for all of them
+	// before reporting the first error.
+	wg.Wait()
+	close(errChannel)
+
+	return <-errChannel
+}
+
+// executeLimitedCollectors buffers the collectors metrics when a max series is
+// set, aggregating instance metrics by instance group once it is exceeded.
+func (c *BoshCollector) executeLimitedCollectors(span *tracing.Span, snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
+	if c.maxSeries <= 0 {
+		return c.executeCollectors(span, snapshot, eachDeployment, ch)
+	}
+
+	var metrics []prometheus.Metric
+	var metricsMu sync.Mutex
+	bufferedChannel := make(chan prometheus.Metric)
+	bufferedDone := make(chan bool)
 	go func() {
-		wg.Wait()
-		close(doneChannel)
+		for metric := range bufferedChannel {
+			metricsMu.Lock()
+			metrics = append(metrics, metric)
+			metricsMu.Unlock()
+		}
+		close(bufferedDone)
 	}()
 
-	select {
-	case <-doneChannel:
-	case err := <-errChannel:
-		return err
+	// executeCollectors returns once every collector has ended, even on
+	// errors, so nothing writes to the buffered channel anymore.
+	err := c.executeCollectors(span, snapshot, eachDeployment, bufferedChannel)
+	close(bufferedChannel)
+	<-bufferedDone
+
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	c.collectedSeriesMetric.Set(float64(len(metrics)))
+	if len(metrics) > c.maxSeries {
+		metrics = aggregateInstanceGroups(metrics)
+		log.Warnf("Collected more than %d series, aggregating instance metrics by instance group into %d series", c.maxSeries, len(metrics))
+		c.cardinalityLimitedMetric.Set(1)
+	} else {
+		c.cardinalityLimitedMetric.Set(0)
+	}
+
+	for _, metric := range metrics {
+		ch <- metric
 	}
 
-	return nil
+	return err
+}
+
+// fanOutDeployments iterates over the deployments once, handing each of them
+// to every collector stream in turn before reading the next one.
+func fanOutDeployments(eachDeployment fetcher.DeploymentsIterator, streams map[string]chan deployments.DeploymentInfo) error {
+	defer func() {
+		for _, stream := range streams {
+			close(stream)
+		}
+	}()
+
+	return eachDeployment(func(deployment deployments.DeploymentInfo) error {
+		for _, stream := range streams {
+			stream <- deployment
+		}
+		return nil
+	})
+}
+
+// collectStream runs a collector over a stream of deployments, draining it
+// when the collector returns early so the other collectors are not blocked.
+func collectStream(collect CollectFunc, snapshot fetcher.Snapshot, stream <-chan deployments.DeploymentInfo, ch chan<- prometheus.Metric) error {
+	defer func() {
+		for range stream {
+		}
+	}()
+
+	eachDeployment := func(fn func(deployments.DeploymentInfo) error) error {
+		for deployment := range stream {
+			if err := fn(deployment); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return collect(snapshot, eachDeployment, ch)
+}
+
+// reportDeploymentsVisibility compares the visible deployments with the ones
+// referenced by recent tasks, as the Director does not report how many
+// deployments exist beyond the credentials teams. The hidden deployments are
+// not reported when the recent tasks were not read.
+func (c *BoshCollector) reportDeploymentsVisibility(snapshot fetcher.Snapshot, ch chan<- prometheus.Metric) {
+	c.visibleDeploymentsMetric.Set(float64(len(snapshot.VisibleDeployments)))
+	c.visibleDeploymentsMetric.Collect(ch)
+
+	if snapshot.Tasks == nil {
+		return
+	}
+	hiddenDeployments := hiddenDeployments(snapshot.VisibleDeployments, snapshot.Tasks)
+
+	c.hiddenDeploymentsMetric.Set(float64(len(hiddenDeployments)))
+	c.hiddenDeploymentsMetric.Collect(ch)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if names := strings.Join(hiddenDeployments, ", "); names != c.lastHiddenDeployments {
+		if names != "" {
+			log.Warnf("BOSH deployments referenced by recent tasks are not visible to the exporter credentials: %s", names)
+		}
+		c.lastHiddenDeployments = names
+	}
+}
+
+func hiddenDeployments(visibleDeployments []string, tasks []deployments.Task) []string {
+	visible := map[string]bool{}
+	for _, deployment := range visibleDeployments {
+		visible[deployment] = true
+	}
+
+	lastTasks := map[string]deployments.Task{}
+	for _, task := range tasks {
+		if task.DeploymentName == "" || visible[task.DeploymentName] {
+			continue
+		}
+		if lastTask, ok := lastTasks[task.DeploymentName]; !ok || task.ID > lastTask.ID {
+			lastTasks[task.DeploymentName] = task
+		}
+	}
+
+	hidden := []string{}
+	for deployment, task := range lastTasks {
+		if strings.HasPrefix(task.Description, "delete deployment") {
+			continue
+		}
+		hidden = append(hidden, deployment)
+	}
+	sort.Strings(hidden)
+
+	return hidden
+}
+
+func (c *BoshCollector) publish(snapshot fetcher.Snapshot) {
+	for _, publisher := range c.snapshotPublishers {
+		if err := publisher.Publish(snapshot); err != nil {
+			log.Error(err)
+		}
+	}
 }
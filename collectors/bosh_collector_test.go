@@ -4,6 +4,9 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -11,10 +14,15 @@ import (
 	"github.com/cloudfoundry/bosh-cli/director"
 	"github.com/cloudfoundry/bosh-cli/director/directorfakes"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/log"
 
 	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
 	"github.com/bosh-prometheus/bosh_exporter/filters"
+	"github.com/bosh-prometheus/bosh_exporter/publishers"
+	"github.com/bosh-prometheus/bosh_exporter/sinks"
+	"github.com/bosh-prometheus/bosh_exporter/tracing"
 
 	. "github.com/bosh-prometheus/bosh_exporter/collectors"
 	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
@@ -24,31 +32,92 @@ func init() {
 	log.Base().SetLevel("fatal")
 }
 
+type fakePublisher struct {
+	snapshots chan fetcher.Snapshot
+}
+
+func (p *fakePublisher) Publish(snapshot fetcher.Snapshot) error {
+	p.snapshots <- snapshot
+	return errors.New("publish error")
+}
+
+type fakeTraceExporter struct {
+	spans []*tracing.Span
+}
+
+func (e *fakeTraceExporter) Export(resource map[string]string, spans []*tracing.Span) error {
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
 var _ = Describe("BoshCollector", func() {
 	var (
-		err                      error
-		namespace                string
-		environment              string
-		boshName                 string
-		boshUUID                 string
-		tmpfile                  *os.File
-		serviceDiscoveryFilename string
-
-		boshDeployments    []string
-		boshClient         *directorfakes.FakeDirector
-		deploymentsFilter  *filters.DeploymentsFilter
-		deploymentsFetcher *deployments.Fetcher
-		collectorsFilter   *filters.CollectorsFilter
-		azsFilter          *filters.AZsFilter
-		processesFilter    *filters.RegexpFilter
-		cidrsFilter        *filters.CidrFilter
-		boshCollector      *BoshCollector
+		err                               error
+		namespace                         string
+		environment                       string
+		boshName                          string
+		boshUUID                          string
+		stemcellVersionsThreshold         int
+		stemcellDaysThreshold             int
+		failedTasksWindow                 time.Duration
+		instanceAttributes                []string
+		persistentDiskGrowthWindow        time.Duration
+		sloObjectives                     SLOObjectives
+		sloWindow                         time.Duration
+		metricsTimestamps                 bool
+		metricsTimestampsMaxAge           time.Duration
+		errorMode                         string
+		maxSeries                         int
+		vitalsHistograms                  bool
+		kbSeries                          bool
+		processThresholds                 []ProcessThreshold
+		stoppedDeployments                string
+		streamDeployments                 bool
+		tracer                            *tracing.Tracer
+		eventRecorder                     EventRecorder
+		tmpfile                           *os.File
+		serviceDiscoveryFilename          string
+		serviceDiscoveryTmpDir            string
+		serviceDiscoveryFsync             bool
+		serviceDiscoveryKeepBackups       int
+		serviceDiscoveryRefuseEmptyOutput bool
+		serviceDiscoverySinks             []sinks.Sink
+		serviceDiscoveryMetadata          bool
+		serviceDiscoverySigningKey        []byte
+		serviceDiscoveryTargetTTL         time.Duration
+		sdInstanceAttributes              []string
+		sdProcessPorts                    map[string]int
+		sdSkippedInstancesLogInterval     time.Duration
+		snapshotPublishers                []publishers.Publisher
+		pauseWindows                      fetcher.PauseWindows
+
+		boshDeployments           []string
+		boshClient                *directorfakes.FakeDirector
+		deploymentsFilter         *filters.DeploymentsFilter
+		deploymentsFetcher        *deployments.Fetcher
+		boshFetcher               *fetcher.Fetcher
+		collectorsFilter          *filters.CollectorsFilter
+		azsFilter                 *filters.AZsFilter
+		processesFilter           *filters.RegexpFilter
+		deploymentProcessesFilter *filters.DeploymentProcessesFilter
+		expressionFilter          *filters.ExpressionFilter
+		cidrsFilter               *filters.CidrFilter
+		boshCollector             *BoshCollector
 
 		totalBoshScrapesMetric              prometheus.Counter
+		coalescedScrapesMetric              prometheus.Counter
 		totalBoshScrapeErrorsMetric         prometheus.Counter
 		lastBoshScrapeErrorMetric           prometheus.Gauge
 		lastBoshScrapeTimestampMetric       prometheus.Gauge
 		lastBoshScrapeDurationSecondsMetric prometheus.Gauge
+		collectorLastSuccessTimestampMetric *prometheus.GaugeVec
+		scrapePausedMetric                  prometheus.Gauge
+		visibleDeploymentsMetric            prometheus.Gauge
+		hiddenDeploymentsMetric             prometheus.Gauge
+		upMetric                            prometheus.Gauge
+		collectionErrorsMetric              *prometheus.CounterVec
+		collectedSeriesMetric               prometheus.Gauge
+		cardinalityLimitedMetric            prometheus.Gauge
 	)
 
 	BeforeEach(func() {
@@ -59,11 +128,45 @@ var _ = Describe("BoshCollector", func() {
 		tmpfile, err = ioutil.TempFile("", "service_discovery_collector_test_")
 		Expect(err).ToNot(HaveOccurred())
 		serviceDiscoveryFilename = tmpfile.Name()
+		serviceDiscoveryTmpDir = ""
+		serviceDiscoveryFsync = true
+		serviceDiscoveryKeepBackups = 0
+		serviceDiscoveryRefuseEmptyOutput = false
+		serviceDiscoverySinks = []sinks.Sink{}
+		serviceDiscoveryMetadata = false
+		serviceDiscoverySigningKey = nil
+		serviceDiscoveryTargetTTL = 0
+		stemcellVersionsThreshold = 0
+		stemcellDaysThreshold = 0
+		failedTasksWindow = 24 * time.Hour
+		instanceAttributes = []string{}
+		persistentDiskGrowthWindow = 6 * time.Hour
+		sloObjectives = SLOObjectives{}
+		sloWindow = 24 * time.Hour
+		sdInstanceAttributes = []string{}
+		sdProcessPorts = map[string]int{}
+		sdSkippedInstancesLogInterval = 0
+		snapshotPublishers = []publishers.Publisher{}
+		pauseWindows = fetcher.PauseWindows{}
+		metricsTimestamps = false
+		metricsTimestampsMaxAge = 5 * time.Minute
+		errorMode = ErrorModeDegraded
+		maxSeries = 0
+		vitalsHistograms = false
+		kbSeries = true
+		processThresholds = nil
+		stoppedDeployments = StoppedDeploymentsInclude
+		streamDeployments = false
+		tracer = nil
+		eventRecorder = nil
 
 		boshDeployments = []string{}
 		boshClient = &directorfakes.FakeDirector{}
+		expressionFilter, err = filters.NewExpressionFilter("")
+		Expect(err).ToNot(HaveOccurred())
 		deploymentsFilter = filters.NewDeploymentsFilter(boshDeployments, boshClient)
-		deploymentsFetcher = deployments.NewFetcher(*deploymentsFilter)
+		deploymentsFetcher = deployments.NewFetcher(*deploymentsFilter, expressionFilter, boshClient, 0, false)
+		boshFetcher = fetcher.NewFetcher(deploymentsFetcher, boshClient)
 		collectorsFilter, err = filters.NewCollectorsFilter([]string{})
 		Expect(err).ToNot(HaveOccurred())
 		azsFilter = filters.NewAZsFilter([]string{})
@@ -71,6 +174,8 @@ var _ = Describe("BoshCollector", func() {
 		Expect(err).ToNot(HaveOccurred())
 		processesFilter, err = filters.NewRegexpFilter([]string{})
 		Expect(err).ToNot(HaveOccurred())
+		deploymentProcessesFilter, err = filters.NewDeploymentProcessesFilter("")
+		Expect(err).ToNot(HaveOccurred())
 
 		totalBoshScrapesMetric = prometheus.NewCounter(
 			prometheus.CounterOpts{
@@ -88,6 +193,20 @@ var _ = Describe("BoshCollector", func() {
 
 		totalBoshScrapesMetric.Inc()
 
+		coalescedScrapesMetric = prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "",
+				Name:      "coalesced_scrapes_total",
+				Help:      "Total number of scrapes that reused the BOSH Director fetch of a concurrent scrape instead of starting their own.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
+
 		totalBoshScrapeErrorsMetric = prometheus.NewCounter(
 			prometheus.CounterOpts{
 				Namespace: namespace,
@@ -145,6 +264,122 @@ var _ = Describe("BoshCollector", func() {
 				},
 			},
 		)
+
+		collectorLastSuccessTimestampMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "collector_last_success_timestamp_seconds",
+				Help:      "Number of seconds since 1970 since the last successful run of a collector.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"collector"},
+		)
+
+		scrapePausedMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "",
+				Name:      "scrape_paused",
+				Help:      "Whether BOSH fetching is paused by a pause window and cached data is served (1 for paused, 0 for not paused).",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
+
+		visibleDeploymentsMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "visible_deployments",
+				Help:      "Number of BOSH deployments visible to the exporter credentials, before filtering.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
+
+		hiddenDeploymentsMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "hidden_deployments",
+				Help:      "Number of BOSH deployments referenced by recent tasks but not visible to the exporter credentials.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
+
+		upMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "up",
+				Help:      "Whether the last collection could fetch the BOSH Director (1 for up, 0 for down).",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
+
+		upMetric.Set(float64(1))
+
+		collectionErrorsMetric = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "collection_errors_total",
+				Help:      "Total number of BOSH collection errors, by error class.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"class"},
+		)
+
+		collectedSeriesMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "collected_series",
+				Help:      "Number of series returned by the collectors during the last collection, before any cardinality limit.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
+
+		cardinalityLimitedMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "cardinality_limited",
+				Help:      "Whether the last collection exceeded the max series and instance metrics were aggregated by instance group (1 for limited, 0 for not limited).",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
 	})
 
 	AfterEach(func() {
@@ -158,11 +393,48 @@ var _ = Describe("BoshCollector", func() {
 			environment,
 			boshName,
 			boshUUID,
+			stemcellVersionsThreshold,
+			stemcellDaysThreshold,
+			failedTasksWindow,
+			instanceAttributes,
+			persistentDiskGrowthWindow,
+			sloObjectives,
+			sloWindow,
+			metricsTimestamps,
+			metricsTimestampsMaxAge,
+			errorMode,
+			maxSeries,
+			vitalsHistograms,
+			kbSeries,
+			processThresholds,
+			stoppedDeployments,
+			streamDeployments,
+			tracer,
+			eventRecorder,
 			serviceDiscoveryFilename,
-			deploymentsFetcher,
+			serviceDiscoveryTmpDir,
+			serviceDiscoveryFsync,
+			serviceDiscoveryKeepBackups,
+			serviceDiscoveryRefuseEmptyOutput,
+			serviceDiscoverySinks,
+			serviceDiscoveryMetadata,
+			serviceDiscoverySigningKey,
+			serviceDiscoveryTargetTTL,
+			time.Duration(0),
+			sdInstanceAttributes,
+			sdProcessPorts,
+			nil,
+			ServiceDiscoveryTargetModeProcess,
+			sdSkippedInstancesLogInterval,
+			nil,
+			snapshotPublishers,
+			pauseWindows,
+			boshFetcher,
 			collectorsFilter,
 			azsFilter,
 			processesFilter,
+			deploymentProcessesFilter,
+			expressionFilter,
 			cidrsFilter,
 		)
 	})
@@ -184,6 +456,10 @@ var _ = Describe("BoshCollector", func() {
 			Eventually(descriptions).Should(Receive(Equal(totalBoshScrapesMetric.Desc())))
 		})
 
+		It("returns a coalesced_scrapes_total description", func() {
+			Eventually(descriptions).Should(Receive(Equal(coalescedScrapesMetric.Desc())))
+		})
+
 		It("returns a scrape_errors_total description", func() {
 			Eventually(descriptions).Should(Receive(Equal(totalBoshScrapeErrorsMetric.Desc())))
 		})
@@ -199,11 +475,65 @@ var _ = Describe("BoshCollector", func() {
 		It("returns a last_scrape_duration_seconds metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(lastBoshScrapeDurationSecondsMetric.Desc())))
 		})
+
+		It("returns an exporter_collector_last_success_timestamp_seconds metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(collectorLastSuccessTimestampMetric.WithLabelValues("Tasks").Desc())))
+		})
+
+		It("returns an exporter_visible_deployments metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(visibleDeploymentsMetric.Desc())))
+		})
+
+		It("returns an exporter_hidden_deployments metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(hiddenDeploymentsMetric.Desc())))
+		})
+
+		It("returns an exporter_up metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(upMetric.Desc())))
+		})
+
+		It("returns an exporter_collection_errors_total metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(collectionErrorsMetric.WithLabelValues("unknown").Desc())))
+		})
+
+		It("returns an exporter_collected_series metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(collectedSeriesMetric.Desc())))
+		})
+
+		It("returns an exporter_cardinality_limited metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(cardinalityLimitedMetric.Desc())))
+		})
+
+		It("returns a scrape_paused metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(scrapePausedMetric.Desc())))
+		})
 	})
 
 	Describe("Collect", func() {
 		var (
 			metrics chan prometheus.Metric
+
+			collectorLastSuccessTimestamps = func() map[string]float64 {
+				timestamps := map[string]float64{}
+				desc := collectorLastSuccessTimestampMetric.WithLabelValues("").Desc()
+				for {
+					select {
+					case metric := <-metrics:
+						if metric.Desc().String() != desc.String() {
+							continue
+						}
+						dtoMetric := &dto.Metric{}
+						metric.Write(dtoMetric)
+						for _, label := range dtoMetric.GetLabel() {
+							if label.GetName() == "collector" {
+								timestamps[label.GetValue()] = dtoMetric.GetGauge().GetValue()
+							}
+						}
+					case <-time.After(500 * time.Millisecond):
+						return timestamps
+					}
+				}
+			}
 		)
 
 		BeforeEach(func() {
@@ -218,6 +548,10 @@ var _ = Describe("BoshCollector", func() {
 			Eventually(metrics).Should(Receive(PrometheusMetric(totalBoshScrapesMetric)))
 		})
 
+		It("returns a coalesced_scrapes_total metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(coalescedScrapesMetric)))
+		})
+
 		It("returns a scrape_errors_total metric", func() {
 			Eventually(metrics).Should(Receive(PrometheusMetric(totalBoshScrapeErrorsMetric)))
 		})
@@ -226,12 +560,318 @@ var _ = Describe("BoshCollector", func() {
 			Eventually(metrics).Should(Receive(PrometheusMetric(lastBoshScrapeErrorMetric)))
 		})
 
+		It("returns an exporter_collector_last_success_timestamp_seconds metric for every collector", func() {
+			timestamps := collectorLastSuccessTimestamps()
+			Expect(timestamps).To(HaveLen(4))
+			for _, collector := range []string{"Deployments", "Jobs", "ServiceDiscovery", "Tasks"} {
+				Expect(timestamps).To(HaveKeyWithValue(collector, BeNumerically("~", float64(time.Now().Unix()), 5)))
+			}
+		})
+
+		It("returns a scrape_paused metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(scrapePausedMetric)))
+		})
+
+		It("returns an exporter_up metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(upMetric)))
+		})
+
+		It("returns an exporter_visible_deployments metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(visibleDeploymentsMetric)))
+		})
+
+		Context("when recent tasks reference deployments that are not visible", func() {
+			BeforeEach(func() {
+				boshClient.RecentTasksReturns([]director.Task{
+					&directorfakes.FakeTask{
+						IDStub:             func() int { return 1 },
+						DeploymentNameStub: func() string { return "hidden-deployment" },
+						DescriptionStub:    func() string { return "create deployment" },
+					},
+					&directorfakes.FakeTask{
+						IDStub:             func() int { return 2 },
+						DeploymentNameStub: func() string { return "deleted-deployment" },
+						DescriptionStub:    func() string { return "create deployment" },
+					},
+					&directorfakes.FakeTask{
+						IDStub:             func() int { return 3 },
+						DeploymentNameStub: func() string { return "deleted-deployment" },
+						DescriptionStub:    func() string { return "delete deployment deleted-deployment" },
+					},
+				}, nil)
+			})
+
+			It("returns an exporter_hidden_deployments metric ignoring deleted deployments", func() {
+				hiddenDeploymentsMetric.Set(1)
+				Eventually(metrics).Should(Receive(PrometheusMetric(hiddenDeploymentsMetric)))
+			})
+		})
+
+		Context("when a max series is set", func() {
+			var (
+				jobHealthyMetrics = func() []*dto.Metric {
+					healthyMetrics := []*dto.Metric{}
+					for {
+						select {
+						case metric := <-metrics:
+							if !strings.Contains(metric.Desc().String(), `"test_exporter_job_healthy"`) {
+								continue
+							}
+							dtoMetric := &dto.Metric{}
+							metric.Write(dtoMetric)
+							healthyMetrics = append(healthyMetrics, dtoMetric)
+						case <-time.After(500 * time.Millisecond):
+							return healthyMetrics
+						}
+					}
+				}
+			)
+
+			BeforeEach(func() {
+				indexes := []int{0, 1}
+				boshClient.DeploymentsReturns([]director.Deployment{
+					&directorfakes.FakeDeployment{
+						NameStub: func() string { return "cf" },
+						InstanceInfosStub: func() ([]director.VMInfo, error) {
+							return []director.VMInfo{
+								{JobName: "router", ID: "router-0", VMID: "vm-0", Index: &indexes[0], AZ: "z1", IPs: []string{"10.0.0.1"}, ProcessState: "running", Processes: []director.VMInfoProcess{{Name: "gorouter", State: "running"}}},
+								{JobName: "router", ID: "router-1", VMID: "vm-1", Index: &indexes[1], AZ: "z2", IPs: []string{"10.0.0.2"}, ProcessState: "failing"},
+							}, nil
+						},
+					},
+				}, nil)
+				maxSeries = 1000
+			})
+
+			It("returns the instances metrics", func() {
+				Expect(jobHealthyMetrics()).To(HaveLen(2))
+			})
+
+			It("returns an exporter_cardinality_limited metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(cardinalityLimitedMetric)))
+			})
+
+			Context("and it is exceeded", func() {
+				BeforeEach(func() {
+					maxSeries = 1
+					cardinalityLimitedMetric.Set(1)
+				})
+
+				It("aggregates the instances metrics by instance group", func() {
+					healthyMetrics := jobHealthyMetrics()
+					Expect(healthyMetrics).To(HaveLen(1))
+					Expect(healthyMetrics[0].GetGauge().GetValue()).To(Equal(0.5))
+					for _, label := range healthyMetrics[0].GetLabel() {
+						switch label.GetName() {
+						case "bosh_job_name":
+							Expect(label.GetValue()).To(Equal("router"))
+						case "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip":
+							Expect(label.GetValue()).To(BeEmpty())
+						}
+					}
+				})
+
+				It("returns an exporter_cardinality_limited metric", func() {
+					Eventually(metrics).Should(Receive(PrometheusMetric(cardinalityLimitedMetric)))
+				})
+			})
+		})
+
+		Context("when a pause window is active", func() {
+			BeforeEach(func() {
+				pauseWindows, err = fetcher.ParsePauseWindows("* * * * * 1h")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			JustBeforeEach(func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(scrapePausedMetric)))
+				go boshCollector.Collect(metrics)
+			})
+
+			It("serves the last snapshot without querying BOSH", func() {
+				scrapePausedMetric.Set(1)
+				Eventually(metrics).Should(Receive(PrometheusMetric(scrapePausedMetric)))
+				Expect(boshClient.InfoCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when metrics timestamps are enabled", func() {
+			var (
+				timestampedMetrics = func() int {
+					timestamped := 0
+					for {
+						select {
+						case metric := <-metrics:
+							dtoMetric := &dto.Metric{}
+							metric.Write(dtoMetric)
+							if dtoMetric.TimestampMs != nil {
+								timestamped++
+							}
+						case <-time.After(500 * time.Millisecond):
+							return timestamped
+						}
+					}
+				}
+			)
+
+			BeforeEach(func() {
+				metricsTimestamps = true
+			})
+
+			It("attaches a timestamp to the collectors metrics", func() {
+				Expect(timestampedMetrics()).To(BeNumerically(">", 0))
+			})
+
+			Context("and the timestamp is older than the max age", func() {
+				BeforeEach(func() {
+					metricsTimestampsMaxAge = time.Nanosecond
+				})
+
+				It("does not attach a timestamp to the metrics", func() {
+					Expect(timestampedMetrics()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when publishers are configured", func() {
+			var (
+				publisher *fakePublisher
+			)
+
+			BeforeEach(func() {
+				publisher = &fakePublisher{snapshots: make(chan fetcher.Snapshot, 1)}
+				snapshotPublishers = []publishers.Publisher{publisher}
+			})
+
+			It("publishes the snapshot", func() {
+				go func() {
+					for range metrics {
+					}
+				}()
+
+				var snapshot fetcher.Snapshot
+				Eventually(publisher.snapshots).Should(Receive(&snapshot))
+				Expect(snapshot.FetchedAt).ToNot(BeZero())
+			})
+
+			It("does not report publishing errors as scrape errors", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(lastBoshScrapeErrorMetric)))
+			})
+		})
+
+		Context("when intentionally stopped deployments are excluded", func() {
+			var (
+				publisher *fakePublisher
+			)
+
+			BeforeEach(func() {
+				index := 0
+				boshClient.DeploymentsReturns([]director.Deployment{
+					&directorfakes.FakeDeployment{
+						NameStub: func() string { return "stopped" },
+						InstanceInfosStub: func() ([]director.VMInfo, error) {
+							return []director.VMInfo{
+								{JobName: "router", ID: "router-0", VMID: "vm-0", Index: &index, State: "stopped", ProcessState: "stopped"},
+							}, nil
+						},
+					},
+					&directorfakes.FakeDeployment{
+						NameStub: func() string { return "running" },
+						InstanceInfosStub: func() ([]director.VMInfo, error) {
+							return []director.VMInfo{
+								{JobName: "router", ID: "router-0", VMID: "vm-1", Index: &index, State: "started", ProcessState: "running"},
+							}, nil
+						},
+					},
+				}, nil)
+				stoppedDeployments = StoppedDeploymentsExclude
+				publisher = &fakePublisher{snapshots: make(chan fetcher.Snapshot, 1)}
+				snapshotPublishers = []publishers.Publisher{publisher}
+			})
+
+			It("does not report the stopped deployments", func() {
+				go func() {
+					for range metrics {
+					}
+				}()
+
+				var snapshot fetcher.Snapshot
+				Eventually(publisher.snapshots).Should(Receive(&snapshot))
+				Expect(snapshot.Deployments).To(HaveLen(1))
+				Expect(snapshot.Deployments[0].Name).To(Equal("running"))
+			})
+		})
+
+		Context("when the deployments are streamed", func() {
+			var (
+				cfDeployment    *directorfakes.FakeDeployment
+				redisDeployment *directorfakes.FakeDeployment
+			)
+
+			BeforeEach(func() {
+				index := 0
+				cfDeployment = &directorfakes.FakeDeployment{
+					NameStub: func() string { return "cf" },
+					InstanceInfosStub: func() ([]director.VMInfo, error) {
+						return []director.VMInfo{
+							{JobName: "router", ID: "router-0", VMID: "vm-0", Index: &index, State: "started", ProcessState: "running"},
+						}, nil
+					},
+				}
+				redisDeployment = &directorfakes.FakeDeployment{
+					NameStub: func() string { return "redis" },
+					InstanceInfosStub: func() ([]director.VMInfo, error) {
+						return []director.VMInfo{
+							{JobName: "redis", ID: "redis-0", VMID: "vm-1", Index: &index, State: "started", ProcessState: "running"},
+						}, nil
+					},
+				}
+				boshClient.DeploymentsReturns([]director.Deployment{cfDeployment, redisDeployment}, nil)
+				streamDeployments = true
+			})
+
+			It("runs every collector", func() {
+				timestamps := collectorLastSuccessTimestamps()
+				Expect(timestamps).To(HaveLen(4))
+			})
+
+			It("fetches every deployment once for all the collectors", func() {
+				collectorLastSuccessTimestamps()
+				Expect(cfDeployment.InstanceInfosCallCount()).To(Equal(1))
+				Expect(redisDeployment.InstanceInfosCallCount()).To(Equal(1))
+			})
+
+			Context("and publishers are configured", func() {
+				var (
+					publisher *fakePublisher
+				)
+
+				BeforeEach(func() {
+					publisher = &fakePublisher{snapshots: make(chan fetcher.Snapshot, 1)}
+					snapshotPublishers = []publishers.Publisher{publisher}
+				})
+
+				It("publishes the whole snapshot", func() {
+					go func() {
+						for range metrics {
+						}
+					}()
+
+					var snapshot fetcher.Snapshot
+					Eventually(publisher.snapshots).Should(Receive(&snapshot))
+					Expect(snapshot.Deployments).To(HaveLen(2))
+				})
+			})
+		})
+
 		Context("when it fails to get the deployment", func() {
 			BeforeEach(func() {
 				boshClient.DeploymentsReturns([]director.Deployment{}, errors.New("no deployments"))
 
 				totalBoshScrapeErrorsMetric.Inc()
 				lastBoshScrapeErrorMetric.Set(float64(1))
+				upMetric.Set(float64(0))
+				collectionErrorsMetric.WithLabelValues("unknown").Inc()
 			})
 
 			It("returns a scrape_errors_total metric", func() {
@@ -241,6 +881,254 @@ var _ = Describe("BoshCollector", func() {
 			It("returns a last_scrape_error metric", func() {
 				Eventually(metrics).Should(Receive(PrometheusMetric(lastBoshScrapeErrorMetric)))
 			})
+
+			It("does not return an exporter_collector_last_success_timestamp_seconds metric", func() {
+				Expect(collectorLastSuccessTimestamps()).To(BeEmpty())
+			})
+
+			It("returns an exporter_up metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(upMetric)))
+			})
+
+			It("returns an exporter_collection_errors_total metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(collectionErrorsMetric.WithLabelValues("unknown"))))
+			})
+
+			It("does not return an invalid metric", func() {
+				Consistently(func() error {
+					select {
+					case metric := <-metrics:
+						return metric.Write(&dto.Metric{})
+					case <-time.After(10 * time.Millisecond):
+						return nil
+					}
+				}, 500*time.Millisecond).Should(Succeed())
+			})
+
+			Context("and an event recorder is set", func() {
+				var recorder *fakeEventRecorder
+
+				BeforeEach(func() {
+					recorder = &fakeEventRecorder{}
+					eventRecorder = recorder
+				})
+
+				It("does not record unclassified errors", func() {
+					Eventually(metrics).Should(Receive(PrometheusMetric(upMetric)))
+					Expect(recorder.Reasons()).To(BeEmpty())
+				})
+			})
+
+			Context("and the director is unreachable", func() {
+				var recorder *fakeEventRecorder
+
+				BeforeEach(func() {
+					boshClient.DeploymentsReturns([]director.Deployment{}, errors.New("dial tcp 127.0.0.1:25555: connect: connection refused"))
+					recorder = &fakeEventRecorder{}
+					eventRecorder = recorder
+				})
+
+				It("records the director as unreachable", func() {
+					Eventually(recorder.Reasons).Should(Equal([]string{"test_environment/director Warning DirectorUnreachable"}))
+				})
+			})
+
+			Context("and the error mode is strict", func() {
+				BeforeEach(func() {
+					errorMode = ErrorModeStrict
+				})
+
+				It("returns an invalid metric", func() {
+					Eventually(func() error {
+						select {
+						case metric := <-metrics:
+							return metric.Write(&dto.Metric{})
+						case <-time.After(10 * time.Millisecond):
+							return nil
+						}
+					}).Should(MatchError(ContainSubstring("no deployments")))
+				})
+			})
+		})
+	})
+
+	Describe("ScanDeploymentProblems", func() {
+		It("returns an error when the deployment is not watched", func() {
+			boshClient.DeploymentsReturns([]director.Deployment{}, nil)
+			Expect(boshCollector.ScanDeploymentProblems("fake-deployment-name")).To(Equal(deployments.ErrDeploymentNotFound))
+		})
+	})
+
+	Describe("PreviewServiceDiscovery", func() {
+		It("does not write the Service Discovery output", func() {
+			Expect(ioutil.WriteFile(serviceDiscoveryFilename, []byte(`[{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-name"}}]`), 0644)).To(Succeed())
+
+			diff, err := boshCollector.PreviewServiceDiscovery(nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diff.Removed).To(HaveLen(1))
+			Expect(boshClient.InfoCallCount()).To(Equal(1))
+
+			targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(targetGroups)).To(ContainSubstring("1.2.3.4"))
+		})
+
+		Context("when the Service Discovery collector is not enabled", func() {
+			BeforeEach(func() {
+				collectorsFilter, err = filters.NewCollectorsFilter([]string{filters.JobsCollector})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns an error", func() {
+				_, err := boshCollector.PreviewServiceDiscovery(nil)
+				Expect(err).To(MatchError(ContainSubstring("not enabled")))
+			})
+		})
+	})
+
+	Describe("Refresh", func() {
+		It("queries BOSH", func() {
+			Expect(boshCollector.Refresh()).To(Succeed())
+			Expect(boshClient.InfoCallCount()).To(Equal(1))
+		})
+
+		It("runs every collector through the middlewares in use", func() {
+			collected := map[string]bool{}
+			collectedMu := &sync.Mutex{}
+			boshCollector.Use(func(name string, next CollectFunc) CollectFunc {
+				return func(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
+					collectedMu.Lock()
+					collected[name] = true
+					collectedMu.Unlock()
+					return next(snapshot, eachDeployment, ch)
+				}
+			})
+
+			Expect(boshCollector.Refresh()).To(Succeed())
+			Expect(collected).To(Equal(map[string]bool{"Deployments": true, "Jobs": true, "ServiceDiscovery": true, "Tasks": true}))
+		})
+
+		Context("when tracing is enabled", func() {
+			var (
+				traceExporter *fakeTraceExporter
+			)
+
+			BeforeEach(func() {
+				traceExporter = &fakeTraceExporter{}
+				tracer = tracing.NewTracer(traceExporter, map[string]string{})
+			})
+
+			It("traces the fetch and every collector", func() {
+				Expect(boshCollector.Refresh()).To(Succeed())
+				Expect(tracer.Flush()).To(Succeed())
+
+				spans := map[string]*tracing.Span{}
+				for _, span := range traceExporter.spans {
+					spans[span.Name] = span
+				}
+				Expect(spans).To(HaveLen(6))
+				Expect(spans["bosh.fetch"].ParentSpanID).To(Equal(spans["bosh.collect"].SpanID))
+				for _, collector := range []string{"Deployments", "Jobs", "ServiceDiscovery", "Tasks"} {
+					Expect(spans).To(HaveKey("bosh.collector." + collector))
+					Expect(spans["bosh.collector."+collector].ParentSpanID).To(Equal(spans["bosh.collect"].SpanID))
+				}
+			})
+		})
+
+		Context("when another scrape is fetching BOSH", func() {
+			var (
+				fetching chan bool
+				release  chan bool
+			)
+
+			BeforeEach(func() {
+				fetching = make(chan bool, 2)
+				release = make(chan bool)
+				boshClient.InfoStub = func() (director.Info, error) {
+					fetching <- true
+					<-release
+					return director.Info{}, nil
+				}
+			})
+
+			It("reuses its snapshot instead of querying BOSH again", func() {
+				refreshed := make(chan error, 2)
+				go func() { refreshed <- boshCollector.Refresh() }()
+				Eventually(fetching).Should(Receive())
+				go func() { refreshed <- boshCollector.Refresh() }()
+				Consistently(fetching).ShouldNot(Receive())
+				close(release)
+
+				Eventually(refreshed).Should(Receive(BeNil()))
+				Eventually(refreshed).Should(Receive(BeNil()))
+				Expect(boshClient.InfoCallCount()).To(Equal(1))
+
+				coalescedScrapesMetric.Inc()
+				metrics := make(chan prometheus.Metric)
+				go boshCollector.Collect(metrics)
+				Eventually(metrics).Should(Receive(PrometheusMetric(coalescedScrapesMetric)))
+			})
+		})
+
+		Context("when a pause window is active", func() {
+			BeforeEach(func() {
+				pauseWindows, err = fetcher.ParsePauseWindows("* * * * * 1h")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("queries BOSH anyway", func() {
+				Expect(boshCollector.Refresh()).To(Succeed())
+				Expect(boshCollector.Refresh()).To(Succeed())
+				Expect(boshClient.InfoCallCount()).To(Equal(2))
+			})
+		})
+
+		Context("when it fails to get the deployment", func() {
+			BeforeEach(func() {
+				boshClient.DeploymentsReturns([]director.Deployment{}, errors.New("no deployments"))
+			})
+
+			It("returns an error", func() {
+				Expect(boshCollector.Refresh()).ToNot(Succeed())
+			})
+		})
+
+		Context("when collectors fail", func() {
+			var (
+				returned   map[string]bool
+				returnedMu *sync.Mutex
+			)
+
+			BeforeEach(func() {
+				returned = map[string]bool{}
+				returnedMu = &sync.Mutex{}
+			})
+
+			JustBeforeEach(func() {
+				boshCollector.Use(func(name string, next CollectFunc) CollectFunc {
+					return func(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
+						var err error
+						if name == "Tasks" {
+							time.Sleep(50 * time.Millisecond)
+							err = next(snapshot, eachDeployment, ch)
+						} else {
+							err = errors.New(name + " failed")
+						}
+						returnedMu.Lock()
+						returned[name] = true
+						returnedMu.Unlock()
+						return err
+					}
+				})
+			})
+
+			It("returns an error once every collector has returned", func() {
+				Expect(boshCollector.Refresh()).ToNot(Succeed())
+
+				returnedMu.Lock()
+				defer returnedMu.Unlock()
+				Expect(returned).To(Equal(map[string]bool{"Deployments": true, "Jobs": true, "ServiceDiscovery": true, "Tasks": true}))
+			})
 		})
 	})
 })
@@ -0,0 +1,83 @@
+package collectors
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// instanceLabels are blanked when aggregating metrics by instance group, so the
+// aggregated series keep the descriptors registered for the instance series.
+var instanceLabels = map[string]bool{
+	"bosh_job_id":    true,
+	"bosh_job_index": true,
+	"bosh_job_az":    true,
+	"bosh_job_ip":    true,
+}
+
+type aggregatedMetric struct {
+	desc        *prometheus.Desc
+	labels      []*dto.LabelPair
+	timestampMs *int64
+	sum         float64
+	count       int
+}
+
+func (m *aggregatedMetric) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+func (m *aggregatedMetric) Write(out *dto.Metric) error {
+	out.Label = m.labels
+	out.Gauge = &dto.Gauge{Value: proto.Float64(m.sum / float64(m.count))}
+	out.TimestampMs = m.timestampMs
+	return nil
+}
+
+// aggregateInstanceGroups replaces the gauges of every instance by the average
+// of the instances of the same instance group.
+func aggregateInstanceGroups(metrics []prometheus.Metric) []prometheus.Metric {
+	aggregatedMetrics := []prometheus.Metric{}
+	groups := map[string]*aggregatedMetric{}
+
+	for _, metric := range metrics {
+		dtoMetric := &dto.Metric{}
+		if err := metric.Write(dtoMetric); err != nil || dtoMetric.Gauge == nil || !hasInstanceLabels(dtoMetric) {
+			aggregatedMetrics = append(aggregatedMetrics, metric)
+			continue
+		}
+
+		key := []string{metric.Desc().String()}
+		labels := []*dto.LabelPair{}
+		for _, label := range dtoMetric.GetLabel() {
+			value := label.GetValue()
+			if instanceLabels[label.GetName()] {
+				value = ""
+			}
+			labels = append(labels, &dto.LabelPair{Name: proto.String(label.GetName()), Value: proto.String(value)})
+			key = append(key, label.GetName()+"="+value)
+		}
+
+		group, ok := groups[strings.Join(key, "\xff")]
+		if !ok {
+			group = &aggregatedMetric{desc: metric.Desc(), labels: labels, timestampMs: dtoMetric.TimestampMs}
+			groups[strings.Join(key, "\xff")] = group
+			aggregatedMetrics = append(aggregatedMetrics, group)
+		}
+		group.sum += dtoMetric.GetGauge().GetValue()
+		group.count++
+	}
+
+	return aggregatedMetrics
+}
+
+func hasInstanceLabels(metric *dto.Metric) bool {
+	for _, label := range metric.GetLabel() {
+		if instanceLabels[label.GetName()] {
+			return true
+		}
+	}
+	return false
+}
@@ -3,10 +3,17 @@ package collectors
 import (
 	"github.com/prometheus/client_golang/prometheus"
 
-	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
 )
 
 type Collector interface {
-	Collect(deployments []deployments.DeploymentInfo, ch chan<- prometheus.Metric) error
+	Collect(snapshot fetcher.Snapshot, ch chan<- prometheus.Metric) error
 	Describe(ch chan<- *prometheus.Desc)
 }
+
+// StreamingCollector is a Collector able to read the deployments from an
+// iterator instead of the snapshot, so they are never all held in memory.
+type StreamingCollector interface {
+	Collector
+	CollectStream(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error
+}
@@ -0,0 +1,196 @@
+package collectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+	"github.com/bosh-prometheus/bosh_exporter/filters"
+)
+
+const (
+	benchmarkProcessesPerInstance = 10
+	benchmarkInstancesPerDeploy   = 100
+)
+
+var benchmarkSizes = []int{10000, 50000}
+
+func benchmarkDeployments(processes int) []deployments.DeploymentInfo {
+	deploymentsInfo := []deployments.DeploymentInfo{}
+
+	instances := processes / benchmarkProcessesPerInstance
+	for i := 0; i < instances; i++ {
+		if i%benchmarkInstancesPerDeploy == 0 {
+			deploymentsInfo = append(deploymentsInfo, deployments.DeploymentInfo{
+				Name: fmt.Sprintf("deployment-%d", len(deploymentsInfo)),
+			})
+		}
+
+		uptime := uint64(3600)
+		cpuTotal := float64(0.5)
+		memKB := uint64(2000)
+		memPercent := float64(20)
+
+		instance := deployments.Instance{
+			Name:    fmt.Sprintf("job-%d", i%benchmarkInstancesPerDeploy),
+			ID:      fmt.Sprintf("job-id-%d", i),
+			Index:   "0",
+			IPs:     []string{fmt.Sprintf("10.%d.%d.%d", i/65536%256, i/256%256, i%256)},
+			AZ:      fmt.Sprintf("z%d", i%3+1),
+			Healthy: true,
+			Vitals: deployments.Vitals{
+				CPU:    deployments.CPU{Sys: "0.5", User: "1.0", Wait: "1.5"},
+				Mem:    deployments.Mem{KB: "1000", Percent: "10"},
+				Swap:   deployments.Mem{KB: "2000", Percent: "20"},
+				Uptime: &uptime,
+				Load:   []string{"0.01", "0.05", "0.15"},
+			},
+		}
+		for p := 0; p < benchmarkProcessesPerInstance; p++ {
+			instance.Processes = append(instance.Processes, deployments.Process{
+				Name:    fmt.Sprintf("process-%d", p),
+				Uptime:  &uptime,
+				Healthy: true,
+				CPU:     deployments.CPU{Total: &cpuTotal},
+				Mem:     deployments.MemInt{KB: &memKB, Percent: &memPercent},
+			})
+		}
+
+		last := len(deploymentsInfo) - 1
+		deploymentsInfo[last].Instances = append(deploymentsInfo[last].Instances, instance)
+	}
+
+	return deploymentsInfo
+}
+
+func benchmarkServiceDiscoveryCollector(b *testing.B) *ServiceDiscoveryCollector {
+	processesFilter, err := filters.NewRegexpFilter([]string{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	cidrsFilter, err := filters.NewCidrFilter([]string{"0.0.0.0/0"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	deploymentProcessesFilter, err := filters.NewDeploymentProcessesFilter("")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return NewServiceDiscoveryCollector(
+		"bench_exporter",
+		"bench_environment",
+		"bench_bosh_name",
+		"bench_bosh_uuid",
+		"",
+		"",
+		true,
+		0,
+		false,
+		nil,
+		false,
+		nil,
+		0,
+		0,
+		nil,
+		nil,
+		nil,
+		ServiceDiscoveryTargetModeProcess,
+		0,
+		nil,
+		nil,
+		filters.NewAZsFilter([]string{}),
+		processesFilter,
+		deploymentProcessesFilter,
+		&filters.ExpressionFilter{},
+		cidrsFilter,
+	)
+}
+
+func BenchmarkCreateLabelGroups(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("processes=%d", size), func(b *testing.B) {
+			deploymentsInfo := benchmarkDeployments(size)
+			collector := benchmarkServiceDiscoveryCollector(b)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				collector.createLabelGroups(fetcher.Snapshot{Deployments: deploymentsInfo}.EachDeployment, time.Now())
+			}
+		})
+	}
+}
+
+func BenchmarkMarshalTargetGroups(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("processes=%d", size), func(b *testing.B) {
+			collector := benchmarkServiceDiscoveryCollector(b)
+			labelGroups, _ := collector.createLabelGroups(fetcher.Snapshot{Deployments: benchmarkDeployments(size)}.EachDeployment, time.Now())
+			targetGroups := collector.createTargetGroups(labelGroups)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(targetGroups); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkJobsCollectorCollect(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("processes=%d", size), func(b *testing.B) {
+			snapshot := fetcher.Snapshot{Deployments: benchmarkDeployments(size)}
+			cidrsFilter, err := filters.NewCidrFilter([]string{"0.0.0.0/0"})
+			if err != nil {
+				b.Fatal(err)
+			}
+			deploymentProcessesFilter, err := filters.NewDeploymentProcessesFilter("")
+			if err != nil {
+				b.Fatal(err)
+			}
+			collector := NewJobsCollector(
+				"bench_exporter",
+				"bench_environment",
+				"bench_bosh_name",
+				"bench_bosh_uuid",
+				nil,
+				0,
+				true,
+				false,
+				filters.NewAZsFilter([]string{}),
+				deploymentProcessesFilter,
+				&filters.ExpressionFilter{},
+				cidrsFilter,
+			)
+
+			metrics := make(chan prometheus.Metric, 1024)
+			done := make(chan struct{})
+			go func() {
+				for range metrics {
+				}
+				close(done)
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := collector.Collect(snapshot, metrics); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			close(metrics)
+			<-done
+		})
+	}
+}
@@ -1,17 +1,44 @@
 package collectors
 
 import (
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
 )
 
+var deploymentProblemTypes = []string{"unresponsive_agent", "missing_vm", "inactive_disk", "missing_disk", "mount_info_mismatch"}
+
 type DeploymentsCollector struct {
 	deploymentReleaseInfoMetric                *prometheus.GaugeVec
 	deploymentStemcellInfoMetric               *prometheus.GaugeVec
+	deploymentStemcellVersionsBehindMetric     *prometheus.GaugeVec
+	deploymentStemcellDaysBehindMetric         *prometheus.GaugeVec
+	deploymentStemcellOutdatedMetric           *prometheus.GaugeVec
+	deploymentConfigInfoMetric                 *prometheus.GaugeVec
+	deploymentConfigOutdatedMetric             *prometheus.GaugeVec
 	deploymentInstancesMetric                  *prometheus.GaugeVec
+	deploymentAZInstancesMetric                *prometheus.GaugeVec
+	deploymentAZHealthyInstancesMetric         *prometheus.GaugeVec
+	deploymentAZMemKBMetric                    *prometheus.GaugeVec
+	deploymentAZMemBytesMetric                 *prometheus.GaugeVec
+	deploymentAZPersistentDiskSizeBytesMetric  *prometheus.GaugeVec
+	deploymentProblemsMetric                   *prometheus.GaugeVec
+	sloObjectiveMetric                         *prometheus.GaugeVec
+	sloErrorBudgetRemainingMetric              *prometheus.GaugeVec
+	deploymentFetchSecondsMetric               *prometheus.GaugeVec
+	deploymentAPICallsDesc                     *prometheus.Desc
+	stemcellVersionsThreshold                  int
+	stemcellDaysThreshold                      int
+	sloObjectives                              SLOObjectives
+	sloWindow                                  time.Duration
+	kbSeries                                   bool
+	sloSamples                                 map[string][]sloSample
+	mu                                         *sync.Mutex
 	lastDeploymentsScrapeTimestampMetric       prometheus.Gauge
 	lastDeploymentsScrapeDurationSecondsMetric prometheus.Gauge
 }
@@ -21,6 +48,11 @@ func NewDeploymentsCollector(
 	environment string,
 	boshName string,
 	boshUUID string,
+	stemcellVersionsThreshold int,
+	stemcellDaysThreshold int,
+	sloObjectives SLOObjectives,
+	sloWindow time.Duration,
+	kbSeries bool,
 ) *DeploymentsCollector {
 	deploymentReleaseInfoMetric := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -52,6 +84,81 @@ func NewDeploymentsCollector(
 		[]string{"bosh_deployment", "bosh_stemcell_name", "bosh_stemcell_version", "bosh_stemcell_os_name"},
 	)
 
+	deploymentStemcellVersionsBehindMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deployment",
+			Name:      "stemcell_versions_behind",
+			Help:      "Number of uploaded versions of the BOSH Deployment Stemcell newer than the deployed one.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_stemcell_name", "bosh_stemcell_version", "bosh_stemcell_latest_version"},
+	)
+
+	deploymentStemcellDaysBehindMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deployment",
+			Name:      "stemcell_days_behind",
+			Help:      "Number of days since the first uploaded version of the BOSH Deployment Stemcell newer than the deployed one was uploaded.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_stemcell_name", "bosh_stemcell_version", "bosh_stemcell_latest_version"},
+	)
+
+	deploymentStemcellOutdatedMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deployment",
+			Name:      "stemcell_outdated",
+			Help:      "BOSH Deployment Stemcell is behind the latest uploaded version by more than the configured versions or days threshold (1 for outdated, 0 for up to date).",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_stemcell_name", "bosh_stemcell_version", "bosh_stemcell_latest_version"},
+	)
+
+	deploymentConfigInfoMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deployment",
+			Name:      "config_info",
+			Help:      "Labeled BOSH Deployment Config Info (cloud, runtime, cpi configs the deployment was last deployed with) with a constant '1' value.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_config_type", "bosh_config_name", "bosh_config_id"},
+	)
+
+	deploymentConfigOutdatedMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deployment",
+			Name:      "config_outdated",
+			Help:      "BOSH Deployment was last deployed with an older version of the config than the latest one (1 for outdated, 0 for up to date).",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_config_type", "bosh_config_name", "bosh_config_id", "bosh_config_latest_id"},
+	)
+
 	deploymentInstancesMetric := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -67,6 +174,152 @@ func NewDeploymentsCollector(
 		[]string{"bosh_deployment", "bosh_vm_type"},
 	)
 
+	deploymentAZInstancesMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deployment",
+			Name:      "az_instances",
+			Help:      "Number of instances in this deployment per AZ.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_az"},
+	)
+
+	deploymentAZHealthyInstancesMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deployment",
+			Name:      "az_healthy_instances",
+			Help:      "Number of healthy instances in this deployment per AZ.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_az"},
+	)
+
+	deploymentAZMemKBMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deployment",
+			Name:      "az_mem_kb",
+			Help:      "Sum of the memory KB used by the instances of this deployment per AZ.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_az"},
+	)
+
+	deploymentAZMemBytesMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deployment",
+			Name:      "az_mem_bytes",
+			Help:      "Sum of the memory in bytes used by the instances of this deployment per AZ.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_az"},
+	)
+
+	deploymentAZPersistentDiskSizeBytesMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deployment",
+			Name:      "az_persistent_disk_size_bytes",
+			Help:      "Sum of the persistent disk sizes in bytes of the instances of this deployment per AZ.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_az"},
+	)
+
+	deploymentProblemsMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "deployment",
+			Name:      "problems",
+			Help:      "Number of problems found by the last BOSH Director problem scan of this deployment, by type.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "type"},
+	)
+
+	sloObjectiveMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "slo",
+			Name:      "objective_ratio",
+			Help:      "Objective of the ratio of running processes in this deployment.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment"},
+	)
+
+	sloErrorBudgetRemainingMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "slo",
+			Name:      "error_budget_remaining_ratio",
+			Help:      "Ratio of the error budget of this deployment left over the SLO window (negative when exhausted).",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment"},
+	)
+
+	deploymentFetchSecondsMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "deployment_fetch_seconds",
+			Help:      "Duration in seconds of the last fetch of a BOSH Deployment from the BOSH Director.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment"},
+	)
+
+	deploymentAPICallsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "deployment_api_calls_total"),
+		"Total number of BOSH Director API calls sent to fetch a BOSH Deployment.",
+		[]string{"bosh_deployment"},
+		prometheus.Labels{
+			"environment": environment,
+			"bosh_name":   boshName,
+			"bosh_uuid":   boshUUID,
+		},
+	)
+
 	lastDeploymentsScrapeTimestampMetric := prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -98,29 +351,104 @@ func NewDeploymentsCollector(
 	collector := &DeploymentsCollector{
 		deploymentReleaseInfoMetric:                deploymentReleaseInfoMetric,
 		deploymentStemcellInfoMetric:               deploymentStemcellInfoMetric,
+		deploymentStemcellVersionsBehindMetric:     deploymentStemcellVersionsBehindMetric,
+		deploymentStemcellDaysBehindMetric:         deploymentStemcellDaysBehindMetric,
+		deploymentStemcellOutdatedMetric:           deploymentStemcellOutdatedMetric,
+		deploymentConfigInfoMetric:                 deploymentConfigInfoMetric,
+		deploymentConfigOutdatedMetric:             deploymentConfigOutdatedMetric,
 		deploymentInstancesMetric:                  deploymentInstancesMetric,
+		deploymentAZInstancesMetric:                deploymentAZInstancesMetric,
+		deploymentAZHealthyInstancesMetric:         deploymentAZHealthyInstancesMetric,
+		deploymentAZMemKBMetric:                    deploymentAZMemKBMetric,
+		deploymentAZMemBytesMetric:                 deploymentAZMemBytesMetric,
+		deploymentAZPersistentDiskSizeBytesMetric:  deploymentAZPersistentDiskSizeBytesMetric,
+		deploymentProblemsMetric:                   deploymentProblemsMetric,
+		sloObjectiveMetric:                         sloObjectiveMetric,
+		sloErrorBudgetRemainingMetric:              sloErrorBudgetRemainingMetric,
+		deploymentFetchSecondsMetric:               deploymentFetchSecondsMetric,
+		deploymentAPICallsDesc:                     deploymentAPICallsDesc,
+		stemcellVersionsThreshold:                  stemcellVersionsThreshold,
+		stemcellDaysThreshold:                      stemcellDaysThreshold,
+		sloObjectives:                              sloObjectives,
+		sloWindow:                                  sloWindow,
+		kbSeries:                                   kbSeries,
+		sloSamples:                                 map[string][]sloSample{},
+		mu:                                         &sync.Mutex{},
 		lastDeploymentsScrapeTimestampMetric:       lastDeploymentsScrapeTimestampMetric,
 		lastDeploymentsScrapeDurationSecondsMetric: lastDeploymentsScrapeDurationSecondsMetric,
 	}
 	return collector
 }
 
-func (c *DeploymentsCollector) Collect(deployments []deployments.DeploymentInfo, ch chan<- prometheus.Metric) error {
+func (c *DeploymentsCollector) Collect(snapshot fetcher.Snapshot, ch chan<- prometheus.Metric) error {
+	return c.CollectStream(snapshot, snapshot.EachDeployment, ch)
+}
+
+func (c *DeploymentsCollector) CollectStream(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
 	var begun = time.Now()
 
 	c.deploymentReleaseInfoMetric.Reset()
 	c.deploymentStemcellInfoMetric.Reset()
+	c.deploymentStemcellVersionsBehindMetric.Reset()
+	c.deploymentStemcellDaysBehindMetric.Reset()
+	c.deploymentStemcellOutdatedMetric.Reset()
+	c.deploymentConfigInfoMetric.Reset()
+	c.deploymentConfigOutdatedMetric.Reset()
 	c.deploymentInstancesMetric.Reset()
+	c.deploymentAZInstancesMetric.Reset()
+	c.deploymentAZHealthyInstancesMetric.Reset()
+	c.deploymentAZMemKBMetric.Reset()
+	c.deploymentAZMemBytesMetric.Reset()
+	c.deploymentAZPersistentDiskSizeBytesMetric.Reset()
+	c.deploymentProblemsMetric.Reset()
+	c.sloObjectiveMetric.Reset()
+	c.sloErrorBudgetRemainingMetric.Reset()
+	c.deploymentFetchSecondsMetric.Reset()
 
-	for _, deployment := range deployments {
+	fetchedAt := snapshot.FetchedAt
+	if fetchedAt.IsZero() {
+		fetchedAt = begun
+	}
+
+	c.mu.Lock()
+	seenDeployments := map[string]bool{}
+	streamErr := eachDeployment(func(deployment deployments.DeploymentInfo) error {
 		c.reportDeploymentReleaseInfoMetrics(deployment, ch)
 		c.reportDeploymentStemcellInfoMetrics(deployment, ch)
+		c.reportDeploymentStemcellVersionsMetrics(deployment, ch)
+		c.reportDeploymentConfigMetrics(deployment, ch)
 		c.reportDeploymentInstancesMetrics(deployment, ch)
+		c.reportDeploymentAZMetrics(deployment, ch)
+		c.reportDeploymentProblemsMetrics(deployment, ch)
+		c.reportDeploymentSLOMetrics(deployment, fetchedAt, ch)
+		c.reportDeploymentFetchMetrics(deployment, ch)
+		seenDeployments[deployment.Name] = true
+		return nil
+	})
+	for deploymentName := range c.sloSamples {
+		if !seenDeployments[deploymentName] {
+			delete(c.sloSamples, deploymentName)
+		}
 	}
+	c.mu.Unlock()
 
 	c.deploymentReleaseInfoMetric.Collect(ch)
 	c.deploymentStemcellInfoMetric.Collect(ch)
+	c.deploymentStemcellVersionsBehindMetric.Collect(ch)
+	c.deploymentStemcellDaysBehindMetric.Collect(ch)
+	c.deploymentStemcellOutdatedMetric.Collect(ch)
+	c.deploymentConfigInfoMetric.Collect(ch)
+	c.deploymentConfigOutdatedMetric.Collect(ch)
 	c.deploymentInstancesMetric.Collect(ch)
+	c.deploymentAZInstancesMetric.Collect(ch)
+	c.deploymentAZHealthyInstancesMetric.Collect(ch)
+	c.deploymentAZMemKBMetric.Collect(ch)
+	c.deploymentAZMemBytesMetric.Collect(ch)
+	c.deploymentAZPersistentDiskSizeBytesMetric.Collect(ch)
+	c.deploymentProblemsMetric.Collect(ch)
+	c.sloObjectiveMetric.Collect(ch)
+	c.sloErrorBudgetRemainingMetric.Collect(ch)
+	c.deploymentFetchSecondsMetric.Collect(ch)
 
 	c.lastDeploymentsScrapeTimestampMetric.Set(float64(time.Now().Unix()))
 	c.lastDeploymentsScrapeTimestampMetric.Collect(ch)
@@ -128,13 +456,28 @@ func (c *DeploymentsCollector) Collect(deployments []deployments.DeploymentInfo,
 	c.lastDeploymentsScrapeDurationSecondsMetric.Set(time.Since(begun).Seconds())
 	c.lastDeploymentsScrapeDurationSecondsMetric.Collect(ch)
 
-	return nil
+	return streamErr
 }
 
 func (c *DeploymentsCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.deploymentReleaseInfoMetric.Describe(ch)
 	c.deploymentStemcellInfoMetric.Describe(ch)
+	c.deploymentStemcellVersionsBehindMetric.Describe(ch)
+	c.deploymentStemcellDaysBehindMetric.Describe(ch)
+	c.deploymentStemcellOutdatedMetric.Describe(ch)
+	c.deploymentConfigInfoMetric.Describe(ch)
+	c.deploymentConfigOutdatedMetric.Describe(ch)
 	c.deploymentInstancesMetric.Describe(ch)
+	c.deploymentAZInstancesMetric.Describe(ch)
+	c.deploymentAZHealthyInstancesMetric.Describe(ch)
+	c.deploymentAZMemKBMetric.Describe(ch)
+	c.deploymentAZMemBytesMetric.Describe(ch)
+	c.deploymentAZPersistentDiskSizeBytesMetric.Describe(ch)
+	c.deploymentProblemsMetric.Describe(ch)
+	c.sloObjectiveMetric.Describe(ch)
+	c.sloErrorBudgetRemainingMetric.Describe(ch)
+	c.deploymentFetchSecondsMetric.Describe(ch)
+	ch <- c.deploymentAPICallsDesc
 	c.lastDeploymentsScrapeTimestampMetric.Describe(ch)
 	c.lastDeploymentsScrapeDurationSecondsMetric.Describe(ch)
 }
@@ -166,6 +509,75 @@ func (c *DeploymentsCollector) reportDeploymentStemcellInfoMetrics(
 	}
 }
 
+func (c *DeploymentsCollector) reportDeploymentStemcellVersionsMetrics(
+	deployment deployments.DeploymentInfo,
+	ch chan<- prometheus.Metric,
+) {
+	for _, stemcell := range deployment.Stemcells {
+		if stemcell.LatestVersion == "" {
+			continue
+		}
+
+		c.deploymentStemcellVersionsBehindMetric.WithLabelValues(
+			deployment.Name,
+			stemcell.Name,
+			stemcell.Version,
+			stemcell.LatestVersion,
+		).Set(float64(stemcell.VersionsBehind))
+
+		c.deploymentStemcellDaysBehindMetric.WithLabelValues(
+			deployment.Name,
+			stemcell.Name,
+			stemcell.Version,
+			stemcell.LatestVersion,
+		).Set(float64(stemcell.DaysBehind))
+
+		outdated := 0
+		if stemcell.VersionsBehind > c.stemcellVersionsThreshold {
+			outdated = 1
+		}
+		if c.stemcellDaysThreshold > 0 && stemcell.DaysBehind > c.stemcellDaysThreshold {
+			outdated = 1
+		}
+		c.deploymentStemcellOutdatedMetric.WithLabelValues(
+			deployment.Name,
+			stemcell.Name,
+			stemcell.Version,
+			stemcell.LatestVersion,
+		).Set(float64(outdated))
+	}
+}
+
+func (c *DeploymentsCollector) reportDeploymentConfigMetrics(
+	deployment deployments.DeploymentInfo,
+	ch chan<- prometheus.Metric,
+) {
+	for _, config := range deployment.Configs {
+		c.deploymentConfigInfoMetric.WithLabelValues(
+			deployment.Name,
+			config.Type,
+			config.Name,
+			config.ID,
+		).Set(float64(1))
+
+		if config.LatestID == "" {
+			continue
+		}
+
+		outdated := 0
+		if config.ID != config.LatestID {
+			outdated = 1
+		}
+		c.deploymentConfigOutdatedMetric.WithLabelValues(
+			deployment.Name,
+			config.Type,
+			config.Name,
+			config.ID,
+			config.LatestID,
+		).Set(float64(outdated))
+	}
+}
+
 func (c *DeploymentsCollector) reportDeploymentInstancesMetrics(
 	deployment deployments.DeploymentInfo,
 	ch chan<- prometheus.Metric,
@@ -177,3 +589,121 @@ func (c *DeploymentsCollector) reportDeploymentInstancesMetrics(
 		).Add(float64(1))
 	}
 }
+
+func (c *DeploymentsCollector) reportDeploymentAZMetrics(
+	deployment deployments.DeploymentInfo,
+	ch chan<- prometheus.Metric,
+) {
+	for _, instance := range deployment.Instances {
+		c.deploymentAZInstancesMetric.WithLabelValues(
+			deployment.Name,
+			instance.AZ,
+		).Add(float64(1))
+
+		healthy := 0
+		if instance.Healthy {
+			healthy = 1
+		}
+		c.deploymentAZHealthyInstancesMetric.WithLabelValues(
+			deployment.Name,
+			instance.AZ,
+		).Add(float64(healthy))
+
+		memKB, err := strconv.ParseFloat(instance.Vitals.Mem.KB, 64)
+		if err != nil {
+			memKB = 0
+		}
+		if c.kbSeries {
+			c.deploymentAZMemKBMetric.WithLabelValues(
+				deployment.Name,
+				instance.AZ,
+			).Add(memKB)
+		}
+		c.deploymentAZMemBytesMetric.WithLabelValues(
+			deployment.Name,
+			instance.AZ,
+		).Add(memKB * 1024)
+
+		c.deploymentAZPersistentDiskSizeBytesMetric.WithLabelValues(
+			deployment.Name,
+			instance.AZ,
+		).Add(float64(instance.PersistentDiskSizeMB) * 1024 * 1024)
+	}
+}
+
+// reportDeploymentFetchMetrics reports what fetching a deployment cost, so
+// operators can find which deployments slow the scrapes down. Deployments
+// restored from a snapshot file without this accounting are skipped.
+func (c *DeploymentsCollector) reportDeploymentFetchMetrics(
+	deployment deployments.DeploymentInfo,
+	ch chan<- prometheus.Metric,
+) {
+	if deployment.APICallsTotal == 0 {
+		return
+	}
+
+	c.deploymentFetchSecondsMetric.WithLabelValues(deployment.Name).Set(deployment.FetchDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(
+		c.deploymentAPICallsDesc,
+		prometheus.CounterValue,
+		float64(deployment.APICallsTotal),
+		deployment.Name,
+	)
+}
+
+func (c *DeploymentsCollector) reportDeploymentProblemsMetrics(
+	deployment deployments.DeploymentInfo,
+	ch chan<- prometheus.Metric,
+) {
+	if !deployment.ProblemsScanned {
+		return
+	}
+
+	problems := map[string]int{}
+	for _, problemType := range deploymentProblemTypes {
+		problems[problemType] = 0
+	}
+	for _, problem := range deployment.Problems {
+		problems[problem.Type]++
+	}
+
+	for problemType, count := range problems {
+		c.deploymentProblemsMetric.WithLabelValues(
+			deployment.Name,
+			problemType,
+		).Set(float64(count))
+	}
+}
+
+func (c *DeploymentsCollector) reportDeploymentSLOMetrics(
+	deployment deployments.DeploymentInfo,
+	fetchedAt time.Time,
+	ch chan<- prometheus.Metric,
+) {
+	objective := c.sloObjectives.objective(deployment.Name)
+	if objective == 0 {
+		return
+	}
+
+	sample := sloSample{at: fetchedAt}
+	for _, instance := range deployment.Instances {
+		for _, process := range instance.Processes {
+			sample.totalProcesses++
+			if process.Healthy {
+				sample.runningProcesses++
+			}
+		}
+	}
+
+	samples := append(c.sloSamples[deployment.Name], sample)
+	for len(samples) > 1 && fetchedAt.Sub(samples[0].at) > c.sloWindow {
+		samples = samples[1:]
+	}
+	c.sloSamples[deployment.Name] = samples
+
+	c.sloObjectiveMetric.WithLabelValues(deployment.Name).Set(objective)
+
+	if errorBudgetRemaining, ok := sloErrorBudgetRemaining(samples, objective); ok {
+		c.sloErrorBudgetRemainingMetric.WithLabelValues(deployment.Name).Set(errorBudgetRemaining)
+	}
+}
@@ -1,6 +1,8 @@
 package collectors_test
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -8,6 +10,7 @@ import (
 	"github.com/prometheus/common/log"
 
 	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
 
 	. "github.com/bosh-prometheus/bosh_exporter/collectors"
 	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
@@ -19,27 +22,54 @@ func init() {
 
 var _ = Describe("DeploymentsCollector", func() {
 	var (
-		namespace            string
-		environment          string
-		boshName             string
-		boshUUID             string
-		deploymentsCollector *DeploymentsCollector
+		namespace                 string
+		environment               string
+		boshName                  string
+		boshUUID                  string
+		stemcellVersionsThreshold int
+		stemcellDaysThreshold     int
+		sloObjectives             SLOObjectives
+		sloWindow                 time.Duration
+		kbSeries                  bool
+		deploymentsCollector      *DeploymentsCollector
 
 		deploymentReleaseInfoMetric                *prometheus.GaugeVec
 		deploymentStemcellInfoMetric               *prometheus.GaugeVec
+		deploymentStemcellVersionsBehindMetric     *prometheus.GaugeVec
+		deploymentStemcellDaysBehindMetric         *prometheus.GaugeVec
+		deploymentStemcellOutdatedMetric           *prometheus.GaugeVec
+		deploymentConfigInfoMetric                 *prometheus.GaugeVec
+		deploymentConfigOutdatedMetric             *prometheus.GaugeVec
 		deploymentInstancesMetric                  *prometheus.GaugeVec
+		deploymentAZInstancesMetric                *prometheus.GaugeVec
+		deploymentAZHealthyInstancesMetric         *prometheus.GaugeVec
+		deploymentAZMemKBMetric                    *prometheus.GaugeVec
+		deploymentAZMemBytesMetric                 *prometheus.GaugeVec
+		deploymentAZPersistentDiskSizeBytesMetric  *prometheus.GaugeVec
+		deploymentProblemsMetric                   *prometheus.GaugeVec
+		sloObjectiveMetric                         *prometheus.GaugeVec
+		sloErrorBudgetRemainingMetric              *prometheus.GaugeVec
 		lastDeploymentsScrapeTimestampMetric       prometheus.Gauge
 		lastDeploymentsScrapeDurationSecondsMetric prometheus.Gauge
 
-		deploymentName  = "fake-deployment-name"
-		releaseName     = "fake-release-name"
-		releaseVersion  = "1.2.3"
-		stemcellName    = "fake-stemcell-name"
-		stemcellVersion = "4.5.6"
-		stemcellOSName  = "fake-stemcell-os-name"
-		vmTypeSmall     = "fake-vm-type-small"
-		vmTypeMedium    = "fake-vm-type-medium"
-		vmTypeLarge     = "fake-vm-type-large"
+		deploymentName         = "fake-deployment-name"
+		releaseName            = "fake-release-name"
+		releaseVersion         = "1.2.3"
+		stemcellName           = "fake-stemcell-name"
+		stemcellVersion        = "4.5.6"
+		stemcellOSName         = "fake-stemcell-os-name"
+		stemcellLatestVersion  = "4.5.8"
+		stemcellVersionsBehind = 2
+		stemcellDaysBehind     = 10
+		configType             = "cloud"
+		configName             = "default"
+		configID               = "3"
+		configLatestID         = "5"
+		vmTypeSmall            = "fake-vm-type-small"
+		vmTypeMedium           = "fake-vm-type-medium"
+		vmTypeLarge            = "fake-vm-type-large"
+		az1                    = "fake-az-1"
+		az2                    = "fake-az-2"
 	)
 
 	BeforeEach(func() {
@@ -47,6 +77,11 @@ var _ = Describe("DeploymentsCollector", func() {
 		environment = "test_environment"
 		boshName = "test_bosh_name"
 		boshUUID = "test_bosh_uuid"
+		stemcellVersionsThreshold = 1
+		stemcellDaysThreshold = 0
+		sloObjectives = SLOObjectives{}
+		sloWindow = 24 * time.Hour
+		kbSeries = true
 
 		deploymentReleaseInfoMetric = prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
@@ -91,6 +126,117 @@ var _ = Describe("DeploymentsCollector", func() {
 			stemcellOSName,
 		).Set(float64(1))
 
+		deploymentStemcellVersionsBehindMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "deployment",
+				Name:      "stemcell_versions_behind",
+				Help:      "Number of uploaded versions of the BOSH Deployment Stemcell newer than the deployed one.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_stemcell_name", "bosh_stemcell_version", "bosh_stemcell_latest_version"},
+		)
+
+		deploymentStemcellVersionsBehindMetric.WithLabelValues(
+			deploymentName,
+			stemcellName,
+			stemcellVersion,
+			stemcellLatestVersion,
+		).Set(float64(stemcellVersionsBehind))
+
+		deploymentStemcellDaysBehindMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "deployment",
+				Name:      "stemcell_days_behind",
+				Help:      "Number of days since the first uploaded version of the BOSH Deployment Stemcell newer than the deployed one was uploaded.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_stemcell_name", "bosh_stemcell_version", "bosh_stemcell_latest_version"},
+		)
+
+		deploymentStemcellDaysBehindMetric.WithLabelValues(
+			deploymentName,
+			stemcellName,
+			stemcellVersion,
+			stemcellLatestVersion,
+		).Set(float64(stemcellDaysBehind))
+
+		deploymentStemcellOutdatedMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "deployment",
+				Name:      "stemcell_outdated",
+				Help:      "BOSH Deployment Stemcell is behind the latest uploaded version by more than the configured versions or days threshold (1 for outdated, 0 for up to date).",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_stemcell_name", "bosh_stemcell_version", "bosh_stemcell_latest_version"},
+		)
+
+		deploymentStemcellOutdatedMetric.WithLabelValues(
+			deploymentName,
+			stemcellName,
+			stemcellVersion,
+			stemcellLatestVersion,
+		).Set(float64(1))
+
+		deploymentConfigInfoMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "deployment",
+				Name:      "config_info",
+				Help:      "Labeled BOSH Deployment Config Info (cloud, runtime, cpi configs the deployment was last deployed with) with a constant '1' value.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_config_type", "bosh_config_name", "bosh_config_id"},
+		)
+
+		deploymentConfigInfoMetric.WithLabelValues(
+			deploymentName,
+			configType,
+			configName,
+			configID,
+		).Set(float64(1))
+
+		deploymentConfigOutdatedMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "deployment",
+				Name:      "config_outdated",
+				Help:      "BOSH Deployment was last deployed with an older version of the config than the latest one (1 for outdated, 0 for up to date).",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_config_type", "bosh_config_name", "bosh_config_id", "bosh_config_latest_id"},
+		)
+
+		deploymentConfigOutdatedMetric.WithLabelValues(
+			deploymentName,
+			configType,
+			configName,
+			configID,
+			configLatestID,
+		).Set(float64(1))
+
 		deploymentInstancesMetric = prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -119,6 +265,151 @@ var _ = Describe("DeploymentsCollector", func() {
 			vmTypeLarge,
 		).Set(float64(3))
 
+		deploymentAZInstancesMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "deployment",
+				Name:      "az_instances",
+				Help:      "Number of instances in this deployment per AZ.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_az"},
+		)
+
+		deploymentAZInstancesMetric.WithLabelValues(
+			deploymentName,
+			az1,
+		).Set(float64(3))
+
+		deploymentAZHealthyInstancesMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "deployment",
+				Name:      "az_healthy_instances",
+				Help:      "Number of healthy instances in this deployment per AZ.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_az"},
+		)
+
+		deploymentAZHealthyInstancesMetric.WithLabelValues(
+			deploymentName,
+			az1,
+		).Set(float64(2))
+
+		deploymentAZMemKBMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "deployment",
+				Name:      "az_mem_kb",
+				Help:      "Sum of the memory KB used by the instances of this deployment per AZ.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_az"},
+		)
+
+		deploymentAZMemKBMetric.WithLabelValues(
+			deploymentName,
+			az1,
+		).Set(float64(3000))
+
+		deploymentAZMemBytesMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "deployment",
+				Name:      "az_mem_bytes",
+				Help:      "Sum of the memory in bytes used by the instances of this deployment per AZ.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_az"},
+		)
+
+		deploymentAZMemBytesMetric.WithLabelValues(
+			deploymentName,
+			az1,
+		).Set(float64(3000 * 1024))
+
+		deploymentAZPersistentDiskSizeBytesMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "deployment",
+				Name:      "az_persistent_disk_size_bytes",
+				Help:      "Sum of the persistent disk sizes in bytes of the instances of this deployment per AZ.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_az"},
+		)
+
+		deploymentAZPersistentDiskSizeBytesMetric.WithLabelValues(
+			deploymentName,
+			az1,
+		).Set(float64(1073741824))
+
+		deploymentProblemsMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "deployment",
+				Name:      "problems",
+				Help:      "Number of problems found by the last BOSH Director problem scan of this deployment, by type.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "type"},
+		)
+
+		sloObjectiveMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "slo",
+				Name:      "objective_ratio",
+				Help:      "Objective of the ratio of running processes in this deployment.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment"},
+		)
+
+		sloErrorBudgetRemainingMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "slo",
+				Name:      "error_budget_remaining_ratio",
+				Help:      "Ratio of the error budget of this deployment left over the SLO window (negative when exhausted).",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment"},
+		)
+
 		lastDeploymentsScrapeTimestampMetric = prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -154,6 +445,11 @@ var _ = Describe("DeploymentsCollector", func() {
 			environment,
 			boshName,
 			boshUUID,
+			stemcellVersionsThreshold,
+			stemcellDaysThreshold,
+			sloObjectives,
+			sloWindow,
+			kbSeries,
 		)
 	})
 
@@ -187,6 +483,52 @@ var _ = Describe("DeploymentsCollector", func() {
 			).Desc())))
 		})
 
+		It("returns a deployment_stemcell_versions_behind metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentStemcellVersionsBehindMetric.WithLabelValues(
+				deploymentName,
+				stemcellName,
+				stemcellVersion,
+				stemcellLatestVersion,
+			).Desc())))
+		})
+
+		It("returns a deployment_stemcell_days_behind metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentStemcellDaysBehindMetric.WithLabelValues(
+				deploymentName,
+				stemcellName,
+				stemcellVersion,
+				stemcellLatestVersion,
+			).Desc())))
+		})
+
+		It("returns a deployment_stemcell_outdated metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentStemcellOutdatedMetric.WithLabelValues(
+				deploymentName,
+				stemcellName,
+				stemcellVersion,
+				stemcellLatestVersion,
+			).Desc())))
+		})
+
+		It("returns a deployment_config_info metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentConfigInfoMetric.WithLabelValues(
+				deploymentName,
+				configType,
+				configName,
+				configID,
+			).Desc())))
+		})
+
+		It("returns a deployment_config_outdated metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentConfigOutdatedMetric.WithLabelValues(
+				deploymentName,
+				configType,
+				configName,
+				configID,
+				configLatestID,
+			).Desc())))
+		})
+
 		It("returns a deployment_instances metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(deploymentInstancesMetric.WithLabelValues(
 				deploymentName,
@@ -194,6 +536,59 @@ var _ = Describe("DeploymentsCollector", func() {
 			).Desc())))
 		})
 
+		It("returns a deployment_az_instances metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentAZInstancesMetric.WithLabelValues(
+				deploymentName,
+				az1,
+			).Desc())))
+		})
+
+		It("returns a deployment_az_healthy_instances metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentAZHealthyInstancesMetric.WithLabelValues(
+				deploymentName,
+				az1,
+			).Desc())))
+		})
+
+		It("returns a deployment_az_mem_kb metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentAZMemKBMetric.WithLabelValues(
+				deploymentName,
+				az1,
+			).Desc())))
+		})
+		It("returns a deployment_az_mem_bytes metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentAZMemBytesMetric.WithLabelValues(
+				deploymentName,
+				az1,
+			).Desc())))
+		})
+
+		It("returns a deployment_az_persistent_disk_size_bytes metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentAZPersistentDiskSizeBytesMetric.WithLabelValues(
+				deploymentName,
+				az1,
+			).Desc())))
+		})
+
+		It("returns a deployment_problems metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentProblemsMetric.WithLabelValues(
+				deploymentName,
+				"unresponsive_agent",
+			).Desc())))
+		})
+
+		It("returns a slo_objective_ratio metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(sloObjectiveMetric.WithLabelValues(
+				deploymentName,
+			).Desc())))
+		})
+
+		It("returns a slo_error_budget_remaining_ratio metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(sloErrorBudgetRemainingMetric.WithLabelValues(
+				deploymentName,
+			).Desc())))
+		})
+
 		It("returns a last_deployments_scrape_timestamp metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(lastDeploymentsScrapeTimestampMetric.Desc())))
 		})
@@ -211,20 +606,18 @@ var _ = Describe("DeploymentsCollector", func() {
 			}
 			releases = []deployments.Release{release}
 
-			stemcell = deployments.Stemcell{
-				Name:    stemcellName,
-				Version: stemcellVersion,
-				OSName:  stemcellOSName,
-			}
-			stemcells = []deployments.Stemcell{stemcell}
+			stemcell  deployments.Stemcell
+			stemcells []deployments.Stemcell
+
+			config deployments.Config
 
 			instances = []deployments.Instance{
-				{VMType: vmTypeSmall},
-				{VMType: vmTypeMedium},
-				{VMType: vmTypeMedium},
-				{VMType: vmTypeLarge},
-				{VMType: vmTypeLarge},
-				{VMType: vmTypeLarge},
+				{VMType: vmTypeSmall, AZ: az1, Healthy: true, Vitals: deployments.Vitals{Mem: deployments.Mem{KB: "1000"}}, PersistentDiskSizeMB: 1024},
+				{VMType: vmTypeMedium, AZ: az1, Healthy: true, Vitals: deployments.Vitals{Mem: deployments.Mem{KB: "2000"}}},
+				{VMType: vmTypeMedium, AZ: az2, Vitals: deployments.Vitals{Mem: deployments.Mem{KB: "3000"}}, PersistentDiskSizeMB: 2048},
+				{VMType: vmTypeLarge, AZ: az1},
+				{VMType: vmTypeLarge, AZ: az2},
+				{VMType: vmTypeLarge, AZ: az2},
 			}
 
 			deploymentInfo deployments.DeploymentInfo
@@ -236,10 +629,28 @@ var _ = Describe("DeploymentsCollector", func() {
 		)
 
 		BeforeEach(func() {
+			stemcell = deployments.Stemcell{
+				Name:           stemcellName,
+				Version:        stemcellVersion,
+				OSName:         stemcellOSName,
+				LatestVersion:  stemcellLatestVersion,
+				VersionsBehind: stemcellVersionsBehind,
+				DaysBehind:     stemcellDaysBehind,
+			}
+			stemcells = []deployments.Stemcell{stemcell}
+
+			config = deployments.Config{
+				ID:       configID,
+				Type:     configType,
+				Name:     configName,
+				LatestID: configLatestID,
+			}
+
 			deploymentInfo = deployments.DeploymentInfo{
 				Name:      deploymentName,
 				Releases:  releases,
 				Stemcells: stemcells,
+				Configs:   []deployments.Config{config},
 				Instances: instances,
 			}
 			deploymentsInfo = []deployments.DeploymentInfo{deploymentInfo}
@@ -250,7 +661,7 @@ var _ = Describe("DeploymentsCollector", func() {
 
 		JustBeforeEach(func() {
 			go func() {
-				if err := deploymentsCollector.Collect(deploymentsInfo, metrics); err != nil {
+				if err := deploymentsCollector.Collect(fetcher.Snapshot{Deployments: deploymentsInfo}, metrics); err != nil {
 					errMetrics <- err
 				}
 			}()
@@ -275,6 +686,114 @@ var _ = Describe("DeploymentsCollector", func() {
 			Consistently(errMetrics).ShouldNot(Receive())
 		})
 
+		It("returns a deployment_stemcell_versions_behind metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploymentStemcellVersionsBehindMetric.WithLabelValues(
+				deploymentName,
+				stemcellName,
+				stemcellVersion,
+				stemcellLatestVersion,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		It("returns a deployment_stemcell_days_behind metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploymentStemcellDaysBehindMetric.WithLabelValues(
+				deploymentName,
+				stemcellName,
+				stemcellVersion,
+				stemcellLatestVersion,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		It("returns a deployment_stemcell_outdated metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploymentStemcellOutdatedMetric.WithLabelValues(
+				deploymentName,
+				stemcellName,
+				stemcellVersion,
+				stemcellLatestVersion,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		Context("when the stemcell is within the versions threshold", func() {
+			BeforeEach(func() {
+				stemcellVersionsThreshold = stemcellVersionsBehind
+				deploymentStemcellOutdatedMetric.WithLabelValues(
+					deploymentName,
+					stemcellName,
+					stemcellVersion,
+					stemcellLatestVersion,
+				).Set(float64(0))
+			})
+
+			It("returns a deployment_stemcell_outdated metric with a '0' value", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(deploymentStemcellOutdatedMetric.WithLabelValues(
+					deploymentName,
+					stemcellName,
+					stemcellVersion,
+					stemcellLatestVersion,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			Context("and behind by more than the days threshold", func() {
+				BeforeEach(func() {
+					stemcellDaysThreshold = stemcellDaysBehind - 1
+					deploymentStemcellOutdatedMetric.WithLabelValues(
+						deploymentName,
+						stemcellName,
+						stemcellVersion,
+						stemcellLatestVersion,
+					).Set(float64(1))
+				})
+
+				It("returns a deployment_stemcell_outdated metric with a '1' value", func() {
+					Eventually(metrics).Should(Receive(PrometheusMetric(deploymentStemcellOutdatedMetric.WithLabelValues(
+						deploymentName,
+						stemcellName,
+						stemcellVersion,
+						stemcellLatestVersion,
+					))))
+					Consistently(errMetrics).ShouldNot(Receive())
+				})
+			})
+
+			Context("and within the days threshold", func() {
+				BeforeEach(func() {
+					stemcellDaysThreshold = stemcellDaysBehind
+				})
+
+				It("returns a deployment_stemcell_outdated metric with a '0' value", func() {
+					Eventually(metrics).Should(Receive(PrometheusMetric(deploymentStemcellOutdatedMetric.WithLabelValues(
+						deploymentName,
+						stemcellName,
+						stemcellVersion,
+						stemcellLatestVersion,
+					))))
+					Consistently(errMetrics).ShouldNot(Receive())
+				})
+			})
+		})
+
+		Context("when the stemcell latest version is unknown", func() {
+			BeforeEach(func() {
+				stemcell.LatestVersion = ""
+				deploymentInfo.Stemcells = []deployments.Stemcell{stemcell}
+				deploymentsInfo = []deployments.DeploymentInfo{deploymentInfo}
+			})
+
+			It("should not return a deployment_stemcell_versions_behind metric", func() {
+				Consistently(metrics).ShouldNot(Receive(PrometheusMetric(deploymentStemcellVersionsBehindMetric.WithLabelValues(
+					deploymentName,
+					stemcellName,
+					stemcellVersion,
+					"",
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
 		It("returns a deployment_instances for small vmType instance", func() {
 			Eventually(metrics).Should(Receive(PrometheusMetric(deploymentInstancesMetric.WithLabelValues(
 				deploymentName,
@@ -299,6 +818,255 @@ var _ = Describe("DeploymentsCollector", func() {
 			Consistently(errMetrics).ShouldNot(Receive())
 		})
 
+		It("returns a deployment_az_instances metric with the number of instances of the AZ", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploymentAZInstancesMetric.WithLabelValues(
+				deploymentName,
+				az1,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		It("returns a deployment_az_healthy_instances metric with the number of healthy instances of the AZ", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploymentAZHealthyInstancesMetric.WithLabelValues(
+				deploymentName,
+				az1,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		It("returns a deployment_az_mem_kb metric with the memory used of the AZ", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploymentAZMemKBMetric.WithLabelValues(
+				deploymentName,
+				az1,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+		It("returns a deployment_az_mem_bytes metric with the memory used of the AZ", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploymentAZMemBytesMetric.WithLabelValues(
+				deploymentName,
+				az1,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		It("returns a deployment_az_persistent_disk_size_bytes metric with the persistent disk size of the AZ", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploymentAZPersistentDiskSizeBytesMetric.WithLabelValues(
+				deploymentName,
+				az1,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		It("should not return a deployment_problems metric when the deployment was not scanned", func() {
+			Consistently(metrics).ShouldNot(Receive(PrometheusMetric(deploymentProblemsMetric.WithLabelValues(
+				deploymentName,
+				"unresponsive_agent",
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		Context("when the deployment problems were scanned", func() {
+			BeforeEach(func() {
+				deploymentInfo.ProblemsScanned = true
+				deploymentInfo.Problems = []deployments.Problem{
+					{Type: "unresponsive_agent", Description: "fake-job/0 is not responding"},
+					{Type: "unresponsive_agent", Description: "fake-job/1 is not responding"},
+				}
+				deploymentsInfo = []deployments.DeploymentInfo{deploymentInfo}
+
+				deploymentProblemsMetric.WithLabelValues(deploymentName, "unresponsive_agent").Set(2)
+				deploymentProblemsMetric.WithLabelValues(deploymentName, "missing_vm").Set(0)
+			})
+
+			It("returns a deployment_problems metric by problem type", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(deploymentProblemsMetric.WithLabelValues(
+					deploymentName,
+					"unresponsive_agent",
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			It("returns a deployment_problems metric for the problem types not found", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(deploymentProblemsMetric.WithLabelValues(
+					deploymentName,
+					"missing_vm",
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
+		It("should not return an exporter_deployment_fetch_seconds metric when the fetch was not accounted", func() {
+			Consistently(metrics).ShouldNot(Receive(WithTransform(func(metric prometheus.Metric) string {
+				return metric.Desc().String()
+			}, ContainSubstring("exporter_deployment_fetch_seconds"))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		Context("when the deployment fetch was accounted", func() {
+			var (
+				deploymentFetchSecondsMetric  *prometheus.GaugeVec
+				deploymentAPICallsTotalMetric *prometheus.CounterVec
+			)
+
+			BeforeEach(func() {
+				deploymentInfo.FetchDuration = 1500 * time.Millisecond
+				deploymentInfo.APICallsTotal = 7
+				deploymentsInfo = []deployments.DeploymentInfo{deploymentInfo}
+
+				constLabels := prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				}
+				deploymentFetchSecondsMetric = prometheus.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Namespace:   namespace,
+						Subsystem:   "exporter",
+						Name:        "deployment_fetch_seconds",
+						Help:        "Duration in seconds of the last fetch of a BOSH Deployment from the BOSH Director.",
+						ConstLabels: constLabels,
+					},
+					[]string{"bosh_deployment"},
+				)
+				deploymentFetchSecondsMetric.WithLabelValues(deploymentName).Set(1.5)
+
+				deploymentAPICallsTotalMetric = prometheus.NewCounterVec(
+					prometheus.CounterOpts{
+						Namespace:   namespace,
+						Subsystem:   "exporter",
+						Name:        "deployment_api_calls_total",
+						Help:        "Total number of BOSH Director API calls sent to fetch a BOSH Deployment.",
+						ConstLabels: constLabels,
+					},
+					[]string{"bosh_deployment"},
+				)
+				deploymentAPICallsTotalMetric.WithLabelValues(deploymentName).Add(7)
+			})
+
+			It("returns an exporter_deployment_fetch_seconds metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(deploymentFetchSecondsMetric.WithLabelValues(deploymentName))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			It("returns an exporter_deployment_api_calls_total metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(deploymentAPICallsTotalMetric.WithLabelValues(deploymentName))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
+		It("should not return a slo_error_budget_remaining_ratio metric", func() {
+			Consistently(metrics).ShouldNot(Receive(PrometheusMetric(sloErrorBudgetRemainingMetric.WithLabelValues(
+				deploymentName,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		Context("when an SLO objective is set for the deployment", func() {
+			BeforeEach(func() {
+				sloObjectives = SLOObjectives{Default: 0.9, Deployments: map[string]float64{deploymentName: 0.5}}
+				deploymentInfo.Instances = []deployments.Instance{
+					{
+						VMType: vmTypeSmall,
+						Processes: []deployments.Process{
+							{Name: "fake-process-1", Healthy: true},
+							{Name: "fake-process-2", Healthy: true},
+						},
+					},
+					{
+						VMType: vmTypeSmall,
+						Processes: []deployments.Process{
+							{Name: "fake-process-1", Healthy: true},
+							{Name: "fake-process-2", Healthy: false},
+						},
+					},
+				}
+				deploymentsInfo = []deployments.DeploymentInfo{deploymentInfo}
+
+				sloObjectiveMetric.WithLabelValues(deploymentName).Set(0.5)
+				sloErrorBudgetRemainingMetric.WithLabelValues(deploymentName).Set(0.5)
+			})
+
+			It("returns a slo_objective_ratio metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(sloObjectiveMetric.WithLabelValues(
+					deploymentName,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			It("returns a slo_error_budget_remaining_ratio metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(sloErrorBudgetRemainingMetric.WithLabelValues(
+					deploymentName,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
+		It("returns a deployment_config_info metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploymentConfigInfoMetric.WithLabelValues(
+				deploymentName,
+				configType,
+				configName,
+				configID,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		It("returns a deployment_config_outdated metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploymentConfigOutdatedMetric.WithLabelValues(
+				deploymentName,
+				configType,
+				configName,
+				configID,
+				configLatestID,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		Context("when the deployment was deployed with the latest config", func() {
+			BeforeEach(func() {
+				config.LatestID = configID
+				deploymentInfo.Configs = []deployments.Config{config}
+				deploymentsInfo = []deployments.DeploymentInfo{deploymentInfo}
+				deploymentConfigOutdatedMetric.WithLabelValues(
+					deploymentName,
+					configType,
+					configName,
+					configID,
+					configID,
+				).Set(float64(0))
+			})
+
+			It("returns a deployment_config_outdated metric with a '0' value", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(deploymentConfigOutdatedMetric.WithLabelValues(
+					deploymentName,
+					configType,
+					configName,
+					configID,
+					configID,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
+		Context("when the latest config is unknown", func() {
+			BeforeEach(func() {
+				config.LatestID = ""
+				deploymentInfo.Configs = []deployments.Config{config}
+				deploymentsInfo = []deployments.DeploymentInfo{deploymentInfo}
+			})
+
+			It("should not return a deployment_config_outdated metric", func() {
+				Consistently(metrics).ShouldNot(Receive(PrometheusMetric(deploymentConfigOutdatedMetric.WithLabelValues(
+					deploymentName,
+					configType,
+					configName,
+					configID,
+					configLatestID,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
 		Context("when there are no deployments", func() {
 			BeforeEach(func() {
 				deploymentsInfo = []deployments.DeploymentInfo{}
@@ -0,0 +1,223 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+const deployEventsMaxPages = 10
+
+// DeployWindows are the rolling windows the deploys of a deployment are
+// counted over, keyed by the value of their `window` label.
+var DeployWindows = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{Name: "7d", Duration: 7 * 24 * time.Hour},
+	{Name: "30d", Duration: 30 * 24 * time.Hour},
+}
+
+// Deploy is a successful deploy of a deployment, as recorded by the end event
+// of a `create` or `update` of the deployment.
+type Deploy struct {
+	ID         string
+	Deployment string
+	FinishedAt time.Time
+}
+
+// DeploysCollector reports when every deployment was last deployed and how
+// many times it was deployed over the DeployWindows.
+type DeploysCollector struct {
+	boshClient      director.Director
+	refreshInterval time.Duration
+	lastRefresh     time.Time
+	clock           clock.Clock
+	deploys         map[string]Deploy
+	lastDeploys     map[string]time.Time
+
+	lastDeployTimestampMetric *prometheus.GaugeVec
+	deploysMetric             *prometheus.GaugeVec
+	mu                        *sync.Mutex
+}
+
+func NewDeploysCollector(
+	namespace string,
+	environment string,
+	boshName string,
+	boshUUID string,
+	boshClient director.Director,
+	refreshInterval time.Duration,
+) *DeploysCollector {
+	constLabels := prometheus.Labels{
+		"environment": environment,
+		"bosh_name":   boshName,
+		"bosh_uuid":   boshUUID,
+	}
+
+	lastDeployTimestampMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "deployment",
+			Name:        "last_deploy_timestamp_seconds",
+			Help:        "Number of seconds since 1970 since the last successful deploy of a BOSH deployment finished.",
+			ConstLabels: constLabels,
+		},
+		[]string{"bosh_deployment"},
+	)
+
+	deploysMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "deployment",
+			Name:        "deploys",
+			Help:        "Number of successful deploys of a BOSH deployment over a rolling window.",
+			ConstLabels: constLabels,
+		},
+		[]string{"bosh_deployment", "window"},
+	)
+
+	return &DeploysCollector{
+		boshClient:                boshClient,
+		refreshInterval:           refreshInterval,
+		clock:                     clock.Real,
+		deploys:                   map[string]Deploy{},
+		lastDeploys:               map[string]time.Time{},
+		lastDeployTimestampMetric: lastDeployTimestampMetric,
+		deploysMetric:             deploysMetric,
+		mu:                        &sync.Mutex{},
+	}
+}
+
+// SetClock replaces the clock telling when to refresh and where the windows
+// start.
+func (c *DeploysCollector) SetClock(clk clock.Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clk
+}
+
+func (c *DeploysCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lastRefresh.IsZero() || c.clock.Now().Sub(c.lastRefresh) >= c.refreshInterval {
+		if err := c.refresh(); err != nil {
+			log.Error(err)
+		}
+		c.lastRefresh = c.clock.Now()
+	}
+
+	c.lastDeployTimestampMetric.Collect(ch)
+	c.deploysMetric.Collect(ch)
+}
+
+func (c *DeploysCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.lastDeployTimestampMetric.Describe(ch)
+	c.deploysMetric.Describe(ch)
+}
+
+// refresh reads the deployment events of the longest window and recounts the
+// deploys. Deploys are remembered by event ID, so an event read twice is only
+// counted once, and the last deploy of a deployment is kept after it leaves
+// the windows.
+func (c *DeploysCollector) refresh() error {
+	now := c.clock.Now()
+	after := now.Add(-DeployWindows[len(DeployWindows)-1].Duration)
+
+	createEvents, err := c.deploymentEvents("create", after)
+	if err != nil {
+		return err
+	}
+	updateEvents, err := c.deploymentEvents("update", after)
+	if err != nil {
+		return err
+	}
+
+	for _, deploy := range Deploys(append(createEvents, updateEvents...)) {
+		c.deploys[deploy.ID] = deploy
+		if deploy.FinishedAt.After(c.lastDeploys[deploy.Deployment]) {
+			c.lastDeploys[deploy.Deployment] = deploy.FinishedAt
+		}
+	}
+
+	for id, deploy := range c.deploys {
+		if deploy.FinishedAt.Before(after) {
+			delete(c.deploys, id)
+		}
+	}
+
+	c.deploysMetric.Reset()
+	for deployment, finishedAt := range c.lastDeploys {
+		c.lastDeployTimestampMetric.WithLabelValues(deployment).Set(float64(finishedAt.Unix()))
+		for _, window := range DeployWindows {
+			c.deploysMetric.WithLabelValues(deployment, window.Name).Set(float64(0))
+		}
+	}
+	for _, deploy := range c.deploys {
+		for _, window := range DeployWindows {
+			if !deploy.FinishedAt.Before(now.Add(-window.Duration)) {
+				c.deploysMetric.WithLabelValues(deploy.Deployment, window.Name).Inc()
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *DeploysCollector) deploymentEvents(action string, after time.Time) ([]director.Event, error) {
+	filter := director.EventsFilter{
+		Action:     action,
+		ObjectType: "deployment",
+		After:      after.UTC().Format(time.RFC3339),
+	}
+
+	events, err := fetcher.ReadEvents(c.boshClient, filter, deployEventsMaxPages)
+	if err != nil {
+		return events, errors.New(fmt.Sprintf("Error while reading `%s` deployment events: %v", action, err))
+	}
+
+	return events, nil
+}
+
+// Deploys returns the successful deploys recorded by events, oldest first.
+// The Director records a deploy as a start event and an end event pointing
+// to it, with the error of the deploy if any, so only the end events without
+// error are kept.
+func Deploys(events []director.Event) []Deploy {
+	deploys := []Deploy{}
+
+	for _, event := range events {
+		if event.ObjectType() != "deployment" || event.ParentID() == "" || event.Error() != "" {
+			continue
+		}
+		if event.Action() != "create" && event.Action() != "update" {
+			continue
+		}
+
+		deployment := event.DeploymentName()
+		if deployment == "" {
+			deployment = event.ObjectName()
+		}
+		deploys = append(deploys, Deploy{
+			ID:         event.ID(),
+			Deployment: deployment,
+			FinishedAt: event.Timestamp(),
+		})
+	}
+
+	sort.SliceStable(deploys, func(i, j int) bool {
+		return deploys[i].FinishedAt.Before(deploys[j].FinishedAt)
+	})
+
+	return deploys
+}
@@ -0,0 +1,230 @@
+package collectors_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/director/directorfakes"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+func deploymentEvent(id string, parentID string, action string, deployment string, timestamp time.Time, eventError string) director.Event {
+	event := &directorfakes.FakeEvent{}
+	event.IDReturns(id)
+	event.ParentIDReturns(parentID)
+	event.ActionReturns(action)
+	event.ObjectTypeReturns("deployment")
+	event.ObjectNameReturns(deployment)
+	event.DeploymentNameReturns(deployment)
+	event.TimestampReturns(timestamp)
+	event.ErrorReturns(eventError)
+	return event
+}
+
+var _ = Describe("DeploysCollector", func() {
+	var (
+		namespace    string
+		environment  string
+		boshName     string
+		boshUUID     string
+		boshClient   *directorfakes.FakeDirector
+		createEvents []director.Event
+		updateEvents []director.Event
+		now          time.Time
+		fakeClock    *clock.FakeClock
+
+		deploysCollector *DeploysCollector
+
+		lastDeployTimestampMetric *prometheus.GaugeVec
+		deploysMetric             *prometheus.GaugeVec
+	)
+
+	BeforeEach(func() {
+		namespace = "test_exporter"
+		environment = "test_environment"
+		boshName = "test_bosh_name"
+		boshUUID = "test_bosh_uuid"
+		now = time.Now().Truncate(time.Second)
+		fakeClock = clock.NewFakeClock(now)
+
+		createEvents = []director.Event{
+			deploymentEvent("2", "1", "create", "fake-deployment", now.Add(-20*24*time.Hour), ""),
+			deploymentEvent("1", "", "create", "fake-deployment", now.Add(-20*24*time.Hour-time.Minute), ""),
+		}
+		updateEvents = []director.Event{
+			deploymentEvent("8", "7", "update", "fake-deployment", now.Add(-time.Hour), "Timed out"),
+			deploymentEvent("7", "", "update", "fake-deployment", now.Add(-time.Hour-time.Minute), ""),
+			deploymentEvent("6", "5", "update", "fake-deployment", now.Add(-2*24*time.Hour), ""),
+			deploymentEvent("5", "", "update", "fake-deployment", now.Add(-2*24*time.Hour-time.Minute), ""),
+			deploymentEvent("4", "3", "update", "fake-deployment", now.Add(-10*24*time.Hour), ""),
+			deploymentEvent("3", "", "update", "fake-deployment", now.Add(-10*24*time.Hour-time.Minute), ""),
+		}
+
+		boshClient = &directorfakes.FakeDirector{}
+		boshClient.EventsStub = func(filter director.EventsFilter) ([]director.Event, error) {
+			if filter.Action == "create" {
+				return createEvents, nil
+			}
+			return updateEvents, nil
+		}
+
+		constLabels := prometheus.Labels{
+			"environment": environment,
+			"bosh_name":   boshName,
+			"bosh_uuid":   boshUUID,
+		}
+
+		lastDeployTimestampMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "deployment",
+				Name:        "last_deploy_timestamp_seconds",
+				Help:        "Number of seconds since 1970 since the last successful deploy of a BOSH deployment finished.",
+				ConstLabels: constLabels,
+			},
+			[]string{"bosh_deployment"},
+		)
+
+		deploysMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "deployment",
+				Name:        "deploys",
+				Help:        "Number of successful deploys of a BOSH deployment over a rolling window.",
+				ConstLabels: constLabels,
+			},
+			[]string{"bosh_deployment", "window"},
+		)
+	})
+
+	JustBeforeEach(func() {
+		deploysCollector = NewDeploysCollector(namespace, environment, boshName, boshUUID, boshClient, time.Hour)
+		deploysCollector.SetClock(fakeClock)
+	})
+
+	Describe("Describe", func() {
+		var (
+			descriptions chan *prometheus.Desc
+		)
+
+		BeforeEach(func() {
+			descriptions = make(chan *prometheus.Desc)
+		})
+
+		JustBeforeEach(func() {
+			go deploysCollector.Describe(descriptions)
+		})
+
+		It("returns a deployment_last_deploy_timestamp_seconds metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(lastDeployTimestampMetric.WithLabelValues("fake-deployment").Desc())))
+		})
+
+		It("returns a deployment_deploys metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploysMetric.WithLabelValues("fake-deployment", "7d").Desc())))
+		})
+	})
+
+	Describe("Collect", func() {
+		var (
+			metrics   chan prometheus.Metric
+			collected chan struct{}
+		)
+
+		BeforeEach(func() {
+			metrics = make(chan prometheus.Metric)
+			lastDeployTimestampMetric.WithLabelValues("fake-deployment").Set(float64(now.Add(-2 * 24 * time.Hour).Unix()))
+			deploysMetric.WithLabelValues("fake-deployment", "7d").Set(float64(1))
+			deploysMetric.WithLabelValues("fake-deployment", "30d").Set(float64(3))
+		})
+
+		JustBeforeEach(func() {
+			collected = make(chan struct{})
+			go func() {
+				deploysCollector.Collect(metrics)
+				close(collected)
+			}()
+		})
+
+		// The collection reads the events of the test, so it must be over
+		// before the next test replaces them.
+		AfterEach(func() {
+			for {
+				select {
+				case <-metrics:
+				case <-collected:
+					return
+				}
+			}
+		})
+
+		It("returns the last successful deploy in a deployment_last_deploy_timestamp_seconds metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(lastDeployTimestampMetric.WithLabelValues("fake-deployment"))))
+		})
+
+		It("returns the successful deploys of the last 7 days in a deployment_deploys metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploysMetric.WithLabelValues("fake-deployment", "7d"))))
+		})
+
+		It("returns the successful deploys of the last 30 days in a deployment_deploys metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(deploysMetric.WithLabelValues("fake-deployment", "30d"))))
+		})
+
+		It("reads the create and update deployment events of the last 30 days", func() {
+			Eventually(boshClient.EventsCallCount).Should(Equal(2))
+			Expect(boshClient.EventsArgsForCall(0).Action).To(Equal("create"))
+			Expect(boshClient.EventsArgsForCall(0).ObjectType).To(Equal("deployment"))
+			Expect(boshClient.EventsArgsForCall(1).Action).To(Equal("update"))
+			Expect(boshClient.EventsArgsForCall(1).ObjectType).To(Equal("deployment"))
+			Expect(boshClient.EventsArgsForCall(0).After).To(Equal(now.Add(-30 * 24 * time.Hour).UTC().Format(time.RFC3339)))
+		})
+
+		Context("when the deploys leave the windows", func() {
+			It("keeps the last deploy and no longer counts them", func() {
+				for i := 0; i < 3; i++ {
+					Eventually(metrics).Should(Receive())
+				}
+
+				fakeClock.Advance(40 * 24 * time.Hour)
+				createEvents = []director.Event{}
+				updateEvents = []director.Event{}
+				deploysMetric.WithLabelValues("fake-deployment", "30d").Set(float64(0))
+
+				go deploysCollector.Collect(metrics)
+				Eventually(metrics).Should(Receive(PrometheusMetric(lastDeployTimestampMetric.WithLabelValues("fake-deployment"))))
+				Eventually(metrics).Should(Receive(PrometheusMetric(deploysMetric.WithLabelValues("fake-deployment", "30d"))))
+				Expect(boshClient.EventsCallCount()).To(Equal(4))
+			})
+		})
+
+		Context("when the events cannot be read", func() {
+			BeforeEach(func() {
+				boshClient.EventsStub = nil
+				boshClient.EventsReturns(nil, errors.New("no events"))
+			})
+
+			It("does not return a metric", func() {
+				Consistently(metrics).ShouldNot(Receive())
+			})
+		})
+	})
+
+	Describe("Deploys", func() {
+		It("returns the successful deploys oldest first", func() {
+			deploys := Deploys(append(createEvents, updateEvents...))
+			Expect(deploys).To(Equal([]Deploy{
+				{ID: "2", Deployment: "fake-deployment", FinishedAt: now.Add(-20 * 24 * time.Hour)},
+				{ID: "4", Deployment: "fake-deployment", FinishedAt: now.Add(-10 * 24 * time.Hour)},
+				{ID: "6", Deployment: "fake-deployment", FinishedAt: now.Add(-2 * 24 * time.Hour)},
+			}))
+		})
+	})
+})
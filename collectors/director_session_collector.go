@@ -0,0 +1,92 @@
+package collectors
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+type DirectorSessionCollector struct {
+	session                          *fetcher.DirectorSession
+	directorConnectionsDesc          *prometheus.Desc
+	directorConnectionReuseRatioDesc *prometheus.Desc
+	responseCacheRequestsDesc        *prometheus.Desc
+	responseCacheSavedBytesDesc      *prometheus.Desc
+}
+
+func NewDirectorSessionCollector(
+	namespace string,
+	environment string,
+	boshName string,
+	boshUUID string,
+	session *fetcher.DirectorSession,
+) *DirectorSessionCollector {
+	constLabels := prometheus.Labels{
+		"environment": environment,
+		"bosh_name":   boshName,
+		"bosh_uuid":   boshUUID,
+	}
+
+	directorConnectionsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "director", "connections_total"),
+		"Total number of connections used to send requests to the BOSH Director, by whether they were reused.",
+		[]string{"reused"},
+		constLabels,
+	)
+
+	directorConnectionReuseRatioDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "director", "connection_reuse_ratio"),
+		"Ratio of the requests sent to the BOSH Director over a reused connection.",
+		nil,
+		constLabels,
+	)
+
+	responseCacheRequestsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "director", "response_cache_requests_total"),
+		"Total number of BOSH Director GET requests sent with conditional requests enabled, by whether the cached response was still valid.",
+		[]string{"result"},
+		constLabels,
+	)
+
+	responseCacheSavedBytesDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "director", "response_cache_saved_bytes_total"),
+		"Total number of BOSH Director response body bytes served from the cache instead of being transferred again.",
+		nil,
+		constLabels,
+	)
+
+	return &DirectorSessionCollector{
+		session:                          session,
+		directorConnectionsDesc:          directorConnectionsDesc,
+		directorConnectionReuseRatioDesc: directorConnectionReuseRatioDesc,
+		responseCacheRequestsDesc:        responseCacheRequestsDesc,
+		responseCacheSavedBytesDesc:      responseCacheSavedBytesDesc,
+	}
+}
+
+func (c *DirectorSessionCollector) Collect(ch chan<- prometheus.Metric) {
+	newConnections, reusedConnections := c.session.Connections()
+
+	ch <- prometheus.MustNewConstMetric(c.directorConnectionsDesc, prometheus.CounterValue, float64(newConnections), "false")
+	ch <- prometheus.MustNewConstMetric(c.directorConnectionsDesc, prometheus.CounterValue, float64(reusedConnections), "true")
+
+	var reuseRatio float64
+	if total := newConnections + reusedConnections; total > 0 {
+		reuseRatio = float64(reusedConnections) / float64(total)
+	}
+	ch <- prometheus.MustNewConstMetric(c.directorConnectionReuseRatioDesc, prometheus.GaugeValue, reuseRatio)
+
+	if responseCache := c.session.ResponseCache(); responseCache != nil {
+		hits, misses, savedBytes := responseCache.Stats()
+		ch <- prometheus.MustNewConstMetric(c.responseCacheRequestsDesc, prometheus.CounterValue, float64(hits), "hit")
+		ch <- prometheus.MustNewConstMetric(c.responseCacheRequestsDesc, prometheus.CounterValue, float64(misses), "miss")
+		ch <- prometheus.MustNewConstMetric(c.responseCacheSavedBytesDesc, prometheus.CounterValue, float64(savedBytes))
+	}
+}
+
+func (c *DirectorSessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.directorConnectionsDesc
+	ch <- c.directorConnectionReuseRatioDesc
+	ch <- c.responseCacheRequestsDesc
+	ch <- c.responseCacheSavedBytesDesc
+}
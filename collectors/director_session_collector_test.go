@@ -0,0 +1,214 @@
+package collectors_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+var _ = Describe("DirectorSessionCollector", func() {
+	var (
+		namespace   string
+		environment string
+		boshName    string
+		boshUUID    string
+		server      *httptest.Server
+		session     *fetcher.DirectorSession
+
+		directorSessionCollector *DirectorSessionCollector
+
+		directorConnectionsMetric          *prometheus.CounterVec
+		directorConnectionReuseRatioMetric prometheus.Gauge
+		responseCacheRequestsMetric        *prometheus.CounterVec
+		responseCacheSavedBytesMetric      prometheus.Counter
+	)
+
+	BeforeEach(func() {
+		namespace = "test_exporter"
+		environment = "test_environment"
+		boshName = "test_bosh_name"
+		boshUUID = "test_bosh_uuid"
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte("{}"))
+		}))
+		session = fetcher.NewDirectorSession(&tls.Config{RootCAs: server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}, nil, nil)
+
+		directorConnectionsMetric = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "director",
+				Name:      "connections_total",
+				Help:      "Total number of connections used to send requests to the BOSH Director, by whether they were reused.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"reused"},
+		)
+
+		directorConnectionReuseRatioMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "director",
+				Name:      "connection_reuse_ratio",
+				Help:      "Ratio of the requests sent to the BOSH Director over a reused connection.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
+
+		responseCacheRequestsMetric = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "director",
+				Name:      "response_cache_requests_total",
+				Help:      "Total number of BOSH Director GET requests sent with conditional requests enabled, by whether the cached response was still valid.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"result"},
+		)
+
+		responseCacheSavedBytesMetric = prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "director",
+				Name:      "response_cache_saved_bytes_total",
+				Help:      "Total number of BOSH Director response body bytes served from the cache instead of being transferred again.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		directorSessionCollector = NewDirectorSessionCollector(namespace, environment, boshName, boshUUID, session)
+	})
+
+	Describe("Describe", func() {
+		var (
+			descriptions chan *prometheus.Desc
+		)
+
+		BeforeEach(func() {
+			descriptions = make(chan *prometheus.Desc)
+		})
+
+		JustBeforeEach(func() {
+			go directorSessionCollector.Describe(descriptions)
+		})
+
+		It("returns a director_connections_total description", func() {
+			Eventually(descriptions).Should(Receive(Equal(directorConnectionsMetric.WithLabelValues("true").Desc())))
+		})
+
+		It("returns a director_connection_reuse_ratio description", func() {
+			Eventually(descriptions).Should(Receive(Equal(directorConnectionReuseRatioMetric.Desc())))
+		})
+
+		It("returns a director_response_cache_requests_total description", func() {
+			Eventually(descriptions).Should(Receive(Equal(responseCacheRequestsMetric.WithLabelValues("hit").Desc())))
+		})
+
+		It("returns a director_response_cache_saved_bytes_total description", func() {
+			Eventually(descriptions).Should(Receive(Equal(responseCacheSavedBytesMetric.Desc())))
+		})
+	})
+
+	Describe("Collect", func() {
+		var (
+			metrics chan prometheus.Metric
+		)
+
+		BeforeEach(func() {
+			for i := 0; i < 2; i++ {
+				resp, err := session.HTTPClient().Get(server.URL)
+				Expect(err).ToNot(HaveOccurred())
+				ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+
+			directorConnectionsMetric.WithLabelValues("false").Add(1)
+			directorConnectionsMetric.WithLabelValues("true").Add(1)
+			directorConnectionReuseRatioMetric.Set(0.5)
+
+			metrics = make(chan prometheus.Metric)
+		})
+
+		JustBeforeEach(func() {
+			go directorSessionCollector.Collect(metrics)
+		})
+
+		It("returns director_connections_total metrics", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(directorConnectionsMetric.WithLabelValues("false"))))
+			Eventually(metrics).Should(Receive(PrometheusMetric(directorConnectionsMetric.WithLabelValues("true"))))
+		})
+
+		It("returns a director_connection_reuse_ratio metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(directorConnectionReuseRatioMetric)))
+		})
+
+		It("does not return director_response_cache metrics", func() {
+			Eventually(metrics).Should(Receive())
+			Eventually(metrics).Should(Receive())
+			Eventually(metrics).Should(Receive())
+			Consistently(metrics).ShouldNot(Receive())
+		})
+
+		Context("when conditional requests are enabled", func() {
+			BeforeEach(func() {
+				session.CacheResponses(1024)
+				for i := 0; i < 2; i++ {
+					resp, err := session.HTTPClient().Get(server.URL)
+					Expect(err).ToNot(HaveOccurred())
+					ioutil.ReadAll(resp.Body)
+					resp.Body.Close()
+				}
+
+				responseCacheRequestsMetric.WithLabelValues("hit").Add(1)
+				responseCacheRequestsMetric.WithLabelValues("miss").Add(1)
+				responseCacheSavedBytesMetric.Add(2)
+			})
+
+			It("returns director_response_cache_requests_total metrics", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(responseCacheRequestsMetric.WithLabelValues("hit"))))
+				Eventually(metrics).Should(Receive(PrometheusMetric(responseCacheRequestsMetric.WithLabelValues("miss"))))
+			})
+
+			It("returns a director_response_cache_saved_bytes_total metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(responseCacheSavedBytesMetric)))
+			})
+		})
+	})
+})
@@ -0,0 +1,69 @@
+package collectors
+
+import (
+	"crypto/x509"
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+)
+
+const (
+	ErrorModeDegraded = "degraded"
+	ErrorModeStrict   = "strict"
+)
+
+const (
+	ErrorClassAuth       = "auth"
+	ErrorClassTimeout    = "timeout"
+	ErrorClassTLS        = "tls"
+	ErrorClassConnection = "connection"
+	ErrorClassDirector   = "director"
+	ErrorClassCollector  = "collector"
+	ErrorClassUnknown    = "unknown"
+)
+
+var directorStatusCodeRegexp = regexp.MustCompile(`status code '(\d+)'`)
+
+// ErrorClass classifies an error returned while fetching BOSH. The bosh-cli
+// wraps errors into plain messages, so the message is inspected when the
+// error type is lost.
+func ErrorClass(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	if errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &certificateInvalidErr) {
+		return ErrorClassTLS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ErrorClassConnection
+	}
+
+	message := strings.ToLower(err.Error())
+	if matches := directorStatusCodeRegexp.FindStringSubmatch(message); matches != nil {
+		if matches[1] == "401" || matches[1] == "403" {
+			return ErrorClassAuth
+		}
+		return ErrorClassDirector
+	}
+
+	switch {
+	case strings.Contains(message, "timeout") || strings.Contains(message, "deadline exceeded"):
+		return ErrorClassTimeout
+	case strings.Contains(message, "x509:") || strings.Contains(message, "tls:"):
+		return ErrorClassTLS
+	case strings.Contains(message, "connection refused") || strings.Contains(message, "no such host") || strings.Contains(message, "connection reset") || strings.Contains(message, "dial tcp"):
+		return ErrorClassConnection
+	case strings.Contains(message, "unauthorized") || strings.Contains(message, "invalid_token") || strings.Contains(message, "oidc token"):
+		return ErrorClassAuth
+	}
+
+	return ErrorClassUnknown
+}
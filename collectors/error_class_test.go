@@ -0,0 +1,43 @@
+package collectors_test
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+)
+
+var _ = Describe("ErrorClass", func() {
+	It("classifies timeouts", func() {
+		Expect(ErrorClass(context.DeadlineExceeded)).To(Equal(ErrorClassTimeout))
+		Expect(ErrorClass(&net.DNSError{Err: "i/o timeout", IsTimeout: true})).To(Equal(ErrorClassTimeout))
+	})
+
+	It("classifies TLS errors", func() {
+		Expect(ErrorClass(x509.UnknownAuthorityError{})).To(Equal(ErrorClassTLS))
+		Expect(ErrorClass(errors.New("Performing request GET 'https://10.0.0.6:25555/info': x509: certificate signed by unknown authority"))).To(Equal(ErrorClassTLS))
+	})
+
+	It("classifies connection errors", func() {
+		Expect(ErrorClass(&net.OpError{Op: "dial", Err: errors.New("connection refused")})).To(Equal(ErrorClassConnection))
+		Expect(ErrorClass(errors.New("Performing request GET 'https://10.0.0.6:25555/info': dial tcp 10.0.0.6:25555: connect: connection refused"))).To(Equal(ErrorClassConnection))
+	})
+
+	It("classifies authentication errors", func() {
+		Expect(ErrorClass(errors.New("Director responded with non-successful status code '401' response 'Not authorized'"))).To(Equal(ErrorClassAuth))
+		Expect(ErrorClass(errors.New("Error while requesting OIDC token from `https://idp`: 400 Bad Request"))).To(Equal(ErrorClassAuth))
+	})
+
+	It("classifies Director errors", func() {
+		Expect(ErrorClass(errors.New("Director responded with non-successful status code '500' response ''"))).To(Equal(ErrorClassDirector))
+	})
+
+	It("does not classify other errors", func() {
+		Expect(ErrorClass(errors.New("no deployments"))).To(Equal(ErrorClassUnknown))
+	})
+})
@@ -0,0 +1,19 @@
+package collectors
+
+const (
+	EventTypeNormal  = "Normal"
+	EventTypeWarning = "Warning"
+
+	EventReasonDirectorReachable           = "DirectorReachable"
+	EventReasonDirectorUnreachable         = "DirectorUnreachable"
+	EventReasonDirectorAuthFailed          = "DirectorAuthFailed"
+	EventReasonServiceDiscoveryWritten     = "ServiceDiscoveryWritten"
+	EventReasonServiceDiscoveryWriteFailed = "ServiceDiscoveryWriteFailed"
+)
+
+// EventRecorder is told about exporter state changes worth surfacing outside
+// of Prometheus. Implementations are expected to only act when the reason
+// recorded for a key changes.
+type EventRecorder interface {
+	Transition(key string, eventType string, reason string, message string)
+}
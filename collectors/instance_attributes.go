@@ -0,0 +1,29 @@
+package collectors
+
+import (
+	"regexp"
+)
+
+var invalidLabelNameCharsRegexp = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func instanceAttributeLabelName(attribute string) string {
+	return "bosh_job_attribute_" + invalidLabelNameCharsRegexp.ReplaceAllString(attribute, "_")
+}
+
+func instanceAttributeLabelNames(instanceAttributes []string) ([]string, []string) {
+	attributes := []string{}
+	labelNames := []string{}
+
+	seenLabelNames := map[string]bool{}
+	for _, attribute := range instanceAttributes {
+		labelName := instanceAttributeLabelName(attribute)
+		if attribute == "" || seenLabelNames[labelName] {
+			continue
+		}
+		seenLabelNames[labelName] = true
+		attributes = append(attributes, attribute)
+		labelNames = append(labelNames, labelName)
+	}
+
+	return attributes, labelNames
+}
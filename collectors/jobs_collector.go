@@ -4,27 +4,50 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
 	"github.com/bosh-prometheus/bosh_exporter/filters"
 )
 
+type diskUsageSample struct {
+	usedBytes float64
+	at        time.Time
+}
+
 type JobsCollector struct {
+	instanceAttributes                  []string
+	persistentDiskGrowthWindow          time.Duration
+	kbSeries                            bool
+	labelStoppedDeployments             bool
+	persistentDiskUsageSamples          map[string][]diskUsageSample
 	azsFilter                           *filters.AZsFilter
+	deploymentProcessesFilter           *filters.DeploymentProcessesFilter
+	expressionFilter                    *filters.ExpressionFilter
 	cidrsFilter                         *filters.CidrFilter
 	jobHealthyMetric                    *prometheus.GaugeVec
+	jobAttributesInfoMetric             *prometheus.GaugeVec
+	jobVMCreatedAtMetric                *prometheus.GaugeVec
+	jobVMCreatedByMetric                *prometheus.GaugeVec
+	jobTrustedCertsGenerationMetric     *prometheus.GaugeVec
+	jobTrustedCertsNotConvergedMetric   *prometheus.GaugeVec
+	directorTrustedCertsMetric          *prometheus.GaugeVec
 	jobLoadAvg01Metric                  *prometheus.GaugeVec
 	jobLoadAvg05Metric                  *prometheus.GaugeVec
 	jobLoadAvg15Metric                  *prometheus.GaugeVec
 	jobCPUSysMetric                     *prometheus.GaugeVec
 	jobCPUUserMetric                    *prometheus.GaugeVec
 	jobCPUWaitMetric                    *prometheus.GaugeVec
+	jobCPUStealMetric                   *prometheus.GaugeVec
 	jobMemKBMetric                      *prometheus.GaugeVec
+	jobMemBytesMetric                   *prometheus.GaugeVec
 	jobMemPercentMetric                 *prometheus.GaugeVec
 	jobSwapKBMetric                     *prometheus.GaugeVec
+	jobSwapBytesMetric                  *prometheus.GaugeVec
 	jobSwapPercentMetric                *prometheus.GaugeVec
 	jobSystemDiskInodePercentMetric     *prometheus.GaugeVec
 	jobSystemDiskPercentMetric          *prometheus.GaugeVec
@@ -32,13 +55,19 @@ type JobsCollector struct {
 	jobEphemeralDiskPercentMetric       *prometheus.GaugeVec
 	jobPersistentDiskInodePercentMetric *prometheus.GaugeVec
 	jobPersistentDiskPercentMetric      *prometheus.GaugeVec
+	jobPersistentDiskSizeBytesMetric    *prometheus.GaugeVec
+	jobPersistentDiskGrowthMetric       *prometheus.GaugeVec
 	jobProcessHealthyMetric             *prometheus.GaugeVec
 	jobProcessUptimeMetric              *prometheus.GaugeVec
 	jobProcessCPUTotalMetric            *prometheus.GaugeVec
+	jobProcessCPULimitMetric            *prometheus.GaugeVec
+	jobProcessCPUThrottledPercentMetric *prometheus.GaugeVec
 	jobProcessMemKBMetric               *prometheus.GaugeVec
+	jobProcessMemBytesMetric            *prometheus.GaugeVec
 	jobProcessMemPercentMetric          *prometheus.GaugeVec
 	lastJobsScrapeTimestampMetric       prometheus.Gauge
 	lastJobsScrapeDurationSecondsMetric prometheus.Gauge
+	mu                                  *sync.Mutex
 }
 
 func NewJobsCollector(
@@ -46,9 +75,24 @@ func NewJobsCollector(
 	environment string,
 	boshName string,
 	boshUUID string,
+	instanceAttributes []string,
+	persistentDiskGrowthWindow time.Duration,
+	kbSeries bool,
+	labelStoppedDeployments bool,
 	azsFilter *filters.AZsFilter,
+	deploymentProcessesFilter *filters.DeploymentProcessesFilter,
+	expressionFilter *filters.ExpressionFilter,
 	cidrsFilter *filters.CidrFilter,
 ) *JobsCollector {
+	instanceAttributes, instanceAttributeLabels := instanceAttributeLabelNames(instanceAttributes)
+
+	healthyLabels := []string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"}
+	processHealthyLabels := []string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"}
+	if labelStoppedDeployments {
+		healthyLabels = append(healthyLabels, "bosh_job_intentionally_stopped")
+		processHealthyLabels = append(processHealthyLabels, "bosh_job_intentionally_stopped")
+	}
+
 	jobHealthyMetric := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -61,7 +105,97 @@ func NewJobsCollector(
 				"bosh_uuid":   boshUUID,
 			},
 		},
-		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
+		healthyLabels,
+	)
+
+	jobAttributesInfoMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job",
+			Name:      "attributes_info",
+			Help:      "BOSH Job instance attributes (always 1).",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		append([]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"}, instanceAttributeLabels...),
+	)
+
+	jobVMCreatedAtMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job",
+			Name:      "vm_created_at_timestamp",
+			Help:      "Number of seconds since 1970 since the BOSH Job VM was created.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_vm_cid"},
+	)
+
+	jobVMCreatedByMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job",
+			Name:      "vm_created_by",
+			Help:      "BOSH Job VM creator (always 1), when reported by the BOSH Director.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_vm_created_by"},
+	)
+
+	jobTrustedCertsGenerationMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job",
+			Name:      "trusted_certs_generation_info",
+			Help:      "BOSH Job VM trusted certificates generation, the SHA1 of the trusted certificates installed on the VM (always 1), when reported by the BOSH Director.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_trusted_certs_sha1"},
+	)
+
+	jobTrustedCertsNotConvergedMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job",
+			Name:      "trusted_certs_not_converged_instances",
+			Help:      "Number of BOSH Job instances whose VM trusted certificates generation is not the latest one of the BOSH Director, when both are reported by the BOSH Director.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name"},
+	)
+
+	directorTrustedCertsMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "director",
+			Name:      "trusted_certs_generation_info",
+			Help:      "BOSH Director latest trusted certificates generation, the SHA1 of the trusted certificates it installs on the VMs (always 1), when reported by the BOSH Director.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_trusted_certs_sha1"},
 	)
 
 	jobLoadAvg01Metric := prometheus.NewGaugeVec(
@@ -154,6 +288,21 @@ func NewJobsCollector(
 		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
 	)
 
+	jobCPUStealMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job",
+			Name:      "cpu_steal",
+			Help:      "BOSH Job CPU Steal, the CPU time stolen by the hypervisor for other VMs.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
+	)
+
 	jobMemKBMetric := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -169,6 +318,21 @@ func NewJobsCollector(
 		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
 	)
 
+	jobMemBytesMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job",
+			Name:      "mem_bytes",
+			Help:      "BOSH Job Memory in bytes.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
+	)
+
 	jobMemPercentMetric := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -199,6 +363,21 @@ func NewJobsCollector(
 		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
 	)
 
+	jobSwapBytesMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job",
+			Name:      "swap_bytes",
+			Help:      "BOSH Job Swap in bytes.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
+	)
+
 	jobSwapPercentMetric := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -304,6 +483,36 @@ func NewJobsCollector(
 		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
 	)
 
+	jobPersistentDiskSizeBytesMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job",
+			Name:      "persistent_disk_size_bytes",
+			Help:      "BOSH Job Persistent Disk size in bytes, as declared in the deployment manifest.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
+	)
+
+	jobPersistentDiskGrowthMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job",
+			Name:      "persistent_disk_growth_bytes_per_hour",
+			Help:      "BOSH Job Persistent Disk usage growth rate in bytes per hour.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
+	)
+
 	jobProcessHealthyMetric := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -316,7 +525,7 @@ func NewJobsCollector(
 				"bosh_uuid":   boshUUID,
 			},
 		},
-		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name"},
+		processHealthyLabels,
 	)
 
 	jobProcessUptimeMetric := prometheus.NewGaugeVec(
@@ -331,7 +540,7 @@ func NewJobsCollector(
 				"bosh_uuid":   boshUUID,
 			},
 		},
-		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name"},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
 	)
 
 	jobProcessCPUTotalMetric := prometheus.NewGaugeVec(
@@ -346,7 +555,37 @@ func NewJobsCollector(
 				"bosh_uuid":   boshUUID,
 			},
 		},
-		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name"},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
+	)
+
+	jobProcessCPULimitMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job_process",
+			Name:      "cpu_limit",
+			Help:      "BOSH Job Process CPU Limit, the CPU allocated to the process cgroup in the unit of the CPU Total.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
+	)
+
+	jobProcessCPUThrottledPercentMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job_process",
+			Name:      "cpu_throttled_percent",
+			Help:      "BOSH Job Process CPU Throttled Percent, the percentage of the process cgroup CPU periods during which it was throttled.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
 	)
 
 	jobProcessMemKBMetric := prometheus.NewGaugeVec(
@@ -361,7 +600,22 @@ func NewJobsCollector(
 				"bosh_uuid":   boshUUID,
 			},
 		},
-		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name"},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
+	)
+
+	jobProcessMemBytesMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job_process",
+			Name:      "mem_bytes",
+			Help:      "BOSH Job Process Memory in bytes.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
 	)
 
 	jobProcessMemPercentMetric := prometheus.NewGaugeVec(
@@ -376,7 +630,7 @@ func NewJobsCollector(
 				"bosh_uuid":   boshUUID,
 			},
 		},
-		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name"},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
 	)
 
 	lastJobsScrapeTimestampMetric := prometheus.NewGauge(
@@ -408,18 +662,34 @@ func NewJobsCollector(
 	)
 
 	collector := &JobsCollector{
+		instanceAttributes:                  instanceAttributes,
+		persistentDiskGrowthWindow:          persistentDiskGrowthWindow,
+		kbSeries:                            kbSeries,
+		labelStoppedDeployments:             labelStoppedDeployments,
+		persistentDiskUsageSamples:          map[string][]diskUsageSample{},
 		azsFilter:                           azsFilter,
+		deploymentProcessesFilter:           deploymentProcessesFilter,
+		expressionFilter:                    expressionFilter,
 		cidrsFilter:                         cidrsFilter,
 		jobHealthyMetric:                    jobHealthyMetric,
+		jobAttributesInfoMetric:             jobAttributesInfoMetric,
+		jobVMCreatedAtMetric:                jobVMCreatedAtMetric,
+		jobVMCreatedByMetric:                jobVMCreatedByMetric,
+		jobTrustedCertsGenerationMetric:     jobTrustedCertsGenerationMetric,
+		jobTrustedCertsNotConvergedMetric:   jobTrustedCertsNotConvergedMetric,
+		directorTrustedCertsMetric:          directorTrustedCertsMetric,
 		jobLoadAvg01Metric:                  jobLoadAvg01Metric,
 		jobLoadAvg05Metric:                  jobLoadAvg05Metric,
 		jobLoadAvg15Metric:                  jobLoadAvg15Metric,
 		jobCPUSysMetric:                     jobCPUSysMetric,
 		jobCPUUserMetric:                    jobCPUUserMetric,
 		jobCPUWaitMetric:                    jobCPUWaitMetric,
+		jobCPUStealMetric:                   jobCPUStealMetric,
 		jobMemKBMetric:                      jobMemKBMetric,
+		jobMemBytesMetric:                   jobMemBytesMetric,
 		jobMemPercentMetric:                 jobMemPercentMetric,
 		jobSwapKBMetric:                     jobSwapKBMetric,
+		jobSwapBytesMetric:                  jobSwapBytesMetric,
 		jobSwapPercentMetric:                jobSwapPercentMetric,
 		jobSystemDiskInodePercentMetric:     jobSystemDiskInodePercentMetric,
 		jobSystemDiskPercentMetric:          jobSystemDiskPercentMetric,
@@ -427,31 +697,50 @@ func NewJobsCollector(
 		jobEphemeralDiskPercentMetric:       jobEphemeralDiskPercentMetric,
 		jobPersistentDiskInodePercentMetric: jobPersistentDiskInodePercentMetric,
 		jobPersistentDiskPercentMetric:      jobPersistentDiskPercentMetric,
+		jobPersistentDiskSizeBytesMetric:    jobPersistentDiskSizeBytesMetric,
+		jobPersistentDiskGrowthMetric:       jobPersistentDiskGrowthMetric,
 		jobProcessHealthyMetric:             jobProcessHealthyMetric,
 		jobProcessUptimeMetric:              jobProcessUptimeMetric,
 		jobProcessCPUTotalMetric:            jobProcessCPUTotalMetric,
+		jobProcessCPULimitMetric:            jobProcessCPULimitMetric,
+		jobProcessCPUThrottledPercentMetric: jobProcessCPUThrottledPercentMetric,
 		jobProcessMemKBMetric:               jobProcessMemKBMetric,
+		jobProcessMemBytesMetric:            jobProcessMemBytesMetric,
 		jobProcessMemPercentMetric:          jobProcessMemPercentMetric,
 		lastJobsScrapeTimestampMetric:       lastJobsScrapeTimestampMetric,
 		lastJobsScrapeDurationSecondsMetric: lastJobsScrapeDurationSecondsMetric,
+		mu:                                  &sync.Mutex{},
 	}
 	return collector
 }
 
-func (c *JobsCollector) Collect(deployments []deployments.DeploymentInfo, ch chan<- prometheus.Metric) error {
+func (c *JobsCollector) Collect(snapshot fetcher.Snapshot, ch chan<- prometheus.Metric) error {
+	return c.CollectStream(snapshot, snapshot.EachDeployment, ch)
+}
+
+func (c *JobsCollector) CollectStream(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
 	var err error
 	var begun = time.Now()
 
 	c.jobHealthyMetric.Reset()
+	c.jobAttributesInfoMetric.Reset()
+	c.jobVMCreatedAtMetric.Reset()
+	c.jobVMCreatedByMetric.Reset()
+	c.jobTrustedCertsGenerationMetric.Reset()
+	c.jobTrustedCertsNotConvergedMetric.Reset()
+	c.directorTrustedCertsMetric.Reset()
 	c.jobLoadAvg01Metric.Reset()
 	c.jobLoadAvg05Metric.Reset()
 	c.jobLoadAvg15Metric.Reset()
 	c.jobCPUSysMetric.Reset()
 	c.jobCPUUserMetric.Reset()
 	c.jobCPUWaitMetric.Reset()
+	c.jobCPUStealMetric.Reset()
 	c.jobMemKBMetric.Reset()
+	c.jobMemBytesMetric.Reset()
 	c.jobMemPercentMetric.Reset()
 	c.jobSwapKBMetric.Reset()
+	c.jobSwapBytesMetric.Reset()
 	c.jobSwapPercentMetric.Reset()
 	c.jobSystemDiskInodePercentMetric.Reset()
 	c.jobSystemDiskPercentMetric.Reset()
@@ -459,26 +748,58 @@ func (c *JobsCollector) Collect(deployments []deployments.DeploymentInfo, ch cha
 	c.jobEphemeralDiskPercentMetric.Reset()
 	c.jobPersistentDiskInodePercentMetric.Reset()
 	c.jobPersistentDiskPercentMetric.Reset()
+	c.jobPersistentDiskSizeBytesMetric.Reset()
+	c.jobPersistentDiskGrowthMetric.Reset()
 	c.jobProcessHealthyMetric.Reset()
 	c.jobProcessUptimeMetric.Reset()
 	c.jobProcessCPUTotalMetric.Reset()
+	c.jobProcessCPULimitMetric.Reset()
+	c.jobProcessCPUThrottledPercentMetric.Reset()
 	c.jobProcessMemKBMetric.Reset()
+	c.jobProcessMemBytesMetric.Reset()
 	c.jobProcessMemPercentMetric.Reset()
 
-	for _, deployment := range deployments {
-		err = c.reportJobMetrics(deployment, ch)
+	fetchedAt := snapshot.FetchedAt
+	if fetchedAt.IsZero() {
+		fetchedAt = begun
+	}
+
+	if snapshot.Director.TrustedCertsSHA1 != "" {
+		c.directorTrustedCertsMetric.WithLabelValues(snapshot.Director.TrustedCertsSHA1).Set(float64(1))
 	}
 
+	c.mu.Lock()
+	seenInstances := map[string]bool{}
+	streamErr := eachDeployment(func(deployment deployments.DeploymentInfo) error {
+		err = c.reportJobMetrics(deployment, snapshot.Director.TrustedCertsSHA1, fetchedAt, seenInstances, ch)
+		return nil
+	})
+	for key := range c.persistentDiskUsageSamples {
+		if !seenInstances[key] {
+			delete(c.persistentDiskUsageSamples, key)
+		}
+	}
+	c.mu.Unlock()
+
 	c.jobHealthyMetric.Collect(ch)
+	c.jobAttributesInfoMetric.Collect(ch)
+	c.jobVMCreatedAtMetric.Collect(ch)
+	c.jobVMCreatedByMetric.Collect(ch)
+	c.jobTrustedCertsGenerationMetric.Collect(ch)
+	c.jobTrustedCertsNotConvergedMetric.Collect(ch)
+	c.directorTrustedCertsMetric.Collect(ch)
 	c.jobLoadAvg01Metric.Collect(ch)
 	c.jobLoadAvg05Metric.Collect(ch)
 	c.jobLoadAvg15Metric.Collect(ch)
 	c.jobCPUSysMetric.Collect(ch)
 	c.jobCPUUserMetric.Collect(ch)
 	c.jobCPUWaitMetric.Collect(ch)
+	c.jobCPUStealMetric.Collect(ch)
 	c.jobMemKBMetric.Collect(ch)
+	c.jobMemBytesMetric.Collect(ch)
 	c.jobMemPercentMetric.Collect(ch)
 	c.jobSwapKBMetric.Collect(ch)
+	c.jobSwapBytesMetric.Collect(ch)
 	c.jobSwapPercentMetric.Collect(ch)
 	c.jobSystemDiskInodePercentMetric.Collect(ch)
 	c.jobSystemDiskPercentMetric.Collect(ch)
@@ -486,10 +807,15 @@ func (c *JobsCollector) Collect(deployments []deployments.DeploymentInfo, ch cha
 	c.jobEphemeralDiskPercentMetric.Collect(ch)
 	c.jobPersistentDiskInodePercentMetric.Collect(ch)
 	c.jobPersistentDiskPercentMetric.Collect(ch)
+	c.jobPersistentDiskSizeBytesMetric.Collect(ch)
+	c.jobPersistentDiskGrowthMetric.Collect(ch)
 	c.jobProcessHealthyMetric.Collect(ch)
 	c.jobProcessUptimeMetric.Collect(ch)
 	c.jobProcessCPUTotalMetric.Collect(ch)
+	c.jobProcessCPULimitMetric.Collect(ch)
+	c.jobProcessCPUThrottledPercentMetric.Collect(ch)
 	c.jobProcessMemKBMetric.Collect(ch)
+	c.jobProcessMemBytesMetric.Collect(ch)
 	c.jobProcessMemPercentMetric.Collect(ch)
 
 	c.lastJobsScrapeTimestampMetric.Set(float64(time.Now().Unix()))
@@ -498,20 +824,32 @@ func (c *JobsCollector) Collect(deployments []deployments.DeploymentInfo, ch cha
 	c.lastJobsScrapeDurationSecondsMetric.Set(time.Since(begun).Seconds())
 	c.lastJobsScrapeDurationSecondsMetric.Collect(ch)
 
+	if streamErr != nil {
+		return streamErr
+	}
 	return err
 }
 
 func (c *JobsCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.jobHealthyMetric.Describe(ch)
+	c.jobAttributesInfoMetric.Describe(ch)
+	c.jobVMCreatedAtMetric.Describe(ch)
+	c.jobVMCreatedByMetric.Describe(ch)
+	c.jobTrustedCertsGenerationMetric.Describe(ch)
+	c.jobTrustedCertsNotConvergedMetric.Describe(ch)
+	c.directorTrustedCertsMetric.Describe(ch)
 	c.jobLoadAvg01Metric.Describe(ch)
 	c.jobLoadAvg05Metric.Describe(ch)
 	c.jobLoadAvg15Metric.Describe(ch)
 	c.jobCPUSysMetric.Describe(ch)
 	c.jobCPUUserMetric.Describe(ch)
 	c.jobCPUWaitMetric.Describe(ch)
+	c.jobCPUStealMetric.Describe(ch)
 	c.jobMemKBMetric.Describe(ch)
+	c.jobMemBytesMetric.Describe(ch)
 	c.jobMemPercentMetric.Describe(ch)
 	c.jobSwapKBMetric.Describe(ch)
+	c.jobSwapBytesMetric.Describe(ch)
 	c.jobSwapPercentMetric.Describe(ch)
 	c.jobSystemDiskInodePercentMetric.Describe(ch)
 	c.jobSystemDiskPercentMetric.Describe(ch)
@@ -519,20 +857,31 @@ func (c *JobsCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.jobEphemeralDiskPercentMetric.Describe(ch)
 	c.jobPersistentDiskInodePercentMetric.Describe(ch)
 	c.jobPersistentDiskPercentMetric.Describe(ch)
+	c.jobPersistentDiskSizeBytesMetric.Describe(ch)
+	c.jobPersistentDiskGrowthMetric.Describe(ch)
 	c.jobProcessHealthyMetric.Describe(ch)
 	c.jobProcessUptimeMetric.Describe(ch)
 	c.jobProcessCPUTotalMetric.Describe(ch)
+	c.jobProcessCPULimitMetric.Describe(ch)
+	c.jobProcessCPUThrottledPercentMetric.Describe(ch)
 	c.jobProcessMemKBMetric.Describe(ch)
+	c.jobProcessMemBytesMetric.Describe(ch)
 	c.jobProcessMemPercentMetric.Describe(ch)
 	c.lastJobsScrapeTimestampMetric.Describe(ch)
 	c.lastJobsScrapeDurationSecondsMetric.Describe(ch)
 }
 
-func (c *JobsCollector) reportJobMetrics(deployment deployments.DeploymentInfo, ch chan<- prometheus.Metric) error {
+func (c *JobsCollector) reportJobMetrics(
+	deployment deployments.DeploymentInfo,
+	directorTrustedCertsSHA1 string,
+	fetchedAt time.Time,
+	seenInstances map[string]bool,
+	ch chan<- prometheus.Metric,
+) error {
 	var err error
 
 	for _, instance := range deployment.Instances {
-		if !c.azsFilter.Enabled(instance.AZ) {
+		if !c.azsFilter.EnabledInstance(instance.AZ, instance.Attributes) {
 			continue
 		}
 
@@ -543,7 +892,16 @@ func (c *JobsCollector) reportJobMetrics(deployment deployments.DeploymentInfo,
 		jobAZ := instance.AZ
 		jobIP, _ := c.cidrsFilter.Select(instance.IPs)
 
-		err = c.jobHealthyMetrics(ch, instance.Healthy, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
+		expressionFields := map[string]string{"deployment": deploymentName, "job": jobName, "az": jobAZ, "ip": jobIP}
+		if !c.expressionFilter.Enabled(expressionFields) {
+			continue
+		}
+
+		err = c.jobHealthyMetrics(ch, instance.Healthy, deployment, jobName, jobID, jobIndex, jobAZ, jobIP)
+		err = c.jobAttributesInfoMetrics(ch, instance.Attributes, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
+		err = c.jobVMCreatedAtMetrics(ch, instance.VMID, instance.VMCreatedAt, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
+		err = c.jobVMCreatedByMetrics(ch, instance.VMCreatedBy, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
+		err = c.jobTrustedCertsMetrics(ch, instance.TrustedCertsSHA1, directorTrustedCertsSHA1, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
 		err = c.jobLoadAvgMetrics(ch, instance.Vitals.Load, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
 		err = c.jobCPUMetrics(ch, instance.Vitals.CPU, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
 		err = c.jobMemMetrics(ch, instance.Vitals.Mem, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
@@ -551,14 +909,24 @@ func (c *JobsCollector) reportJobMetrics(deployment deployments.DeploymentInfo,
 		err = c.jobSystemDiskMetrics(ch, instance.Vitals.SystemDisk, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
 		err = c.jobEphemeralDiskMetrics(ch, instance.Vitals.EphemeralDisk, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
 		err = c.jobPersistentDiskMetrics(ch, instance.Vitals.PersistentDisk, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
+		seenInstances[deploymentName+"/"+jobID] = true
+		err = c.jobPersistentDiskGrowthMetrics(ch, instance.Vitals.PersistentDisk, instance.PersistentDiskSizeMB, fetchedAt, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP)
 
 		for _, process := range instance.Processes {
+			if !c.deploymentProcessesFilter.Enabled(deploymentName, process.Name) {
+				continue
+			}
+			expressionFields["process"] = process.Name
+			if !c.expressionFilter.Enabled(expressionFields) {
+				continue
+			}
 			jobProcessName := process.Name
+			jobProcessJobTemplate := process.JobTemplate
 
-			err = c.jobProcessHealthyMetrics(ch, process.Healthy, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, jobProcessName)
-			err = c.jobProcessUptimeMetrics(ch, process.Uptime, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, jobProcessName)
-			err = c.jobProcessCPUMetrics(ch, process.CPU, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, jobProcessName)
-			err = c.jobProcessMemMetrics(ch, process.Mem, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, jobProcessName)
+			err = c.jobProcessHealthyMetrics(ch, process.Healthy, deployment, jobName, jobID, jobIndex, jobAZ, jobIP, jobProcessName, jobProcessJobTemplate)
+			err = c.jobProcessUptimeMetrics(ch, process.Uptime, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, jobProcessName, jobProcessJobTemplate)
+			err = c.jobProcessCPUMetrics(ch, process.CPU, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, jobProcessName, jobProcessJobTemplate)
+			err = c.jobProcessMemMetrics(ch, process.Mem, deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, jobProcessName, jobProcessJobTemplate)
 		}
 	}
 
@@ -568,7 +936,7 @@ func (c *JobsCollector) reportJobMetrics(deployment deployments.DeploymentInfo,
 func (c *JobsCollector) jobHealthyMetrics(
 	ch chan<- prometheus.Metric,
 	healthy bool,
-	deploymentName string,
+	deployment deployments.DeploymentInfo,
 	jobName string,
 	jobID string,
 	jobIndex string,
@@ -580,14 +948,121 @@ func (c *JobsCollector) jobHealthyMetrics(
 		healthyMetric = 1
 	}
 
-	c.jobHealthyMetric.WithLabelValues(
+	labelValues := []string{deployment.Name, jobName, jobID, jobIndex, jobAZ, jobIP}
+	if c.labelStoppedDeployments {
+		labelValues = append(labelValues, intentionallyStoppedLabelValue(deployment))
+	}
+	c.jobHealthyMetric.WithLabelValues(labelValues...).Set(healthyMetric)
+
+	return nil
+}
+
+func (c *JobsCollector) jobAttributesInfoMetrics(
+	ch chan<- prometheus.Metric,
+	attributes map[string]string,
+	deploymentName string,
+	jobName string,
+	jobID string,
+	jobIndex string,
+	jobAZ string,
+	jobIP string,
+) error {
+	if len(c.instanceAttributes) == 0 {
+		return nil
+	}
+
+	labelValues := []string{deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP}
+	for _, attribute := range c.instanceAttributes {
+		labelValues = append(labelValues, attributes[attribute])
+	}
+	c.jobAttributesInfoMetric.WithLabelValues(labelValues...).Set(float64(1))
+
+	return nil
+}
+
+func (c *JobsCollector) jobVMCreatedAtMetrics(
+	ch chan<- prometheus.Metric,
+	vmID string,
+	vmCreatedAt time.Time,
+	deploymentName string,
+	jobName string,
+	jobID string,
+	jobIndex string,
+	jobAZ string,
+	jobIP string,
+) error {
+	if !vmCreatedAt.IsZero() {
+		c.jobVMCreatedAtMetric.WithLabelValues(
+			deploymentName,
+			jobName,
+			jobID,
+			jobIndex,
+			jobAZ,
+			jobIP,
+			vmID,
+		).Set(float64(vmCreatedAt.Unix()))
+	}
+
+	return nil
+}
+
+func (c *JobsCollector) jobVMCreatedByMetrics(
+	ch chan<- prometheus.Metric,
+	vmCreatedBy string,
+	deploymentName string,
+	jobName string,
+	jobID string,
+	jobIndex string,
+	jobAZ string,
+	jobIP string,
+) error {
+	if vmCreatedBy != "" {
+		c.jobVMCreatedByMetric.WithLabelValues(
+			deploymentName,
+			jobName,
+			jobID,
+			jobIndex,
+			jobAZ,
+			jobIP,
+			vmCreatedBy,
+		).Set(float64(1))
+	}
+
+	return nil
+}
+
+func (c *JobsCollector) jobTrustedCertsMetrics(
+	ch chan<- prometheus.Metric,
+	trustedCertsSHA1 string,
+	directorTrustedCertsSHA1 string,
+	deploymentName string,
+	jobName string,
+	jobID string,
+	jobIndex string,
+	jobAZ string,
+	jobIP string,
+) error {
+	if trustedCertsSHA1 == "" {
+		return nil
+	}
+
+	c.jobTrustedCertsGenerationMetric.WithLabelValues(
 		deploymentName,
 		jobName,
 		jobID,
 		jobIndex,
 		jobAZ,
 		jobIP,
-	).Set(healthyMetric)
+		trustedCertsSHA1,
+	).Set(float64(1))
+
+	if directorTrustedCertsSHA1 != "" {
+		var notConverged float64
+		if trustedCertsSHA1 != directorTrustedCertsSHA1 {
+			notConverged = 1
+		}
+		c.jobTrustedCertsNotConvergedMetric.WithLabelValues(deploymentName, jobName).Add(notConverged)
+	}
 
 	return nil
 }
@@ -718,6 +1193,22 @@ func (c *JobsCollector) jobCPUMetrics(
 		}
 	}
 
+	if cpu.Steal != "" {
+		cpuSteal, err := strconv.ParseFloat(cpu.Steal, 64)
+		if err != nil {
+			err = errors.New(fmt.Sprintf("Error while converting CPU Steal metric for deployment `%s` and job `%s`: %v", deploymentName, jobName, err))
+		} else {
+			c.jobCPUStealMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+			).Set(cpuSteal)
+		}
+	}
+
 	return err
 }
 
@@ -738,14 +1229,24 @@ func (c *JobsCollector) jobMemMetrics(
 		if err != nil {
 			err = errors.New(fmt.Sprintf("Error while converting Mem KB metric for deployment `%s` and job `%s`: %v", deploymentName, jobName, err))
 		} else {
-			c.jobMemKBMetric.WithLabelValues(
+			if c.kbSeries {
+				c.jobMemKBMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+				).Set(memKB)
+			}
+			c.jobMemBytesMetric.WithLabelValues(
 				deploymentName,
 				jobName,
 				jobID,
 				jobIndex,
 				jobAZ,
 				jobIP,
-			).Set(memKB)
+			).Set(memKB * 1024)
 		}
 	}
 
@@ -785,14 +1286,24 @@ func (c *JobsCollector) jobSwapMetrics(
 		if err != nil {
 			err = errors.New(fmt.Sprintf("Error while converting Swap KB metric for deployment `%s` and job `%s`: %v", deploymentName, jobName, err))
 		} else {
-			c.jobSwapKBMetric.WithLabelValues(
+			if c.kbSeries {
+				c.jobSwapKBMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+				).Set(swapKB)
+			}
+			c.jobSwapBytesMetric.WithLabelValues(
 				deploymentName,
 				jobName,
 				jobID,
 				jobIndex,
 				jobAZ,
 				jobIP,
-			).Set(swapKB)
+			).Set(swapKB * 1024)
 		}
 	}
 
@@ -956,31 +1467,91 @@ func (c *JobsCollector) jobPersistentDiskMetrics(
 	return err
 }
 
-func (c *JobsCollector) jobProcessHealthyMetrics(
+func (c *JobsCollector) jobPersistentDiskGrowthMetrics(
 	ch chan<- prometheus.Metric,
-	healthy bool,
+	persistentDisk deployments.Disk,
+	persistentDiskSizeMB uint64,
+	fetchedAt time.Time,
 	deploymentName string,
 	jobName string,
 	jobID string,
 	jobIndex string,
 	jobAZ string,
 	jobIP string,
-	jobProcessName string,
 ) error {
-	var healthyMetric float64
-	if healthy {
-		healthyMetric = 1
+	if persistentDiskSizeMB == 0 {
+		return nil
 	}
 
-	c.jobProcessHealthyMetric.WithLabelValues(
+	persistentDiskSizeBytes := float64(persistentDiskSizeMB) * 1024 * 1024
+	c.jobPersistentDiskSizeBytesMetric.WithLabelValues(
 		deploymentName,
 		jobName,
 		jobID,
 		jobIndex,
 		jobAZ,
 		jobIP,
-		jobProcessName,
-	).Set(healthyMetric)
+	).Set(persistentDiskSizeBytes)
+
+	if persistentDisk.Percent == "" || c.persistentDiskGrowthWindow <= 0 {
+		return nil
+	}
+
+	persistentDiskPercent, err := strconv.ParseFloat(persistentDisk.Percent, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while converting Persistent Disk Percent metric for deployment `%s` and job `%s`: %v", deploymentName, jobName, err))
+	}
+
+	key := deploymentName + "/" + jobID
+	samples := append(c.persistentDiskUsageSamples[key], diskUsageSample{
+		usedBytes: persistentDiskPercent / 100 * persistentDiskSizeBytes,
+		at:        fetchedAt,
+	})
+	for len(samples) > 2 && fetchedAt.Sub(samples[1].at) >= c.persistentDiskGrowthWindow {
+		samples = samples[1:]
+	}
+	c.persistentDiskUsageSamples[key] = samples
+
+	first, last := samples[0], samples[len(samples)-1]
+	elapsedHours := last.at.Sub(first.at).Hours()
+	if elapsedHours <= 0 {
+		return nil
+	}
+
+	c.jobPersistentDiskGrowthMetric.WithLabelValues(
+		deploymentName,
+		jobName,
+		jobID,
+		jobIndex,
+		jobAZ,
+		jobIP,
+	).Set((last.usedBytes - first.usedBytes) / elapsedHours)
+
+	return nil
+}
+
+func (c *JobsCollector) jobProcessHealthyMetrics(
+	ch chan<- prometheus.Metric,
+	healthy bool,
+	deployment deployments.DeploymentInfo,
+	jobName string,
+	jobID string,
+	jobIndex string,
+	jobAZ string,
+	jobIP string,
+	jobProcessName string,
+	jobProcessJobTemplate string,
+) error {
+	var healthyMetric float64
+	if healthy {
+		healthyMetric = 1
+	}
+
+	labelValues := []string{deployment.Name, jobName, jobID, jobIndex, jobAZ, jobIP, jobProcessName, jobProcessJobTemplate}
+	if c.labelStoppedDeployments {
+		labelValues = append(labelValues, intentionallyStoppedLabelValue(deployment))
+	}
+	c.jobProcessHealthyMetric.WithLabelValues(labelValues...).Set(healthyMetric)
 
 	return nil
 }
@@ -995,6 +1566,7 @@ func (c *JobsCollector) jobProcessUptimeMetrics(
 	jobAZ string,
 	jobIP string,
 	jobProcessName string,
+	jobProcessJobTemplate string,
 ) error {
 	if uptime != nil {
 		c.jobProcessUptimeMetric.WithLabelValues(
@@ -1005,6 +1577,7 @@ func (c *JobsCollector) jobProcessUptimeMetrics(
 			jobAZ,
 			jobIP,
 			jobProcessName,
+			jobProcessJobTemplate,
 		).Set(float64(*uptime))
 	}
 
@@ -1021,6 +1594,7 @@ func (c *JobsCollector) jobProcessCPUMetrics(
 	jobAZ string,
 	jobIP string,
 	jobProcessName string,
+	jobProcessJobTemplate string,
 ) error {
 	if cpu.Total != nil {
 		c.jobProcessCPUTotalMetric.WithLabelValues(
@@ -1031,9 +1605,36 @@ func (c *JobsCollector) jobProcessCPUMetrics(
 			jobAZ,
 			jobIP,
 			jobProcessName,
+			jobProcessJobTemplate,
 		).Set(float64(*cpu.Total))
 	}
 
+	if cpu.Limit != nil {
+		c.jobProcessCPULimitMetric.WithLabelValues(
+			deploymentName,
+			jobName,
+			jobID,
+			jobIndex,
+			jobAZ,
+			jobIP,
+			jobProcessName,
+			jobProcessJobTemplate,
+		).Set(*cpu.Limit)
+	}
+
+	if cpu.Throttled != nil {
+		c.jobProcessCPUThrottledPercentMetric.WithLabelValues(
+			deploymentName,
+			jobName,
+			jobID,
+			jobIndex,
+			jobAZ,
+			jobIP,
+			jobProcessName,
+			jobProcessJobTemplate,
+		).Set(*cpu.Throttled)
+	}
+
 	return nil
 }
 
@@ -1047,9 +1648,22 @@ func (c *JobsCollector) jobProcessMemMetrics(
 	jobAZ string,
 	jobIP string,
 	jobProcessName string,
+	jobProcessJobTemplate string,
 ) error {
 	if mem.KB != nil {
-		c.jobProcessMemKBMetric.WithLabelValues(
+		if c.kbSeries {
+			c.jobProcessMemKBMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+				jobProcessName,
+				jobProcessJobTemplate,
+			).Set(float64(*mem.KB))
+		}
+		c.jobProcessMemBytesMetric.WithLabelValues(
 			deploymentName,
 			jobName,
 			jobID,
@@ -1057,7 +1671,8 @@ func (c *JobsCollector) jobProcessMemMetrics(
 			jobAZ,
 			jobIP,
 			jobProcessName,
-		).Set(float64(*mem.KB))
+			jobProcessJobTemplate,
+		).Set(float64(*mem.KB) * 1024)
 	}
 
 	if mem.Percent != nil {
@@ -1069,6 +1684,7 @@ func (c *JobsCollector) jobProcessMemMetrics(
 			jobAZ,
 			jobIP,
 			jobProcessName,
+			jobProcessJobTemplate,
 		).Set(*mem.Percent)
 	}
 
@@ -2,6 +2,7 @@ package collectors_test
 
 import (
 	"strconv"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -10,6 +11,7 @@ import (
 	"github.com/prometheus/common/log"
 
 	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
 	"github.com/bosh-prometheus/bosh_exporter/filters"
 
 	. "github.com/bosh-prometheus/bosh_exporter/collectors"
@@ -22,25 +24,35 @@ func init() {
 
 var _ = Describe("JobsCollector", func() {
 	var (
-		err           error
-		namespace     string
-		environment   string
-		boshName      string
-		boshUUID      string
-		azsFilter     *filters.AZsFilter
-		cidrsFilter   *filters.CidrFilter
-		jobsCollector *JobsCollector
+		err                        error
+		namespace                  string
+		environment                string
+		boshName                   string
+		boshUUID                   string
+		attributes                 []string
+		persistentDiskGrowthWindow time.Duration
+		kbSeries                   bool
+		labelStoppedDeployments    bool
+		azsFilter                  *filters.AZsFilter
+		deploymentProcessesFilter  *filters.DeploymentProcessesFilter
+		expressionFilter           *filters.ExpressionFilter
+		cidrsFilter                *filters.CidrFilter
+		jobsCollector              *JobsCollector
 
 		jobHealthyMetric                    *prometheus.GaugeVec
+		jobVMCreatedAtMetric                *prometheus.GaugeVec
 		jobLoadAvg01Metric                  *prometheus.GaugeVec
 		jobLoadAvg05Metric                  *prometheus.GaugeVec
 		jobLoadAvg15Metric                  *prometheus.GaugeVec
 		jobCPUSysMetric                     *prometheus.GaugeVec
 		jobCPUUserMetric                    *prometheus.GaugeVec
 		jobCPUWaitMetric                    *prometheus.GaugeVec
+		jobCPUStealMetric                   *prometheus.GaugeVec
 		jobMemKBMetric                      *prometheus.GaugeVec
+		jobMemBytesMetric                   *prometheus.GaugeVec
 		jobMemPercentMetric                 *prometheus.GaugeVec
 		jobSwapKBMetric                     *prometheus.GaugeVec
+		jobSwapBytesMetric                  *prometheus.GaugeVec
 		jobSwapPercentMetric                *prometheus.GaugeVec
 		jobSystemDiskInodePercentMetric     *prometheus.GaugeVec
 		jobSystemDiskPercentMetric          *prometheus.GaugeVec
@@ -51,7 +63,10 @@ var _ = Describe("JobsCollector", func() {
 		jobProcessHealthyMetric             *prometheus.GaugeVec
 		jobProcessUptimeMetric              *prometheus.GaugeVec
 		jobProcessCPUTotalMetric            *prometheus.GaugeVec
+		jobProcessCPULimitMetric            *prometheus.GaugeVec
+		jobProcessCPUThrottledPercentMetric *prometheus.GaugeVec
 		jobProcessMemKBMetric               *prometheus.GaugeVec
+		jobProcessMemBytesMetric            *prometheus.GaugeVec
 		jobProcessMemPercentMetric          *prometheus.GaugeVec
 		lastJobsScrapeTimestampMetric       prometheus.Gauge
 		lastJobsScrapeDurationSecondsMetric prometheus.Gauge
@@ -62,10 +77,13 @@ var _ = Describe("JobsCollector", func() {
 		jobIndex                      = "0"
 		jobIP                         = "1.2.3.4"
 		jobAZ                         = "fake-job-az"
+		jobVMID                       = "fake-job-vm-cid"
+		jobVMCreatedAt                = time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
 		jobHealthy                    = true
 		jobCPUSys                     = float64(0.5)
 		jobCPUUser                    = float64(1.0)
 		jobCPUWait                    = float64(1.5)
+		jobCPUSteal                   = float64(2.5)
 		jobMemKB                      = 1000
 		jobMemPercent                 = 10
 		jobSwapKB                     = 2000
@@ -80,9 +98,12 @@ var _ = Describe("JobsCollector", func() {
 		jobPersistentDiskInodePercent = 50
 		jobPersistentDiskPercent      = 60
 		jobProcessName                = "fake-process-name"
+		jobProcessJobTemplate         = "fake-job-template"
 		jobProcessUptime              = uint64(3600)
 		jobProcessHealthy             = true
 		jobProcessCPUTotal            = float64(0.5)
+		jobProcessCPULimit            = float64(200)
+		jobProcessCPUThrottledPercent = float64(12.5)
 		jobProcessMemKB               = uint64(2000)
 		jobProcessMemPercent          = float64(20)
 	)
@@ -92,7 +113,15 @@ var _ = Describe("JobsCollector", func() {
 		environment = "test_environment"
 		boshName = "test_bosh_name"
 		boshUUID = "test_bosh_uuid"
+		attributes = []string{}
+		persistentDiskGrowthWindow = 6 * time.Hour
+		kbSeries = true
+		labelStoppedDeployments = false
 		azsFilter = filters.NewAZsFilter([]string{})
+		deploymentProcessesFilter, err = filters.NewDeploymentProcessesFilter("")
+		Expect(err).ToNot(HaveOccurred())
+		expressionFilter, err = filters.NewExpressionFilter("")
+		Expect(err).ToNot(HaveOccurred())
 		cidrsFilter, err = filters.NewCidrFilter([]string{"0.0.0.0/0"})
 		Expect(err).ToNot(HaveOccurred())
 
@@ -120,6 +149,31 @@ var _ = Describe("JobsCollector", func() {
 			jobIP,
 		).Set(float64(1))
 
+		jobVMCreatedAtMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "job",
+				Name:      "vm_created_at_timestamp",
+				Help:      "Number of seconds since 1970 since the BOSH Job VM was created.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_vm_cid"},
+		)
+
+		jobVMCreatedAtMetric.WithLabelValues(
+			deploymentName,
+			jobName,
+			jobID,
+			jobIndex,
+			jobAZ,
+			jobIP,
+			jobVMID,
+		).Set(float64(jobVMCreatedAt.Unix()))
+
 		jobLoadAvg01Metric = prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -264,6 +318,30 @@ var _ = Describe("JobsCollector", func() {
 			jobIP,
 		).Set(jobCPUWait)
 
+		jobCPUStealMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "job",
+				Name:      "cpu_steal",
+				Help:      "BOSH Job CPU Steal, the CPU time stolen by the hypervisor for other VMs.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
+		)
+
+		jobCPUStealMetric.WithLabelValues(
+			deploymentName,
+			jobName,
+			jobID,
+			jobIndex,
+			jobAZ,
+			jobIP,
+		).Set(jobCPUSteal)
+
 		jobMemKBMetric = prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -288,6 +366,30 @@ var _ = Describe("JobsCollector", func() {
 			jobIP,
 		).Set(float64(jobMemKB))
 
+		jobMemBytesMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "job",
+				Name:      "mem_bytes",
+				Help:      "BOSH Job Memory in bytes.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
+		)
+
+		jobMemBytesMetric.WithLabelValues(
+			deploymentName,
+			jobName,
+			jobID,
+			jobIndex,
+			jobAZ,
+			jobIP,
+		).Set(float64(jobMemKB) * 1024)
+
 		jobMemPercentMetric = prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -336,6 +438,30 @@ var _ = Describe("JobsCollector", func() {
 			jobIP,
 		).Set(float64(jobSwapKB))
 
+		jobSwapBytesMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "job",
+				Name:      "swap_bytes",
+				Help:      "BOSH Job Swap in bytes.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
+		)
+
+		jobSwapBytesMetric.WithLabelValues(
+			deploymentName,
+			jobName,
+			jobID,
+			jobIndex,
+			jobAZ,
+			jobIP,
+		).Set(float64(jobSwapKB) * 1024)
+
 		jobSwapPercentMetric = prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -516,7 +642,7 @@ var _ = Describe("JobsCollector", func() {
 					"bosh_uuid":   boshUUID,
 				},
 			},
-			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name"},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
 		)
 
 		jobProcessHealthyMetric.WithLabelValues(
@@ -527,6 +653,7 @@ var _ = Describe("JobsCollector", func() {
 			jobAZ,
 			jobIP,
 			jobProcessName,
+			jobProcessJobTemplate,
 		).Set(float64(1))
 
 		jobProcessUptimeMetric = prometheus.NewGaugeVec(
@@ -541,7 +668,7 @@ var _ = Describe("JobsCollector", func() {
 					"bosh_uuid":   boshUUID,
 				},
 			},
-			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name"},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
 		)
 
 		jobProcessUptimeMetric.WithLabelValues(
@@ -552,6 +679,7 @@ var _ = Describe("JobsCollector", func() {
 			jobAZ,
 			jobIP,
 			jobProcessName,
+			jobProcessJobTemplate,
 		).Set(float64(jobProcessUptime))
 
 		jobProcessCPUTotalMetric = prometheus.NewGaugeVec(
@@ -566,7 +694,7 @@ var _ = Describe("JobsCollector", func() {
 					"bosh_uuid":   boshUUID,
 				},
 			},
-			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name"},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
 		)
 
 		jobProcessCPUTotalMetric.WithLabelValues(
@@ -577,8 +705,61 @@ var _ = Describe("JobsCollector", func() {
 			jobAZ,
 			jobIP,
 			jobProcessName,
+			jobProcessJobTemplate,
 		).Set(jobProcessCPUTotal)
 
+		jobProcessCPULimitMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "job_process",
+				Name:      "cpu_limit",
+				Help:      "BOSH Job Process CPU Limit, the CPU allocated to the process cgroup in the unit of the CPU Total.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
+		)
+
+		jobProcessCPULimitMetric.WithLabelValues(
+			deploymentName,
+			jobName,
+			jobID,
+			jobIndex,
+			jobAZ,
+			jobIP,
+			jobProcessName,
+			jobProcessJobTemplate,
+		).Set(jobProcessCPULimit)
+
+		jobProcessCPUThrottledPercentMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "job_process",
+				Name:      "cpu_throttled_percent",
+				Help:      "BOSH Job Process CPU Throttled Percent, the percentage of the process cgroup CPU periods during which it was throttled.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
+		)
+
+		jobProcessCPUThrottledPercentMetric.WithLabelValues(
+			deploymentName,
+			jobName,
+			jobID,
+			jobIndex,
+			jobAZ,
+			jobIP,
+			jobProcessName,
+			jobProcessJobTemplate,
+		).Set(jobProcessCPUThrottledPercent)
+
 		jobProcessMemKBMetric = prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -591,7 +772,7 @@ var _ = Describe("JobsCollector", func() {
 					"bosh_uuid":   boshUUID,
 				},
 			},
-			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name"},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
 		)
 
 		jobProcessMemKBMetric.WithLabelValues(
@@ -602,8 +783,35 @@ var _ = Describe("JobsCollector", func() {
 			jobAZ,
 			jobIP,
 			jobProcessName,
+			jobProcessJobTemplate,
 		).Set(float64(jobProcessMemKB))
 
+		jobProcessMemBytesMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "job_process",
+				Name:      "mem_bytes",
+				Help:      "BOSH Job Process Memory in bytes.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
+		)
+
+		jobProcessMemBytesMetric.WithLabelValues(
+			deploymentName,
+			jobName,
+			jobID,
+			jobIndex,
+			jobAZ,
+			jobIP,
+			jobProcessName,
+			jobProcessJobTemplate,
+		).Set(float64(jobProcessMemKB) * 1024)
+
 		jobProcessMemPercentMetric = prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -616,7 +824,7 @@ var _ = Describe("JobsCollector", func() {
 					"bosh_uuid":   boshUUID,
 				},
 			},
-			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name"},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template"},
 		)
 
 		jobProcessMemPercentMetric.WithLabelValues(
@@ -627,6 +835,7 @@ var _ = Describe("JobsCollector", func() {
 			jobAZ,
 			jobIP,
 			jobProcessName,
+			jobProcessJobTemplate,
 		).Set(jobProcessMemPercent)
 
 		lastJobsScrapeTimestampMetric = prometheus.NewGauge(
@@ -659,7 +868,7 @@ var _ = Describe("JobsCollector", func() {
 	})
 
 	JustBeforeEach(func() {
-		jobsCollector = NewJobsCollector(namespace, environment, boshName, boshUUID, azsFilter, cidrsFilter)
+		jobsCollector = NewJobsCollector(namespace, environment, boshName, boshUUID, attributes, persistentDiskGrowthWindow, kbSeries, labelStoppedDeployments, azsFilter, deploymentProcessesFilter, expressionFilter, cidrsFilter)
 	})
 
 	Describe("Describe", func() {
@@ -686,6 +895,18 @@ var _ = Describe("JobsCollector", func() {
 			).Desc())))
 		})
 
+		It("returns a job_vm_created_at_timestamp metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(jobVMCreatedAtMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+				jobVMID,
+			).Desc())))
+		})
+
 		It("returns a job_load_avg01 metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(jobLoadAvg01Metric.WithLabelValues(
 				deploymentName,
@@ -752,6 +973,17 @@ var _ = Describe("JobsCollector", func() {
 			).Desc())))
 		})
 
+		It("returns a job_cpu_steal metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(jobCPUStealMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+			).Desc())))
+		})
+
 		It("returns a job_mem_kb metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(jobMemKBMetric.WithLabelValues(
 				deploymentName,
@@ -762,6 +994,16 @@ var _ = Describe("JobsCollector", func() {
 				jobIP,
 			).Desc())))
 		})
+		It("returns a job_mem_bytes metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(jobMemBytesMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+			).Desc())))
+		})
 
 		It("returns a job_mem_percent metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(jobMemPercentMetric.WithLabelValues(
@@ -784,6 +1026,16 @@ var _ = Describe("JobsCollector", func() {
 				jobIP,
 			).Desc())))
 		})
+		It("returns a job_swap_bytes metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(jobSwapBytesMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+			).Desc())))
+		})
 
 		It("returns a job_swap_percent metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(jobSwapPercentMetric.WithLabelValues(
@@ -871,6 +1123,7 @@ var _ = Describe("JobsCollector", func() {
 				jobAZ,
 				jobIP,
 				jobProcessName,
+				jobProcessJobTemplate,
 			).Desc())))
 		})
 
@@ -883,6 +1136,7 @@ var _ = Describe("JobsCollector", func() {
 				jobAZ,
 				jobIP,
 				jobProcessName,
+				jobProcessJobTemplate,
 			).Desc())))
 		})
 
@@ -895,6 +1149,33 @@ var _ = Describe("JobsCollector", func() {
 				jobAZ,
 				jobIP,
 				jobProcessName,
+				jobProcessJobTemplate,
+			).Desc())))
+		})
+
+		It("returns a job_process_cpu_limit metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(jobProcessCPULimitMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+				jobProcessName,
+				jobProcessJobTemplate,
+			).Desc())))
+		})
+
+		It("returns a job_process_cpu_throttled_percent metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(jobProcessCPUThrottledPercentMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+				jobProcessName,
+				jobProcessJobTemplate,
 			).Desc())))
 		})
 
@@ -907,6 +1188,19 @@ var _ = Describe("JobsCollector", func() {
 				jobAZ,
 				jobIP,
 				jobProcessName,
+				jobProcessJobTemplate,
+			).Desc())))
+		})
+		It("returns a job_process_mem_bytes metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(jobProcessMemBytesMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+				jobProcessName,
+				jobProcessJobTemplate,
 			).Desc())))
 		})
 
@@ -919,6 +1213,7 @@ var _ = Describe("JobsCollector", func() {
 				jobAZ,
 				jobIP,
 				jobProcessName,
+				jobProcessJobTemplate,
 			).Desc())))
 		})
 
@@ -938,6 +1233,8 @@ var _ = Describe("JobsCollector", func() {
 			instances       []deployments.Instance
 			deploymentInfo  deployments.DeploymentInfo
 			deploymentsInfo []deployments.DeploymentInfo
+			fetchedAt       time.Time
+			directorInfo    fetcher.DirectorInfo
 
 			metrics    chan prometheus.Metric
 			errMetrics chan error
@@ -946,11 +1243,12 @@ var _ = Describe("JobsCollector", func() {
 		BeforeEach(func() {
 			processes = []deployments.Process{
 				{
-					Name:    jobProcessName,
-					Uptime:  &jobProcessUptime,
-					Healthy: jobProcessHealthy,
-					CPU:     deployments.CPU{Total: &jobProcessCPUTotal},
-					Mem:     deployments.MemInt{KB: &jobProcessMemKB, Percent: &jobProcessMemPercent},
+					Name:        jobProcessName,
+					JobTemplate: jobProcessJobTemplate,
+					Uptime:      &jobProcessUptime,
+					Healthy:     jobProcessHealthy,
+					CPU:         deployments.CPU{Total: &jobProcessCPUTotal},
+					Mem:         deployments.MemInt{KB: &jobProcessMemKB, Percent: &jobProcessMemPercent},
 				},
 			}
 
@@ -989,14 +1287,20 @@ var _ = Describe("JobsCollector", func() {
 
 			instances = []deployments.Instance{
 				{
-					Name:      jobName,
-					ID:        jobID,
-					Index:     jobIndex,
-					IPs:       []string{jobIP},
-					AZ:        jobAZ,
-					Healthy:   jobHealthy,
-					Vitals:    vitals,
-					Processes: processes,
+					Name:        jobName,
+					ID:          jobID,
+					Index:       jobIndex,
+					IPs:         []string{jobIP},
+					AZ:          jobAZ,
+					VMID:        jobVMID,
+					VMCreatedAt: jobVMCreatedAt,
+					Healthy:     jobHealthy,
+					Vitals:      vitals,
+					Processes:   processes,
+					Attributes: map[string]string{
+						"vm_type":                        "fake-vm-type",
+						"cloud_properties.instance_type": "fake-instance-type",
+					},
 				},
 			}
 
@@ -1006,6 +1310,8 @@ var _ = Describe("JobsCollector", func() {
 			}
 
 			deploymentsInfo = []deployments.DeploymentInfo{deploymentInfo}
+			fetchedAt = time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+			directorInfo = fetcher.DirectorInfo{}
 
 			metrics = make(chan prometheus.Metric)
 			errMetrics = make(chan error, 1)
@@ -1013,7 +1319,7 @@ var _ = Describe("JobsCollector", func() {
 
 		JustBeforeEach(func() {
 			go func() {
-				if err := jobsCollector.Collect(deploymentsInfo, metrics); err != nil {
+				if err := jobsCollector.Collect(fetcher.Snapshot{Director: directorInfo, Deployments: deploymentsInfo, FetchedAt: fetchedAt}, metrics); err != nil {
 					errMetrics <- err
 				}
 			}()
@@ -1031,6 +1337,126 @@ var _ = Describe("JobsCollector", func() {
 			Consistently(errMetrics).ShouldNot(Receive())
 		})
 
+		Context("when intentionally stopped deployments are labelled", func() {
+			var jobHealthyStoppedMetric *prometheus.GaugeVec
+
+			BeforeEach(func() {
+				labelStoppedDeployments = true
+				jobHealthyStoppedMetric = prometheus.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Namespace: namespace,
+						Subsystem: "job",
+						Name:      "healthy",
+						Help:      "BOSH Job Healthy (1 for healthy, 0 for unhealthy).",
+						ConstLabels: prometheus.Labels{
+							"environment": environment,
+							"bosh_name":   boshName,
+							"bosh_uuid":   boshUUID,
+						},
+					},
+					[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_intentionally_stopped"},
+				)
+			})
+
+			It("returns a job_healthy metric labelled as not stopped", func() {
+				jobHealthyStoppedMetric.WithLabelValues(deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, "0").Set(float64(1))
+
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobHealthyStoppedMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+					"0",
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			Context("and every instance of the deployment is stopped", func() {
+				BeforeEach(func() {
+					deploymentInfo.Instances[0].Stopped = true
+					deploymentInfo.Instances[0].Healthy = false
+					deploymentsInfo = []deployments.DeploymentInfo{deploymentInfo}
+				})
+
+				It("returns a job_healthy metric labelled as intentionally stopped", func() {
+					jobHealthyStoppedMetric.WithLabelValues(deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, "1").Set(float64(0))
+
+					Eventually(metrics).Should(Receive(PrometheusMetric(jobHealthyStoppedMetric.WithLabelValues(
+						deploymentName,
+						jobName,
+						jobID,
+						jobIndex,
+						jobAZ,
+						jobIP,
+						"1",
+					))))
+					Consistently(errMetrics).ShouldNot(Receive())
+				})
+			})
+		})
+
+		Context("when instance attributes are configured", func() {
+			var jobAttributesInfoMetric *prometheus.GaugeVec
+
+			BeforeEach(func() {
+				attributes = []string{"vm_type", "cloud_properties.instance_type", "stemcell"}
+
+				jobAttributesInfoMetric = prometheus.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Namespace: namespace,
+						Subsystem: "job",
+						Name:      "attributes_info",
+						Help:      "BOSH Job instance attributes (always 1).",
+						ConstLabels: prometheus.Labels{
+							"environment": environment,
+							"bosh_name":   boshName,
+							"bosh_uuid":   boshUUID,
+						},
+					},
+					[]string{
+						"bosh_deployment",
+						"bosh_job_name",
+						"bosh_job_id",
+						"bosh_job_index",
+						"bosh_job_az",
+						"bosh_job_ip",
+						"bosh_job_attribute_vm_type",
+						"bosh_job_attribute_cloud_properties_instance_type",
+						"bosh_job_attribute_stemcell",
+					},
+				)
+
+				jobAttributesInfoMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+					"fake-vm-type",
+					"fake-instance-type",
+					"",
+				).Set(float64(1))
+			})
+
+			It("returns a job_attributes_info metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobAttributesInfoMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+					"fake-vm-type",
+					"fake-instance-type",
+					"",
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
 		Context("when the process is not running", func() {
 			BeforeEach(func() {
 				instances[0].Healthy = false
@@ -1058,6 +1484,150 @@ var _ = Describe("JobsCollector", func() {
 			})
 		})
 
+		It("returns a job_vm_created_at_timestamp metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(jobVMCreatedAtMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+				jobVMID,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		Context("when there is no vm creation time", func() {
+			BeforeEach(func() {
+				instances[0].VMCreatedAt = time.Time{}
+			})
+
+			It("does not return a job_vm_created_at_timestamp metric", func() {
+				Consistently(metrics).ShouldNot(Receive(PrometheusMetric(jobVMCreatedAtMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+					jobVMID,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
+		Context("when the BOSH Director reports the VM creator and trusted certificates", func() {
+			var (
+				jobVMCreatedBy                    = "fake-vm-created-by"
+				jobTrustedCertsSHA1               = "fake-job-trusted-certs-sha1"
+				directorTrustedCertsSHA1          = "fake-director-trusted-certs-sha1"
+				jobVMCreatedByMetric              *prometheus.GaugeVec
+				jobTrustedCertsGenerationMetric   *prometheus.GaugeVec
+				jobTrustedCertsNotConvergedMetric *prometheus.GaugeVec
+				directorTrustedCertsMetric        *prometheus.GaugeVec
+			)
+
+			BeforeEach(func() {
+				constLabels := prometheus.Labels{"environment": environment, "bosh_name": boshName, "bosh_uuid": boshUUID}
+
+				jobVMCreatedByMetric = prometheus.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Namespace:   namespace,
+						Subsystem:   "job",
+						Name:        "vm_created_by",
+						Help:        "BOSH Job VM creator (always 1), when reported by the BOSH Director.",
+						ConstLabels: constLabels,
+					},
+					[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_vm_created_by"},
+				)
+				jobVMCreatedByMetric.WithLabelValues(deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, jobVMCreatedBy).Set(float64(1))
+
+				jobTrustedCertsGenerationMetric = prometheus.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Namespace:   namespace,
+						Subsystem:   "job",
+						Name:        "trusted_certs_generation_info",
+						Help:        "BOSH Job VM trusted certificates generation, the SHA1 of the trusted certificates installed on the VM (always 1), when reported by the BOSH Director.",
+						ConstLabels: constLabels,
+					},
+					[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_trusted_certs_sha1"},
+				)
+				jobTrustedCertsGenerationMetric.WithLabelValues(deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, jobTrustedCertsSHA1).Set(float64(1))
+
+				jobTrustedCertsNotConvergedMetric = prometheus.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Namespace:   namespace,
+						Subsystem:   "job",
+						Name:        "trusted_certs_not_converged_instances",
+						Help:        "Number of BOSH Job instances whose VM trusted certificates generation is not the latest one of the BOSH Director, when both are reported by the BOSH Director.",
+						ConstLabels: constLabels,
+					},
+					[]string{"bosh_deployment", "bosh_job_name"},
+				)
+
+				directorTrustedCertsMetric = prometheus.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Namespace:   namespace,
+						Subsystem:   "director",
+						Name:        "trusted_certs_generation_info",
+						Help:        "BOSH Director latest trusted certificates generation, the SHA1 of the trusted certificates it installs on the VMs (always 1), when reported by the BOSH Director.",
+						ConstLabels: constLabels,
+					},
+					[]string{"bosh_trusted_certs_sha1"},
+				)
+				directorTrustedCertsMetric.WithLabelValues(directorTrustedCertsSHA1).Set(float64(1))
+
+				instances[0].VMCreatedBy = jobVMCreatedBy
+				instances[0].TrustedCertsSHA1 = jobTrustedCertsSHA1
+				directorInfo.TrustedCertsSHA1 = directorTrustedCertsSHA1
+			})
+
+			It("returns a job_vm_created_by metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobVMCreatedByMetric.WithLabelValues(deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, jobVMCreatedBy))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			It("returns a job_trusted_certs_generation_info metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobTrustedCertsGenerationMetric.WithLabelValues(deploymentName, jobName, jobID, jobIndex, jobAZ, jobIP, jobTrustedCertsSHA1))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			It("returns a director_trusted_certs_generation_info metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(directorTrustedCertsMetric.WithLabelValues(directorTrustedCertsSHA1))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			It("counts the instance as not converged", func() {
+				jobTrustedCertsNotConvergedMetric.WithLabelValues(deploymentName, jobName).Set(float64(1))
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobTrustedCertsNotConvergedMetric.WithLabelValues(deploymentName, jobName))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			Context("when the instance has the latest trusted certificates", func() {
+				BeforeEach(func() {
+					instances[0].TrustedCertsSHA1 = directorTrustedCertsSHA1
+				})
+
+				It("counts the instance as converged", func() {
+					jobTrustedCertsNotConvergedMetric.WithLabelValues(deploymentName, jobName).Set(float64(0))
+					Eventually(metrics).Should(Receive(PrometheusMetric(jobTrustedCertsNotConvergedMetric.WithLabelValues(deploymentName, jobName))))
+					Consistently(errMetrics).ShouldNot(Receive())
+				})
+			})
+
+			Context("when the BOSH Director does not report its trusted certificates", func() {
+				BeforeEach(func() {
+					directorInfo.TrustedCertsSHA1 = ""
+				})
+
+				It("does not return a job_trusted_certs_not_converged_instances metric", func() {
+					jobTrustedCertsNotConvergedMetric.WithLabelValues(deploymentName, jobName).Set(float64(0))
+					Consistently(metrics).ShouldNot(Receive(PrometheusMetric(jobTrustedCertsNotConvergedMetric.WithLabelValues(deploymentName, jobName))))
+					Consistently(errMetrics).ShouldNot(Receive())
+				})
+			})
+		})
+
 		It("returns a job_load_avg01 metric", func() {
 			Eventually(metrics).Should(Receive(PrometheusMetric(jobLoadAvg01Metric.WithLabelValues(
 				deploymentName,
@@ -1227,6 +1797,35 @@ var _ = Describe("JobsCollector", func() {
 			})
 		})
 
+		It("does not return a job_cpu_steal metric", func() {
+			Consistently(metrics).ShouldNot(Receive(PrometheusMetric(jobCPUStealMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+			))))
+		})
+
+		Context("when the agent reports the cpu steal", func() {
+			BeforeEach(func() {
+				instances[0].Vitals.CPU.Steal = strconv.FormatFloat(jobCPUSteal, 'E', -1, 64)
+			})
+
+			It("returns a job_cpu_steal metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobCPUStealMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
 		It("returns a job_mem_kb metric", func() {
 			Eventually(metrics).Should(Receive(PrometheusMetric(jobMemKBMetric.WithLabelValues(
 				deploymentName,
@@ -1238,6 +1837,47 @@ var _ = Describe("JobsCollector", func() {
 			))))
 			Consistently(errMetrics).ShouldNot(Receive())
 		})
+		It("returns a job_mem_bytes metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(jobMemBytesMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		Context("when the kb series are disabled", func() {
+			BeforeEach(func() {
+				kbSeries = false
+			})
+
+			It("does not return a job_mem_kb metric", func() {
+				Consistently(metrics).ShouldNot(Receive(PrometheusMetric(jobMemKBMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			It("returns a job_mem_bytes metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobMemBytesMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
 
 		Context("when there is no mem kb value", func() {
 			BeforeEach(func() {
@@ -1302,6 +1942,17 @@ var _ = Describe("JobsCollector", func() {
 			))))
 			Consistently(errMetrics).ShouldNot(Receive())
 		})
+		It("returns a job_swap_bytes metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(jobSwapBytesMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
 
 		Context("when there is no swap kb value", func() {
 			BeforeEach(func() {
@@ -1547,6 +2198,107 @@ var _ = Describe("JobsCollector", func() {
 			})
 		})
 
+		Context("when the persistent disk size is known", func() {
+			var (
+				jobPersistentDiskSizeBytesMetric *prometheus.GaugeVec
+				jobPersistentDiskGrowthMetric    *prometheus.GaugeVec
+			)
+
+			BeforeEach(func() {
+				instances[0].PersistentDiskSizeMB = 1024
+
+				jobPersistentDiskSizeBytesMetric = prometheus.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Namespace: namespace,
+						Subsystem: "job",
+						Name:      "persistent_disk_size_bytes",
+						Help:      "BOSH Job Persistent Disk size in bytes, as declared in the deployment manifest.",
+						ConstLabels: prometheus.Labels{
+							"environment": environment,
+							"bosh_name":   boshName,
+							"bosh_uuid":   boshUUID,
+						},
+					},
+					[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
+				)
+
+				jobPersistentDiskSizeBytesMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+				).Set(float64(1024 * 1024 * 1024))
+
+				jobPersistentDiskGrowthMetric = prometheus.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Namespace: namespace,
+						Subsystem: "job",
+						Name:      "persistent_disk_growth_bytes_per_hour",
+						Help:      "BOSH Job Persistent Disk usage growth rate in bytes per hour.",
+						ConstLabels: prometheus.Labels{
+							"environment": environment,
+							"bosh_name":   boshName,
+							"bosh_uuid":   boshUUID,
+						},
+					},
+					[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip"},
+				)
+			})
+
+			It("returns a job_persistent_disk_size_bytes metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobPersistentDiskSizeBytesMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			Context("and the persistent disk usage grows between snapshots", func() {
+				BeforeEach(func() {
+					jobPersistentDiskGrowthMetric.WithLabelValues(
+						deploymentName,
+						jobName,
+						jobID,
+						jobIndex,
+						jobAZ,
+						jobIP,
+					).Set((float64(jobPersistentDiskPercent+1)/100*float64(1024*1024*1024) - float64(jobPersistentDiskPercent)/100*float64(1024*1024*1024)) / 2)
+				})
+
+				JustBeforeEach(func() {
+					Eventually(func() bool {
+						metric := <-metrics
+						return metric.Desc().String() == lastJobsScrapeDurationSecondsMetric.Desc().String()
+					}).Should(BeTrue())
+
+					instances[0].Vitals.PersistentDisk.Percent = strconv.Itoa(int(jobPersistentDiskPercent) + 1)
+					go func() {
+						if err := jobsCollector.Collect(fetcher.Snapshot{Deployments: deploymentsInfo, FetchedAt: fetchedAt.Add(2 * time.Hour)}, metrics); err != nil {
+							errMetrics <- err
+						}
+					}()
+				})
+
+				It("returns a job_persistent_disk_growth_bytes_per_hour metric", func() {
+					Eventually(metrics).Should(Receive(PrometheusMetric(jobPersistentDiskGrowthMetric.WithLabelValues(
+						deploymentName,
+						jobName,
+						jobID,
+						jobIndex,
+						jobAZ,
+						jobIP,
+					))))
+					Consistently(errMetrics).ShouldNot(Receive())
+				})
+			})
+		})
+
 		It("returns a healthy job_process_healthy metric", func() {
 			Eventually(metrics).Should(Receive(PrometheusMetric(jobProcessHealthyMetric.WithLabelValues(
 				deploymentName,
@@ -1556,10 +2308,91 @@ var _ = Describe("JobsCollector", func() {
 				jobAZ,
 				jobIP,
 				jobProcessName,
+				jobProcessJobTemplate,
 			))))
 			Consistently(errMetrics).ShouldNot(Receive())
 		})
 
+		Context("when the deployment processes filter does not allow the process", func() {
+			BeforeEach(func() {
+				deploymentProcessesFilter, err = filters.NewDeploymentProcessesFilter("fake-other-deployment:.*")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("does not return a job_process_healthy metric", func() {
+				Consistently(metrics).ShouldNot(Receive(PrometheusMetric(jobProcessHealthyMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+					jobProcessName,
+					jobProcessJobTemplate,
+				))))
+			})
+
+			It("returns a job_healthy metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobHealthyMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+				))))
+			})
+		})
+
+		Context("when the filter expression rejects the process", func() {
+			BeforeEach(func() {
+				expressionFilter, err = filters.NewExpressionFilter(`process != "` + jobProcessName + `"`)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("does not return a job_process_healthy metric", func() {
+				Consistently(metrics).ShouldNot(Receive(PrometheusMetric(jobProcessHealthyMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+					jobProcessName,
+					jobProcessJobTemplate,
+				))))
+			})
+
+			It("returns a job_healthy metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobHealthyMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+				))))
+			})
+		})
+
+		Context("when the filter expression rejects the job", func() {
+			BeforeEach(func() {
+				expressionFilter, err = filters.NewExpressionFilter(`job != "` + jobName + `"`)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("does not return a job_healthy metric", func() {
+				Consistently(metrics).ShouldNot(Receive(PrometheusMetric(jobHealthyMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+				))))
+			})
+		})
+
 		Context("when a process is not running", func() {
 			BeforeEach(func() {
 				instances[0].Processes[0].Healthy = false
@@ -1572,6 +2405,7 @@ var _ = Describe("JobsCollector", func() {
 					jobAZ,
 					jobIP,
 					jobProcessName,
+					jobProcessJobTemplate,
 				).Set(float64(0))
 			})
 
@@ -1584,6 +2418,7 @@ var _ = Describe("JobsCollector", func() {
 					jobAZ,
 					jobIP,
 					jobProcessName,
+					jobProcessJobTemplate,
 				))))
 				Consistently(errMetrics).ShouldNot(Receive())
 			})
@@ -1598,6 +2433,7 @@ var _ = Describe("JobsCollector", func() {
 				jobAZ,
 				jobIP,
 				jobProcessName,
+				jobProcessJobTemplate,
 			))))
 			Consistently(errMetrics).ShouldNot(Receive())
 		})
@@ -1616,6 +2452,7 @@ var _ = Describe("JobsCollector", func() {
 					jobAZ,
 					jobIP,
 					jobProcessName,
+					jobProcessJobTemplate,
 				))))
 				Consistently(errMetrics).ShouldNot(Receive())
 			})
@@ -1630,6 +2467,7 @@ var _ = Describe("JobsCollector", func() {
 				jobAZ,
 				jobIP,
 				jobProcessName,
+				jobProcessJobTemplate,
 			))))
 			Consistently(errMetrics).ShouldNot(Receive())
 		})
@@ -1648,6 +2486,55 @@ var _ = Describe("JobsCollector", func() {
 					jobAZ,
 					jobIP,
 					jobProcessName,
+					jobProcessJobTemplate,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
+		It("does not return a job_process_cpu_limit metric", func() {
+			Consistently(metrics).ShouldNot(Receive(PrometheusMetric(jobProcessCPULimitMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+				jobProcessName,
+				jobProcessJobTemplate,
+			))))
+		})
+
+		Context("when the agent reports the process cpu allocation", func() {
+			BeforeEach(func() {
+				instances[0].Processes[0].CPU.Limit = &jobProcessCPULimit
+				instances[0].Processes[0].CPU.Throttled = &jobProcessCPUThrottledPercent
+			})
+
+			It("returns a job_process_cpu_limit metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobProcessCPULimitMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+					jobProcessName,
+					jobProcessJobTemplate,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			It("returns a job_process_cpu_throttled_percent metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobProcessCPUThrottledPercentMetric.WithLabelValues(
+					deploymentName,
+					jobName,
+					jobID,
+					jobIndex,
+					jobAZ,
+					jobIP,
+					jobProcessName,
+					jobProcessJobTemplate,
 				))))
 				Consistently(errMetrics).ShouldNot(Receive())
 			})
@@ -1662,6 +2549,20 @@ var _ = Describe("JobsCollector", func() {
 				jobAZ,
 				jobIP,
 				jobProcessName,
+				jobProcessJobTemplate,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+		It("returns a job_process_mem_bytes metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(jobProcessMemBytesMetric.WithLabelValues(
+				deploymentName,
+				jobName,
+				jobID,
+				jobIndex,
+				jobAZ,
+				jobIP,
+				jobProcessName,
+				jobProcessJobTemplate,
 			))))
 			Consistently(errMetrics).ShouldNot(Receive())
 		})
@@ -1680,6 +2581,7 @@ var _ = Describe("JobsCollector", func() {
 					jobAZ,
 					jobIP,
 					jobProcessName,
+					jobProcessJobTemplate,
 				))))
 				Consistently(errMetrics).ShouldNot(Receive())
 			})
@@ -1694,6 +2596,7 @@ var _ = Describe("JobsCollector", func() {
 				jobAZ,
 				jobIP,
 				jobProcessName,
+				jobProcessJobTemplate,
 			))))
 			Consistently(errMetrics).ShouldNot(Receive())
 		})
@@ -1712,6 +2615,7 @@ var _ = Describe("JobsCollector", func() {
 					jobAZ,
 					jobIP,
 					jobProcessName,
+					jobProcessJobTemplate,
 				))))
 				Consistently(errMetrics).ShouldNot(Receive())
 			})
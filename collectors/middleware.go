@@ -0,0 +1,119 @@
+package collectors
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+	"github.com/bosh-prometheus/bosh_exporter/tracing"
+)
+
+// CollectFunc runs a collector over the deployments of the snapshot, or over
+// eachDeployment when the deployments are streamed.
+type CollectFunc func(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error
+
+// Middleware wraps the CollectFunc of the named collector with a stage
+// applied uniformly to every collector, such as timing, filtering or
+// relabeling the metrics.
+type Middleware func(name string, next CollectFunc) CollectFunc
+
+// Pipeline returns the CollectFunc running the collector through the
+// middlewares, the first middleware being the outermost stage.
+func Pipeline(name string, collector Collector, middlewares ...Middleware) CollectFunc {
+	collect := collectorFunc(collector)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		collect = middlewares[i](name, collect)
+	}
+
+	return collect
+}
+
+// collectorFunc runs a collector over a stream of deployments, handing them
+// all at once to the collectors not able to stream them.
+func collectorFunc(collector Collector) CollectFunc {
+	return func(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
+		if eachDeployment == nil {
+			return collector.Collect(snapshot, ch)
+		}
+
+		if streamingCollector, ok := collector.(StreamingCollector); ok {
+			return streamingCollector.CollectStream(snapshot, eachDeployment, ch)
+		}
+
+		err := eachDeployment(func(deployment deployments.DeploymentInfo) error {
+			snapshot.Deployments = append(snapshot.Deployments, deployment)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return collector.Collect(snapshot, ch)
+	}
+}
+
+// MetricsMiddleware rewrites the metrics of every collector with fn, dropping
+// the ones for which it returns false.
+func MetricsMiddleware(fn func(snapshot fetcher.Snapshot, metric prometheus.Metric) (prometheus.Metric, bool)) Middleware {
+	return func(name string, next CollectFunc) CollectFunc {
+		return func(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
+			rewrittenChannel := make(chan prometheus.Metric)
+			forwarded := make(chan bool)
+			go func() {
+				defer close(forwarded)
+				for metric := range rewrittenChannel {
+					if metric, ok := fn(snapshot, metric); ok {
+						ch <- metric
+					}
+				}
+			}()
+
+			err := next(snapshot, eachDeployment, rewrittenChannel)
+			close(rewrittenChannel)
+			<-forwarded
+
+			return err
+		}
+	}
+}
+
+// TimestampsMiddleware sets the time of the fetch as the timestamp of the
+// metrics, unless it is older than maxAge (0 for no maximum).
+func TimestampsMiddleware(maxAge time.Duration) Middleware {
+	return MetricsMiddleware(func(snapshot fetcher.Snapshot, metric prometheus.Metric) (prometheus.Metric, bool) {
+		if maxAge > 0 && time.Since(snapshot.FetchedAt) > maxAge {
+			return metric, true
+		}
+		return prometheus.NewMetricWithTimestamp(snapshot.FetchedAt, metric), true
+	})
+}
+
+// TracingMiddleware traces every collector as a child of the parent span.
+func TracingMiddleware(tracer *tracing.Tracer, parent *tracing.Span) Middleware {
+	return func(name string, next CollectFunc) CollectFunc {
+		return func(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
+			span := tracer.Start(parent, "bosh.collector."+name)
+			defer span.Finish()
+
+			err := next(snapshot, eachDeployment, ch)
+			span.RecordError(err)
+
+			return err
+		}
+	}
+}
+
+// LastSuccessMiddleware records the time every collector last succeeded in
+// lastSuccessMetric, labeled by collector name.
+func LastSuccessMiddleware(lastSuccessMetric *prometheus.GaugeVec) Middleware {
+	return func(name string, next CollectFunc) CollectFunc {
+		return func(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
+			if err := next(snapshot, eachDeployment, ch); err != nil {
+				return err
+			}
+			lastSuccessMetric.WithLabelValues(name).Set(float64(time.Now().Unix()))
+			return nil
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package collectors_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+)
+
+type fakeCollector struct {
+	desc        *prometheus.Desc
+	deployments []string
+	err         error
+}
+
+func (c *fakeCollector) Collect(snapshot fetcher.Snapshot, ch chan<- prometheus.Metric) error {
+	for _, deployment := range snapshot.Deployments {
+		c.deployments = append(c.deployments, deployment.Name)
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1, deployment.Name)
+	}
+	return c.err
+}
+
+func (c *fakeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func collectPipeline(collect CollectFunc, snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+	ch := make(chan prometheus.Metric)
+	done := make(chan error)
+	go func() {
+		done <- collect(snapshot, eachDeployment, ch)
+	}()
+	for {
+		select {
+		case metric := <-ch:
+			metrics = append(metrics, metric)
+		case err := <-done:
+			return metrics, err
+		}
+	}
+}
+
+var _ = Describe("Pipeline", func() {
+	var (
+		collector *fakeCollector
+		snapshot  fetcher.Snapshot
+		stages    []string
+
+		recordingMiddleware = func(stage string) Middleware {
+			return func(name string, next CollectFunc) CollectFunc {
+				return func(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
+					stages = append(stages, stage+" "+name)
+					return next(snapshot, eachDeployment, ch)
+				}
+			}
+		}
+	)
+
+	BeforeEach(func() {
+		collector = &fakeCollector{
+			desc: prometheus.NewDesc("test_deployment", "Test deployment.", []string{"bosh_deployment"}, nil),
+		}
+		snapshot = fetcher.Snapshot{
+			FetchedAt: time.Now(),
+			Deployments: []deployments.DeploymentInfo{
+				{Name: "fake-deployment-1"},
+				{Name: "fake-deployment-2"},
+			},
+		}
+		stages = []string{}
+	})
+
+	It("runs the middlewares from the first to the last", func() {
+		collect := Pipeline("Fake", collector, recordingMiddleware("outer"), recordingMiddleware("inner"))
+		metrics, err := collectPipeline(collect, snapshot, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(metrics).To(HaveLen(2))
+		Expect(stages).To(Equal([]string{"outer Fake", "inner Fake"}))
+	})
+
+	It("hands the streamed deployments at once to the collectors not able to stream them", func() {
+		eachDeployment := fetcher.Snapshot{Deployments: snapshot.Deployments}.EachDeployment
+		snapshot.Deployments = nil
+
+		_, err := collectPipeline(Pipeline("Fake", collector), snapshot, eachDeployment)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collector.deployments).To(Equal([]string{"fake-deployment-1", "fake-deployment-2"}))
+	})
+
+	Describe("MetricsMiddleware", func() {
+		It("rewrites and drops metrics", func() {
+			dropFirst := MetricsMiddleware(func(snapshot fetcher.Snapshot, metric prometheus.Metric) (prometheus.Metric, bool) {
+				dtoMetric := &dto.Metric{}
+				metric.Write(dtoMetric)
+				return metric, dtoMetric.GetLabel()[0].GetValue() != "fake-deployment-1"
+			})
+
+			metrics, err := collectPipeline(Pipeline("Fake", collector, dropFirst), snapshot, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metrics).To(HaveLen(1))
+		})
+
+		It("returns the collector error", func() {
+			collector.err = errors.New("no metrics")
+			passThrough := MetricsMiddleware(func(snapshot fetcher.Snapshot, metric prometheus.Metric) (prometheus.Metric, bool) {
+				return metric, true
+			})
+
+			metrics, err := collectPipeline(Pipeline("Fake", collector, passThrough), snapshot, nil)
+			Expect(err).To(MatchError("no metrics"))
+			Expect(metrics).To(HaveLen(2))
+		})
+	})
+
+	Describe("TimestampsMiddleware", func() {
+		It("sets the fetch time as the metrics timestamp", func() {
+			metrics, err := collectPipeline(Pipeline("Fake", collector, TimestampsMiddleware(0)), snapshot, nil)
+			Expect(err).ToNot(HaveOccurred())
+			dtoMetric := &dto.Metric{}
+			metrics[0].Write(dtoMetric)
+			Expect(dtoMetric.GetTimestampMs()).To(Equal(snapshot.FetchedAt.UnixNano() / int64(time.Millisecond)))
+		})
+	})
+
+	Describe("LastSuccessMiddleware", func() {
+		var (
+			lastSuccessMetric *prometheus.GaugeVec
+		)
+
+		BeforeEach(func() {
+			lastSuccessMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_last_success"}, []string{"collector"})
+		})
+
+		It("records the time of the last success", func() {
+			_, err := collectPipeline(Pipeline("Fake", collector, LastSuccessMiddleware(lastSuccessMetric)), snapshot, nil)
+			Expect(err).ToNot(HaveOccurred())
+			dtoMetric := &dto.Metric{}
+			lastSuccessMetric.WithLabelValues("Fake").Write(dtoMetric)
+			Expect(dtoMetric.GetGauge().GetValue()).To(BeNumerically("~", time.Now().Unix(), 5))
+		})
+
+		It("does not record failures", func() {
+			collector.err = errors.New("no metrics")
+			_, err := collectPipeline(Pipeline("Fake", collector, LastSuccessMiddleware(lastSuccessMetric)), snapshot, nil)
+			Expect(err).To(HaveOccurred())
+			dtoMetric := &dto.Metric{}
+			lastSuccessMetric.WithLabelValues("Fake").Write(dtoMetric)
+			Expect(dtoMetric.GetGauge().GetValue()).To(Equal(float64(0)))
+		})
+	})
+})
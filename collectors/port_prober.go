@@ -0,0 +1,183 @@
+package collectors
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// PortProber checks that the Service Discovery targets answer on their port
+// by opening a TCP connection, dialing at most `concurrency` targets at once
+// so large directors do not exhaust the exporter file descriptors.
+//
+// A background prober never probes while being asked: it probes the targets
+// it was last asked about in Run, and answers from the results of its last
+// probes, so unreachable targets do not delay the collections.
+type PortProber struct {
+	timeout     time.Duration
+	concurrency int
+	background  bool
+	cachedOnly  bool
+	state       *portProberState
+}
+
+type portProberState struct {
+	mu      *sync.Mutex
+	watched []string
+	results map[string]bool
+	wake    chan struct{}
+}
+
+// NewPortProber returns a prober probing the targets whenever it is asked
+// whether they are reachable.
+func NewPortProber(timeout time.Duration, concurrency int) *PortProber {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &PortProber{
+		timeout:     timeout,
+		concurrency: concurrency,
+		state: &portProberState{
+			mu:      &sync.Mutex{},
+			results: map[string]bool{},
+			wake:    make(chan struct{}, 1),
+		},
+	}
+}
+
+// NewBackgroundPortProber returns a prober probing the targets in Run.
+func NewBackgroundPortProber(timeout time.Duration, concurrency int) *PortProber {
+	prober := NewPortProber(timeout, concurrency)
+	prober.background = true
+
+	return prober
+}
+
+// Reachable returns whether each target answered. Targets without a port are
+// not probed and always reachable. A background prober returns the results of
+// its last probes instead, the targets not probed yet being reachable, and
+// probes the targets on its next run.
+func (p *PortProber) Reachable(targets []string) map[string]bool {
+	if p.cachedOnly {
+		return p.cached(targets)
+	}
+	if p.background {
+		p.watch(targets)
+		return p.cached(targets)
+	}
+
+	reachable := p.probe(targets)
+	p.state.mu.Lock()
+	p.state.results = reachable
+	p.state.mu.Unlock()
+
+	return reachable
+}
+
+// Run probes the targets the prober was last asked about every interval, and
+// as soon as it is asked about targets not probed yet, until stop is closed.
+func (p *PortProber) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.state.wake:
+		case <-stop:
+			return
+		}
+
+		p.state.mu.Lock()
+		watched := p.state.watched
+		p.state.mu.Unlock()
+
+		reachable := p.probe(watched)
+		p.state.mu.Lock()
+		p.state.results = reachable
+		p.state.mu.Unlock()
+	}
+}
+
+// cachedOnlyCopy returns a prober sharing the results of p that never probes,
+// e.g. for previews.
+func (p *PortProber) cachedOnlyCopy() *PortProber {
+	if p == nil {
+		return nil
+	}
+
+	prober := *p
+	prober.cachedOnly = true
+
+	return &prober
+}
+
+func (p *PortProber) watch(targets []string) {
+	p.state.mu.Lock()
+	defer p.state.mu.Unlock()
+
+	p.state.watched = targets
+	for _, target := range targets {
+		if _, found := p.state.results[target]; !found {
+			select {
+			case p.state.wake <- struct{}{}:
+			default:
+			}
+			return
+		}
+	}
+}
+
+func (p *PortProber) cached(targets []string) map[string]bool {
+	p.state.mu.Lock()
+	defer p.state.mu.Unlock()
+
+	reachable := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		result, found := p.state.results[target]
+		reachable[target] = result || !found
+	}
+
+	return reachable
+}
+
+func (p *PortProber) probe(targets []string) map[string]bool {
+	reachable := make(map[string]bool, len(targets))
+	results := make(chan string, len(targets))
+	semaphore := make(chan struct{}, p.concurrency)
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		if _, found := reachable[target]; found {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			reachable[target] = true
+			continue
+		}
+		reachable[target] = false
+
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			conn, err := net.DialTimeout("tcp", target, p.timeout)
+			if err != nil {
+				return
+			}
+			conn.Close()
+			results <- target
+		}(target)
+	}
+	wg.Wait()
+	close(results)
+
+	for target := range results {
+		reachable[target] = true
+	}
+
+	return reachable
+}
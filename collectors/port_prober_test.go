@@ -0,0 +1,74 @@
+package collectors_test
+
+import (
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+)
+
+var _ = Describe("PortProber", func() {
+	var (
+		listener      net.Listener
+		listeningAddr string
+		closedAddr    string
+	)
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		listeningAddr = listener.Addr().String()
+
+		closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		closedAddr = closedListener.Addr().String()
+		closedListener.Close()
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	It("returns whether each target answers", func() {
+		reachable := NewPortProber(time.Second, 1).Reachable([]string{listeningAddr, closedAddr, listeningAddr})
+		Expect(reachable).To(Equal(map[string]bool{listeningAddr: true, closedAddr: false}))
+	})
+
+	It("does not probe the targets without a port", func() {
+		reachable := NewPortProber(time.Second, 0).Reachable([]string{"1.2.3.4"})
+		Expect(reachable).To(Equal(map[string]bool{"1.2.3.4": true}))
+	})
+
+	Context("when probing in the background", func() {
+		var (
+			prober *PortProber
+			stop   chan struct{}
+		)
+
+		BeforeEach(func() {
+			prober = NewBackgroundPortProber(time.Second, 1)
+			stop = make(chan struct{})
+		})
+
+		AfterEach(func() {
+			close(stop)
+		})
+
+		It("returns the targets not probed yet as reachable", func() {
+			reachable := prober.Reachable([]string{listeningAddr, closedAddr})
+			Expect(reachable).To(Equal(map[string]bool{listeningAddr: true, closedAddr: true}))
+		})
+
+		It("returns the results of the last probes", func() {
+			go prober.Run(time.Hour, stop)
+
+			Eventually(func() map[string]bool {
+				return prober.Reachable([]string{listeningAddr, closedAddr})
+			}).Should(Equal(map[string]bool{listeningAddr: true, closedAddr: false}))
+		})
+	})
+})
@@ -0,0 +1,123 @@
+package collectors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+const boshAnnotationLabelPrefix = model.MetaLabelPrefix + "bosh_annotation_"
+
+var invalidAnnotationNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// ProcessAnnotation adds Annotations to the Service Discovery targets of the
+// processes matching Process, in the deployments matching Deployment. Both
+// are anchored regular expressions, an empty one matching everything.
+type ProcessAnnotation struct {
+	Deployment  string            `yaml:"deployment"`
+	Process     string            `yaml:"process"`
+	Annotations map[string]string `yaml:"annotations"`
+
+	deploymentRegexp *regexp.Regexp
+	processRegexp    *regexp.Regexp
+}
+
+type processAnnotationsFile struct {
+	Annotations []ProcessAnnotation `yaml:"annotations"`
+}
+
+// LoadProcessAnnotations reads the process annotations of a YAML file.
+func LoadProcessAnnotations(filename string) ([]ProcessAnnotation, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error reading process annotations file `%s`: %v", filename, err))
+	}
+
+	annotations, err := ParseProcessAnnotations(data)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing process annotations file `%s`: %v", filename, err))
+	}
+
+	return annotations, nil
+}
+
+func ParseProcessAnnotations(data []byte) ([]ProcessAnnotation, error) {
+	var file processAnnotationsFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, err
+	}
+
+	for i := range file.Annotations {
+		annotation := &file.Annotations[i]
+		if len(annotation.Annotations) == 0 {
+			return nil, errors.New(fmt.Sprintf("annotation %d: `annotations` is required", i))
+		}
+
+		var err error
+		if annotation.deploymentRegexp, err = anchoredRegexp(annotation.Deployment); err != nil {
+			return nil, errors.New(fmt.Sprintf("annotation %d: invalid deployment regexp `%s`: %v", i, annotation.Deployment, err))
+		}
+		if annotation.processRegexp, err = anchoredRegexp(annotation.Process); err != nil {
+			return nil, errors.New(fmt.Sprintf("annotation %d: invalid process regexp `%s`: %v", i, annotation.Process, err))
+		}
+	}
+
+	return file.Annotations, nil
+}
+
+func anchoredRegexp(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		expr = ".*"
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// AnnotationLabelName returns the Service Discovery label of an annotation,
+// replacing the characters not allowed in label names with underscores as
+// the Kubernetes Service Discovery does, so `prometheus.io/scrape` becomes
+// `__meta_bosh_annotation_prometheus_io_scrape`.
+func AnnotationLabelName(name string) string {
+	return boshAnnotationLabelPrefix + invalidAnnotationNameChars.ReplaceAllString(name, "_")
+}
+
+// processAnnotations returns the JSON encoded labels of the annotations
+// matching a deployment and any of its processes, the first matching
+// annotation of a name winning.
+func processAnnotations(annotations []ProcessAnnotation, deployment string, processes ...string) string {
+	if len(annotations) == 0 {
+		return ""
+	}
+
+	labels := map[string]string{}
+	for _, annotation := range annotations {
+		if !annotation.deploymentRegexp.MatchString(deployment) {
+			continue
+		}
+		for _, process := range processes {
+			if !annotation.processRegexp.MatchString(process) {
+				continue
+			}
+			for name, value := range annotation.Annotations {
+				if _, ok := labels[AnnotationLabelName(name)]; !ok {
+					labels[AnnotationLabelName(name)] = value
+				}
+			}
+			break
+		}
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+
+	encodedLabels, err := json.Marshal(labels)
+	if err != nil {
+		return ""
+	}
+
+	return string(encodedLabels)
+}
@@ -0,0 +1,66 @@
+package collectors_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+)
+
+var _ = Describe("ParseProcessAnnotations", func() {
+	It("parses the annotations", func() {
+		annotations, err := ParseProcessAnnotations([]byte(`
+annotations:
+- deployment: cf
+  process: gorouter
+  annotations:
+    team: routing
+`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(annotations).To(HaveLen(1))
+		Expect(annotations[0].Deployment).To(Equal("cf"))
+		Expect(annotations[0].Process).To(Equal("gorouter"))
+		Expect(annotations[0].Annotations).To(Equal(map[string]string{"team": "routing"}))
+	})
+
+	It("returns an error when the annotations are missing", func() {
+		_, err := ParseProcessAnnotations([]byte(`
+annotations:
+- process: gorouter
+`))
+		Expect(err).To(MatchError(ContainSubstring("`annotations` is required")))
+	})
+
+	It("returns an error when a regexp is invalid", func() {
+		_, err := ParseProcessAnnotations([]byte(`
+annotations:
+- process: "gorouter("
+  annotations:
+    team: routing
+`))
+		Expect(err).To(MatchError(ContainSubstring("invalid process regexp")))
+	})
+
+	It("returns an error when a field is unknown", func() {
+		_, err := ParseProcessAnnotations([]byte(`
+annotations:
+- job: gorouter
+  annotations:
+    team: routing
+`))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("LoadProcessAnnotations", func() {
+	It("returns an error when the file does not exist", func() {
+		_, err := LoadProcessAnnotations("/does/not/exist")
+		Expect(err).To(MatchError(ContainSubstring("Error reading process annotations file")))
+	})
+})
+
+var _ = Describe("AnnotationLabelName", func() {
+	It("replaces the characters not allowed in label names", func() {
+		Expect(AnnotationLabelName("prometheus.io/scrape-interval")).To(Equal("__meta_bosh_annotation_prometheus_io_scrape_interval"))
+	})
+})
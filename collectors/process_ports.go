@@ -0,0 +1,46 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+)
+
+func ParseProcessPorts(processPorts []string) (map[string]int, error) {
+	ports := map[string]int{}
+
+	for _, processPort := range processPorts {
+		parts := strings.SplitN(processPort, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.New(fmt.Sprintf("Process port `%s` must be formatted as <process>=<port>", processPort))
+		}
+
+		port, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, errors.New(fmt.Sprintf("Process port `%s` must be a number between 1 and 65535", processPort))
+		}
+		ports[strings.TrimSpace(parts[0])] = port
+	}
+
+	return ports, nil
+}
+
+func processTargets(ip string, process deployments.Process, configuredPorts map[string]int) []string {
+	if len(process.Ports) > 0 {
+		targets := make([]string, 0, len(process.Ports))
+		for _, port := range process.Ports {
+			targets = append(targets, net.JoinHostPort(ip, strconv.Itoa(port)))
+		}
+		return targets
+	}
+
+	if port, ok := configuredPorts[process.Name]; ok {
+		return []string{net.JoinHostPort(ip, strconv.Itoa(port))}
+	}
+
+	return []string{ip}
+}
@@ -0,0 +1,27 @@
+package collectors_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+)
+
+var _ = Describe("ParseProcessPorts", func() {
+	It("parses the process ports", func() {
+		ports, err := ParseProcessPorts([]string{"node_exporter=9100", " redis_exporter = 9121 "})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ports).To(Equal(map[string]int{"node_exporter": 9100, "redis_exporter": 9121}))
+	})
+
+	It("returns an error when a process port is not well formatted", func() {
+		_, err := ParseProcessPorts([]string{"node_exporter"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("<process>=<port>"))
+	})
+
+	It("returns an error when a port is out of range", func() {
+		_, err := ParseProcessPorts([]string{"node_exporter=70000"})
+		Expect(err).To(HaveOccurred())
+	})
+})
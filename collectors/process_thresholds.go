@@ -0,0 +1,107 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+)
+
+// ProcessThreshold is breached by the processes matching Process, in the
+// deployments matching Deployment, whose CPU Total is above CPU or whose
+// memory percentage is above MemPercent for Cycles collections in a row.
+// Deployment and Process are anchored regular expressions, an empty one
+// matching everything.
+type ProcessThreshold struct {
+	Name       string   `yaml:"name"`
+	Deployment string   `yaml:"deployment"`
+	Process    string   `yaml:"process"`
+	CPU        *float64 `yaml:"cpu"`
+	MemPercent *float64 `yaml:"mem_percent"`
+	Cycles     int      `yaml:"cycles"`
+
+	deploymentRegexp *regexp.Regexp
+	processRegexp    *regexp.Regexp
+}
+
+type processThresholdsFile struct {
+	Thresholds []ProcessThreshold `yaml:"thresholds"`
+}
+
+// LoadProcessThresholds reads the process thresholds of a YAML file.
+func LoadProcessThresholds(filename string) ([]ProcessThreshold, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error reading process thresholds file `%s`: %v", filename, err))
+	}
+
+	thresholds, err := ParseProcessThresholds(data)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing process thresholds file `%s`: %v", filename, err))
+	}
+
+	return thresholds, nil
+}
+
+func ParseProcessThresholds(data []byte) ([]ProcessThreshold, error) {
+	var file processThresholdsFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for i := range file.Thresholds {
+		threshold := &file.Thresholds[i]
+		if threshold.Name == "" {
+			return nil, errors.New(fmt.Sprintf("threshold %d: `name` is required", i))
+		}
+		if !model.LabelValue(threshold.Name).IsValid() {
+			return nil, errors.New(fmt.Sprintf("threshold %d: invalid name `%s`", i, threshold.Name))
+		}
+		if names[threshold.Name] {
+			return nil, errors.New(fmt.Sprintf("threshold %d: duplicate name `%s`", i, threshold.Name))
+		}
+		names[threshold.Name] = true
+
+		if threshold.CPU == nil && threshold.MemPercent == nil {
+			return nil, errors.New(fmt.Sprintf("threshold %d: `cpu` or `mem_percent` is required", i))
+		}
+		if threshold.Cycles < 0 {
+			return nil, errors.New(fmt.Sprintf("threshold %d: `cycles` must be positive", i))
+		}
+		if threshold.Cycles == 0 {
+			threshold.Cycles = 1
+		}
+
+		var err error
+		if threshold.deploymentRegexp, err = anchoredRegexp(threshold.Deployment); err != nil {
+			return nil, errors.New(fmt.Sprintf("threshold %d: invalid deployment regexp `%s`: %v", i, threshold.Deployment, err))
+		}
+		if threshold.processRegexp, err = anchoredRegexp(threshold.Process); err != nil {
+			return nil, errors.New(fmt.Sprintf("threshold %d: invalid process regexp `%s`: %v", i, threshold.Process, err))
+		}
+	}
+
+	return file.Thresholds, nil
+}
+
+func (t ProcessThreshold) matches(deployment string, process string) bool {
+	return t.deploymentRegexp.MatchString(deployment) && t.processRegexp.MatchString(process)
+}
+
+// exceeded returns whether the vitals of a process are above the threshold.
+// Vitals not reported by the agent never exceed it.
+func (t ProcessThreshold) exceeded(process deployments.Process) bool {
+	if t.CPU != nil && process.CPU.Total != nil && *process.CPU.Total > *t.CPU {
+		return true
+	}
+	if t.MemPercent != nil && process.Mem.Percent != nil && *process.Mem.Percent > *t.MemPercent {
+		return true
+	}
+	return false
+}
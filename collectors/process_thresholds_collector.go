@@ -0,0 +1,156 @@
+package collectors
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+	"github.com/bosh-prometheus/bosh_exporter/filters"
+)
+
+const processThresholdsCollectorName = "ProcessThresholds"
+
+type processThresholdKey struct {
+	deploymentName string
+	jobID          string
+	processName    string
+	threshold      string
+}
+
+type ProcessThresholdsCollector struct {
+	thresholds                  []ProcessThreshold
+	azsFilter                   *filters.AZsFilter
+	deploymentProcessesFilter   *filters.DeploymentProcessesFilter
+	expressionFilter            *filters.ExpressionFilter
+	cidrsFilter                 *filters.CidrFilter
+	jobProcessThresholdBreached *prometheus.Desc
+	exceededCycles              map[processThresholdKey]int
+	mu                          *sync.Mutex
+}
+
+func NewProcessThresholdsCollector(
+	namespace string,
+	environment string,
+	boshName string,
+	boshUUID string,
+	thresholds []ProcessThreshold,
+	azsFilter *filters.AZsFilter,
+	deploymentProcessesFilter *filters.DeploymentProcessesFilter,
+	expressionFilter *filters.ExpressionFilter,
+	cidrsFilter *filters.CidrFilter,
+) *ProcessThresholdsCollector {
+	jobProcessThresholdBreached := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "job_process", "threshold_breached"),
+		"BOSH Job Process Threshold Breached (1 when the process vitals were above the threshold for its number of cycles, 0 otherwise).",
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "threshold"},
+		prometheus.Labels{
+			"environment": environment,
+			"bosh_name":   boshName,
+			"bosh_uuid":   boshUUID,
+		},
+	)
+
+	return &ProcessThresholdsCollector{
+		thresholds:                  thresholds,
+		azsFilter:                   azsFilter,
+		deploymentProcessesFilter:   deploymentProcessesFilter,
+		expressionFilter:            expressionFilter,
+		cidrsFilter:                 cidrsFilter,
+		jobProcessThresholdBreached: jobProcessThresholdBreached,
+		exceededCycles:              map[processThresholdKey]int{},
+		mu:                          &sync.Mutex{},
+	}
+}
+
+func (c *ProcessThresholdsCollector) Collect(snapshot fetcher.Snapshot, ch chan<- prometheus.Metric) error {
+	return c.CollectStream(snapshot, snapshot.EachDeployment, ch)
+}
+
+func (c *ProcessThresholdsCollector) CollectStream(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := map[processThresholdKey]bool{}
+	err := eachDeployment(func(deployment deployments.DeploymentInfo) error {
+		c.reportDeployment(deployment, seen, ch)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for key := range c.exceededCycles {
+		if !seen[key] {
+			delete(c.exceededCycles, key)
+		}
+	}
+
+	return nil
+}
+
+func (c *ProcessThresholdsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.jobProcessThresholdBreached
+}
+
+func (c *ProcessThresholdsCollector) reportDeployment(
+	deployment deployments.DeploymentInfo,
+	seen map[processThresholdKey]bool,
+	ch chan<- prometheus.Metric,
+) {
+	for _, instance := range deployment.Instances {
+		if !c.azsFilter.EnabledInstance(instance.AZ, instance.Attributes) {
+			continue
+		}
+
+		jobIP, _ := c.cidrsFilter.Select(instance.IPs)
+		expressionFields := map[string]string{"deployment": deployment.Name, "job": instance.Name, "az": instance.AZ, "ip": jobIP}
+		if !c.expressionFilter.Enabled(expressionFields) {
+			continue
+		}
+
+		for _, process := range instance.Processes {
+			if !c.deploymentProcessesFilter.Enabled(deployment.Name, process.Name) {
+				continue
+			}
+			expressionFields["process"] = process.Name
+			if !c.expressionFilter.Enabled(expressionFields) {
+				continue
+			}
+
+			for _, threshold := range c.thresholds {
+				if !threshold.matches(deployment.Name, process.Name) {
+					continue
+				}
+
+				key := processThresholdKey{deploymentName: deployment.Name, jobID: instance.ID, processName: process.Name, threshold: threshold.Name}
+				seen[key] = true
+				if threshold.exceeded(process) {
+					c.exceededCycles[key]++
+				} else {
+					c.exceededCycles[key] = 0
+				}
+
+				var breached float64
+				if c.exceededCycles[key] >= threshold.Cycles {
+					breached = 1
+				}
+
+				ch <- prometheus.MustNewConstMetric(
+					c.jobProcessThresholdBreached,
+					prometheus.GaugeValue,
+					breached,
+					deployment.Name,
+					instance.Name,
+					instance.ID,
+					instance.Index,
+					instance.AZ,
+					jobIP,
+					process.Name,
+					threshold.Name,
+				)
+			}
+		}
+	}
+}
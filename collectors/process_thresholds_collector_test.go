@@ -0,0 +1,209 @@
+package collectors_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+	"github.com/bosh-prometheus/bosh_exporter/filters"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+var _ = Describe("ProcessThresholdsCollector", func() {
+	var (
+		err                        error
+		namespace                  string
+		environment                string
+		boshName                   string
+		boshUUID                   string
+		thresholds                 []ProcessThreshold
+		azsFilter                  *filters.AZsFilter
+		deploymentProcessesFilter  *filters.DeploymentProcessesFilter
+		expressionFilter           *filters.ExpressionFilter
+		cidrsFilter                *filters.CidrFilter
+		processThresholdsCollector *ProcessThresholdsCollector
+
+		jobProcessThresholdBreachedDesc *prometheus.Desc
+	)
+
+	BeforeEach(func() {
+		namespace = "test_exporter"
+		environment = "test_environment"
+		boshName = "test_bosh_name"
+		boshUUID = "test_bosh_uuid"
+		thresholds, err = ParseProcessThresholds([]byte(`
+thresholds:
+- name: gorouter_busy
+  process: gorouter
+  cpu: 80
+  mem_percent: 90
+  cycles: 2
+`))
+		Expect(err).ToNot(HaveOccurred())
+		azsFilter = filters.NewAZsFilter([]string{})
+		deploymentProcessesFilter, err = filters.NewDeploymentProcessesFilter("")
+		Expect(err).ToNot(HaveOccurred())
+		expressionFilter, err = filters.NewExpressionFilter("")
+		Expect(err).ToNot(HaveOccurred())
+		cidrsFilter, err = filters.NewCidrFilter([]string{"0.0.0.0/0"})
+		Expect(err).ToNot(HaveOccurred())
+
+		jobProcessThresholdBreachedDesc = prometheus.NewDesc(
+			"test_exporter_job_process_threshold_breached",
+			"BOSH Job Process Threshold Breached (1 when the process vitals were above the threshold for its number of cycles, 0 otherwise).",
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "threshold"},
+			prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		)
+	})
+
+	JustBeforeEach(func() {
+		processThresholdsCollector = NewProcessThresholdsCollector(
+			namespace,
+			environment,
+			boshName,
+			boshUUID,
+			thresholds,
+			azsFilter,
+			deploymentProcessesFilter,
+			expressionFilter,
+			cidrsFilter,
+		)
+	})
+
+	Describe("Describe", func() {
+		var (
+			descriptions chan *prometheus.Desc
+		)
+
+		BeforeEach(func() {
+			descriptions = make(chan *prometheus.Desc)
+		})
+
+		JustBeforeEach(func() {
+			go processThresholdsCollector.Describe(descriptions)
+		})
+
+		It("returns a job_process_threshold_breached description", func() {
+			Eventually(descriptions).Should(Receive(Equal(jobProcessThresholdBreachedDesc)))
+		})
+	})
+
+	Describe("Collect", func() {
+		var (
+			cpuTotal   float64
+			memPercent float64
+
+			snapshot fetcher.Snapshot
+			collect  func() []prometheus.Metric
+		)
+
+		BeforeEach(func() {
+			cpuTotal = 95
+			memPercent = 10
+
+			snapshot = fetcher.Snapshot{
+				Deployments: []deployments.DeploymentInfo{
+					{
+						Name: "fake-deployment-name",
+						Instances: []deployments.Instance{
+							{
+								Name:  "router",
+								ID:    "fake-job-id",
+								Index: "0",
+								AZ:    "z1",
+								IPs:   []string{"1.2.3.4"},
+								Processes: []deployments.Process{
+									{Name: "gorouter", CPU: deployments.CPU{Total: &cpuTotal}, Mem: deployments.MemInt{Percent: &memPercent}},
+									{Name: "metron", CPU: deployments.CPU{Total: &cpuTotal}},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			collect = func() []prometheus.Metric {
+				metrics := make(chan prometheus.Metric, 10)
+				Expect(processThresholdsCollector.Collect(snapshot, metrics)).To(Succeed())
+				close(metrics)
+				collected := []prometheus.Metric{}
+				for metric := range metrics {
+					collected = append(collected, metric)
+				}
+				return collected
+			}
+		})
+
+		breachedMetric := func(value float64) prometheus.Metric {
+			return prometheus.MustNewConstMetric(
+				jobProcessThresholdBreachedDesc,
+				prometheus.GaugeValue,
+				value,
+				"fake-deployment-name",
+				"router",
+				"fake-job-id",
+				"0",
+				"z1",
+				"1.2.3.4",
+				"gorouter",
+				"gorouter_busy",
+			)
+		}
+
+		It("only evaluates the thresholds of the matching processes", func() {
+			Expect(collect()).To(HaveLen(1))
+		})
+
+		It("is not breached before the number of cycles", func() {
+			Expect(collect()).To(ConsistOf(PrometheusMetric(breachedMetric(0))))
+		})
+
+		It("is breached once the vitals stayed above the threshold for the number of cycles", func() {
+			collect()
+			Expect(collect()).To(ConsistOf(PrometheusMetric(breachedMetric(1))))
+		})
+
+		It("counts the cycles again once the vitals went back below the threshold", func() {
+			collect()
+			collect()
+			cpuTotal = 50
+			Expect(collect()).To(ConsistOf(PrometheusMetric(breachedMetric(0))))
+			cpuTotal = 95
+			Expect(collect()).To(ConsistOf(PrometheusMetric(breachedMetric(0))))
+			Expect(collect()).To(ConsistOf(PrometheusMetric(breachedMetric(1))))
+		})
+
+		It("is breached when the memory is above the threshold", func() {
+			cpuTotal = 50
+			memPercent = 95
+			collect()
+			Expect(collect()).To(ConsistOf(PrometheusMetric(breachedMetric(1))))
+		})
+
+		It("is not breached when the agent does not report the vitals", func() {
+			snapshot.Deployments[0].Instances[0].Processes[0] = deployments.Process{Name: "gorouter"}
+			collect()
+			Expect(collect()).To(ConsistOf(PrometheusMetric(breachedMetric(0))))
+		})
+
+		Context("when the process is filtered out", func() {
+			BeforeEach(func() {
+				expressionFilter, err = filters.NewExpressionFilter(`process != "gorouter"`)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("does not evaluate its thresholds", func() {
+				Expect(collect()).To(BeEmpty())
+			})
+		})
+	})
+})
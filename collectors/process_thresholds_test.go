@@ -0,0 +1,83 @@
+package collectors_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+)
+
+var _ = Describe("ParseProcessThresholds", func() {
+	It("parses the thresholds", func() {
+		thresholds, err := ParseProcessThresholds([]byte(`
+thresholds:
+- name: gorouter_busy
+  deployment: cf
+  process: gorouter
+  cpu: 80
+  mem_percent: 90
+  cycles: 3
+`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(thresholds).To(HaveLen(1))
+		Expect(thresholds[0].Name).To(Equal("gorouter_busy"))
+		Expect(*thresholds[0].CPU).To(Equal(float64(80)))
+		Expect(*thresholds[0].MemPercent).To(Equal(float64(90)))
+		Expect(thresholds[0].Cycles).To(Equal(3))
+	})
+
+	It("defaults to a single cycle", func() {
+		thresholds, err := ParseProcessThresholds([]byte(`
+thresholds:
+- name: busy
+  cpu: 80
+`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(thresholds[0].Cycles).To(Equal(1))
+	})
+
+	It("returns an error when the name is missing", func() {
+		_, err := ParseProcessThresholds([]byte(`
+thresholds:
+- cpu: 80
+`))
+		Expect(err).To(MatchError(ContainSubstring("`name` is required")))
+	})
+
+	It("returns an error when a name is used twice", func() {
+		_, err := ParseProcessThresholds([]byte(`
+thresholds:
+- name: busy
+  cpu: 80
+- name: busy
+  mem_percent: 90
+`))
+		Expect(err).To(MatchError(ContainSubstring("duplicate name `busy`")))
+	})
+
+	It("returns an error when no limit is set", func() {
+		_, err := ParseProcessThresholds([]byte(`
+thresholds:
+- name: busy
+  process: gorouter
+`))
+		Expect(err).To(MatchError(ContainSubstring("`cpu` or `mem_percent` is required")))
+	})
+
+	It("returns an error when a regexp is invalid", func() {
+		_, err := ParseProcessThresholds([]byte(`
+thresholds:
+- name: busy
+  process: "gorouter("
+  cpu: 80
+`))
+		Expect(err).To(MatchError(ContainSubstring("invalid process regexp")))
+	})
+})
+
+var _ = Describe("LoadProcessThresholds", func() {
+	It("returns an error when the file does not exist", func() {
+		_, err := LoadProcessThresholds("/does/not/exist")
+		Expect(err).To(MatchError(ContainSubstring("Error reading process thresholds file")))
+	})
+})
@@ -1,25 +1,45 @@
 package collectors
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/version"
 
+	"github.com/bosh-prometheus/bosh_exporter/clock"
 	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+	"github.com/bosh-prometheus/bosh_exporter/filesystem"
 	"github.com/bosh-prometheus/bosh_exporter/filters"
+	"github.com/bosh-prometheus/bosh_exporter/sanitizers"
+	"github.com/bosh-prometheus/bosh_exporter/sinks"
 )
 
 const (
 	boshDeploymentNameLabel = model.MetaLabelPrefix + "bosh_deployment"
 	boshJobProcessNameLabel = model.MetaLabelPrefix + "bosh_job_process_name"
+	boshJobTemplateLabel    = model.MetaLabelPrefix + "bosh_job_template"
+	boshStaleLabel          = model.MetaLabelPrefix + "bosh_stale"
+	boshJobProcessesLabel   = model.MetaLabelPrefix + "bosh_job_processes"
+)
+
+const (
+	ServiceDiscoveryTargetModeProcess  = "process"
+	ServiceDiscoveryTargetModeInstance = "instance"
 )
 
 type LabelGroups map[LabelGroupKey][]string
@@ -27,13 +47,72 @@ type LabelGroups map[LabelGroupKey][]string
 type LabelGroupKey struct {
 	DeploymentName string
 	ProcessName    string
+	Processes      string
+	JobTemplate    string
+	Attributes     string
+	Annotations    string
+	Stale          bool
 }
 
 func (k *LabelGroupKey) Labels() model.LabelSet {
-	return model.LabelSet{
+	labels := model.LabelSet{
 		model.LabelName(boshDeploymentNameLabel): model.LabelValue(k.DeploymentName),
-		model.LabelName(boshJobProcessNameLabel): model.LabelValue(k.ProcessName),
 	}
+	if k.Processes != "" {
+		labels[model.LabelName(boshJobProcessesLabel)] = model.LabelValue(k.Processes)
+	} else {
+		labels[model.LabelName(boshJobProcessNameLabel)] = model.LabelValue(k.ProcessName)
+	}
+	if k.JobTemplate != "" {
+		labels[model.LabelName(boshJobTemplateLabel)] = model.LabelValue(k.JobTemplate)
+	}
+	if k.Stale {
+		labels[model.LabelName(boshStaleLabel)] = model.LabelValue("true")
+	}
+	if k.Attributes != "" {
+		attributes := map[string]string{}
+		if err := json.Unmarshal([]byte(k.Attributes), &attributes); err == nil {
+			for labelName, value := range attributes {
+				labels[model.LabelName(model.MetaLabelPrefix+labelName)] = model.LabelValue(value)
+			}
+		}
+	}
+	if k.Annotations != "" {
+		annotations := map[string]string{}
+		if err := json.Unmarshal([]byte(k.Annotations), &annotations); err == nil {
+			for labelName, value := range annotations {
+				labels[model.LabelName(labelName)] = model.LabelValue(value)
+			}
+		}
+	}
+
+	return labels
+}
+
+type targetKey struct {
+	DeploymentName string
+	ProcessName    string
+	Processes      string
+	JobTemplate    string
+	Attributes     string
+	Annotations    string
+	Target         string
+}
+
+// pendingTarget is a process target waiting for the port probes before being
+// added to its label group.
+type pendingTarget struct {
+	key        LabelGroupKey
+	deployment deployments.DeploymentInfo
+	instance   deployments.Instance
+	ip         string
+	process    deployments.Process
+	target     string
+}
+
+type lastKnownIPs struct {
+	ips    []string
+	seenAt time.Time
 }
 
 type TargetGroups []TargetGroup
@@ -43,13 +122,61 @@ type TargetGroup struct {
 	Labels  model.LabelSet `json:"labels,omitempty"`
 }
 
+type TargetGroupsMetadata struct {
+	GeneratedAt     time.Time `json:"generated_at"`
+	ExporterVersion string    `json:"exporter_version"`
+	BoshName        string    `json:"bosh_name"`
+	BoshUUID        string    `json:"bosh_uuid"`
+	SHA256          string    `json:"sha256"`
+	HMACSHA256      string    `json:"hmac_sha256,omitempty"`
+}
+
+type TargetGroupsWithMetadata struct {
+	Metadata     TargetGroupsMetadata `json:"metadata"`
+	TargetGroups json.RawMessage      `json:"target_groups"`
+}
+
 type ServiceDiscoveryCollector struct {
+	boshName                                        string
+	boshUUID                                        string
 	serviceDiscoveryFilename                        string
+	serviceDiscoveryTmpDir                          string
+	serviceDiscoveryFsync                           bool
+	serviceDiscoveryKeepBackups                     int
+	serviceDiscoveryRefuseEmptyOutput               bool
+	serviceDiscoverySinks                           []sinks.Sink
+	serviceDiscoveryMetadata                        bool
+	serviceDiscoverySigningKey                      []byte
+	serviceDiscoveryTargetTTL                       time.Duration
+	serviceDiscoveryIPFallbackTTL                   time.Duration
+	serviceDiscoveryInstanceAttributes              []string
+	serviceDiscoveryProcessPorts                    map[string]int
+	serviceDiscoveryProcessAnnotations              []ProcessAnnotation
+	serviceDiscoveryTargetMode                      string
+	skippedInstancesLogInterval                     time.Duration
+	eventRecorder                                   EventRecorder
+	labelSanitizer                                  *sanitizers.LabelSanitizer
+	environment                                     string
+	clock                                           clock.Clock
+	fs                                              filesystem.FS
+	lastSeenTargets                                 map[targetKey]time.Time
+	lastSkippedInstanceLogs                         map[string]time.Time
+	lastKnownInstanceIPs                            map[string]lastKnownIPs
+	lastWrittenChecksum                             string
+	lastWrittenTargetGroups                         int
 	azsFilter                                       *filters.AZsFilter
 	processesFilter                                 *filters.RegexpFilter
+	deploymentProcessesFilter                       *filters.DeploymentProcessesFilter
+	expressionFilter                                *filters.ExpressionFilter
 	cidrsFilter                                     *filters.CidrFilter
+	portProber                                      *PortProber
 	lastServiceDiscoveryScrapeTimestampMetric       prometheus.Gauge
 	lastServiceDiscoveryScrapeDurationSecondsMetric prometheus.Gauge
+	instancesSkippedMetric                          *prometheus.CounterVec
+	ipFallbacksMetric                               prometheus.Counter
+	emptyOutputsRefusedMetric                       prometheus.Counter
+	unreachableTargetsMetric                        prometheus.Counter
+	jobProcessTargetInfoMetric                      *prometheus.GaugeVec
 	mu                                              *sync.Mutex
 }
 
@@ -59,8 +186,26 @@ func NewServiceDiscoveryCollector(
 	boshName string,
 	boshUUID string,
 	serviceDiscoveryFilename string,
+	serviceDiscoveryTmpDir string,
+	serviceDiscoveryFsync bool,
+	serviceDiscoveryKeepBackups int,
+	serviceDiscoveryRefuseEmptyOutput bool,
+	serviceDiscoverySinks []sinks.Sink,
+	serviceDiscoveryMetadata bool,
+	serviceDiscoverySigningKey []byte,
+	serviceDiscoveryTargetTTL time.Duration,
+	serviceDiscoveryIPFallbackTTL time.Duration,
+	serviceDiscoveryInstanceAttributes []string,
+	serviceDiscoveryProcessPorts map[string]int,
+	serviceDiscoveryProcessAnnotations []ProcessAnnotation,
+	serviceDiscoveryTargetMode string,
+	skippedInstancesLogInterval time.Duration,
+	eventRecorder EventRecorder,
+	labelSanitizer *sanitizers.LabelSanitizer,
 	azsFilter *filters.AZsFilter,
 	processesFilter *filters.RegexpFilter,
+	deploymentProcessesFilter *filters.DeploymentProcessesFilter,
+	expressionFilter *filters.ExpressionFilter,
 	cidrsFilter *filters.CidrFilter,
 ) *ServiceDiscoveryCollector {
 	lastServiceDiscoveryScrapeTimestampMetric := prometheus.NewGauge(
@@ -91,38 +236,269 @@ func NewServiceDiscoveryCollector(
 		},
 	)
 
+	instancesSkippedMetric := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "instances_skipped_total",
+			Help:      "Total number of BOSH instances left out of the Service Discovery targets, by reason.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"reason"},
+	)
+
+	ipFallbacksMetric := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "sd_ip_fallbacks_total",
+			Help:      "Total number of times the last known IPs of a BOSH instance were used because BOSH reported none.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
+	emptyOutputsRefusedMetric := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "sd_empty_outputs_refused_total",
+			Help:      "Total number of empty Service Discovery outputs not written over non-empty ones.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
+	unreachableTargetsMetric := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "sd_unreachable_targets_total",
+			Help:      "Total number of Service Discovery targets left out because their port did not answer the probe.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
+	jobProcessTargetInfoMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "job_process",
+			Name:      "target_info",
+			Help:      "BOSH Job Process Service Discovery target, to join scraped metrics with BOSH Job Process metrics.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template", "target"},
+	)
+
+	serviceDiscoveryInstanceAttributes, _ = instanceAttributeLabelNames(serviceDiscoveryInstanceAttributes)
+
 	collector := &ServiceDiscoveryCollector{
-		serviceDiscoveryFilename: serviceDiscoveryFilename,
-		azsFilter:                azsFilter,
-		processesFilter:          processesFilter,
-		cidrsFilter:              cidrsFilter,
-		lastServiceDiscoveryScrapeTimestampMetric:       lastServiceDiscoveryScrapeTimestampMetric,
+		boshName:                                  boshName,
+		boshUUID:                                  boshUUID,
+		serviceDiscoveryFilename:                  serviceDiscoveryFilename,
+		serviceDiscoveryTmpDir:                    serviceDiscoveryTmpDir,
+		serviceDiscoveryFsync:                     serviceDiscoveryFsync,
+		serviceDiscoveryKeepBackups:               serviceDiscoveryKeepBackups,
+		serviceDiscoveryRefuseEmptyOutput:         serviceDiscoveryRefuseEmptyOutput,
+		serviceDiscoverySinks:                     serviceDiscoverySinks,
+		serviceDiscoveryMetadata:                  serviceDiscoveryMetadata,
+		serviceDiscoverySigningKey:                serviceDiscoverySigningKey,
+		serviceDiscoveryTargetTTL:                 serviceDiscoveryTargetTTL,
+		serviceDiscoveryIPFallbackTTL:             serviceDiscoveryIPFallbackTTL,
+		serviceDiscoveryInstanceAttributes:        serviceDiscoveryInstanceAttributes,
+		serviceDiscoveryProcessPorts:              serviceDiscoveryProcessPorts,
+		serviceDiscoveryProcessAnnotations:        serviceDiscoveryProcessAnnotations,
+		serviceDiscoveryTargetMode:                serviceDiscoveryTargetMode,
+		skippedInstancesLogInterval:               skippedInstancesLogInterval,
+		eventRecorder:                             eventRecorder,
+		labelSanitizer:                            labelSanitizer,
+		environment:                               environment,
+		clock:                                     clock.Real,
+		fs:                                        filesystem.OS,
+		lastSeenTargets:                           map[targetKey]time.Time{},
+		lastSkippedInstanceLogs:                   map[string]time.Time{},
+		lastKnownInstanceIPs:                      map[string]lastKnownIPs{},
+		lastWrittenTargetGroups:                   -1,
+		azsFilter:                                 azsFilter,
+		processesFilter:                           processesFilter,
+		deploymentProcessesFilter:                 deploymentProcessesFilter,
+		expressionFilter:                          expressionFilter,
+		cidrsFilter:                               cidrsFilter,
+		lastServiceDiscoveryScrapeTimestampMetric: lastServiceDiscoveryScrapeTimestampMetric,
 		lastServiceDiscoveryScrapeDurationSecondsMetric: lastServiceDiscoveryScrapeDurationSecondsMetric,
-		mu: &sync.Mutex{},
+		instancesSkippedMetric:                          instancesSkippedMetric,
+		ipFallbacksMetric:                               ipFallbacksMetric,
+		emptyOutputsRefusedMetric:                       emptyOutputsRefusedMetric,
+		unreachableTargetsMetric:                        unreachableTargetsMetric,
+		jobProcessTargetInfoMetric:                      jobProcessTargetInfoMetric,
+		mu:                                              &sync.Mutex{},
 	}
 	return collector
 }
 
-func (c *ServiceDiscoveryCollector) Collect(deployments []deployments.DeploymentInfo, ch chan<- prometheus.Metric) error {
+// SetClock replaces the clock timing the targets TTL and IP fallbacks and
+// dating the output metadata. It must be called before the first collection.
+func (c *ServiceDiscoveryCollector) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+// SetPortProber makes the collector only write the process targets whose
+// port answers the prober. It must be called before the first collection.
+func (c *ServiceDiscoveryCollector) SetPortProber(prober *PortProber) {
+	c.portProber = prober
+}
+
+// SetFS replaces the file system the output file and its backups are read
+// from and written to. It must be called before the first collection.
+func (c *ServiceDiscoveryCollector) SetFS(fs filesystem.FS) {
+	c.fs = fs
+}
+
+func (c *ServiceDiscoveryCollector) Collect(snapshot fetcher.Snapshot, ch chan<- prometheus.Metric) error {
+	return c.CollectStream(snapshot, snapshot.EachDeployment, ch)
+}
+
+func (c *ServiceDiscoveryCollector) CollectStream(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
 	var begun = time.Now()
+	now := c.clock.Now()
 
-	labelGroups := c.createLabelGroups(deployments)
+	c.jobProcessTargetInfoMetric.Reset()
+
+	labelGroups, err := c.createLabelGroups(eachDeployment, now)
+	if err != nil {
+		return err
+	}
+	if c.serviceDiscoveryTargetTTL > 0 {
+		c.addStaleTargets(labelGroups, now)
+	}
 	targetGroups := c.createTargetGroups(labelGroups)
 
-	err := c.writeTargetGroupsToFile(targetGroups)
+	if c.refuseEmptyOutput(targetGroups, snapshot) {
+		log.Warnf("Not replacing the Service Discovery output with an empty one, as BOSH did not report zero deployments")
+		c.emptyOutputsRefusedMetric.Inc()
+	} else {
+		err = c.writeTargetGroups(targetGroups)
+		c.recordWriteState(err)
+	}
 
-	c.lastServiceDiscoveryScrapeTimestampMetric.Set(float64(time.Now().Unix()))
+	c.jobProcessTargetInfoMetric.Collect(ch)
+
+	c.lastServiceDiscoveryScrapeTimestampMetric.Set(float64(c.clock.Now().Unix()))
 	c.lastServiceDiscoveryScrapeTimestampMetric.Collect(ch)
 
 	c.lastServiceDiscoveryScrapeDurationSecondsMetric.Set(time.Since(begun).Seconds())
 	c.lastServiceDiscoveryScrapeDurationSecondsMetric.Collect(ch)
 
+	c.instancesSkippedMetric.Collect(ch)
+	if c.serviceDiscoveryIPFallbackTTL > 0 {
+		c.ipFallbacksMetric.Collect(ch)
+	}
+	if c.serviceDiscoveryRefuseEmptyOutput {
+		c.emptyOutputsRefusedMetric.Collect(ch)
+	}
+	if c.portProber != nil {
+		c.unreachableTargetsMetric.Collect(ch)
+	}
+
 	return err
 }
 
+// PreviewTargetGroups returns the target groups currently served and the ones
+// the next write would produce from the snapshot, without writing them nor
+// updating the metrics, logs and state of the collector. A non-nil
+// expressionFilter replaces the configured one.
+func (c *ServiceDiscoveryCollector) PreviewTargetGroups(snapshot fetcher.Snapshot, expressionFilter *filters.ExpressionFilter) (TargetGroups, TargetGroups, error) {
+	current, err := readFileTargetGroups(c.fs, c.serviceDiscoveryFilename)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("Error reading Service Discovery output `%s`: %v", c.serviceDiscoveryFilename, err))
+	}
+
+	preview := c.previewCopy()
+	if expressionFilter != nil {
+		preview.expressionFilter = expressionFilter
+	}
+
+	now := c.clock.Now()
+	labelGroups, err := preview.createLabelGroups(snapshot.EachDeployment, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if preview.serviceDiscoveryTargetTTL > 0 {
+		preview.addStaleTargets(labelGroups, now)
+	}
+
+	return current, preview.createTargetGroups(labelGroups), nil
+}
+
+// previewCopy returns a copy of the collector working on copies of its state
+// and on unregistered metrics, not logging the skipped instances and not
+// probing the target ports, reusing the results of the last probes instead.
+func (c *ServiceDiscoveryCollector) previewCopy() *ServiceDiscoveryCollector {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	preview := *c
+	preview.mu = &sync.Mutex{}
+	preview.skippedInstancesLogInterval = 0
+	preview.portProber = c.portProber.cachedOnlyCopy()
+	preview.lastSeenTargets = make(map[targetKey]time.Time, len(c.lastSeenTargets))
+	for target, lastSeen := range c.lastSeenTargets {
+		preview.lastSeenTargets[target] = lastSeen
+	}
+	preview.lastKnownInstanceIPs = make(map[string]lastKnownIPs, len(c.lastKnownInstanceIPs))
+	for key, lastKnown := range c.lastKnownInstanceIPs {
+		preview.lastKnownInstanceIPs[key] = lastKnown
+	}
+	preview.instancesSkippedMetric = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "preview_instances_skipped"}, []string{"reason"})
+	preview.ipFallbacksMetric = prometheus.NewCounter(prometheus.CounterOpts{Name: "preview_ip_fallbacks"})
+	preview.unreachableTargetsMetric = prometheus.NewCounter(prometheus.CounterOpts{Name: "preview_unreachable_targets"})
+	preview.jobProcessTargetInfoMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "preview_job_process_target_info"},
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template", "target"},
+	)
+
+	return &preview
+}
+
+func (c *ServiceDiscoveryCollector) recordWriteState(err error) {
+	if c.eventRecorder == nil {
+		return
+	}
+
+	key := c.environment + "/service_discovery"
+	if err != nil {
+		c.eventRecorder.Transition(key, EventTypeWarning, EventReasonServiceDiscoveryWriteFailed, fmt.Sprintf("Error writing the Service Discovery output of BOSH Director `%s`: %v", c.boshName, err))
+		return
+	}
+	c.eventRecorder.Transition(key, EventTypeNormal, EventReasonServiceDiscoveryWritten, fmt.Sprintf("Service Discovery output of BOSH Director `%s` written", c.boshName))
+}
+
 func (c *ServiceDiscoveryCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.lastServiceDiscoveryScrapeTimestampMetric.Describe(ch)
 	c.lastServiceDiscoveryScrapeDurationSecondsMetric.Describe(ch)
+	c.instancesSkippedMetric.Describe(ch)
+	c.ipFallbacksMetric.Describe(ch)
+	c.emptyOutputsRefusedMetric.Describe(ch)
+	c.unreachableTargetsMetric.Describe(ch)
+	c.jobProcessTargetInfoMetric.Describe(ch)
 }
 
 func (c *ServiceDiscoveryCollector) getLabelGroupKey(
@@ -133,41 +509,294 @@ func (c *ServiceDiscoveryCollector) getLabelGroupKey(
 	return LabelGroupKey{
 		DeploymentName: deployment.Name,
 		ProcessName:    process.Name,
+		JobTemplate:    process.JobTemplate,
+		Attributes:     c.instanceAttributes(instance),
+		Annotations:    processAnnotations(c.serviceDiscoveryProcessAnnotations, deployment.Name, process.Name),
+	}
+}
+
+func (c *ServiceDiscoveryCollector) instanceAttributes(instance deployments.Instance) string {
+	if len(c.serviceDiscoveryInstanceAttributes) == 0 {
+		return ""
+	}
+
+	attributes := map[string]string{}
+	for _, attribute := range c.serviceDiscoveryInstanceAttributes {
+		attributes[instanceAttributeLabelName(attribute)] = instance.Attributes[attribute]
 	}
+
+	encodedAttributes, err := json.Marshal(attributes)
+	if err != nil {
+		return ""
+	}
+
+	return string(encodedAttributes)
 }
 
-func (c *ServiceDiscoveryCollector) createLabelGroups(deployments []deployments.DeploymentInfo) LabelGroups {
+func (c *ServiceDiscoveryCollector) createLabelGroups(eachDeployment fetcher.DeploymentsIterator, now time.Time) (LabelGroups, error) {
 	labelGroups := LabelGroups{}
+	pendingTargets := []pendingTarget{}
 
-	for _, deployment := range deployments {
+	err := eachDeployment(func(deployment deployments.DeploymentInfo) error {
 		for _, instance := range deployment.Instances {
+			instance.IPs = c.instanceIPs(deployment, instance, now)
+			if len(instance.IPs) == 0 {
+				c.skipInstance(deployment, instance, "no_ip", "the instance has no IP")
+				continue
+			}
 			ip, found := c.cidrsFilter.Select(instance.IPs)
-			if !found || !c.azsFilter.Enabled(instance.AZ) {
+			if !found {
+				c.skipInstance(deployment, instance, "cidr_mismatch", fmt.Sprintf("none of the IPs %v is in the configured CIDRs", instance.IPs))
+				continue
+			}
+			if !c.azsFilter.EnabledInstance(instance.AZ, instance.Attributes) {
+				c.skipInstance(deployment, instance, "az_filter", fmt.Sprintf("AZ `%s` is filtered out", instance.AZ))
+				continue
+			}
+			if len(instance.Processes) == 0 {
+				c.skipInstance(deployment, instance, "no_processes", "the instance has no processes")
 				continue
 			}
 
+			processes := []deployments.Process{}
 			for _, process := range instance.Processes {
-				if !c.processesFilter.Enabled(process.Name) {
+				if !c.processesFilter.Enabled(process.Name) || !c.deploymentProcessesFilter.Enabled(deployment.Name, process.Name) {
 					continue
 				}
+				if !c.expressionFilter.Enabled(map[string]string{"deployment": deployment.Name, "job": instance.Name, "az": instance.AZ, "ip": ip, "process": process.Name}) {
+					continue
+				}
+				processes = append(processes, process)
+			}
+			if len(processes) == 0 {
+				c.skipInstance(deployment, instance, "processes_filtered", "all the processes are filtered out")
+				continue
+			}
+
+			if c.serviceDiscoveryTargetMode == ServiceDiscoveryTargetModeInstance {
+				c.addInstanceTarget(labelGroups, deployment, instance, ip, processes)
+				continue
+			}
+			for _, process := range processes {
 				key := c.getLabelGroupKey(deployment, instance, process)
-				if _, ok := labelGroups[key]; !ok {
-					labelGroups[key] = []string{}
+				for _, target := range processTargets(ip, process, c.serviceDiscoveryProcessPorts) {
+					pendingTargets = append(pendingTargets, pendingTarget{
+						key:        key,
+						deployment: deployment,
+						instance:   instance,
+						ip:         ip,
+						process:    process,
+						target:     target,
+					})
 				}
-				labelGroups[key] = append(labelGroups[key], ip)
 			}
 		}
+		return nil
+	})
+
+	reachable := c.probeTargets(pendingTargets)
+	for _, pending := range pendingTargets {
+		if reachable != nil && !reachable[pending.target] {
+			continue
+		}
+		labelGroups[pending.key] = append(labelGroups[pending.key], pending.target)
+		c.reportJobProcessTarget(pending.deployment, pending.instance, pending.ip, pending.process, pending.target)
+	}
+
+	if c.serviceDiscoveryIPFallbackTTL > 0 {
+		c.mu.Lock()
+		for key, lastKnown := range c.lastKnownInstanceIPs {
+			if now.Sub(lastKnown.seenAt) > c.serviceDiscoveryIPFallbackTTL {
+				delete(c.lastKnownInstanceIPs, key)
+			}
+		}
+		c.mu.Unlock()
+	}
+
+	return labelGroups, err
+}
+
+// probeTargets returns whether each process target answered the port prober,
+// or nil when targets are not probed. The unreachable targets are forgotten so
+// they are not kept as stale targets either.
+func (c *ServiceDiscoveryCollector) probeTargets(pendingTargets []pendingTarget) map[string]bool {
+	if c.portProber == nil || len(pendingTargets) == 0 {
+		return nil
+	}
+
+	targets := make([]string, 0, len(pendingTargets))
+	for _, pending := range pendingTargets {
+		targets = append(targets, pending.target)
+	}
+	reachable := c.portProber.Reachable(targets)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, pending := range pendingTargets {
+		if reachable[pending.target] {
+			continue
+		}
+		c.unreachableTargetsMetric.Inc()
+		delete(c.lastSeenTargets, targetKey{
+			DeploymentName: pending.key.DeploymentName,
+			ProcessName:    pending.key.ProcessName,
+			JobTemplate:    pending.key.JobTemplate,
+			Attributes:     pending.key.Attributes,
+			Annotations:    pending.key.Annotations,
+			Target:         pending.target,
+		})
+	}
+
+	return reachable
+}
+
+// instanceIPs falls back to the last IPs known for an instance when BOSH
+// transiently reports it without any (e.g. while running cck), so its targets
+// are not dropped.
+func (c *ServiceDiscoveryCollector) instanceIPs(deployment deployments.DeploymentInfo, instance deployments.Instance, now time.Time) []string {
+	if c.serviceDiscoveryIPFallbackTTL <= 0 || instance.ID == "" {
+		return instance.IPs
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := deployment.Name + "/" + instance.ID
+	if len(instance.IPs) > 0 {
+		c.lastKnownInstanceIPs[key] = lastKnownIPs{ips: instance.IPs, seenAt: now}
+		return instance.IPs
+	}
+
+	lastKnown, ok := c.lastKnownInstanceIPs[key]
+	if !ok || now.Sub(lastKnown.seenAt) > c.serviceDiscoveryIPFallbackTTL {
+		return instance.IPs
+	}
+
+	c.ipFallbacksMetric.Inc()
+	return lastKnown.ips
+}
+
+// addInstanceTarget adds a single target per instance, on its IP, listing the
+// colocated processes instead of repeating the target for each of them.
+func (c *ServiceDiscoveryCollector) addInstanceTarget(
+	labelGroups LabelGroups,
+	deployment deployments.DeploymentInfo,
+	instance deployments.Instance,
+	ip string,
+	processes []deployments.Process,
+) {
+	processNames := make([]string, 0, len(processes))
+	for _, process := range processes {
+		processNames = append(processNames, process.Name)
+		c.reportJobProcessTarget(deployment, instance, ip, process, ip)
 	}
+	sort.Strings(processNames)
 
-	return labelGroups
+	key := LabelGroupKey{
+		DeploymentName: deployment.Name,
+		Processes:      "," + strings.Join(processNames, ",") + ",",
+		Attributes:     c.instanceAttributes(instance),
+		Annotations:    processAnnotations(c.serviceDiscoveryProcessAnnotations, deployment.Name, processNames...),
+	}
+	labelGroups[key] = append(labelGroups[key], ip)
+}
+
+func (c *ServiceDiscoveryCollector) reportJobProcessTarget(
+	deployment deployments.DeploymentInfo,
+	instance deployments.Instance,
+	ip string,
+	process deployments.Process,
+	target string,
+) {
+	c.jobProcessTargetInfoMetric.WithLabelValues(
+		deployment.Name,
+		instance.Name,
+		instance.ID,
+		instance.Index,
+		instance.AZ,
+		ip,
+		process.Name,
+		process.JobTemplate,
+		target,
+	).Set(float64(1))
+}
+
+// skipInstance logs at most one skipped instance per reason and log interval,
+// so the log stays readable on large directors.
+func (c *ServiceDiscoveryCollector) skipInstance(deployment deployments.DeploymentInfo, instance deployments.Instance, reason string, detail string) {
+	c.instancesSkippedMetric.WithLabelValues(reason).Inc()
+
+	if c.skippedInstancesLogInterval <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if now.Sub(c.lastSkippedInstanceLogs[reason]) < c.skippedInstancesLogInterval {
+		return
+	}
+	c.lastSkippedInstanceLogs[reason] = now
+
+	log.Infof("Skipping instance `%s/%s` of deployment `%s` from Service Discovery (%s): %s", instance.Name, instance.ID, deployment.Name, reason, detail)
+}
+
+func (c *ServiceDiscoveryCollector) addStaleTargets(labelGroups LabelGroups, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seenTargets := map[targetKey]bool{}
+	for key, targets := range labelGroups {
+		for _, target := range targets {
+			seenTarget := targetKey{
+				DeploymentName: key.DeploymentName,
+				ProcessName:    key.ProcessName,
+				Processes:      key.Processes,
+				JobTemplate:    key.JobTemplate,
+				Attributes:     key.Attributes,
+				Annotations:    key.Annotations,
+				Target:         target,
+			}
+			c.lastSeenTargets[seenTarget] = now
+			seenTargets[seenTarget] = true
+		}
+	}
+
+	for target, lastSeen := range c.lastSeenTargets {
+		if seenTargets[target] {
+			continue
+		}
+
+		if now.Sub(lastSeen) > c.serviceDiscoveryTargetTTL {
+			delete(c.lastSeenTargets, target)
+			continue
+		}
+
+		key := LabelGroupKey{
+			DeploymentName: target.DeploymentName,
+			ProcessName:    target.ProcessName,
+			Processes:      target.Processes,
+			JobTemplate:    target.JobTemplate,
+			Attributes:     target.Attributes,
+			Annotations:    target.Annotations,
+			Stale:          true,
+		}
+		labelGroups[key] = append(labelGroups[key], target.Target)
+	}
 }
 
 func (c *ServiceDiscoveryCollector) createTargetGroups(labelGroups LabelGroups) TargetGroups {
 	targetGroups := TargetGroups{}
 
 	for key, targets := range labelGroups {
+		labels := key.Labels()
+		for labelName, labelValue := range labels {
+			labels[labelName] = model.LabelValue(c.labelSanitizer.Sanitize(string(labelName), string(labelValue)))
+		}
 		targetGroups = append(targetGroups, TargetGroup{
-			Labels:  key.Labels(),
+			Labels:  labels,
 			Targets: targets,
 		})
 	}
@@ -175,35 +804,251 @@ func (c *ServiceDiscoveryCollector) createTargetGroups(labelGroups LabelGroups)
 	return targetGroups
 }
 
-func (c *ServiceDiscoveryCollector) writeTargetGroupsToFile(targetGroups TargetGroups) error {
+func (c *ServiceDiscoveryCollector) writeTargetGroups(targetGroups TargetGroups) error {
 	targetGroupsJSON, err := json.Marshal(targetGroups)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error while marshalling TargetGroups: %v", err))
 	}
 
-	dir, name := path.Split(c.serviceDiscoveryFilename)
-	f, err := ioutil.TempFile(dir, name)
-	if err != nil {
-		return errors.New(fmt.Sprintf("Error creating temp file: %v", err))
+	var signature string
+	if len(c.serviceDiscoverySigningKey) > 0 {
+		mac := hmac.New(sha256.New, c.serviceDiscoverySigningKey)
+		mac.Write(targetGroupsJSON)
+		signature = hex.EncodeToString(mac.Sum(nil))
+	}
+
+	content := targetGroupsJSON
+	contentSignature := signature
+	if c.serviceDiscoveryMetadata {
+		content, err = c.wrapTargetGroups(targetGroupsJSON, signature)
+		if err != nil {
+			return err
+		}
+		// The signature is then part of the content, it is not the signature
+		// of the content itself.
+		contentSignature = ""
+	}
+
+	files := []outputFile{{filename: c.serviceDiscoveryFilename, content: content}}
+	if contentSignature != "" {
+		files = append(files, outputFile{filename: c.serviceDiscoveryFilename + ".sig", content: []byte(contentSignature + "\n")})
+	}
+	err = c.writeOutputFile(targetGroupsJSON, len(targetGroups), files)
+
+	for _, sink := range c.serviceDiscoverySinks {
+		if sinkErr := sinks.Write(sink, content, contentSignature); sinkErr != nil && err == nil {
+			err = sinkErr
+		}
+	}
+
+	return err
+}
+
+// refuseEmptyOutput tells whether empty target groups must not replace the
+// previous non-empty output, which happens when fetching the deployments
+// failed, unless BOSH explicitly reported zero deployments.
+func (c *ServiceDiscoveryCollector) refuseEmptyOutput(targetGroups TargetGroups, snapshot fetcher.Snapshot) bool {
+	if !c.serviceDiscoveryRefuseEmptyOutput || len(targetGroups) > 0 {
+		return false
+	}
+	if snapshot.VisibleDeployments != nil && len(snapshot.VisibleDeployments) == 0 {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lastWrittenTargetGroups < 0 {
+		c.lastWrittenTargetGroups = countFileTargetGroups(c.fs, c.serviceDiscoveryFilename)
+	}
+
+	return c.lastWrittenTargetGroups > 0
+}
+
+// writeOutputFile writes the Service Discovery output file and its signature,
+// first rotating its backups when the target groups changed since the last
+// write.
+func (c *ServiceDiscoveryCollector) writeOutputFile(targetGroupsJSON []byte, targetGroupsCount int, files []outputFile) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checksum := sha256.Sum256(targetGroupsJSON)
+	encodedChecksum := hex.EncodeToString(checksum[:])
+	if c.serviceDiscoveryKeepBackups > 0 && encodedChecksum != c.lastWrittenChecksum {
+		if err := c.rotateBackups(); err != nil {
+			log.Errorf("Error while rotating Service Discovery backups: %v", err)
+		}
 	}
 
-	_, err = f.Write(targetGroupsJSON)
+	err := c.writeFiles(files...)
 	if err == nil {
+		c.lastWrittenChecksum = encodedChecksum
+		c.lastWrittenTargetGroups = targetGroupsCount
+	}
+
+	return err
+}
+
+// rotateBackups keeps the previous versions of the Service Discovery output
+// in <filename>.1 to <filename>.N, the most recent first.
+func (c *ServiceDiscoveryCollector) rotateBackups() error {
+	content, err := c.fs.ReadFile(c.serviceDiscoveryFilename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := c.serviceDiscoveryKeepBackups - 1; i >= 1; i-- {
+		backupFilename := fmt.Sprintf("%s.%d", c.serviceDiscoveryFilename, i)
+		if _, err := c.fs.Stat(backupFilename); os.IsNotExist(err) {
+			continue
+		}
+		if err := c.fs.Rename(backupFilename, fmt.Sprintf("%s.%d", c.serviceDiscoveryFilename, i+1)); err != nil {
+			return err
+		}
+	}
+
+	return c.writeFile(c.serviceDiscoveryFilename+".1", content)
+}
+
+func (c *ServiceDiscoveryCollector) wrapTargetGroups(targetGroupsJSON []byte, signature string) ([]byte, error) {
+	checksum := sha256.Sum256(targetGroupsJSON)
+
+	content, err := json.Marshal(TargetGroupsWithMetadata{
+		Metadata: TargetGroupsMetadata{
+			GeneratedAt:     c.clock.Now().UTC(),
+			ExporterVersion: version.Version,
+			BoshName:        c.boshName,
+			BoshUUID:        c.boshUUID,
+			SHA256:          hex.EncodeToString(checksum[:]),
+			HMACSHA256:      signature,
+		},
+		TargetGroups: targetGroupsJSON,
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error while marshalling TargetGroups metadata: %v", err))
+	}
+
+	return content, nil
+}
+
+func (c *ServiceDiscoveryCollector) writeFile(filename string, content []byte) error {
+	return c.writeFiles(outputFile{filename: filename, content: content})
+}
+
+// outputFile is a file written by the collector.
+type outputFile struct {
+	filename string
+	content  []byte
+}
+
+// writeFiles writes files living in the same directory, so either all of them
+// or none are replaced.
+func (c *ServiceDiscoveryCollector) writeFiles(files ...outputFile) error {
+	dir, _ := path.Split(files[0].filename)
+	tmpDir := dir
+	if c.serviceDiscoveryTmpDir != "" {
+		tmpDir = c.serviceDiscoveryTmpDir
+	}
+
+	err := c.writeFilesThrough(tmpDir, files)
+	if errors.Is(err, syscall.EXDEV) && path.Clean(tmpDir) != path.Clean(dir) {
+		log.Debugf("Temp directory `%s` is on a different filesystem than `%s`, writing the temp files next to it", tmpDir, files[0].filename)
+		err = c.writeFilesThrough(dir, files)
+	}
+
+	return err
+}
+
+// writeFilesThrough writes every file to a temp file in tmpDir, then renames
+// them in order once all of them are written, so readers never see a
+// partially written file, nor a file replaced while the next one could not
+// be written.
+func (c *ServiceDiscoveryCollector) writeFilesThrough(tmpDir string, files []outputFile) error {
+	tmpNames := make([]string, 0, len(files))
+	renamed := 0
+	defer func() {
+		for _, tmpName := range tmpNames[renamed:] {
+			c.fs.Remove(tmpName)
+		}
+	}()
+
+	for _, file := range files {
+		tmpName, err := c.writeTempFile(tmpDir, file)
+		if err != nil {
+			return err
+		}
+		tmpNames = append(tmpNames, tmpName)
+	}
+
+	for i, file := range files {
+		if err := c.fs.Rename(tmpNames[i], file.filename); err != nil {
+			return err
+		}
+		renamed++
+	}
+
+	return nil
+}
+
+// writeTempFile writes the content of file to a temp file in tmpDir.
+func (c *ServiceDiscoveryCollector) writeTempFile(tmpDir string, file outputFile) (string, error) {
+	_, name := path.Split(file.filename)
+	f, err := c.fs.TempFile(tmpDir, name)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Error creating temp file: %v", err))
+	}
+
+	_, err = f.Write(file.content)
+	if err == nil && c.serviceDiscoveryFsync {
 		err = f.Sync()
 	}
 	if closeErr := f.Close(); err == nil {
 		err = closeErr
 	}
-	if permErr := os.Chmod(f.Name(), 0644); err == nil {
+	if permErr := c.fs.Chmod(f.Name(), 0644); err == nil {
 		err = permErr
 	}
-	if err == nil {
-		err = os.Rename(f.Name(), c.serviceDiscoveryFilename)
+	if err != nil {
+		c.fs.Remove(f.Name())
+		return "", err
 	}
 
+	return f.Name(), nil
+}
+
+func countFileTargetGroups(fs filesystem.FS, filename string) int {
+	targetGroups, err := readFileTargetGroups(fs, filename)
 	if err != nil {
-		os.Remove(f.Name())
+		return 0
 	}
 
-	return err
+	return len(targetGroups)
+}
+
+// readFileTargetGroups reads the target groups of a Service Discovery output
+// file, with or without metadata. A missing file has no target groups.
+func readFileTargetGroups(fs filesystem.FS, filename string) (TargetGroups, error) {
+	content, err := fs.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return TargetGroups{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var targetGroups TargetGroups
+	if err := json.Unmarshal(content, &targetGroups); err != nil {
+		var targetGroupsWithMetadata TargetGroupsWithMetadata
+		if err := json.Unmarshal(content, &targetGroupsWithMetadata); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(targetGroupsWithMetadata.TargetGroups, &targetGroups); err != nil {
+			return nil, err
+		}
+	}
+
+	return targetGroups, nil
 }
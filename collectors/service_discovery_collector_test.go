@@ -1,8 +1,18 @@
 package collectors_test
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
+	"sync"
+	"syscall"
+	"time"
 
 	. "github.com/benjamintf1/unmarshalledmatchers"
 	. "github.com/onsi/ginkgo"
@@ -11,32 +21,99 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
 
+	"github.com/bosh-prometheus/bosh_exporter/clock"
 	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+	"github.com/bosh-prometheus/bosh_exporter/filesystem"
 	"github.com/bosh-prometheus/bosh_exporter/filters"
+	"github.com/bosh-prometheus/bosh_exporter/sanitizers"
+	"github.com/bosh-prometheus/bosh_exporter/sinks"
 
 	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
 )
 
 func init() {
 	log.Base().SetLevel("fatal")
 }
 
+type fakeSink struct {
+	content []byte
+	err     error
+}
+
+func (s *fakeSink) Write(content []byte) error {
+	s.content = content
+	return s.err
+}
+
+type fakeSignedSink struct {
+	fakeSink
+	signature string
+}
+
+func (s *fakeSignedSink) WriteSigned(content []byte, signature string) error {
+	s.content = content
+	s.signature = signature
+	return s.err
+}
+
+type fakeEventRecorder struct {
+	mu      sync.Mutex
+	reasons []string
+}
+
+func (r *fakeEventRecorder) Transition(key string, eventType string, reason string, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reasons = append(r.reasons, key+" "+eventType+" "+reason)
+}
+
+func (r *fakeEventRecorder) Reasons() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.reasons...)
+}
+
 var _ = Describe("ServiceDiscoveryCollector", func() {
 	var (
-		err                       error
-		namespace                 string
-		environment               string
-		boshName                  string
-		boshUUID                  string
-		tmpfile                   *os.File
-		serviceDiscoveryFilename  string
-		azsFilter                 *filters.AZsFilter
-		processesFilter           *filters.RegexpFilter
-		cidrsFilter               *filters.CidrFilter
-		serviceDiscoveryCollector *ServiceDiscoveryCollector
+		err                               error
+		namespace                         string
+		environment                       string
+		boshName                          string
+		boshUUID                          string
+		tmpfile                           *os.File
+		serviceDiscoveryFilename          string
+		serviceDiscoveryTmpDir            string
+		serviceDiscoveryFsync             bool
+		serviceDiscoveryKeepBackups       int
+		serviceDiscoveryRefuseEmptyOutput bool
+		serviceDiscoverySinks             []sinks.Sink
+		serviceDiscoveryMetadata          bool
+		serviceDiscoverySigningKey        []byte
+		serviceDiscoveryTargetTTL         time.Duration
+		ipFallbackTTL                     time.Duration
+		instanceAttributes                []string
+		processPorts                      map[string]int
+		processAnnotations                []ProcessAnnotation
+		targetMode                        string
+		skippedInstancesLogInterval       time.Duration
+		labelSanitizer                    *sanitizers.LabelSanitizer
+		eventRecorder                     EventRecorder
+		azsFilter                         *filters.AZsFilter
+		processesFilter                   *filters.RegexpFilter
+		deploymentProcessesFilter         *filters.DeploymentProcessesFilter
+		expressionFilter                  *filters.ExpressionFilter
+		cidrsFilter                       *filters.CidrFilter
+		fakeClock                         *clock.FakeClock
+		fs                                filesystem.FS
+		portProber                        *PortProber
+		serviceDiscoveryCollector         *ServiceDiscoveryCollector
 
 		lastServiceDiscoveryScrapeTimestampMetric       prometheus.Gauge
 		lastServiceDiscoveryScrapeDurationSecondsMetric prometheus.Gauge
+		instancesSkippedMetric                          *prometheus.CounterVec
+		jobProcessTargetInfoMetric                      *prometheus.GaugeVec
 	)
 
 	BeforeEach(func() {
@@ -47,9 +124,30 @@ var _ = Describe("ServiceDiscoveryCollector", func() {
 		tmpfile, err = ioutil.TempFile("", "service_discovery_collector_test_")
 		Expect(err).ToNot(HaveOccurred())
 		serviceDiscoveryFilename = tmpfile.Name()
+		serviceDiscoveryTmpDir = ""
+		serviceDiscoveryFsync = true
+		serviceDiscoveryKeepBackups = 0
+		serviceDiscoveryRefuseEmptyOutput = false
+		serviceDiscoverySinks = []sinks.Sink{}
+		serviceDiscoveryMetadata = false
+		serviceDiscoverySigningKey = nil
+		serviceDiscoveryTargetTTL = 0
+		ipFallbackTTL = 0
+		instanceAttributes = []string{}
+		processPorts = map[string]int{}
+		processAnnotations = nil
+		targetMode = ServiceDiscoveryTargetModeProcess
+		skippedInstancesLogInterval = 0
+		fakeClock = clock.NewFakeClock(time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC))
+		fs = filesystem.OS
+		portProber = nil
+		labelSanitizer = nil
+		eventRecorder = nil
 		azsFilter = filters.NewAZsFilter([]string{})
 		cidrsFilter, err = filters.NewCidrFilter([]string{"0.0.0.0/0"})
 		processesFilter, err = filters.NewRegexpFilter([]string{})
+		deploymentProcessesFilter, err = filters.NewDeploymentProcessesFilter("")
+		expressionFilter, err = filters.NewExpressionFilter("")
 
 		lastServiceDiscoveryScrapeTimestampMetric = prometheus.NewGauge(
 			prometheus.GaugeOpts{
@@ -78,6 +176,36 @@ var _ = Describe("ServiceDiscoveryCollector", func() {
 				},
 			},
 		)
+
+		instancesSkippedMetric = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "exporter",
+				Name:      "instances_skipped_total",
+				Help:      "Total number of BOSH instances left out of the Service Discovery targets, by reason.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"reason"},
+		)
+
+		jobProcessTargetInfoMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "job_process",
+				Name:      "target_info",
+				Help:      "BOSH Job Process Service Discovery target, to join scraped metrics with BOSH Job Process metrics.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_index", "bosh_job_az", "bosh_job_ip", "bosh_job_process_name", "bosh_job_template", "target"},
+		)
 	})
 
 	AfterEach(func() {
@@ -92,10 +220,33 @@ var _ = Describe("ServiceDiscoveryCollector", func() {
 			boshName,
 			boshUUID,
 			serviceDiscoveryFilename,
+			serviceDiscoveryTmpDir,
+			serviceDiscoveryFsync,
+			serviceDiscoveryKeepBackups,
+			serviceDiscoveryRefuseEmptyOutput,
+			serviceDiscoverySinks,
+			serviceDiscoveryMetadata,
+			serviceDiscoverySigningKey,
+			serviceDiscoveryTargetTTL,
+			ipFallbackTTL,
+			instanceAttributes,
+			processPorts,
+			processAnnotations,
+			targetMode,
+			skippedInstancesLogInterval,
+			eventRecorder,
+			labelSanitizer,
 			azsFilter,
 			processesFilter,
+			deploymentProcessesFilter,
+			expressionFilter,
 			cidrsFilter,
 		)
+		serviceDiscoveryCollector.SetClock(fakeClock)
+		serviceDiscoveryCollector.SetFS(fs)
+		if portProber != nil {
+			serviceDiscoveryCollector.SetPortProber(portProber)
+		}
 	})
 
 	Describe("Describe", func() {
@@ -118,6 +269,14 @@ var _ = Describe("ServiceDiscoveryCollector", func() {
 		It("returns a last_service_discovery_scrape_duration_seconds metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(lastServiceDiscoveryScrapeDurationSecondsMetric.Desc())))
 		})
+
+		It("returns an exporter_instances_skipped_total metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(instancesSkippedMetric.WithLabelValues("no_ip").Desc())))
+		})
+
+		It("returns a job_process_target_info metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(jobProcessTargetInfoMetric.WithLabelValues("", "", "", "", "", "", "", "", "").Desc())))
+		})
 	})
 
 	Describe("Collect", func() {
@@ -201,7 +360,7 @@ var _ = Describe("ServiceDiscoveryCollector", func() {
 
 		JustBeforeEach(func() {
 			go func() {
-				if err := serviceDiscoveryCollector.Collect(deploymentsInfo, metrics); err != nil {
+				if err := serviceDiscoveryCollector.Collect(fetcher.Snapshot{Deployments: deploymentsInfo}, metrics); err != nil {
 					errMetrics <- err
 				}
 			}()
@@ -214,13 +373,714 @@ var _ = Describe("ServiceDiscoveryCollector", func() {
 			Expect(string(targetGroups)).To(MatchUnorderedJSON(targetGroupsContent))
 		})
 
-		It("returns a last_service_discovery_scrape_timestamp & last_service_discovery_scrape_duration_seconds", func() {
-			Eventually(metrics).Should(Receive())
-			Eventually(metrics).Should(Receive())
+		It("returns a job_process_target_info metric for every target", func() {
+			jobProcessTargetInfoMetric.WithLabelValues(deployment1Name, job1Name, "", "", job1AZ, job1IP, jobProcess1Name, "", job1IP).Set(1)
+			Eventually(metrics).Should(Receive(PrometheusMetric(jobProcessTargetInfoMetric.WithLabelValues(deployment1Name, job1Name, "", "", job1AZ, job1IP, jobProcess1Name, "", job1IP))))
+		})
+
+		It("returns job_process_target_info, last_service_discovery_scrape_timestamp & last_service_discovery_scrape_duration_seconds", func() {
+			for i := 0; i < 5; i++ {
+				Eventually(metrics).Should(Receive())
+			}
 			Consistently(metrics).ShouldNot(Receive())
 			Consistently(errMetrics).ShouldNot(Receive())
 		})
 
+		Context("when the file system is injected", func() {
+			var (
+				memFS *filesystem.MemFS
+			)
+
+			BeforeEach(func() {
+				memFS = filesystem.NewMemFS()
+				fs = memFS
+			})
+
+			It("writes the target groups file through it", func() {
+				Eventually(metrics).Should(Receive())
+				files := memFS.Files()
+				Expect(files).To(HaveLen(1))
+				Expect(string(files[serviceDiscoveryFilename])).To(MatchUnorderedJSON(targetGroupsContent))
+				fileInfo, err := memFS.Stat(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fileInfo.Mode()).To(Equal(os.FileMode(0644)))
+			})
+
+			Context("and the temp directory is on another file system", func() {
+				BeforeEach(func() {
+					serviceDiscoveryTmpDir = "/other-filesystem"
+					memFS.FailNext("Rename", syscall.EXDEV)
+				})
+
+				It("writes the temp file next to the target groups file", func() {
+					Eventually(metrics).Should(Receive())
+					files := memFS.Files()
+					Expect(files).To(HaveLen(1))
+					Expect(string(files[serviceDiscoveryFilename])).To(MatchUnorderedJSON(targetGroupsContent))
+				})
+			})
+
+			Context("and the write fails", func() {
+				BeforeEach(func() {
+					memFS.FailNext("Write", errors.New("no space left on device"))
+				})
+
+				It("returns an error and leaves no temp file behind", func() {
+					Eventually(func() error {
+						select {
+						case <-metrics:
+							return nil
+						case err := <-errMetrics:
+							return err
+						}
+					}).Should(MatchError(ContainSubstring("no space left on device")))
+					Expect(memFS.Files()).To(BeEmpty())
+				})
+			})
+
+			Context("and a signing key is set", func() {
+				BeforeEach(func() {
+					serviceDiscoverySigningKey = []byte("fake-signing-key")
+				})
+
+				It("writes the target groups file and its signature", func() {
+					Eventually(metrics).Should(Receive())
+					files := memFS.Files()
+					Expect(files).To(HaveLen(2))
+					mac := hmac.New(sha256.New, serviceDiscoverySigningKey)
+					mac.Write(files[serviceDiscoveryFilename])
+					Expect(string(files[serviceDiscoveryFilename+".sig"])).To(Equal(hex.EncodeToString(mac.Sum(nil)) + "\n"))
+				})
+
+				Context("and the signature write fails", func() {
+					BeforeEach(func() {
+						memFS.WriteFile(serviceDiscoveryFilename, []byte("fake-previous-target-groups"), 0644)
+						memFS.FailNext("Write", nil)
+						memFS.FailNext("Write", errors.New("no space left on device"))
+					})
+
+					It("returns an error and leaves the target groups file unchanged", func() {
+						Eventually(func() error {
+							select {
+							case <-metrics:
+								return nil
+							case err := <-errMetrics:
+								return err
+							}
+						}).Should(MatchError(ContainSubstring("no space left on device")))
+						Expect(memFS.Files()).To(Equal(map[string][]byte{
+							serviceDiscoveryFilename: []byte("fake-previous-target-groups"),
+						}))
+					})
+				})
+			})
+		})
+
+		Context("when a temp directory is set and fsync is disabled", func() {
+			BeforeEach(func() {
+				serviceDiscoveryTmpDir, err = ioutil.TempDir("", "service_discovery_collector_test_tmp_")
+				Expect(err).ToNot(HaveOccurred())
+				serviceDiscoveryFsync = false
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(serviceDiscoveryTmpDir)
+			})
+
+			It("writes a target groups file", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(targetGroupsContent))
+			})
+
+			It("leaves no temp file behind", func() {
+				Eventually(metrics).Should(Receive())
+				tmpFiles, err := ioutil.ReadDir(serviceDiscoveryTmpDir)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(tmpFiles).To(BeEmpty())
+			})
+		})
+
+		Context("when a process has a job template", func() {
+			BeforeEach(func() {
+				deploymentsInfo[1].Instances[0].Processes[0].JobTemplate = "fake-job-template"
+			})
+
+			It("labels the target group with the job template", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-1-name"}},
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-2-name"}},
+					{"targets":["5.6.7.8"],"labels":{"__meta_bosh_deployment":"fake-deployment-2-name","__meta_bosh_job_process_name":"fake-process-2-name","__meta_bosh_job_template":"fake-job-template"}}
+				]`))
+			})
+		})
+
+		Context("when the target mode is instance", func() {
+			BeforeEach(func() {
+				targetMode = ServiceDiscoveryTargetModeInstance
+				deploymentsInfo[0].Instances[0].Processes[0].Ports = []int{8080}
+			})
+
+			It("writes a single target per instance listing its processes", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_processes":",fake-process-1-name,fake-process-2-name,"}},
+					{"targets":["5.6.7.8"],"labels":{"__meta_bosh_deployment":"fake-deployment-2-name","__meta_bosh_job_processes":",fake-process-2-name,"}}
+				]`))
+			})
+
+			It("returns a job_process_target_info metric for every process on the instance target", func() {
+				jobProcessTargetInfoMetric.WithLabelValues(deployment1Name, job1Name, "", "", job1AZ, job1IP, jobProcess2Name, "", job1IP).Set(1)
+				Eventually(metrics).Should(Receive(PrometheusMetric(jobProcessTargetInfoMetric.WithLabelValues(deployment1Name, job1Name, "", "", job1AZ, job1IP, jobProcess2Name, "", job1IP))))
+			})
+
+			Context("and a process is filtered out", func() {
+				BeforeEach(func() {
+					deploymentProcessesFilter, err = filters.NewDeploymentProcessesFilter(deployment1Name + ":" + jobProcess1Name)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("only lists the allowed processes", func() {
+					Eventually(metrics).Should(Receive())
+					targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+						{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_processes":",fake-process-1-name,"}}
+					]`))
+				})
+			})
+		})
+
+		Context("when a deployment processes filter is set", func() {
+			BeforeEach(func() {
+				deploymentProcessesFilter, err = filters.NewDeploymentProcessesFilter(deployment1Name + ":" + jobProcess1Name)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("only writes the allowed processes of each deployment", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-1-name"}}
+				]`))
+			})
+		})
+
+		Context("when a filter expression is set", func() {
+			BeforeEach(func() {
+				expressionFilter, err = filters.NewExpressionFilter(`deployment == "` + deployment2Name + `" || process not in (` + jobProcess2Name + `)`)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("only writes the processes matching the expression", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-1-name"}},
+					{"targets":["5.6.7.8"],"labels":{"__meta_bosh_deployment":"fake-deployment-2-name","__meta_bosh_job_process_name":"fake-process-2-name"}}
+				]`))
+			})
+		})
+
+		Context("when a process reports its listening ports", func() {
+			BeforeEach(func() {
+				deploymentsInfo[0].Instances[0].Processes[0].Ports = []int{9100, 9101}
+				processPorts = map[string]int{jobProcess1Name: 9200, jobProcess2Name: 9300}
+			})
+
+			It("uses the detected ports, then the configured ports, as targets", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+					{"targets":["1.2.3.4:9100","1.2.3.4:9101"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-1-name"}},
+					{"targets":["1.2.3.4:9300"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-2-name"}},
+					{"targets":["5.6.7.8:9300"],"labels":{"__meta_bosh_deployment":"fake-deployment-2-name","__meta_bosh_job_process_name":"fake-process-2-name"}}
+				]`))
+			})
+		})
+
+		Context("when ports are probed", func() {
+			var (
+				listener                 net.Listener
+				listeningPort            int
+				closedPort               int
+				unreachableTargetsMetric prometheus.Counter
+			)
+
+			BeforeEach(func() {
+				listener, err = net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).ToNot(HaveOccurred())
+				listeningPort = listener.Addr().(*net.TCPAddr).Port
+
+				closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).ToNot(HaveOccurred())
+				closedPort = closedListener.Addr().(*net.TCPAddr).Port
+				closedListener.Close()
+
+				deploymentsInfo[0].Instances[0].IPs = []string{"127.0.0.1"}
+				deploymentsInfo[0].Instances[0].Processes[0].Ports = []int{listeningPort, closedPort}
+				portProber = NewPortProber(time.Second, 2)
+
+				unreachableTargetsMetric = prometheus.NewCounter(
+					prometheus.CounterOpts{
+						Namespace: namespace,
+						Subsystem: "exporter",
+						Name:      "sd_unreachable_targets_total",
+						Help:      "Total number of Service Discovery targets left out because their port did not answer the probe.",
+						ConstLabels: prometheus.Labels{
+							"environment": environment,
+							"bosh_name":   boshName,
+							"bosh_uuid":   boshUUID,
+						},
+					},
+				)
+			})
+
+			AfterEach(func() {
+				listener.Close()
+			})
+
+			It("only writes the targets whose port answers", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(fmt.Sprintf(`[
+					{"targets":["127.0.0.1:%d"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-1-name"}},
+					{"targets":["127.0.0.1"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-2-name"}},
+					{"targets":["5.6.7.8"],"labels":{"__meta_bosh_deployment":"fake-deployment-2-name","__meta_bosh_job_process_name":"fake-process-2-name"}}
+				]`, listeningPort)))
+			})
+
+			It("returns an exporter_sd_unreachable_targets_total metric", func() {
+				unreachableTargetsMetric.Inc()
+				Eventually(metrics).Should(Receive(PrometheusMetric(unreachableTargetsMetric)))
+			})
+		})
+
+		Context("when instance attributes are configured", func() {
+			BeforeEach(func() {
+				instanceAttributes = []string{"vm_type"}
+				deploymentsInfo[0].Instances[0].Attributes = map[string]string{"vm_type": "small"}
+				deploymentsInfo[1].Instances[0].Attributes = map[string]string{"vm_type": "large"}
+			})
+
+			It("labels the target groups with the instance attributes", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-1-name","__meta_bosh_job_attribute_vm_type":"small"}},
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-2-name","__meta_bosh_job_attribute_vm_type":"small"}},
+					{"targets":["5.6.7.8"],"labels":{"__meta_bosh_deployment":"fake-deployment-2-name","__meta_bosh_job_process_name":"fake-process-2-name","__meta_bosh_job_attribute_vm_type":"large"}}
+				]`))
+			})
+		})
+
+		Context("when process annotations are configured", func() {
+			BeforeEach(func() {
+				processAnnotations, err = ParseProcessAnnotations([]byte(`
+annotations:
+- deployment: fake-deployment-1-name
+  process: fake-process-1-name
+  annotations:
+    team: routing
+    prometheus.io/scrape_interval: 15s
+- process: fake-process-.*
+  annotations:
+    team: platform
+`))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("labels the target groups with the annotations of the matching processes", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-1-name","__meta_bosh_annotation_team":"routing","__meta_bosh_annotation_prometheus_io_scrape_interval":"15s"}},
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-2-name","__meta_bosh_annotation_team":"platform"}},
+					{"targets":["5.6.7.8"],"labels":{"__meta_bosh_deployment":"fake-deployment-2-name","__meta_bosh_job_process_name":"fake-process-2-name","__meta_bosh_annotation_team":"platform"}}
+				]`))
+			})
+		})
+
+		Context("when a label sanitizer is set", func() {
+			BeforeEach(func() {
+				labelSanitizer, err = sanitizers.NewLabelSanitizer(sanitizers.Config{
+					Rules:  []sanitizers.Rule{{Regex: "-", Replacement: "_"}},
+					Labels: []string{"__meta_bosh_job_process_name"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("sanitizes the target groups labels", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake_process_1_name"}},
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake_process_2_name"}},
+					{"targets":["5.6.7.8"],"labels":{"__meta_bosh_deployment":"fake-deployment-2-name","__meta_bosh_job_process_name":"fake_process_2_name"}}
+				]`))
+			})
+		})
+
+		Context("when a target TTL is set", func() {
+			var (
+				elapsed time.Duration
+			)
+
+			BeforeEach(func() {
+				serviceDiscoveryTargetTTL = time.Hour
+				elapsed = time.Minute
+			})
+
+			JustBeforeEach(func() {
+				for i := 0; i < 5; i++ {
+					Eventually(metrics).Should(Receive())
+				}
+
+				fakeClock.Advance(elapsed)
+
+				go func() {
+					if err := serviceDiscoveryCollector.Collect(fetcher.Snapshot{Deployments: []deployments.DeploymentInfo{deployment1Info}}, metrics); err != nil {
+						errMetrics <- err
+					}
+				}()
+			})
+
+			It("keeps the disappeared targets labeled as stale", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-1-name"}},
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-2-name"}},
+					{"targets":["5.6.7.8"],"labels":{"__meta_bosh_deployment":"fake-deployment-2-name","__meta_bosh_job_process_name":"fake-process-2-name","__meta_bosh_stale":"true"}}
+				]`))
+			})
+
+			Context("and the TTL has expired", func() {
+				BeforeEach(func() {
+					elapsed = 2 * time.Hour
+				})
+
+				It("drops the disappeared targets", func() {
+					Eventually(metrics).Should(Receive())
+					targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+						{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-1-name"}},
+						{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-2-name"}}
+					]`))
+				})
+			})
+		})
+
+		Context("when backups are kept", func() {
+			BeforeEach(func() {
+				serviceDiscoveryKeepBackups = 2
+			})
+
+			JustBeforeEach(func() {
+				for i := 0; i < 5; i++ {
+					Eventually(metrics).Should(Receive())
+				}
+
+				go func() {
+					if err := serviceDiscoveryCollector.Collect(fetcher.Snapshot{Deployments: []deployments.DeploymentInfo{deployment1Info}}, metrics); err != nil {
+						errMetrics <- err
+					}
+				}()
+			})
+
+			AfterEach(func() {
+				os.Remove(serviceDiscoveryFilename + ".1")
+				os.Remove(serviceDiscoveryFilename + ".2")
+			})
+
+			It("keeps the previous versions of the target groups file", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(`[
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-1-name"}},
+					{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-2-name"}}
+				]`))
+
+				firstBackup, err := ioutil.ReadFile(serviceDiscoveryFilename + ".1")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(firstBackup)).To(MatchUnorderedJSON(targetGroupsContent))
+
+				secondBackup, err := ioutil.ReadFile(serviceDiscoveryFilename + ".2")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(secondBackup)).To(BeEmpty())
+			})
+		})
+
+		Context("when empty outputs are refused", func() {
+			var (
+				visibleDeployments        []string
+				emptyOutputsRefusedMetric prometheus.Counter
+			)
+
+			BeforeEach(func() {
+				serviceDiscoveryRefuseEmptyOutput = true
+				visibleDeployments = []string{deployment1Name, deployment2Name}
+
+				emptyOutputsRefusedMetric = prometheus.NewCounter(
+					prometheus.CounterOpts{
+						Namespace: namespace,
+						Subsystem: "exporter",
+						Name:      "sd_empty_outputs_refused_total",
+						Help:      "Total number of empty Service Discovery outputs not written over non-empty ones.",
+						ConstLabels: prometheus.Labels{
+							"environment": environment,
+							"bosh_name":   boshName,
+							"bosh_uuid":   boshUUID,
+						},
+					},
+				)
+			})
+
+			JustBeforeEach(func() {
+				for i := 0; i < 6; i++ {
+					Eventually(metrics).Should(Receive())
+				}
+
+				go func() {
+					if err := serviceDiscoveryCollector.Collect(fetcher.Snapshot{Deployments: []deployments.DeploymentInfo{}, VisibleDeployments: visibleDeployments}, metrics); err != nil {
+						errMetrics <- err
+					}
+				}()
+			})
+
+			It("keeps the previous target groups file", func() {
+				emptyOutputsRefusedMetric.Inc()
+				Eventually(metrics).Should(Receive(PrometheusMetric(emptyOutputsRefusedMetric)))
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(targetGroupsContent))
+			})
+
+			Context("and BOSH reported zero deployments", func() {
+				BeforeEach(func() {
+					visibleDeployments = []string{}
+				})
+
+				It("writes an empty target groups file", func() {
+					Eventually(metrics).Should(Receive(PrometheusMetric(emptyOutputsRefusedMetric)))
+					targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(targetGroups)).To(MatchJSON("[]"))
+				})
+			})
+		})
+
+		Context("when an IP fallback TTL is set", func() {
+			var (
+				ipFallbacksMetric prometheus.Counter
+			)
+
+			BeforeEach(func() {
+				ipFallbackTTL = time.Hour
+
+				deployment1Instances[0].ID = "fake-job-1-id"
+				deployment1Info.Instances = deployment1Instances
+				deploymentsInfo = []deployments.DeploymentInfo{deployment1Info, deployment2Info}
+
+				ipFallbacksMetric = prometheus.NewCounter(
+					prometheus.CounterOpts{
+						Namespace: namespace,
+						Subsystem: "exporter",
+						Name:      "sd_ip_fallbacks_total",
+						Help:      "Total number of times the last known IPs of a BOSH instance were used because BOSH reported none.",
+						ConstLabels: prometheus.Labels{
+							"environment": environment,
+							"bosh_name":   boshName,
+							"bosh_uuid":   boshUUID,
+						},
+					},
+				)
+			})
+
+			JustBeforeEach(func() {
+				for i := 0; i < 6; i++ {
+					Eventually(metrics).Should(Receive())
+				}
+
+				flappingInstances := []deployments.Instance{deployment1Instances[0]}
+				flappingInstances[0].IPs = []string{}
+				flappingDeployment1Info := deployments.DeploymentInfo{Name: deployment1Name, Instances: flappingInstances}
+
+				go func() {
+					if err := serviceDiscoveryCollector.Collect(fetcher.Snapshot{Deployments: []deployments.DeploymentInfo{flappingDeployment1Info, deployment2Info}}, metrics); err != nil {
+						errMetrics <- err
+					}
+				}()
+			})
+
+			It("keeps the targets on the last known IPs", func() {
+				ipFallbacksMetric.Inc()
+				Eventually(metrics).Should(Receive(PrometheusMetric(ipFallbacksMetric)))
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(MatchUnorderedJSON(targetGroupsContent))
+			})
+		})
+
+		Context("when a signing key is set", func() {
+			BeforeEach(func() {
+				serviceDiscoverySigningKey = []byte("fake-signing-key")
+			})
+
+			AfterEach(func() {
+				os.Remove(serviceDiscoveryFilename + ".sig")
+			})
+
+			It("writes a detached signature file", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				signature, err := ioutil.ReadFile(serviceDiscoveryFilename + ".sig")
+				Expect(err).ToNot(HaveOccurred())
+
+				mac := hmac.New(sha256.New, serviceDiscoverySigningKey)
+				mac.Write(targetGroups)
+				Expect(string(signature)).To(Equal(hex.EncodeToString(mac.Sum(nil)) + "\n"))
+			})
+		})
+
+		Context("when metadata is enabled", func() {
+			var targetGroupsWithMetadata TargetGroupsWithMetadata
+
+			BeforeEach(func() {
+				serviceDiscoveryMetadata = true
+				serviceDiscoverySigningKey = []byte("fake-signing-key")
+			})
+
+			JustBeforeEach(func() {
+				Eventually(metrics).Should(Receive())
+				content, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(json.Unmarshal(content, &targetGroupsWithMetadata)).To(Succeed())
+			})
+
+			It("wraps the target groups", func() {
+				Expect(string(targetGroupsWithMetadata.TargetGroups)).To(MatchUnorderedJSON(targetGroupsContent))
+			})
+
+			It("includes the generation metadata", func() {
+				checksum := sha256.Sum256(targetGroupsWithMetadata.TargetGroups)
+				mac := hmac.New(sha256.New, serviceDiscoverySigningKey)
+				mac.Write(targetGroupsWithMetadata.TargetGroups)
+
+				Expect(targetGroupsWithMetadata.Metadata.GeneratedAt).To(Equal(fakeClock.Now()))
+				Expect(targetGroupsWithMetadata.Metadata.BoshName).To(Equal(boshName))
+				Expect(targetGroupsWithMetadata.Metadata.BoshUUID).To(Equal(boshUUID))
+				Expect(targetGroupsWithMetadata.Metadata.SHA256).To(Equal(hex.EncodeToString(checksum[:])))
+				Expect(targetGroupsWithMetadata.Metadata.HMACSHA256).To(Equal(hex.EncodeToString(mac.Sum(nil))))
+			})
+
+			It("does not write a detached signature file", func() {
+				_, err := os.Stat(serviceDiscoveryFilename + ".sig")
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
+		Context("when there are sinks", func() {
+			var sink *fakeSink
+
+			BeforeEach(func() {
+				sink = &fakeSink{}
+				serviceDiscoverySinks = []sinks.Sink{sink}
+			})
+
+			It("writes the target groups to the sinks", func() {
+				Eventually(metrics).Should(Receive())
+				Expect(string(sink.content)).To(MatchUnorderedJSON(targetGroupsContent))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+
+			Context("and a signing key is set", func() {
+				var signedSink *fakeSignedSink
+
+				BeforeEach(func() {
+					serviceDiscoverySigningKey = []byte("fake-signing-key")
+					signedSink = &fakeSignedSink{}
+					serviceDiscoverySinks = []sinks.Sink{sink, signedSink}
+				})
+
+				AfterEach(func() {
+					os.Remove(serviceDiscoveryFilename + ".sig")
+				})
+
+				It("passes the signature to the sinks supporting it", func() {
+					Eventually(metrics).Should(Receive())
+					mac := hmac.New(sha256.New, serviceDiscoverySigningKey)
+					mac.Write(signedSink.content)
+					Expect(signedSink.signature).To(Equal(hex.EncodeToString(mac.Sum(nil))))
+					Expect(string(sink.content)).To(MatchUnorderedJSON(targetGroupsContent))
+				})
+			})
+
+			Context("and a sink fails", func() {
+				BeforeEach(func() {
+					sink.err = errors.New("sink error")
+				})
+
+				It("returns an error", func() {
+					for i := 0; i < 5; i++ {
+						Eventually(metrics).Should(Receive())
+					}
+					Eventually(errMetrics).Should(Receive())
+				})
+
+				It("still writes the target groups file", func() {
+					Eventually(metrics).Should(Receive())
+					targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(targetGroups)).To(MatchUnorderedJSON(targetGroupsContent))
+				})
+
+				Context("and an event recorder is set", func() {
+					var recorder *fakeEventRecorder
+
+					BeforeEach(func() {
+						recorder = &fakeEventRecorder{}
+						eventRecorder = recorder
+					})
+
+					It("records the write failure", func() {
+						Eventually(recorder.Reasons).Should(Equal([]string{"test_environment/service_discovery Warning ServiceDiscoveryWriteFailed"}))
+					})
+				})
+			})
+
+			Context("and an event recorder is set", func() {
+				var recorder *fakeEventRecorder
+
+				BeforeEach(func() {
+					recorder = &fakeEventRecorder{}
+					eventRecorder = recorder
+				})
+
+				It("records the successful write", func() {
+					Eventually(metrics).Should(Receive())
+					Eventually(recorder.Reasons).Should(Equal([]string{"test_environment/service_discovery Normal ServiceDiscoveryWritten"}))
+				})
+			})
+		})
+
 		Context("when there are no deployments", func() {
 			BeforeEach(func() {
 				deploymentsInfo = []deployments.DeploymentInfo{}
@@ -275,7 +1135,13 @@ var _ = Describe("ServiceDiscoveryCollector", func() {
 				Expect(string(targetGroups)).To(Equal("[]"))
 			})
 
-			It("returns only last_service_discovery_scrape_timestamp & last_service_discovery_scrape_duration_seconds", func() {
+			It("returns an exporter_instances_skipped_total metric", func() {
+				instancesSkippedMetric.WithLabelValues("no_ip").Inc()
+				Eventually(metrics).Should(Receive(PrometheusMetric(instancesSkippedMetric.WithLabelValues("no_ip"))))
+			})
+
+			It("returns last_service_discovery_scrape_timestamp, last_service_discovery_scrape_duration_seconds & exporter_instances_skipped_total", func() {
+				Eventually(metrics).Should(Receive())
 				Eventually(metrics).Should(Receive())
 				Eventually(metrics).Should(Receive())
 				Consistently(metrics).ShouldNot(Receive())
@@ -295,7 +1161,13 @@ var _ = Describe("ServiceDiscoveryCollector", func() {
 				Expect(string(targetGroups)).To(Equal("[]"))
 			})
 
-			It("returns only last_service_discovery_scrape_timestamp & last_service_discovery_scrape_duration_seconds", func() {
+			It("returns an exporter_instances_skipped_total metric", func() {
+				instancesSkippedMetric.WithLabelValues("cidr_mismatch").Add(2)
+				Eventually(metrics).Should(Receive(PrometheusMetric(instancesSkippedMetric.WithLabelValues("cidr_mismatch"))))
+			})
+
+			It("returns last_service_discovery_scrape_timestamp, last_service_discovery_scrape_duration_seconds & exporter_instances_skipped_total", func() {
+				Eventually(metrics).Should(Receive())
 				Eventually(metrics).Should(Receive())
 				Eventually(metrics).Should(Receive())
 				Consistently(metrics).ShouldNot(Receive())
@@ -316,12 +1188,145 @@ var _ = Describe("ServiceDiscoveryCollector", func() {
 				Expect(string(targetGroups)).To(Equal("[]"))
 			})
 
-			It("returns only last_service_discovery_scrape_timestamp & last_service_discovery_scrape_duration_seconds", func() {
+			It("returns an exporter_instances_skipped_total metric", func() {
+				instancesSkippedMetric.WithLabelValues("no_processes").Inc()
+				Eventually(metrics).Should(Receive(PrometheusMetric(instancesSkippedMetric.WithLabelValues("no_processes"))))
+			})
+
+			It("returns last_service_discovery_scrape_timestamp, last_service_discovery_scrape_duration_seconds & exporter_instances_skipped_total", func() {
+				Eventually(metrics).Should(Receive())
 				Eventually(metrics).Should(Receive())
 				Eventually(metrics).Should(Receive())
 				Consistently(metrics).ShouldNot(Receive())
 				Consistently(errMetrics).ShouldNot(Receive())
 			})
 		})
+
+		Context("when the AZ of an instance is filtered out", func() {
+			BeforeEach(func() {
+				azsFilter = filters.NewAZsFilter([]string{job2AZ})
+				deploymentsInfo = []deployments.DeploymentInfo{deployment1Info}
+			})
+
+			It("writes an empty target groups file", func() {
+				Eventually(metrics).Should(Receive())
+				targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(targetGroups)).To(Equal("[]"))
+			})
+
+			It("returns an exporter_instances_skipped_total metric", func() {
+				instancesSkippedMetric.WithLabelValues("az_filter").Inc()
+				Eventually(metrics).Should(Receive(PrometheusMetric(instancesSkippedMetric.WithLabelValues("az_filter"))))
+			})
+		})
+
+		Context("when all the processes of an instance are filtered out", func() {
+			BeforeEach(func() {
+				processesFilter, err = filters.NewRegexpFilter([]string{jobProcess1Name})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns an exporter_instances_skipped_total metric", func() {
+				instancesSkippedMetric.WithLabelValues("processes_filtered").Inc()
+				Eventually(metrics).Should(Receive(PrometheusMetric(instancesSkippedMetric.WithLabelValues("processes_filtered"))))
+			})
+		})
+	})
+
+	Describe("PreviewTargetGroups", func() {
+		var (
+			currentContent = `[{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"fake-deployment-1-name","__meta_bosh_job_process_name":"fake-process-1-name"}}]`
+			snapshot       fetcher.Snapshot
+		)
+
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(serviceDiscoveryFilename, []byte(currentContent), 0644)).To(Succeed())
+			snapshot = fetcher.Snapshot{
+				Deployments: []deployments.DeploymentInfo{
+					{
+						Name: "fake-deployment-1-name",
+						Instances: []deployments.Instance{
+							{
+								Name:      "fake-job-1-name",
+								IPs:       []string{"1.2.3.4"},
+								Processes: []deployments.Process{{Name: "fake-process-1-name"}, {Name: "fake-process-2-name"}},
+							},
+						},
+					},
+				},
+			}
+		})
+
+		It("returns the current and next target groups", func() {
+			current, next, err := serviceDiscoveryCollector.PreviewTargetGroups(snapshot, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(current).To(HaveLen(1))
+			Expect(next).To(HaveLen(2))
+		})
+
+		It("does not write the target groups file", func() {
+			_, _, err := serviceDiscoveryCollector.PreviewTargetGroups(snapshot, nil)
+			Expect(err).ToNot(HaveOccurred())
+			targetGroups, err := ioutil.ReadFile(serviceDiscoveryFilename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(targetGroups)).To(Equal(currentContent))
+		})
+
+		Context("when ports are probed", func() {
+			It("does not probe the target ports", func() {
+				closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).ToNot(HaveOccurred())
+				closedPort := closedListener.Addr().(*net.TCPAddr).Port
+				closedListener.Close()
+
+				snapshot.Deployments[0].Instances[0].IPs = []string{"127.0.0.1"}
+				snapshot.Deployments[0].Instances[0].Processes[0].Ports = []int{closedPort}
+				serviceDiscoveryCollector.SetPortProber(NewPortProber(time.Second, 1))
+
+				_, next, err := serviceDiscoveryCollector.PreviewTargetGroups(snapshot, nil)
+				Expect(err).ToNot(HaveOccurred())
+				targets := []string{}
+				for _, targetGroup := range next {
+					targets = append(targets, targetGroup.Targets...)
+				}
+				Expect(targets).To(ContainElement(fmt.Sprintf("127.0.0.1:%d", closedPort)))
+			})
+		})
+
+		Context("when a filter expression is given", func() {
+			It("uses it instead of the configured one", func() {
+				previewFilter, err := filters.NewExpressionFilter(`process == "fake-process-2-name"`)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, next, err := serviceDiscoveryCollector.PreviewTargetGroups(snapshot, previewFilter)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(next).To(HaveLen(1))
+				Expect(string(next[0].Labels["__meta_bosh_job_process_name"])).To(Equal("fake-process-2-name"))
+			})
+		})
+
+		Context("when the target groups file is wrapped with metadata", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(serviceDiscoveryFilename, []byte(`{"metadata":{},"target_groups":`+currentContent+`}`), 0644)).To(Succeed())
+			})
+
+			It("reads the wrapped target groups", func() {
+				current, _, err := serviceDiscoveryCollector.PreviewTargetGroups(snapshot, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(current).To(HaveLen(1))
+			})
+		})
+
+		Context("when the target groups file is invalid", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(serviceDiscoveryFilename, []byte(`not json`), 0644)).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				_, _, err := serviceDiscoveryCollector.PreviewTargetGroups(snapshot, nil)
+				Expect(err).To(HaveOccurred())
+			})
+		})
 	})
 })
@@ -0,0 +1,72 @@
+package collectors
+
+import (
+	"sort"
+
+	"github.com/prometheus/common/model"
+)
+
+// ServiceDiscoveryTarget is a single target of the Service Discovery output
+// with the labels of its target group.
+type ServiceDiscoveryTarget struct {
+	Target string
+	Labels model.LabelSet
+}
+
+// ServiceDiscoveryDiff lists the targets added to and removed from the
+// Service Discovery output, a target whose labels changed being both removed
+// and added.
+type ServiceDiscoveryDiff struct {
+	Added     []ServiceDiscoveryTarget
+	Removed   []ServiceDiscoveryTarget
+	Unchanged int
+}
+
+// DiffTargetGroups compares the targets of two Service Discovery outputs,
+// regardless of the order and grouping of the target groups.
+func DiffTargetGroups(current TargetGroups, next TargetGroups) ServiceDiscoveryDiff {
+	currentTargets := indexTargets(current)
+	nextTargets := indexTargets(next)
+
+	diff := ServiceDiscoveryDiff{
+		Added:   []ServiceDiscoveryTarget{},
+		Removed: []ServiceDiscoveryTarget{},
+	}
+	for key, target := range nextTargets {
+		if _, ok := currentTargets[key]; ok {
+			diff.Unchanged++
+			continue
+		}
+		diff.Added = append(diff.Added, target)
+	}
+	for key, target := range currentTargets {
+		if _, ok := nextTargets[key]; !ok {
+			diff.Removed = append(diff.Removed, target)
+		}
+	}
+
+	sortTargets(diff.Added)
+	sortTargets(diff.Removed)
+
+	return diff
+}
+
+func indexTargets(targetGroups TargetGroups) map[string]ServiceDiscoveryTarget {
+	targets := map[string]ServiceDiscoveryTarget{}
+	for _, targetGroup := range targetGroups {
+		for _, target := range targetGroup.Targets {
+			targets[target+targetGroup.Labels.String()] = ServiceDiscoveryTarget{Target: target, Labels: targetGroup.Labels}
+		}
+	}
+
+	return targets
+}
+
+func sortTargets(targets []ServiceDiscoveryTarget) {
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Target != targets[j].Target {
+			return targets[i].Target < targets[j].Target
+		}
+		return targets[i].Labels.String() < targets[j].Labels.String()
+	})
+}
@@ -0,0 +1,62 @@
+package collectors_test
+
+import (
+	"github.com/prometheus/common/model"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+)
+
+var _ = Describe("DiffTargetGroups", func() {
+	var (
+		process1Labels = model.LabelSet{"__meta_bosh_deployment": "fake-deployment", "__meta_bosh_job_process_name": "fake-process-1"}
+		process2Labels = model.LabelSet{"__meta_bosh_deployment": "fake-deployment", "__meta_bosh_job_process_name": "fake-process-2"}
+		current        TargetGroups
+	)
+
+	BeforeEach(func() {
+		current = TargetGroups{
+			{Targets: []string{"1.2.3.4", "5.6.7.8"}, Labels: process1Labels},
+			{Targets: []string{"1.2.3.4"}, Labels: process2Labels},
+		}
+	})
+
+	It("returns no changes for the same targets grouped differently", func() {
+		next := TargetGroups{
+			{Targets: []string{"1.2.3.4"}, Labels: process2Labels},
+			{Targets: []string{"5.6.7.8"}, Labels: process1Labels},
+			{Targets: []string{"1.2.3.4"}, Labels: process1Labels},
+		}
+		Expect(DiffTargetGroups(current, next)).To(Equal(ServiceDiscoveryDiff{
+			Added:     []ServiceDiscoveryTarget{},
+			Removed:   []ServiceDiscoveryTarget{},
+			Unchanged: 3,
+		}))
+	})
+
+	It("returns the added and removed targets", func() {
+		next := TargetGroups{
+			{Targets: []string{"1.2.3.4", "9.9.9.9"}, Labels: process1Labels},
+			{Targets: []string{"1.2.3.4"}, Labels: process2Labels},
+		}
+		Expect(DiffTargetGroups(current, next)).To(Equal(ServiceDiscoveryDiff{
+			Added:     []ServiceDiscoveryTarget{{Target: "9.9.9.9", Labels: process1Labels}},
+			Removed:   []ServiceDiscoveryTarget{{Target: "5.6.7.8", Labels: process1Labels}},
+			Unchanged: 2,
+		}))
+	})
+
+	It("returns a target whose labels changed as removed and added", func() {
+		staleLabels := process2Labels.Clone()
+		staleLabels["__meta_bosh_stale"] = "true"
+		next := TargetGroups{
+			{Targets: []string{"1.2.3.4", "5.6.7.8"}, Labels: process1Labels},
+			{Targets: []string{"1.2.3.4"}, Labels: staleLabels},
+		}
+		diff := DiffTargetGroups(current, next)
+		Expect(diff.Added).To(Equal([]ServiceDiscoveryTarget{{Target: "1.2.3.4", Labels: staleLabels}}))
+		Expect(diff.Removed).To(Equal([]ServiceDiscoveryTarget{{Target: "1.2.3.4", Labels: process2Labels}}))
+	})
+})
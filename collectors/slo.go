@@ -0,0 +1,79 @@
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SLOObjectives struct {
+	Default     float64
+	Deployments map[string]float64
+}
+
+type sloSample struct {
+	at               time.Time
+	runningProcesses int
+	totalProcesses   int
+}
+
+func ParseSLOObjectives(defaultObjective float64, deploymentObjectives []string) (SLOObjectives, error) {
+	objectives := SLOObjectives{Default: defaultObjective, Deployments: map[string]float64{}}
+	if err := validateSLOObjective(defaultObjective, true); err != nil {
+		return SLOObjectives{}, err
+	}
+
+	for _, deploymentObjective := range deploymentObjectives {
+		parts := strings.SplitN(deploymentObjective, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return SLOObjectives{}, errors.New(fmt.Sprintf("SLO objective `%s` must be formatted as <deployment>=<ratio>", deploymentObjective))
+		}
+
+		objective, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return SLOObjectives{}, errors.New(fmt.Sprintf("Error parsing SLO objective `%s`: %v", deploymentObjective, err))
+		}
+		if err := validateSLOObjective(objective, false); err != nil {
+			return SLOObjectives{}, err
+		}
+		objectives.Deployments[strings.TrimSpace(parts[0])] = objective
+	}
+
+	return objectives, nil
+}
+
+func validateSLOObjective(objective float64, allowDisabled bool) error {
+	if allowDisabled && objective == 0 {
+		return nil
+	}
+	if objective <= 0 || objective >= 1 {
+		return errors.New(fmt.Sprintf("SLO objective `%v` must be greater than 0 and lower than 1", objective))
+	}
+
+	return nil
+}
+
+func (o SLOObjectives) objective(deploymentName string) float64 {
+	if objective, ok := o.Deployments[deploymentName]; ok {
+		return objective
+	}
+
+	return o.Default
+}
+
+func sloErrorBudgetRemaining(samples []sloSample, objective float64) (float64, bool) {
+	runningProcesses, totalProcesses := 0, 0
+	for _, sample := range samples {
+		runningProcesses += sample.runningProcesses
+		totalProcesses += sample.totalProcesses
+	}
+	if totalProcesses == 0 {
+		return 0, false
+	}
+
+	errorRatio := 1 - float64(runningProcesses)/float64(totalProcesses)
+
+	return 1 - errorRatio/(1-objective), true
+}
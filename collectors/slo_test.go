@@ -0,0 +1,38 @@
+package collectors_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+)
+
+var _ = Describe("ParseSLOObjectives", func() {
+	It("parses the default and per deployment objectives", func() {
+		objectives, err := ParseSLOObjectives(0.99, []string{"cf=0.999", " redis = 0.95"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(objectives).To(Equal(SLOObjectives{
+			Default:     0.99,
+			Deployments: map[string]float64{"cf": 0.999, "redis": 0.95},
+		}))
+	})
+
+	It("allows disabling the default objective", func() {
+		objectives, err := ParseSLOObjectives(0, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(objectives.Default).To(Equal(float64(0)))
+	})
+
+	It("returns an error when the format is invalid", func() {
+		_, err := ParseSLOObjectives(0, []string{"cf"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error when an objective is out of range", func() {
+		_, err := ParseSLOObjectives(0, []string{"cf=1"})
+		Expect(err).To(HaveOccurred())
+
+		_, err = ParseSLOObjectives(1.5, nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,47 @@
+package collectors
+
+import (
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+const (
+	StoppedDeploymentsInclude = "include"
+	StoppedDeploymentsExclude = "exclude"
+	StoppedDeploymentsLabel   = "label"
+)
+
+// excludeStoppedDeployments drops the deployments whose instances were all
+// stopped on purpose, so they do not fire process alerts.
+func excludeStoppedDeployments(snapshot fetcher.Snapshot) fetcher.Snapshot {
+	runningDeployments := make([]deployments.DeploymentInfo, 0, len(snapshot.Deployments))
+	for _, deployment := range snapshot.Deployments {
+		if deployment.IntentionallyStopped() {
+			continue
+		}
+		runningDeployments = append(runningDeployments, deployment)
+	}
+	snapshot.Deployments = runningDeployments
+
+	return snapshot
+}
+
+// excludeStoppedDeploymentsStream is excludeStoppedDeployments for streamed
+// deployments.
+func excludeStoppedDeploymentsStream(eachDeployment fetcher.DeploymentsIterator) fetcher.DeploymentsIterator {
+	return func(fn func(deployments.DeploymentInfo) error) error {
+		return eachDeployment(func(deployment deployments.DeploymentInfo) error {
+			if deployment.IntentionallyStopped() {
+				return nil
+			}
+			return fn(deployment)
+		})
+	}
+}
+
+func intentionallyStoppedLabelValue(deployment deployments.DeploymentInfo) string {
+	if deployment.IntentionallyStopped() {
+		return "1"
+	}
+	return "0"
+}
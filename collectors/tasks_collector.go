@@ -0,0 +1,294 @@
+package collectors
+
+import (
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+const (
+	CPITaskErrorClass         = "cpi"
+	CompilationTaskErrorClass = "compilation"
+	CanaryTaskErrorClass      = "canary"
+	UpdateTaskErrorClass      = "update"
+	TimeoutTaskErrorClass     = "timeout"
+	CancelledTaskErrorClass   = "cancelled"
+	OtherTaskErrorClass       = "other"
+)
+
+var (
+	activeTaskStates = []string{"queued", "processing", "cancelling"}
+	taskAgeBuckets   = []float64{10, 30, 60, 300, 900, 1800, 3600, 7200, 14400, 43200, 86400}
+)
+
+type taskErrorClassifier struct {
+	errorClass string
+	regexp     *regexp.Regexp
+}
+
+var taskErrorClassifiers = []taskErrorClassifier{
+	{errorClass: CPITaskErrorClass, regexp: regexp.MustCompile(`(?i)CPI error|CPI '[^']*' method|CloudError`)},
+	{errorClass: CompilationTaskErrorClass, regexp: regexp.MustCompile(`(?i)compil`)},
+	{errorClass: CanaryTaskErrorClass, regexp: regexp.MustCompile(`(?i)canar`)},
+	{errorClass: UpdateTaskErrorClass, regexp: regexp.MustCompile(`(?i)is not running after update`)},
+}
+
+func ClassifyTaskError(task deployments.Task) string {
+	for _, classifier := range taskErrorClassifiers {
+		if classifier.regexp.MatchString(task.Result) {
+			return classifier.errorClass
+		}
+	}
+
+	switch task.State {
+	case "timeout":
+		return TimeoutTaskErrorClass
+	case "cancelled":
+		return CancelledTaskErrorClass
+	}
+
+	return OtherTaskErrorClass
+}
+
+type TasksCollector struct {
+	failedTasksWindow                    time.Duration
+	clock                                clock.Clock
+	startedAt                            time.Time
+	seenFailedTasks                      map[int]time.Time
+	directorFailedTasksTotalMetric       *prometheus.CounterVec
+	directorOldestQueuedTaskAgeMetric    prometheus.Gauge
+	directorTaskAgeDesc                  *prometheus.Desc
+	lastTasksScrapeTimestampMetric       prometheus.Gauge
+	lastTasksScrapeDurationSecondsMetric prometheus.Gauge
+	mu                                   *sync.Mutex
+}
+
+func NewTasksCollector(
+	namespace string,
+	environment string,
+	boshName string,
+	boshUUID string,
+	failedTasksWindow time.Duration,
+) *TasksCollector {
+	directorFailedTasksTotalMetric := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "director",
+			Name:      "failed_tasks_total",
+			Help:      "Total number of BOSH Director tasks failed since the exporter started, by error class.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"bosh_deployment", "error_class"},
+	)
+
+	directorOldestQueuedTaskAgeMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "director",
+			Name:      "oldest_queued_task_age_seconds",
+			Help:      "Age in seconds of the oldest queued BOSH Director task (0 when no task is queued).",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
+	directorTaskAgeDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "director", "task_age_seconds"),
+		"Age in seconds of the unfinished BOSH Director tasks by state.",
+		[]string{"state"},
+		prometheus.Labels{
+			"environment": environment,
+			"bosh_name":   boshName,
+			"bosh_uuid":   boshUUID,
+		},
+	)
+
+	lastTasksScrapeTimestampMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "",
+			Name:      "last_tasks_scrape_timestamp",
+			Help:      "Number of seconds since 1970 since last scrape of Tasks metrics from BOSH.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
+	lastTasksScrapeDurationSecondsMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "",
+			Name:      "last_tasks_scrape_duration_seconds",
+			Help:      "Duration of the last scrape of Tasks metrics from BOSH.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+	)
+
+	collector := &TasksCollector{
+		failedTasksWindow:                    failedTasksWindow,
+		clock:                                clock.Real,
+		startedAt:                            time.Now(),
+		seenFailedTasks:                      map[int]time.Time{},
+		directorFailedTasksTotalMetric:       directorFailedTasksTotalMetric,
+		directorOldestQueuedTaskAgeMetric:    directorOldestQueuedTaskAgeMetric,
+		directorTaskAgeDesc:                  directorTaskAgeDesc,
+		lastTasksScrapeTimestampMetric:       lastTasksScrapeTimestampMetric,
+		lastTasksScrapeDurationSecondsMetric: lastTasksScrapeDurationSecondsMetric,
+		mu:                                   &sync.Mutex{},
+	}
+	return collector
+}
+
+// SetClock replaces the clock timing the failed tasks window, the failed tasks
+// being counted from the current time of clk. It must be called before the
+// first collection.
+func (c *TasksCollector) SetClock(clk clock.Clock) {
+	c.clock = clk
+	c.startedAt = clk.Now()
+}
+
+func (c *TasksCollector) Collect(snapshot fetcher.Snapshot, ch chan<- prometheus.Metric) error {
+	return c.CollectStream(snapshot, snapshot.EachDeployment, ch)
+}
+
+func (c *TasksCollector) CollectStream(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
+	var begun = time.Now()
+
+	c.mu.Lock()
+	windowStart := c.clock.Now().Add(-c.failedTasksWindow)
+	streamErr := eachDeployment(func(deployment deployments.DeploymentInfo) error {
+		c.reportDirectorFailedTasksMetrics(deployment, windowStart)
+		return nil
+	})
+	for id, finishedAt := range c.seenFailedTasks {
+		if finishedAt.Before(windowStart) {
+			delete(c.seenFailedTasks, id)
+		}
+	}
+	c.mu.Unlock()
+
+	c.directorFailedTasksTotalMetric.Collect(ch)
+
+	now := snapshot.FetchedAt
+	if now.IsZero() {
+		now = begun
+	}
+	c.reportDirectorTaskAgeMetrics(snapshot.Tasks, now, ch)
+
+	c.lastTasksScrapeTimestampMetric.Set(float64(time.Now().Unix()))
+	c.lastTasksScrapeTimestampMetric.Collect(ch)
+
+	c.lastTasksScrapeDurationSecondsMetric.Set(time.Since(begun).Seconds())
+	c.lastTasksScrapeDurationSecondsMetric.Collect(ch)
+
+	return streamErr
+}
+
+func (c *TasksCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.directorFailedTasksTotalMetric.Describe(ch)
+	c.directorOldestQueuedTaskAgeMetric.Describe(ch)
+	ch <- c.directorTaskAgeDesc
+	c.lastTasksScrapeTimestampMetric.Describe(ch)
+	c.lastTasksScrapeDurationSecondsMetric.Describe(ch)
+}
+
+// reportDirectorFailedTasksMetrics counts the failed tasks once, when first
+// seen. The tasks that finished before the exporter started are not counted,
+// so a restart does not count the same failures again, nor the tasks seen
+// after the window, which are no longer remembered.
+func (c *TasksCollector) reportDirectorFailedTasksMetrics(
+	deployment deployments.DeploymentInfo,
+	windowStart time.Time,
+) {
+	for _, task := range deployment.Tasks {
+		if !c.taskFailed(task) || task.FinishedAt.Before(windowStart) || task.FinishedAt.Before(c.startedAt) {
+			continue
+		}
+
+		if _, ok := c.seenFailedTasks[task.ID]; ok {
+			continue
+		}
+		c.seenFailedTasks[task.ID] = task.FinishedAt
+
+		c.directorFailedTasksTotalMetric.WithLabelValues(
+			deployment.Name,
+			ClassifyTaskError(task),
+		).Inc()
+	}
+}
+
+func (c *TasksCollector) reportDirectorTaskAgeMetrics(
+	tasks []deployments.Task,
+	now time.Time,
+	ch chan<- prometheus.Metric,
+) {
+	var oldestQueuedTaskAge float64
+	stateAges := map[string][]float64{}
+
+	for _, task := range tasks {
+		age := taskAge(task, now)
+		stateAges[task.State] = append(stateAges[task.State], age)
+		if task.State == "queued" && age > oldestQueuedTaskAge {
+			oldestQueuedTaskAge = age
+		}
+	}
+
+	c.directorOldestQueuedTaskAgeMetric.Set(oldestQueuedTaskAge)
+	c.directorOldestQueuedTaskAgeMetric.Collect(ch)
+
+	for _, state := range activeTaskStates {
+		var sum float64
+		buckets := make(map[float64]uint64, len(taskAgeBuckets))
+		for _, bucket := range taskAgeBuckets {
+			buckets[bucket] = 0
+		}
+		for _, age := range stateAges[state] {
+			sum += age
+			for _, bucket := range taskAgeBuckets {
+				if age <= bucket {
+					buckets[bucket]++
+				}
+			}
+		}
+
+		ch <- prometheus.MustNewConstHistogram(c.directorTaskAgeDesc, uint64(len(stateAges[state])), sum, buckets, state)
+	}
+}
+
+func taskAge(task deployments.Task, now time.Time) float64 {
+	// Queued tasks are not started yet, the director timestamp then holds the time they were queued
+	since := task.StartedAt
+	if task.State == "queued" || since.Unix() <= 0 {
+		since = task.FinishedAt
+	}
+
+	if since.Unix() <= 0 || since.After(now) {
+		return 0
+	}
+
+	return now.Sub(since).Seconds()
+}
+
+func (c *TasksCollector) taskFailed(task deployments.Task) bool {
+	return task.State == "error" || task.State == "timeout" || task.State == "cancelled"
+}
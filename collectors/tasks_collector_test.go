@@ -0,0 +1,423 @@
+package collectors_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+func init() {
+	_ = log.Base().SetLevel("fatal")
+}
+
+var _ = Describe("TasksCollector", func() {
+	var (
+		namespace         string
+		environment       string
+		boshName          string
+		boshUUID          string
+		failedTasksWindow time.Duration
+		startedAt         time.Time
+		fakeClock         *clock.FakeClock
+		tasksCollector    *TasksCollector
+
+		directorFailedTasksTotalMetric       *prometheus.CounterVec
+		directorOldestQueuedTaskAgeMetric    prometheus.Gauge
+		directorTaskAgeDesc                  *prometheus.Desc
+		lastTasksScrapeTimestampMetric       prometheus.Gauge
+		lastTasksScrapeDurationSecondsMetric prometheus.Gauge
+
+		deploymentName = "fake-deployment-name"
+	)
+
+	BeforeEach(func() {
+		namespace = "test_exporter"
+		environment = "test_environment"
+		boshName = "test_bosh_name"
+		boshUUID = "test_bosh_uuid"
+		failedTasksWindow = 24 * time.Hour
+		startedAt = time.Now().Add(-2 * time.Hour)
+
+		directorFailedTasksTotalMetric = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "director",
+				Name:      "failed_tasks_total",
+				Help:      "Total number of BOSH Director tasks failed since the exporter started, by error class.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"bosh_deployment", "error_class"},
+		)
+
+		directorOldestQueuedTaskAgeMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "director",
+				Name:      "oldest_queued_task_age_seconds",
+				Help:      "Age in seconds of the oldest queued BOSH Director task (0 when no task is queued).",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
+
+		directorTaskAgeDesc = prometheus.NewDesc(
+			"test_exporter_director_task_age_seconds",
+			"Age in seconds of the unfinished BOSH Director tasks by state.",
+			[]string{"state"},
+			prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		)
+
+		lastTasksScrapeTimestampMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "",
+				Name:      "last_tasks_scrape_timestamp",
+				Help:      "Number of seconds since 1970 since last scrape of Tasks metrics from BOSH.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
+
+		lastTasksScrapeDurationSecondsMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "",
+				Name:      "last_tasks_scrape_duration_seconds",
+				Help:      "Duration of the last scrape of Tasks metrics from BOSH.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+		)
+	})
+
+	JustBeforeEach(func() {
+		tasksCollector = NewTasksCollector(
+			namespace,
+			environment,
+			boshName,
+			boshUUID,
+			failedTasksWindow,
+		)
+		fakeClock = clock.NewFakeClock(startedAt)
+		tasksCollector.SetClock(fakeClock)
+		fakeClock.Set(time.Now())
+	})
+
+	Describe("Describe", func() {
+		var (
+			descriptions chan *prometheus.Desc
+		)
+
+		BeforeEach(func() {
+			descriptions = make(chan *prometheus.Desc)
+		})
+
+		JustBeforeEach(func() {
+			go tasksCollector.Describe(descriptions)
+		})
+
+		It("returns a director_failed_tasks_total description", func() {
+			Eventually(descriptions).Should(Receive(Equal(directorFailedTasksTotalMetric.WithLabelValues(
+				deploymentName,
+				CPITaskErrorClass,
+			).Desc())))
+		})
+
+		It("returns a director_oldest_queued_task_age_seconds metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(directorOldestQueuedTaskAgeMetric.Desc())))
+		})
+
+		It("returns a director_task_age_seconds metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(directorTaskAgeDesc)))
+		})
+
+		It("returns a last_tasks_scrape_timestamp metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(lastTasksScrapeTimestampMetric.Desc())))
+		})
+
+		It("returns a last_tasks_scrape_duration_seconds metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(lastTasksScrapeDurationSecondsMetric.Desc())))
+		})
+	})
+
+	Describe("Collect", func() {
+		var (
+			tasks           []deployments.Task
+			recentTasks     []deployments.Task
+			deploymentsInfo []deployments.DeploymentInfo
+			fetchedAt       time.Time
+
+			metrics    chan prometheus.Metric
+			errMetrics chan error
+		)
+
+		BeforeEach(func() {
+			tasks = []deployments.Task{
+				{
+					ID:         1,
+					State:      "error",
+					Result:     "Unknown CPI error 'Unknown' with message 'quota exceeded' in 'create_vm' CPI method",
+					FinishedAt: time.Now().Add(-time.Hour),
+				},
+				{
+					ID:         2,
+					State:      "error",
+					Result:     "Unknown CPI error 'Unknown' with message 'quota exceeded' in 'create_vm' CPI method",
+					FinishedAt: time.Now().Add(-time.Hour),
+				},
+				{
+					ID:         3,
+					State:      "done",
+					Result:     "/deployments/fake-deployment-name",
+					FinishedAt: time.Now().Add(-time.Hour),
+				},
+				{
+					ID:         4,
+					State:      "error",
+					Result:     "Unknown CPI error 'Unknown' with message 'quota exceeded' in 'create_vm' CPI method",
+					FinishedAt: time.Now().Add(-48 * time.Hour),
+				},
+			}
+			deploymentsInfo = []deployments.DeploymentInfo{
+				{
+					Name:  deploymentName,
+					Tasks: tasks,
+				},
+			}
+
+			recentTasks = []deployments.Task{}
+			fetchedAt = time.Now()
+
+			directorFailedTasksTotalMetric.WithLabelValues(
+				deploymentName,
+				CPITaskErrorClass,
+			).Add(float64(2))
+
+			metrics = make(chan prometheus.Metric)
+			errMetrics = make(chan error, 1)
+		})
+
+		JustBeforeEach(func() {
+			go func() {
+				if err := tasksCollector.Collect(fetcher.Snapshot{Deployments: deploymentsInfo, Tasks: recentTasks, FetchedAt: fetchedAt}, metrics); err != nil {
+					errMetrics <- err
+				}
+			}()
+		})
+
+		It("returns a director_failed_tasks_total metric for the failed tasks within the window", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(directorFailedTasksTotalMetric.WithLabelValues(
+				deploymentName,
+				CPITaskErrorClass,
+			))))
+			Consistently(errMetrics).ShouldNot(Receive())
+		})
+
+		Context("when the same failed tasks are collected again", func() {
+			JustBeforeEach(func() {
+				for i := 0; i < 7; i++ {
+					Eventually(metrics).Should(Receive())
+				}
+
+				go func() {
+					if err := tasksCollector.Collect(fetcher.Snapshot{Deployments: deploymentsInfo, Tasks: recentTasks, FetchedAt: fetchedAt}, metrics); err != nil {
+						errMetrics <- err
+					}
+				}()
+			})
+
+			It("does not count them twice", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(directorFailedTasksTotalMetric.WithLabelValues(
+					deploymentName,
+					CPITaskErrorClass,
+				))))
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
+		Context("when the failed tasks finished before the exporter started", func() {
+			BeforeEach(func() {
+				startedAt = time.Now().Add(-30 * time.Minute)
+			})
+
+			It("does not count them", func() {
+				for i := 0; i < 6; i++ {
+					Eventually(metrics).Should(Receive())
+				}
+				Consistently(metrics).ShouldNot(Receive())
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+
+		Context("when there are no failed tasks", func() {
+			BeforeEach(func() {
+				deploymentsInfo[0].Tasks = []deployments.Task{tasks[2]}
+			})
+
+			It("returns only the task age & scrape metrics", func() {
+				for i := 0; i < 6; i++ {
+					Eventually(metrics).Should(Receive())
+				}
+				Consistently(metrics).ShouldNot(Receive())
+				Consistently(errMetrics).ShouldNot(Receive())
+			})
+		})
+	})
+
+	Describe("Collect task ages", func() {
+		var (
+			fetchedAt   time.Time
+			recentTasks []deployments.Task
+
+			metrics chan prometheus.Metric
+		)
+
+		BeforeEach(func() {
+			fetchedAt = time.Now()
+			recentTasks = []deployments.Task{
+				{
+					ID:         10,
+					State:      "queued",
+					StartedAt:  time.Unix(0, 0),
+					FinishedAt: fetchedAt.Add(-2 * time.Hour),
+				},
+				{
+					ID:         11,
+					State:      "queued",
+					StartedAt:  time.Unix(0, 0),
+					FinishedAt: fetchedAt.Add(-20 * time.Second),
+				},
+				{
+					ID:         12,
+					State:      "processing",
+					StartedAt:  fetchedAt.Add(-45 * time.Second),
+					FinishedAt: fetchedAt.Add(-5 * time.Second),
+				},
+				{
+					ID:         13,
+					State:      "done",
+					StartedAt:  fetchedAt.Add(-3 * time.Hour),
+					FinishedAt: fetchedAt.Add(-2 * time.Hour),
+				},
+			}
+
+			directorOldestQueuedTaskAgeMetric.Set(float64(2 * 60 * 60))
+
+			metrics = make(chan prometheus.Metric)
+		})
+
+		JustBeforeEach(func() {
+			go func() {
+				_ = tasksCollector.Collect(fetcher.Snapshot{Tasks: recentTasks, FetchedAt: fetchedAt}, metrics)
+			}()
+		})
+
+		It("returns a director_oldest_queued_task_age_seconds metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(directorOldestQueuedTaskAgeMetric)))
+		})
+
+		It("returns a director_task_age_seconds histogram for queued tasks", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(prometheus.MustNewConstHistogram(
+				directorTaskAgeDesc,
+				2,
+				float64(2*60*60+20),
+				map[float64]uint64{10: 0, 30: 1, 60: 1, 300: 1, 900: 1, 1800: 1, 3600: 1, 7200: 2, 14400: 2, 43200: 2, 86400: 2},
+				"queued",
+			))))
+		})
+
+		It("returns a director_task_age_seconds histogram for processing tasks", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(prometheus.MustNewConstHistogram(
+				directorTaskAgeDesc,
+				1,
+				float64(45),
+				map[float64]uint64{10: 0, 30: 0, 60: 1, 300: 1, 900: 1, 1800: 1, 3600: 1, 7200: 1, 14400: 1, 43200: 1, 86400: 1},
+				"processing",
+			))))
+		})
+
+		Context("when there are no queued tasks", func() {
+			BeforeEach(func() {
+				recentTasks = recentTasks[2:]
+				directorOldestQueuedTaskAgeMetric.Set(0)
+			})
+
+			It("returns a zero director_oldest_queued_task_age_seconds metric", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(directorOldestQueuedTaskAgeMetric)))
+			})
+		})
+	})
+
+	Describe("ClassifyTaskError", func() {
+		It("classifies CPI errors", func() {
+			Expect(ClassifyTaskError(deployments.Task{
+				State:  "error",
+				Result: "CPI error 'Bosh::Clouds::VMCreationFailed' with message 'VM failed to create' in 'create_vm' CPI method",
+			})).To(Equal(CPITaskErrorClass))
+		})
+
+		It("classifies compilation failures", func() {
+			Expect(ClassifyTaskError(deployments.Task{
+				State:  "error",
+				Result: "Action Failed get_task: Task 1 result: Compiling package golang: Running packaging script: exit status 2",
+			})).To(Equal(CompilationTaskErrorClass))
+		})
+
+		It("classifies canary failures", func() {
+			Expect(ClassifyTaskError(deployments.Task{
+				State:  "error",
+				Result: "Failed updating canary instance 'router/0 (abc)'",
+			})).To(Equal(CanaryTaskErrorClass))
+		})
+
+		It("classifies update failures", func() {
+			Expect(ClassifyTaskError(deployments.Task{
+				State:  "error",
+				Result: "'router/abc (0)' is not running after update. Review logs for failed jobs: gorouter",
+			})).To(Equal(UpdateTaskErrorClass))
+		})
+
+		It("classifies timed out tasks", func() {
+			Expect(ClassifyTaskError(deployments.Task{State: "timeout"})).To(Equal(TimeoutTaskErrorClass))
+		})
+
+		It("classifies cancelled tasks", func() {
+			Expect(ClassifyTaskError(deployments.Task{State: "cancelled"})).To(Equal(CancelledTaskErrorClass))
+		})
+
+		It("classifies unknown errors as other", func() {
+			Expect(ClassifyTaskError(deployments.Task{
+				State:  "error",
+				Result: "Something went wrong",
+			})).To(Equal(OtherTaskErrorClass))
+		})
+	})
+})
@@ -0,0 +1,124 @@
+package collectors
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+)
+
+type TLSEndpoint struct {
+	Name    string
+	Address string
+}
+
+type TLSCertificatesCollector struct {
+	endpoints                   []TLSEndpoint
+	timeout                     time.Duration
+	refreshInterval             time.Duration
+	lastRefresh                 time.Time
+	clock                       clock.Clock
+	certificateExpiryTimeMetric *prometheus.GaugeVec
+	mu                          *sync.Mutex
+}
+
+func NewTLSCertificatesCollector(
+	namespace string,
+	environment string,
+	boshName string,
+	boshUUID string,
+	endpoints []TLSEndpoint,
+	timeout time.Duration,
+	refreshInterval time.Duration,
+) *TLSCertificatesCollector {
+	certificateExpiryTimeMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "director",
+			Name:      "tls_certificate_expiry_timestamp_seconds",
+			Help:      "Number of seconds since 1970 until the TLS certificate presented by a BOSH Director endpoint expires.",
+			ConstLabels: prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		},
+		[]string{"endpoint", "address"},
+	)
+
+	return &TLSCertificatesCollector{
+		endpoints:                   endpoints,
+		timeout:                     timeout,
+		refreshInterval:             refreshInterval,
+		clock:                       clock.Real,
+		certificateExpiryTimeMetric: certificateExpiryTimeMetric,
+		mu:                          &sync.Mutex{},
+	}
+}
+
+// SetClock replaces the clock telling when to refresh.
+func (c *TLSCertificatesCollector) SetClock(clk clock.Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clk
+}
+
+func (c *TLSCertificatesCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lastRefresh.IsZero() || c.clock.Now().Sub(c.lastRefresh) >= c.refreshInterval {
+		c.refresh()
+		c.lastRefresh = c.clock.Now()
+	}
+
+	c.certificateExpiryTimeMetric.Collect(ch)
+}
+
+func (c *TLSCertificatesCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.certificateExpiryTimeMetric.Describe(ch)
+}
+
+func (c *TLSCertificatesCollector) refresh() {
+	c.certificateExpiryTimeMetric.Reset()
+
+	for _, endpoint := range c.endpoints {
+		expiry, err := c.certificateExpiry(endpoint.Address)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		c.certificateExpiryTimeMetric.WithLabelValues(endpoint.Name, endpoint.Address).Set(float64(expiry.Unix()))
+	}
+}
+
+func (c *TLSCertificatesCollector) certificateExpiry(address string) (time.Time, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("Error while parsing TLS endpoint `%s`: %v", address, err))
+	}
+
+	// The certificate is only inspected, its validity is checked by the BOSH and UAA clients
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: c.timeout}, "tcp", address, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("Error while reading TLS certificate from `%s`: %v", address, err))
+	}
+	defer conn.Close()
+
+	certificates := conn.ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		return time.Time{}, errors.New(fmt.Sprintf("No TLS certificate presented by `%s`", address))
+	}
+
+	return certificates[0].NotAfter, nil
+}
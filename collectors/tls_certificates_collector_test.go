@@ -0,0 +1,110 @@
+package collectors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+var _ = Describe("TLSCertificatesCollector", func() {
+	var (
+		namespace   string
+		environment string
+		boshName    string
+		boshUUID    string
+		server      *httptest.Server
+		endpoints   []TLSEndpoint
+
+		tlsCertificatesCollector *TLSCertificatesCollector
+
+		certificateExpiryTimeMetric *prometheus.GaugeVec
+	)
+
+	BeforeEach(func() {
+		namespace = "test_exporter"
+		environment = "test_environment"
+		boshName = "test_bosh_name"
+		boshUUID = "test_bosh_uuid"
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		endpoints = []TLSEndpoint{{Name: "director", Address: strings.TrimPrefix(server.URL, "https://")}}
+
+		certificateExpiryTimeMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "director",
+				Name:      "tls_certificate_expiry_timestamp_seconds",
+				Help:      "Number of seconds since 1970 until the TLS certificate presented by a BOSH Director endpoint expires.",
+				ConstLabels: prometheus.Labels{
+					"environment": environment,
+					"bosh_name":   boshName,
+					"bosh_uuid":   boshUUID,
+				},
+			},
+			[]string{"endpoint", "address"},
+		)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		tlsCertificatesCollector = NewTLSCertificatesCollector(namespace, environment, boshName, boshUUID, endpoints, time.Second, time.Hour)
+	})
+
+	Describe("Describe", func() {
+		var (
+			descriptions chan *prometheus.Desc
+		)
+
+		BeforeEach(func() {
+			descriptions = make(chan *prometheus.Desc)
+		})
+
+		JustBeforeEach(func() {
+			go tlsCertificatesCollector.Describe(descriptions)
+		})
+
+		It("returns a director_tls_certificate_expiry_timestamp_seconds metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(certificateExpiryTimeMetric.WithLabelValues("director", endpoints[0].Address).Desc())))
+		})
+	})
+
+	Describe("Collect", func() {
+		var (
+			metrics chan prometheus.Metric
+		)
+
+		BeforeEach(func() {
+			metrics = make(chan prometheus.Metric)
+			certificateExpiryTimeMetric.WithLabelValues("director", endpoints[0].Address).Set(float64(server.Certificate().NotAfter.Unix()))
+		})
+
+		JustBeforeEach(func() {
+			go tlsCertificatesCollector.Collect(metrics)
+		})
+
+		It("returns a director_tls_certificate_expiry_timestamp_seconds metric", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(certificateExpiryTimeMetric.WithLabelValues("director", endpoints[0].Address))))
+		})
+
+		Context("when the endpoint is not reachable", func() {
+			BeforeEach(func() {
+				endpoints = []TLSEndpoint{{Name: "uaa", Address: "127.0.0.1:1"}}
+			})
+
+			It("does not return a metric", func() {
+				Consistently(metrics).ShouldNot(Receive())
+			})
+		})
+	})
+})
@@ -0,0 +1,200 @@
+package collectors
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+	"github.com/bosh-prometheus/bosh_exporter/filters"
+)
+
+const vitalsHistogramsCollectorName = "VitalsHistograms"
+
+// Bucket boundaries are the powers of 2 used by schema 0 native histograms.
+var (
+	processCPUTotalBuckets = prometheus.ExponentialBuckets(0.125, 2, 12)
+	processMemKBBuckets    = prometheus.ExponentialBuckets(1024, 2, 20)
+	processMemBytesBuckets = prometheus.ExponentialBuckets(1024*1024, 2, 20)
+)
+
+type processDistributionKey struct {
+	deploymentName string
+	processName    string
+}
+
+type VitalsHistogramsCollector struct {
+	azsFilter                     *filters.AZsFilter
+	deploymentProcessesFilter     *filters.DeploymentProcessesFilter
+	expressionFilter              *filters.ExpressionFilter
+	cidrsFilter                   *filters.CidrFilter
+	deploymentProcessCPUTotalDesc *prometheus.Desc
+	deploymentProcessMemKBDesc    *prometheus.Desc
+	deploymentProcessMemBytesDesc *prometheus.Desc
+	kbSeries                      bool
+}
+
+func NewVitalsHistogramsCollector(
+	namespace string,
+	environment string,
+	boshName string,
+	boshUUID string,
+	kbSeries bool,
+	azsFilter *filters.AZsFilter,
+	deploymentProcessesFilter *filters.DeploymentProcessesFilter,
+	expressionFilter *filters.ExpressionFilter,
+	cidrsFilter *filters.CidrFilter,
+) *VitalsHistogramsCollector {
+	deploymentProcessCPUTotalDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "deployment_process", "cpu_total"),
+		"Distribution of the BOSH Job Process CPU Total across the instances of a deployment.",
+		[]string{"bosh_deployment", "bosh_job_process_name"},
+		prometheus.Labels{
+			"environment": environment,
+			"bosh_name":   boshName,
+			"bosh_uuid":   boshUUID,
+		},
+	)
+
+	deploymentProcessMemKBDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "deployment_process", "mem_kb"),
+		"Distribution of the BOSH Job Process Memory KB across the instances of a deployment.",
+		[]string{"bosh_deployment", "bosh_job_process_name"},
+		prometheus.Labels{
+			"environment": environment,
+			"bosh_name":   boshName,
+			"bosh_uuid":   boshUUID,
+		},
+	)
+
+	deploymentProcessMemBytesDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "deployment_process", "mem_bytes"),
+		"Distribution of the BOSH Job Process Memory in bytes across the instances of a deployment.",
+		[]string{"bosh_deployment", "bosh_job_process_name"},
+		prometheus.Labels{
+			"environment": environment,
+			"bosh_name":   boshName,
+			"bosh_uuid":   boshUUID,
+		},
+	)
+
+	return &VitalsHistogramsCollector{
+		azsFilter:                     azsFilter,
+		deploymentProcessesFilter:     deploymentProcessesFilter,
+		expressionFilter:              expressionFilter,
+		cidrsFilter:                   cidrsFilter,
+		deploymentProcessCPUTotalDesc: deploymentProcessCPUTotalDesc,
+		deploymentProcessMemKBDesc:    deploymentProcessMemKBDesc,
+		deploymentProcessMemBytesDesc: deploymentProcessMemBytesDesc,
+		kbSeries:                      kbSeries,
+	}
+}
+
+func (c *VitalsHistogramsCollector) Collect(snapshot fetcher.Snapshot, ch chan<- prometheus.Metric) error {
+	return c.CollectStream(snapshot, snapshot.EachDeployment, ch)
+}
+
+func (c *VitalsHistogramsCollector) CollectStream(snapshot fetcher.Snapshot, eachDeployment fetcher.DeploymentsIterator, ch chan<- prometheus.Metric) error {
+	cpuTotals := map[processDistributionKey][]float64{}
+	memKBs := map[processDistributionKey][]float64{}
+
+	if err := eachDeployment(func(deployment deployments.DeploymentInfo) error {
+		c.observeDeployment(deployment, cpuTotals, memKBs)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	c.reportHistograms(ch, c.deploymentProcessCPUTotalDesc, cpuTotals, processCPUTotalBuckets)
+	if c.kbSeries {
+		c.reportHistograms(ch, c.deploymentProcessMemKBDesc, memKBs, processMemKBBuckets)
+	}
+	memBytes := make(map[processDistributionKey][]float64, len(memKBs))
+	for key, values := range memKBs {
+		for _, value := range values {
+			memBytes[key] = append(memBytes[key], value*1024)
+		}
+	}
+	c.reportHistograms(ch, c.deploymentProcessMemBytesDesc, memBytes, processMemBytesBuckets)
+
+	return nil
+}
+
+func (c *VitalsHistogramsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.deploymentProcessCPUTotalDesc
+	ch <- c.deploymentProcessMemKBDesc
+	ch <- c.deploymentProcessMemBytesDesc
+}
+
+func (c *VitalsHistogramsCollector) observeDeployment(
+	deployment deployments.DeploymentInfo,
+	cpuTotals map[processDistributionKey][]float64,
+	memKBs map[processDistributionKey][]float64,
+) {
+	for _, instance := range deployment.Instances {
+		if !c.azsFilter.EnabledInstance(instance.AZ, instance.Attributes) {
+			continue
+		}
+
+		jobIP, _ := c.cidrsFilter.Select(instance.IPs)
+		expressionFields := map[string]string{"deployment": deployment.Name, "job": instance.Name, "az": instance.AZ, "ip": jobIP}
+		if !c.expressionFilter.Enabled(expressionFields) {
+			continue
+		}
+
+		for _, process := range instance.Processes {
+			if !c.deploymentProcessesFilter.Enabled(deployment.Name, process.Name) {
+				continue
+			}
+			expressionFields["process"] = process.Name
+			if !c.expressionFilter.Enabled(expressionFields) {
+				continue
+			}
+
+			key := processDistributionKey{deploymentName: deployment.Name, processName: process.Name}
+			if process.CPU.Total != nil {
+				cpuTotals[key] = append(cpuTotals[key], float64(*process.CPU.Total))
+			}
+			if process.Mem.KB != nil {
+				memKBs[key] = append(memKBs[key], float64(*process.Mem.KB))
+			}
+		}
+	}
+}
+
+func (c *VitalsHistogramsCollector) reportHistograms(
+	ch chan<- prometheus.Metric,
+	desc *prometheus.Desc,
+	distributions map[processDistributionKey][]float64,
+	bucketBounds []float64,
+) {
+	keys := make([]processDistributionKey, 0, len(distributions))
+	for key := range distributions {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].deploymentName != keys[j].deploymentName {
+			return keys[i].deploymentName < keys[j].deploymentName
+		}
+		return keys[i].processName < keys[j].processName
+	})
+
+	for _, key := range keys {
+		var sum float64
+		buckets := make(map[float64]uint64, len(bucketBounds))
+		for _, bucket := range bucketBounds {
+			buckets[bucket] = 0
+		}
+		for _, value := range distributions[key] {
+			sum += value
+			for _, bucket := range bucketBounds {
+				if value <= bucket {
+					buckets[bucket]++
+				}
+			}
+		}
+
+		ch <- prometheus.MustNewConstHistogram(desc, uint64(len(distributions[key])), sum, buckets, key.deploymentName, key.processName)
+	}
+}
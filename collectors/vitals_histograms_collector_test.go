@@ -0,0 +1,238 @@
+package collectors_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+	"github.com/bosh-prometheus/bosh_exporter/filters"
+
+	. "github.com/bosh-prometheus/bosh_exporter/collectors"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+func histogramBuckets(bounds []float64, values ...float64) map[float64]uint64 {
+	buckets := map[float64]uint64{}
+	for _, bound := range bounds {
+		buckets[bound] = 0
+		for _, value := range values {
+			if value <= bound {
+				buckets[bound]++
+			}
+		}
+	}
+	return buckets
+}
+
+var _ = Describe("VitalsHistogramsCollector", func() {
+	var (
+		err                       error
+		namespace                 string
+		environment               string
+		boshName                  string
+		boshUUID                  string
+		kbSeries                  bool
+		azsFilter                 *filters.AZsFilter
+		deploymentProcessesFilter *filters.DeploymentProcessesFilter
+		expressionFilter          *filters.ExpressionFilter
+		cidrsFilter               *filters.CidrFilter
+		vitalsHistogramsCollector *VitalsHistogramsCollector
+
+		deploymentProcessCPUTotalDesc *prometheus.Desc
+		deploymentProcessMemKBDesc    *prometheus.Desc
+		deploymentProcessMemBytesDesc *prometheus.Desc
+
+		cpuTotalBuckets = prometheus.ExponentialBuckets(0.125, 2, 12)
+		memKBBuckets    = prometheus.ExponentialBuckets(1024, 2, 20)
+		memBytesBuckets = prometheus.ExponentialBuckets(1024*1024, 2, 20)
+	)
+
+	BeforeEach(func() {
+		namespace = "test_exporter"
+		environment = "test_environment"
+		boshName = "test_bosh_name"
+		boshUUID = "test_bosh_uuid"
+		kbSeries = true
+		azsFilter = filters.NewAZsFilter([]string{})
+		deploymentProcessesFilter, err = filters.NewDeploymentProcessesFilter("")
+		Expect(err).ToNot(HaveOccurred())
+		expressionFilter, err = filters.NewExpressionFilter("")
+		Expect(err).ToNot(HaveOccurred())
+		cidrsFilter, err = filters.NewCidrFilter([]string{"0.0.0.0/0"})
+		Expect(err).ToNot(HaveOccurred())
+
+		deploymentProcessCPUTotalDesc = prometheus.NewDesc(
+			"test_exporter_deployment_process_cpu_total",
+			"Distribution of the BOSH Job Process CPU Total across the instances of a deployment.",
+			[]string{"bosh_deployment", "bosh_job_process_name"},
+			prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		)
+
+		deploymentProcessMemKBDesc = prometheus.NewDesc(
+			"test_exporter_deployment_process_mem_kb",
+			"Distribution of the BOSH Job Process Memory KB across the instances of a deployment.",
+			[]string{"bosh_deployment", "bosh_job_process_name"},
+			prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		)
+
+		deploymentProcessMemBytesDesc = prometheus.NewDesc(
+			"test_exporter_deployment_process_mem_bytes",
+			"Distribution of the BOSH Job Process Memory in bytes across the instances of a deployment.",
+			[]string{"bosh_deployment", "bosh_job_process_name"},
+			prometheus.Labels{
+				"environment": environment,
+				"bosh_name":   boshName,
+				"bosh_uuid":   boshUUID,
+			},
+		)
+	})
+
+	JustBeforeEach(func() {
+		vitalsHistogramsCollector = NewVitalsHistogramsCollector(
+			namespace,
+			environment,
+			boshName,
+			boshUUID,
+			kbSeries,
+			azsFilter,
+			deploymentProcessesFilter,
+			expressionFilter,
+			cidrsFilter,
+		)
+	})
+
+	Describe("Describe", func() {
+		var (
+			descriptions chan *prometheus.Desc
+		)
+
+		BeforeEach(func() {
+			descriptions = make(chan *prometheus.Desc)
+		})
+
+		JustBeforeEach(func() {
+			go vitalsHistogramsCollector.Describe(descriptions)
+		})
+
+		It("returns a deployment_process_cpu_total description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentProcessCPUTotalDesc)))
+		})
+
+		It("returns a deployment_process_mem_kb description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentProcessMemKBDesc)))
+		})
+		It("returns a deployment_process_mem_bytes description", func() {
+			Eventually(descriptions).Should(Receive(Equal(deploymentProcessMemBytesDesc)))
+		})
+	})
+
+	Describe("Collect", func() {
+		var (
+			firstCPUTotal  = 0.5
+			secondCPUTotal = 3.0
+			firstMemKB     = uint64(2048)
+			secondMemKB    = uint64(100000)
+
+			metrics         chan prometheus.Metric
+			deploymentInfos []deployments.DeploymentInfo
+		)
+
+		BeforeEach(func() {
+			deploymentInfos = []deployments.DeploymentInfo{
+				{
+					Name: "fake-deployment-name",
+					Instances: []deployments.Instance{
+						{
+							Name: "router",
+							ID:   "0",
+							AZ:   "z1",
+							IPs:  []string{"1.2.3.4"},
+							Processes: []deployments.Process{
+								{Name: "gorouter", CPU: deployments.CPU{Total: &firstCPUTotal}, Mem: deployments.MemInt{KB: &firstMemKB}},
+							},
+						},
+						{
+							Name: "router",
+							ID:   "1",
+							AZ:   "z2",
+							IPs:  []string{"1.2.3.5"},
+							Processes: []deployments.Process{
+								{Name: "gorouter", CPU: deployments.CPU{Total: &secondCPUTotal}, Mem: deployments.MemInt{KB: &secondMemKB}},
+							},
+						},
+					},
+				},
+			}
+
+			metrics = make(chan prometheus.Metric)
+		})
+
+		JustBeforeEach(func() {
+			go func() {
+				_ = vitalsHistogramsCollector.Collect(fetcher.Snapshot{Deployments: deploymentInfos}, metrics)
+			}()
+		})
+
+		It("returns a deployment_process_cpu_total histogram", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(prometheus.MustNewConstHistogram(
+				deploymentProcessCPUTotalDesc,
+				2,
+				firstCPUTotal+secondCPUTotal,
+				histogramBuckets(cpuTotalBuckets, firstCPUTotal, secondCPUTotal),
+				"fake-deployment-name",
+				"gorouter",
+			))))
+		})
+
+		It("returns a deployment_process_mem_kb histogram", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(prometheus.MustNewConstHistogram(
+				deploymentProcessMemKBDesc,
+				2,
+				float64(firstMemKB+secondMemKB),
+				histogramBuckets(memKBBuckets, float64(firstMemKB), float64(secondMemKB)),
+				"fake-deployment-name",
+				"gorouter",
+			))))
+		})
+
+		It("returns a deployment_process_mem_bytes histogram", func() {
+			Eventually(metrics).Should(Receive(PrometheusMetric(prometheus.MustNewConstHistogram(
+				deploymentProcessMemBytesDesc,
+				2,
+				float64(firstMemKB+secondMemKB)*1024,
+				histogramBuckets(memBytesBuckets, float64(firstMemKB)*1024, float64(secondMemKB)*1024),
+				"fake-deployment-name",
+				"gorouter",
+			))))
+		})
+
+		Context("when an instance is filtered out", func() {
+			BeforeEach(func() {
+				expressionFilter, err = filters.NewExpressionFilter(`az != "z2"`)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("leaves it out of the distribution", func() {
+				Eventually(metrics).Should(Receive(PrometheusMetric(prometheus.MustNewConstHistogram(
+					deploymentProcessCPUTotalDesc,
+					1,
+					firstCPUTotal,
+					histogramBuckets(cpuTotalBuckets, firstCPUTotal),
+					"fake-deployment-name",
+					"gorouter",
+				))))
+			})
+		})
+	})
+})
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/api"
+	"github.com/bosh-prometheus/bosh_exporter/environments"
+)
+
+// configReloader reloads and validates the configuration on demand and logs
+// what changed since the running configuration was loaded. The BOSH
+// collectors are built once at startup, so the changes are not applied: they
+// are reported as pending until the next restart (the CA certificate files
+// already reload on their own). The metrics always carry the hash of the
+// running configuration.
+type configReloader struct {
+	environments                     []environments.Environment
+	hash                             string
+	loadEnvironments                 func() ([]environments.Environment, error)
+	lastReloadSuccessfulMetric       *prometheus.GaugeVec
+	lastReloadSuccessTimestampMetric prometheus.Gauge
+	reloadPendingMetric              prometheus.Gauge
+	mu                               *sync.Mutex
+}
+
+func newConfigReloader(namespace string, boshEnvironments []environments.Environment, loadEnvironments func() ([]environments.Environment, error)) *configReloader {
+	lastReloadSuccessfulMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "config_last_reload_successful",
+			Help:      "Whether the last configuration reload attempt loaded a valid configuration (1 for success, 0 for failure), by hash of the running configuration.",
+		},
+		[]string{"config_hash"},
+	)
+
+	lastReloadSuccessTimestampMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "config_last_reload_success_timestamp_seconds",
+			Help:      "Number of seconds since 1970 since the running configuration was loaded.",
+		},
+	)
+
+	reloadPendingMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "config_reload_pending",
+			Help:      "Whether the last configuration reloaded differs from the running configuration and waits for a restart of the exporter (1 for pending, 0 for not pending).",
+		},
+	)
+
+	hash := environments.Hash(boshEnvironments)
+	lastReloadSuccessfulMetric.WithLabelValues(hash).Set(1)
+	lastReloadSuccessTimestampMetric.Set(float64(time.Now().Unix()))
+	reloadPendingMetric.Set(0)
+
+	return &configReloader{
+		environments:                     boshEnvironments,
+		hash:                             hash,
+		loadEnvironments:                 loadEnvironments,
+		lastReloadSuccessfulMetric:       lastReloadSuccessfulMetric,
+		lastReloadSuccessTimestampMetric: lastReloadSuccessTimestampMetric,
+		reloadPendingMetric:              reloadPendingMetric,
+		mu:                               &sync.Mutex{},
+	}
+}
+
+// Reload loads the configuration again and returns its changes from the
+// running configuration.
+func (r *configReloader) Reload() ([]environments.EnvironmentChanges, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	boshEnvironments, err := r.loadEnvironments()
+	if err != nil {
+		log.Errorf("Error reloading the configuration: %v", err)
+		r.lastReloadSuccessfulMetric.WithLabelValues(r.hash).Set(0)
+		return nil, err
+	}
+	r.lastReloadSuccessfulMetric.WithLabelValues(r.hash).Set(1)
+
+	changes := environments.Diff(r.environments, boshEnvironments)
+	for _, change := range changes {
+		logConfigChanges(change)
+	}
+	if len(changes) == 0 {
+		log.Info("Configuration reloaded, nothing changed from the running configuration")
+		r.reloadPendingMetric.Set(0)
+	} else {
+		log.Warnf("Configuration reloaded, %d environments changed from the running configuration: the changes apply on the next restart of the exporter", len(changes))
+		r.reloadPendingMetric.Set(1)
+	}
+
+	return changes, nil
+}
+
+func (r *configReloader) Describe(ch chan<- *prometheus.Desc) {
+	r.lastReloadSuccessfulMetric.Describe(ch)
+	r.lastReloadSuccessTimestampMetric.Describe(ch)
+	r.reloadPendingMetric.Describe(ch)
+}
+
+func (r *configReloader) Collect(ch chan<- prometheus.Metric) {
+	r.lastReloadSuccessfulMetric.Collect(ch)
+	r.lastReloadSuccessTimestampMetric.Collect(ch)
+	r.reloadPendingMetric.Collect(ch)
+}
+
+func logConfigChanges(change environments.EnvironmentChanges) {
+	switch {
+	case change.Added:
+		log.Infof("Configuration reload: environment `%s` (%s) added", change.Environment, change.URL)
+		return
+	case change.Removed:
+		log.Infof("Configuration reload: environment `%s` (%s) removed", change.Environment, change.URL)
+		return
+	}
+
+	for name, values := range change.FiltersAdded {
+		log.Infof("Configuration reload: environment `%s` filter `%s` added `%s`", change.Environment, name, strings.Join(values, ","))
+	}
+	for name, values := range change.FiltersRemoved {
+		log.Infof("Configuration reload: environment `%s` filter `%s` removed `%s`", change.Environment, name, strings.Join(values, ","))
+	}
+	log.Infof("Configuration reload: environment `%s` credentials rotated: %t", change.Environment, change.CredentialsRotated)
+	if len(change.Changed) > 0 {
+		log.Infof("Configuration reload: environment `%s` changed `%s`", change.Environment, strings.Join(change.Changed, ","))
+	}
+}
+
+func configReloadHandler(reloader *configReloader) http.Handler {
+	return authHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		log.Infof("Configuration reload requested from `%s`", r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json")
+
+		response := api.ConfigReloadResponse{Status: api.StatusSuccess, Data: []api.EnvironmentConfigChanges{}}
+		changes, err := reloader.Reload()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			response = api.ConfigReloadResponse{Status: api.StatusError, Error: err.Error()}
+		}
+		for _, change := range changes {
+			response.Data = append(response.Data, api.EnvironmentConfigChanges(change))
+		}
+		response.Pending = len(response.Data) > 0
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Errorf("Error encoding config reload: %v", err)
+		}
+	}))
+}
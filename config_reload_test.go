@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/bosh_exporter/api"
+	"github.com/bosh-prometheus/bosh_exporter/environments"
+)
+
+// gaugeValue returns the value of the gauge named name collected by collector.
+func gaugeValue(collector prometheus.Collector, name string) float64 {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	metricFamilies, err := registry.Gather()
+	Expect(err).ToNot(HaveOccurred())
+
+	for _, metricFamily := range metricFamilies {
+		if metricFamily.GetName() == name {
+			Expect(metricFamily.GetMetric()).To(HaveLen(1))
+			return metricFamily.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	Fail("metric `" + name + "` not collected")
+
+	return 0
+}
+
+var _ = Describe("configReloader", func() {
+	var (
+		runningEnvironments []environments.Environment
+		loadedEnvironments  []environments.Environment
+		loadErr             error
+		reloader            *configReloader
+	)
+
+	BeforeEach(func() {
+		runningEnvironments = []environments.Environment{
+			{Environment: "fake-environment", URL: "https://fake-director:25555"},
+		}
+		loadedEnvironments = runningEnvironments
+		loadErr = nil
+	})
+
+	JustBeforeEach(func() {
+		reloader = newConfigReloader("test_exporter", runningEnvironments, func() ([]environments.Environment, error) {
+			return loadedEnvironments, loadErr
+		})
+	})
+
+	It("reports the running configuration as successfully loaded", func() {
+		Expect(gaugeValue(reloader, "test_exporter_exporter_config_last_reload_successful")).To(Equal(float64(1)))
+		Expect(gaugeValue(reloader, "test_exporter_exporter_config_reload_pending")).To(Equal(float64(0)))
+	})
+
+	Describe("Reload", func() {
+		Context("when the configuration did not change", func() {
+			It("returns no changes and no pending reload", func() {
+				changes, err := reloader.Reload()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(changes).To(BeEmpty())
+				Expect(gaugeValue(reloader, "test_exporter_exporter_config_reload_pending")).To(Equal(float64(0)))
+			})
+		})
+
+		Context("when an environment was added", func() {
+			BeforeEach(func() {
+				loadedEnvironments = append(loadedEnvironments, environments.Environment{Environment: "fake-other-environment", URL: "https://fake-other-director:25555"})
+			})
+
+			It("returns the change and reports a pending reload", func() {
+				changes, err := reloader.Reload()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(changes).To(Equal([]environments.EnvironmentChanges{
+					{Environment: "fake-other-environment", URL: "https://fake-other-director:25555", Added: true},
+				}))
+				Expect(gaugeValue(reloader, "test_exporter_exporter_config_reload_pending")).To(Equal(float64(1)))
+			})
+		})
+
+		Context("when the configuration is invalid", func() {
+			BeforeEach(func() {
+				loadErr = errors.New("fake-error")
+			})
+
+			It("returns the error and reports the failed reload", func() {
+				_, err := reloader.Reload()
+				Expect(err).To(MatchError("fake-error"))
+				Expect(gaugeValue(reloader, "test_exporter_exporter_config_last_reload_successful")).To(Equal(float64(0)))
+			})
+		})
+	})
+
+	Describe("configReloadHandler", func() {
+		var (
+			method   string
+			recorder *httptest.ResponseRecorder
+		)
+
+		BeforeEach(func() {
+			method = http.MethodPost
+			loadedEnvironments = []environments.Environment{}
+		})
+
+		JustBeforeEach(func() {
+			recorder = httptest.NewRecorder()
+			configReloadHandler(reloader).ServeHTTP(recorder, httptest.NewRequest(method, "/-/reload", nil))
+		})
+
+		It("returns the pending changes", func() {
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var response api.ConfigReloadResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Status).To(Equal(api.StatusSuccess))
+			Expect(response.Pending).To(BeTrue())
+			Expect(response.Data).To(HaveLen(1))
+			Expect(response.Data[0].Removed).To(BeTrue())
+		})
+
+		Context("when the configuration is invalid", func() {
+			BeforeEach(func() {
+				loadErr = errors.New("fake-error")
+			})
+
+			It("returns the error", func() {
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+
+				var response api.ConfigReloadResponse
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Status).To(Equal(api.StatusError))
+				Expect(response.Error).To(Equal("fake-error"))
+			})
+		})
+
+		Context("when the request is not a POST", func() {
+			BeforeEach(func() {
+				method = http.MethodGet
+			})
+
+			It("is not allowed", func() {
+				Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+				Expect(recorder.Header().Get("Allow")).To(Equal(http.MethodPost))
+			})
+		})
+	})
+})
@@ -0,0 +1,69 @@
+package deployments
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+)
+
+// accountedDeployment counts the BOSH Director API calls sent while fetching
+// a deployment. Name is served from the deployments list and is not counted.
+type accountedDeployment struct {
+	director.Deployment
+	apiCalls uint64
+}
+
+func (d *accountedDeployment) InstanceInfos() ([]director.VMInfo, error) {
+	d.apiCalls++
+	return d.Deployment.InstanceInfos()
+}
+
+func (d *accountedDeployment) Manifest() (string, error) {
+	d.apiCalls++
+	return d.Deployment.Manifest()
+}
+
+func (d *accountedDeployment) CloudConfig() (string, error) {
+	d.apiCalls++
+	return d.Deployment.CloudConfig()
+}
+
+func (d *accountedDeployment) Releases() ([]director.Release, error) {
+	d.apiCalls++
+	return d.Deployment.Releases()
+}
+
+func (d *accountedDeployment) Stemcells() ([]director.Stemcell, error) {
+	d.apiCalls++
+	return d.Deployment.Stemcells()
+}
+
+// apiCallsAccounting keeps the total number of BOSH Director API calls sent
+// for every deployment since the exporter started.
+type apiCallsAccounting struct {
+	mu    sync.Mutex
+	total map[string]uint64
+}
+
+func newAPICallsAccounting() *apiCallsAccounting {
+	return &apiCallsAccounting{total: map[string]uint64{}}
+}
+
+func (a *apiCallsAccounting) add(deploymentName string, apiCalls uint64) uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.total[deploymentName] += apiCalls
+	return a.total[deploymentName]
+}
+
+func (a *apiCallsAccounting) forget(seenDeployments map[string]bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for deploymentName := range a.total {
+		if !seenDeployments[deploymentName] {
+			delete(a.total, deploymentName)
+		}
+	}
+}
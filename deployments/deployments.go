@@ -1,34 +1,69 @@
 package deployments
 
+import (
+	"time"
+)
+
 type DeploymentInfo struct {
-	Name      string
-	Instances []Instance
-	Releases  []Release
-	Stemcells []Stemcell
+	Name            string
+	Instances       []Instance
+	Releases        []Release
+	Stemcells       []Stemcell
+	Configs         []Config
+	Tasks           []Task
+	Problems        []Problem
+	ProblemsScanned bool
+	FetchDuration   time.Duration
+	APICallsTotal   uint64
+}
+
+// IntentionallyStopped returns whether every instance of the deployment was
+// stopped on purpose (`bosh stop`), rather than failing.
+func (d DeploymentInfo) IntentionallyStopped() bool {
+	if len(d.Instances) == 0 {
+		return false
+	}
+
+	for _, instance := range d.Instances {
+		if !instance.Stopped {
+			return false
+		}
+	}
+
+	return true
 }
 
 type Instance struct {
-	AgentID            string
-	Name               string
-	ID                 string
-	Index              string
-	Bootstrap          bool
-	IPs                []string
-	AZ                 string
-	VMType             string
-	ResourcePool       string
-	ResurrectionPaused bool
-	Healthy            bool
-	Processes          []Process
-	Vitals             Vitals
+	AgentID              string
+	Stopped              bool
+	Name                 string
+	ID                   string
+	Index                string
+	Bootstrap            bool
+	IPs                  []string
+	AZ                   string
+	VMType               string
+	ResourcePool         string
+	VMID                 string
+	VMCreatedAt          time.Time
+	VMCreatedBy          string
+	TrustedCertsSHA1     string
+	ResurrectionPaused   bool
+	Healthy              bool
+	Processes            []Process
+	Vitals               Vitals
+	Attributes           map[string]string
+	PersistentDiskSizeMB uint64
 }
 
 type Process struct {
-	Name    string
-	Uptime  *uint64
-	Healthy bool
-	CPU     CPU
-	Mem     MemInt
+	Name        string
+	JobTemplate string
+	Uptime      *uint64
+	Healthy     bool
+	CPU         CPU
+	Mem         MemInt
+	Ports       []int
 }
 
 type Vitals struct {
@@ -43,10 +78,13 @@ type Vitals struct {
 }
 
 type CPU struct {
-	Total *float64
-	Sys   string
-	User  string
-	Wait  string
+	Total     *float64
+	Sys       string
+	User      string
+	Wait      string
+	Steal     string
+	Limit     *float64
+	Throttled *float64
 }
 
 type Mem struct {
@@ -70,7 +108,32 @@ type Release struct {
 }
 
 type Stemcell struct {
-	Name    string
-	Version string
-	OSName  string
+	Name           string
+	Version        string
+	OSName         string
+	LatestVersion  string
+	VersionsBehind int
+	DaysBehind     int
+}
+
+type Config struct {
+	ID       string
+	Type     string
+	Name     string
+	LatestID string
+}
+
+type Task struct {
+	ID             int
+	DeploymentName string
+	State          string
+	Description    string
+	Result         string
+	StartedAt      time.Time
+	FinishedAt     time.Time
+}
+
+type Problem struct {
+	Type        string
+	Description string
 }
@@ -3,20 +3,102 @@ package deployments
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/cloudfoundry/bosh-cli/director"
+	semver "github.com/cppforlife/go-semi-semantic/version"
 	"github.com/prometheus/common/log"
+	"gopkg.in/yaml.v2"
 
+	"github.com/bosh-prometheus/bosh_exporter/clock"
 	"github.com/bosh-prometheus/bosh_exporter/filters"
 )
 
+type deploymentManifest struct {
+	Tags           map[string]interface{} `yaml:"tags"`
+	InstanceGroups []struct {
+		Name string `yaml:"name"`
+		Jobs []struct {
+			Name string `yaml:"name"`
+		} `yaml:"jobs"`
+		PersistentDisk     uint64 `yaml:"persistent_disk"`
+		PersistentDiskType string `yaml:"persistent_disk_type"`
+	} `yaml:"instance_groups"`
+}
+
+type deploymentCloudConfig struct {
+	AZs []struct {
+		Name            string                 `yaml:"name"`
+		CloudProperties map[string]interface{} `yaml:"cloud_properties"`
+	} `yaml:"azs"`
+	DiskTypes []struct {
+		Name     string `yaml:"name"`
+		DiskSize uint64 `yaml:"disk_size"`
+	} `yaml:"disk_types"`
+}
+
+const stoppedState = "stopped"
+
 type Fetcher struct {
-	deploymentsFilter filters.DeploymentsFilter
+	deploymentsFilter      filters.DeploymentsFilter
+	expressionFilter       *filters.ExpressionFilter
+	boshClient             director.Director
+	interner               *stringInterner
+	problemsScanner        *problemsScanner
+	fetchSchedule          *fetchSchedule
+	apiCallsAccounting     *apiCallsAccounting
+	fetchAZCloudProperties bool
+	vmInfoExtensions       *VMInfoExtensions
+	compareLatestVersions  bool
+	stemcellUploads        *stemcellUploads
+	clock                  clock.Clock
+}
+
+func NewFetcher(deploymentsFilter filters.DeploymentsFilter, expressionFilter *filters.ExpressionFilter, boshClient director.Director, problemsScanInterval time.Duration, fetchAZCloudProperties bool) *Fetcher {
+	return &Fetcher{
+		deploymentsFilter:      deploymentsFilter,
+		expressionFilter:       expressionFilter,
+		boshClient:             boshClient,
+		interner:               newStringInterner(),
+		problemsScanner:        newProblemsScanner(problemsScanInterval),
+		fetchSchedule:          newFetchSchedule(0),
+		apiCallsAccounting:     newAPICallsAccounting(),
+		fetchAZCloudProperties: fetchAZCloudProperties,
+		compareLatestVersions:  true,
+		stemcellUploads:        newStemcellUploads(),
+		clock:                  clock.Real,
+	}
+}
+
+// SetFetchSpread spreads the deployment fetches of Deployments over a period,
+// each deployment being fetched once per period at its own slot and its last
+// fetched info being returned in between. 0 fetches every deployment on every
+// call. It must be called before the first fetch.
+func (f *Fetcher) SetFetchSpread(spread time.Duration) {
+	f.fetchSchedule.spread = spread
+}
+
+// SetClock replaces the clock timing the fetch spread and the stemcell
+// upload ages. It must be called before the first fetch.
+func (f *Fetcher) SetClock(clk clock.Clock) {
+	f.fetchSchedule.clock = clk
+	f.clock = clk
+}
+
+// SetCompareLatestVersions sets whether the deployed stemcells and configs are
+// compared with the latest ones uploaded to the Director, which only the
+// Deployments collector reports. It must be called before the first fetch.
+func (f *Fetcher) SetCompareLatestVersions(compare bool) {
+	f.compareLatestVersions = compare
 }
 
-func NewFetcher(deploymentsFilter filters.DeploymentsFilter) *Fetcher {
-	return &Fetcher{deploymentsFilter: deploymentsFilter}
+// SetVMInfoExtensions completes the instances with the fields captured by
+// extensions from the Director responses. It must be called before the first
+// fetch.
+func (f *Fetcher) SetVMInfoExtensions(extensions *VMInfoExtensions) {
+	f.vmInfoExtensions = extensions
 }
 
 func (f *Fetcher) Deployments() ([]DeploymentInfo, error) {
@@ -28,8 +110,19 @@ func (f *Fetcher) Deployments() ([]DeploymentInfo, error) {
 	if err != nil {
 		return deploymentsInfo, err
 	}
+	f.interner.rotate()
 
 	for _, deployment := range deployments {
+		if !f.expressionFilter.Enabled(map[string]string{"deployment": deployment.Name()}) {
+			continue
+		}
+		if deploymentInfo, ok := f.fetchSchedule.cached(deployment.Name()); ok {
+			mutex.Lock()
+			deploymentsInfo = append(deploymentsInfo, deploymentInfo)
+			mutex.Unlock()
+			continue
+		}
+
 		wg.Add(1)
 		go func(deployment director.Deployment) {
 			defer wg.Done()
@@ -38,6 +131,7 @@ func (f *Fetcher) Deployments() ([]DeploymentInfo, error) {
 				log.Error(err)
 				return
 			}
+			f.fetchSchedule.store(*deploymentInfo)
 
 			mutex.Lock()
 			deploymentsInfo = append(deploymentsInfo, *deploymentInfo)
@@ -46,14 +140,142 @@ func (f *Fetcher) Deployments() ([]DeploymentInfo, error) {
 	}
 	wg.Wait()
 
+	seenDeployments := map[string]bool{}
+	for _, deploymentInfo := range deploymentsInfo {
+		seenDeployments[deploymentInfo.Name] = true
+	}
+	f.problemsScanner.forget(seenDeployments)
+	f.fetchSchedule.forget(seenDeployments)
+	f.apiCallsAccounting.forget(seenDeployments)
+
+	latest := f.fetchLatestVersions()
+	for _, deploymentInfo := range deploymentsInfo {
+		f.applyLatestVersions(deploymentInfo, latest)
+	}
+
 	return deploymentsInfo, nil
 }
 
+// StreamDeployments lists the deployments and returns an iterator fetching
+// them one at a time, calling fn with each of them as soon as it is read so
+// that only one deployment is held in memory instead of all of them. The
+// iterator stops at the first error returned by fn.
+func (f *Fetcher) StreamDeployments() (func(fn func(DeploymentInfo) error) error, error) {
+	deployments, err := f.deploymentsFilter.GetDeployments()
+	if err != nil {
+		return nil, err
+	}
+	f.interner.rotate()
+
+	latest := f.fetchLatestVersions()
+
+	return func(fn func(DeploymentInfo) error) error {
+		seenDeployments := map[string]bool{}
+		defer func() {
+			f.problemsScanner.forget(seenDeployments)
+			f.apiCallsAccounting.forget(seenDeployments)
+		}()
+
+		for _, deployment := range deployments {
+			if !f.expressionFilter.Enabled(map[string]string{"deployment": deployment.Name()}) {
+				continue
+			}
+
+			deploymentInfo, err := f.fetchDeploymentInfo(deployment)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			seenDeployments[deploymentInfo.Name] = true
+
+			f.applyLatestVersions(*deploymentInfo, latest)
+			if err := fn(*deploymentInfo); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, nil
+}
+
+// latestVersions are the latest stemcell versions uploaded to and the latest
+// configs stored in the BOSH Director, nil when they could not be read.
+type latestVersions struct {
+	uploadedStemcells map[string][]uploadedStemcell
+	configs           map[string]string
+	fetchedAt         time.Time
+}
+
+func (f *Fetcher) fetchLatestVersions() latestVersions {
+	latest := latestVersions{fetchedAt: f.clock.Now()}
+	if !f.compareLatestVersions {
+		return latest
+	}
+
+	uploadedStemcells, err := f.fetchUploadedStemcells()
+	if err != nil {
+		log.Error(err)
+	} else {
+		latest.uploadedStemcells = uploadedStemcells
+	}
+
+	configs, err := f.fetchLatestConfigs()
+	if err != nil {
+		log.Error(err)
+	} else {
+		latest.configs = configs
+	}
+
+	return latest
+}
+
+func (f *Fetcher) applyLatestVersions(deploymentInfo DeploymentInfo, latest latestVersions) {
+	if latest.uploadedStemcells != nil {
+		for i, stemcell := range deploymentInfo.Stemcells {
+			deploymentInfo.Stemcells[i] = f.stemcellWithLatestVersion(stemcell, latest.uploadedStemcells[stemcell.Name], latest.fetchedAt)
+		}
+	}
+	if latest.configs != nil {
+		for i, config := range deploymentInfo.Configs {
+			deploymentInfo.Configs[i].LatestID = latest.configs[config.Type+"/"+config.Name]
+		}
+	}
+}
+
+// ScanProblems queues a BOSH Director problem scan of a deployment watched by
+// the exporter. Problems are never resolved.
+func (f *Fetcher) ScanProblems(deploymentName string) error {
+	if !f.deploymentsFilter.Explain(deploymentName).Accepted || !f.expressionFilter.Enabled(map[string]string{"deployment": deploymentName}) {
+		return ErrDeploymentNotFound
+	}
+
+	deployments, err := f.deploymentsFilter.GetDeployments()
+	if err != nil {
+		return err
+	}
+	for _, deployment := range deployments {
+		if deployment.Name() == deploymentName {
+			return f.problemsScanner.trigger(deployment)
+		}
+	}
+
+	return ErrDeploymentNotFound
+}
+
 func (f *Fetcher) fetchDeploymentInfo(deployment director.Deployment) (*DeploymentInfo, error) {
 	deploymentInfo := &DeploymentInfo{
-		Name: deployment.Name(),
+		Name: f.interner.intern(deployment.Name()),
 	}
 
+	begun := time.Now()
+	problemsDeployment := deployment
+	accounted := &accountedDeployment{Deployment: deployment}
+	deployment = accounted
+	defer func() {
+		deploymentInfo.FetchDuration = time.Since(begun)
+		deploymentInfo.APICallsTotal = f.apiCallsAccounting.add(deploymentInfo.Name, accounted.apiCalls)
+	}()
+
 	instances, err := f.fetchDeploymentInstances(deployment)
 	if err != nil {
 		return deploymentInfo, err
@@ -72,61 +294,100 @@ func (f *Fetcher) fetchDeploymentInfo(deployment director.Deployment) (*Deployme
 	}
 	deploymentInfo.Stemcells = stemcells
 
+	configs, err := f.fetchDeploymentConfigs(deployment)
+	accounted.apiCalls++
+	if err != nil {
+		log.Error(err)
+	}
+	deploymentInfo.Configs = configs
+
+	deploymentInfo.Problems, deploymentInfo.ProblemsScanned = f.problemsScanner.problems(problemsDeployment)
+
+	if len(stemcells) == 1 {
+		stemcell := f.interner.intern(stemcells[0].Name + "/" + stemcells[0].Version)
+		for _, instance := range deploymentInfo.Instances {
+			instance.Attributes["stemcell"] = stemcell
+		}
+	}
+
 	return deploymentInfo, nil
 }
 
 func (f *Fetcher) fetchDeploymentInstances(deployment director.Deployment) ([]Instance, error) {
-	deploymentInstances := []Instance{}
-
 	log.Debugf("Reading Instances for deployment `%s`:", deployment.Name())
 	instances, err := deployment.InstanceInfos()
 	if err != nil {
-		return deploymentInstances, fmt.Errorf("Error while reading Instances for deployment `%s`: %v", deployment.Name(), err)
+		return []Instance{}, fmt.Errorf("Error while reading Instances for deployment `%s`: %v", deployment.Name(), err)
 	}
+	deploymentInstances := make([]Instance, 0, len(instances))
+
+	manifest, err := f.fetchDeploymentManifest(deployment)
+	if err != nil {
+		log.Error(err)
+	}
+	jobTemplates := f.jobTemplates(manifest)
+
+	var cloudConfig *deploymentCloudConfig
+	if f.fetchAZCloudProperties {
+		if cloudConfig, err = f.fetchCloudConfig(deployment); err != nil {
+			log.Error(err)
+		}
+	}
+	persistentDiskSizes := f.persistentDiskSizes(deployment, manifest, cloudConfig)
+	azAttributes := f.azAttributes(cloudConfig)
+	tagAttributes := map[string]string{}
+	f.flattenAttributes(tagAttributes, "tags", normalizeYAML(manifest.Tags))
 
 	for _, instance := range instances {
+		extension := f.vmInfoExtensions.take(instance.AgentID)
 		if instance.VMID == "" {
 			continue
 		}
 
 		deploymentInstance := Instance{
 			AgentID:            instance.AgentID,
-			Name:               instance.JobName,
+			Name:               f.interner.intern(instance.JobName),
 			ID:                 instance.ID,
 			Bootstrap:          instance.Bootstrap,
 			IPs:                instance.IPs,
-			AZ:                 instance.AZ,
-			VMType:             instance.VMType,
-			ResourcePool:       instance.ResourcePool,
+			AZ:                 f.interner.intern(instance.AZ),
+			VMType:             f.interner.intern(instance.VMType),
+			ResourcePool:       f.interner.intern(instance.ResourcePool),
+			VMID:               instance.VMID,
+			VMCreatedAt:        instance.VMCreatedAt,
+			VMCreatedBy:        extension.CreatedBy,
+			TrustedCertsSHA1:   extension.TrustedCertsSHA1,
 			ResurrectionPaused: instance.ResurrectionPaused,
 			Healthy:            instance.IsRunning(),
+			Stopped:            instance.State == stoppedState,
 			Vitals: Vitals{
 				CPU: CPU{
-					Sys:  instance.Vitals.CPU.Sys,
-					User: instance.Vitals.CPU.User,
-					Wait: instance.Vitals.CPU.Wait,
+					Sys:   f.vitalValue(instance.Vitals.CPU.Sys),
+					User:  f.vitalValue(instance.Vitals.CPU.User),
+					Wait:  f.vitalValue(instance.Vitals.CPU.Wait),
+					Steal: f.vitalValue(extension.Vitals.CPU.Steal),
 				},
 				Mem: Mem{
-					KB:      instance.Vitals.Mem.KB,
-					Percent: instance.Vitals.Mem.Percent,
+					KB:      f.vitalValue(instance.Vitals.Mem.KB),
+					Percent: f.vitalValue(instance.Vitals.Mem.Percent),
 				},
 				Swap: Mem{
-					KB:      instance.Vitals.Swap.KB,
-					Percent: instance.Vitals.Swap.Percent,
+					KB:      f.vitalValue(instance.Vitals.Swap.KB),
+					Percent: f.vitalValue(instance.Vitals.Swap.Percent),
 				},
 				Uptime: instance.Vitals.Uptime.Seconds,
-				Load:   instance.Vitals.Load,
+				Load:   f.loadAvgValues(instance.Vitals.Load),
 				SystemDisk: Disk{
-					InodePercent: instance.Vitals.SystemDisk().InodePercent,
-					Percent:      instance.Vitals.SystemDisk().Percent,
+					InodePercent: f.vitalValue(instance.Vitals.SystemDisk().InodePercent),
+					Percent:      f.vitalValue(instance.Vitals.SystemDisk().Percent),
 				},
 				EphemeralDisk: Disk{
-					InodePercent: instance.Vitals.EphemeralDisk().InodePercent,
-					Percent:      instance.Vitals.EphemeralDisk().Percent,
+					InodePercent: f.vitalValue(instance.Vitals.EphemeralDisk().InodePercent),
+					Percent:      f.vitalValue(instance.Vitals.EphemeralDisk().Percent),
 				},
 				PersistentDisk: Disk{
-					InodePercent: instance.Vitals.PersistentDisk().InodePercent,
-					Percent:      instance.Vitals.PersistentDisk().Percent,
+					InodePercent: f.vitalValue(instance.Vitals.PersistentDisk().InodePercent),
+					Percent:      f.vitalValue(instance.Vitals.PersistentDisk().Percent),
 				},
 			},
 		}
@@ -135,19 +396,33 @@ func (f *Fetcher) fetchDeploymentInstances(deployment director.Deployment) ([]In
 			deploymentInstance.Index = strconv.Itoa(int(*instance.Index))
 		}
 
-		deploymentProcesses := []Process{}
+		deploymentInstance.Attributes = f.instanceAttributes(instance)
+		for name, value := range azAttributes[instance.AZ] {
+			deploymentInstance.Attributes[name] = value
+		}
+		for name, value := range tagAttributes {
+			deploymentInstance.Attributes[name] = value
+		}
+		deploymentInstance.PersistentDiskSizeMB = persistentDiskSizes[instance.JobName]
+
+		deploymentProcesses := make([]Process, 0, len(instance.Processes))
 		for _, process := range instance.Processes {
+			processExtension := extension.process(process.Name)
 			deploymentProcess := Process{
-				Name:    process.Name,
-				Uptime:  process.Uptime.Seconds,
-				Healthy: process.IsRunning(),
+				Name:        f.interner.intern(process.Name),
+				JobTemplate: f.processJobTemplate(process.Name, jobTemplates[instance.JobName]),
+				Uptime:      process.Uptime.Seconds,
+				Healthy:     process.IsRunning(),
 				CPU: CPU{
-					Total: process.CPU.Total,
+					Total:     process.CPU.Total,
+					Limit:     processExtension.CPU.Limit,
+					Throttled: processExtension.CPU.Throttled,
 				},
 				Mem: MemInt{
 					KB:      process.Mem.KB,
 					Percent: process.Mem.Percent,
 				},
+				Ports: processExtension.Ports,
 			}
 			deploymentProcesses = append(deploymentProcesses, deploymentProcess)
 		}
@@ -159,6 +434,184 @@ func (f *Fetcher) fetchDeploymentInstances(deployment director.Deployment) ([]In
 	return deploymentInstances, nil
 }
 
+func (f *Fetcher) fetchDeploymentManifest(deployment director.Deployment) (deploymentManifest, error) {
+	var parsedManifest deploymentManifest
+
+	log.Debugf("Reading Manifest for deployment `%s`:", deployment.Name())
+	manifest, err := deployment.Manifest()
+	if err != nil {
+		return parsedManifest, fmt.Errorf("Error while reading Manifest for deployment `%s`: %v", deployment.Name(), err)
+	}
+
+	if err := yaml.Unmarshal([]byte(manifest), &parsedManifest); err != nil {
+		return parsedManifest, fmt.Errorf("Error while parsing Manifest for deployment `%s`: %v", deployment.Name(), err)
+	}
+
+	return parsedManifest, nil
+}
+
+func (f *Fetcher) jobTemplates(manifest deploymentManifest) map[string][]string {
+	jobTemplates := map[string][]string{}
+
+	for _, instanceGroup := range manifest.InstanceGroups {
+		for _, job := range instanceGroup.Jobs {
+			jobTemplates[instanceGroup.Name] = append(jobTemplates[instanceGroup.Name], job.Name)
+		}
+	}
+
+	return jobTemplates
+}
+
+// persistentDiskSizes only reads the Cloud Config when it is not already read
+// and an instance group uses a persistent disk type.
+func (f *Fetcher) persistentDiskSizes(deployment director.Deployment, manifest deploymentManifest, cloudConfig *deploymentCloudConfig) map[string]uint64 {
+	persistentDiskSizes := map[string]uint64{}
+
+	var diskTypeSizes map[string]uint64
+	if cloudConfig != nil {
+		diskTypeSizes = f.diskTypeSizes(cloudConfig)
+	}
+	for _, instanceGroup := range manifest.InstanceGroups {
+		if instanceGroup.PersistentDisk > 0 {
+			persistentDiskSizes[instanceGroup.Name] = instanceGroup.PersistentDisk
+			continue
+		}
+
+		if instanceGroup.PersistentDiskType == "" {
+			continue
+		}
+
+		if diskTypeSizes == nil {
+			var err error
+			diskTypeSizes, err = f.fetchDiskTypeSizes(deployment)
+			if err != nil {
+				log.Error(err)
+			}
+		}
+		persistentDiskSizes[instanceGroup.Name] = diskTypeSizes[instanceGroup.PersistentDiskType]
+	}
+
+	return persistentDiskSizes
+}
+
+func (f *Fetcher) fetchDiskTypeSizes(deployment director.Deployment) (map[string]uint64, error) {
+	cloudConfig, err := f.fetchCloudConfig(deployment)
+	if err != nil {
+		return map[string]uint64{}, err
+	}
+
+	return f.diskTypeSizes(cloudConfig), nil
+}
+
+func (f *Fetcher) fetchCloudConfig(deployment director.Deployment) (*deploymentCloudConfig, error) {
+	log.Debugf("Reading Cloud Config for deployment `%s`:", deployment.Name())
+	cloudConfig, err := deployment.CloudConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading Cloud Config for deployment `%s`: %v", deployment.Name(), err)
+	}
+
+	var parsedCloudConfig deploymentCloudConfig
+	if err := yaml.Unmarshal([]byte(cloudConfig), &parsedCloudConfig); err != nil {
+		return nil, fmt.Errorf("Error while parsing Cloud Config for deployment `%s`: %v", deployment.Name(), err)
+	}
+
+	return &parsedCloudConfig, nil
+}
+
+func (f *Fetcher) diskTypeSizes(cloudConfig *deploymentCloudConfig) map[string]uint64 {
+	diskTypeSizes := map[string]uint64{}
+	for _, diskType := range cloudConfig.DiskTypes {
+		diskTypeSizes[diskType.Name] = diskType.DiskSize
+	}
+
+	return diskTypeSizes
+}
+
+// azAttributes returns the scalar cloud properties of every AZ defined in the
+// Cloud Config as `az_cloud_properties.<key>` attributes.
+func (f *Fetcher) azAttributes(cloudConfig *deploymentCloudConfig) map[string]map[string]string {
+	azAttributes := map[string]map[string]string{}
+	if cloudConfig == nil {
+		return azAttributes
+	}
+
+	for _, az := range cloudConfig.AZs {
+		attributes := map[string]string{}
+		f.flattenAttributes(attributes, strings.TrimSuffix(filters.AZCloudPropertiesAttributePrefix, "."), normalizeYAML(az.CloudProperties))
+		azAttributes[az.Name] = attributes
+	}
+
+	return azAttributes
+}
+
+func (f *Fetcher) instanceAttributes(instance director.VMInfo) map[string]string {
+	attributes := map[string]string{
+		"agent_id":            instance.AgentID,
+		"vm_cid":              instance.VMID,
+		"vm_type":             f.interner.intern(instance.VMType),
+		"resource_pool":       f.interner.intern(instance.ResourcePool),
+		"az":                  f.interner.intern(instance.AZ),
+		"bootstrap":           strconv.FormatBool(instance.Bootstrap),
+		"resurrection_paused": strconv.FormatBool(instance.ResurrectionPaused),
+	}
+	f.flattenAttributes(attributes, "cloud_properties", instance.CloudProperties)
+
+	return attributes
+}
+
+func (f *Fetcher) flattenAttributes(attributes map[string]string, prefix string, value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			f.flattenAttributes(attributes, f.interner.intern(prefix+"."+k), v)
+		}
+	case string:
+		attributes[prefix] = f.interner.intern(value)
+	case float64:
+		attributes[prefix] = f.interner.intern(strconv.FormatFloat(value, 'f', -1, 64))
+	case bool:
+		attributes[prefix] = strconv.FormatBool(value)
+	}
+}
+
+func (f *Fetcher) processJobTemplate(processName string, jobTemplates []string) string {
+	for _, jobTemplate := range jobTemplates {
+		if jobTemplate == processName {
+			return jobTemplate
+		}
+	}
+
+	if len(jobTemplates) == 1 {
+		return jobTemplates[0]
+	}
+
+	return ""
+}
+
+func (f *Fetcher) vitalValue(value string) string {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "%"))
+
+	switch strings.ToLower(value) {
+	case "n/a", "na", "-":
+		return ""
+	}
+
+	return value
+}
+
+func (f *Fetcher) loadAvgValues(load []string) []string {
+	if len(load) == 0 {
+		return load
+	}
+
+	loadAvg := make([]string, 3)
+	for i := 0; i < len(load) && i < len(loadAvg); i++ {
+		loadAvg[i] = f.vitalValue(load[i])
+	}
+
+	return loadAvg
+}
+
 func (f *Fetcher) fetchDeploymentReleases(deployment director.Deployment) ([]Release, error) {
 	deploymentReleases := []Release{}
 
@@ -199,3 +652,113 @@ func (f *Fetcher) fetchDeploymentStemcells(deployment director.Deployment) ([]St
 
 	return deploymentStemcells, nil
 }
+
+func (f *Fetcher) fetchDeploymentConfigs(deployment director.Deployment) ([]Config, error) {
+	var deploymentConfigs []Config
+
+	log.Debugf("Reading Configs for deployment `%s`:", deployment.Name())
+	configs, err := f.boshClient.ListDeploymentConfigs(deployment.Name())
+	if err != nil {
+		return deploymentConfigs, fmt.Errorf("Error while reading Configs for deployment `%s`: %v", deployment.Name(), err)
+	}
+
+	for _, config := range configs.GetConfigs() {
+		deploymentConfigs = append(deploymentConfigs, Config{
+			ID:   strconv.Itoa(config.Id),
+			Type: f.interner.intern(config.Type),
+			Name: f.interner.intern(config.Name),
+		})
+	}
+
+	return deploymentConfigs, nil
+}
+
+// fetchLatestConfigs returns the ID of the latest version of every config,
+// keyed by <type>/<name>.
+func (f *Fetcher) fetchLatestConfigs() (map[string]string, error) {
+	latestConfigs := map[string]string{}
+
+	log.Debugf("Reading latest Configs:")
+	configs, err := f.boshClient.ListConfigs(1, director.ConfigsFilter{})
+	if err != nil {
+		return latestConfigs, fmt.Errorf("Error while reading latest Configs: %v", err)
+	}
+
+	for _, config := range configs {
+		latestConfigs[config.Type+"/"+config.Name] = config.ID
+	}
+
+	return latestConfigs, nil
+}
+
+func (f *Fetcher) fetchUploadedStemcells() (map[string][]uploadedStemcell, error) {
+	log.Debugf("Reading uploaded Stemcells:")
+	stemcells, err := f.boshClient.Stemcells()
+	if err != nil {
+		return map[string][]uploadedStemcell{}, fmt.Errorf("Error while reading uploaded Stemcells: %v", err)
+	}
+
+	return f.stemcellUploads.record(f.boshClient, stemcells, f.clock.Now()), nil
+}
+
+// stemcellWithLatestVersion completes the deployed stemcell with the latest
+// uploaded version, the number of newer uploaded versions, and the number of
+// days since the first of them was uploaded.
+func (f *Fetcher) stemcellWithLatestVersion(stemcell Stemcell, uploadedStemcells []uploadedStemcell, now time.Time) Stemcell {
+	if len(uploadedStemcells) == 0 {
+		return stemcell
+	}
+
+	deployedVersion, err := semver.NewVersionFromString(stemcell.Version)
+	if err != nil {
+		log.Errorf("Error while parsing Stemcell `%s` version `%s`: %v", stemcell.Name, stemcell.Version, err)
+		return stemcell
+	}
+
+	latestVersion := deployedVersion
+	var behindSince time.Time
+	for _, uploaded := range uploadedStemcells {
+		if uploaded.version.IsGt(deployedVersion) {
+			stemcell.VersionsBehind++
+			if behindSince.IsZero() || uploaded.uploadedAt.Before(behindSince) {
+				behindSince = uploaded.uploadedAt
+			}
+		}
+		if uploaded.version.IsGt(latestVersion) {
+			latestVersion = uploaded.version
+		}
+	}
+	stemcell.LatestVersion = latestVersion.AsString()
+	if !behindSince.IsZero() && now.After(behindSince) {
+		stemcell.DaysBehind = int(now.Sub(behindSince) / (24 * time.Hour))
+	}
+
+	return stemcell
+}
+
+// normalizeYAML converts the maps and integers decoded from YAML to the JSON
+// types handled by flattenAttributes.
+func normalizeYAML(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		normalized := map[string]interface{}{}
+		for k, v := range value {
+			normalized[k] = normalizeYAML(v)
+		}
+		return normalized
+	case map[interface{}]interface{}:
+		normalized := map[string]interface{}{}
+		for k, v := range value {
+			normalized[fmt.Sprintf("%v", k)] = normalizeYAML(v)
+		}
+		return normalized
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case uint64:
+		return float64(value)
+	default:
+		return value
+	}
+}
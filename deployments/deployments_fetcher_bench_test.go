@@ -0,0 +1,114 @@
+package deployments
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/director/directorfakes"
+
+	"github.com/bosh-prometheus/bosh_exporter/filters"
+)
+
+const (
+	benchmarkProcessesPerInstance = 10
+	benchmarkInstancesPerDeploy   = 100
+)
+
+var benchmarkSizes = []int{10000, 50000}
+
+// freshString allocates a new copy of s, as decoding the BOSH API responses does.
+func freshString(s string) string {
+	return string([]byte(s))
+}
+
+func benchmarkInstanceInfos(deployment int) []director.VMInfo {
+	instances := []director.VMInfo{}
+
+	for i := 0; i < benchmarkInstancesPerDeploy; i++ {
+		index := i
+		processes := []director.VMInfoProcess{}
+		for p := 0; p < benchmarkProcessesPerInstance; p++ {
+			processes = append(processes, director.VMInfoProcess{
+				Name:  fmt.Sprintf("process-%d", p),
+				State: freshString("running"),
+			})
+		}
+
+		instances = append(instances, director.VMInfo{
+			AgentID:      fmt.Sprintf("agent-%d-%d", deployment, i),
+			JobName:      fmt.Sprintf("job-%d", i%10),
+			ID:           fmt.Sprintf("id-%d-%d", deployment, i),
+			Index:        &index,
+			ProcessState: freshString("running"),
+			IPs:          []string{fmt.Sprintf("10.%d.%d.%d", deployment/256%256, deployment%256, i)},
+			AZ:           fmt.Sprintf("z%d", i%3+1),
+			VMID:         fmt.Sprintf("vm-%d-%d", deployment, i),
+			VMType:       freshString("small"),
+			ResourcePool: freshString("small"),
+			CloudProperties: map[string]interface{}{
+				"instance_type": freshString("m5.large"),
+			},
+			Processes: processes,
+		})
+	}
+
+	return instances
+}
+
+func benchmarkFetcher(processes int) *Fetcher {
+	deployments := []director.Deployment{}
+	for d := 0; d < processes/benchmarkProcessesPerInstance/benchmarkInstancesPerDeploy; d++ {
+		d := d
+		deployments = append(deployments, &directorfakes.FakeDeployment{
+			NameStub:          func() string { return fmt.Sprintf("deployment-%d", d) },
+			InstanceInfosStub: func() ([]director.VMInfo, error) { return benchmarkInstanceInfos(d), nil },
+		})
+	}
+
+	boshClient := &directorfakes.FakeDirector{}
+	boshClient.DeploymentsReturns(deployments, nil)
+
+	return NewFetcher(*filters.NewDeploymentsFilter([]string{}, boshClient), &filters.ExpressionFilter{}, boshClient, 0, false)
+}
+
+func benchmarkDeploymentsHeap(b *testing.B, processes int, interning bool) {
+	fetcher := benchmarkFetcher(processes)
+	if !interning {
+		fetcher.interner = nil
+	}
+
+	var snapshots [2][]DeploymentInfo
+	var heapInUse uint64
+	var memStats runtime.MemStats
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		deploymentsInfo, err := fetcher.Deployments()
+		if err != nil {
+			b.Fatal(err)
+		}
+		snapshots[i%2] = deploymentsInfo
+
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&memStats)
+		heapInUse += memStats.HeapInuse
+		b.StartTimer()
+	}
+
+	b.ReportMetric(float64(heapInUse)/float64(b.N), "heap-bytes")
+	runtime.KeepAlive(snapshots)
+}
+
+func BenchmarkFetcherDeployments(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		for _, interning := range []bool{false, true} {
+			b.Run(fmt.Sprintf("processes=%d/interning=%t", size, interning), func(b *testing.B) {
+				benchmarkDeploymentsHeap(b, size, interning)
+			})
+		}
+	}
+}
@@ -3,6 +3,7 @@ package deployments_test
 import (
 	"errors"
 	"strconv"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -12,6 +13,7 @@ import (
 	"github.com/cppforlife/go-semi-semantic/version"
 	"github.com/prometheus/common/log"
 
+	"github.com/bosh-prometheus/bosh_exporter/clock"
 	"github.com/bosh-prometheus/bosh_exporter/filters"
 
 	. "github.com/bosh-prometheus/bosh_exporter/deployments"
@@ -27,17 +29,39 @@ var _ = Describe("Fetcher", func() {
 		boshDeployments    []string
 		boshClient         *directorfakes.FakeDirector
 		deploymentsFilter  *filters.DeploymentsFilter
+		expression         string
+		expressionFilter   *filters.ExpressionFilter
 		deploymentsFetcher *Fetcher
+
+		problemsScanInterval   time.Duration
+		fetchAZCloudProperties bool
+		fetchSpread            time.Duration
+		compareLatestVersions  bool
+		fakeClock              *clock.FakeClock
+		vmInfoExtensions       *VMInfoExtensions
 	)
 
 	BeforeEach(func() {
 		boshDeployments = []string{}
+		expression = ""
+		problemsScanInterval = 0
+		fetchAZCloudProperties = false
+		fetchSpread = 0
+		compareLatestVersions = true
+		fakeClock = clock.NewFakeClock(time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC))
 		boshClient = &directorfakes.FakeDirector{}
+		vmInfoExtensions = NewVMInfoExtensions()
 	})
 
 	JustBeforeEach(func() {
 		deploymentsFilter = filters.NewDeploymentsFilter(boshDeployments, boshClient)
-		deploymentsFetcher = NewFetcher(*deploymentsFilter)
+		expressionFilter, err = filters.NewExpressionFilter(expression)
+		Expect(err).ToNot(HaveOccurred())
+		deploymentsFetcher = NewFetcher(*deploymentsFilter, expressionFilter, boshClient, problemsScanInterval, fetchAZCloudProperties)
+		deploymentsFetcher.SetFetchSpread(fetchSpread)
+		deploymentsFetcher.SetClock(fakeClock)
+		deploymentsFetcher.SetVMInfoExtensions(vmInfoExtensions)
+		deploymentsFetcher.SetCompareLatestVersions(compareLatestVersions)
 	})
 
 	Describe("Deployments", func() {
@@ -54,6 +78,7 @@ var _ = Describe("Fetcher", func() {
 			jobResourcePool               = "fake-job-resource-pool"
 			jobResurrectionPause          = true
 			jobVMID                       = "fake-job-vmid"
+			jobVMCreatedAt                = time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
 			processState                  = "running"
 			jobUptimeSeconds              = uint64(3600)
 			jobLoadAvg01                  = float64(0.01)
@@ -161,8 +186,14 @@ var _ = Describe("Fetcher", func() {
 					ResourcePool:       jobResourcePool,
 					ResurrectionPaused: jobResurrectionPause,
 					VMID:               jobVMID,
+					VMCreatedAt:        jobVMCreatedAt,
 					Vitals:             vitals,
 					Processes:          processes,
+					CloudProperties: map[string]interface{}{
+						"instance_type":   "m4.large",
+						"ephemeral_disk":  map[string]interface{}{"size": float64(10240)},
+						"security_groups": []interface{}{"bosh"},
+					},
 				},
 			}
 
@@ -203,8 +234,22 @@ var _ = Describe("Fetcher", func() {
 							AZ:                 jobAZ,
 							VMType:             jobVMType,
 							ResourcePool:       jobResourcePool,
+							VMID:               jobVMID,
+							VMCreatedAt:        jobVMCreatedAt,
 							ResurrectionPaused: jobResurrectionPause,
 							Healthy:            true,
+							Attributes: map[string]string{
+								"agent_id":                             agentID,
+								"vm_cid":                               jobVMID,
+								"vm_type":                              jobVMType,
+								"resource_pool":                        jobResourcePool,
+								"az":                                   jobAZ,
+								"bootstrap":                            "true",
+								"resurrection_paused":                  "true",
+								"stemcell":                             stemcellName + "/" + stemcellVersion,
+								"cloud_properties.instance_type":       "m4.large",
+								"cloud_properties.ephemeral_disk.size": "10240",
+							},
 							Processes: []Process{
 								Process{
 									Name:    jobProcessName,
@@ -255,12 +300,17 @@ var _ = Describe("Fetcher", func() {
 					Stemcells: []Stemcell{
 						Stemcell{Name: stemcellName, Version: stemcellVersion, OSName: stemcellOSName},
 					},
+					APICallsTotal: 5,
 				},
 			}
 		})
 
 		JustBeforeEach(func() {
 			deploymentsInfo, err = deploymentsFetcher.Deployments()
+			for i := range deploymentsInfo {
+				Expect(deploymentsInfo[i].FetchDuration).To(BeNumerically(">", 0))
+				deploymentsInfo[i].FetchDuration = 0
+			}
 		})
 
 		It("returns the deployments", func() {
@@ -268,6 +318,86 @@ var _ = Describe("Fetcher", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("accumulates the API calls sent for every deployment", func() {
+			deploymentsInfo, err = deploymentsFetcher.Deployments()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentsInfo[0].APICallsTotal).To(Equal(uint64(10)))
+		})
+
+		It("iterates over the same deployments one at a time", func() {
+			iteratedDeploymentsInfo := []DeploymentInfo{}
+			eachDeployment, err := deploymentsFetcher.StreamDeployments()
+			Expect(err).ToNot(HaveOccurred())
+			err = eachDeployment(func(deploymentInfo DeploymentInfo) error {
+				deploymentInfo.FetchDuration = 0
+				iteratedDeploymentsInfo = append(iteratedDeploymentsInfo, deploymentInfo)
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			expectedDeploymentsInfo[0].APICallsTotal = 10
+			Expect(iteratedDeploymentsInfo).To(Equal(expectedDeploymentsInfo))
+		})
+
+		It("stops iterating at the first error", func() {
+			eachDeployment, err := deploymentsFetcher.StreamDeployments()
+			Expect(err).ToNot(HaveOccurred())
+			err = eachDeployment(func(deploymentInfo DeploymentInfo) error {
+				return errors.New("stop")
+			})
+			Expect(err).To(MatchError("stop"))
+		})
+
+		Context("when the agent reports the CPU steal and the process CPU allocation", func() {
+			var (
+				processCPULimit     = float64(200)
+				processCPUThrottled = float64(12.5)
+			)
+
+			BeforeEach(func() {
+				readThrough(vmInfoExtensions, "https://director/tasks/1/output?type=result", `{"agent_id":"`+agentID+`","vitals":{"cpu":{"steal":"2.5"}},"processes":[{"name":"`+jobProcessName+`","cpu":{"limit":200,"throttled":12.5}}]}`)
+			})
+
+			It("returns them", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentsInfo[0].Instances[0].Vitals.CPU.Steal).To(Equal("2.5"))
+				Expect(deploymentsInfo[0].Instances[0].Processes[0].CPU).To(Equal(CPU{
+					Total:     &jobProcessCPUTotal,
+					Limit:     &processCPULimit,
+					Throttled: &processCPUThrottled,
+				}))
+			})
+		})
+
+		Context("when the agent reports the process ports", func() {
+			BeforeEach(func() {
+				readThrough(vmInfoExtensions, "https://director/tasks/1/output?type=result", `{"agent_id":"`+agentID+`","processes":[{"name":"`+jobProcessName+`","ports":[9100,9101]}]}`)
+			})
+
+			It("returns them", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentsInfo[0].Instances[0].Processes[0].Ports).To(Equal([]int{9100, 9101}))
+			})
+
+			It("does not return them again without a new task result", func() {
+				deploymentsInfo, err = deploymentsFetcher.Deployments()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentsInfo[0].Instances[0].Processes[0].Ports).To(BeNil())
+			})
+		})
+
+		Context("when the Director reports the VM creator and trusted certificates", func() {
+			BeforeEach(func() {
+				readThrough(vmInfoExtensions, "https://director/tasks/1/output?type=result", `{"agent_id":"`+agentID+`","created_by":"fake-created-by","trusted_certs_sha1":"fake-trusted-certs-sha1"}`)
+			})
+
+			It("returns them", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentsInfo[0].Instances[0].VMCreatedBy).To(Equal("fake-created-by"))
+				Expect(deploymentsInfo[0].Instances[0].TrustedCertsSHA1).To(Equal("fake-trusted-certs-sha1"))
+			})
+		})
+
 		Context("when instance has no VMID", func() {
 			BeforeEach(func() {
 				instances[0].VMID = ""
@@ -286,6 +416,479 @@ var _ = Describe("Fetcher", func() {
 			})
 		})
 
+		Context("when every instance was stopped", func() {
+			BeforeEach(func() {
+				instances[0].State = "stopped"
+				instances[0].ProcessState = "stopped"
+			})
+
+			It("returns the deployment as intentionally stopped", func() {
+				Expect(deploymentsInfo[0].Instances[0].Stopped).To(BeTrue())
+				Expect(deploymentsInfo[0].IntentionallyStopped()).To(BeTrue())
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		It("does not return the deployment as intentionally stopped", func() {
+			Expect(deploymentsInfo[0].IntentionallyStopped()).To(BeFalse())
+		})
+
+		Context("when instance is a Windows VM", func() {
+			BeforeEach(func() {
+				instances[0].Vitals = director.VMInfoVitals{
+					CPU: director.VMInfoVitalsCPU{
+						Sys:  "12.5%",
+						User: "N/A",
+					},
+					Mem: director.VMInfoVitalsMemSize{
+						KB:      strconv.Itoa(jobMemKB),
+						Percent: " 25 % ",
+					},
+					Load: []string{""},
+					Disk: map[string]director.VMInfoVitalsDiskSize{
+						"system": director.VMInfoVitalsDiskSize{
+							Percent: "30",
+						},
+					},
+				}
+				instances[0].Processes = []director.VMInfoProcess{
+					{
+						Name:  jobProcessName,
+						State: jobProcessState,
+					},
+				}
+			})
+
+			It("returns the available vitals", func() {
+				Expect(deploymentsInfo[0].Instances[0].Vitals).To(Equal(Vitals{
+					CPU: CPU{
+						Sys: "12.5",
+					},
+					Mem: Mem{
+						KB:      strconv.Itoa(jobMemKB),
+						Percent: "25",
+					},
+					Load: []string{"", "", ""},
+					SystemDisk: Disk{
+						Percent: "30",
+					},
+				}))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns the processes without the missing vitals", func() {
+				Expect(deploymentsInfo[0].Instances[0].Processes).To(Equal([]Process{
+					Process{
+						Name:    jobProcessName,
+						Healthy: true,
+					},
+				}))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the manifest declares the instance group jobs", func() {
+			BeforeEach(func() {
+				deployment.(*directorfakes.FakeDeployment).ManifestReturns(`
+instance_groups:
+- name: fake-job-name
+  jobs:
+  - name: fake-other-job-template
+  - name: fake-process-name
+`, nil)
+			})
+
+			It("returns the job template owning the process", func() {
+				Expect(deploymentsInfo[0].Instances[0].Processes[0].JobTemplate).To(Equal(jobProcessName))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the instance group has a single job", func() {
+			BeforeEach(func() {
+				deployment.(*directorfakes.FakeDeployment).ManifestReturns(`
+instance_groups:
+- name: fake-job-name
+  jobs:
+  - name: fake-job-template
+`, nil)
+			})
+
+			It("returns the job as the process job template", func() {
+				Expect(deploymentsInfo[0].Instances[0].Processes[0].JobTemplate).To(Equal("fake-job-template"))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the instance group has a persistent disk", func() {
+			BeforeEach(func() {
+				deployment.(*directorfakes.FakeDeployment).ManifestReturns(`
+instance_groups:
+- name: fake-job-name
+  persistent_disk: 10240
+`, nil)
+			})
+
+			It("returns the persistent disk size", func() {
+				Expect(deploymentsInfo[0].Instances[0].PersistentDiskSizeMB).To(Equal(uint64(10240)))
+				Expect(deployment.(*directorfakes.FakeDeployment).CloudConfigCallCount()).To(Equal(0))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the instance group has a persistent disk type", func() {
+			BeforeEach(func() {
+				deployment.(*directorfakes.FakeDeployment).ManifestReturns(`
+instance_groups:
+- name: fake-job-name
+  persistent_disk_type: large
+`, nil)
+				deployment.(*directorfakes.FakeDeployment).CloudConfigReturns(`
+disk_types:
+- name: small
+  disk_size: 1024
+- name: large
+  disk_size: 51200
+`, nil)
+			})
+
+			It("returns the persistent disk size from the cloud config", func() {
+				Expect(deploymentsInfo[0].Instances[0].PersistentDiskSizeMB).To(Equal(uint64(51200)))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			Context("and it fails to get the cloud config", func() {
+				BeforeEach(func() {
+					deployment.(*directorfakes.FakeDeployment).CloudConfigReturns("", errors.New("no cloud config"))
+				})
+
+				It("returns the instances without a persistent disk size", func() {
+					Expect(deploymentsInfo[0].Instances[0].PersistentDiskSizeMB).To(BeZero())
+					Expect(err).ToNot(HaveOccurred())
+				})
+			})
+		})
+
+		Context("when the manifest declares tags", func() {
+			BeforeEach(func() {
+				deployment.(*directorfakes.FakeDeployment).ManifestReturns(`
+tags:
+  cost_center: cc-1234
+  owner: platform-team
+  retention_days: 30
+instance_groups:
+- name: fake-job-name
+`, nil)
+			})
+
+			It("attaches the tags to the instances", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentsInfo[0].Instances[0].Attributes).To(HaveKeyWithValue("tags.cost_center", "cc-1234"))
+				Expect(deploymentsInfo[0].Instances[0].Attributes).To(HaveKeyWithValue("tags.owner", "platform-team"))
+				Expect(deploymentsInfo[0].Instances[0].Attributes).To(HaveKeyWithValue("tags.retention_days", "30"))
+			})
+		})
+
+		Context("when the AZ cloud properties are fetched", func() {
+			BeforeEach(func() {
+				fetchAZCloudProperties = true
+				deployment.(*directorfakes.FakeDeployment).ManifestReturns(`
+instance_groups:
+- name: fake-job-name
+  persistent_disk_type: large
+`, nil)
+				deployment.(*directorfakes.FakeDeployment).CloudConfigReturns(`
+azs:
+- name: fake-job-az
+  cloud_properties:
+    datacenter: dc1
+    fault_domain: 2
+    datastores:
+      primary: ds1
+- name: fake-other-az
+  cloud_properties:
+    datacenter: dc2
+disk_types:
+- name: large
+  disk_size: 51200
+`, nil)
+			})
+
+			It("attaches the AZ cloud properties to the instances", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentsInfo[0].Instances[0].Attributes).To(HaveKeyWithValue("az_cloud_properties.datacenter", "dc1"))
+				Expect(deploymentsInfo[0].Instances[0].Attributes).To(HaveKeyWithValue("az_cloud_properties.fault_domain", "2"))
+				Expect(deploymentsInfo[0].Instances[0].Attributes).To(HaveKeyWithValue("az_cloud_properties.datastores.primary", "ds1"))
+			})
+
+			It("reads the cloud config once", func() {
+				Expect(deploymentsInfo[0].Instances[0].PersistentDiskSizeMB).To(Equal(uint64(51200)))
+				Expect(deployment.(*directorfakes.FakeDeployment).CloudConfigCallCount()).To(Equal(1))
+			})
+
+			Context("and it fails to get the cloud config", func() {
+				BeforeEach(func() {
+					deployment.(*directorfakes.FakeDeployment).CloudConfigReturns("", errors.New("no cloud config"))
+				})
+
+				It("returns the instances without AZ cloud properties", func() {
+					Expect(err).ToNot(HaveOccurred())
+					Expect(deploymentsInfo[0].Instances[0].Attributes).ToNot(HaveKey("az_cloud_properties.datacenter"))
+				})
+			})
+		})
+
+		Context("when the AZ cloud properties are not fetched", func() {
+			It("does not read the cloud config", func() {
+				Expect(deployment.(*directorfakes.FakeDeployment).CloudConfigCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when it fails to get the manifest", func() {
+			BeforeEach(func() {
+				deployment.(*directorfakes.FakeDeployment).ManifestReturns("", errors.New("no manifest"))
+			})
+
+			It("returns the processes without a job template", func() {
+				Expect(deploymentsInfo).To(Equal(expectedDeploymentsInfo))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when there are newer uploaded stemcells", func() {
+			var uploadedStemcells []director.Stemcell
+
+			BeforeEach(func() {
+				uploadedStemcells = []director.Stemcell{}
+				for _, uploadedVersion := range []string{"4.5.5", stemcellVersion, "4.5.7", "4.5.10"} {
+					uploadedVersion := uploadedVersion
+					uploadedStemcells = append(uploadedStemcells, &directorfakes.FakeStemcell{
+						NameStub:    func() string { return stemcellName },
+						VersionStub: func() version.Version { return version.MustNewVersionFromString(uploadedVersion) },
+					})
+				}
+				uploadedStemcells = append(uploadedStemcells, &directorfakes.FakeStemcell{
+					NameStub:    func() string { return "fake-other-stemcell-name" },
+					VersionStub: func() version.Version { return version.MustNewVersionFromString("9.9.9") },
+				})
+				boshClient.StemcellsReturns(uploadedStemcells, nil)
+			})
+
+			It("returns the latest uploaded version and the number of versions behind", func() {
+				Expect(deploymentsInfo[0].Stemcells).To(Equal([]Stemcell{
+					Stemcell{
+						Name:           stemcellName,
+						Version:        stemcellVersion,
+						OSName:         stemcellOSName,
+						LatestVersion:  "4.5.10",
+						VersionsBehind: 2,
+					},
+				}))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("iterates over the deployments with the latest uploaded version", func() {
+				eachDeployment, err := deploymentsFetcher.StreamDeployments()
+				Expect(err).ToNot(HaveOccurred())
+				err = eachDeployment(func(deploymentInfo DeploymentInfo) error {
+					Expect(deploymentInfo.Stemcells[0].LatestVersion).To(Equal("4.5.10"))
+					return nil
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns the number of days since the first newer version was first seen", func() {
+				fakeClock.Advance(3 * 24 * time.Hour)
+				deploymentsInfo, err = deploymentsFetcher.Deployments()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentsInfo[0].Stemcells[0].DaysBehind).To(Equal(3))
+				Expect(boshClient.RecentTasksCallCount()).To(Equal(1))
+			})
+
+			Context("and they are uploaded for several CPIs", func() {
+				BeforeEach(func() {
+					boshClient.StemcellsReturns(append(uploadedStemcells, uploadedStemcells...), nil)
+				})
+
+				It("counts every version once", func() {
+					Expect(deploymentsInfo[0].Stemcells[0].VersionsBehind).To(Equal(2))
+				})
+			})
+
+			Context("and their upload tasks are among the recent tasks", func() {
+				BeforeEach(func() {
+					uploadTask := &directorfakes.FakeTask{}
+					uploadTask.StateReturns("done")
+					uploadTask.DescriptionReturns("create stemcell")
+					uploadTask.ResultReturns("/stemcells/" + stemcellName + "/4.5.7")
+					uploadTask.FinishedAtReturns(fakeClock.Now().Add(-10 * 24 * time.Hour))
+					boshClient.RecentTasksReturns([]director.Task{uploadTask}, nil)
+				})
+
+				It("returns the number of days since the first newer version was uploaded", func() {
+					Expect(deploymentsInfo[0].Stemcells[0].DaysBehind).To(Equal(10))
+				})
+			})
+
+			Context("and the latest versions are not compared", func() {
+				BeforeEach(func() {
+					compareLatestVersions = false
+				})
+
+				It("does not read the uploaded stemcells", func() {
+					Expect(deploymentsInfo).To(Equal(expectedDeploymentsInfo))
+					Expect(boshClient.StemcellsCallCount()).To(Equal(0))
+					Expect(boshClient.ListConfigsCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when it fails to get the uploaded stemcells", func() {
+			BeforeEach(func() {
+				boshClient.StemcellsReturns(nil, errors.New("no stemcells"))
+			})
+
+			It("returns the deployments without the latest stemcell version", func() {
+				Expect(deploymentsInfo).To(Equal(expectedDeploymentsInfo))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the deployment was deployed with configs", func() {
+			BeforeEach(func() {
+				boshClient.ListDeploymentConfigsReturns(director.DeploymentConfigs{
+					Configs: []director.DeploymentConfig{
+						{Config: director.DeploymentConfigProperties{Id: 3, Type: "cloud", Name: "default"}},
+						{Config: director.DeploymentConfigProperties{Id: 7, Type: "runtime", Name: "dns"}},
+					},
+				}, nil)
+				boshClient.ListConfigsReturns([]director.Config{
+					{ID: "5", Type: "cloud", Name: "default"},
+					{ID: "7", Type: "runtime", Name: "dns"},
+				}, nil)
+			})
+
+			It("returns the deployed and the latest config IDs", func() {
+				Expect(deploymentsInfo[0].Configs).To(Equal([]Config{
+					Config{ID: "3", Type: "cloud", Name: "default", LatestID: "5"},
+					Config{ID: "7", Type: "runtime", Name: "dns", LatestID: "7"},
+				}))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("reads the deployment configs and the latest configs", func() {
+				Expect(boshClient.ListDeploymentConfigsArgsForCall(0)).To(Equal(deploymentName))
+				limit, filter := boshClient.ListConfigsArgsForCall(0)
+				Expect(limit).To(Equal(1))
+				Expect(filter).To(Equal(director.ConfigsFilter{}))
+			})
+
+			Context("and it fails to get the latest configs", func() {
+				BeforeEach(func() {
+					boshClient.ListConfigsReturns(nil, errors.New("no configs"))
+				})
+
+				It("returns the deployed configs without the latest config IDs", func() {
+					Expect(deploymentsInfo[0].Configs).To(Equal([]Config{
+						Config{ID: "3", Type: "cloud", Name: "default"},
+						Config{ID: "7", Type: "runtime", Name: "dns"},
+					}))
+					Expect(err).ToNot(HaveOccurred())
+				})
+			})
+		})
+
+		Context("when it fails to get the deployment configs", func() {
+			BeforeEach(func() {
+				boshClient.ListDeploymentConfigsReturns(director.DeploymentConfigs{}, errors.New("no deployment configs"))
+			})
+
+			It("returns the deployments without configs", func() {
+				Expect(deploymentsInfo).To(Equal(expectedDeploymentsInfo))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when problems scanning is enabled", func() {
+			var (
+				deploymentFake *directorfakes.FakeDeployment
+			)
+
+			BeforeEach(func() {
+				problemsScanInterval = time.Hour
+				deploymentFake = deployment.(*directorfakes.FakeDeployment)
+				deploymentFake.ScanForProblemsReturns([]director.Problem{
+					{ID: 1, Type: "unresponsive_agent", Description: "api/1 is not responding"},
+				}, nil)
+			})
+
+			It("scans the deployment in the background and returns the problems on the next fetch", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentsInfo[0].ProblemsScanned).To(BeFalse())
+				Eventually(deploymentFake.ScanForProblemsCallCount).Should(Equal(1))
+
+				Eventually(func() bool {
+					deploymentsInfo, err = deploymentsFetcher.Deployments()
+					return deploymentsInfo[0].ProblemsScanned
+				}).Should(BeTrue())
+				Expect(deploymentsInfo[0].Problems).To(Equal([]Problem{
+					{Type: "unresponsive_agent", Description: "api/1 is not responding"},
+				}))
+				Expect(deploymentFake.ScanForProblemsCallCount()).To(Equal(1))
+			})
+
+			Context("and the scan fails", func() {
+				BeforeEach(func() {
+					deploymentFake.ScanForProblemsReturns(nil, errors.New("deployment is locked"))
+				})
+
+				It("does not return problems", func() {
+					Eventually(deploymentFake.ScanForProblemsCallCount).Should(Equal(1))
+					Consistently(func() bool {
+						deploymentsInfo, err = deploymentsFetcher.Deployments()
+						return deploymentsInfo[0].ProblemsScanned
+					}).Should(BeFalse())
+					Expect(deploymentsInfo[0].Problems).To(BeEmpty())
+				})
+			})
+		})
+
+		Context("when the fetches are spread", func() {
+			var (
+				deploymentFake *directorfakes.FakeDeployment
+			)
+
+			BeforeEach(func() {
+				fetchSpread = time.Hour
+				deploymentFake = deployment.(*directorfakes.FakeDeployment)
+			})
+
+			It("returns the last fetched deployment until its next slot", func() {
+				Expect(deploymentFake.InstanceInfosCallCount()).To(Equal(1))
+
+				deploymentsInfo, err = deploymentsFetcher.Deployments()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentsInfo[0].Instances).To(Equal(expectedDeploymentsInfo[0].Instances))
+				Expect(deploymentFake.InstanceInfosCallCount()).To(Equal(1))
+
+				fakeClock.Set(FetchSlot(deploymentName, fetchSpread, fakeClock.Now()).Add(fetchSpread))
+				deploymentsInfo, err = deploymentsFetcher.Deployments()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentFake.InstanceInfosCallCount()).To(Equal(2))
+			})
+		})
+
+		Context("when the filter expression rejects the deployment", func() {
+			BeforeEach(func() {
+				expression = `deployment != "fake-deployment-name"`
+			})
+
+			It("does not return the deployment", func() {
+				Expect(deploymentsInfo).To(BeEmpty())
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
 		Context("when there are no deployments", func() {
 			BeforeEach(func() {
 				boshClient.DeploymentsReturns([]director.Deployment{}, nil)
@@ -306,6 +909,11 @@ var _ = Describe("Fetcher", func() {
 				Expect(deploymentsInfo).To(BeEmpty())
 				Expect(err).To(HaveOccurred())
 			})
+
+			It("does not stream deployments", func() {
+				_, err = deploymentsFetcher.StreamDeployments()
+				Expect(err).To(HaveOccurred())
+			})
 		})
 
 		Context("when there are no instances", func() {
@@ -407,4 +1015,87 @@ var _ = Describe("Fetcher", func() {
 			})
 		})
 	})
+
+	Describe("FetchSlot", func() {
+		var (
+			now = time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+		)
+
+		It("returns the last slot of the deployment within a period", func() {
+			slot := FetchSlot("cf", time.Hour, now)
+			Expect(slot).ToNot(BeTemporally(">", now))
+			Expect(now.Sub(slot)).To(BeNumerically("<", time.Hour))
+			Expect(FetchSlot("cf", time.Hour, slot)).To(Equal(slot))
+			Expect(FetchSlot("cf", time.Hour, slot.Add(-time.Nanosecond))).To(Equal(slot.Add(-time.Hour)))
+		})
+
+		It("spreads the deployments over the period", func() {
+			Expect(FetchSlot("cf", time.Hour, now)).ToNot(Equal(FetchSlot("redis", time.Hour, now)))
+		})
+	})
+
+	Describe("ScanProblems", func() {
+		var (
+			deploymentName = "fake-deployment-name"
+			deployment     *directorfakes.FakeDeployment
+			scanStarted    chan bool
+			scanReleased   chan bool
+		)
+
+		BeforeEach(func() {
+			scanStarted = make(chan bool, 1)
+			scanReleased = make(chan bool)
+			started, released := scanStarted, scanReleased
+			deployment = &directorfakes.FakeDeployment{
+				NameStub: func() string { return deploymentName },
+				ScanForProblemsStub: func() ([]director.Problem, error) {
+					started <- true
+					<-released
+					return []director.Problem{{Type: "missing_vm"}}, nil
+				},
+			}
+			boshClient.DeploymentsReturns([]director.Deployment{deployment}, nil)
+		})
+
+		AfterEach(func() {
+			close(scanReleased)
+		})
+
+		It("queues a problem scan of the deployment", func() {
+			Expect(deploymentsFetcher.ScanProblems(deploymentName)).To(Succeed())
+			Eventually(scanStarted).Should(Receive())
+		})
+
+		It("returns an error when a scan of the deployment is in progress", func() {
+			Expect(deploymentsFetcher.ScanProblems(deploymentName)).To(Succeed())
+			Eventually(scanStarted).Should(Receive())
+			Expect(deploymentsFetcher.ScanProblems(deploymentName)).To(Equal(ErrProblemsScanInProgress))
+		})
+
+		It("returns the problems found on the next fetch", func() {
+			Expect(deploymentsFetcher.ScanProblems(deploymentName)).To(Succeed())
+			Eventually(scanStarted).Should(Receive())
+			scanReleased <- true
+
+			Eventually(func() []Problem {
+				deploymentsInfo, _ := deploymentsFetcher.Deployments()
+				return deploymentsInfo[0].Problems
+			}).Should(Equal([]Problem{{Type: "missing_vm"}}))
+		})
+
+		It("returns an error when the deployment does not exist", func() {
+			Expect(deploymentsFetcher.ScanProblems("fake-other-deployment")).To(Equal(ErrDeploymentNotFound))
+		})
+
+		Context("when the filter expression rejects the deployment", func() {
+			BeforeEach(func() {
+				expression = `deployment != "fake-deployment-name"`
+			})
+
+			It("returns an error", func() {
+				Expect(deploymentsFetcher.ScanProblems(deploymentName)).To(Equal(ErrDeploymentNotFound))
+				Consistently(scanStarted).ShouldNot(Receive())
+			})
+		})
+	})
 })
@@ -0,0 +1,92 @@
+package deployments
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+)
+
+type scheduledFetch struct {
+	deploymentInfo DeploymentInfo
+	fetchedAt      time.Time
+}
+
+// fetchSchedule spreads the deployment fetches over a period instead of
+// fetching every deployment on every scrape: a deployment is fetched again
+// once the slot derived from a hash of its name has passed since its last
+// fetch, and its last fetched info is reused in between, so the BOSH Director
+// load is flat across the period instead of spiking at every scrape.
+type fetchSchedule struct {
+	spread  time.Duration
+	clock   clock.Clock
+	fetched map[string]scheduledFetch
+	mu      *sync.Mutex
+}
+
+func newFetchSchedule(spread time.Duration) *fetchSchedule {
+	return &fetchSchedule{
+		spread:  spread,
+		clock:   clock.Real,
+		fetched: map[string]scheduledFetch{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+// FetchSlot returns the last time, at or before now, a deployment is due to be
+// fetched when its fetches are spread over a period. The slots of a deployment
+// are a period apart, at an offset within the period derived from its name.
+func FetchSlot(deploymentName string, spread time.Duration, now time.Time) time.Time {
+	hash := fnv.New64a()
+	hash.Write([]byte(deploymentName))
+	offset := time.Duration(hash.Sum64() % uint64(spread))
+
+	slot := now.Truncate(spread).Add(offset)
+	if slot.After(now) {
+		slot = slot.Add(-spread)
+	}
+
+	return slot
+}
+
+// cached returns the last fetched info of a deployment unless it is due to be
+// fetched again.
+func (s *fetchSchedule) cached(deploymentName string) (DeploymentInfo, bool) {
+	if s.spread <= 0 {
+		return DeploymentInfo{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fetched, ok := s.fetched[deploymentName]
+	if !ok || fetched.fetchedAt.Before(FetchSlot(deploymentName, s.spread, s.clock.Now())) {
+		return DeploymentInfo{}, false
+	}
+
+	return fetched.deploymentInfo, true
+}
+
+func (s *fetchSchedule) store(deploymentInfo DeploymentInfo) {
+	if s.spread <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fetched[deploymentInfo.Name] = scheduledFetch{deploymentInfo: deploymentInfo, fetchedAt: s.clock.Now()}
+}
+
+// forget drops the deployments that are gone, or failed to be fetched.
+func (s *fetchSchedule) forget(seenDeployments map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for deploymentName := range s.fetched {
+		if !seenDeployments[deploymentName] {
+			delete(s.fetched, deploymentName)
+		}
+	}
+}
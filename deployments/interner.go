@@ -0,0 +1,49 @@
+package deployments
+
+import (
+	"sync"
+)
+
+type stringInterner struct {
+	mu       *sync.Mutex
+	current  map[string]string
+	previous map[string]string
+}
+
+func newStringInterner() *stringInterner {
+	return &stringInterner{mu: &sync.Mutex{}, current: map[string]string{}, previous: map[string]string{}}
+}
+
+func (i *stringInterner) intern(s string) string {
+	if i == nil || s == "" {
+		return s
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if interned, ok := i.current[s]; ok {
+		return interned
+	}
+	if interned, ok := i.previous[s]; ok {
+		i.current[s] = interned
+		return interned
+	}
+	i.current[s] = s
+
+	return s
+}
+
+// rotate drops the strings that were not interned since the previous rotation,
+// so names of deleted deployments, jobs or processes are eventually released.
+func (i *stringInterner) rotate() {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.previous = i.current
+	i.current = make(map[string]string, len(i.previous))
+}
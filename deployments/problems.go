@@ -0,0 +1,112 @@
+package deployments
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/prometheus/common/log"
+)
+
+var (
+	ErrDeploymentNotFound     = errors.New("Deployment not found")
+	ErrProblemsScanInProgress = errors.New("A problem scan is already in progress for this deployment")
+)
+
+type problemsScan struct {
+	problems  []Problem
+	scanned   bool
+	scannedAt time.Time
+	scanning  bool
+}
+
+// problemsScanner runs the BOSH Director problem scans (the ones behind
+// `bosh cck --report`) in the background, at most once per interval for each
+// deployment, so a scrape never waits for a scan task to finish.
+type problemsScanner struct {
+	interval time.Duration
+	scans    map[string]*problemsScan
+	mu       *sync.Mutex
+}
+
+func newProblemsScanner(interval time.Duration) *problemsScanner {
+	return &problemsScanner{
+		interval: interval,
+		scans:    map[string]*problemsScan{},
+		mu:       &sync.Mutex{},
+	}
+}
+
+func (s *problemsScanner) problems(deployment director.Deployment) ([]Problem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scan, ok := s.scans[deployment.Name()]
+	if !ok {
+		if s.interval <= 0 {
+			return nil, false
+		}
+		scan = &problemsScan{}
+		s.scans[deployment.Name()] = scan
+	}
+
+	if s.interval > 0 && !scan.scanning && time.Since(scan.scannedAt) >= s.interval {
+		scan.scanning = true
+		go s.scan(deployment, scan)
+	}
+
+	return scan.problems, scan.scanned
+}
+
+func (s *problemsScanner) trigger(deployment director.Deployment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scan, ok := s.scans[deployment.Name()]
+	if !ok {
+		scan = &problemsScan{}
+		s.scans[deployment.Name()] = scan
+	}
+
+	if scan.scanning {
+		return ErrProblemsScanInProgress
+	}
+	scan.scanning = true
+	go s.scan(deployment, scan)
+
+	return nil
+}
+
+func (s *problemsScanner) scan(deployment director.Deployment, scan *problemsScan) {
+	log.Debugf("Scanning problems for deployment `%s`:", deployment.Name())
+	problems, err := deployment.ScanForProblems()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scan.scanning = false
+	scan.scannedAt = time.Now()
+	if err != nil {
+		log.Error(fmt.Errorf("Error while scanning problems for deployment `%s`: %v", deployment.Name(), err))
+		return
+	}
+
+	scan.problems = make([]Problem, 0, len(problems))
+	for _, problem := range problems {
+		scan.problems = append(scan.problems, Problem{Type: problem.Type, Description: problem.Description})
+	}
+	scan.scanned = true
+}
+
+func (s *problemsScanner) forget(seenDeployments map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for deploymentName, scan := range s.scans {
+		if !seenDeployments[deploymentName] && !scan.scanning {
+			delete(s.scans, deploymentName)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package deployments
+
+import (
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	semver "github.com/cppforlife/go-semi-semantic/version"
+	"github.com/prometheus/common/log"
+)
+
+const stemcellUploadTasksLimit = 200
+
+var stemcellUploadResultRegexp = regexp.MustCompile(`^/stemcells/([^/]+)/([^/]+)$`)
+
+type uploadedStemcell struct {
+	version    semver.Version
+	uploadedAt time.Time
+}
+
+// stemcellUploads remembers when each uploaded stemcell version was uploaded.
+// The Director does not report the stemcell upload dates, so they are read
+// from the upload tasks still among its recent tasks, or else are the time the
+// exporter first saw the stemcell.
+type stemcellUploads struct {
+	uploadedAt map[string]time.Time
+	mu         *sync.Mutex
+}
+
+func newStemcellUploads() *stemcellUploads {
+	return &stemcellUploads{
+		uploadedAt: map[string]time.Time{},
+		mu:         &sync.Mutex{},
+	}
+}
+
+// record returns the uploaded stemcells by name, once per version even when
+// the stemcell is uploaded for several CPIs, along with their upload time.
+func (u *stemcellUploads) record(boshClient director.Director, stemcells []director.Stemcell, now time.Time) map[string][]uploadedStemcell {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	uploadedStemcells := map[string][]uploadedStemcell{}
+	seenStemcells := map[string]bool{}
+	unknownStemcells := map[string]bool{}
+	for _, stemcell := range stemcells {
+		key := stemcell.Name() + "/" + stemcell.Version().AsString()
+		if seenStemcells[key] {
+			continue
+		}
+		seenStemcells[key] = true
+		if _, ok := u.uploadedAt[key]; !ok {
+			unknownStemcells[key] = true
+		}
+		uploadedStemcells[stemcell.Name()] = append(uploadedStemcells[stemcell.Name()], uploadedStemcell{version: stemcell.Version()})
+	}
+
+	if len(unknownStemcells) > 0 {
+		for key, uploadedAt := range u.readUploadTasks(boshClient) {
+			if unknownStemcells[key] {
+				u.uploadedAt[key] = uploadedAt
+				delete(unknownStemcells, key)
+			}
+		}
+	}
+	for key := range unknownStemcells {
+		u.uploadedAt[key] = now
+	}
+	for key := range u.uploadedAt {
+		if !seenStemcells[key] {
+			delete(u.uploadedAt, key)
+		}
+	}
+
+	for name, versions := range uploadedStemcells {
+		for i, uploaded := range versions {
+			versions[i].uploadedAt = u.uploadedAt[name+"/"+uploaded.version.AsString()]
+		}
+	}
+
+	return uploadedStemcells
+}
+
+func (u *stemcellUploads) readUploadTasks(boshClient director.Director) map[string]time.Time {
+	uploads := map[string]time.Time{}
+
+	log.Debugf("Reading Stemcell upload Tasks:")
+	tasks, err := boshClient.RecentTasks(stemcellUploadTasksLimit, director.TasksFilter{})
+	if err != nil {
+		log.Errorf("Error while reading Stemcell upload Tasks: %v", err)
+		return uploads
+	}
+
+	for _, task := range tasks {
+		if task.State() != "done" || task.Description() != "create stemcell" {
+			continue
+		}
+		match := stemcellUploadResultRegexp.FindStringSubmatch(task.Result())
+		if match == nil {
+			continue
+		}
+		version, err := semver.NewVersionFromString(match[2])
+		if err != nil {
+			continue
+		}
+		key := match[1] + "/" + version.AsString()
+		if uploadedAt, ok := uploads[key]; !ok || task.FinishedAt().Before(uploadedAt) {
+			uploads[key] = task.FinishedAt()
+		}
+	}
+
+	return uploads
+}
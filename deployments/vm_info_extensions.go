@@ -0,0 +1,125 @@
+package deployments
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sync"
+)
+
+var taskOutputPathRegexp = regexp.MustCompile(`/tasks/\d+/output$`)
+
+// VMInfoExtensions captures the instance fields reported by recent BOSH
+// Agents that the bosh-cli VMInfo does not decode, reading them from the task
+// results sent through the transport it wraps. The fetcher takes them by
+// agent ID once the bosh-cli has decoded the same result.
+type VMInfoExtensions struct {
+	mu        *sync.Mutex
+	byAgentID map[string]vmInfoExtension
+}
+
+type vmInfoExtension struct {
+	AgentID          string `json:"agent_id"`
+	CreatedBy        string `json:"created_by"`
+	TrustedCertsSHA1 string `json:"trusted_certs_sha1"`
+	Vitals           struct {
+		CPU struct {
+			Steal string `json:"steal"`
+		} `json:"cpu"`
+	} `json:"vitals"`
+	Processes []vmInfoProcessExtension `json:"processes"`
+}
+
+type vmInfoProcessExtension struct {
+	Name  string `json:"name"`
+	Ports []int  `json:"ports"`
+	CPU   struct {
+		// cgroup CPU allocation and throttled periods percentage
+		Limit     *float64 `json:"limit"`
+		Throttled *float64 `json:"throttled"`
+	} `json:"cpu"`
+}
+
+func NewVMInfoExtensions() *VMInfoExtensions {
+	return &VMInfoExtensions{mu: &sync.Mutex{}, byAgentID: map[string]vmInfoExtension{}}
+}
+
+// Wrap returns a transport capturing the extensions of the task results read
+// through transport.
+func (e *VMInfoExtensions) Wrap(transport http.RoundTripper) http.RoundTripper {
+	return &vmInfoExtensionsTransport{transport: transport, extensions: e}
+}
+
+// take returns and forgets the extension captured for the agent, so the
+// extensions of deleted VMs are not kept.
+func (e *VMInfoExtensions) take(agentID string) vmInfoExtension {
+	if e == nil || agentID == "" {
+		return vmInfoExtension{}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	extension := e.byAgentID[agentID]
+	delete(e.byAgentID, agentID)
+
+	return extension
+}
+
+func (e *VMInfoExtensions) capture(result []byte) {
+	extensions := []vmInfoExtension{}
+	scanner := bufio.NewScanner(bytes.NewReader(result))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(result)+1)
+	for scanner.Scan() {
+		var extension vmInfoExtension
+		if err := json.Unmarshal(scanner.Bytes(), &extension); err != nil || extension.AgentID == "" {
+			continue
+		}
+		extensions = append(extensions, extension)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, extension := range extensions {
+		e.byAgentID[extension.AgentID] = extension
+	}
+}
+
+func (x vmInfoExtension) process(name string) vmInfoProcessExtension {
+	for _, process := range x.Processes {
+		if process.Name == name {
+			return process
+		}
+	}
+
+	return vmInfoProcessExtension{}
+}
+
+type vmInfoExtensionsTransport struct {
+	transport  http.RoundTripper
+	extensions *VMInfoExtensions
+}
+
+func (t *vmInfoExtensionsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || req.Method != http.MethodGet {
+		return resp, err
+	}
+	if !taskOutputPathRegexp.MatchString(req.URL.Path) || req.URL.Query().Get("type") != "result" {
+		return resp, nil
+	}
+
+	result, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(result))
+	t.extensions.capture(result)
+
+	return resp, nil
+}
@@ -0,0 +1,54 @@
+package deployments_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/deployments"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// readThrough reads url through transport wrapped by extensions, the Director
+// answering body.
+func readThrough(extensions *VMInfoExtensions, url string, body string) string {
+	transport := extensions.Wrap(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	Expect(err).ToNot(HaveOccurred())
+	resp, err := transport.RoundTrip(req)
+	Expect(err).ToNot(HaveOccurred())
+	read, err := ioutil.ReadAll(resp.Body)
+	Expect(err).ToNot(HaveOccurred())
+
+	return string(read)
+}
+
+var _ = Describe("VMInfoExtensions", func() {
+	var (
+		extensions *VMInfoExtensions
+		result     = `{"agent_id":"fake-agent-id","processes":[{"name":"fake-process-name","ports":[9100]}]}` + "\n"
+	)
+
+	BeforeEach(func() {
+		extensions = NewVMInfoExtensions()
+	})
+
+	It("passes the task results through unchanged", func() {
+		Expect(readThrough(extensions, "https://director/tasks/1/output?type=result", result)).To(Equal(result))
+	})
+
+	It("passes the other responses through unchanged", func() {
+		Expect(readThrough(extensions, "https://director/tasks/1/output?type=debug", "fake-debug")).To(Equal("fake-debug"))
+	})
+})
@@ -0,0 +1,173 @@
+package environments
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// EnvironmentChanges are the changes of an environment, matched by URL,
+// between two configs. Credentials are never reported, only whether any of
+// them changed.
+type EnvironmentChanges struct {
+	Environment        string              `json:"environment"`
+	URL                string              `json:"url"`
+	Added              bool                `json:"added,omitempty"`
+	Removed            bool                `json:"removed,omitempty"`
+	FiltersAdded       map[string][]string `json:"filters_added,omitempty"`
+	FiltersRemoved     map[string][]string `json:"filters_removed,omitempty"`
+	CredentialsRotated bool                `json:"credentials_rotated"`
+	Changed            []string            `json:"changed,omitempty"`
+}
+
+// Hash returns the hex SHA-256 of the environments, to tell configs apart.
+// The credentials are left out, so the hash can be exposed without giving an
+// offline brute-force oracle of the secrets: rotating credentials does not
+// change it.
+func Hash(environments []Environment) string {
+	redacted := make([]Environment, len(environments))
+	for i, environment := range environments {
+		environment.Username = ""
+		environment.Password = ""
+		environment.UAAClientID = ""
+		environment.UAAClientSecret = ""
+		environment.OIDCClientID = ""
+		environment.OIDCClientSecret = ""
+		redacted[i] = environment
+	}
+
+	content, _ := json.Marshal(redacted)
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Diff returns the changes of the environments that were added, removed or
+// changed from previous to next, in the order of the next config followed by
+// the removed environments.
+func Diff(previous []Environment, next []Environment) []EnvironmentChanges {
+	previousByURL := map[string]Environment{}
+	for _, environment := range previous {
+		previousByURL[environment.URL] = environment
+	}
+
+	changes := []EnvironmentChanges{}
+	seen := map[string]bool{}
+	for _, environment := range next {
+		seen[environment.URL] = true
+		previousEnvironment, ok := previousByURL[environment.URL]
+		if !ok {
+			changes = append(changes, EnvironmentChanges{
+				Environment:  environment.Environment,
+				URL:          environment.URL,
+				Added:        true,
+				FiltersAdded: diffFilters(Filters{}, environment.Filters),
+			})
+			continue
+		}
+
+		environmentChanges := diffEnvironment(previousEnvironment, environment)
+		if len(environmentChanges.FiltersAdded) > 0 || len(environmentChanges.FiltersRemoved) > 0 || environmentChanges.CredentialsRotated || len(environmentChanges.Changed) > 0 {
+			changes = append(changes, environmentChanges)
+		}
+	}
+
+	for _, environment := range previous {
+		if seen[environment.URL] {
+			continue
+		}
+		changes = append(changes, EnvironmentChanges{
+			Environment:    environment.Environment,
+			URL:            environment.URL,
+			Removed:        true,
+			FiltersRemoved: diffFilters(environment.Filters, Filters{}),
+		})
+	}
+
+	return changes
+}
+
+func diffEnvironment(previous Environment, next Environment) EnvironmentChanges {
+	changes := EnvironmentChanges{
+		Environment:    next.Environment,
+		URL:            next.URL,
+		FiltersAdded:   diffFilters(previous.Filters, next.Filters),
+		FiltersRemoved: diffFilters(next.Filters, previous.Filters),
+		CredentialsRotated: previous.Username != next.Username ||
+			previous.Password != next.Password ||
+			previous.UAAClientID != next.UAAClientID ||
+			previous.UAAClientSecret != next.UAAClientSecret ||
+			previous.OIDCClientID != next.OIDCClientID ||
+			previous.OIDCClientSecret != next.OIDCClientSecret,
+	}
+
+	fields := []struct {
+		name     string
+		previous interface{}
+		next     interface{}
+	}{
+		{"environment", previous.Environment, next.Environment},
+		{"oidc_issuer_url", previous.OIDCIssuerURL, next.OIDCIssuerURL},
+		{"oidc_audience", previous.OIDCAudience, next.OIDCAudience},
+		{"oidc_scopes", previous.OIDCScopes, next.OIDCScopes},
+		{"oidc_grant_type", previous.OIDCGrantType, next.OIDCGrantType},
+		{"oidc_subject_token_file", previous.OIDCSubjectTokenFile, next.OIDCSubjectTokenFile},
+		{"ca_cert_files", previous.CACerts(), next.CACerts()},
+		{"use_system_cas", previous.UseSystemCAs, next.UseSystemCAs},
+		{"sd_filename", previous.SDFilename, next.SDFilename},
+	}
+	for _, field := range fields {
+		if !reflect.DeepEqual(field.previous, field.next) {
+			changes.Changed = append(changes.Changed, field.name)
+		}
+	}
+
+	return changes
+}
+
+// diffFilters returns the filter values of next missing from previous, by
+// filter name, or nil when there are none.
+func diffFilters(previous Filters, next Filters) map[string][]string {
+	added := map[string][]string{}
+	previousValues := filterValues(previous)
+	for name, values := range filterValues(next) {
+		existing := map[string]bool{}
+		for _, value := range previousValues[name] {
+			existing[value] = true
+		}
+		for _, value := range values {
+			if !existing[value] {
+				added[name] = append(added[name], value)
+			}
+		}
+		sort.Strings(added[name])
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	return added
+}
+
+func filterValues(filters Filters) map[string][]string {
+	values := map[string][]string{
+		"deployments": filters.Deployments,
+		"azs":         filters.AZs,
+		"collectors":  filters.Collectors,
+		"cidrs":       filters.CIDRs,
+	}
+	for name, value := range map[string]string{
+		"processes_regexp":     filters.ProcessesRegexp,
+		"deployment_processes": filters.DeploymentProcesses,
+		"expression":           filters.Expression,
+		"profile":              filters.Profile,
+	} {
+		if value != "" {
+			values[name] = []string{value}
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,121 @@
+package environments_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/environments"
+)
+
+var _ = Describe("Diff", func() {
+	var (
+		previous []Environment
+		next     []Environment
+	)
+
+	BeforeEach(func() {
+		previous = []Environment{
+			{
+				Environment: "production",
+				URL:         "https://10.0.0.6:25555",
+				Username:    "admin",
+				Password:    "secret",
+				CACertFile:  "/etc/ssl/ca.crt",
+				Filters:     Filters{Deployments: []string{"cf", "redis"}, Expression: `az == "z1"`},
+			},
+			{
+				Environment: "staging",
+				URL:         "https://10.1.0.6:25555",
+			},
+		}
+		next = []Environment{
+			{
+				Environment: "production",
+				URL:         "https://10.0.0.6:25555",
+				Username:    "admin",
+				Password:    "rotated",
+				CACertFiles: []string{"/etc/ssl/ca.crt", "/etc/ssl/rotated-ca.crt"},
+				Filters:     Filters{Deployments: []string{"cf", "mysql"}, Expression: `az == "z1"`},
+			},
+			{
+				Environment: "development",
+				URL:         "https://10.2.0.6:25555",
+				Filters:     Filters{AZs: []string{"z1"}},
+			},
+		}
+	})
+
+	It("returns the changed, added and removed environments", func() {
+		Expect(Diff(previous, next)).To(Equal([]EnvironmentChanges{
+			{
+				Environment:        "production",
+				URL:                "https://10.0.0.6:25555",
+				FiltersAdded:       map[string][]string{"deployments": {"mysql"}},
+				FiltersRemoved:     map[string][]string{"deployments": {"redis"}},
+				CredentialsRotated: true,
+				Changed:            []string{"ca_cert_files"},
+			},
+			{
+				Environment:  "development",
+				URL:          "https://10.2.0.6:25555",
+				Added:        true,
+				FiltersAdded: map[string][]string{"azs": {"z1"}},
+			},
+			{
+				Environment: "staging",
+				URL:         "https://10.1.0.6:25555",
+				Removed:     true,
+			},
+		}))
+	})
+
+	It("returns no changes for the same config", func() {
+		Expect(Diff(previous, previous)).To(BeEmpty())
+	})
+
+	It("reports a changed single-valued filter as removed and added", func() {
+		next = []Environment{previous[0], previous[1]}
+		next[0].Filters.Expression = `az == "z2"`
+
+		Expect(Diff(previous, next)).To(Equal([]EnvironmentChanges{
+			{
+				Environment:    "production",
+				URL:            "https://10.0.0.6:25555",
+				FiltersAdded:   map[string][]string{"expression": {`az == "z2"`}},
+				FiltersRemoved: map[string][]string{"expression": {`az == "z1"`}},
+			},
+		}))
+	})
+})
+
+var _ = Describe("Hash", func() {
+	var (
+		environments []Environment
+	)
+
+	BeforeEach(func() {
+		environments = []Environment{{Environment: "production", URL: "https://10.0.0.6:25555", Password: "secret"}}
+	})
+
+	It("changes with the config", func() {
+		hash := Hash(environments)
+		Expect(hash).To(HaveLen(64))
+		Expect(Hash(environments)).To(Equal(hash))
+
+		environments[0].Filters.Deployments = []string{"cf"}
+		Expect(Hash(environments)).ToNot(Equal(hash))
+	})
+
+	It("does not depend on the credentials", func() {
+		hash := Hash(environments)
+
+		environments[0].Username = "admin"
+		environments[0].Password = "rotated"
+		environments[0].UAAClientID = "exporter"
+		environments[0].UAAClientSecret = "rotated"
+		environments[0].OIDCClientID = "exporter"
+		environments[0].OIDCClientSecret = "rotated"
+		Expect(Hash(environments)).To(Equal(hash))
+		Expect(environments[0].Password).To(Equal("rotated"))
+	})
+})
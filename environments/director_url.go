@@ -0,0 +1,109 @@
+package environments
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultDirectorPort      = 25555
+	defaultDirectorProxyPort = 443
+)
+
+type DirectorURL struct {
+	Host       string
+	Port       int
+	PathPrefix string
+}
+
+func ParseDirectorURL(rawURL string) (DirectorURL, error) {
+	if rawURL == "" {
+		return DirectorURL{}, errors.New("Expected non-empty BOSH Director URL")
+	}
+
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return DirectorURL{}, errors.New(fmt.Sprintf("Error parsing BOSH Director URL `%s`: %v", rawURL, err))
+	}
+
+	if parsedURL.Scheme != "https" {
+		return DirectorURL{}, errors.New(fmt.Sprintf("BOSH Director URL `%s` must use the https scheme", rawURL))
+	}
+
+	if parsedURL.Hostname() == "" {
+		return DirectorURL{}, errors.New(fmt.Sprintf("BOSH Director URL `%s` does not contain a host", rawURL))
+	}
+
+	directorURL := DirectorURL{
+		Host:       parsedURL.Hostname(),
+		Port:       defaultDirectorPort,
+		PathPrefix: strings.TrimSuffix(parsedURL.Path, "/"),
+	}
+
+	if parsedURL.Port() != "" {
+		directorURL.Port, err = strconv.Atoi(parsedURL.Port())
+		if err != nil {
+			return DirectorURL{}, errors.New(fmt.Sprintf("Error parsing BOSH Director URL `%s` port: %v", rawURL, err))
+		}
+	} else if directorURL.PathPrefix != "" {
+		directorURL.Port = defaultDirectorProxyPort
+	}
+
+	return directorURL, nil
+}
+
+func (d DirectorURL) String() string {
+	return fmt.Sprintf("https://%s%s", net.JoinHostPort(d.Host, strconv.Itoa(d.Port)), d.PathPrefix)
+}
+
+func ResolveDirectorURL(directorURL DirectorURL, httpClient *http.Client) (DirectorURL, error) {
+	infoURL := directorURL.String() + "/info"
+
+	resp, err := httpClient.Get(infoURL)
+	if err != nil {
+		return DirectorURL{}, errors.New(fmt.Sprintf("Error while resolving BOSH Director URL `%s`: %v", directorURL, err))
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return DirectorURL{}, errors.New(fmt.Sprintf("Error while resolving BOSH Director URL `%s`: %s", directorURL, resp.Status))
+	}
+
+	finalURL := resp.Request.URL
+	if finalURL.String() == infoURL {
+		return directorURL, nil
+	}
+
+	if !strings.HasSuffix(finalURL.Path, "/info") {
+		return DirectorURL{}, errors.New(fmt.Sprintf("BOSH Director URL `%s` redirected to `%s`, which is not a BOSH Director info endpoint", directorURL, finalURL))
+	}
+
+	if finalURL.Scheme != "https" {
+		return DirectorURL{}, errors.New(fmt.Sprintf("BOSH Director URL `%s` redirected to `%s`, which does not use the https scheme", directorURL, finalURL))
+	}
+
+	resolvedURL := DirectorURL{
+		Host:       finalURL.Hostname(),
+		Port:       defaultDirectorProxyPort,
+		PathPrefix: strings.TrimSuffix(finalURL.Path, "/info"),
+	}
+	if finalURL.Port() != "" {
+		resolvedURL.Port, err = strconv.Atoi(finalURL.Port())
+		if err != nil {
+			return DirectorURL{}, errors.New(fmt.Sprintf("Error parsing BOSH Director URL `%s` port: %v", finalURL, err))
+		}
+	}
+
+	return resolvedURL, nil
+}
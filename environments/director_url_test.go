@@ -0,0 +1,115 @@
+package environments_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/environments"
+)
+
+var _ = Describe("DirectorURL", func() {
+	Describe("ParseDirectorURL", func() {
+		It("defaults to the BOSH Director port", func() {
+			directorURL, err := ParseDirectorURL("https://10.0.0.6")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(directorURL).To(Equal(DirectorURL{Host: "10.0.0.6", Port: 25555}))
+		})
+
+		It("accepts a host without scheme", func() {
+			directorURL, err := ParseDirectorURL("10.0.0.6:25555")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(directorURL).To(Equal(DirectorURL{Host: "10.0.0.6", Port: 25555}))
+		})
+
+		It("accepts an alternate port", func() {
+			directorURL, err := ParseDirectorURL("https://director.example.com:8443/")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(directorURL).To(Equal(DirectorURL{Host: "director.example.com", Port: 8443}))
+		})
+
+		It("accepts a path prefix and defaults to the https port", func() {
+			directorURL, err := ParseDirectorURL("https://proxy.example.com/bosh/")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(directorURL).To(Equal(DirectorURL{Host: "proxy.example.com", Port: 443, PathPrefix: "/bosh"}))
+			Expect(directorURL.String()).To(Equal("https://proxy.example.com:443/bosh"))
+		})
+
+		It("returns an error when the scheme is not https", func() {
+			_, err := ParseDirectorURL("http://10.0.0.6:25555")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("must use the https scheme"))
+		})
+
+		It("returns an error when the URL is empty", func() {
+			_, err := ParseDirectorURL("")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("ResolveDirectorURL", func() {
+		var (
+			server      *httptest.Server
+			directorURL DirectorURL
+		)
+
+		BeforeEach(func() {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/api/bosh/info", http.StatusMovedPermanently)
+			})
+			mux.HandleFunc("/api/bosh/info", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"name":"fake-bosh-name"}`))
+			})
+			mux.HandleFunc("/broken/info", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/login", http.StatusTemporaryRedirect)
+			})
+			mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`login`))
+			})
+			server = httptest.NewTLSServer(mux)
+
+			serverURL, _ := url.Parse(server.URL)
+			port, _ := strconv.Atoi(serverURL.Port())
+			directorURL = DirectorURL{Host: serverURL.Hostname(), Port: port}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("follows redirects to the BOSH Director info endpoint", func() {
+			resolvedURL, err := ResolveDirectorURL(directorURL, server.Client())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resolvedURL).To(Equal(DirectorURL{Host: directorURL.Host, Port: directorURL.Port, PathPrefix: "/api/bosh"}))
+		})
+
+		It("keeps the URL when there is no redirect", func() {
+			directorURL.PathPrefix = "/api/bosh"
+
+			resolvedURL, err := ResolveDirectorURL(directorURL, server.Client())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resolvedURL).To(Equal(directorURL))
+		})
+
+		It("returns an error when redirected away from the info endpoint", func() {
+			directorURL.PathPrefix = "/broken"
+
+			_, err := ResolveDirectorURL(directorURL, server.Client())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("not a BOSH Director info endpoint"))
+		})
+
+		It("returns an error when the endpoint does not exist", func() {
+			directorURL.PathPrefix = "/missing"
+
+			_, err := ResolveDirectorURL(directorURL, server.Client())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("404 Not Found"))
+		})
+	})
+})
@@ -0,0 +1,158 @@
+package environments
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+type Config struct {
+	Environments []Environment `yaml:"environments"`
+}
+
+type Environment struct {
+	Environment          string   `yaml:"environment"`
+	URL                  string   `yaml:"url"`
+	Username             string   `yaml:"username"`
+	Password             string   `yaml:"password"`
+	UAAClientID          string   `yaml:"uaa_client_id"`
+	UAAClientSecret      string   `yaml:"uaa_client_secret"`
+	OIDCIssuerURL        string   `yaml:"oidc_issuer_url"`
+	OIDCClientID         string   `yaml:"oidc_client_id"`
+	OIDCClientSecret     string   `yaml:"oidc_client_secret"`
+	OIDCAudience         string   `yaml:"oidc_audience"`
+	OIDCScopes           []string `yaml:"oidc_scopes"`
+	OIDCGrantType        string   `yaml:"oidc_grant_type"`
+	OIDCSubjectTokenFile string   `yaml:"oidc_subject_token_file"`
+	CACertFile           string   `yaml:"ca_cert_file"`
+	CACertFiles          []string `yaml:"ca_cert_files"`
+	UseSystemCAs         bool     `yaml:"use_system_cas"`
+	SDFilename           string   `yaml:"sd_filename"`
+	Filters              Filters  `yaml:"filters"`
+}
+
+// CACerts returns every CA certificate file configured for the environment.
+func (e Environment) CACerts() []string {
+	caCerts := []string{}
+	if e.CACertFile != "" {
+		caCerts = append(caCerts, e.CACertFile)
+	}
+
+	return append(caCerts, e.CACertFiles...)
+}
+
+type Filters struct {
+	Deployments         []string `yaml:"deployments"`
+	AZs                 []string `yaml:"azs"`
+	Collectors          []string `yaml:"collectors"`
+	CIDRs               []string `yaml:"cidrs"`
+	ProcessesRegexp     string   `yaml:"processes_regexp"`
+	DeploymentProcesses string   `yaml:"deployment_processes"`
+	Expression          string   `yaml:"expression"`
+	Profile             string   `yaml:"profile"`
+}
+
+func (f Filters) WithDefaults(defaults Filters) Filters {
+	if f.Deployments == nil {
+		f.Deployments = defaults.Deployments
+	}
+	if f.AZs == nil {
+		f.AZs = defaults.AZs
+	}
+	if f.Collectors == nil {
+		f.Collectors = defaults.Collectors
+	}
+	if f.CIDRs == nil {
+		f.CIDRs = defaults.CIDRs
+	}
+	if f.ProcessesRegexp == "" {
+		f.ProcessesRegexp = defaults.ProcessesRegexp
+	}
+	if f.DeploymentProcesses == "" {
+		f.DeploymentProcesses = defaults.DeploymentProcesses
+	}
+	if f.Expression == "" {
+		f.Expression = defaults.Expression
+	}
+	if f.Profile == "" {
+		f.Profile = defaults.Profile
+	}
+
+	return f
+}
+
+func LoadConfig(filename string) (*Config, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error reading environments config `%s`: %v", filename, err))
+	}
+
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing environments config `%s`: %v", filename, err))
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (c *Config) Validate() error {
+	if len(c.Environments) == 0 {
+		return errors.New("Environments config does not contain any environment")
+	}
+
+	urls := map[string]bool{}
+	for i, environment := range c.Environments {
+		if environment.URL == "" {
+			return errors.New(fmt.Sprintf("Environment #%d does not have an `url`", i+1))
+		}
+		if urls[environment.URL] {
+			return errors.New(fmt.Sprintf("Environment url `%s` is duplicated", environment.URL))
+		}
+		urls[environment.URL] = true
+	}
+
+	return nil
+}
+
+type LabelSet struct {
+	Environment string
+	BoshName    string
+	BoshUUID    string
+}
+
+func ValidateLabelSets(labelSets []LabelSet) error {
+	seen := map[LabelSet]bool{}
+
+	for _, labelSet := range labelSets {
+		if seen[labelSet] {
+			return errors.New(fmt.Sprintf(
+				"More than one BOSH Director exports metrics with environment `%s`, bosh_name `%s` and bosh_uuid `%s`",
+				labelSet.Environment,
+				labelSet.BoshName,
+				labelSet.BoshUUID,
+			))
+		}
+		seen[labelSet] = true
+	}
+
+	return nil
+}
+
+func ValidateSDFilenames(sdFilenames []string) error {
+	seen := map[string]bool{}
+
+	for _, sdFilename := range sdFilenames {
+		if seen[sdFilename] {
+			return errors.New(fmt.Sprintf("More than one BOSH Director writes Service Discovery output to `%s`", sdFilename))
+		}
+		seen[sdFilename] = true
+	}
+
+	return nil
+}
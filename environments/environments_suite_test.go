@@ -0,0 +1,13 @@
+package environments_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEnvironments(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Environments Suite")
+}
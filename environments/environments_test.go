@@ -0,0 +1,215 @@
+package environments_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/environments"
+)
+
+var _ = Describe("Environments", func() {
+	Describe("LoadConfig", func() {
+		var (
+			err      error
+			filename string
+			content  string
+			config   *Config
+		)
+
+		BeforeEach(func() {
+			content = `
+environments:
+- environment: production
+  url: https://10.0.0.6:25555
+  username: admin
+  password: secret
+  ca_cert_file: /var/vcap/jobs/bosh_exporter/config/ca.crt
+  sd_filename: /tmp/production_bosh_target_groups.json
+  filters:
+    deployments: [cf]
+    cidrs: [10.0.0.0/8]
+- url: https://10.1.0.6:25555
+  uaa_client_id: bosh_exporter
+  uaa_client_secret: secret
+  ca_cert_files: [/etc/ssl/internal-ca.crt, /etc/ssl/rotated-ca.crt]
+  use_system_cas: true
+`
+		})
+
+		JustBeforeEach(func() {
+			tmpfile, tmpErr := ioutil.TempFile("", "environments_test_")
+			Expect(tmpErr).ToNot(HaveOccurred())
+			_, tmpErr = tmpfile.Write([]byte(content))
+			Expect(tmpErr).ToNot(HaveOccurred())
+			Expect(tmpfile.Close()).To(Succeed())
+			filename = tmpfile.Name()
+
+			config, err = LoadConfig(filename)
+		})
+
+		AfterEach(func() {
+			os.Remove(filename)
+		})
+
+		It("returns the environments", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Environments).To(Equal([]Environment{
+				{
+					Environment: "production",
+					URL:         "https://10.0.0.6:25555",
+					Username:    "admin",
+					Password:    "secret",
+					CACertFile:  "/var/vcap/jobs/bosh_exporter/config/ca.crt",
+					SDFilename:  "/tmp/production_bosh_target_groups.json",
+					Filters: Filters{
+						Deployments: []string{"cf"},
+						CIDRs:       []string{"10.0.0.0/8"},
+					},
+				},
+				{
+					URL:             "https://10.1.0.6:25555",
+					UAAClientID:     "bosh_exporter",
+					UAAClientSecret: "secret",
+					CACertFiles:     []string{"/etc/ssl/internal-ca.crt", "/etc/ssl/rotated-ca.crt"},
+					UseSystemCAs:    true,
+				},
+			}))
+		})
+
+		Context("when the config contains an unknown field", func() {
+			BeforeEach(func() {
+				content = `
+environments:
+- url: https://10.0.0.6:25555
+  unknown: field
+`
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("when there are no environments", func() {
+			BeforeEach(func() {
+				content = `environments: []`
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Environments config does not contain any environment"))
+			})
+		})
+
+		Context("when an environment does not have an url", func() {
+			BeforeEach(func() {
+				content = `
+environments:
+- environment: production
+`
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Environment #1 does not have an `url`"))
+			})
+		})
+
+		Context("when an environment url is duplicated", func() {
+			BeforeEach(func() {
+				content = `
+environments:
+- url: https://10.0.0.6:25555
+- url: https://10.0.0.6:25555
+`
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Environment url `https://10.0.0.6:25555` is duplicated"))
+			})
+		})
+
+		Context("when the file does not exist", func() {
+			JustBeforeEach(func() {
+				config, err = LoadConfig("/does/not/exist")
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Environment CACerts", func() {
+		It("returns the CA certificate file followed by the CA certificate files", func() {
+			environment := Environment{CACertFile: "/etc/ssl/ca.crt", CACertFiles: []string{"/etc/ssl/internal-ca.crt"}}
+			Expect(environment.CACerts()).To(Equal([]string{"/etc/ssl/ca.crt", "/etc/ssl/internal-ca.crt"}))
+		})
+
+		It("returns no files when no CA certificate is configured", func() {
+			Expect(Environment{}.CACerts()).To(BeEmpty())
+		})
+	})
+
+	Describe("Filters WithDefaults", func() {
+		var (
+			defaults = Filters{
+				Deployments:     []string{"cf"},
+				AZs:             []string{"z1"},
+				Collectors:      []string{"Jobs"},
+				CIDRs:           []string{"0.0.0.0/0"},
+				ProcessesRegexp: ".*",
+				Profile:         "minimal",
+			}
+		)
+
+		It("returns the defaults for the filters not set", func() {
+			Expect(Filters{AZs: []string{"z2"}}.WithDefaults(defaults)).To(Equal(Filters{
+				Deployments:     []string{"cf"},
+				AZs:             []string{"z2"},
+				Collectors:      []string{"Jobs"},
+				CIDRs:           []string{"0.0.0.0/0"},
+				ProcessesRegexp: ".*",
+				Profile:         "minimal",
+			}))
+		})
+
+		It("keeps filters explicitly set to empty", func() {
+			Expect(Filters{Deployments: []string{}}.WithDefaults(defaults).Deployments).To(BeEmpty())
+		})
+	})
+
+	Describe("ValidateLabelSets", func() {
+		It("does not return an error when label sets are unique", func() {
+			Expect(ValidateLabelSets([]LabelSet{
+				{Environment: "production", BoshName: "bosh-1", BoshUUID: "uuid-1"},
+				{Environment: "production", BoshName: "bosh-2", BoshUUID: "uuid-2"},
+			})).To(Succeed())
+		})
+
+		It("returns an error when label sets are duplicated", func() {
+			err := ValidateLabelSets([]LabelSet{
+				{Environment: "production", BoshName: "bosh-1", BoshUUID: "uuid-1"},
+				{Environment: "production", BoshName: "bosh-1", BoshUUID: "uuid-1"},
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("More than one BOSH Director exports metrics with environment `production`, bosh_name `bosh-1` and bosh_uuid `uuid-1`"))
+		})
+	})
+
+	Describe("ValidateSDFilenames", func() {
+		It("does not return an error when filenames are unique", func() {
+			Expect(ValidateSDFilenames([]string{"a.json", "b.json"})).To(Succeed())
+		})
+
+		It("returns an error when filenames are duplicated", func() {
+			err := ValidateSDFilenames([]string{"a.json", "a.json"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("More than one BOSH Director writes Service Discovery output to `a.json`"))
+		})
+	})
+})
@@ -0,0 +1,203 @@
+package exposition
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/log"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// HealthwatchAliases is the name of the built-in alias table emitting the
+// instance vitals under the BOSH system metrics names and labels used by the
+// TAS Healthwatch dashboards and alerts.
+const HealthwatchAliases = "healthwatch"
+
+var healthwatchAliasLabels = map[string]string{
+	"bosh_deployment": "deployment",
+	"bosh_job_name":   "job",
+	"bosh_job_id":     "id",
+	"bosh_job_index":  "index",
+	"bosh_job_ip":     "ip",
+}
+
+var healthwatchAliases = []Alias{
+	{Metric: "job_healthy", Name: "system_healthy", Labels: healthwatchAliasLabels},
+	{Metric: "job_cpu_user", Name: "system_cpu_user", Labels: healthwatchAliasLabels},
+	{Metric: "job_cpu_sys", Name: "system_cpu_sys", Labels: healthwatchAliasLabels},
+	{Metric: "job_cpu_wait", Name: "system_cpu_wait", Labels: healthwatchAliasLabels},
+	{Metric: "job_load_avg01", Name: "system_load_1m", Labels: healthwatchAliasLabels},
+	{Metric: "job_load_avg05", Name: "system_load_5m", Labels: healthwatchAliasLabels},
+	{Metric: "job_load_avg15", Name: "system_load_15m", Labels: healthwatchAliasLabels},
+	{Metric: "job_mem_percent", Name: "system_mem_percent", Labels: healthwatchAliasLabels},
+	{Metric: "job_mem_kb", Name: "system_mem_kb", Labels: healthwatchAliasLabels},
+	{Metric: "job_swap_percent", Name: "system_swap_percent", Labels: healthwatchAliasLabels},
+	{Metric: "job_swap_kb", Name: "system_swap_kb", Labels: healthwatchAliasLabels},
+	{Metric: "job_system_disk_percent", Name: "system_disk_system_percent", Labels: healthwatchAliasLabels},
+	{Metric: "job_system_disk_inode_percent", Name: "system_disk_system_inode_percent", Labels: healthwatchAliasLabels},
+	{Metric: "job_ephemeral_disk_percent", Name: "system_disk_ephemeral_percent", Labels: healthwatchAliasLabels},
+	{Metric: "job_ephemeral_disk_inode_percent", Name: "system_disk_ephemeral_inode_percent", Labels: healthwatchAliasLabels},
+	{Metric: "job_persistent_disk_percent", Name: "system_disk_persistent_percent", Labels: healthwatchAliasLabels},
+	{Metric: "job_persistent_disk_inode_percent", Name: "system_disk_persistent_inode_percent", Labels: healthwatchAliasLabels},
+}
+
+// Alias emits the metric family Metric (without the metrics namespace) again
+// under Name, renaming its labels after Labels (an empty new name drops the
+// label) and adding ConstLabels.
+type Alias struct {
+	Metric      string            `yaml:"metric"`
+	Name        string            `yaml:"name"`
+	Help        string            `yaml:"help"`
+	Labels      map[string]string `yaml:"labels"`
+	ConstLabels map[string]string `yaml:"const_labels"`
+}
+
+type aliasesFile struct {
+	Aliases []Alias `yaml:"aliases"`
+}
+
+// LoadAliases returns the built-in Healthwatch table when source is
+// `healthwatch`, and reads the alias table of the source YAML file otherwise.
+func LoadAliases(source string) ([]Alias, error) {
+	if source == HealthwatchAliases {
+		return healthwatchAliases, nil
+	}
+
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error reading metric aliases file `%s`: %v", source, err))
+	}
+
+	aliases, err := ParseAliases(data)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing metric aliases file `%s`: %v", source, err))
+	}
+
+	return aliases, nil
+}
+
+func ParseAliases(data []byte) ([]Alias, error) {
+	var file aliasesFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, err
+	}
+
+	for i, alias := range file.Aliases {
+		if alias.Metric == "" || alias.Name == "" {
+			return nil, errors.New(fmt.Sprintf("alias %d: `metric` and `name` are required", i))
+		}
+		if !model.IsValidMetricName(model.LabelValue(alias.Name)) {
+			return nil, errors.New(fmt.Sprintf("alias %d: invalid metric name `%s`", i, alias.Name))
+		}
+		for name, newName := range alias.Labels {
+			if newName != "" && !model.LabelName(newName).IsValid() {
+				return nil, errors.New(fmt.Sprintf("alias %d: invalid label name `%s` for `%s`", i, newName, name))
+			}
+		}
+		for name := range alias.ConstLabels {
+			if !model.LabelName(name).IsValid() {
+				return nil, errors.New(fmt.Sprintf("alias %d: invalid label name `%s`", i, name))
+			}
+		}
+	}
+
+	return file.Aliases, nil
+}
+
+// AliasGatherer adds to the metric families of a gatherer their aliases.
+// Aliases whose name is already used by another family are dropped, as are
+// the series made duplicate by dropped labels.
+func AliasGatherer(gatherer prometheus.Gatherer, namespace string, aliases []Alias) prometheus.Gatherer {
+	if len(aliases) == 0 {
+		return gatherer
+	}
+
+	aliasesByMetric := map[string][]Alias{}
+	for _, alias := range aliases {
+		metric := alias.Metric
+		if namespace != "" {
+			metric = namespace + "_" + metric
+		}
+		aliasesByMetric[metric] = append(aliasesByMetric[metric], alias)
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		metricFamilies, err := gatherer.Gather()
+
+		names := map[string]bool{}
+		for _, metricFamily := range metricFamilies {
+			names[metricFamily.GetName()] = true
+		}
+
+		aliasedFamilies := metricFamilies
+		for _, metricFamily := range metricFamilies {
+			for _, alias := range aliasesByMetric[metricFamily.GetName()] {
+				if names[alias.Name] {
+					log.Debugf("Dropping alias `%s` of metric family `%s`: the name is already used", alias.Name, metricFamily.GetName())
+					continue
+				}
+				names[alias.Name] = true
+				aliasedFamilies = append(aliasedFamilies, aliasMetricFamily(metricFamily, alias))
+			}
+		}
+
+		sort.Slice(aliasedFamilies, func(i, j int) bool {
+			return aliasedFamilies[i].GetName() < aliasedFamilies[j].GetName()
+		})
+
+		return aliasedFamilies, err
+	})
+}
+
+func aliasMetricFamily(metricFamily *dto.MetricFamily, alias Alias) *dto.MetricFamily {
+	aliasedFamily := proto.Clone(metricFamily).(*dto.MetricFamily)
+	aliasedFamily.Name = proto.String(alias.Name)
+	if alias.Help != "" {
+		aliasedFamily.Help = proto.String(alias.Help)
+	}
+
+	seen := map[string]bool{}
+	aliasedMetrics := make([]*dto.Metric, 0, len(aliasedFamily.GetMetric()))
+	for _, metric := range aliasedFamily.GetMetric() {
+		labels := make([]*dto.LabelPair, 0, len(metric.GetLabel())+len(alias.ConstLabels))
+		for _, label := range metric.GetLabel() {
+			name := label.GetName()
+			if newName, ok := alias.Labels[name]; ok {
+				if newName == "" {
+					continue
+				}
+				name = newName
+			}
+			if _, ok := alias.ConstLabels[name]; ok {
+				continue
+			}
+			labels = append(labels, &dto.LabelPair{Name: proto.String(name), Value: proto.String(label.GetValue())})
+		}
+		for name, value := range alias.ConstLabels {
+			labels = append(labels, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+		}
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].GetName() < labels[j].GetName()
+		})
+
+		signature := make([]string, 0, len(labels))
+		for _, label := range labels {
+			signature = append(signature, label.GetName()+"="+label.GetValue())
+		}
+		if key := strings.Join(signature, "\xff"); !seen[key] {
+			seen[key] = true
+			metric.Label = labels
+			aliasedMetrics = append(aliasedMetrics, metric)
+		}
+	}
+	aliasedFamily.Metric = aliasedMetrics
+
+	return aliasedFamily
+}
@@ -0,0 +1,179 @@
+package exposition_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/exposition"
+)
+
+var _ = Describe("AliasGatherer", func() {
+	var (
+		registry *prometheus.Registry
+		aliases  []Alias
+	)
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+
+		jobHealthy := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{Namespace: "test", Name: "job_healthy", Help: "BOSH Job Healthy."},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_index", "bosh_job_az"},
+		)
+		jobHealthy.WithLabelValues("cf", "router", "0", "z1").Set(1)
+		jobHealthy.WithLabelValues("cf", "router", "1", "z2").Set(0)
+		registry.MustRegister(jobHealthy)
+
+		jobCPUUser := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{Namespace: "test", Name: "job_cpu_user", Help: "BOSH Job CPU User."},
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_index"},
+		)
+		jobCPUUser.WithLabelValues("cf", "router", "0").Set(12.5)
+		registry.MustRegister(jobCPUUser)
+
+		aliases = []Alias{
+			{
+				Metric:      "job_healthy",
+				Name:        "system_healthy",
+				Labels:      map[string]string{"bosh_deployment": "deployment", "bosh_job_name": "job", "bosh_job_index": "index"},
+				ConstLabels: map[string]string{"origin": "bosh_exporter"},
+			},
+		}
+	})
+
+	It("keeps the original metric families", func() {
+		metricFamilies := scrape(AliasGatherer(registry, "test", aliases))
+		Expect(scrapedFamily(metricFamilies, "test_job_healthy").GetMetric()).To(HaveLen(2))
+		Expect(scrapedFamily(metricFamilies, "test_job_cpu_user").GetMetric()).To(HaveLen(1))
+	})
+
+	It("emits the aliases with renamed and added labels", func() {
+		metricFamilies := scrape(AliasGatherer(registry, "test", aliases))
+		aliasFamily := scrapedFamily(metricFamilies, "system_healthy")
+		Expect(aliasFamily).ToNot(BeNil())
+		Expect(aliasFamily.GetHelp()).To(Equal("BOSH Job Healthy."))
+		Expect(aliasFamily.GetMetric()).To(HaveLen(2))
+
+		labels := map[string]string{}
+		for _, label := range aliasFamily.GetMetric()[0].GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		Expect(labels).To(Equal(map[string]string{
+			"bosh_job_az": "z1",
+			"deployment":  "cf",
+			"index":       "0",
+			"job":         "router",
+			"origin":      "bosh_exporter",
+		}))
+		Expect(aliasFamily.GetMetric()[0].GetGauge().GetValue()).To(Equal(float64(1)))
+	})
+
+	It("does not modify the original metric families", func() {
+		metricFamilies := scrape(AliasGatherer(registry, "test", aliases))
+		labelNames := []string{}
+		for _, label := range scrapedFamily(metricFamilies, "test_job_healthy").GetMetric()[0].GetLabel() {
+			labelNames = append(labelNames, label.GetName())
+		}
+		Expect(labelNames).To(Equal([]string{"bosh_deployment", "bosh_job_az", "bosh_job_index", "bosh_job_name"}))
+	})
+
+	Context("when a label is dropped", func() {
+		BeforeEach(func() {
+			aliases[0].Labels = map[string]string{"bosh_job_index": "", "bosh_job_az": ""}
+		})
+
+		It("drops the series made duplicate", func() {
+			metricFamilies := scrape(AliasGatherer(registry, "test", aliases))
+			Expect(scrapedFamily(metricFamilies, "system_healthy").GetMetric()).To(HaveLen(1))
+		})
+	})
+
+	Context("when the alias name is already used", func() {
+		BeforeEach(func() {
+			aliases[0].Name = "test_job_cpu_user"
+		})
+
+		It("drops the alias", func() {
+			metricFamilies := scrape(AliasGatherer(registry, "test", aliases))
+			Expect(scrapedFamily(metricFamilies, "test_job_cpu_user").GetHelp()).To(Equal("BOSH Job CPU User."))
+		})
+	})
+
+	Context("with the Healthwatch aliases", func() {
+		BeforeEach(func() {
+			var err error
+			aliases, err = LoadAliases(HealthwatchAliases)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("emits the BOSH system metrics names", func() {
+			metricFamilies := scrape(AliasGatherer(registry, "test", aliases))
+			Expect(scrapedFamily(metricFamilies, "system_healthy")).ToNot(BeNil())
+			Expect(scrapedFamily(metricFamilies, "system_cpu_user")).ToNot(BeNil())
+		})
+	})
+})
+
+var _ = Describe("LoadAliases", func() {
+	var (
+		aliasesFile *os.File
+	)
+
+	BeforeEach(func() {
+		var err error
+		aliasesFile, err = ioutil.TempFile("", "aliases")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.Remove(aliasesFile.Name())
+	})
+
+	It("reads the alias table of a file", func() {
+		Expect(ioutil.WriteFile(aliasesFile.Name(), []byte(`
+aliases:
+- metric: job_healthy
+  name: system_healthy
+  labels:
+    bosh_deployment: deployment
+`), 0644)).To(Succeed())
+
+		aliases, err := LoadAliases(aliasesFile.Name())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(aliases).To(Equal([]Alias{
+			{Metric: "job_healthy", Name: "system_healthy", Labels: map[string]string{"bosh_deployment": "deployment"}},
+		}))
+	})
+
+	It("returns an error when the file does not exist", func() {
+		_, err := LoadAliases("/does/not/exist")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error when an alias name is invalid", func() {
+		Expect(ioutil.WriteFile(aliasesFile.Name(), []byte(`
+aliases:
+- metric: job_healthy
+  name: system.healthy
+`), 0644)).To(Succeed())
+
+		_, err := LoadAliases(aliasesFile.Name())
+		Expect(err).To(MatchError(ContainSubstring("invalid metric name `system.healthy`")))
+	})
+
+	It("returns an error when a field is unknown", func() {
+		Expect(ioutil.WriteFile(aliasesFile.Name(), []byte(`
+aliases:
+- metric: job_healthy
+  alias: system_healthy
+`), 0644)).To(Succeed())
+
+		_, err := LoadAliases(aliasesFile.Name())
+		Expect(err).To(HaveOccurred())
+	})
+})
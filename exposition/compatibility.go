@@ -0,0 +1,121 @@
+package exposition
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/log"
+)
+
+// percentSuffixes are the families exposing percentages, renamed to their
+// base unit ratio in compatibility mode.
+var percentSuffixes = map[string]string{
+	"_percent":   "_ratio",
+	"_cpu_total": "_cpu_ratio",
+	"_cpu_limit": "_cpu_limit_ratio",
+}
+
+// CompatibleGatherer rewrites the metric families of a gatherer so that they
+// pass `promtool check metrics` and the OpenMetrics strict parsing:
+//   - families with abbreviated units (the deprecated `*_kb` ones, exposed
+//     alongside their `*_bytes` equivalent) are dropped;
+//   - percentages are renamed to `*_ratio` and scaled to a ratio;
+//   - counters get a `_total` suffix and other types lose it;
+//   - missing or multi-line help strings are fixed.
+//
+// Families still failing the checks afterwards are dropped.
+func CompatibleGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		metricFamilies, err := gatherer.Gather()
+
+		names := map[string]bool{}
+		for _, metricFamily := range metricFamilies {
+			names[metricFamily.GetName()] = true
+		}
+
+		compatibleFamilies := []*dto.MetricFamily{}
+		for _, metricFamily := range metricFamilies {
+			name := metricFamily.GetName()
+			if !compatibleMetricFamily(metricFamily) {
+				log.Debugf("Dropping metric family `%s` not compatible with the exposition checks", name)
+				continue
+			}
+			if metricFamily.GetName() != name && names[metricFamily.GetName()] {
+				log.Debugf("Dropping metric family `%s`: its compatible name `%s` is already used", name, metricFamily.GetName())
+				continue
+			}
+			names[metricFamily.GetName()] = true
+			compatibleFamilies = append(compatibleFamilies, metricFamily)
+		}
+
+		sort.Slice(compatibleFamilies, func(i, j int) bool {
+			return compatibleFamilies[i].GetName() < compatibleFamilies[j].GetName()
+		})
+
+		return compatibleFamilies, err
+	})
+}
+
+// compatibleMetricFamily rewrites a metric family in place, returning whether
+// it passes the exposition checks.
+func compatibleMetricFamily(metricFamily *dto.MetricFamily) bool {
+	name := metricFamily.GetName()
+	isCounter := metricFamily.GetType() == dto.MetricType_COUNTER
+
+	for _, token := range strings.Split(name, "_") {
+		if abbreviatedUnits[token] {
+			return false
+		}
+	}
+
+	help := strings.Join(strings.Fields(metricFamily.GetHelp()), " ")
+	if !isCounter {
+		for suffix, ratioSuffix := range percentSuffixes {
+			if strings.HasSuffix(name, suffix) {
+				name = strings.TrimSuffix(name, suffix) + ratioSuffix
+				scaleMetricFamily(metricFamily, 0.01)
+				help = strings.TrimSuffix(help, ".") + ", as a ratio."
+				break
+			}
+		}
+	}
+
+	if isCounter && !strings.HasSuffix(name, "_total") {
+		name += "_total"
+	}
+	if !isCounter {
+		name = strings.TrimSuffix(name, "_total")
+	}
+	if help == "" {
+		help = "Metric " + name + "."
+	}
+
+	metricFamily.Name = proto.String(name)
+	metricFamily.Help = proto.String(help)
+
+	return len(lintMetricFamily(metricFamily)) == 0
+}
+
+func scaleMetricFamily(metricFamily *dto.MetricFamily, factor float64) {
+	for _, metric := range metricFamily.GetMetric() {
+		switch {
+		case metric.Gauge != nil:
+			metric.Gauge.Value = proto.Float64(metric.Gauge.GetValue() * factor)
+		case metric.Untyped != nil:
+			metric.Untyped.Value = proto.Float64(metric.Untyped.GetValue() * factor)
+		case metric.Histogram != nil:
+			metric.Histogram.SampleSum = proto.Float64(metric.Histogram.GetSampleSum() * factor)
+			for _, bucket := range metric.Histogram.GetBucket() {
+				bucket.UpperBound = proto.Float64(bucket.GetUpperBound() * factor)
+			}
+		case metric.Summary != nil:
+			metric.Summary.SampleSum = proto.Float64(metric.Summary.GetSampleSum() * factor)
+			for _, quantile := range metric.Summary.GetQuantile() {
+				quantile.Value = proto.Float64(quantile.GetValue() * factor)
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package exposition_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/bosh_exporter/collectors"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+	"github.com/bosh-prometheus/bosh_exporter/filters"
+
+	. "github.com/bosh-prometheus/bosh_exporter/exposition"
+)
+
+func scrape(gatherer prometheus.Gatherer) []*dto.MetricFamily {
+	metricFamilies, err := gatherer.Gather()
+	Expect(err).ToNot(HaveOccurred())
+
+	var payload bytes.Buffer
+	encoder := expfmt.NewEncoder(&payload, expfmt.FmtText)
+	for _, metricFamily := range metricFamilies {
+		Expect(encoder.Encode(metricFamily)).To(Succeed())
+	}
+
+	var parser expfmt.TextParser
+	parsedFamilies, err := parser.TextToMetricFamilies(&payload)
+	Expect(err).ToNot(HaveOccurred())
+
+	scraped := []*dto.MetricFamily{}
+	for _, metricFamily := range parsedFamilies {
+		scraped = append(scraped, metricFamily)
+	}
+
+	return scraped
+}
+
+func scrapedFamily(metricFamilies []*dto.MetricFamily, name string) *dto.MetricFamily {
+	for _, metricFamily := range metricFamilies {
+		if metricFamily.GetName() == name {
+			return metricFamily
+		}
+	}
+
+	return nil
+}
+
+var _ = Describe("CompatibleGatherer", func() {
+	var (
+		registry *prometheus.Registry
+	)
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+	})
+
+	It("drops the families with abbreviated units", func() {
+		memKB := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_mem_kb", Help: "Memory KB."})
+		memBytes := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_mem_bytes", Help: "Memory in bytes."})
+		registry.MustRegister(memKB, memBytes)
+
+		metricFamilies := scrape(CompatibleGatherer(registry))
+		Expect(scrapedFamily(metricFamilies, "test_mem_kb")).To(BeNil())
+		Expect(scrapedFamily(metricFamilies, "test_mem_bytes")).ToNot(BeNil())
+	})
+
+	It("renames the percentages to ratios", func() {
+		memPercent := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_mem_percent", Help: "Memory Percent."})
+		memPercent.Set(50)
+		cpuTotal := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_process_cpu_total", Help: "Process CPU Total."})
+		cpuTotal.Set(150)
+		cpuLimit := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_process_cpu_limit", Help: "Process CPU Limit."})
+		cpuLimit.Set(200)
+		registry.MustRegister(memPercent, cpuTotal, cpuLimit)
+
+		metricFamilies := scrape(CompatibleGatherer(registry))
+		Expect(scrapedFamily(metricFamilies, "test_mem_percent")).To(BeNil())
+		memRatio := scrapedFamily(metricFamilies, "test_mem_ratio")
+		Expect(memRatio).ToNot(BeNil())
+		Expect(memRatio.GetHelp()).To(Equal("Memory Percent, as a ratio."))
+		Expect(memRatio.GetMetric()[0].GetGauge().GetValue()).To(Equal(0.5))
+		cpuRatio := scrapedFamily(metricFamilies, "test_process_cpu_ratio")
+		Expect(cpuRatio).ToNot(BeNil())
+		Expect(cpuRatio.GetMetric()[0].GetGauge().GetValue()).To(Equal(1.5))
+		cpuLimitRatio := scrapedFamily(metricFamilies, "test_process_cpu_limit_ratio")
+		Expect(cpuLimitRatio).ToNot(BeNil())
+		Expect(cpuLimitRatio.GetMetric()[0].GetGauge().GetValue()).To(Equal(2.0))
+	})
+
+	It("fixes the _total suffixes and the help strings", func() {
+		requests := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_requests", Help: "Requests\nserved."})
+		jobs := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_jobs_total", Help: ""})
+		registry.MustRegister(requests, jobs)
+
+		metricFamilies := scrape(CompatibleGatherer(registry))
+		Expect(scrapedFamily(metricFamilies, "test_requests_total").GetHelp()).To(Equal("Requests served."))
+		Expect(scrapedFamily(metricFamilies, "test_jobs").GetHelp()).To(Equal("Metric test_jobs."))
+	})
+
+	It("drops the families it cannot fix", func() {
+		jobCount := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_jobCount", Help: "Jobs."})
+		registry.MustRegister(jobCount)
+
+		Expect(scrapedFamily(scrape(CompatibleGatherer(registry)), "test_jobCount")).To(BeNil())
+	})
+
+	Context("when scraping a synthetic BOSH Director", func() {
+		var (
+			serviceDiscoveryFile *os.File
+		)
+
+		BeforeEach(func() {
+			var err error
+			serviceDiscoveryFile, err = ioutil.TempFile("", "compatibility_test_")
+			Expect(err).ToNot(HaveOccurred())
+
+			snapshot := fetcher.SyntheticSnapshot(fetcher.SyntheticOptions{
+				Environment: "synthetic",
+				Deployments: 3,
+				Instances:   4,
+				Processes:   3,
+				Seed:        1,
+			}, time.Now())
+
+			expressionFilter, err := filters.NewExpressionFilter("")
+			Expect(err).ToNot(HaveOccurred())
+			deploymentsFilter := filters.NewDeploymentsFilter([]string{}, nil)
+			collectorsFilter, err := filters.NewCollectorsFilter([]string{})
+			Expect(err).ToNot(HaveOccurred())
+			processesFilter, err := filters.NewRegexpFilter([]string{})
+			Expect(err).ToNot(HaveOccurred())
+			deploymentProcessesFilter, err := filters.NewDeploymentProcessesFilter("")
+			Expect(err).ToNot(HaveOccurred())
+			cidrsFilter, err := filters.NewCidrFilter([]string{"0.0.0.0/0"})
+			Expect(err).ToNot(HaveOccurred())
+
+			boshCollector := collectors.NewBoshCollector(
+				"bosh",
+				"synthetic",
+				"synthetic",
+				snapshot.Director.UUID,
+				0,
+				0,
+				24*time.Hour,
+				[]string{},
+				6*time.Hour,
+				collectors.SLOObjectives{},
+				24*time.Hour,
+				false,
+				5*time.Minute,
+				collectors.ErrorModeDegraded,
+				0,
+				true,
+				true,
+				nil,
+				collectors.StoppedDeploymentsLabel,
+				false,
+				nil,
+				nil,
+				serviceDiscoveryFile.Name(),
+				"",
+				false,
+				0,
+				false,
+				nil,
+				false,
+				nil,
+				0,
+				0,
+				[]string{},
+				map[string]int{},
+				nil,
+				collectors.ServiceDiscoveryTargetModeProcess,
+				0,
+				nil,
+				nil,
+				fetcher.PauseWindows{},
+				fetcher.NewReplayFetcher(snapshot, deploymentsFilter, expressionFilter),
+				collectorsFilter,
+				filters.NewAZsFilter([]string{}),
+				processesFilter,
+				deploymentProcessesFilter,
+				expressionFilter,
+				cidrsFilter,
+			)
+			registry.MustRegister(boshCollector)
+			registry.MustRegister(NewHandler("bosh", "metrics", registry, 0, CompressionGzip, true))
+		})
+
+		AfterEach(func() {
+			Expect(os.Remove(serviceDiscoveryFile.Name())).To(Succeed())
+		})
+
+		It("fails the exposition checks by default", func() {
+			Expect(Lint(scrape(registry))).ToNot(BeEmpty())
+		})
+
+		It("passes the exposition checks in compatibility mode", func() {
+			metricFamilies := scrape(CompatibleGatherer(registry))
+			Expect(Lint(metricFamilies)).To(BeEmpty())
+			Expect(scrapedFamily(metricFamilies, "bosh_job_mem_ratio")).ToNot(BeNil())
+			Expect(scrapedFamily(metricFamilies, "bosh_deployment_process_cpu_ratio")).ToNot(BeNil())
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package exposition_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Exposition Suite")
+}
@@ -0,0 +1,200 @@
+package exposition
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	CompressionGzip = "gzip"
+	CompressionNone = "none"
+)
+
+// Handler serves the metrics of a gatherer like promhttp.HandlerFor, but
+// refuses scrapes above a series cap and reports the size of the payloads it
+// serves.
+type Handler struct {
+	gatherer        prometheus.Gatherer
+	maxSeries       int
+	compression     string
+	continueOnError bool
+
+	payloadBytes        prometheus.Gauge
+	responseBytes       prometheus.Gauge
+	series              prometheus.Gauge
+	seriesLimitExceeded prometheus.Counter
+}
+
+func NewHandler(
+	namespace string,
+	name string,
+	gatherer prometheus.Gatherer,
+	maxSeries int,
+	compression string,
+	continueOnError bool,
+) *Handler {
+	constLabels := prometheus.Labels{"handler": name}
+
+	payloadBytes := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "exporter",
+			Name:        "scrape_payload_bytes",
+			Help:        "Size in bytes of the last metrics payload served, before compression.",
+			ConstLabels: constLabels,
+		},
+	)
+
+	responseBytes := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "exporter",
+			Name:        "scrape_response_bytes",
+			Help:        "Size in bytes of the last metrics response body sent, after compression.",
+			ConstLabels: constLabels,
+		},
+	)
+
+	series := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "exporter",
+			Name:        "scrape_series",
+			Help:        "Number of series gathered by the last metrics scrape.",
+			ConstLabels: constLabels,
+		},
+	)
+
+	seriesLimitExceeded := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "exporter",
+			Name:        "scrape_series_limit_exceeded_total",
+			Help:        "Total number of metrics scrapes refused because they exceeded the series cap.",
+			ConstLabels: constLabels,
+		},
+	)
+
+	return &Handler{
+		gatherer:            gatherer,
+		maxSeries:           maxSeries,
+		compression:         compression,
+		continueOnError:     continueOnError,
+		payloadBytes:        payloadBytes,
+		responseBytes:       responseBytes,
+		series:              series,
+		seriesLimitExceeded: seriesLimitExceeded,
+	}
+}
+
+func (h *Handler) Describe(ch chan<- *prometheus.Desc) {
+	h.payloadBytes.Describe(ch)
+	h.responseBytes.Describe(ch)
+	h.series.Describe(ch)
+	h.seriesLimitExceeded.Describe(ch)
+}
+
+func (h *Handler) Collect(ch chan<- prometheus.Metric) {
+	h.payloadBytes.Collect(ch)
+	h.responseBytes.Collect(ch)
+	h.series.Collect(ch)
+	h.seriesLimitExceeded.Collect(ch)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	metricFamilies, err := h.gatherer.Gather()
+	if err != nil {
+		log.Errorf("Error gathering metrics: %v", err)
+		if !h.continueOnError || len(metricFamilies) == 0 {
+			http.Error(w, fmt.Sprintf("An error has occurred while gathering metrics:\n\n%v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	series := 0
+	for _, metricFamily := range metricFamilies {
+		series += len(metricFamily.GetMetric())
+	}
+	h.series.Set(float64(series))
+
+	if h.maxSeries > 0 && series > h.maxSeries {
+		h.seriesLimitExceeded.Inc()
+		log.Errorf("Refusing metrics scrape from `%s`: %d series exceed the limit of %d", r.RemoteAddr, series, h.maxSeries)
+		http.Error(w, fmt.Sprintf("%d series exceed the limit of %d", series, h.maxSeries), http.StatusInternalServerError)
+		return
+	}
+
+	contentType := expfmt.Negotiate(r.Header)
+	payload, err := encode(metricFamilies, contentType)
+	if err != nil {
+		log.Errorf("Error encoding metrics: %v", err)
+		http.Error(w, fmt.Sprintf("An error has occurred while encoding metrics:\n\n%v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", string(contentType))
+	w.Header().Add("Vary", "Accept-Encoding")
+	body := &countingWriter{writer: w}
+	if h.compression == CompressionGzip && gzipAccepted(r.Header.Get("Accept-Encoding")) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(body)
+		gz.Write(payload)
+		gz.Close()
+	} else {
+		body.Write(payload)
+	}
+
+	h.payloadBytes.Set(float64(len(payload)))
+	h.responseBytes.Set(float64(body.count))
+}
+
+func encode(metricFamilies []*dto.MetricFamily, contentType expfmt.Format) ([]byte, error) {
+	var payload bytes.Buffer
+	encoder := expfmt.NewEncoder(&payload, contentType)
+	for _, metricFamily := range metricFamilies {
+		if err := encoder.Encode(metricFamily); err != nil {
+			return nil, errors.New(fmt.Sprintf("Error encoding metric family `%s`: %v", metricFamily.GetName(), err))
+		}
+	}
+
+	return payload.Bytes(), nil
+}
+
+func gzipAccepted(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		if strings.ToLower(strings.TrimSpace(params[0])) != "gzip" {
+			continue
+		}
+		for _, param := range params[1:] {
+			param = strings.Replace(strings.TrimSpace(param), " ", "", -1)
+			if strings.HasPrefix(param, "q=") && strings.Trim(strings.TrimPrefix(param, "q="), "0.") == "" {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+type countingWriter struct {
+	writer io.Writer
+	count  int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += n
+	return n, err
+}
@@ -0,0 +1,142 @@
+package exposition_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/exposition"
+)
+
+func handlerMetric(handler *Handler, name string) float64 {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(handler)
+	metricFamilies, err := registry.Gather()
+	Expect(err).ToNot(HaveOccurred())
+	for _, metricFamily := range metricFamilies {
+		if metricFamily.GetName() != name {
+			continue
+		}
+		metric := metricFamily.GetMetric()[0]
+		if metric.GetGauge() != nil {
+			return metric.GetGauge().GetValue()
+		}
+		return metric.GetCounter().GetValue()
+	}
+	Fail("metric " + name + " not found")
+	return 0
+}
+
+var _ = Describe("Handler", func() {
+	var (
+		registry       *prometheus.Registry
+		maxSeries      int
+		compression    string
+		acceptEncoding string
+		handler        *Handler
+		recorder       *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+		gaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_gauge", Help: "Test gauge."}, []string{"index"})
+		gaugeVec.WithLabelValues("0").Set(1)
+		gaugeVec.WithLabelValues("1").Set(2)
+		gaugeVec.WithLabelValues("2").Set(3)
+		registry.MustRegister(gaugeVec)
+
+		maxSeries = 0
+		compression = CompressionGzip
+		acceptEncoding = ""
+	})
+
+	JustBeforeEach(func() {
+		handler = NewHandler("test", "metrics", registry, maxSeries, compression, true)
+		recorder = httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/metrics", nil)
+		if acceptEncoding != "" {
+			request.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		handler.ServeHTTP(recorder, request)
+	})
+
+	It("serves the metrics uncompressed", func() {
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Encoding")).To(BeEmpty())
+		Expect(recorder.Body.String()).To(ContainSubstring(`test_gauge{index="2"} 3`))
+	})
+
+	It("reports the payload size and series", func() {
+		Expect(handlerMetric(handler, "test_exporter_scrape_payload_bytes")).To(Equal(float64(recorder.Body.Len())))
+		Expect(handlerMetric(handler, "test_exporter_scrape_response_bytes")).To(Equal(float64(recorder.Body.Len())))
+		Expect(handlerMetric(handler, "test_exporter_scrape_series")).To(Equal(float64(3)))
+	})
+
+	Context("when the client accepts gzip", func() {
+		BeforeEach(func() {
+			acceptEncoding = "deflate, gzip;q=0.8"
+		})
+
+		It("serves the metrics compressed", func() {
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Encoding")).To(Equal("gzip"))
+
+			reader, err := gzip.NewReader(recorder.Body)
+			Expect(err).ToNot(HaveOccurred())
+			payload, err := ioutil.ReadAll(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(payload)).To(ContainSubstring(`test_gauge{index="2"} 3`))
+			Expect(handlerMetric(handler, "test_exporter_scrape_payload_bytes")).To(Equal(float64(len(payload))))
+		})
+
+		Context("and compression is disabled", func() {
+			BeforeEach(func() {
+				compression = CompressionNone
+			})
+
+			It("serves the metrics uncompressed", func() {
+				Expect(recorder.Header().Get("Content-Encoding")).To(BeEmpty())
+				Expect(recorder.Body.String()).To(ContainSubstring(`test_gauge{index="2"} 3`))
+			})
+		})
+	})
+
+	Context("when the client refuses gzip", func() {
+		BeforeEach(func() {
+			acceptEncoding = "gzip;q=0, identity"
+		})
+
+		It("serves the metrics uncompressed", func() {
+			Expect(recorder.Header().Get("Content-Encoding")).To(BeEmpty())
+		})
+	})
+
+	Context("when the series exceed the limit", func() {
+		BeforeEach(func() {
+			maxSeries = 2
+		})
+
+		It("refuses the scrape", func() {
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			Expect(recorder.Body.String()).To(ContainSubstring("3 series exceed the limit of 2"))
+			Expect(handlerMetric(handler, "test_exporter_scrape_series_limit_exceeded_total")).To(Equal(float64(1)))
+		})
+	})
+
+	Context("when the series fit the limit", func() {
+		BeforeEach(func() {
+			maxSeries = 3
+		})
+
+		It("serves the metrics", func() {
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(handlerMetric(handler, "test_exporter_scrape_series_limit_exceeded_total")).To(Equal(float64(0)))
+		})
+	})
+})
@@ -0,0 +1,159 @@
+package exposition
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRegexp  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	camelCaseRegexp  = regexp.MustCompile(`[a-z][A-Z]`)
+
+	abbreviatedUnits = map[string]bool{
+		"s": true, "ms": true, "us": true, "ns": true, "sec": true,
+		"b": true, "kb": true, "mb": true, "gb": true, "tb": true, "pb": true,
+		"m": true, "h": true, "d": true,
+	}
+
+	nonBaseUnits = map[string]string{
+		"milliseconds": "seconds", "microseconds": "seconds", "nanoseconds": "seconds",
+		"minutes": "seconds", "hours": "seconds", "days": "seconds",
+		"kilobytes": "bytes", "megabytes": "bytes", "gigabytes": "bytes",
+		"percent": "ratio",
+	}
+)
+
+// LintProblem is a metric family failing the `promtool check metrics` rules
+// or the OpenMetrics strict parsing rules.
+type LintProblem struct {
+	Metric string
+	Text   string
+}
+
+func (p LintProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Metric, p.Text)
+}
+
+// Lint checks metric families against the `promtool check metrics` rules and
+// the OpenMetrics strict parsing rules, returning the problems sorted by
+// metric name.
+func Lint(metricFamilies []*dto.MetricFamily) []LintProblem {
+	problems := []LintProblem{}
+	for _, metricFamily := range metricFamilies {
+		problems = append(problems, lintMetricFamily(metricFamily)...)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Metric < problems[j].Metric
+	})
+
+	return problems
+}
+
+func lintMetricFamily(metricFamily *dto.MetricFamily) []LintProblem {
+	name := metricFamily.GetName()
+	metricType := metricFamily.GetType()
+	problems := []LintProblem{}
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, LintProblem{Metric: name, Text: fmt.Sprintf(format, args...)})
+	}
+
+	if !metricNameRegexp.MatchString(name) {
+		problem("invalid metric name")
+	}
+	if strings.Contains(name, ":") {
+		problem("metric names should not contain ':'")
+	}
+	if camelCaseRegexp.MatchString(name) {
+		problem("metric names should be written in 'snake_case' not 'camelCase'")
+	}
+
+	help := metricFamily.GetHelp()
+	if help == "" {
+		problem("no help text")
+	} else if strings.ContainsAny(help, "\n\r") {
+		problem("help text should be a single line")
+	}
+
+	for _, typeName := range []string{"counter", "gauge", "histogram", "summary"} {
+		if strings.Contains(name, "_"+typeName+"_") || strings.HasSuffix(name, "_"+typeName) {
+			problem("metric name should not include type '%s'", typeName)
+		}
+	}
+
+	for _, token := range strings.Split(name, "_") {
+		if abbreviatedUnits[token] {
+			problem("metric names should not contain abbreviated units")
+			break
+		}
+	}
+	for _, token := range strings.Split(name, "_") {
+		if baseUnit, ok := nonBaseUnits[token]; ok {
+			problem("use base unit %q instead of %q", baseUnit, token)
+		}
+	}
+
+	isCounter := metricType == dto.MetricType_COUNTER
+	isHistogram := metricType == dto.MetricType_HISTOGRAM
+	isSummary := metricType == dto.MetricType_SUMMARY
+	if isCounter && !strings.HasSuffix(name, "_total") {
+		problem(`counter metrics should have "_total" suffix`)
+	}
+	if !isCounter && strings.HasSuffix(name, "_total") {
+		problem(`non-counter metrics should not have "_total" suffix`)
+	}
+	if strings.HasSuffix(name, "_created") {
+		problem(`metric names should not have "_created" suffix, reserved by OpenMetrics for counters`)
+	}
+	if !isHistogram && strings.HasSuffix(name, "_bucket") {
+		problem(`non-histogram metrics should not have "_bucket" suffix`)
+	}
+	if !isHistogram && !isSummary {
+		if strings.HasSuffix(name, "_count") {
+			problem(`non-histogram and non-summary metrics should not have "_count" suffix`)
+		}
+		if strings.HasSuffix(name, "_sum") {
+			problem(`non-histogram and non-summary metrics should not have "_sum" suffix`)
+		}
+	}
+	if strings.HasSuffix(name, "_info") && metricType != dto.MetricType_GAUGE {
+		problem(`info metrics should be gauges`)
+	}
+
+	labelProblems := map[string]bool{}
+	for _, metric := range metricFamily.GetMetric() {
+		for _, label := range metric.GetLabel() {
+			labelName := label.GetName()
+			if !labelNameRegexp.MatchString(labelName) || strings.HasPrefix(labelName, "__") {
+				labelProblems[fmt.Sprintf("invalid label name %q", labelName)] = true
+			}
+			if camelCaseRegexp.MatchString(labelName) {
+				labelProblems["label names should be written in 'snake_case' not 'camelCase'"] = true
+			}
+			if labelName == "le" && !isHistogram {
+				labelProblems[`non-histogram metrics should not have "le" label`] = true
+			}
+			if labelName == "quantile" && !isSummary {
+				labelProblems[`non-summary metrics should not have "quantile" label`] = true
+			}
+		}
+		if strings.HasSuffix(name, "_info") && metric.GetGauge().GetValue() != 1 {
+			labelProblems["info metrics should have a constant 1 value"] = true
+		}
+	}
+	texts := []string{}
+	for text := range labelProblems {
+		texts = append(texts, text)
+	}
+	sort.Strings(texts)
+	for _, text := range texts {
+		problem("%s", text)
+	}
+
+	return problems
+}
@@ -0,0 +1,99 @@
+package exposition_test
+
+import (
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/exposition"
+)
+
+func metricFamily(name string, help string, metricType dto.MetricType, labels ...string) *dto.MetricFamily {
+	metric := &dto.Metric{}
+	for _, label := range labels {
+		metric.Label = append(metric.Label, &dto.LabelPair{Name: proto.String(label), Value: proto.String("value")})
+	}
+	switch metricType {
+	case dto.MetricType_COUNTER:
+		metric.Counter = &dto.Counter{Value: proto.Float64(1)}
+	case dto.MetricType_HISTOGRAM:
+		metric.Histogram = &dto.Histogram{SampleCount: proto.Uint64(1), SampleSum: proto.Float64(1)}
+	default:
+		metric.Gauge = &dto.Gauge{Value: proto.Float64(1)}
+	}
+
+	return &dto.MetricFamily{
+		Name:   proto.String(name),
+		Help:   proto.String(help),
+		Type:   metricType.Enum(),
+		Metric: []*dto.Metric{metric},
+	}
+}
+
+var _ = Describe("Lint", func() {
+	It("accepts well named metrics", func() {
+		Expect(Lint([]*dto.MetricFamily{
+			metricFamily("test_requests_total", "Total requests.", dto.MetricType_COUNTER, "code"),
+			metricFamily("test_memory_bytes", "Memory in bytes.", dto.MetricType_GAUGE),
+			metricFamily("test_duration_seconds", "Duration.", dto.MetricType_HISTOGRAM, "handler"),
+			metricFamily("test_build_info", "Build information.", dto.MetricType_GAUGE, "version"),
+		})).To(BeEmpty())
+	})
+
+	It("reports the counters without a _total suffix", func() {
+		Expect(Lint([]*dto.MetricFamily{
+			metricFamily("test_requests", "Requests.", dto.MetricType_COUNTER),
+		})).To(ConsistOf(LintProblem{Metric: "test_requests", Text: `counter metrics should have "_total" suffix`}))
+	})
+
+	It("reports the gauges with a _total suffix", func() {
+		Expect(Lint([]*dto.MetricFamily{
+			metricFamily("test_cpu_total", "CPU.", dto.MetricType_GAUGE),
+		})).To(ConsistOf(LintProblem{Metric: "test_cpu_total", Text: `non-counter metrics should not have "_total" suffix`}))
+	})
+
+	It("reports the missing and multi-line help strings", func() {
+		Expect(Lint([]*dto.MetricFamily{
+			metricFamily("test_empty", "", dto.MetricType_GAUGE),
+			metricFamily("test_multiline", "First line.\nSecond line.", dto.MetricType_GAUGE),
+		})).To(Equal([]LintProblem{
+			{Metric: "test_empty", Text: "no help text"},
+			{Metric: "test_multiline", Text: "help text should be a single line"},
+		}))
+	})
+
+	It("reports the abbreviated and non base units", func() {
+		Expect(Lint([]*dto.MetricFamily{
+			metricFamily("test_mem_kb", "Memory.", dto.MetricType_GAUGE),
+			metricFamily("test_mem_percent", "Memory.", dto.MetricType_GAUGE),
+		})).To(Equal([]LintProblem{
+			{Metric: "test_mem_kb", Text: "metric names should not contain abbreviated units"},
+			{Metric: "test_mem_percent", Text: `use base unit "ratio" instead of "percent"`},
+		}))
+	})
+
+	It("reports the names reserved by histograms, summaries and OpenMetrics", func() {
+		Expect(Lint([]*dto.MetricFamily{
+			metricFamily("test_disk_count", "Disks.", dto.MetricType_GAUGE),
+			metricFamily("test_start_created", "Start.", dto.MetricType_GAUGE),
+			metricFamily("test_latency", "Latency.", dto.MetricType_GAUGE, "le"),
+		})).To(Equal([]LintProblem{
+			{Metric: "test_disk_count", Text: `non-histogram and non-summary metrics should not have "_count" suffix`},
+			{Metric: "test_latency", Text: `non-histogram metrics should not have "le" label`},
+			{Metric: "test_start_created", Text: `metric names should not have "_created" suffix, reserved by OpenMetrics for counters`},
+		}))
+	})
+
+	It("reports the camelCase names and type names", func() {
+		Expect(Lint([]*dto.MetricFamily{
+			metricFamily("test_jobCount", "Jobs.", dto.MetricType_GAUGE, "jobName"),
+			metricFamily("test_jobs_gauge", "Jobs.", dto.MetricType_GAUGE),
+		})).To(Equal([]LintProblem{
+			{Metric: "test_jobCount", Text: "metric names should be written in 'snake_case' not 'camelCase'"},
+			{Metric: "test_jobCount", Text: "label names should be written in 'snake_case' not 'camelCase'"},
+			{Metric: "test_jobs_gauge", Text: "metric name should not include type 'gauge'"},
+		}))
+	})
+})
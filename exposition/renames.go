@@ -0,0 +1,148 @@
+package exposition
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// NamingVersion is the version of the current metric names and labels. It is
+// bumped whenever a metric or one of its labels is renamed.
+var NamingVersion = 1
+
+// Rename records that the metric family OldMetric (without the metrics
+// namespace) is emitted as Metric since Version, with its labels renamed
+// after Labels (old name to new name). Metric is always the current name, so
+// the entries of a metric renamed again are updated to point to its new name.
+type Rename struct {
+	Version   int
+	OldMetric string
+	Metric    string
+	Labels    map[string]string
+}
+
+// Renames lists every rename since the first naming version. No metric has
+// been renamed yet.
+var Renames = []Rename{}
+
+var identifierRegexp = regexp.MustCompile(`[a-zA-Z_:][a-zA-Z0-9_:]*`)
+
+// RenamesSince returns the renames applied after the naming version, or an
+// error when the version is unknown.
+func RenamesSince(version int) ([]Rename, error) {
+	if version < 1 || version > NamingVersion {
+		return nil, errors.New(fmt.Sprintf("Unknown metric naming version %d, must be between 1 and %d", version, NamingVersion))
+	}
+
+	renames := []Rename{}
+	for _, rename := range Renames {
+		if rename.Version > version {
+			renames = append(renames, rename)
+		}
+	}
+
+	return renames, nil
+}
+
+// CompatAliases returns the aliases emitting the metrics renamed after the
+// naming version under their old names and labels, alongside the new ones.
+func CompatAliases(namespace string, version int) ([]Alias, error) {
+	renames, err := RenamesSince(version)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := []Alias{}
+	for _, rename := range renames {
+		labels := map[string]string{}
+		for oldLabel, label := range rename.Labels {
+			labels[label] = oldLabel
+		}
+		aliases = append(aliases, Alias{
+			Metric: rename.Metric,
+			Name:   namespacedName(namespace, rename.OldMetric),
+			Labels: labels,
+		})
+	}
+
+	return aliases, nil
+}
+
+// MigrateRules rewrites the metric and label names of the naming version used
+// by a Prometheus rule file to the current ones, returning the rewritten file
+// and the renamed identifiers. Identifiers are replaced as whole words
+// anywhere in the file, so comments and formatting are kept, and the labels
+// are only renamed on the lines using a renamed metric.
+func MigrateRules(data []byte, namespace string, version int) ([]byte, []string, error) {
+	renames, err := RenamesSince(version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metrics := map[string]string{}
+	labelsByMetric := map[string]map[string]string{}
+	for _, rename := range renames {
+		metric := namespacedName(namespace, rename.Metric)
+		metrics[namespacedName(namespace, rename.OldMetric)] = metric
+		if len(rename.Labels) > 0 {
+			labelsByMetric[metric] = rename.Labels
+		}
+	}
+
+	changes := map[string]bool{}
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		labels := map[string]string{}
+		line = replaceIdentifiers(line, func(identifier string) string {
+			metric, ok := metrics[identifier]
+			if !ok {
+				return identifier
+			}
+			changes[identifier+" -> "+metric] = true
+			for oldLabel, label := range labelsByMetric[metric] {
+				labels[oldLabel] = label
+			}
+			return metric
+		})
+		if len(labels) > 0 {
+			line = replaceIdentifiers(line, func(identifier string) string {
+				label, ok := labels[identifier]
+				if !ok {
+					return identifier
+				}
+				changes[identifier+" -> "+label] = true
+				return label
+			})
+		}
+		lines[i] = line
+	}
+
+	changeList := []string{}
+	for change := range changes {
+		changeList = append(changeList, change)
+	}
+	sort.Strings(changeList)
+
+	return []byte(strings.Join(lines, "\n")), changeList, nil
+}
+
+func replaceIdentifiers(line string, replace func(string) string) string {
+	replaced := ""
+	last := 0
+	for _, loc := range identifierRegexp.FindAllStringIndex(line, -1) {
+		replaced += line[last:loc[0]] + replace(line[loc[0]:loc[1]])
+		last = loc[1]
+	}
+
+	return replaced + line[last:]
+}
+
+func namespacedName(namespace string, name string) string {
+	if namespace == "" {
+		return name
+	}
+
+	return namespace + "_" + name
+}
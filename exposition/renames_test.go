@@ -0,0 +1,133 @@
+package exposition_test
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/exposition"
+)
+
+var _ = Describe("Renames", func() {
+	var (
+		renames       []Rename
+		namingVersion int
+	)
+
+	BeforeEach(func() {
+		renames = Renames
+		namingVersion = NamingVersion
+		NamingVersion = 2
+		Renames = []Rename{
+			{Version: 2, OldMetric: "last_scrape_timestamp", Metric: "last_scrape_timestamp_seconds"},
+			{Version: 2, OldMetric: "job_process_mem", Metric: "job_process_mem_bytes", Labels: map[string]string{"bosh_job_process_name": "bosh_process_name"}},
+		}
+	})
+
+	AfterEach(func() {
+		Renames = renames
+		NamingVersion = namingVersion
+	})
+
+	Describe("RenamesSince", func() {
+		It("returns the renames applied after the naming version", func() {
+			Expect(RenamesSince(1)).To(Equal(Renames))
+			Expect(RenamesSince(2)).To(BeEmpty())
+		})
+
+		It("fails for an unknown naming version", func() {
+			_, err := RenamesSince(NamingVersion + 1)
+			Expect(err).To(HaveOccurred())
+			_, err = RenamesSince(0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("CompatAliases", func() {
+		It("emits the renamed metrics under their old names and labels", func() {
+			registry := prometheus.NewRegistry()
+			lastScrapeTimestamp := prometheus.NewGauge(
+				prometheus.GaugeOpts{Namespace: "test", Name: "last_scrape_timestamp_seconds", Help: "Last scrape."},
+			)
+			lastScrapeTimestamp.Set(1000)
+			registry.MustRegister(lastScrapeTimestamp)
+
+			aliases, err := CompatAliases("test", 1)
+			Expect(err).ToNot(HaveOccurred())
+
+			metricFamilies := scrape(AliasGatherer(registry, "test", aliases))
+			Expect(scrapedFamily(metricFamilies, "test_last_scrape_timestamp_seconds").GetMetric()).To(HaveLen(1))
+			oldFamily := scrapedFamily(metricFamilies, "test_last_scrape_timestamp")
+			Expect(oldFamily).ToNot(BeNil())
+			Expect(oldFamily.GetMetric()[0].GetGauge().GetValue()).To(Equal(float64(1000)))
+		})
+
+		It("renames the labels back", func() {
+			aliases, err := CompatAliases("test", 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(aliases[1]).To(Equal(Alias{
+				Metric: "job_process_mem_bytes",
+				Name:   "test_job_process_mem",
+				Labels: map[string]string{"bosh_process_name": "bosh_job_process_name"},
+			}))
+		})
+
+		It("returns no alias for the current naming version", func() {
+			Expect(CompatAliases("test", NamingVersion)).To(BeEmpty())
+		})
+	})
+
+	Describe("MigrateRules", func() {
+		var (
+			rules string
+		)
+
+		BeforeEach(func() {
+			rules = `groups:
+- name: bosh
+  rules:
+  # Alert on bosh_last_scrape_timestamp staleness
+  - alert: BOSHStale
+    expr: time() - bosh_last_scrape_timestamp > 600 and bosh_last_scrape_timestamp_total > 0
+  - alert: BOSHProcessMemory
+    expr: sum by (bosh_job_process_name) (bosh_job_process_mem{bosh_job_process_name="nats"}) > 1e9
+    labels:
+      bosh_job_process_name: nats
+`
+		})
+
+		It("rewrites the renamed metrics and their labels", func() {
+			migrated, changes, err := MigrateRules([]byte(rules), "bosh", 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(migrated)).To(Equal(`groups:
+- name: bosh
+  rules:
+  # Alert on bosh_last_scrape_timestamp_seconds staleness
+  - alert: BOSHStale
+    expr: time() - bosh_last_scrape_timestamp_seconds > 600 and bosh_last_scrape_timestamp_total > 0
+  - alert: BOSHProcessMemory
+    expr: sum by (bosh_process_name) (bosh_job_process_mem_bytes{bosh_process_name="nats"}) > 1e9
+    labels:
+      bosh_job_process_name: nats
+`))
+			Expect(changes).To(Equal([]string{
+				"bosh_job_process_mem -> bosh_job_process_mem_bytes",
+				"bosh_job_process_name -> bosh_process_name",
+				"bosh_last_scrape_timestamp -> bosh_last_scrape_timestamp_seconds",
+			}))
+		})
+
+		It("keeps the rules already using the current naming version", func() {
+			migrated, changes, err := MigrateRules([]byte(rules), "bosh", NamingVersion)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(migrated)).To(Equal(rules))
+			Expect(changes).To(BeEmpty())
+		})
+
+		It("fails for an unknown naming version", func() {
+			_, _, err := MigrateRules([]byte(rules), "bosh", 0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,94 @@
+package features
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	StreamDeployments = "stream-deployments"
+	VitalsHistograms  = "vitals-histograms"
+)
+
+// Feature is an experimental subsystem shipped disabled, that an installation
+// opts into with `--enable-feature`.
+type Feature struct {
+	Name        string
+	Description string
+}
+
+// Known lists the features that can be enabled.
+var Known = []Feature{
+	{
+		Name:        StreamDeployments,
+		Description: "Fetch the deployments one at a time and stream them to the collectors, as bosh.stream-deployments",
+	},
+	{
+		Name:        VitalsHistograms,
+		Description: "Expose the distribution of the process CPU and memory across every deployment as histograms",
+	},
+}
+
+// Gates tells which features are enabled.
+type Gates struct {
+	enabled map[string]bool
+}
+
+// NewGates enables the features named in values, each being a comma
+// separated list, and fails on features missing from known.
+func NewGates(known []Feature, values []string) (*Gates, error) {
+	knownNames := map[string]bool{}
+	for _, feature := range known {
+		knownNames[feature.Name] = true
+	}
+
+	gates := &Gates{enabled: map[string]bool{}}
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !knownNames[name] {
+				return nil, errors.New(fmt.Sprintf("Unknown feature `%s`, known features are: %s", name, strings.Join(names(known), ", ")))
+			}
+			gates.enabled[name] = true
+		}
+	}
+
+	return gates, nil
+}
+
+// Enabled tells whether a feature is enabled, a nil Gates enabling none.
+func (g *Gates) Enabled(name string) bool {
+	if g == nil {
+		return false
+	}
+	return g.enabled[name]
+}
+
+// EnabledFeatures returns the sorted names of the enabled features.
+func (g *Gates) EnabledFeatures() []string {
+	enabled := []string{}
+	if g == nil {
+		return enabled
+	}
+	for name := range g.enabled {
+		enabled = append(enabled, name)
+	}
+	sort.Strings(enabled)
+
+	return enabled
+}
+
+func names(features []Feature) []string {
+	featureNames := make([]string, 0, len(features))
+	for _, feature := range features {
+		featureNames = append(featureNames, feature.Name)
+	}
+	sort.Strings(featureNames)
+
+	return featureNames
+}
@@ -0,0 +1,68 @@
+package features_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/bosh-prometheus/bosh_exporter/features"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+var _ = Describe("Gates", func() {
+	var (
+		known = []Feature{
+			{Name: "feature-a", Description: "Feature A"},
+			{Name: "feature-b", Description: "Feature B"},
+			{Name: "feature-c", Description: "Feature C"},
+		}
+	)
+
+	It("enables the comma separated and repeated features", func() {
+		gates, err := NewGates(known, []string{"feature-c, feature-a", "feature-c"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gates.Enabled("feature-a")).To(BeTrue())
+		Expect(gates.Enabled("feature-b")).To(BeFalse())
+		Expect(gates.EnabledFeatures()).To(Equal([]string{"feature-a", "feature-c"}))
+	})
+
+	It("enables no feature by default", func() {
+		gates, err := NewGates(known, []string{""})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gates.EnabledFeatures()).To(BeEmpty())
+	})
+
+	It("fails on unknown features", func() {
+		_, err := NewGates(known, []string{"feature-a,feature-z"})
+		Expect(err).To(MatchError("Unknown feature `feature-z`, known features are: feature-a, feature-b, feature-c"))
+	})
+
+	It("enables no feature when nil", func() {
+		var gates *Gates
+		Expect(gates.Enabled("feature-a")).To(BeFalse())
+		Expect(gates.EnabledFeatures()).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Info", func() {
+	It("returns an exporter_feature_info metric per enabled feature", func() {
+		gates, err := NewGates(Known, []string{StreamDeployments})
+		Expect(err).ToNot(HaveOccurred())
+
+		featureInfoMetric := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "test_exporter",
+				Subsystem: "exporter",
+				Name:      "feature_info",
+				Help:      "Experimental feature enabled with --enable-feature, with a constant '1' value.",
+			},
+			[]string{"feature"},
+		)
+		featureInfoMetric.WithLabelValues(StreamDeployments).Set(float64(1))
+
+		metrics := make(chan prometheus.Metric)
+		go NewInfo("test_exporter", gates).Collect(metrics)
+		Eventually(metrics).Should(Receive(PrometheusMetric(featureInfoMetric.WithLabelValues(StreamDeployments))))
+	})
+})
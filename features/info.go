@@ -0,0 +1,23 @@
+package features
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewInfo returns a collector reporting an info metric per enabled feature.
+func NewInfo(namespace string, gates *Gates) prometheus.Collector {
+	featureInfoMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "feature_info",
+			Help:      "Experimental feature enabled with --enable-feature, with a constant '1' value.",
+		},
+		[]string{"feature"},
+	)
+	for _, name := range gates.EnabledFeatures() {
+		featureInfoMetric.WithLabelValues(name).Set(float64(1))
+	}
+
+	return featureInfoMetric
+}
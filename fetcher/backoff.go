@@ -0,0 +1,52 @@
+package fetcher
+
+import (
+	"time"
+)
+
+// Backoff returns exponentially growing waits between the attempts of a
+// retried operation, from min doubling up to max.
+type Backoff struct {
+	min      time.Duration
+	max      time.Duration
+	attempts uint
+}
+
+func NewBackoff(min time.Duration, max time.Duration) *Backoff {
+	return &Backoff{min: min, max: max}
+}
+
+// Next returns the wait before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	wait := b.min
+	for i := uint(0); i < b.attempts && wait < b.max; i++ {
+		wait *= 2
+	}
+	if wait > b.max {
+		wait = b.max
+	}
+	b.attempts++
+
+	return wait
+}
+
+// Retry calls fn until it succeeds, waiting the next backoff after every
+// failure, which is reported to onError. It gives up with the last error when
+// stop is closed.
+func Retry(fn func() error, backoff *Backoff, stop <-chan struct{}, onError func(err error, wait time.Duration)) error {
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		wait := backoff.Next()
+		onError(err, wait)
+
+		select {
+		case <-time.After(wait):
+		case <-stop:
+			return err
+		}
+	}
+}
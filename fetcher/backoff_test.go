@@ -0,0 +1,62 @@
+package fetcher_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+var _ = Describe("Backoff", func() {
+	It("doubles the wait up to the maximum", func() {
+		backoff := NewBackoff(time.Second, 5*time.Second)
+		waits := []time.Duration{}
+		for i := 0; i < 5; i++ {
+			waits = append(waits, backoff.Next())
+		}
+		Expect(waits).To(Equal([]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}))
+	})
+})
+
+var _ = Describe("Retry", func() {
+	var (
+		attempts int
+		failures []error
+		onError  func(err error, wait time.Duration)
+	)
+
+	BeforeEach(func() {
+		attempts = 0
+		failures = []error{}
+		onError = func(err error, wait time.Duration) {
+			failures = append(failures, err)
+		}
+	})
+
+	It("retries until the operation succeeds", func() {
+		err := Retry(func() error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("director unavailable")
+			}
+			return nil
+		}, NewBackoff(time.Millisecond, 10*time.Millisecond), make(chan struct{}), onError)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(attempts).To(Equal(3))
+		Expect(failures).To(HaveLen(2))
+	})
+
+	It("returns the last error when stopped", func() {
+		stop := make(chan struct{})
+		close(stop)
+		err := Retry(func() error {
+			attempts++
+			return errors.New("director unavailable")
+		}, NewBackoff(time.Hour, time.Hour), stop, onError)
+		Expect(err).To(MatchError("director unavailable"))
+		Expect(attempts).To(Equal(1))
+	})
+})
@@ -0,0 +1,138 @@
+package fetcher
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+	"unsafe"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/uaa"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"github.com/cloudfoundry/bosh-utils/httpclient"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const (
+	boshClientMaxAttempts = 5
+	boshClientRetryDelay  = 500 * time.Millisecond
+)
+
+// NewDirector returns a BOSH Director client sending its requests through
+// httpClient (a default BOSH client trusting the config CA certificate when
+// nil) to the Director API served under pathPrefix. It builds the client the
+// way the bosh-cli director factory does, which takes neither an HTTP client
+// nor a path prefix.
+func NewDirector(config director.FactoryConfig, pathPrefix string, httpClient *http.Client, logger boshlog.Logger) (director.Director, error) {
+	if err := config.Validate(); err != nil {
+		return nil, bosherr.WrapErrorf(err, "Validating Director connection config")
+	}
+
+	rawClient, err := boshRawClient(httpClient, config.CACertPool)
+	if err != nil {
+		return nil, err
+	}
+
+	host := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	authAdjustment := director.NewAuthRequestAdjustment(config.TokenFunc, config.Client, config.ClientSecret)
+	rawClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > 10 {
+			return bosherr.Error("Too many redirects")
+		}
+
+		// Redirected requests are not retried, so adjust the auth token now.
+		if err := authAdjustment.Adjust(req, true); err != nil {
+			return err
+		}
+
+		req.URL.Host = host
+
+		authorization := req.Header.Get("Authorization")
+		req.Header = http.Header{}
+		if authorization != "" {
+			req.Header.Add("Authorization", authorization)
+		}
+		req.Body = nil
+
+		return nil
+	}
+
+	retryClient := httpclient.NewNetworkSafeRetryClient(rawClient, boshClientMaxAttempts, boshClientRetryDelay, logger)
+	authedClient := director.NewAdjustableClient(retryClient, authAdjustment)
+	endpoint := url.URL{Scheme: "https", Host: host, Path: pathPrefix}
+	client := director.NewClient(
+		endpoint.String(),
+		httpclient.NewHTTPClientOpts(authedClient, logger, httpclient.Opts{NoRedactUrlQuery: true}),
+		director.NewNoopTaskReporter(),
+		director.NewNoopFileReporter(),
+		logger,
+	)
+
+	boshDirector := &director.DirectorImpl{}
+	if err := setUnexportedField(boshDirector, "client", client); err != nil {
+		return nil, err
+	}
+
+	return *boshDirector, nil
+}
+
+// NewUAA returns a UAA client sending its requests through httpClient (a
+// default BOSH client trusting the config CA certificate when nil), the way
+// the bosh-cli UAA factory builds it, which does not take an HTTP client.
+func NewUAA(config uaa.Config, httpClient *http.Client, logger boshlog.Logger) (uaa.UAA, error) {
+	if err := config.Validate(); err != nil {
+		return nil, bosherr.WrapErrorf(err, "Validating UAA connection config")
+	}
+
+	rawClient, err := boshRawClient(httpClient, config.CACertPool)
+	if err != nil {
+		return nil, err
+	}
+
+	retryClient := httpclient.NewNetworkSafeRetryClient(rawClient, boshClientMaxAttempts, boshClientRetryDelay, logger)
+	endpoint := url.URL{Scheme: "https", Host: net.JoinHostPort(config.Host, strconv.Itoa(config.Port)), Path: config.Path}
+	client := uaa.NewClient(endpoint.String(), config.Client, config.ClientSecret, httpclient.NewHTTPClient(retryClient, logger), logger)
+
+	boshUAA := &uaa.UAAImpl{}
+	if err := setUnexportedField(boshUAA, "client", client); err != nil {
+		return nil, err
+	}
+
+	return *boshUAA, nil
+}
+
+// boshRawClient returns a copy of httpClient, so its redirect policy can be
+// set without changing the shared client.
+func boshRawClient(httpClient *http.Client, caCertPool func() (*x509.CertPool, error)) (*http.Client, error) {
+	if httpClient != nil {
+		rawClient := *httpClient
+		return &rawClient, nil
+	}
+
+	certPool, err := caCertPool()
+	if err != nil {
+		return nil, err
+	}
+
+	return httpclient.CreateDefaultClient(certPool), nil
+}
+
+// setUnexportedField sets the unexported field of a bosh-cli client wrapper
+// that the bosh-cli only sets from its own factories. It fails rather than
+// corrupting memory if a bosh-cli update changes the field.
+func setUnexportedField(target interface{}, name string, value interface{}) error {
+	field := reflect.ValueOf(target).Elem().FieldByName(name)
+	if !field.IsValid() || field.Type() != reflect.TypeOf(value) {
+		return errors.New(fmt.Sprintf("Unsupported bosh-cli version: %T has no `%s` field of type %T", target, name, value))
+	}
+
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(reflect.ValueOf(value))
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package fetcher_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/uaa"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+var _ = Describe("NewDirector", func() {
+	var (
+		server   *httptest.Server
+		requests []*http.Request
+		config   director.FactoryConfig
+	)
+
+	BeforeEach(func() {
+		requests = []*http.Request{}
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			w.Write([]byte(`{"name":"bosh-lite","uuid":"fake-uuid","version":"1.0.0","user_authentication":{"type":"basic","options":{}}}`))
+		}))
+
+		host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		config = director.FactoryConfig{Host: host, Client: "admin", ClientSecret: "secret"}
+		config.Port, err = strconv.Atoi(port)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends the requests through the HTTP client under the path prefix", func() {
+		boshDirector, err := NewDirector(config, "/bosh", server.Client(), boshlog.NewLogger(boshlog.LevelNone))
+		Expect(err).ToNot(HaveOccurred())
+
+		info, err := boshDirector.Info()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Name).To(Equal("bosh-lite"))
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].URL.Path).To(Equal("/bosh/info"))
+		username, password, ok := requests[0].BasicAuth()
+		Expect(ok).To(BeTrue())
+		Expect(username).To(Equal("admin"))
+		Expect(password).To(Equal("secret"))
+	})
+
+	It("does not change the redirect policy of the HTTP client", func() {
+		httpClient := server.Client()
+		_, err := NewDirector(config, "", httpClient, boshlog.NewLogger(boshlog.LevelNone))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(httpClient.CheckRedirect).To(BeNil())
+	})
+
+	It("validates the config", func() {
+		_, err := NewDirector(director.FactoryConfig{}, "", server.Client(), boshlog.NewLogger(boshlog.LevelNone))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("NewUAA", func() {
+	var (
+		server   *httptest.Server
+		requests []*http.Request
+		config   uaa.Config
+	)
+
+	BeforeEach(func() {
+		requests = []*http.Request{}
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			w.Write([]byte(`{"token_type":"bearer","access_token":"fake-token"}`))
+		}))
+
+		var err error
+		config, err = uaa.NewConfigFromURL(server.URL + "/uaa")
+		Expect(err).ToNot(HaveOccurred())
+		config.Client = "exporter"
+		config.ClientSecret = "secret"
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends the requests through the HTTP client", func() {
+		boshUAA, err := NewUAA(config, server.Client(), boshlog.NewLogger(boshlog.LevelNone))
+		Expect(err).ToNot(HaveOccurred())
+
+		token, err := boshUAA.ClientCredentialsGrant()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.Value()).To(Equal("fake-token"))
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].URL.Path).To(Equal("/uaa/oauth/token"))
+	})
+
+	It("validates the config", func() {
+		_, err := NewUAA(uaa.Config{}, server.Client(), boshlog.NewLogger(boshlog.LevelNone))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,200 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// CABundle holds the CA certificates trusted to connect to a BOSH Director,
+// reloading them when the CA files change.
+type CABundle struct {
+	files             []string
+	useSystem         bool
+	revocationChecker *RevocationChecker
+	pool              atomic.Value
+	modTimes          map[string]time.Time
+	mu                *sync.Mutex
+}
+
+func NewCABundle(files []string, useSystem bool) (*CABundle, error) {
+	bundle := &CABundle{files: files, useSystem: useSystem, mu: &sync.Mutex{}}
+	if _, err := bundle.Reload(); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+// CertPool returns nil when neither CA files nor the system trust store are
+// configured, so the system trust store is used.
+func (b *CABundle) CertPool() *x509.CertPool {
+	pool, _ := b.pool.Load().(*x509.CertPool)
+	return pool
+}
+
+// SetRevocationChecker makes TLSConfig also check the revocation status of
+// the server certificates.
+func (b *CABundle) SetRevocationChecker(revocationChecker *RevocationChecker) {
+	b.revocationChecker = revocationChecker
+}
+
+// ChainVerifier checks the verified certificate chains of a server once its
+// certificate verified against the CA certificates.
+type ChainVerifier func(verifiedChains [][]*x509.Certificate) error
+
+// TLSConfig verifies the server certificates against the current CA
+// certificates, so reloaded CAs apply to new connections without
+// rebuilding the HTTP clients. The certificates must be valid for the host
+// the connection is established to, or for serverName when that host is an
+// IP address (the TLS connection state only carries DNS server names). The
+// verifiers are then run on the verified chains, never on the unverified
+// certificates sent by the server.
+func (b *CABundle) TLSConfig(serverName string, verifiers ...ChainVerifier) *tls.Config {
+	tlsConfig := &tls.Config{}
+	if len(b.files) == 0 {
+		if b.revocationChecker != nil || len(verifiers) > 0 {
+			tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+				return b.checkChains(state.VerifiedChains, state.OCSPResponse, verifiers)
+			}
+		}
+		return tlsConfig
+	}
+
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return errors.New("tls: server did not present a certificate")
+		}
+
+		dnsName := state.ServerName
+		if dnsName == "" {
+			dnsName = serverName
+		}
+		if dnsName == "" {
+			return errors.New("tls: no server name to verify the server certificate against")
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, certificate := range state.PeerCertificates[1:] {
+			intermediates.AddCert(certificate)
+		}
+		chains, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+			DNSName:       dnsName,
+			Roots:         b.CertPool(),
+			Intermediates: intermediates,
+		})
+		if err != nil {
+			return err
+		}
+		return b.checkChains(chains, state.OCSPResponse, verifiers)
+	}
+
+	return tlsConfig
+}
+
+func (b *CABundle) checkChains(verifiedChains [][]*x509.Certificate, ocspResponse []byte, verifiers []ChainVerifier) error {
+	if b.revocationChecker != nil && len(verifiedChains) > 0 {
+		if err := b.revocationChecker.Check(verifiedChains[0], ocspResponse); err != nil {
+			return err
+		}
+	}
+
+	for _, verifier := range verifiers {
+		if err := verifier(verifiedChains); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reload reads the CA files again if any of them changed since the last
+// read, keeping the current CA certificates when they cannot be read.
+func (b *CABundle) Reload() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	modTimes := map[string]time.Time{}
+	changed := b.modTimes == nil
+	for _, file := range b.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false, fmt.Errorf("Error reading CA certificate file `%s`: %v", file, err)
+		}
+		modTimes[file] = info.ModTime()
+		if !info.ModTime().Equal(b.modTimes[file]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	pool, err := b.loadCertPool()
+	if err != nil {
+		return false, err
+	}
+	if pool != nil {
+		b.pool.Store(pool)
+	}
+	b.modTimes = modTimes
+
+	return true, nil
+}
+
+func (b *CABundle) loadCertPool() (*x509.CertPool, error) {
+	if len(b.files) == 0 {
+		return nil, nil
+	}
+
+	pool := x509.NewCertPool()
+	if b.useSystem {
+		systemPool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the system CA certificates: %v", err)
+		}
+		pool = systemPool
+	}
+
+	for _, file := range b.files {
+		caCert, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading CA certificate file `%s`: %v", file, err)
+		}
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("CA certificate file `%s` does not contain any PEM certificate", file)
+		}
+	}
+
+	return pool, nil
+}
+
+func (b *CABundle) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			changed, err := b.Reload()
+			if err != nil {
+				log.Errorf("Error reloading CA certificates, keeping the previous ones: %v", err)
+				continue
+			}
+			if changed {
+				log.Infof("Reloaded CA certificates from %v", b.files)
+			}
+		}
+	}
+}
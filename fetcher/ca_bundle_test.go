@@ -0,0 +1,201 @@
+package fetcher_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+func newSelfSignedTLSServer() (*httptest.Server, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "bosh-director"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+
+	return server, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+var _ = Describe("CABundle", func() {
+	var (
+		err       error
+		server    *httptest.Server
+		serverCA  []byte
+		caFile    string
+		caBundle  *CABundle
+		useSystem bool
+	)
+
+	get := func(url string) error {
+		resp, err := NewTLSClient(caBundle.TLSConfig("127.0.0.1"), nil).Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	BeforeEach(func() {
+		useSystem = false
+		server, serverCA = newSelfSignedTLSServer()
+
+		tmpfile, tmpErr := ioutil.TempFile("", "ca_bundle_test_")
+		Expect(tmpErr).ToNot(HaveOccurred())
+		_, tmpErr = tmpfile.Write(serverCA)
+		Expect(tmpErr).ToNot(HaveOccurred())
+		Expect(tmpfile.Close()).To(Succeed())
+		caFile = tmpfile.Name()
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.Remove(caFile)
+	})
+
+	JustBeforeEach(func() {
+		caBundle, err = NewCABundle([]string{caFile}, useSystem)
+	})
+
+	It("trusts the servers signed by the CA files", func() {
+		Expect(err).ToNot(HaveOccurred())
+		Expect(get(server.URL)).To(Succeed())
+	})
+
+	It("does not trust other servers", func() {
+		otherServer, _ := newSelfSignedTLSServer()
+		defer otherServer.Close()
+
+		Expect(get(otherServer.URL)).ToNot(Succeed())
+	})
+
+	Context("when the server presents a certificate for another host", func() {
+		var (
+			otherHostServer *httptest.Server
+		)
+
+		BeforeEach(func() {
+			ca := newTestCA()
+			leaf := ca.issueFor(1, nil, []string{"other-director.example.com"})
+			otherHostServer = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("{}"))
+			}))
+			otherHostServer.TLS = &tls.Config{Certificates: []tls.Certificate{leaf}}
+			otherHostServer.StartTLS()
+
+			Expect(ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw}), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			otherHostServer.Close()
+		})
+
+		It("does not trust the server reached by IP address", func() {
+			Expect(err).ToNot(HaveOccurred())
+
+			err := get(otherHostServer.URL)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("127.0.0.1"))
+		})
+	})
+
+	Context("when using the system CAs", func() {
+		BeforeEach(func() {
+			useSystem = true
+		})
+
+		It("trusts the servers signed by the CA files", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(get(server.URL)).To(Succeed())
+		})
+	})
+
+	Context("when a CA file does not exist", func() {
+		BeforeEach(func() {
+			os.Remove(caFile)
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when a CA file does not contain any certificate", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(caFile, []byte("not a certificate"), 0644)).To(Succeed())
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("does not contain any PEM certificate"))
+		})
+	})
+
+	Describe("Reload", func() {
+		var (
+			rotatedServer *httptest.Server
+			rotatedCA     []byte
+		)
+
+		BeforeEach(func() {
+			rotatedServer, rotatedCA = newSelfSignedTLSServer()
+		})
+
+		AfterEach(func() {
+			rotatedServer.Close()
+		})
+
+		It("does not reload unchanged CA files", func() {
+			Expect(caBundle.Reload()).To(BeFalse())
+		})
+
+		It("trusts the rotated CA once the CA files changed", func() {
+			Expect(ioutil.WriteFile(caFile, rotatedCA, 0644)).To(Succeed())
+			Expect(os.Chtimes(caFile, time.Now(), time.Now().Add(time.Minute))).To(Succeed())
+
+			Expect(caBundle.Reload()).To(BeTrue())
+			Expect(get(rotatedServer.URL)).To(Succeed())
+			Expect(get(server.URL)).ToNot(Succeed())
+		})
+
+		It("keeps the previous CAs when the CA files are invalid", func() {
+			Expect(ioutil.WriteFile(caFile, []byte("not a certificate"), 0644)).To(Succeed())
+			Expect(os.Chtimes(caFile, time.Now(), time.Now().Add(time.Minute))).To(Succeed())
+
+			_, err := caBundle.Reload()
+			Expect(err).To(HaveOccurred())
+			Expect(get(server.URL)).To(Succeed())
+		})
+	})
+})
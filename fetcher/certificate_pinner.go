@@ -0,0 +1,101 @@
+package fetcher
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/common/log"
+)
+
+// CertificatePinner refuses the BOSH Director connections whose verified
+// certificate chains match none of the pinned SHA-256 fingerprints, so a
+// certificate issued by a compromised CA is not trusted even though it
+// verifies. A pin matches either the DER certificate or its public key
+// (SubjectPublicKeyInfo), of the leaf, of an intermediate or of the CA of a
+// verified chain. The extra certificates a server sends that are not part of
+// a verified chain never match, so they cannot be used to forge a pin.
+type CertificatePinner struct {
+	pins     map[string]bool
+	failures uint64
+}
+
+func NewCertificatePinner(pins []string) (*CertificatePinner, error) {
+	pinner := &CertificatePinner{pins: map[string]bool{}}
+	for _, pin := range pins {
+		fingerprint, err := ParseCertificatePin(pin)
+		if err != nil {
+			return nil, err
+		}
+		pinner.pins[fingerprint] = true
+	}
+	if len(pinner.pins) == 0 {
+		return nil, errors.New("No certificate pin")
+	}
+
+	return pinner, nil
+}
+
+// ParseCertificatePin returns the lowercase hex SHA-256 fingerprint of a pin,
+// written in hex (with or without colons) or in base64 (optionally prefixed
+// by `sha256//` as with curl --pinnedpubkey).
+func ParseCertificatePin(pin string) (string, error) {
+	value := strings.TrimSpace(pin)
+	if strings.HasPrefix(value, "sha256//") {
+		value = strings.TrimPrefix(value, "sha256//")
+	} else {
+		if fingerprint, err := hex.DecodeString(strings.Replace(value, ":", "", -1)); err == nil && len(fingerprint) == sha256.Size {
+			return hex.EncodeToString(fingerprint), nil
+		}
+	}
+
+	fingerprint, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(fingerprint) != sha256.Size {
+		return "", errors.New(fmt.Sprintf("Invalid certificate pin `%s`, must be a hex or base64 SHA-256 fingerprint", pin))
+	}
+
+	return hex.EncodeToString(fingerprint), nil
+}
+
+// CertificatePins returns the hex SHA-256 fingerprints of the DER certificate
+// and of the public key of a certificate.
+func CertificatePins(certificate *x509.Certificate) (string, string) {
+	certificateSum := sha256.Sum256(certificate.Raw)
+	publicKeySum := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+
+	return hex.EncodeToString(certificateSum[:]), hex.EncodeToString(publicKeySum[:])
+}
+
+func (p *CertificatePinner) Failures() uint64 {
+	return atomic.LoadUint64(&p.failures)
+}
+
+// Check returns an error, logged and counted, when no certificate of the
+// verified chains matches a pin. It is a ChainVerifier of CABundle.TLSConfig.
+func (p *CertificatePinner) Check(verifiedChains [][]*x509.Certificate) error {
+	for _, chain := range verifiedChains {
+		for _, certificate := range chain {
+			certificatePin, publicKeyPin := CertificatePins(certificate)
+			if p.pins[certificatePin] || p.pins[publicKeyPin] {
+				return nil
+			}
+		}
+	}
+
+	atomic.AddUint64(&p.failures, 1)
+	if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+		log.Errorf("BOSH Director did not present a verified certificate to check against the certificate pins")
+		return errors.New("tls: certificate pinning failed: no verified certificate")
+	}
+
+	leaf := verifiedChains[0][0]
+	certificatePin, publicKeyPin := CertificatePins(leaf)
+	log.Errorf("BOSH Director certificate `%s` matches no certificate pin (certificate SHA-256 `%s`, public key SHA-256 `%s`)", leaf.Subject, certificatePin, publicKeyPin)
+
+	return errors.New(fmt.Sprintf("tls: certificate pinning failed: certificate `%s` matches no pin", leaf.Subject))
+}
@@ -0,0 +1,174 @@
+package fetcher_test
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+var _ = Describe("CertificatePinner", func() {
+	var (
+		server      *httptest.Server
+		certificate *x509.Certificate
+		caFile      string
+	)
+
+	get := func(pinner *CertificatePinner) error {
+		caBundle, err := NewCABundle([]string{caFile}, false)
+		Expect(err).ToNot(HaveOccurred())
+		tlsConfig := caBundle.TLSConfig("127.0.0.1", pinner.Check)
+
+		resp, err := NewTLSClient(tlsConfig, nil).Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	BeforeEach(func() {
+		var serverCA []byte
+		server, serverCA = newSelfSignedTLSServer()
+		caFile = writeTempFile(serverCA)
+
+		block, _ := pem.Decode(serverCA)
+		var err error
+		certificate, err = x509.ParseCertificate(block.Bytes)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.Remove(caFile)
+	})
+
+	It("accepts a server presenting the pinned certificate", func() {
+		certificatePin, _ := CertificatePins(certificate)
+		pinner, err := NewCertificatePinner([]string{certificatePin})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(get(pinner)).To(Succeed())
+		Expect(pinner.Failures()).To(Equal(uint64(0)))
+	})
+
+	It("accepts a server presenting the pinned public key", func() {
+		publicKeySum := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+		pinner, err := NewCertificatePinner([]string{"sha256//" + base64.StdEncoding.EncodeToString(publicKeySum[:])})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(get(pinner)).To(Succeed())
+	})
+
+	It("refuses and counts a server matching no pin", func() {
+		otherSum := sha256.Sum256([]byte("other"))
+		pinner, err := NewCertificatePinner([]string{hex.EncodeToString(otherSum[:])})
+		Expect(err).ToNot(HaveOccurred())
+
+		err = get(pinner)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("certificate pinning failed"))
+		Expect(pinner.Failures()).To(Equal(uint64(1)))
+	})
+
+	It("does not check the pins of an untrusted server", func() {
+		certificatePin, _ := CertificatePins(certificate)
+		pinner, err := NewCertificatePinner([]string{certificatePin})
+		Expect(err).ToNot(HaveOccurred())
+
+		otherServer, otherCA := newSelfSignedTLSServer()
+		defer otherServer.Close()
+		os.Remove(caFile)
+		caFile = writeTempFile(otherCA)
+
+		Expect(get(pinner)).ToNot(Succeed())
+		Expect(pinner.Failures()).To(Equal(uint64(0)))
+	})
+
+	It("does not match the pins against certificates outside of the verified chain", func() {
+		certificatePin, _ := CertificatePins(certificate)
+		pinner, err := NewCertificatePinner([]string{certificatePin})
+		Expect(err).ToNot(HaveOccurred())
+
+		ca := newTestCA()
+		leaf := ca.issue(1)
+		leaf.Certificate = append(leaf.Certificate, certificate.Raw)
+		forgedServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		}))
+		forgedServer.TLS = &tls.Config{Certificates: []tls.Certificate{leaf}}
+		forgedServer.StartTLS()
+		defer forgedServer.Close()
+		server.Close()
+		server = forgedServer
+		os.Remove(caFile)
+		caFile = writeTempFile(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw}))
+
+		err = get(pinner)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("certificate pinning failed"))
+		Expect(pinner.Failures()).To(Equal(uint64(1)))
+	})
+
+	It("accepts a pinned CA of the verified chain", func() {
+		ca := newTestCA()
+		certificatePin, _ := CertificatePins(ca.certificate)
+		pinner, err := NewCertificatePinner([]string{certificatePin})
+		Expect(err).ToNot(HaveOccurred())
+
+		caServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		}))
+		caServer.TLS = &tls.Config{Certificates: []tls.Certificate{ca.issue(1)}}
+		caServer.StartTLS()
+		defer caServer.Close()
+		server.Close()
+		server = caServer
+		os.Remove(caFile)
+		caFile = writeTempFile(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw}))
+
+		Expect(get(pinner)).To(Succeed())
+	})
+
+	Describe("ParseCertificatePin", func() {
+		var (
+			fingerprint []byte
+		)
+
+		BeforeEach(func() {
+			sum := sha256.Sum256([]byte("director"))
+			fingerprint = sum[:]
+		})
+
+		It("parses hex pins with or without colons", func() {
+			colonPin := []string{}
+			for _, b := range fingerprint {
+				colonPin = append(colonPin, strings.ToUpper(hex.EncodeToString([]byte{b})))
+			}
+
+			Expect(ParseCertificatePin(hex.EncodeToString(fingerprint))).To(Equal(hex.EncodeToString(fingerprint)))
+			Expect(ParseCertificatePin(strings.Join(colonPin, ":"))).To(Equal(hex.EncodeToString(fingerprint)))
+		})
+
+		It("parses base64 pins", func() {
+			Expect(ParseCertificatePin(base64.StdEncoding.EncodeToString(fingerprint))).To(Equal(hex.EncodeToString(fingerprint)))
+			Expect(ParseCertificatePin("sha256//" + base64.StdEncoding.EncodeToString(fingerprint))).To(Equal(hex.EncodeToString(fingerprint)))
+		})
+
+		It("refuses pins that are not SHA-256 fingerprints", func() {
+			_, err := ParseCertificatePin("abcd")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid certificate pin `abcd`"))
+		})
+	})
+})
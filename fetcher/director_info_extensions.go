@@ -0,0 +1,69 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DirectorInfoExtensions captures the Director info fields reported by recent
+// BOSH Directors that the bosh-cli Info does not decode, reading them from the
+// info responses sent through the transport it wraps.
+type DirectorInfoExtensions struct {
+	mu               *sync.Mutex
+	trustedCertsSHA1 string
+}
+
+type directorInfoExtension struct {
+	TrustedCertsSHA1 string `json:"trusted_certs_sha1"`
+}
+
+func NewDirectorInfoExtensions() *DirectorInfoExtensions {
+	return &DirectorInfoExtensions{mu: &sync.Mutex{}}
+}
+
+// Wrap returns a transport capturing the extensions of the Director info read
+// through transport.
+func (e *DirectorInfoExtensions) Wrap(transport http.RoundTripper) http.RoundTripper {
+	return &directorInfoExtensionsTransport{transport: transport, extensions: e}
+}
+
+// TrustedCertsSHA1 returns the SHA1 of the trusted certificates the Director
+// last reported it installs on the VMs, empty when it does not report it.
+func (e *DirectorInfoExtensions) TrustedCertsSHA1() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.trustedCertsSHA1
+}
+
+type directorInfoExtensionsTransport struct {
+	transport  http.RoundTripper
+	extensions *DirectorInfoExtensions
+}
+
+func (t *directorInfoExtensionsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || req.Method != http.MethodGet || !strings.HasSuffix(req.URL.Path, "/info") {
+		return resp, err
+	}
+
+	info, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(info))
+
+	var extension directorInfoExtension
+	if err := json.Unmarshal(info, &extension); err == nil {
+		t.extensions.mu.Lock()
+		t.extensions.trustedCertsSHA1 = extension.TrustedCertsSHA1
+		t.extensions.mu.Unlock()
+	}
+
+	return resp, nil
+}
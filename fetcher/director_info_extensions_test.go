@@ -0,0 +1,62 @@
+package fetcher_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+var _ = Describe("DirectorInfoExtensions", func() {
+	var (
+		server     *httptest.Server
+		info       string
+		extensions *DirectorInfoExtensions
+		client     *http.Client
+	)
+
+	BeforeEach(func() {
+		info = `{"name":"fake-director","trusted_certs_sha1":"fake-trusted-certs-sha1"}`
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, info)
+		}))
+		extensions = NewDirectorInfoExtensions()
+		client = &http.Client{Transport: extensions.Wrap(http.DefaultTransport)}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	get := func(path string) string {
+		resp, err := client.Get(server.URL + path)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return string(body)
+	}
+
+	It("captures the trusted certificates of the Director info", func() {
+		Expect(get("/info")).To(Equal(info))
+		Expect(extensions.TrustedCertsSHA1()).To(Equal("fake-trusted-certs-sha1"))
+	})
+
+	It("does not capture the other responses", func() {
+		Expect(get("/deployments")).To(Equal(info))
+		Expect(extensions.TrustedCertsSHA1()).To(BeEmpty())
+	})
+
+	Context("when the Director does not report its trusted certificates", func() {
+		It("returns no trusted certificates", func() {
+			info = `{"name":"fake-director"}`
+			get("/info")
+			Expect(extensions.TrustedCertsSHA1()).To(BeEmpty())
+		})
+	})
+})
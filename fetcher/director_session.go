@@ -0,0 +1,142 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/cloudfoundry/bosh-utils/httpclient"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/tracing"
+)
+
+const directorSessionMaxIdleConns = 16
+
+// DirectorSession is the HTTP session shared by every request sent to a BOSH
+// Director, keeping connections and TLS sessions alive between requests.
+type DirectorSession struct {
+	client            *http.Client
+	responseCache     *ResponseCache
+	vmInfoExtensions  *deployments.VMInfoExtensions
+	infoExtensions    *DirectorInfoExtensions
+	startedTasks      *StartedTasks
+	newConnections    uint64
+	reusedConnections uint64
+}
+
+func NewDirectorSession(tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error), tracer *tracing.Tracer) *DirectorSession {
+	session := &DirectorSession{
+		vmInfoExtensions: deployments.NewVMInfoExtensions(),
+		infoExtensions:   NewDirectorInfoExtensions(),
+		startedTasks:     NewStartedTasks(),
+	}
+
+	client := NewTLSClient(tlsConfig, proxy)
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		transport.DisableKeepAlives = false
+		transport.MaxIdleConnsPerHost = directorSessionMaxIdleConns
+		transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	client.Transport = &sessionTransport{transport: client.Transport, session: session, tracer: tracer}
+	client.Transport = session.startedTasks.Wrap(session.infoExtensions.Wrap(session.vmInfoExtensions.Wrap(client.Transport)))
+	session.client = client
+
+	return session
+}
+
+// NewTLSClient returns the default BOSH HTTP client verifying the server
+// certificates with the given TLS configuration. A nil proxy keeps the proxy
+// set in the environment.
+func NewTLSClient(tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) *http.Client {
+	client := httpclient.CreateDefaultClient(tlsConfig.RootCAs)
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		if proxy != nil {
+			transport.Proxy = proxy
+		}
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.RootCAs = tlsConfig.RootCAs
+		transport.TLSClientConfig.InsecureSkipVerify = tlsConfig.InsecureSkipVerify
+		transport.TLSClientConfig.VerifyConnection = tlsConfig.VerifyConnection
+		transport.TLSClientConfig.MinVersion = tlsConfig.MinVersion
+		transport.TLSClientConfig.MaxVersion = tlsConfig.MaxVersion
+		transport.TLSClientConfig.CipherSuites = tlsConfig.CipherSuites
+		transport.TLSClientConfig.CurvePreferences = tlsConfig.CurvePreferences
+	}
+
+	return client
+}
+
+func (s *DirectorSession) HTTPClient() *http.Client {
+	return s.client
+}
+
+// VMInfoExtensions returns the instance fields captured from the Director
+// responses that the bosh-cli does not decode.
+func (s *DirectorSession) VMInfoExtensions() *deployments.VMInfoExtensions {
+	return s.vmInfoExtensions
+}
+
+// DirectorInfoExtensions returns the Director info fields captured from the
+// Director responses that the bosh-cli does not decode.
+func (s *DirectorSession) DirectorInfoExtensions() *DirectorInfoExtensions {
+	return s.infoExtensions
+}
+
+// StartedTasks returns the Director tasks started by the session requests.
+func (s *DirectorSession) StartedTasks() *StartedTasks {
+	return s.startedTasks
+}
+
+// CacheResponses sends conditional requests for the responses already read
+// through the session, see ResponseCache.
+func (s *DirectorSession) CacheResponses(maxBodyBytes int64) {
+	s.responseCache = NewResponseCache(maxBodyBytes)
+	s.client.Transport = s.responseCache.Wrap(s.client.Transport)
+}
+
+// ResponseCache returns nil unless CacheResponses was called.
+func (s *DirectorSession) ResponseCache() *ResponseCache {
+	return s.responseCache
+}
+
+func (s *DirectorSession) Connections() (newConnections uint64, reusedConnections uint64) {
+	return atomic.LoadUint64(&s.newConnections), atomic.LoadUint64(&s.reusedConnections)
+}
+
+type sessionTransport struct {
+	transport http.RoundTripper
+	session   *DirectorSession
+	tracer    *tracing.Tracer
+}
+
+func (t *sessionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddUint64(&t.session.reusedConnections, 1)
+			} else {
+				atomic.AddUint64(&t.session.newConnections, 1)
+			}
+		},
+	}
+
+	span := t.tracer.StartClient(t.tracer.Active(), req.Method+" "+req.URL.Path)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.target", req.URL.RequestURI())
+	defer span.Finish()
+
+	resp, err := t.transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		span.RecordError(err)
+		return resp, err
+	}
+	span.SetAttribute("http.status_code", strconv.Itoa(resp.StatusCode))
+
+	return resp, nil
+}
@@ -0,0 +1,79 @@
+package fetcher_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/bosh_exporter/tracing"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+type fakeTraceExporter struct {
+	spans []*tracing.Span
+}
+
+func (e *fakeTraceExporter) Export(resource map[string]string, spans []*tracing.Span) error {
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+var _ = Describe("DirectorSession", func() {
+	var (
+		server        *httptest.Server
+		traceExporter *fakeTraceExporter
+		tracer        *tracing.Tracer
+		session       *DirectorSession
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		}))
+		certPool := server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+		traceExporter = &fakeTraceExporter{}
+		tracer = tracing.NewTracer(traceExporter, map[string]string{})
+		session = NewDirectorSession(&tls.Config{RootCAs: certPool}, nil, tracer)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("reuses connections between requests", func() {
+		for i := 0; i < 3; i++ {
+			resp, err := session.HTTPClient().Get(server.URL + "/info")
+			Expect(err).ToNot(HaveOccurred())
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		newConnections, reusedConnections := session.Connections()
+		Expect(newConnections).To(Equal(uint64(1)))
+		Expect(reusedConnections).To(Equal(uint64(2)))
+	})
+
+	It("traces the requests as children of the active span", func() {
+		parent := tracer.Start(nil, "bosh.fetch")
+		tracer.SetActive(parent)
+
+		resp, err := session.HTTPClient().Get(server.URL + "/deployments?exclude_configs=true")
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+
+		Expect(tracer.Flush()).To(Succeed())
+		Expect(traceExporter.spans).To(HaveLen(1))
+		Expect(traceExporter.spans[0].Name).To(Equal("GET /deployments"))
+		Expect(traceExporter.spans[0].ParentSpanID).To(Equal(parent.SpanID))
+		Expect(traceExporter.spans[0].Attributes).To(Equal(map[string]string{
+			"http.method":      "GET",
+			"http.target":      "/deployments?exclude_configs=true",
+			"http.status_code": "200",
+		}))
+	})
+})
@@ -0,0 +1,30 @@
+package fetcher
+
+import (
+	"github.com/cloudfoundry/bosh-cli/director"
+)
+
+// EventsPageSize is the number of events the BOSH Director returns per page.
+const EventsPageSize = 200
+
+// ReadEvents reads the events matching filter, newest first, following the
+// pages of the Director up to maxPages pages. It returns the events read so
+// far along with the error of a failing page.
+func ReadEvents(boshClient director.Director, filter director.EventsFilter, maxPages int) ([]director.Event, error) {
+	events := []director.Event{}
+
+	for page := 0; page < maxPages; page++ {
+		pageEvents, err := boshClient.Events(filter)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, pageEvents...)
+
+		if len(pageEvents) < EventsPageSize {
+			break
+		}
+		filter.BeforeID = pageEvents[len(pageEvents)-1].ID()
+	}
+
+	return events, nil
+}
@@ -0,0 +1,63 @@
+package fetcher_test
+
+import (
+	"errors"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/director/directorfakes"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+var _ = Describe("ReadEvents", func() {
+	var (
+		boshClient *directorfakes.FakeDirector
+	)
+
+	page := func(firstID int, size int) []director.Event {
+		events := []director.Event{}
+		for id := firstID; id > firstID-size; id-- {
+			event := &directorfakes.FakeEvent{}
+			event.IDReturns(strconv.Itoa(id))
+			events = append(events, event)
+		}
+		return events
+	}
+
+	BeforeEach(func() {
+		boshClient = &directorfakes.FakeDirector{}
+	})
+
+	It("follows the pages of the Director", func() {
+		boshClient.EventsReturnsOnCall(0, page(1000, EventsPageSize), nil)
+		boshClient.EventsReturnsOnCall(1, page(800, 10), nil)
+
+		events, err := ReadEvents(boshClient, director.EventsFilter{Action: "update"}, 10)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(events).To(HaveLen(EventsPageSize + 10))
+		Expect(boshClient.EventsCallCount()).To(Equal(2))
+		Expect(boshClient.EventsArgsForCall(1)).To(Equal(director.EventsFilter{Action: "update", BeforeID: "801"}))
+	})
+
+	It("reads at most maxPages pages", func() {
+		boshClient.EventsReturns(page(1000, EventsPageSize), nil)
+
+		events, err := ReadEvents(boshClient, director.EventsFilter{}, 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(events).To(HaveLen(2 * EventsPageSize))
+		Expect(boshClient.EventsCallCount()).To(Equal(2))
+	})
+
+	It("returns the events read before a failing page", func() {
+		boshClient.EventsReturnsOnCall(0, page(1000, EventsPageSize), nil)
+		boshClient.EventsReturnsOnCall(1, nil, errors.New("no events"))
+
+		events, err := ReadEvents(boshClient, director.EventsFilter{}, 10)
+		Expect(err).To(MatchError("no events"))
+		Expect(events).To(HaveLen(EventsPageSize))
+	})
+})
@@ -0,0 +1,220 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+)
+
+const recentTasksLimit = 200
+
+type SnapshotFetcher interface {
+	Fetch(ctx context.Context) (Snapshot, error)
+}
+
+// DeploymentsIterator calls fn with every deployment, stopping at the first
+// error returned by fn.
+type DeploymentsIterator func(fn func(deployments.DeploymentInfo) error) error
+
+// StreamingFetcher fetches the deployments lazily, see Fetcher.FetchStream.
+type StreamingFetcher interface {
+	FetchStream(ctx context.Context) (Snapshot, DeploymentsIterator, error)
+}
+
+type ProblemsScanner interface {
+	ScanProblems(deploymentName string) error
+}
+
+type Fetcher struct {
+	deploymentsFetcher     *deployments.Fetcher
+	boshClient             director.Director
+	directorInfoExtensions *DirectorInfoExtensions
+	fetchTasks             bool
+}
+
+func NewFetcher(deploymentsFetcher *deployments.Fetcher, boshClient director.Director) *Fetcher {
+	return &Fetcher{deploymentsFetcher: deploymentsFetcher, boshClient: boshClient, fetchTasks: true}
+}
+
+// SetFetchTasks sets whether the recent tasks are read, which only the Tasks
+// collector and the hidden deployments detection need. It must be called
+// before the first fetch.
+func (f *Fetcher) SetFetchTasks(fetchTasks bool) {
+	f.fetchTasks = fetchTasks
+}
+
+// SetDirectorInfoExtensions completes the Director info with the fields
+// captured by extensions from the Director responses. It must be called
+// before the first fetch.
+func (f *Fetcher) SetDirectorInfoExtensions(extensions *DirectorInfoExtensions) {
+	f.directorInfoExtensions = extensions
+}
+
+func (f *Fetcher) ScanProblems(deploymentName string) error {
+	return f.deploymentsFetcher.ScanProblems(deploymentName)
+}
+
+func (f *Fetcher) Fetch(ctx context.Context) (Snapshot, error) {
+	snapshot, err := f.fetchDirector(ctx)
+	if err != nil {
+		return snapshot, err
+	}
+
+	deploymentsInfo, err := f.deploymentsFetcher.Deployments()
+	if err != nil {
+		return snapshot, err
+	}
+
+	if err := f.fetchVisibleDeploymentsAndTasks(ctx, &snapshot); err != nil {
+		return snapshot, err
+	}
+
+	deploymentTasks := tasksByDeployment(snapshot.Tasks)
+	for i, deploymentInfo := range deploymentsInfo {
+		deploymentsInfo[i].Tasks = deploymentTasks[deploymentInfo.Name]
+	}
+	snapshot.Deployments = deploymentsInfo
+
+	snapshot.FetchDuration = time.Since(snapshot.FetchedAt)
+
+	return snapshot, nil
+}
+
+// FetchStream returns a snapshot without deployments and an iterator fetching
+// them one at a time, so that every instance of every deployment is never
+// held in memory at once. The iterator must be called exactly once.
+func (f *Fetcher) FetchStream(ctx context.Context) (Snapshot, DeploymentsIterator, error) {
+	snapshot, err := f.fetchDirector(ctx)
+	if err != nil {
+		return snapshot, nil, err
+	}
+
+	if err := f.fetchVisibleDeploymentsAndTasks(ctx, &snapshot); err != nil {
+		return snapshot, nil, err
+	}
+
+	streamDeployments, err := f.deploymentsFetcher.StreamDeployments()
+	if err != nil {
+		return snapshot, nil, err
+	}
+
+	deploymentTasks := tasksByDeployment(snapshot.Tasks)
+	eachDeployment := func(fn func(deployments.DeploymentInfo) error) error {
+		return streamDeployments(func(deploymentInfo deployments.DeploymentInfo) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			deploymentInfo.Tasks = deploymentTasks[deploymentInfo.Name]
+			return fn(deploymentInfo)
+		})
+	}
+
+	snapshot.FetchDuration = time.Since(snapshot.FetchedAt)
+
+	return snapshot, eachDeployment, nil
+}
+
+func (f *Fetcher) fetchDirector(ctx context.Context) (Snapshot, error) {
+	snapshot := Snapshot{FetchedAt: time.Now()}
+
+	if err := ctx.Err(); err != nil {
+		return snapshot, err
+	}
+	boshInfo, err := f.boshClient.Info()
+	if err != nil {
+		return snapshot, fmt.Errorf("Error while reading BOSH Director info: %v", err)
+	}
+	snapshot.Director = DirectorInfo{
+		Name:    boshInfo.Name,
+		UUID:    boshInfo.UUID,
+		Version: boshInfo.Version,
+	}
+	if f.directorInfoExtensions != nil {
+		snapshot.Director.TrustedCertsSHA1 = f.directorInfoExtensions.TrustedCertsSHA1()
+	}
+
+	return snapshot, ctx.Err()
+}
+
+func (f *Fetcher) fetchVisibleDeploymentsAndTasks(ctx context.Context, snapshot *Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	visibleDeployments, err := f.fetchVisibleDeployments()
+	if err != nil {
+		log.Error(err)
+	}
+	snapshot.VisibleDeployments = visibleDeployments
+
+	if !f.fetchTasks {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tasks, err := f.fetchRecentTasks()
+	if err != nil {
+		log.Error(err)
+	}
+	snapshot.Tasks = tasks
+
+	return nil
+}
+
+func tasksByDeployment(tasks []deployments.Task) map[string][]deployments.Task {
+	deploymentTasks := map[string][]deployments.Task{}
+	for _, task := range tasks {
+		if task.DeploymentName == "" {
+			continue
+		}
+		deploymentTasks[task.DeploymentName] = append(deploymentTasks[task.DeploymentName], task)
+	}
+
+	return deploymentTasks
+}
+
+// fetchVisibleDeployments lists every deployment the credentials can see,
+// regardless of the configured filters.
+func (f *Fetcher) fetchVisibleDeployments() ([]string, error) {
+	log.Debugf("Reading visible Deployments:")
+	visibleDeployments, err := f.boshClient.Deployments()
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading visible Deployments: %v", err)
+	}
+
+	names := make([]string, 0, len(visibleDeployments))
+	for _, deployment := range visibleDeployments {
+		names = append(names, deployment.Name())
+	}
+
+	return names, nil
+}
+
+func (f *Fetcher) fetchRecentTasks() ([]deployments.Task, error) {
+	recentTasks := []deployments.Task{}
+
+	log.Debugf("Reading recent Tasks:")
+	tasks, err := f.boshClient.RecentTasks(recentTasksLimit, director.TasksFilter{All: true})
+	if err != nil {
+		return recentTasks, fmt.Errorf("Error while reading recent Tasks: %v", err)
+	}
+
+	for _, task := range tasks {
+		recentTasks = append(recentTasks, deployments.Task{
+			ID:             task.ID(),
+			DeploymentName: task.DeploymentName(),
+			State:          task.State(),
+			Description:    task.Description(),
+			Result:         task.Result(),
+			StartedAt:      task.StartedAt(),
+			FinishedAt:     task.FinishedAt(),
+		})
+	}
+
+	return recentTasks, nil
+}
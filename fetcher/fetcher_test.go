@@ -0,0 +1,229 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/director/directorfakes"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/filters"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+func init() {
+	log.Base().SetLevel("fatal")
+}
+
+var _ = Describe("Fetcher", func() {
+	var (
+		err         error
+		ctx         context.Context
+		boshClient  *directorfakes.FakeDirector
+		boshFetcher *Fetcher
+		fetchTasks  bool
+		snapshot    Snapshot
+
+		deploymentName = "fake-deployment-name"
+		taskStartedAt  = time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+		taskFinishedAt = time.Date(2018, time.January, 1, 0, 5, 0, 0, time.UTC)
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fetchTasks = true
+		boshClient = &directorfakes.FakeDirector{}
+		boshClient.InfoReturns(director.Info{Name: "fake-bosh-name", UUID: "fake-bosh-uuid", Version: "1.2.3"}, nil)
+		boshClient.DeploymentsReturns([]director.Deployment{
+			&directorfakes.FakeDeployment{
+				NameStub: func() string { return deploymentName },
+			},
+		}, nil)
+		boshClient.RecentTasksReturns([]director.Task{
+			&directorfakes.FakeTask{
+				IDStub:             func() int { return 1 },
+				StateStub:          func() string { return "error" },
+				DeploymentNameStub: func() string { return deploymentName },
+				DescriptionStub:    func() string { return "create deployment" },
+				ResultStub:         func() string { return "CPI error" },
+				StartedAtStub:      func() time.Time { return taskStartedAt },
+				FinishedAtStub:     func() time.Time { return taskFinishedAt },
+			},
+			&directorfakes.FakeTask{
+				IDStub:             func() int { return 2 },
+				StateStub:          func() string { return "done" },
+				DeploymentNameStub: func() string { return "" },
+			},
+		}, nil)
+	})
+
+	JustBeforeEach(func() {
+		deploymentsFilter := filters.NewDeploymentsFilter([]string{}, boshClient)
+		deploymentsFetcher := deployments.NewFetcher(*deploymentsFilter, &filters.ExpressionFilter{}, boshClient, 0, false)
+		boshFetcher = NewFetcher(deploymentsFetcher, boshClient)
+		boshFetcher.SetFetchTasks(fetchTasks)
+		snapshot, err = boshFetcher.Fetch(ctx)
+	})
+
+	Describe("Fetch", func() {
+		It("returns the director info", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshot.Director).To(Equal(DirectorInfo{Name: "fake-bosh-name", UUID: "fake-bosh-uuid", Version: "1.2.3"}))
+		})
+
+		It("returns the deployments with their tasks", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshot.Deployments).To(HaveLen(1))
+			Expect(snapshot.Deployments[0].Name).To(Equal(deploymentName))
+			Expect(snapshot.Deployments[0].Tasks).To(Equal([]deployments.Task{
+				{
+					ID:             1,
+					DeploymentName: deploymentName,
+					State:          "error",
+					Description:    "create deployment",
+					Result:         "CPI error",
+					StartedAt:      taskStartedAt,
+					FinishedAt:     taskFinishedAt,
+				},
+			}))
+		})
+
+		It("returns the visible deployments", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshot.VisibleDeployments).To(Equal([]string{deploymentName}))
+		})
+
+		It("returns all recent tasks", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshot.Tasks).To(HaveLen(2))
+			Expect(boshClient.RecentTasksCallCount()).To(Equal(1))
+			_, tasksFilter := boshClient.RecentTasksArgsForCall(0)
+			Expect(tasksFilter.All).To(BeTrue())
+		})
+
+		It("returns the fetch metadata", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshot.FetchedAt).ToNot(BeZero())
+			Expect(snapshot.FetchDuration).To(BeNumerically(">=", 0))
+		})
+
+		Context("when it fails to get the director info", func() {
+			BeforeEach(func() {
+				boshClient.InfoReturns(director.Info{}, errors.New("no info"))
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(boshClient.DeploymentsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when it fails to get the deployments", func() {
+			BeforeEach(func() {
+				boshClient.DeploymentsReturns(nil, errors.New("no deployments"))
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("when it fails to get the recent tasks", func() {
+			BeforeEach(func() {
+				boshClient.RecentTasksReturns(nil, errors.New("no tasks"))
+			})
+
+			It("returns the deployments without tasks", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(snapshot.Deployments).To(HaveLen(1))
+				Expect(snapshot.Deployments[0].Tasks).To(BeEmpty())
+				Expect(snapshot.Tasks).To(BeEmpty())
+			})
+		})
+
+		Context("when the tasks are not fetched", func() {
+			BeforeEach(func() {
+				fetchTasks = false
+			})
+
+			It("does not read the recent tasks", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(boshClient.RecentTasksCallCount()).To(Equal(0))
+				Expect(snapshot.Tasks).To(BeNil())
+				Expect(snapshot.Deployments[0].Tasks).To(BeEmpty())
+			})
+		})
+
+		Context("when the context is cancelled", func() {
+			BeforeEach(func() {
+				cancelledCtx, cancel := context.WithCancel(context.Background())
+				cancel()
+				ctx = cancelledCtx
+			})
+
+			It("returns the context error", func() {
+				Expect(err).To(Equal(context.Canceled))
+				Expect(boshClient.InfoCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("FetchStream", func() {
+		var (
+			streamedSnapshot Snapshot
+			eachDeployment   DeploymentsIterator
+			streamErr        error
+		)
+
+		JustBeforeEach(func() {
+			streamedSnapshot, eachDeployment, streamErr = boshFetcher.FetchStream(ctx)
+		})
+
+		It("returns a snapshot without deployments", func() {
+			Expect(streamErr).ToNot(HaveOccurred())
+			Expect(streamedSnapshot.Director).To(Equal(snapshot.Director))
+			Expect(streamedSnapshot.VisibleDeployments).To(Equal([]string{deploymentName}))
+			Expect(streamedSnapshot.Tasks).To(HaveLen(2))
+			Expect(streamedSnapshot.Deployments).To(BeNil())
+		})
+
+		It("iterates over the deployments with their tasks", func() {
+			Expect(streamErr).ToNot(HaveOccurred())
+			streamedDeployments := []deployments.DeploymentInfo{}
+			Expect(eachDeployment(func(deploymentInfo deployments.DeploymentInfo) error {
+				streamedDeployments = append(streamedDeployments, deploymentInfo)
+				return nil
+			})).To(Succeed())
+			Expect(streamedDeployments).To(HaveLen(1))
+			Expect(streamedDeployments[0].Name).To(Equal(deploymentName))
+			Expect(streamedDeployments[0].Tasks).To(Equal(snapshot.Deployments[0].Tasks))
+		})
+
+		Context("when it fails to get the director info", func() {
+			BeforeEach(func() {
+				boshClient.InfoReturns(director.Info{}, errors.New("no info"))
+			})
+
+			It("returns an error", func() {
+				Expect(streamErr).To(HaveOccurred())
+			})
+		})
+
+		Context("when it fails to get the deployments", func() {
+			BeforeEach(func() {
+				boshClient.DeploymentsReturns(nil, errors.New("no deployments"))
+			})
+
+			It("returns an error", func() {
+				Expect(streamErr).To(HaveOccurred())
+			})
+		})
+	})
+})
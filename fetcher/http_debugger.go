@@ -0,0 +1,169 @@
+package fetcher
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+const redacted = "REDACTED"
+
+var (
+	secretNames = []string{"token", "password", "secret", "authorization", "cookie", "assertion", "private_key", "credential"}
+
+	jsonSecretRegexp = regexp.MustCompile(`(?i)("[^"]*(?:` + strings.Join(secretNames, "|") + `)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*(?:"|$)`)
+	formSecretRegexp = regexp.MustCompile(`(?i)((?:^|&)[^=&]*(?:` + strings.Join(secretNames, "|") + `)[^=&]*=)[^&]*`)
+	yamlSecretRegexp = regexp.MustCompile(`(?i)((?:^|\n|\\n)[ \t]*[\w.-]*(?:` + strings.Join(secretNames, "|") + `)[\w.-]*:[ \t]*)[^\s\\"][^\n\\"]*`)
+	privateKeyRegexp = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`)
+)
+
+// HTTPDebugger logs the requests sent to the BOSH Director and its UAA, and
+// their truncated responses, at debug level. Secrets in headers, query
+// parameters and bodies are redacted. It can be enabled and disabled at
+// runtime.
+type HTTPDebugger struct {
+	enabled      int32
+	maxBodyBytes int
+}
+
+func NewHTTPDebugger(enabled bool, maxBodyBytes int) *HTTPDebugger {
+	debugger := &HTTPDebugger{maxBodyBytes: maxBodyBytes}
+	debugger.SetEnabled(enabled)
+
+	return debugger
+}
+
+func (d *HTTPDebugger) SetEnabled(enabled bool) {
+	var value int32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&d.enabled, value)
+}
+
+func (d *HTTPDebugger) Enabled() bool {
+	return atomic.LoadInt32(&d.enabled) == 1
+}
+
+// Wrap returns transport unchanged when the debugger is nil, so it can be
+// used unconditionally.
+func (d *HTTPDebugger) Wrap(transport http.RoundTripper) http.RoundTripper {
+	if d == nil {
+		return transport
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &debugTransport{transport: transport, debugger: d}
+}
+
+type debugTransport struct {
+	transport http.RoundTripper
+	debugger  *HTTPDebugger
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.debugger.Enabled() {
+		return t.transport.RoundTrip(req)
+	}
+
+	var requestBody []byte
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			requestBody, _ = ioutil.ReadAll(io.LimitReader(body, int64(t.debugger.maxBodyBytes)))
+			body.Close()
+		}
+	}
+	log.Debugf("BOSH HTTP request: %s %s headers=%s body=%s", req.Method, RedactURL(req.URL), RedactHeaders(req.Header), RedactBody(requestBody))
+
+	begun := time.Now()
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		log.Debugf("BOSH HTTP response: %s %s error=%v duration=%s", req.Method, RedactURL(req.URL), err, time.Since(begun))
+		return resp, err
+	}
+
+	responseBody, readErr := ioutil.ReadAll(io.LimitReader(resp.Body, int64(t.debugger.maxBodyBytes)))
+	resp.Body = &prefixedReadCloser{Reader: io.MultiReader(bytes.NewReader(responseBody), resp.Body), Closer: resp.Body}
+	truncated := ""
+	if len(responseBody) == t.debugger.maxBodyBytes {
+		truncated = " (truncated)"
+	}
+	if readErr != nil {
+		truncated = " (read error: " + readErr.Error() + ")"
+	}
+	log.Debugf("BOSH HTTP response: %s %s status=%d duration=%s headers=%s body=%s%s", req.Method, RedactURL(req.URL), resp.StatusCode, time.Since(begun), RedactHeaders(resp.Header), RedactBody(responseBody), truncated)
+
+	return resp, nil
+}
+
+type prefixedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func isSecretName(name string) bool {
+	name = strings.ToLower(name)
+	for _, secretName := range secretNames {
+		if strings.Contains(name, secretName) {
+			return true
+		}
+	}
+	return false
+}
+
+func RedactURL(u *url.URL) string {
+	redactedURL := *u
+	if redactedURL.User != nil {
+		redactedURL.User = url.User(redactedURL.User.Username())
+	}
+	query := redactedURL.Query()
+	for name := range query {
+		if isSecretName(name) {
+			query[name] = []string{redacted}
+		}
+	}
+	redactedURL.RawQuery = query.Encode()
+
+	return redactedURL.String()
+}
+
+func RedactHeaders(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(header[name], ",")
+		if isSecretName(name) {
+			value = redacted
+		}
+		parts = append(parts, name+": "+value)
+	}
+
+	return "{" + strings.Join(parts, "; ") + "}"
+}
+
+func RedactBody(body []byte) string {
+	redactedBody := privateKeyRegexp.ReplaceAll(body, []byte(redacted))
+	redactedBody = jsonSecretRegexp.ReplaceAll(redactedBody, []byte(`$1"`+redacted+`"`))
+	redactedBody = yamlSecretRegexp.ReplaceAll(redactedBody, []byte("${1}"+redacted))
+	if !bytes.ContainsAny(bytes.TrimSpace(redactedBody), "{[") {
+		redactedBody = formSecretRegexp.ReplaceAll(redactedBody, []byte("${1}"+redacted))
+	}
+
+	return string(redactedBody)
+}
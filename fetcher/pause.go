@@ -0,0 +1,144 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxPauseWindowDuration = 7 * 24 * time.Hour
+
+type cronField struct {
+	min int
+	max int
+}
+
+var cronFields = []cronField{
+	{min: 0, max: 59},
+	{min: 0, max: 23},
+	{min: 1, max: 31},
+	{min: 1, max: 12},
+	{min: 0, max: 6},
+}
+
+type PauseWindow struct {
+	schedule [5]map[int]bool
+	duration time.Duration
+}
+
+type PauseWindows []PauseWindow
+
+// ParsePauseWindows parses semicolon separated windows formatted as a 5 fields
+// cron schedule (minute hour day-of-month month day-of-week) followed by a duration.
+func ParsePauseWindows(windows string) (PauseWindows, error) {
+	pauseWindows := PauseWindows{}
+
+	for _, window := range strings.Split(windows, ";") {
+		fields := strings.Fields(window)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 6 {
+			return nil, errors.New(fmt.Sprintf("Pause window `%s` must be formatted as <minute> <hour> <day of month> <month> <day of week> <duration>", strings.TrimSpace(window)))
+		}
+
+		pauseWindow := PauseWindow{}
+		for i, field := range fields[:5] {
+			values, err := parseCronField(field, cronFields[i])
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Error parsing pause window `%s`: %v", strings.TrimSpace(window), err))
+			}
+			pauseWindow.schedule[i] = values
+		}
+
+		duration, err := time.ParseDuration(fields[5])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Error parsing pause window `%s` duration: %v", strings.TrimSpace(window), err))
+		}
+		if duration < time.Minute || duration > maxPauseWindowDuration {
+			return nil, errors.New(fmt.Sprintf("Pause window `%s` duration must be between 1m and %s", strings.TrimSpace(window), maxPauseWindowDuration))
+		}
+		pauseWindow.duration = duration
+
+		pauseWindows = append(pauseWindows, pauseWindow)
+	}
+
+	return pauseWindows, nil
+}
+
+func (w PauseWindows) Paused(t time.Time) bool {
+	for _, window := range w {
+		if window.Paused(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w PauseWindow) Paused(t time.Time) bool {
+	start := t.Truncate(time.Minute)
+	for elapsed := time.Duration(0); elapsed < w.duration; elapsed += time.Minute {
+		if w.matches(start.Add(-elapsed)) && t.Sub(start.Add(-elapsed)) < w.duration {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w PauseWindow) matches(t time.Time) bool {
+	return w.schedule[0][t.Minute()] &&
+		w.schedule[1][t.Hour()] &&
+		w.schedule[2][t.Day()] &&
+		w.schedule[3][int(t.Month())] &&
+		w.schedule[4][int(t.Weekday())]
+}
+
+func parseCronField(field string, bounds cronField) (map[int]bool, error) {
+	values := map[int]bool{}
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		stepped := false
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return nil, errors.New(fmt.Sprintf("invalid step in `%s`", part))
+			}
+			part = part[:i]
+			stepped = true
+		}
+
+		from, to := bounds.min, bounds.max
+		if part != "*" {
+			rangeBounds := strings.SplitN(part, "-", 2)
+			var err error
+			from, err = strconv.Atoi(rangeBounds[0])
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid value `%s`", part))
+			}
+			if !stepped {
+				to = from
+			}
+			if len(rangeBounds) == 2 {
+				to, err = strconv.Atoi(rangeBounds[1])
+				if err != nil {
+					return nil, errors.New(fmt.Sprintf("invalid range `%s`", part))
+				}
+			}
+		}
+		if from < bounds.min || to > bounds.max || from > to {
+			return nil, errors.New(fmt.Sprintf("`%s` is out of range %d-%d", part, bounds.min, bounds.max))
+		}
+
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,105 @@
+package fetcher_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+var _ = Describe("PauseWindows", func() {
+	var (
+		err          error
+		windows      string
+		pauseWindows PauseWindows
+	)
+
+	JustBeforeEach(func() {
+		pauseWindows, err = ParsePauseWindows(windows)
+	})
+
+	Describe("ParsePauseWindows", func() {
+		Context("when the windows are well formatted", func() {
+			BeforeEach(func() {
+				windows = "0 2 * * * 2h; */15 8-18 1,15 * 1-5 5m"
+			})
+
+			It("does not return an error", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(pauseWindows).To(HaveLen(2))
+			})
+		})
+
+		Context("when there are no windows", func() {
+			BeforeEach(func() {
+				windows = ""
+			})
+
+			It("returns no windows", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(pauseWindows).To(BeEmpty())
+			})
+		})
+
+		Context("when a window has no duration", func() {
+			BeforeEach(func() {
+				windows = "0 2 * * *"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("<minute> <hour> <day of month> <month> <day of week> <duration>"))
+			})
+		})
+
+		Context("when a field is out of range", func() {
+			BeforeEach(func() {
+				windows = "0 24 * * * 1h"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("out of range 0-23"))
+			})
+		})
+
+		Context("when the duration is invalid", func() {
+			BeforeEach(func() {
+				windows = "0 2 * * * forever"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Paused", func() {
+		BeforeEach(func() {
+			windows = "30 2 * * * 2h"
+		})
+
+		It("is paused within a window", func() {
+			Expect(pauseWindows.Paused(time.Date(2020, time.March, 1, 2, 30, 0, 0, time.UTC))).To(BeTrue())
+			Expect(pauseWindows.Paused(time.Date(2020, time.March, 1, 4, 29, 59, 0, time.UTC))).To(BeTrue())
+		})
+
+		It("is not paused outside a window", func() {
+			Expect(pauseWindows.Paused(time.Date(2020, time.March, 1, 2, 29, 0, 0, time.UTC))).To(BeFalse())
+			Expect(pauseWindows.Paused(time.Date(2020, time.March, 1, 4, 30, 0, 0, time.UTC))).To(BeFalse())
+		})
+
+		Context("when the window is restricted to some week days", func() {
+			BeforeEach(func() {
+				windows = "0 23 * * 6 3h"
+			})
+
+			It("spans midnight", func() {
+				Expect(pauseWindows.Paused(time.Date(2020, time.March, 8, 1, 0, 0, 0, time.UTC))).To(BeTrue())
+				Expect(pauseWindows.Paused(time.Date(2020, time.March, 9, 1, 0, 0, 0, time.UTC))).To(BeFalse())
+			})
+		})
+	})
+})
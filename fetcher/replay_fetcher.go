@@ -0,0 +1,53 @@
+package fetcher
+
+import (
+	"context"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/filters"
+)
+
+type ReplayFetcher struct {
+	snapshot          Snapshot
+	deploymentsFilter *filters.DeploymentsFilter
+	expressionFilter  *filters.ExpressionFilter
+}
+
+func NewReplayFetcher(snapshot Snapshot, deploymentsFilter *filters.DeploymentsFilter, expressionFilter *filters.ExpressionFilter) *ReplayFetcher {
+	return &ReplayFetcher{snapshot: snapshot, deploymentsFilter: deploymentsFilter, expressionFilter: expressionFilter}
+}
+
+// Fetch returns the saved snapshot, filtering its deployments as if they were read from BOSH.
+func (f *ReplayFetcher) Fetch(ctx context.Context) (Snapshot, error) {
+	snapshot := f.snapshot
+	if err := ctx.Err(); err != nil {
+		return snapshot, err
+	}
+
+	snapshot.Deployments = []deployments.DeploymentInfo{}
+	for _, deployment := range f.snapshot.Deployments {
+		if !f.deploymentsFilter.Explain(deployment.Name).Accepted {
+			continue
+		}
+		if !f.expressionFilter.Enabled(map[string]string{"deployment": deployment.Name}) {
+			continue
+		}
+		snapshot.Deployments = append(snapshot.Deployments, deployment)
+	}
+
+	return snapshot, nil
+}
+
+// FetchStream returns the saved snapshot and an iterator over its filtered
+// deployments.
+func (f *ReplayFetcher) FetchStream(ctx context.Context) (Snapshot, DeploymentsIterator, error) {
+	snapshot, err := f.Fetch(ctx)
+	if err != nil {
+		return snapshot, nil, err
+	}
+
+	eachDeployment := snapshot.EachDeployment
+	snapshot.Deployments = nil
+
+	return snapshot, eachDeployment, nil
+}
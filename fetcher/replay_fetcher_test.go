@@ -0,0 +1,68 @@
+package fetcher_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/filters"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+var _ = Describe("ReplayFetcher", func() {
+	var (
+		err               error
+		deploymentsFilter *filters.DeploymentsFilter
+		expressionFilter  *filters.ExpressionFilter
+		snapshot          Snapshot
+		replayedSnapshot  Snapshot
+	)
+
+	BeforeEach(func() {
+		deploymentsFilter = filters.NewDeploymentsFilter([]string{}, nil)
+		expressionFilter, err = filters.NewExpressionFilter("")
+		Expect(err).ToNot(HaveOccurred())
+		snapshot = Snapshot{
+			Director:    DirectorInfo{Name: "fake-bosh-name"},
+			Deployments: []deployments.DeploymentInfo{{Name: "cf"}, {Name: "redis"}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		replayedSnapshot, err = NewReplayFetcher(snapshot, deploymentsFilter, expressionFilter).Fetch(context.Background())
+	})
+
+	It("returns the saved snapshot", func() {
+		Expect(err).ToNot(HaveOccurred())
+		Expect(replayedSnapshot).To(Equal(snapshot))
+	})
+
+	Context("when deployments are filtered", func() {
+		BeforeEach(func() {
+			deploymentsFilter = filters.NewDeploymentsFilter([]string{"cf", "redis"}, nil)
+			expressionFilter, err = filters.NewExpressionFilter("deployment != redis")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("only returns the enabled deployments", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(replayedSnapshot.Deployments).To(Equal([]deployments.DeploymentInfo{{Name: "cf"}}))
+		})
+	})
+
+	It("streams the filtered deployments", func() {
+		streamedSnapshot, eachDeployment, err := NewReplayFetcher(snapshot, deploymentsFilter, expressionFilter).FetchStream(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(streamedSnapshot.Deployments).To(BeNil())
+
+		names := []string{}
+		Expect(eachDeployment(func(deployment deployments.DeploymentInfo) error {
+			names = append(names, deployment.Name)
+			return nil
+		})).To(Succeed())
+		Expect(names).To(Equal([]string{"cf", "redis"}))
+	})
+})
@@ -0,0 +1,162 @@
+package fetcher
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ResponseCache keeps the BOSH Director GET responses carrying an ETag or a
+// Last-Modified header, and revalidates them with conditional requests, so
+// unchanged deployment lists and configs are not transferred again on every
+// fetch. Directors not supporting conditional requests are queried as usual.
+type ResponseCache struct {
+	maxBodyBytes int64
+	entries      map[string]*cachedResponse
+	hits         uint64
+	misses       uint64
+	savedBytes   uint64
+	mu           *sync.Mutex
+}
+
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+}
+
+func NewResponseCache(maxBodyBytes int64) *ResponseCache {
+	return &ResponseCache{
+		maxBodyBytes: maxBodyBytes,
+		entries:      map[string]*cachedResponse{},
+		mu:           &sync.Mutex{},
+	}
+}
+
+// Wrap returns transport unchanged when the cache is nil, so it can be used
+// unconditionally.
+func (c *ResponseCache) Wrap(transport http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return transport
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &cachingTransport{transport: transport, cache: c}
+}
+
+// Stats returns the number of GET requests served from the cache after a
+// `304 Not Modified` response, the number of those transferring a full
+// response, and the number of body bytes not transferred again.
+func (c *ResponseCache) Stats() (hits uint64, misses uint64, savedBytes uint64) {
+	return atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses), atomic.LoadUint64(&c.savedBytes)
+}
+
+func (c *ResponseCache) get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *ResponseCache) set(key string, entry *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry == nil {
+		delete(c.entries, key)
+		return
+	}
+	c.entries[key] = entry
+}
+
+type cachingTransport struct {
+	transport http.RoundTripper
+	cache     *ResponseCache
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.transport.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	entry, cached := t.cache.get(key)
+	if cached {
+		req = req.Clone(req.Context())
+		if entry.etag != "" {
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if cached && resp.StatusCode == http.StatusNotModified {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		atomic.AddUint64(&t.cache.hits, 1)
+		atomic.AddUint64(&t.cache.savedBytes, uint64(len(entry.body)))
+		return entry.response(req, resp), nil
+	}
+	atomic.AddUint64(&t.cache.misses, 1)
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") || strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		if cached {
+			t.cache.set(key, nil)
+		}
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, t.cache.maxBodyBytes+1))
+	if err != nil || int64(len(body)) > t.cache.maxBodyBytes {
+		t.cache.set(key, nil)
+		resp.Body = &prefixedReadCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	t.cache.set(key, &cachedResponse{
+		etag:         etag,
+		lastModified: lastModified,
+		header:       resp.Header.Clone(),
+		body:         body,
+	})
+
+	return resp, nil
+}
+
+// response rebuilds the cached `200 OK` response answering req.
+func (e *cachedResponse) response(req *http.Request, notModified *http.Response) *http.Response {
+	header := e.header.Clone()
+	header.Set("Content-Length", strconv.Itoa(len(e.body)))
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+		TLS:           notModified.TLS,
+	}
+}
@@ -0,0 +1,149 @@
+package fetcher_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+var _ = Describe("ResponseCache", func() {
+	var (
+		server        *httptest.Server
+		body          string
+		etag          string
+		lastModified  string
+		conditionals  []string
+		responseCache *ResponseCache
+		client        *http.Client
+		maxBodyBytes  int64
+		get           func(path string) (int, string)
+	)
+
+	BeforeEach(func() {
+		body = `[{"name":"fake-deployment"}]`
+		etag = `"v1"`
+		lastModified = ""
+		conditionals = []string{}
+		maxBodyBytes = 1024
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conditionals = append(conditionals, r.Header.Get("If-None-Match")+r.Header.Get("If-Modified-Since"))
+			if etag != "" {
+				w.Header().Set("ETag", etag)
+			}
+			if lastModified != "" {
+				w.Header().Set("Last-Modified", lastModified)
+			}
+			if (etag != "" && r.Header.Get("If-None-Match") == etag) || (lastModified != "" && r.Header.Get("If-Modified-Since") == lastModified) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte(body))
+		}))
+
+		get = func(path string) (int, string) {
+			resp, err := client.Get(server.URL + path)
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			responseBody, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			return resp.StatusCode, string(responseBody)
+		}
+	})
+
+	JustBeforeEach(func() {
+		responseCache = NewResponseCache(maxBodyBytes)
+		client = &http.Client{Transport: responseCache.Wrap(http.DefaultTransport)}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("serves the cached response when the director answers 304 Not Modified", func() {
+		get("/deployments")
+		statusCode, responseBody := get("/deployments")
+		Expect(statusCode).To(Equal(http.StatusOK))
+		Expect(responseBody).To(Equal(body))
+		Expect(conditionals).To(Equal([]string{"", `"v1"`}))
+
+		hits, misses, savedBytes := responseCache.Stats()
+		Expect(hits).To(Equal(uint64(1)))
+		Expect(misses).To(Equal(uint64(1)))
+		Expect(savedBytes).To(Equal(uint64(len(body))))
+	})
+
+	It("caches every URL separately", func() {
+		get("/deployments")
+		get("/configs?latest=true")
+		Expect(conditionals).To(Equal([]string{"", ""}))
+	})
+
+	Context("when the response changed", func() {
+		It("replaces the cached response", func() {
+			get("/deployments")
+			etag = `"v2"`
+			body = `[]`
+			_, responseBody := get("/deployments")
+			Expect(responseBody).To(Equal(`[]`))
+			get("/deployments")
+			Expect(conditionals).To(Equal([]string{"", `"v1"`, `"v2"`}))
+
+			hits, misses, _ := responseCache.Stats()
+			Expect(hits).To(Equal(uint64(1)))
+			Expect(misses).To(Equal(uint64(2)))
+		})
+	})
+
+	Context("when the director only sends Last-Modified", func() {
+		BeforeEach(func() {
+			etag = ""
+			lastModified = "Mon, 12 Oct 2026 10:00:00 GMT"
+		})
+
+		It("sends If-Modified-Since", func() {
+			get("/deployments")
+			_, responseBody := get("/deployments")
+			Expect(responseBody).To(Equal(body))
+			Expect(conditionals).To(Equal([]string{"", lastModified}))
+		})
+	})
+
+	Context("when the director does not support conditional requests", func() {
+		BeforeEach(func() {
+			etag = ""
+		})
+
+		It("does not cache the response", func() {
+			get("/deployments")
+			get("/deployments")
+			Expect(conditionals).To(Equal([]string{"", ""}))
+		})
+	})
+
+	Context("when the response is larger than the maximum body size", func() {
+		BeforeEach(func() {
+			maxBodyBytes = 8
+		})
+
+		It("returns the whole response without caching it", func() {
+			_, responseBody := get("/deployments")
+			Expect(responseBody).To(Equal(body))
+			get("/deployments")
+			Expect(conditionals).To(Equal([]string{"", ""}))
+		})
+	})
+
+	It("does not cache other methods than GET", func() {
+		resp, err := client.Post(server.URL+"/deployments", "application/json", nil)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		get("/deployments")
+		Expect(conditionals).To(Equal([]string{"", ""}))
+	})
+})
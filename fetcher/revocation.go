@@ -0,0 +1,371 @@
+package fetcher
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+const (
+	OCSPStapleOff     = "off"
+	OCSPStapleVerify  = "verify"
+	OCSPStapleRequire = "require"
+)
+
+const (
+	RevocationFailureRevoked     = "revoked"
+	RevocationFailureCRLExpired  = "crl_expired"
+	RevocationFailureOCSPMissing = "ocsp_missing"
+	RevocationFailureOCSPInvalid = "ocsp_invalid"
+	RevocationFailureOCSPUnknown = "ocsp_unknown"
+)
+
+var RevocationFailureReasons = []string{
+	RevocationFailureRevoked,
+	RevocationFailureCRLExpired,
+	RevocationFailureOCSPMissing,
+	RevocationFailureOCSPInvalid,
+	RevocationFailureOCSPUnknown,
+}
+
+var ocspBasicResponseOID = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+var ocspSignatureAlgorithms = []struct {
+	oid       asn1.ObjectIdentifier
+	algorithm x509.SignatureAlgorithm
+}{
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}, x509.SHA1WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, x509.SHA256WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}, x509.SHA384WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}, x509.SHA512WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}, x509.ECDSAWithSHA256},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}, x509.ECDSAWithSHA384},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}, x509.ECDSAWithSHA512},
+	{asn1.ObjectIdentifier{1, 3, 101, 112}, x509.PureEd25519},
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Version     int `asn1:"optional,default:0,explicit,tag:0"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []ocspSingleResponse
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	Good       asn1.Flag       `asn1:"tag:0,optional"`
+	Revoked    ocspRevokedInfo `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag       `asn1:"tag:2,optional"`
+	ThisUpdate time.Time       `asn1:"generalized"`
+	NextUpdate time.Time       `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time `asn1:"generalized"`
+}
+
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+// RevocationError is returned when the BOSH Director certificate is revoked
+// or its revocation status cannot be established.
+type RevocationError struct {
+	Reason string
+	Err    error
+}
+
+func (e *RevocationError) Error() string {
+	return fmt.Sprintf("tls: certificate revocation check failed (%s): %v", e.Reason, e.Err)
+}
+
+// RevocationChecker checks the BOSH Director certificate against CRL files
+// and the OCSP response stapled to the TLS handshake, counting the failures
+// so that rejected connections are visible in metrics.
+type RevocationChecker struct {
+	crlFiles   []string
+	ocspStaple string
+	now        func() time.Time
+	crls       atomic.Value
+	modTimes   map[string]time.Time
+	failures   map[string]*uint64
+	mu         *sync.Mutex
+}
+
+func NewRevocationChecker(crlFiles []string, ocspStaple string) (*RevocationChecker, error) {
+	checker := &RevocationChecker{
+		crlFiles:   crlFiles,
+		ocspStaple: ocspStaple,
+		now:        time.Now,
+		failures:   map[string]*uint64{},
+		mu:         &sync.Mutex{},
+	}
+	for _, reason := range RevocationFailureReasons {
+		checker.failures[reason] = new(uint64)
+	}
+	if _, err := checker.Reload(); err != nil {
+		return nil, err
+	}
+
+	return checker, nil
+}
+
+func (c *RevocationChecker) Failures(reason string) uint64 {
+	return atomic.LoadUint64(c.failures[reason])
+}
+
+// Check verifies the revocation status of the leaf certificate of a verified
+// chain, whose second element is the leaf issuer.
+func (c *RevocationChecker) Check(chain []*x509.Certificate, ocspStaple []byte) error {
+	if len(chain) == 0 {
+		return nil
+	}
+
+	err := c.check(chain, ocspStaple)
+	if revocationErr, ok := err.(*RevocationError); ok {
+		atomic.AddUint64(c.failures[revocationErr.Reason], 1)
+	}
+
+	return err
+}
+
+func (c *RevocationChecker) check(chain []*x509.Certificate, ocspStaple []byte) error {
+	leaf := chain[0]
+	var issuer *x509.Certificate
+	if len(chain) > 1 {
+		issuer = chain[1]
+	}
+
+	crls, _ := c.crls.Load().([]*x509.RevocationList)
+	for _, crl := range crls {
+		if !bytes.Equal(crl.RawIssuer, leaf.RawIssuer) {
+			continue
+		}
+		if !crl.NextUpdate.IsZero() && c.now().After(crl.NextUpdate) {
+			return &RevocationError{Reason: RevocationFailureCRLExpired, Err: fmt.Errorf("CRL of `%s` expired at %s", leaf.Issuer, crl.NextUpdate)}
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+				return &RevocationError{Reason: RevocationFailureRevoked, Err: fmt.Errorf("certificate `%s` was revoked at %s", leaf.Subject, entry.RevocationTime)}
+			}
+		}
+	}
+
+	if c.ocspStaple == OCSPStapleOff {
+		return nil
+	}
+	if len(ocspStaple) == 0 {
+		if c.ocspStaple == OCSPStapleRequire {
+			return &RevocationError{Reason: RevocationFailureOCSPMissing, Err: errors.New("the server did not staple an OCSP response")}
+		}
+		return nil
+	}
+	if issuer == nil {
+		return &RevocationError{Reason: RevocationFailureOCSPInvalid, Err: errors.New("the certificate issuer is unknown")}
+	}
+
+	return c.checkOCSPStaple(leaf, issuer, ocspStaple)
+}
+
+func (c *RevocationChecker) checkOCSPStaple(leaf *x509.Certificate, issuer *x509.Certificate, staple []byte) error {
+	invalid := func(err error) error {
+		return &RevocationError{Reason: RevocationFailureOCSPInvalid, Err: err}
+	}
+
+	var response ocspResponse
+	if _, err := asn1.Unmarshal(staple, &response); err != nil {
+		return invalid(fmt.Errorf("error parsing the stapled OCSP response: %v", err))
+	}
+	if response.Status != 0 {
+		return invalid(fmt.Errorf("the stapled OCSP response status is %d", response.Status))
+	}
+	if !response.Response.ResponseType.Equal(ocspBasicResponseOID) {
+		return invalid(fmt.Errorf("unsupported OCSP response type %v", response.Response.ResponseType))
+	}
+
+	var basicResponse ocspBasicResponse
+	if _, err := asn1.Unmarshal(response.Response.Response, &basicResponse); err != nil {
+		return invalid(fmt.Errorf("error parsing the stapled OCSP response: %v", err))
+	}
+	var responseData ocspResponseData
+	if _, err := asn1.Unmarshal(basicResponse.TBSResponseData.FullBytes, &responseData); err != nil {
+		return invalid(fmt.Errorf("error parsing the stapled OCSP response data: %v", err))
+	}
+
+	responder := issuer
+	if len(basicResponse.Certificates) > 0 {
+		delegated, err := x509.ParseCertificate(basicResponse.Certificates[0].FullBytes)
+		if err != nil {
+			return invalid(fmt.Errorf("error parsing the OCSP responder certificate: %v", err))
+		}
+		if !bytes.Equal(delegated.Raw, issuer.Raw) {
+			if err := delegated.CheckSignatureFrom(issuer); err != nil {
+				return invalid(fmt.Errorf("the OCSP responder certificate is not signed by the issuer: %v", err))
+			}
+			if !hasExtKeyUsage(delegated, x509.ExtKeyUsageOCSPSigning) {
+				return invalid(errors.New("the OCSP responder certificate is not allowed to sign OCSP responses"))
+			}
+		}
+		responder = delegated
+	}
+
+	signatureAlgorithm := x509.UnknownSignatureAlgorithm
+	for _, candidate := range ocspSignatureAlgorithms {
+		if basicResponse.SignatureAlgorithm.Algorithm.Equal(candidate.oid) {
+			signatureAlgorithm = candidate.algorithm
+		}
+	}
+	if err := responder.CheckSignature(signatureAlgorithm, basicResponse.TBSResponseData.FullBytes, basicResponse.Signature.RightAlign()); err != nil {
+		return invalid(fmt.Errorf("invalid OCSP response signature: %v", err))
+	}
+
+	for _, singleResponse := range responseData.Responses {
+		if singleResponse.CertID.SerialNumber == nil || singleResponse.CertID.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+			continue
+		}
+		if !singleResponse.NextUpdate.IsZero() && c.now().After(singleResponse.NextUpdate) {
+			return invalid(fmt.Errorf("the stapled OCSP response expired at %s", singleResponse.NextUpdate))
+		}
+		switch {
+		case bool(singleResponse.Good):
+			return nil
+		case bool(singleResponse.Unknown):
+			return &RevocationError{Reason: RevocationFailureOCSPUnknown, Err: fmt.Errorf("the OCSP responder does not know certificate `%s`", leaf.Subject)}
+		default:
+			return &RevocationError{Reason: RevocationFailureRevoked, Err: fmt.Errorf("certificate `%s` was revoked at %s", leaf.Subject, singleResponse.Revoked.RevocationTime)}
+		}
+	}
+
+	return invalid(fmt.Errorf("the stapled OCSP response does not cover certificate `%s`", leaf.Subject))
+}
+
+// Reload reads the CRL files again if any of them changed since the last
+// read, keeping the current CRLs when they cannot be read.
+func (c *RevocationChecker) Reload() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	modTimes := map[string]time.Time{}
+	changed := c.modTimes == nil
+	for _, file := range c.crlFiles {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false, fmt.Errorf("Error reading CRL file `%s`: %v", file, err)
+		}
+		modTimes[file] = info.ModTime()
+		if !info.ModTime().Equal(c.modTimes[file]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	crls := []*x509.RevocationList{}
+	for _, file := range c.crlFiles {
+		fileCRLs, err := readCRLFile(file)
+		if err != nil {
+			return false, err
+		}
+		crls = append(crls, fileCRLs...)
+	}
+	c.crls.Store(crls)
+	c.modTimes = modTimes
+
+	return true, nil
+}
+
+func (c *RevocationChecker) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			changed, err := c.Reload()
+			if err != nil {
+				log.Errorf("Error reloading CRLs, keeping the previous ones: %v", err)
+				continue
+			}
+			if changed {
+				log.Infof("Reloaded CRLs from %v", c.crlFiles)
+			}
+		}
+	}
+}
+
+func readCRLFile(file string) ([]*x509.RevocationList, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading CRL file `%s`: %v", file, err)
+	}
+
+	ders := [][]byte{}
+	for rest := content; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "X509 CRL" {
+			ders = append(ders, block.Bytes)
+		}
+	}
+	if len(ders) == 0 {
+		ders = append(ders, content)
+	}
+
+	crls := []*x509.RevocationList{}
+	for _, der := range ders {
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing CRL file `%s`: %v", file, err)
+		}
+		crls = append(crls, crl)
+	}
+
+	return crls, nil
+}
+
+func hasExtKeyUsage(certificate *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, extKeyUsage := range certificate.ExtKeyUsage {
+		if extKeyUsage == usage {
+			return true
+		}
+	}
+	return false
+}
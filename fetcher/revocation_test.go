@@ -0,0 +1,389 @@
+package fetcher_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+type testOCSPResponse struct {
+	Status   asn1.Enumerated
+	Response testOCSPResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type testOCSPResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type testOCSPBasicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+}
+
+type testOCSPResponseData struct {
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []testOCSPSingleResponse
+}
+
+type testOCSPSingleResponse struct {
+	CertID     testOCSPCertID
+	Good       asn1.Flag           `asn1:"tag:0,optional"`
+	Revoked    testOCSPRevokedInfo `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag           `asn1:"tag:2,optional"`
+	ThisUpdate time.Time           `asn1:"generalized"`
+	NextUpdate time.Time           `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type testOCSPRevokedInfo struct {
+	RevocationTime time.Time `asn1:"generalized"`
+}
+
+type testOCSPCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type testCA struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCA() testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "bosh-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+	certificate, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+
+	return testCA{certificate: certificate, key: key}
+}
+
+func (ca testCA) issue(serialNumber int64) tls.Certificate {
+	return ca.issueFor(serialNumber, []net.IP{net.ParseIP("127.0.0.1")}, nil)
+}
+
+func (ca testCA) issueFor(serialNumber int64, ipAddresses []net.IP, dnsNames []string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serialNumber),
+		Subject:      pkix.Name{CommonName: "bosh-director"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  ipAddresses,
+		DNSNames:     dnsNames,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, &key.PublicKey, ca.key)
+	Expect(err).ToNot(HaveOccurred())
+	leaf, err := x509.ParseCertificate(der)
+	Expect(err).ToNot(HaveOccurred())
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func (ca testCA) crl(nextUpdate time.Time, revokedSerialNumbers ...int64) []byte {
+	entries := []x509.RevocationListEntry{}
+	for _, serialNumber := range revokedSerialNumbers {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: big.NewInt(serialNumber), RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now().Add(-time.Hour),
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+	}, ca.certificate, ca.key)
+	Expect(err).ToNot(HaveOccurred())
+
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+func (ca testCA) ocspResponse(serialNumber int64, status string, signer crypto.Signer) []byte {
+	singleResponse := testOCSPSingleResponse{
+		CertID: testOCSPCertID{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}, Parameters: asn1.NullRawValue},
+			NameHash:      []byte("fake-name-hash"),
+			IssuerKeyHash: []byte("fake-key-hash"),
+			SerialNumber:  big.NewInt(serialNumber),
+		},
+		ThisUpdate: time.Now().Add(-time.Minute).UTC(),
+		NextUpdate: time.Now().Add(time.Hour).UTC(),
+	}
+	switch status {
+	case "good":
+		singleResponse.Good = true
+	case "unknown":
+		singleResponse.Unknown = true
+	default:
+		singleResponse.Revoked = testOCSPRevokedInfo{RevocationTime: time.Now().Add(-time.Minute).UTC()}
+	}
+
+	keyHash, err := asn1.Marshal([]byte("fake-key-hash"))
+	Expect(err).ToNot(HaveOccurred())
+	tbs, err := asn1.Marshal(testOCSPResponseData{
+		ResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: keyHash},
+		ProducedAt:  time.Now().UTC(),
+		Responses:   []testOCSPSingleResponse{singleResponse},
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	digest := sha256.Sum256(tbs)
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	Expect(err).ToNot(HaveOccurred())
+
+	basicResponse, err := asn1.Marshal(testOCSPBasicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	response, err := asn1.Marshal(testOCSPResponse{
+		Response: testOCSPResponseBytes{
+			ResponseType: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1},
+			Response:     basicResponse,
+		},
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	return response
+}
+
+func writeTempFile(content []byte) string {
+	tmpfile, err := ioutil.TempFile("", "revocation_test_")
+	Expect(err).ToNot(HaveOccurred())
+	_, err = tmpfile.Write(content)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(tmpfile.Close()).To(Succeed())
+	return tmpfile.Name()
+}
+
+var _ = Describe("RevocationChecker", func() {
+	var (
+		err               error
+		ca                testCA
+		leaf              tls.Certificate
+		chain             []*x509.Certificate
+		crlFiles          []string
+		ocspStaple        string
+		staple            []byte
+		revocationChecker *RevocationChecker
+	)
+
+	BeforeEach(func() {
+		ca = newTestCA()
+		leaf = ca.issue(42)
+		chain = []*x509.Certificate{leaf.Leaf, ca.certificate}
+		crlFiles = []string{}
+		ocspStaple = OCSPStapleOff
+		staple = nil
+	})
+
+	AfterEach(func() {
+		for _, crlFile := range crlFiles {
+			os.Remove(crlFile)
+		}
+	})
+
+	JustBeforeEach(func() {
+		revocationChecker, err = NewRevocationChecker(crlFiles, ocspStaple)
+		Expect(err).ToNot(HaveOccurred())
+		err = revocationChecker.Check(chain, staple)
+	})
+
+	It("accepts the certificate when no revocation source is configured", func() {
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Context("when a CRL file is set", func() {
+		BeforeEach(func() {
+			crlFiles = []string{writeTempFile(ca.crl(time.Now().Add(time.Hour), 7))}
+		})
+
+		It("accepts a certificate that is not revoked", func() {
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("and the certificate is revoked", func() {
+			BeforeEach(func() {
+				crlFiles = []string{writeTempFile(ca.crl(time.Now().Add(time.Hour), 7, 42))}
+			})
+
+			It("rejects the certificate and counts the failure", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("revoked"))
+				Expect(revocationChecker.Failures(RevocationFailureRevoked)).To(Equal(uint64(1)))
+			})
+		})
+
+		Context("and the CRL expired", func() {
+			BeforeEach(func() {
+				crlFiles = []string{writeTempFile(ca.crl(time.Now().Add(-time.Minute)))}
+			})
+
+			It("rejects the certificate and counts the failure", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(revocationChecker.Failures(RevocationFailureCRLExpired)).To(Equal(uint64(1)))
+			})
+		})
+	})
+
+	Context("when a CRL file is invalid", func() {
+		It("returns an error", func() {
+			crlFile := writeTempFile([]byte("not a CRL"))
+			defer os.Remove(crlFile)
+
+			_, err := NewRevocationChecker([]string{crlFile}, OCSPStapleOff)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Error parsing CRL file"))
+		})
+	})
+
+	Context("when OCSP staples are verified", func() {
+		BeforeEach(func() {
+			ocspStaple = OCSPStapleVerify
+		})
+
+		It("accepts a missing staple", func() {
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("and the staple reports the certificate as good", func() {
+			BeforeEach(func() {
+				staple = ca.ocspResponse(42, "good", ca.key)
+			})
+
+			It("accepts the certificate", func() {
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("and the staple reports the certificate as revoked", func() {
+			BeforeEach(func() {
+				staple = ca.ocspResponse(42, "revoked", ca.key)
+			})
+
+			It("rejects the certificate and counts the failure", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(revocationChecker.Failures(RevocationFailureRevoked)).To(Equal(uint64(1)))
+			})
+		})
+
+		Context("and the staple reports the certificate as unknown", func() {
+			BeforeEach(func() {
+				staple = ca.ocspResponse(42, "unknown", ca.key)
+			})
+
+			It("rejects the certificate and counts the failure", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(revocationChecker.Failures(RevocationFailureOCSPUnknown)).To(Equal(uint64(1)))
+			})
+		})
+
+		Context("and the staple is not signed by the issuer", func() {
+			BeforeEach(func() {
+				otherKey, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				Expect(keyErr).ToNot(HaveOccurred())
+				staple = ca.ocspResponse(42, "good", otherKey)
+			})
+
+			It("rejects the certificate and counts the failure", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("invalid OCSP response signature"))
+				Expect(revocationChecker.Failures(RevocationFailureOCSPInvalid)).To(Equal(uint64(1)))
+			})
+		})
+
+		Context("and the staple covers another certificate", func() {
+			BeforeEach(func() {
+				staple = ca.ocspResponse(7, "good", ca.key)
+			})
+
+			It("rejects the certificate", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(revocationChecker.Failures(RevocationFailureOCSPInvalid)).To(Equal(uint64(1)))
+			})
+		})
+	})
+
+	Context("when OCSP staples are required", func() {
+		BeforeEach(func() {
+			ocspStaple = OCSPStapleRequire
+		})
+
+		It("rejects a missing staple and counts the failure", func() {
+			Expect(err).To(HaveOccurred())
+			Expect(revocationChecker.Failures(RevocationFailureOCSPMissing)).To(Equal(uint64(1)))
+		})
+	})
+
+	Context("when used by a CA bundle", func() {
+		var (
+			server *httptest.Server
+			caFile string
+		)
+
+		BeforeEach(func() {
+			server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("{}"))
+			}))
+			server.TLS = &tls.Config{Certificates: []tls.Certificate{leaf}}
+			server.StartTLS()
+
+			caFile = writeTempFile(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw}))
+			crlFiles = []string{writeTempFile(ca.crl(time.Now().Add(time.Hour), 42))}
+		})
+
+		AfterEach(func() {
+			server.Close()
+			os.Remove(caFile)
+		})
+
+		It("refuses to connect to a server with a revoked certificate", func() {
+			caBundle, err := NewCABundle([]string{caFile}, false)
+			Expect(err).ToNot(HaveOccurred())
+			caBundle.SetRevocationChecker(revocationChecker)
+
+			_, err = NewTLSClient(caBundle.TLSConfig("127.0.0.1"), nil).Get(server.URL)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("revocation check failed"))
+		})
+	})
+})
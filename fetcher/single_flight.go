@@ -0,0 +1,54 @@
+package fetcher
+
+import (
+	"context"
+	"sync"
+)
+
+// SingleFlight coalesces concurrent fetches, for example when several
+// Prometheus servers scrape the exporter at the same time: a fetch started
+// while another one is in flight waits for it and shares its snapshot instead
+// of querying the BOSH Director again.
+type SingleFlight struct {
+	inFlight *flight
+	mu       *sync.Mutex
+}
+
+type flight struct {
+	done     chan struct{}
+	snapshot Snapshot
+	err      error
+}
+
+func NewSingleFlight() *SingleFlight {
+	return &SingleFlight{mu: &sync.Mutex{}}
+}
+
+// Fetch calls fetch, or waits for the result of the fetch in flight, telling
+// whether the result was shared with another caller.
+func (s *SingleFlight) Fetch(ctx context.Context, fetch func() (Snapshot, error)) (Snapshot, bool, error) {
+	s.mu.Lock()
+	if current := s.inFlight; current != nil {
+		s.mu.Unlock()
+		select {
+		case <-current.done:
+			return current.snapshot, true, current.err
+		case <-ctx.Done():
+			return Snapshot{}, true, ctx.Err()
+		}
+	}
+	current := &flight{done: make(chan struct{})}
+	s.inFlight = current
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.inFlight = nil
+		s.mu.Unlock()
+		close(current.done)
+	}()
+
+	current.snapshot, current.err = fetch()
+
+	return current.snapshot, false, current.err
+}
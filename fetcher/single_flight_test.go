@@ -0,0 +1,109 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+type fetchResult struct {
+	snapshot Snapshot
+	shared   bool
+	err      error
+}
+
+var _ = Describe("SingleFlight", func() {
+	var (
+		singleFlight *SingleFlight
+		fetches      int32
+		started      chan bool
+		release      chan bool
+		fetchErr     error
+
+		fetch = func() (Snapshot, error) {
+			fetchNumber := atomic.AddInt32(&fetches, 1)
+			started <- true
+			<-release
+			return Snapshot{Director: DirectorInfo{Name: "fake-bosh-name", Version: string('0' + fetchNumber)}}, fetchErr
+		}
+	)
+
+	BeforeEach(func() {
+		singleFlight = NewSingleFlight()
+		fetches = 0
+		started = make(chan bool, 2)
+		release = make(chan bool)
+		fetchErr = nil
+	})
+
+	fetchAsync := func(ctx context.Context) chan fetchResult {
+		results := make(chan fetchResult, 1)
+		go func() {
+			snapshot, shared, err := singleFlight.Fetch(ctx, fetch)
+			results <- fetchResult{snapshot: snapshot, shared: shared, err: err}
+		}()
+		return results
+	}
+
+	It("shares the snapshot of the fetch in flight", func() {
+		first := fetchAsync(context.Background())
+		Eventually(started).Should(Receive())
+		second := fetchAsync(context.Background())
+		Consistently(started).ShouldNot(Receive())
+		close(release)
+
+		var firstResult, secondResult fetchResult
+		Eventually(first).Should(Receive(&firstResult))
+		Eventually(second).Should(Receive(&secondResult))
+		Expect(firstResult.shared).To(BeFalse())
+		Expect(secondResult.shared).To(BeTrue())
+		Expect(secondResult.snapshot).To(Equal(firstResult.snapshot))
+		Expect(atomic.LoadInt32(&fetches)).To(Equal(int32(1)))
+	})
+
+	It("shares the error of the fetch in flight", func() {
+		fetchErr = errors.New("no director")
+		first := fetchAsync(context.Background())
+		Eventually(started).Should(Receive())
+		second := fetchAsync(context.Background())
+		close(release)
+
+		var secondResult fetchResult
+		Eventually(second).Should(Receive(&secondResult))
+		Expect(secondResult.err).To(MatchError("no director"))
+		Eventually(first).Should(Receive())
+	})
+
+	It("fetches again once the fetch in flight is done", func() {
+		close(release)
+		_, shared, err := singleFlight.Fetch(context.Background(), fetch)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shared).To(BeFalse())
+		_, shared, err = singleFlight.Fetch(context.Background(), fetch)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shared).To(BeFalse())
+		Expect(atomic.LoadInt32(&fetches)).To(Equal(int32(2)))
+	})
+
+	Context("when the context of a waiting fetch is cancelled", func() {
+		It("stops waiting", func() {
+			first := fetchAsync(context.Background())
+			Eventually(started).Should(Receive())
+			ctx, cancel := context.WithCancel(context.Background())
+			second := fetchAsync(ctx)
+			cancel()
+
+			var secondResult fetchResult
+			Eventually(second).Should(Receive(&secondResult))
+			Expect(secondResult.err).To(Equal(context.Canceled))
+
+			close(release)
+			Eventually(first).Should(Receive())
+		})
+	})
+})
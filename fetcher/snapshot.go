@@ -0,0 +1,34 @@
+package fetcher
+
+import (
+	"time"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+)
+
+type Snapshot struct {
+	Director           DirectorInfo
+	Deployments        []deployments.DeploymentInfo
+	VisibleDeployments []string
+	Tasks              []deployments.Task
+	FetchedAt          time.Time
+	FetchDuration      time.Duration
+}
+
+type DirectorInfo struct {
+	Name             string
+	UUID             string
+	Version          string
+	TrustedCertsSHA1 string
+}
+
+// EachDeployment iterates over the deployments of the snapshot.
+func (s Snapshot) EachDeployment(fn func(deployments.DeploymentInfo) error) error {
+	for _, deployment := range s.Deployments {
+		if err := fn(deployment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
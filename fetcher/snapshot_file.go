@@ -0,0 +1,105 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const snapshotFileVersion = 1
+
+type EnvironmentSnapshot struct {
+	Environment string   `json:"environment"`
+	Snapshot    Snapshot `json:"snapshot"`
+}
+
+type snapshotFile struct {
+	Version      int                   `json:"version"`
+	Environments []EnvironmentSnapshot `json:"environments"`
+}
+
+// WriteSnapshot writes the snapshots as JSON, in the snapshot file format.
+func WriteSnapshot(writer io.Writer, snapshots []EnvironmentSnapshot) error {
+	content, err := encodeSnapshots(snapshots)
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(content); err != nil {
+		return errors.New(fmt.Sprintf("Error while writing snapshot: %v", err))
+	}
+
+	return nil
+}
+
+// WriteSnapshotFile saves the snapshots as JSON, gzipped when the filename ends with `.gz`.
+func WriteSnapshotFile(filename string, snapshots []EnvironmentSnapshot) error {
+	content, err := encodeSnapshots(snapshots)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(filename, ".gz") {
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		if _, err := writer.Write(content); err != nil {
+			return errors.New(fmt.Sprintf("Error while compressing snapshot: %v", err))
+		}
+		if err := writer.Close(); err != nil {
+			return errors.New(fmt.Sprintf("Error while compressing snapshot: %v", err))
+		}
+		content = buffer.Bytes()
+	}
+
+	if err := ioutil.WriteFile(filename, content, 0600); err != nil {
+		return errors.New(fmt.Sprintf("Error while writing snapshot file `%s`: %v", filename, err))
+	}
+
+	return nil
+}
+
+func encodeSnapshots(snapshots []EnvironmentSnapshot) ([]byte, error) {
+	content, err := json.Marshal(snapshotFile{Version: snapshotFileVersion, Environments: snapshots})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error while encoding snapshot: %v", err))
+	}
+
+	return content, nil
+}
+
+func ReadSnapshotFile(filename string) ([]EnvironmentSnapshot, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error while reading snapshot file `%s`: %v", filename, err))
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(filename, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Error while decompressing snapshot file `%s`: %v", filename, err))
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	var snapshots snapshotFile
+	if err := json.NewDecoder(reader).Decode(&snapshots); err != nil {
+		return nil, errors.New(fmt.Sprintf("Error while decoding snapshot file `%s`: %v", filename, err))
+	}
+	if snapshots.Version != snapshotFileVersion {
+		return nil, errors.New(fmt.Sprintf("Snapshot file `%s` version %d is not supported, expected version %d", filename, snapshots.Version, snapshotFileVersion))
+	}
+	if len(snapshots.Environments) == 0 {
+		return nil, errors.New(fmt.Sprintf("Snapshot file `%s` does not contain any environment", filename))
+	}
+
+	return snapshots.Environments, nil
+}
@@ -0,0 +1,115 @@
+package fetcher_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+var _ = Describe("SnapshotFile", func() {
+	var (
+		err       error
+		tmpDir    string
+		filename  string
+		snapshots []EnvironmentSnapshot
+	)
+
+	BeforeEach(func() {
+		tmpDir, err = ioutil.TempDir("", "snapshot_file_test_")
+		Expect(err).ToNot(HaveOccurred())
+		filename = filepath.Join(tmpDir, "snapshot.json.gz")
+
+		uptime := uint64(3600)
+		snapshots = []EnvironmentSnapshot{
+			{
+				Environment: "fake-environment",
+				Snapshot: Snapshot{
+					Director: DirectorInfo{Name: "fake-bosh-name", UUID: "fake-bosh-uuid", Version: "1.2.3"},
+					Deployments: []deployments.DeploymentInfo{
+						{
+							Name: "fake-deployment-name",
+							Instances: []deployments.Instance{
+								{
+									Name:       "fake-job-name",
+									IPs:        []string{"1.2.3.4"},
+									Processes:  []deployments.Process{{Name: "fake-process-name", Uptime: &uptime}},
+									Attributes: map[string]string{"vm_type": "small"},
+								},
+							},
+						},
+					},
+					Tasks:     []deployments.Task{{ID: 1, State: "queued", StartedAt: time.Unix(0, 0).UTC()}},
+					FetchedAt: time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	It("reads back the written snapshots", func() {
+		Expect(WriteSnapshotFile(filename, snapshots)).To(Succeed())
+
+		readSnapshots, err := ReadSnapshotFile(filename)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readSnapshots).To(Equal(snapshots))
+	})
+
+	It("compresses files ending with .gz", func() {
+		Expect(WriteSnapshotFile(filename, snapshots)).To(Succeed())
+
+		content, err := ioutil.ReadFile(filename)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content[:2]).To(Equal([]byte{0x1f, 0x8b}))
+	})
+
+	Context("when the file is not compressed", func() {
+		BeforeEach(func() {
+			filename = filepath.Join(tmpDir, "snapshot.json")
+		})
+
+		It("writes plain JSON", func() {
+			Expect(WriteSnapshotFile(filename, snapshots)).To(Succeed())
+
+			content, err := ioutil.ReadFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(HavePrefix(`{"version":1,`))
+		})
+	})
+
+	It("writes the snapshots in the snapshot file format", func() {
+		var buffer bytes.Buffer
+		Expect(WriteSnapshot(&buffer, snapshots)).To(Succeed())
+		Expect(buffer.String()).To(HavePrefix(`{"version":1,`))
+
+		filename = filepath.Join(tmpDir, "snapshot.json")
+		Expect(ioutil.WriteFile(filename, buffer.Bytes(), 0600)).To(Succeed())
+		readSnapshots, err := ReadSnapshotFile(filename)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readSnapshots).To(Equal(snapshots))
+	})
+
+	Context("when the file version is not supported", func() {
+		BeforeEach(func() {
+			filename = filepath.Join(tmpDir, "snapshot.json")
+			Expect(ioutil.WriteFile(filename, []byte(`{"version":2,"environments":[]}`), 0600)).To(Succeed())
+		})
+
+		It("returns an error", func() {
+			_, err := ReadSnapshotFile(filename)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("version 2 is not supported"))
+		})
+	})
+})
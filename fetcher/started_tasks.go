@@ -0,0 +1,76 @@
+package fetcher
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+var taskLocationRegexp = regexp.MustCompile(`/tasks/(\d+)$`)
+
+// StartedTasks records the BOSH Director tasks started by the requests sent
+// through the transport it wraps, read from the redirects of the Director to
+// the tasks it starts (e.g. for the `format=full` instance queries).
+type StartedTasks struct {
+	mu  *sync.Mutex
+	ids map[int]bool
+}
+
+func NewStartedTasks() *StartedTasks {
+	return &StartedTasks{mu: &sync.Mutex{}, ids: map[int]bool{}}
+}
+
+// Wrap returns a transport recording the tasks started by the requests sent
+// through transport.
+func (t *StartedTasks) Wrap(transport http.RoundTripper) http.RoundTripper {
+	return &startedTasksTransport{transport: transport, startedTasks: t}
+}
+
+// IDs returns the IDs of the recorded tasks.
+func (t *StartedTasks) IDs() map[int]bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ids := make(map[int]bool, len(t.ids))
+	for id := range t.ids {
+		ids[id] = true
+	}
+
+	return ids
+}
+
+// Forget stops recording a task, once it is finished or cancelled.
+func (t *StartedTasks) Forget(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.ids, id)
+}
+
+func (t *StartedTasks) record(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.ids[id] = true
+}
+
+type startedTasksTransport struct {
+	transport    http.RoundTripper
+	startedTasks *StartedTasks
+}
+
+func (t *startedTasksTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil || (resp.StatusCode != http.StatusFound && resp.StatusCode != http.StatusSeeOther) {
+		return resp, err
+	}
+
+	if match := taskLocationRegexp.FindStringSubmatch(resp.Header.Get("Location")); match != nil {
+		if id, err := strconv.Atoi(match[1]); err == nil {
+			t.startedTasks.record(id)
+		}
+	}
+
+	return resp, nil
+}
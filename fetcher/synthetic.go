@@ -0,0 +1,140 @@
+package fetcher
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+)
+
+const syntheticInstanceGroupSize = 10
+
+var syntheticAZs = []string{"z1", "z2", "z3"}
+
+type SyntheticOptions struct {
+	Environment string
+	Deployments int
+	Instances   int
+	Processes   int
+	Seed        int64
+}
+
+// SyntheticSnapshot generates a snapshot of a fake BOSH Director with the
+// requested number of deployments, instances per deployment and processes per
+// instance. The same options always generate the same snapshot.
+func SyntheticSnapshot(options SyntheticOptions, now time.Time) Snapshot {
+	random := rand.New(rand.NewSource(options.Seed))
+
+	snapshot := Snapshot{
+		Director: DirectorInfo{
+			Name:    options.Environment,
+			UUID:    fmt.Sprintf("00000000-0000-0000-0000-%012d", options.Seed),
+			Version: "271.0.0",
+		},
+		Deployments:        []deployments.DeploymentInfo{},
+		VisibleDeployments: []string{},
+		Tasks:              []deployments.Task{},
+		FetchedAt:          now,
+	}
+
+	for d := 0; d < options.Deployments; d++ {
+		deploymentName := fmt.Sprintf("deployment-%d", d)
+		deployment := deployments.DeploymentInfo{
+			Name: deploymentName,
+			Releases: []deployments.Release{
+				{Name: "synthetic-release", Version: fmt.Sprintf("%d.0.0", 1+random.Intn(20))},
+			},
+			Stemcells: []deployments.Stemcell{
+				{Name: "bosh-synthetic-ubuntu-jammy-go_agent", Version: "1.100", OSName: "ubuntu-jammy", LatestVersion: "1.100"},
+			},
+			Configs: []deployments.Config{
+				{ID: strconv.Itoa(d + 1), Type: "cloud", Name: "default", LatestID: strconv.Itoa(d + 1)},
+			},
+			Instances: []deployments.Instance{},
+		}
+
+		for i := 0; i < options.Instances; i++ {
+			deployment.Instances = append(deployment.Instances, syntheticInstance(random, d, i, options.Processes, now))
+		}
+
+		task := deployments.Task{
+			ID:             d + 1,
+			DeploymentName: deploymentName,
+			State:          "done",
+			Description:    "create deployment",
+			Result:         "/deployments/" + deploymentName,
+			StartedAt:      now.Add(-time.Duration(d+1) * time.Hour),
+			FinishedAt:     now.Add(-time.Duration(d+1)*time.Hour + 10*time.Minute),
+		}
+		deployment.Tasks = []deployments.Task{task}
+
+		snapshot.Deployments = append(snapshot.Deployments, deployment)
+		snapshot.VisibleDeployments = append(snapshot.VisibleDeployments, deploymentName)
+		snapshot.Tasks = append(snapshot.Tasks, task)
+	}
+
+	return snapshot
+}
+
+func syntheticInstance(random *rand.Rand, deploymentIndex int, instanceIndex int, processes int, now time.Time) deployments.Instance {
+	group := instanceIndex / syntheticInstanceGroupSize
+	uptime := uint64(3600 + random.Intn(30*24*3600))
+	cpuTotal := random.Float64() * 100
+	healthy := random.Intn(100) != 0
+
+	instance := deployments.Instance{
+		AgentID:      fmt.Sprintf("agent-%d-%d", deploymentIndex, instanceIndex),
+		Name:         fmt.Sprintf("instance-group-%d", group),
+		ID:           fmt.Sprintf("%08d-0000-0000-0000-%012d", deploymentIndex, instanceIndex),
+		Index:        strconv.Itoa(instanceIndex % syntheticInstanceGroupSize),
+		Bootstrap:    instanceIndex%syntheticInstanceGroupSize == 0,
+		IPs:          []string{fmt.Sprintf("10.%d.%d.%d", deploymentIndex%256, instanceIndex/256%256, instanceIndex%256)},
+		AZ:           syntheticAZs[instanceIndex%len(syntheticAZs)],
+		VMType:       "default",
+		ResourcePool: "default",
+		VMID:         fmt.Sprintf("vm-%d-%d", deploymentIndex, instanceIndex),
+		VMCreatedAt:  now.Add(-time.Duration(uptime) * time.Second),
+		Healthy:      healthy,
+		Processes:    []deployments.Process{},
+		Vitals: deployments.Vitals{
+			CPU: deployments.CPU{
+				Total: &cpuTotal,
+				Sys:   strconv.FormatFloat(random.Float64()*10, 'f', 1, 64),
+				User:  strconv.FormatFloat(random.Float64()*50, 'f', 1, 64),
+				Wait:  strconv.FormatFloat(random.Float64()*5, 'f', 1, 64),
+			},
+			Mem:    deployments.Mem{KB: strconv.Itoa(1024 * (512 + random.Intn(7680))), Percent: strconv.Itoa(random.Intn(100))},
+			Swap:   deployments.Mem{KB: "0", Percent: "0"},
+			Uptime: &uptime,
+			Load: []string{
+				strconv.FormatFloat(random.Float64()*4, 'f', 2, 64),
+				strconv.FormatFloat(random.Float64()*4, 'f', 2, 64),
+				strconv.FormatFloat(random.Float64()*4, 'f', 2, 64),
+			},
+			SystemDisk:     deployments.Disk{InodePercent: strconv.Itoa(random.Intn(50)), Percent: strconv.Itoa(random.Intn(90))},
+			EphemeralDisk:  deployments.Disk{InodePercent: strconv.Itoa(random.Intn(50)), Percent: strconv.Itoa(random.Intn(90))},
+			PersistentDisk: deployments.Disk{InodePercent: strconv.Itoa(random.Intn(50)), Percent: strconv.Itoa(random.Intn(90))},
+		},
+		PersistentDiskSizeMB: 10240,
+	}
+
+	for p := 0; p < processes; p++ {
+		processUptime := uptime
+		processCPU := random.Float64() * 10
+		processMemKB := uint64(1024 * (16 + random.Intn(1024)))
+		processMemPercent := random.Float64() * 10
+		instance.Processes = append(instance.Processes, deployments.Process{
+			Name:        fmt.Sprintf("process-%d", p),
+			JobTemplate: fmt.Sprintf("job-%d", p),
+			Uptime:      &processUptime,
+			Healthy:     healthy || p > 0,
+			CPU:         deployments.CPU{Total: &processCPU},
+			Mem:         deployments.MemInt{KB: &processMemKB, Percent: &processMemPercent},
+			Ports:       []int{8000 + p},
+		})
+	}
+
+	return instance
+}
@@ -0,0 +1,70 @@
+package fetcher_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+var _ = Describe("SyntheticSnapshot", func() {
+	var (
+		now      time.Time
+		options  SyntheticOptions
+		snapshot Snapshot
+	)
+
+	BeforeEach(func() {
+		now = time.Unix(1700000000, 0)
+		options = SyntheticOptions{Environment: "test", Deployments: 3, Instances: 25, Processes: 4, Seed: 42}
+	})
+
+	JustBeforeEach(func() {
+		snapshot = SyntheticSnapshot(options, now)
+	})
+
+	It("generates the requested deployments, instances and processes", func() {
+		Expect(snapshot.Director.Name).To(Equal("test"))
+		Expect(snapshot.Deployments).To(HaveLen(3))
+		Expect(snapshot.VisibleDeployments).To(Equal([]string{"deployment-0", "deployment-1", "deployment-2"}))
+		for _, deployment := range snapshot.Deployments {
+			Expect(deployment.Instances).To(HaveLen(25))
+			for _, instance := range deployment.Instances {
+				Expect(instance.Processes).To(HaveLen(4))
+				Expect(instance.IPs).To(HaveLen(1))
+			}
+		}
+	})
+
+	It("groups the instances by instance group", func() {
+		instanceGroups := map[string]int{}
+		for _, instance := range snapshot.Deployments[0].Instances {
+			instanceGroups[instance.Name]++
+		}
+		Expect(instanceGroups).To(Equal(map[string]int{"instance-group-0": 10, "instance-group-1": 10, "instance-group-2": 5}))
+	})
+
+	It("generates unique instance IPs", func() {
+		ips := map[string]bool{}
+		for _, deployment := range snapshot.Deployments {
+			for _, instance := range deployment.Instances {
+				Expect(ips).ToNot(HaveKey(instance.IPs[0]))
+				ips[instance.IPs[0]] = true
+			}
+		}
+	})
+
+	It("always generates the same snapshot for the same options", func() {
+		Expect(SyntheticSnapshot(options, now)).To(Equal(snapshot))
+	})
+
+	Context("when the seed changes", func() {
+		It("generates another snapshot", func() {
+			otherOptions := options
+			otherOptions.Seed = 43
+			Expect(SyntheticSnapshot(otherOptions, now)).ToNot(Equal(snapshot))
+		})
+	})
+})
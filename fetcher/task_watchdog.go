@@ -0,0 +1,159 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// TaskWatchdog cancels the tasks started by the exporter that are still
+// queued or processing after a deadline, so stuck queries do not pile up on
+// the BOSH Director. The tasks started by other clients with the same
+// credentials are left alone.
+type TaskWatchdog struct {
+	boshClient   director.Director
+	startedTasks *StartedTasks
+	deadline     time.Duration
+
+	cancelledTasks prometheus.Counter
+	cancelErrors   prometheus.Counter
+
+	mu        sync.Mutex
+	firstSeen map[int]time.Time
+}
+
+func NewTaskWatchdog(
+	namespace string,
+	environment string,
+	boshName string,
+	boshUUID string,
+	boshClient director.Director,
+	startedTasks *StartedTasks,
+	deadline time.Duration,
+) *TaskWatchdog {
+	constLabels := prometheus.Labels{
+		"environment": environment,
+		"bosh_name":   boshName,
+		"bosh_uuid":   boshUUID,
+	}
+
+	cancelledTasks := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "exporter",
+			Name:        "cancelled_tasks_total",
+			Help:        "Total number of stuck BOSH Director tasks started by the exporter and cancelled by the task watchdog.",
+			ConstLabels: constLabels,
+		},
+	)
+
+	cancelErrors := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "exporter",
+			Name:        "cancel_task_errors_total",
+			Help:        "Total number of errors while looking up or cancelling stuck BOSH Director tasks.",
+			ConstLabels: constLabels,
+		},
+	)
+
+	return &TaskWatchdog{
+		boshClient:     boshClient,
+		startedTasks:   startedTasks,
+		deadline:       deadline,
+		cancelledTasks: cancelledTasks,
+		cancelErrors:   cancelErrors,
+		firstSeen:      map[int]time.Time{},
+	}
+}
+
+// Check cancels the exporter tasks older than the deadline. Queued tasks have
+// no start time yet, so their age is counted from the first check that saw
+// them. The recorded tasks no longer current are forgotten.
+func (w *TaskWatchdog) Check(now time.Time) error {
+	startedTasks := w.startedTasks.IDs()
+	if len(startedTasks) == 0 {
+		return nil
+	}
+
+	tasks, err := w.boshClient.CurrentTasks(director.TasksFilter{All: true})
+	if err != nil {
+		w.cancelErrors.Inc()
+		return errors.New(fmt.Sprintf("Error while reading the current BOSH Director tasks: %v", err))
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	firstSeen := map[int]time.Time{}
+	for _, task := range tasks {
+		if !startedTasks[task.ID()] {
+			continue
+		}
+		delete(startedTasks, task.ID())
+
+		startedAt := task.StartedAt()
+		if seenAt, ok := w.firstSeen[task.ID()]; ok {
+			firstSeen[task.ID()] = seenAt
+		} else {
+			firstSeen[task.ID()] = now
+		}
+		if startedAt.Unix() <= 0 {
+			startedAt = firstSeen[task.ID()]
+		}
+
+		if now.Sub(startedAt) < w.deadline {
+			continue
+		}
+
+		if err := task.Cancel(); err != nil {
+			w.cancelErrors.Inc()
+			log.Errorf("Error while cancelling stuck BOSH Director task %d: %v", task.ID(), err)
+			continue
+		}
+		w.cancelledTasks.Inc()
+		log.Warnf("Cancelled BOSH Director task %d (`%s`) started at %s, older than %s", task.ID(), task.Description(), startedAt.Format(time.RFC3339), w.deadline)
+	}
+	w.firstSeen = firstSeen
+
+	// The recorded tasks that were not current when the tasks were read are
+	// finished; the ones started since then are kept for the next check.
+	for id := range startedTasks {
+		w.startedTasks.Forget(id)
+	}
+
+	return nil
+}
+
+// Run checks the tasks every interval until stop is closed.
+func (w *TaskWatchdog) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := w.Check(time.Now()); err != nil {
+			log.Errorf("%v", err)
+		}
+	}
+}
+
+func (w *TaskWatchdog) Describe(ch chan<- *prometheus.Desc) {
+	w.cancelledTasks.Describe(ch)
+	w.cancelErrors.Describe(ch)
+}
+
+func (w *TaskWatchdog) Collect(ch chan<- prometheus.Metric) {
+	w.cancelledTasks.Collect(ch)
+	w.cancelErrors.Collect(ch)
+}
@@ -0,0 +1,201 @@
+package fetcher_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-cli/director"
+	"github.com/cloudfoundry/bosh-cli/director/directorfakes"
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/bosh-prometheus/bosh_exporter/fetcher"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// redirectThrough sends a request through startedTasks, the Director answering
+// statusCode with location.
+func redirectThrough(startedTasks *StartedTasks, statusCode int, location string) {
+	transport := startedTasks.Wrap(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: statusCode, Header: http.Header{"Location": []string{location}}}, nil
+	}))
+	req, err := http.NewRequest(http.MethodGet, "https://director/deployments/fake-deployment/instances?format=full", nil)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = transport.RoundTrip(req)
+	Expect(err).ToNot(HaveOccurred())
+}
+
+var _ = Describe("TaskWatchdog", func() {
+	var (
+		err          error
+		boshClient   *directorfakes.FakeDirector
+		stuckTask    *directorfakes.FakeTask
+		recentTask   *directorfakes.FakeTask
+		queuedTask   *directorfakes.FakeTask
+		foreignTask  *directorfakes.FakeTask
+		deployTask   *directorfakes.FakeTask
+		startedTasks *StartedTasks
+		taskWatchdog *TaskWatchdog
+		now          time.Time
+
+		cancelledTasksMetric prometheus.Counter
+	)
+
+	newTask := func(id int, user string, description string, startedAt time.Time) *directorfakes.FakeTask {
+		return &directorfakes.FakeTask{
+			IDStub:          func() int { return id },
+			UserStub:        func() string { return user },
+			DescriptionStub: func() string { return description },
+			StartedAtStub:   func() time.Time { return startedAt },
+		}
+	}
+
+	// start records a task as started by the exporter, the Director
+	// redirecting the request starting it to the task.
+	start := func(id int) {
+		redirectThrough(startedTasks, http.StatusFound, fmt.Sprintf("/tasks/%d", id))
+	}
+
+	BeforeEach(func() {
+		now = time.Date(2018, time.January, 1, 1, 0, 0, 0, time.UTC)
+		stuckTask = newTask(1, "bosh_exporter", "retrieve vm-stats", now.Add(-time.Hour))
+		recentTask = newTask(2, "bosh_exporter", "retrieve vm-stats", now.Add(-time.Minute))
+		queuedTask = newTask(3, "bosh_exporter", "retrieve vm-stats", time.Unix(0, 0).UTC())
+		foreignTask = newTask(4, "bosh_exporter", "retrieve vm-stats", now.Add(-time.Hour))
+		deployTask = newTask(5, "bosh_exporter", "create deployment", now.Add(-time.Hour))
+
+		boshClient = &directorfakes.FakeDirector{}
+		boshClient.CurrentTasksReturns([]director.Task{stuckTask, recentTask, queuedTask, foreignTask, deployTask}, nil)
+
+		cancelledTasksMetric = prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "test_exporter",
+				Subsystem: "exporter",
+				Name:      "cancelled_tasks_total",
+				Help:      "Total number of stuck BOSH Director tasks started by the exporter and cancelled by the task watchdog.",
+				ConstLabels: prometheus.Labels{
+					"environment": "test_environment",
+					"bosh_name":   "test_bosh_name",
+					"bosh_uuid":   "test_bosh_uuid",
+				},
+			},
+		)
+
+		startedTasks = NewStartedTasks()
+		start(1)
+		start(2)
+		start(3)
+		start(6)
+
+		taskWatchdog = NewTaskWatchdog("test_exporter", "test_environment", "test_bosh_name", "test_bosh_uuid", boshClient, startedTasks, 10*time.Minute)
+	})
+
+	Describe("Check", func() {
+		JustBeforeEach(func() {
+			err = taskWatchdog.Check(now)
+		})
+
+		It("reads every current task", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(boshClient.CurrentTasksArgsForCall(0)).To(Equal(director.TasksFilter{All: true}))
+		})
+
+		It("cancels the exporter VM stats tasks older than the deadline", func() {
+			Expect(stuckTask.CancelCallCount()).To(Equal(1))
+			Expect(recentTask.CancelCallCount()).To(Equal(0))
+		})
+
+		It("does not cancel the tasks not started by the exporter", func() {
+			Expect(foreignTask.CancelCallCount()).To(Equal(0))
+			Expect(deployTask.CancelCallCount()).To(Equal(0))
+		})
+
+		It("forgets the finished tasks", func() {
+			Expect(startedTasks.IDs()).To(Equal(map[int]bool{1: true, 2: true, 3: true}))
+		})
+
+		Context("when the exporter has no task started", func() {
+			BeforeEach(func() {
+				startedTasks = NewStartedTasks()
+				taskWatchdog = NewTaskWatchdog("test_exporter", "test_environment", "test_bosh_name", "test_bosh_uuid", boshClient, startedTasks, 10*time.Minute)
+			})
+
+			It("does not read the current tasks", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(boshClient.CurrentTasksCallCount()).To(Equal(0))
+			})
+		})
+
+		It("does not cancel queued tasks seen for the first time", func() {
+			Expect(queuedTask.CancelCallCount()).To(Equal(0))
+		})
+
+		It("cancels queued tasks still queued after the deadline", func() {
+			Expect(taskWatchdog.Check(now.Add(11 * time.Minute))).To(Succeed())
+			Expect(queuedTask.CancelCallCount()).To(Equal(1))
+		})
+
+		It("returns an exporter_cancelled_tasks_total metric", func() {
+			cancelledTasksMetric.Inc()
+
+			metrics := make(chan prometheus.Metric, 2)
+			taskWatchdog.Collect(metrics)
+			Expect(metrics).To(Receive(PrometheusMetric(cancelledTasksMetric)))
+		})
+
+		Context("when it fails to cancel a task", func() {
+			BeforeEach(func() {
+				stuckTask.CancelReturns(errors.New("no task"))
+			})
+
+			It("does not return an error", func() {
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when it fails to read the current tasks", func() {
+			BeforeEach(func() {
+				boshClient.CurrentTasksReturns(nil, errors.New("no tasks"))
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("no tasks"))
+			})
+		})
+	})
+})
+
+var _ = Describe("StartedTasks", func() {
+	var (
+		startedTasks *StartedTasks
+	)
+
+	BeforeEach(func() {
+		startedTasks = NewStartedTasks()
+	})
+
+	It("records the tasks the Director redirects to", func() {
+		redirectThrough(startedTasks, http.StatusFound, "/tasks/42")
+		Expect(startedTasks.IDs()).To(Equal(map[int]bool{42: true}))
+
+		startedTasks.Forget(42)
+		Expect(startedTasks.IDs()).To(BeEmpty())
+	})
+
+	It("does not record the other redirects", func() {
+		redirectThrough(startedTasks, http.StatusFound, "/deployments")
+		redirectThrough(startedTasks, http.StatusOK, "/tasks/42")
+		Expect(startedTasks.IDs()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,56 @@
+package filesystem
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// FS is the file system the Service Discovery output and its backups are
+// written to, so the writes can be tested with a MemFS instead of real temp
+// files.
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (os.FileInfo, error)
+	TempFile(dir string, pattern string) (File, error)
+	Chmod(name string, mode os.FileMode) error
+	Rename(oldpath string, newpath string) error
+	Remove(name string) error
+}
+
+// File is a file opened for writing by an FS.
+type File interface {
+	io.Writer
+	Name() string
+	Sync() error
+	Close() error
+}
+
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) TempFile(dir string, pattern string) (File, error) {
+	return ioutil.TempFile(dir, pattern)
+}
+
+func (osFS) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (osFS) Rename(oldpath string, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// OS is the FS of the operating system.
+var OS FS = osFS{}
@@ -0,0 +1,219 @@
+package filesystem
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// MemFS is an in memory FS, whose next operations can be made to fail.
+type MemFS struct {
+	files     map[string][]byte
+	modes     map[string]os.FileMode
+	failures  map[string][]error
+	tempFiles int
+	mu        *sync.Mutex
+}
+
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files:    map[string][]byte{},
+		modes:    map[string]os.FileMode{},
+		failures: map[string][]error{},
+		mu:       &sync.Mutex{},
+	}
+}
+
+// FailNext makes the next call of the op (`ReadFile`, `Stat`, `TempFile`,
+// `Write`, `Sync`, `Close`, `Chmod`, `Rename` or `Remove`) return err.
+func (fs *MemFS) FailNext(op string, err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.failures[op] = append(fs.failures[op], err)
+}
+
+// WriteFile sets the content of a file.
+func (fs *MemFS) WriteFile(name string, content []byte, mode os.FileMode) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.files[path.Clean(name)] = append([]byte{}, content...)
+	fs.modes[path.Clean(name)] = mode
+}
+
+// Files returns the content of every file by name.
+func (fs *MemFS) Files() map[string][]byte {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	files := map[string][]byte{}
+	for name, content := range fs.files {
+		files[name] = append([]byte{}, content...)
+	}
+
+	return files
+}
+
+func (fs *MemFS) ReadFile(name string) ([]byte, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.failure("ReadFile"); err != nil {
+		return nil, err
+	}
+	content, ok := fs.files[path.Clean(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	return append([]byte{}, content...), nil
+}
+
+func (fs *MemFS) Stat(name string) (os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.failure("Stat"); err != nil {
+		return nil, err
+	}
+	content, ok := fs.files[path.Clean(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	return memFileInfo{name: path.Base(name), size: int64(len(content)), mode: fs.modes[path.Clean(name)]}, nil
+}
+
+func (fs *MemFS) TempFile(dir string, pattern string) (File, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.failure("TempFile"); err != nil {
+		return nil, err
+	}
+	fs.tempFiles++
+	name := path.Join(dir, fmt.Sprintf("%s%d", pattern, fs.tempFiles))
+	fs.files[name] = []byte{}
+	fs.modes[name] = 0600
+
+	return &memFile{fs: fs, name: name, buffer: &bytes.Buffer{}}, nil
+}
+
+func (fs *MemFS) Chmod(name string, mode os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.failure("Chmod"); err != nil {
+		return err
+	}
+	if _, ok := fs.files[path.Clean(name)]; !ok {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+	fs.modes[path.Clean(name)] = mode
+
+	return nil
+}
+
+func (fs *MemFS) Rename(oldpath string, newpath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.failure("Rename"); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	content, ok := fs.files[path.Clean(oldpath)]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+	fs.files[path.Clean(newpath)] = content
+	fs.modes[path.Clean(newpath)] = fs.modes[path.Clean(oldpath)]
+	delete(fs.files, path.Clean(oldpath))
+	delete(fs.modes, path.Clean(oldpath))
+
+	return nil
+}
+
+func (fs *MemFS) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.failure("Remove"); err != nil {
+		return err
+	}
+	if _, ok := fs.files[path.Clean(name)]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(fs.files, path.Clean(name))
+	delete(fs.modes, path.Clean(name))
+
+	return nil
+}
+
+func (fs *MemFS) failure(op string) error {
+	failures := fs.failures[op]
+	if len(failures) == 0 {
+		return nil
+	}
+	fs.failures[op] = failures[1:]
+
+	return failures[0]
+}
+
+type memFile struct {
+	fs     *MemFS
+	name   string
+	buffer *bytes.Buffer
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.fs.failure("Write"); err != nil {
+		return 0, err
+	}
+
+	return f.buffer.Write(p)
+}
+
+func (f *memFile) Sync() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	return f.fs.failure("Sync")
+}
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.fs.failure("Close"); err != nil {
+		return err
+	}
+	if _, ok := f.fs.files[f.name]; ok {
+		f.fs.files[f.name] = f.buffer.Bytes()
+	}
+
+	return nil
+}
+
+type memFileInfo struct {
+	name string
+	size int64
+	mode os.FileMode
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() os.FileMode  { return i.mode }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return false }
+func (i memFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,60 @@
+package filesystem_test
+
+import (
+	"errors"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/filesystem"
+)
+
+var _ = Describe("MemFS", func() {
+	var (
+		fs *MemFS
+	)
+
+	BeforeEach(func() {
+		fs = NewMemFS()
+	})
+
+	It("writes a temp file and renames it", func() {
+		f, err := fs.TempFile("/sd", "bosh_target_groups.json")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = f.Write([]byte("[]"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Sync()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+		Expect(fs.Chmod(f.Name(), 0644)).To(Succeed())
+		Expect(fs.Rename(f.Name(), "/sd/bosh_target_groups.json")).To(Succeed())
+
+		Expect(fs.Files()).To(Equal(map[string][]byte{"/sd/bosh_target_groups.json": []byte("[]")}))
+		fileInfo, err := fs.Stat("/sd/bosh_target_groups.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fileInfo.Mode()).To(Equal(os.FileMode(0644)))
+		Expect(fileInfo.Size()).To(Equal(int64(2)))
+	})
+
+	It("reads a file", func() {
+		fs.WriteFile("/sd/bosh_target_groups.json", []byte("[]"), 0644)
+		content, err := fs.ReadFile("/sd/bosh_target_groups.json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content).To(Equal([]byte("[]")))
+	})
+
+	It("returns not exist errors for missing files", func() {
+		_, err := fs.ReadFile("/sd/missing.json")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		_, err = fs.Stat("/sd/missing.json")
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		Expect(os.IsNotExist(fs.Remove("/sd/missing.json"))).To(BeTrue())
+	})
+
+	It("fails the next call of an operation", func() {
+		fs.WriteFile("/sd/a.json", []byte("[]"), 0644)
+		fs.FailNext("Rename", errors.New("cross-device link"))
+		Expect(fs.Rename("/sd/a.json", "/sd/b.json")).To(MatchError(ContainSubstring("cross-device link")))
+		Expect(fs.Rename("/sd/a.json", "/sd/b.json")).To(Succeed())
+	})
+})
@@ -1,25 +1,76 @@
 package filters
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 )
 
+const AZCloudPropertiesAttributePrefix = "az_cloud_properties."
+
+// AZsFilter enables instances by AZ name, or by AZ cloud properties for the
+// `<property>=<value>` filters (e.g. `datacenter=dc1`).
 type AZsFilter struct {
-	azsEnabled map[string]bool
+	azsEnabled        map[string]bool
+	propertiesEnabled map[string]bool
 }
 
 func NewAZsFilter(filters []string) *AZsFilter {
 	azsEnabled := make(map[string]bool)
+	propertiesEnabled := make(map[string]bool)
 
 	for _, az := range filters {
-		azsEnabled[strings.Trim(az, " ")] = true
+		az = strings.Trim(az, " ")
+		if strings.Contains(az, "=") {
+			propertiesEnabled[az] = true
+			continue
+		}
+		azsEnabled[az] = true
+	}
+
+	return &AZsFilter{azsEnabled: azsEnabled, propertiesEnabled: propertiesEnabled}
+}
+
+// CloudPropertiesRequired returns whether the filter needs the AZ cloud
+// properties of the instances.
+func (f *AZsFilter) CloudPropertiesRequired() bool {
+	return len(f.propertiesEnabled) > 0
+}
+
+// EnabledInstance also matches the `az_cloud_properties.<property>` attributes
+// of an instance against the AZ cloud properties filters.
+func (f *AZsFilter) EnabledInstance(az string, attributes map[string]string) bool {
+	if f.Enabled(az) {
+		return true
+	}
+
+	_, ok := f.enabledProperty(attributes)
+	return ok
+}
+
+// enabledProperty returns the first AZ cloud properties filter, in order,
+// matched by the `az_cloud_properties.<property>` attributes of an instance.
+func (f *AZsFilter) enabledProperty(attributes map[string]string) (string, bool) {
+	properties := []string{}
+	for name, value := range attributes {
+		if !strings.HasPrefix(name, AZCloudPropertiesAttributePrefix) {
+			continue
+		}
+		properties = append(properties, strings.TrimPrefix(name, AZCloudPropertiesAttributePrefix)+"="+value)
+	}
+	sort.Strings(properties)
+
+	for _, property := range properties {
+		if f.propertiesEnabled[property] {
+			return property, true
+		}
 	}
 
-	return &AZsFilter{azsEnabled: azsEnabled}
+	return "", false
 }
 
 func (f *AZsFilter) Enabled(az string) bool {
-	if len(f.azsEnabled) == 0 {
+	if len(f.azsEnabled) == 0 && len(f.propertiesEnabled) == 0 {
 		return true
 	}
 
@@ -29,3 +80,34 @@ func (f *AZsFilter) Enabled(az string) bool {
 
 	return false
 }
+
+// Explain takes the `az_cloud_properties.<property>` attributes of the
+// instance into account, like EnabledInstance.
+func (f *AZsFilter) Explain(az string, attributes map[string]string) FilterResult {
+	result := FilterResult{Filter: "azs", Value: az, Accepted: f.EnabledInstance(az, attributes)}
+
+	azs := []string{}
+	for enabledAZ := range f.azsEnabled {
+		azs = append(azs, enabledAZ)
+	}
+	for enabledProperty := range f.propertiesEnabled {
+		azs = append(azs, enabledProperty)
+	}
+	sort.Strings(azs)
+
+	property, propertyEnabled := f.enabledProperty(attributes)
+	switch {
+	case len(azs) == 0:
+		result.Reason = "No AZs filter configured"
+	case f.azsEnabled[az]:
+		result.Reason = fmt.Sprintf("AZ `%s` is in the AZs filter `%v`", az, azs)
+	case propertyEnabled:
+		result.Reason = fmt.Sprintf("AZ `%s` cloud property `%s` is in the AZs filter `%v`", az, property, azs)
+	case len(f.propertiesEnabled) > 0:
+		result.Reason = fmt.Sprintf("Neither AZ `%s` nor its cloud properties are in the AZs filter `%v`", az, azs)
+	default:
+		result.Reason = fmt.Sprintf("AZ `%s` is not in the AZs filter `%v`", az, azs)
+	}
+
+	return result
+}
@@ -54,4 +54,108 @@ var _ = Describe("AZsFilter", func() {
 			})
 		})
 	})
+
+	Describe("EnabledInstance", func() {
+		BeforeEach(func() {
+			filter = []string{"fake-az-1", "datacenter=dc1"}
+		})
+
+		It("enables an instance in an enabled az", func() {
+			Expect(azsFilter.EnabledInstance("fake-az-1", map[string]string{})).To(BeTrue())
+		})
+
+		It("enables an instance whose az cloud properties match", func() {
+			Expect(azsFilter.EnabledInstance("fake-az-2", map[string]string{"az_cloud_properties.datacenter": "dc1"})).To(BeTrue())
+		})
+
+		It("does not enable an instance whose az cloud properties do not match", func() {
+			Expect(azsFilter.EnabledInstance("fake-az-2", map[string]string{"az_cloud_properties.datacenter": "dc2"})).To(BeFalse())
+		})
+
+		It("does not match the instance cloud properties", func() {
+			Expect(azsFilter.EnabledInstance("fake-az-2", map[string]string{"cloud_properties.datacenter": "dc1"})).To(BeFalse())
+		})
+
+		It("requires the az cloud properties", func() {
+			Expect(azsFilter.CloudPropertiesRequired()).To(BeTrue())
+		})
+
+		Context("when there are only az names", func() {
+			BeforeEach(func() {
+				filter = []string{"fake-az-1"}
+			})
+
+			It("does not require the az cloud properties", func() {
+				Expect(azsFilter.CloudPropertiesRequired()).To(BeFalse())
+			})
+		})
+
+		Context("when there is no filter", func() {
+			BeforeEach(func() {
+				filter = []string{}
+			})
+
+			It("enables any instance", func() {
+				Expect(azsFilter.EnabledInstance("fake-az-2", map[string]string{})).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Explain", func() {
+		BeforeEach(func() {
+			filter = []string{"fake-az-2", "fake-az-1"}
+		})
+
+		It("accepts an enabled az", func() {
+			Expect(azsFilter.Explain("fake-az-1", nil)).To(Equal(FilterResult{
+				Filter:   "azs",
+				Value:    "fake-az-1",
+				Accepted: true,
+				Reason:   "AZ `fake-az-1` is in the AZs filter `[fake-az-1 fake-az-2]`",
+			}))
+		})
+
+		It("rejects a not enabled az", func() {
+			Expect(azsFilter.Explain("fake-az-3", nil)).To(Equal(FilterResult{
+				Filter:   "azs",
+				Value:    "fake-az-3",
+				Accepted: false,
+				Reason:   "AZ `fake-az-3` is not in the AZs filter `[fake-az-1 fake-az-2]`",
+			}))
+		})
+
+		Context("when there are az cloud properties filters", func() {
+			BeforeEach(func() {
+				filter = []string{"fake-az-1", "datacenter=dc1"}
+			})
+
+			It("accepts an az whose cloud properties match", func() {
+				Expect(azsFilter.Explain("fake-az-2", map[string]string{"az_cloud_properties.datacenter": "dc1"})).To(Equal(FilterResult{
+					Filter:   "azs",
+					Value:    "fake-az-2",
+					Accepted: true,
+					Reason:   "AZ `fake-az-2` cloud property `datacenter=dc1` is in the AZs filter `[datacenter=dc1 fake-az-1]`",
+				}))
+			})
+
+			It("rejects an az whose cloud properties do not match", func() {
+				Expect(azsFilter.Explain("fake-az-2", map[string]string{"az_cloud_properties.datacenter": "dc2"})).To(Equal(FilterResult{
+					Filter:   "azs",
+					Value:    "fake-az-2",
+					Accepted: false,
+					Reason:   "Neither AZ `fake-az-2` nor its cloud properties are in the AZs filter `[datacenter=dc1 fake-az-1]`",
+				}))
+			})
+		})
+
+		Context("when there are no filters", func() {
+			BeforeEach(func() {
+				filter = []string{}
+			})
+
+			It("accepts any az", func() {
+				Expect(azsFilter.Explain("fake-az-3", nil).Accepted).To(BeTrue())
+			})
+		})
+	})
 })
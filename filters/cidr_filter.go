@@ -1,6 +1,7 @@
 package filters
 
 import (
+	"fmt"
 	"net"
 )
 
@@ -37,3 +38,24 @@ func (f *CidrFilter) Select(ips []string) (string, bool) {
 
 	return "", false
 }
+
+func (f *CidrFilter) Explain(ip string) FilterResult {
+	result := FilterResult{Filter: "cidrs", Value: ip}
+
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		result.Reason = fmt.Sprintf("`%s` is not a valid IP", ip)
+		return result
+	}
+
+	for _, c := range f.cidrFilters {
+		if c.Contains(parsedIP) {
+			result.Accepted = true
+			result.Reason = fmt.Sprintf("IP `%s` is within CIDR `%s`", ip, c.String())
+			return result
+		}
+	}
+
+	result.Reason = fmt.Sprintf("IP `%s` is not within any CIDR `%v`", ip, f.cidrFilters)
+	return result
+}
@@ -116,4 +116,32 @@ var _ = Describe("Cidr Filter", func() {
 			})
 		})
 	})
+
+	Describe("Explain", func() {
+		BeforeEach(func() {
+			cidrs = []string{"10.254.0.0/16"}
+		})
+
+		It("accepts an ip within a cidr", func() {
+			Expect(cidrFilter.Explain("10.254.1.1")).To(Equal(FilterResult{
+				Filter:   "cidrs",
+				Value:    "10.254.1.1",
+				Accepted: true,
+				Reason:   "IP `10.254.1.1` is within CIDR `10.254.0.0/16`",
+			}))
+		})
+
+		It("rejects an ip not within any cidr", func() {
+			Expect(cidrFilter.Explain("192.168.0.1")).To(Equal(FilterResult{
+				Filter:   "cidrs",
+				Value:    "192.168.0.1",
+				Accepted: false,
+				Reason:   "IP `192.168.0.1` is not within any CIDR `[10.254.0.0/16]`",
+			}))
+		})
+
+		It("rejects an invalid ip", func() {
+			Expect(cidrFilter.Explain("invalid").Accepted).To(BeFalse())
+		})
+	})
 })
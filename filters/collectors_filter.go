@@ -10,6 +10,7 @@ const (
 	DeploymentsCollector      = "Deployments"
 	JobsCollector             = "Jobs"
 	ServiceDiscoveryCollector = "ServiceDiscovery"
+	TasksCollector            = "Tasks"
 )
 
 type CollectorsFilter struct {
@@ -27,6 +28,8 @@ func NewCollectorsFilter(filters []string) (*CollectorsFilter, error) {
 			collectorsEnabled[JobsCollector] = true
 		case ServiceDiscoveryCollector:
 			collectorsEnabled[ServiceDiscoveryCollector] = true
+		case TasksCollector:
+			collectorsEnabled[TasksCollector] = true
 		default:
 			return &CollectorsFilter{}, errors.New(fmt.Sprintf("Collector filter `%s` is not supported", collectorName))
 		}
@@ -22,7 +22,7 @@ var _ = Describe("CollectorsFilter", func() {
 	Describe("New", func() {
 		Context("when filters are supported", func() {
 			BeforeEach(func() {
-				filters = []string{DeploymentsCollector, JobsCollector, ServiceDiscoveryCollector}
+				filters = []string{DeploymentsCollector, JobsCollector, ServiceDiscoveryCollector, TasksCollector}
 			})
 
 			It("does not return an error", func() {
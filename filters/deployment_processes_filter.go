@@ -0,0 +1,86 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const anyDeployment = "*"
+
+type DeploymentProcessesFilter struct {
+	processesEnabled map[string][]*regexp.Regexp
+}
+
+func NewDeploymentProcessesFilter(filter string) (*DeploymentProcessesFilter, error) {
+	processesEnabled := map[string][]*regexp.Regexp{}
+
+	for _, deploymentFilter := range strings.Split(filter, ";") {
+		if strings.TrimSpace(deploymentFilter) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(deploymentFilter, ":", 2)
+		deployment := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || deployment == "" {
+			return nil, errors.New(fmt.Sprintf("Deployment processes filter `%s` must be formatted as <deployment>:<process regexp>[,<process regexp>]", deploymentFilter))
+		}
+
+		for _, process := range strings.Split(parts[1], ",") {
+			process = strings.TrimSpace(process)
+			if process == "" {
+				continue
+			}
+
+			re, err := regexp.Compile("^(?:" + process + ")$")
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Error compiling deployment `%s` processes filter `%s`: %v", deployment, process, err))
+			}
+			processesEnabled[deployment] = append(processesEnabled[deployment], re)
+		}
+
+		if _, ok := processesEnabled[deployment]; !ok {
+			processesEnabled[deployment] = []*regexp.Regexp{}
+		}
+	}
+
+	return &DeploymentProcessesFilter{processesEnabled: processesEnabled}, nil
+}
+
+func (f *DeploymentProcessesFilter) Enabled(deployment string, process string) bool {
+	if len(f.processesEnabled) == 0 {
+		return true
+	}
+
+	reFilters, ok := f.processesEnabled[deployment]
+	if !ok {
+		reFilters = f.processesEnabled[anyDeployment]
+	}
+
+	for _, re := range reFilters {
+		if re.MatchString(process) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *DeploymentProcessesFilter) Explain(deployment string, process string) FilterResult {
+	result := FilterResult{Filter: "deployment_processes", Value: deployment + ":" + process, Accepted: f.Enabled(deployment, process)}
+
+	_, deploymentScoped := f.processesEnabled[deployment]
+	switch {
+	case len(f.processesEnabled) == 0:
+		result.Reason = "No deployment processes filter configured"
+	case result.Accepted:
+		result.Reason = fmt.Sprintf("Process `%s` is allowed for deployment `%s`", process, deployment)
+	case !deploymentScoped && len(f.processesEnabled[anyDeployment]) == 0:
+		result.Reason = fmt.Sprintf("Deployment `%s` is not in the deployment processes filter", deployment)
+	default:
+		result.Reason = fmt.Sprintf("Process `%s` is not allowed for deployment `%s`", process, deployment)
+	}
+
+	return result
+}
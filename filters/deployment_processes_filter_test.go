@@ -0,0 +1,113 @@
+package filters_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/filters"
+)
+
+var _ = Describe("DeploymentProcessesFilter", func() {
+	var (
+		err    error
+		filter string
+
+		deploymentProcessesFilter *DeploymentProcessesFilter
+	)
+
+	JustBeforeEach(func() {
+		deploymentProcessesFilter, err = NewDeploymentProcessesFilter(filter)
+	})
+
+	Describe("New", func() {
+		Context("when the filter is well formatted", func() {
+			BeforeEach(func() {
+				filter = "cf:gorouter,uaa;mysql:.*"
+			})
+
+			It("does not return an error", func() {
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when a deployment has no processes list", func() {
+			BeforeEach(func() {
+				filter = "cf"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("<deployment>:<process regexp>"))
+			})
+		})
+
+		Context("when a process regexp does not compile", func() {
+			BeforeEach(func() {
+				filter = "cf:[z-a]"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Enabled", func() {
+		BeforeEach(func() {
+			filter = "cf:gorouter,uaa;mysql:.*"
+		})
+
+		It("enables the listed processes of a deployment", func() {
+			Expect(deploymentProcessesFilter.Enabled("cf", "gorouter")).To(BeTrue())
+			Expect(deploymentProcessesFilter.Enabled("cf", "uaa")).To(BeTrue())
+			Expect(deploymentProcessesFilter.Enabled("mysql", "mysqld")).To(BeTrue())
+		})
+
+		It("matches full process names", func() {
+			Expect(deploymentProcessesFilter.Enabled("cf", "gorouter_healthchecker")).To(BeFalse())
+		})
+
+		It("disables the processes of unlisted deployments", func() {
+			Expect(deploymentProcessesFilter.Enabled("redis", "redis")).To(BeFalse())
+		})
+
+		Context("when there is a catch-all deployment", func() {
+			BeforeEach(func() {
+				filter = "cf:gorouter;*:node_exporter"
+			})
+
+			It("applies it to unlisted deployments only", func() {
+				Expect(deploymentProcessesFilter.Enabled("redis", "node_exporter")).To(BeTrue())
+				Expect(deploymentProcessesFilter.Enabled("redis", "redis")).To(BeFalse())
+				Expect(deploymentProcessesFilter.Enabled("cf", "node_exporter")).To(BeFalse())
+			})
+		})
+
+		Context("when there is no filter", func() {
+			BeforeEach(func() {
+				filter = ""
+			})
+
+			It("enables every process", func() {
+				Expect(deploymentProcessesFilter.Enabled("redis", "redis")).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Explain", func() {
+		BeforeEach(func() {
+			filter = "cf:gorouter"
+		})
+
+		It("explains why a process is rejected", func() {
+			result := deploymentProcessesFilter.Explain("redis", "redis")
+			Expect(result.Accepted).To(BeFalse())
+			Expect(result.Reason).To(ContainSubstring("not in the deployment processes filter"))
+		})
+
+		It("explains why a process is accepted", func() {
+			result := deploymentProcessesFilter.Explain("cf", "gorouter")
+			Expect(result.Accepted).To(BeTrue())
+		})
+	})
+})
@@ -41,3 +41,24 @@ func (f *DeploymentsFilter) GetDeployments() ([]director.Deployment, error) {
 
 	return deployments, nil
 }
+
+func (f *DeploymentsFilter) Explain(deploymentName string) FilterResult {
+	result := FilterResult{Filter: "deployments", Value: deploymentName}
+
+	if len(f.filters) == 0 {
+		result.Accepted = true
+		result.Reason = "No deployments filter configured"
+		return result
+	}
+
+	for _, filter := range f.filters {
+		if strings.Trim(filter, " ") == deploymentName {
+			result.Accepted = true
+			result.Reason = fmt.Sprintf("Deployment `%s` is in the deployments filter `%v`", deploymentName, f.filters)
+			return result
+		}
+	}
+
+	result.Reason = fmt.Sprintf("Deployment `%s` is not in the deployments filter `%v`", deploymentName, f.filters)
+	return result
+}
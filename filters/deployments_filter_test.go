@@ -123,4 +123,38 @@ var _ = Describe("DeploymentsFilter", func() {
 			})
 		})
 	})
+
+	Describe("Explain", func() {
+		BeforeEach(func() {
+			filters = []string{" fake-deployment-1 "}
+			boshClient = &directorfakes.FakeDirector{}
+		})
+
+		JustBeforeEach(func() {
+			deploymentsFilter = NewDeploymentsFilter(filters, boshClient)
+		})
+
+		It("accepts a filtered deployment", func() {
+			Expect(deploymentsFilter.Explain("fake-deployment-1").Accepted).To(BeTrue())
+		})
+
+		It("rejects a not filtered deployment", func() {
+			Expect(deploymentsFilter.Explain("fake-deployment-2")).To(Equal(FilterResult{
+				Filter:   "deployments",
+				Value:    "fake-deployment-2",
+				Accepted: false,
+				Reason:   "Deployment `fake-deployment-2` is not in the deployments filter `[ fake-deployment-1 ]`",
+			}))
+		})
+
+		Context("when there are no filters", func() {
+			BeforeEach(func() {
+				filters = []string{}
+			})
+
+			It("accepts any deployment", func() {
+				Expect(deploymentsFilter.Explain("fake-deployment-2").Accepted).To(BeTrue())
+			})
+		})
+	})
 })
@@ -0,0 +1,405 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var expressionFields = map[string]bool{
+	"deployment": true,
+	"job":        true,
+	"az":         true,
+	"process":    true,
+	"ip":         true,
+}
+
+var expressionOperators = map[string]bool{
+	"(": true, ")": true, ",": true, "!": true,
+	"==": true, "!=": true, "=~": true, "!~": true, "&&": true, "||": true,
+}
+
+type expressionResult int
+
+const (
+	expressionFalse expressionResult = iota
+	expressionUnknown
+	expressionTrue
+)
+
+type expressionNode interface {
+	eval(fields map[string]string) expressionResult
+}
+
+type ExpressionFilter struct {
+	expression string
+	root       expressionNode
+}
+
+func NewExpressionFilter(expression string) (*ExpressionFilter, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return &ExpressionFilter{}, nil
+	}
+
+	tokens, err := tokenizeExpression(expression)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing filter expression `%s`: %v", expression, err))
+	}
+
+	parser := &expressionParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err == nil && parser.pos < len(parser.tokens) {
+		err = errors.New(fmt.Sprintf("unexpected `%s`", parser.tokens[parser.pos].value))
+	}
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing filter expression `%s`: %v", expression, err))
+	}
+
+	return &ExpressionFilter{expression: expression, root: root}, nil
+}
+
+// Enabled only rejects when the known fields make the expression false, so
+// a deployment can be filtered before its instances and processes are known.
+func (f *ExpressionFilter) Enabled(fields map[string]string) bool {
+	if f.root == nil {
+		return true
+	}
+
+	return f.root.eval(fields) != expressionFalse
+}
+
+func (f *ExpressionFilter) Explain(fields map[string]string) FilterResult {
+	values := []string{}
+	for _, field := range []string{"deployment", "job", "az", "process", "ip"} {
+		if value, ok := fields[field]; ok {
+			values = append(values, field+"="+value)
+		}
+	}
+
+	result := FilterResult{Filter: "expression", Value: strings.Join(values, ","), Accepted: f.Enabled(fields)}
+
+	switch {
+	case f.root == nil:
+		result.Reason = "No filter expression configured"
+	case result.Accepted:
+		result.Reason = fmt.Sprintf("`%s` does not reject the filter expression `%s`", result.Value, f.expression)
+	default:
+		result.Reason = fmt.Sprintf("`%s` does not match the filter expression `%s`", result.Value, f.expression)
+	}
+
+	return result
+}
+
+type andNode struct {
+	left  expressionNode
+	right expressionNode
+}
+
+func (n andNode) eval(fields map[string]string) expressionResult {
+	left := n.left.eval(fields)
+	if left == expressionFalse {
+		return expressionFalse
+	}
+
+	right := n.right.eval(fields)
+	if right < left {
+		return right
+	}
+	return left
+}
+
+type orNode struct {
+	left  expressionNode
+	right expressionNode
+}
+
+func (n orNode) eval(fields map[string]string) expressionResult {
+	left := n.left.eval(fields)
+	if left == expressionTrue {
+		return expressionTrue
+	}
+
+	right := n.right.eval(fields)
+	if right > left {
+		return right
+	}
+	return left
+}
+
+type notNode struct {
+	node expressionNode
+}
+
+func (n notNode) eval(fields map[string]string) expressionResult {
+	return expressionTrue - n.node.eval(fields)
+}
+
+type matchNode struct {
+	field string
+	match func(value string) bool
+}
+
+func (n matchNode) eval(fields map[string]string) expressionResult {
+	value, ok := fields[n.field]
+	if !ok {
+		return expressionUnknown
+	}
+
+	if n.match(value) {
+		return expressionTrue
+	}
+	return expressionFalse
+}
+
+type expressionToken struct {
+	value  string
+	quoted bool
+}
+
+func tokenizeExpression(expression string) ([]expressionToken, error) {
+	tokens := []expressionToken{}
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == ',':
+			tokens = append(tokens, expressionToken{value: string(r)})
+			i++
+		case r == '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(runes) {
+				return nil, errors.New("unterminated string")
+			}
+			value, err := strconv.Unquote(string(runes[i : j+1]))
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid string %s", string(runes[i:j+1])))
+			}
+			tokens = append(tokens, expressionToken{value: value, quoted: true})
+			i = j + 1
+		case strings.ContainsRune("=!~&|", r):
+			if i+1 < len(runes) {
+				operator := string(runes[i : i+2])
+				switch operator {
+				case "==", "!=", "=~", "!~", "&&", "||":
+					tokens = append(tokens, expressionToken{value: operator})
+					i += 2
+					continue
+				}
+			}
+			if r != '!' {
+				return nil, errors.New(fmt.Sprintf("unexpected `%c`", r))
+			}
+			tokens = append(tokens, expressionToken{value: "!"})
+			i++
+		default:
+			j := i
+			for ; j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("(),\"=!~&|", runes[j]); j++ {
+			}
+			tokens = append(tokens, expressionToken{value: string(runes[i:j])})
+			i = j
+		}
+	}
+
+	return tokens, nil
+}
+
+type expressionParser struct {
+	tokens []expressionToken
+	pos    int
+}
+
+func (p *expressionParser) peek() (expressionToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return expressionToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *expressionParser) accept(value string) bool {
+	token, ok := p.peek()
+	if ok && !token.quoted && token.value == value {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *expressionParser) next(expected string) (expressionToken, error) {
+	token, ok := p.peek()
+	if !ok {
+		return token, errors.New(fmt.Sprintf("expected %s, got end of expression", expected))
+	}
+	p.pos++
+	return token, nil
+}
+
+func (p *expressionParser) nextValue() (expressionToken, error) {
+	value, err := p.next("a value")
+	if err != nil {
+		return value, err
+	}
+	if !value.quoted && expressionOperators[value.value] {
+		return value, errors.New(fmt.Sprintf("expected a value, got `%s`", value.value))
+	}
+	return value, nil
+}
+
+func (p *expressionParser) parseOr() (expressionNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *expressionParser) parseAnd() (expressionNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *expressionParser) parseUnary() (expressionNode, error) {
+	if p.accept("!") {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node: node}, nil
+	}
+
+	if p.accept("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, errors.New("expected `)`")
+		}
+		return node, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *expressionParser) parseComparison() (expressionNode, error) {
+	field, err := p.next("a field")
+	if err != nil {
+		return nil, err
+	}
+	if field.quoted || !expressionFields[field.value] {
+		return nil, errors.New(fmt.Sprintf("unknown field `%s`, must be one of deployment, job, az, process or ip", field.value))
+	}
+
+	if p.accept("not") {
+		if !p.accept("in") {
+			return nil, errors.New("expected `in` after `not`")
+		}
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node: matchNode{field: field.value, match: inList(values)}}, nil
+	}
+
+	if p.accept("in") {
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return matchNode{field: field.value, match: inList(values)}, nil
+	}
+
+	operator, err := p.next("an operator")
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.nextValue()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case operator.quoted:
+	case operator.value == "==":
+		return matchNode{field: field.value, match: func(v string) bool { return v == value.value }}, nil
+	case operator.value == "!=":
+		return notNode{node: matchNode{field: field.value, match: func(v string) bool { return v == value.value }}}, nil
+	case operator.value == "=~" || operator.value == "!~":
+		re, err := regexp.Compile("^(?:" + value.value + ")$")
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid regexp `%s`: %v", value.value, err))
+		}
+		node := matchNode{field: field.value, match: re.MatchString}
+		if operator.value == "!~" {
+			return notNode{node: node}, nil
+		}
+		return node, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("unknown operator `%s`, must be one of ==, !=, =~, !~, in or not in", operator.value))
+}
+
+func (p *expressionParser) parseList() ([]string, error) {
+	if !p.accept("(") {
+		return nil, errors.New("expected `(` after `in`")
+	}
+
+	values := []string{}
+	for {
+		value, err := p.nextValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value.value)
+
+		if p.accept(")") {
+			return values, nil
+		}
+		if !p.accept(",") {
+			return nil, errors.New("expected `,` or `)` in list")
+		}
+	}
+}
+
+func inList(values []string) func(string) bool {
+	return func(v string) bool {
+		for _, value := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,141 @@
+package filters_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/filters"
+)
+
+var _ = Describe("ExpressionFilter", func() {
+	var (
+		err        error
+		expression string
+
+		expressionFilter *ExpressionFilter
+	)
+
+	JustBeforeEach(func() {
+		expressionFilter, err = NewExpressionFilter(expression)
+	})
+
+	Describe("New", func() {
+		Context("when the expression is well formatted", func() {
+			BeforeEach(func() {
+				expression = `deployment =~ "cf-.*" && az != "z3" && process not in (metron_agent, consul_agent)`
+			})
+
+			It("does not return an error", func() {
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the expression uses an unknown field", func() {
+			BeforeEach(func() {
+				expression = `stemcell == "xenial"`
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("unknown field `stemcell`"))
+			})
+		})
+
+		Context("when the expression uses an unknown operator", func() {
+			BeforeEach(func() {
+				expression = `deployment like "cf"`
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("unknown operator `like`"))
+			})
+		})
+
+		Context("when the expression is incomplete", func() {
+			BeforeEach(func() {
+				expression = `(deployment == cf`
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("expected `)`"))
+			})
+		})
+
+		Context("when a regexp does not compile", func() {
+			BeforeEach(func() {
+				expression = `process =~ "[z-a]"`
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Enabled", func() {
+		BeforeEach(func() {
+			expression = `deployment =~ "cf-.*" && az != "z3" && process not in (metron_agent)`
+		})
+
+		It("enables values matching the expression", func() {
+			Expect(expressionFilter.Enabled(map[string]string{"deployment": "cf-prod", "az": "z1", "process": "gorouter"})).To(BeTrue())
+		})
+
+		It("disables values not matching the expression", func() {
+			Expect(expressionFilter.Enabled(map[string]string{"deployment": "cf-prod", "az": "z3", "process": "gorouter"})).To(BeFalse())
+			Expect(expressionFilter.Enabled(map[string]string{"deployment": "cf-prod", "az": "z1", "process": "metron_agent"})).To(BeFalse())
+		})
+
+		It("matches full regexps", func() {
+			Expect(expressionFilter.Enabled(map[string]string{"deployment": "my-cf-prod"})).To(BeFalse())
+		})
+
+		It("does not reject on unknown fields", func() {
+			Expect(expressionFilter.Enabled(map[string]string{"deployment": "cf-prod"})).To(BeTrue())
+			Expect(expressionFilter.Enabled(map[string]string{"deployment": "redis"})).To(BeFalse())
+		})
+
+		Context("when the expression has alternatives", func() {
+			BeforeEach(func() {
+				expression = `!(deployment == redis) || (job in ("redis", sentinel) && ip !~ "10\\.0\\..*")`
+			})
+
+			It("applies the operators precedence", func() {
+				Expect(expressionFilter.Enabled(map[string]string{"deployment": "cf"})).To(BeTrue())
+				Expect(expressionFilter.Enabled(map[string]string{"deployment": "redis", "job": "sentinel", "ip": "10.1.0.1"})).To(BeTrue())
+				Expect(expressionFilter.Enabled(map[string]string{"deployment": "redis", "job": "sentinel", "ip": "10.0.0.1"})).To(BeFalse())
+				Expect(expressionFilter.Enabled(map[string]string{"deployment": "redis", "job": "broker"})).To(BeFalse())
+			})
+		})
+
+		Context("when there is no expression", func() {
+			BeforeEach(func() {
+				expression = ""
+			})
+
+			It("enables everything", func() {
+				Expect(expressionFilter.Enabled(map[string]string{"deployment": "redis"})).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Explain", func() {
+		BeforeEach(func() {
+			expression = `deployment == cf`
+		})
+
+		It("explains why values are rejected", func() {
+			result := expressionFilter.Explain(map[string]string{"deployment": "redis"})
+			Expect(result.Accepted).To(BeFalse())
+			Expect(result.Value).To(Equal("deployment=redis"))
+			Expect(result.Reason).To(ContainSubstring("does not match the filter expression"))
+		})
+
+		It("explains why values are accepted", func() {
+			result := expressionFilter.Explain(map[string]string{"deployment": "cf"})
+			Expect(result.Accepted).To(BeTrue())
+		})
+	})
+})
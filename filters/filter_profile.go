@@ -0,0 +1,121 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	MinimalProfile  = "minimal"
+	StandardProfile = "standard"
+	FullProfile     = "full"
+)
+
+// FilterPreset groups processes and collectors excluded together.
+type FilterPreset struct {
+	Name               string
+	ExcludedProcesses  []string
+	ExcludedCollectors []string
+}
+
+var (
+	BoshDNSPreset = FilterPreset{
+		Name:              "bosh-dns",
+		ExcludedProcesses: []string{"bosh-dns", "bosh-dns-resolvconf", "bosh-dns-healthcheck", "bosh-dns-windows"},
+	}
+
+	SystemAgentsPreset = FilterPreset{
+		Name: "system-agents",
+		ExcludedProcesses: []string{
+			"system-metrics-agent",
+			"metrics-agent",
+			"metrics-discovery-registrar",
+			"metron_agent",
+			"loggr-forwarder-agent",
+			"loggr-syslog-agent",
+			"loggr-udp-forwarder",
+			"prom_scraper",
+			"syslog_forwarder",
+			"node_exporter",
+		},
+	}
+
+	TasksPreset = FilterPreset{
+		Name:               "tasks",
+		ExcludedCollectors: []string{TasksCollector},
+	}
+)
+
+type FilterProfile struct {
+	Name    string
+	Presets []FilterPreset
+}
+
+var filterProfiles = map[string]FilterProfile{
+	MinimalProfile:  {Name: MinimalProfile, Presets: []FilterPreset{BoshDNSPreset, SystemAgentsPreset, TasksPreset}},
+	StandardProfile: {Name: StandardProfile, Presets: []FilterPreset{BoshDNSPreset}},
+	FullProfile:     {Name: FullProfile},
+}
+
+func NewFilterProfile(name string) (FilterProfile, error) {
+	if name == "" {
+		name = FullProfile
+	}
+
+	profile, ok := filterProfiles[name]
+	if !ok {
+		return FilterProfile{}, errors.New(fmt.Sprintf("Filter profile `%s` is not supported, must be one of %s, %s or %s", name, MinimalProfile, StandardProfile, FullProfile))
+	}
+
+	return profile, nil
+}
+
+// Expression combines the processes excluded by the profile with a filter
+// expression.
+func (p FilterProfile) Expression(expression string) string {
+	excludedProcesses := []string{}
+	for _, preset := range p.Presets {
+		for _, process := range preset.ExcludedProcesses {
+			excludedProcesses = append(excludedProcesses, strconv.Quote(process))
+		}
+	}
+	if len(excludedProcesses) == 0 {
+		return expression
+	}
+
+	profileExpression := fmt.Sprintf("process not in (%s)", strings.Join(excludedProcesses, ", "))
+	if strings.TrimSpace(expression) == "" {
+		return profileExpression
+	}
+
+	return fmt.Sprintf("%s && (%s)", profileExpression, expression)
+}
+
+// Collectors returns the collectors not excluded by the profile, unless a
+// collectors filter is explicitly set.
+func (p FilterProfile) Collectors(collectors []string) []string {
+	if len(collectors) > 0 {
+		return collectors
+	}
+
+	excludedCollectors := map[string]bool{}
+	for _, preset := range p.Presets {
+		for _, collector := range preset.ExcludedCollectors {
+			excludedCollectors[collector] = true
+		}
+	}
+	if len(excludedCollectors) == 0 {
+		return collectors
+	}
+
+	enabledCollectors := []string{}
+	for _, collector := range []string{DeploymentsCollector, JobsCollector, ServiceDiscoveryCollector, TasksCollector} {
+		if !excludedCollectors[collector] {
+			enabledCollectors = append(enabledCollectors, collector)
+		}
+	}
+
+	return enabledCollectors
+}
@@ -0,0 +1,117 @@
+package filters_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/filters"
+)
+
+var _ = Describe("FilterProfile", func() {
+	var (
+		err     error
+		name    string
+		profile FilterProfile
+	)
+
+	JustBeforeEach(func() {
+		profile, err = NewFilterProfile(name)
+	})
+
+	Describe("New", func() {
+		Context("when the profile is not set", func() {
+			BeforeEach(func() {
+				name = ""
+			})
+
+			It("returns the full profile", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(profile.Name).To(Equal(FullProfile))
+			})
+		})
+
+		Context("when the profile is not supported", func() {
+			BeforeEach(func() {
+				name = "tiny"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Filter profile `tiny` is not supported"))
+			})
+		})
+	})
+
+	Describe("Expression", func() {
+		Context("when the profile is minimal", func() {
+			BeforeEach(func() {
+				name = MinimalProfile
+			})
+
+			It("excludes the BOSH DNS and system agents processes", func() {
+				expressionFilter, err := NewExpressionFilter(profile.Expression(""))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(expressionFilter.Enabled(map[string]string{"process": "bosh-dns"})).To(BeFalse())
+				Expect(expressionFilter.Enabled(map[string]string{"process": "system-metrics-agent"})).To(BeFalse())
+				Expect(expressionFilter.Enabled(map[string]string{"process": "gorouter"})).To(BeTrue())
+			})
+
+			It("combines the exclusions with the filter expression", func() {
+				expressionFilter, err := NewExpressionFilter(profile.Expression(`deployment == cf || deployment == redis`))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(expressionFilter.Enabled(map[string]string{"deployment": "redis", "process": "redis"})).To(BeTrue())
+				Expect(expressionFilter.Enabled(map[string]string{"deployment": "redis", "process": "bosh-dns"})).To(BeFalse())
+				Expect(expressionFilter.Enabled(map[string]string{"deployment": "mysql", "process": "mysql"})).To(BeFalse())
+			})
+		})
+
+		Context("when the profile is standard", func() {
+			BeforeEach(func() {
+				name = StandardProfile
+			})
+
+			It("only excludes the BOSH DNS processes", func() {
+				expressionFilter, err := NewExpressionFilter(profile.Expression(""))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(expressionFilter.Enabled(map[string]string{"process": "bosh-dns-healthcheck"})).To(BeFalse())
+				Expect(expressionFilter.Enabled(map[string]string{"process": "system-metrics-agent"})).To(BeTrue())
+			})
+		})
+
+		Context("when the profile is full", func() {
+			BeforeEach(func() {
+				name = FullProfile
+			})
+
+			It("returns the filter expression", func() {
+				Expect(profile.Expression(`deployment == cf`)).To(Equal(`deployment == cf`))
+			})
+		})
+	})
+
+	Describe("Collectors", func() {
+		Context("when the profile is minimal", func() {
+			BeforeEach(func() {
+				name = MinimalProfile
+			})
+
+			It("excludes the Tasks collector", func() {
+				Expect(profile.Collectors(nil)).To(Equal([]string{DeploymentsCollector, JobsCollector, ServiceDiscoveryCollector}))
+			})
+
+			It("keeps an explicit collectors filter", func() {
+				Expect(profile.Collectors([]string{TasksCollector})).To(Equal([]string{TasksCollector}))
+			})
+		})
+
+		Context("when the profile is full", func() {
+			BeforeEach(func() {
+				name = FullProfile
+			})
+
+			It("does not filter collectors", func() {
+				Expect(profile.Collectors(nil)).To(BeEmpty())
+			})
+		})
+	})
+})
@@ -0,0 +1,8 @@
+package filters
+
+type FilterResult struct {
+	Filter   string `json:"filter"`
+	Value    string `json:"value"`
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason"`
+}
@@ -1,6 +1,7 @@
 package filters
 
 import (
+	"fmt"
 	"regexp"
 )
 
@@ -36,3 +37,24 @@ func (f *RegexpFilter) Enabled(expr string) bool {
 
 	return false
 }
+
+func (f *RegexpFilter) Explain(name string, expr string) FilterResult {
+	result := FilterResult{Filter: name, Value: expr}
+
+	if len(f.reFilters) == 0 {
+		result.Accepted = true
+		result.Reason = "No regexp filter configured"
+		return result
+	}
+
+	for _, re := range f.reFilters {
+		if re.MatchString(expr) {
+			result.Accepted = true
+			result.Reason = fmt.Sprintf("`%s` matches regexp `%s`", expr, re.String())
+			return result
+		}
+	}
+
+	result.Reason = fmt.Sprintf("`%s` does not match any regexp `%v`", expr, f.reFilters)
+	return result
+}
@@ -69,4 +69,38 @@ var _ = Describe("RegexpFilter", func() {
 			})
 		})
 	})
+
+	Describe("Explain", func() {
+		BeforeEach(func() {
+			filters = []string{"^bosh_"}
+		})
+
+		It("accepts a matching value", func() {
+			Expect(regexpFilter.Explain("processes", "bosh_exporter")).To(Equal(FilterResult{
+				Filter:   "processes",
+				Value:    "bosh_exporter",
+				Accepted: true,
+				Reason:   "`bosh_exporter` matches regexp `^bosh_`",
+			}))
+		})
+
+		It("rejects a value not matching", func() {
+			Expect(regexpFilter.Explain("processes", "deployments_exporter")).To(Equal(FilterResult{
+				Filter:   "processes",
+				Value:    "deployments_exporter",
+				Accepted: false,
+				Reason:   "`deployments_exporter` does not match any regexp `[^bosh_]`",
+			}))
+		})
+
+		Context("when there are no filters", func() {
+			BeforeEach(func() {
+				filters = []string{}
+			})
+
+			It("accepts any value", func() {
+				Expect(regexpFilter.Explain("processes", "deployments_exporter").Accepted).To(BeTrue())
+			})
+		})
+	})
 })
@@ -0,0 +1,168 @@
+package instrumentation
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTPInstrumenter measures the requests served by the exporter HTTP server
+// per handler and, when an access log is set, writes one line per request in
+// the Apache combined log format followed by the duration in seconds.
+type HTTPInstrumenter struct {
+	requestDuration *prometheus.HistogramVec
+	requestSize     *prometheus.HistogramVec
+	responseSize    *prometheus.HistogramVec
+
+	accessLogMu sync.Mutex
+	accessLog   io.Writer
+}
+
+func NewHTTPInstrumenter(namespace string, accessLog io.Writer) *HTTPInstrumenter {
+	requestDuration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration in seconds of the HTTP requests served by the exporter.",
+			Buckets:   []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 120},
+		},
+		[]string{"handler", "method", "code"},
+	)
+
+	requestSize := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "http_request_size_bytes",
+			Help:      "Size in bytes of the HTTP requests served by the exporter.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+		},
+		[]string{"handler"},
+	)
+
+	responseSize := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "http_response_size_bytes",
+			Help:      "Size in bytes of the HTTP responses sent by the exporter.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 10),
+		},
+		[]string{"handler"},
+	)
+
+	return &HTTPInstrumenter{
+		requestDuration: requestDuration,
+		requestSize:     requestSize,
+		responseSize:    responseSize,
+		accessLog:       accessLog,
+	}
+}
+
+// Handler instruments a handler, labelling its metrics with the given name.
+func (i *HTTPInstrumenter) Handler(name string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		begun := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		handler.ServeHTTP(recorder, r)
+
+		duration := time.Since(begun)
+		i.requestDuration.WithLabelValues(name, strings.ToLower(r.Method), strconv.Itoa(recorder.status)).Observe(duration.Seconds())
+		i.requestSize.WithLabelValues(name).Observe(float64(requestSize(r)))
+		i.responseSize.WithLabelValues(name).Observe(float64(recorder.size))
+
+		if i.accessLog != nil {
+			i.logRequest(r, recorder, begun, duration)
+		}
+	})
+}
+
+func (i *HTTPInstrumenter) logRequest(r *http.Request, recorder *responseRecorder, begun time.Time, duration time.Duration) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user := "-"
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		user = username
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] %q %d %d %q %q %.3f\n",
+		host,
+		user,
+		begun.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+r.URL.RequestURI()+" "+r.Proto,
+		recorder.status,
+		recorder.size,
+		r.Referer(),
+		r.UserAgent(),
+		duration.Seconds(),
+	)
+
+	i.accessLogMu.Lock()
+	defer i.accessLogMu.Unlock()
+	io.WriteString(i.accessLog, line)
+}
+
+func (i *HTTPInstrumenter) Describe(ch chan<- *prometheus.Desc) {
+	i.requestDuration.Describe(ch)
+	i.requestSize.Describe(ch)
+	i.responseSize.Describe(ch)
+}
+
+func (i *HTTPInstrumenter) Collect(ch chan<- prometheus.Metric) {
+	i.requestDuration.Collect(ch)
+	i.requestSize.Collect(ch)
+	i.responseSize.Collect(ch)
+}
+
+// requestSize approximates the size of a request as promhttp does: request
+// line, headers and body.
+func requestSize(r *http.Request) int {
+	size := len(r.Method) + len(r.Proto) + len(r.Host)
+	if r.URL != nil {
+		size += len(r.URL.String())
+	}
+	for name, values := range r.Header {
+		size += len(name)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	if r.ContentLength > 0 {
+		size += int(r.ContentLength)
+	}
+
+	return size
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(p)
+	r.size += n
+	return n, err
+}
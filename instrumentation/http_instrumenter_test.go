@@ -0,0 +1,97 @@
+package instrumentation_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/bosh-prometheus/bosh_exporter/instrumentation"
+)
+
+var _ = Describe("HTTPInstrumenter", func() {
+	var (
+		accessLog    *bytes.Buffer
+		instrumenter *HTTPInstrumenter
+		recorder     *httptest.ResponseRecorder
+		request      *http.Request
+	)
+
+	gather := func() map[string]*dto.MetricFamily {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(instrumenter)
+		metricFamilies, err := registry.Gather()
+		Expect(err).ToNot(HaveOccurred())
+
+		byName := map[string]*dto.MetricFamily{}
+		for _, metricFamily := range metricFamilies {
+			byName[metricFamily.GetName()] = metricFamily
+		}
+		return byName
+	}
+
+	BeforeEach(func() {
+		accessLog = &bytes.Buffer{}
+		instrumenter = NewHTTPInstrumenter("test_exporter", accessLog)
+		request = httptest.NewRequest(http.MethodGet, "/metrics?debug=1", nil)
+		request.RemoteAddr = "10.0.0.1:34567"
+		request.Header.Set("User-Agent", "Prometheus/2.0")
+		request.SetBasicAuth("prometheus", "secret")
+	})
+
+	JustBeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		instrumenter.Handler("/metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("short and stout"))
+		})).ServeHTTP(recorder, request)
+	})
+
+	It("serves the wrapped handler", func() {
+		Expect(recorder.Code).To(Equal(http.StatusTeapot))
+		Expect(recorder.Body.String()).To(Equal("short and stout"))
+	})
+
+	It("returns an exporter_http_request_duration_seconds metric by handler, method and code", func() {
+		metricFamily := gather()["test_exporter_exporter_http_request_duration_seconds"]
+		Expect(metricFamily).ToNot(BeNil())
+		Expect(metricFamily.GetMetric()).To(HaveLen(1))
+		labels := map[string]string{}
+		for _, label := range metricFamily.GetMetric()[0].GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		Expect(labels).To(Equal(map[string]string{"handler": "/metrics", "method": "get", "code": "418"}))
+		Expect(metricFamily.GetMetric()[0].GetHistogram().GetSampleCount()).To(Equal(uint64(1)))
+	})
+
+	It("returns an exporter_http_response_size_bytes metric", func() {
+		metricFamily := gather()["test_exporter_exporter_http_response_size_bytes"]
+		Expect(metricFamily).ToNot(BeNil())
+		Expect(metricFamily.GetMetric()[0].GetHistogram().GetSampleSum()).To(Equal(float64(len("short and stout"))))
+	})
+
+	It("returns an exporter_http_request_size_bytes metric", func() {
+		metricFamily := gather()["test_exporter_exporter_http_request_size_bytes"]
+		Expect(metricFamily).ToNot(BeNil())
+		Expect(metricFamily.GetMetric()[0].GetHistogram().GetSampleSum()).To(BeNumerically(">", 0))
+	})
+
+	It("writes an access log line", func() {
+		Expect(accessLog.String()).To(MatchRegexp(`^10\.0\.0\.1 - prometheus \[[^\]]+\] "GET /metrics\?debug=1 HTTP/1\.1" 418 15 "" "Prometheus/2\.0" [0-9]+\.[0-9]{3}\n$`))
+	})
+
+	Context("when there is no access log", func() {
+		BeforeEach(func() {
+			instrumenter = NewHTTPInstrumenter("test_exporter", nil)
+		})
+
+		It("still returns the metrics", func() {
+			Expect(gather()).To(HaveKey("test_exporter_exporter_http_request_duration_seconds"))
+		})
+	})
+})
@@ -0,0 +1,193 @@
+package instrumentation
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// KubernetesClientInstrumenter measures the requests sent to the Kubernetes
+// API and, when a QPS is set, throttles them client side, measuring how long
+// they waited, so slow Service Discovery updates can be traced to client or
+// API server (HTTP 429) throttling.
+type KubernetesClientInstrumenter struct {
+	limiter            *rateLimiter
+	requestsTotal      *prometheus.CounterVec
+	requestDuration    *prometheus.HistogramVec
+	throttleWaitMetric prometheus.Histogram
+}
+
+// NewKubernetesClientInstrumenter returns an instrumenter throttling the
+// requests of all the clients it wraps to qps requests per second with
+// bursts of burst requests, or not throttling them when qps is 0.
+func NewKubernetesClientInstrumenter(namespace string, qps float64, burst int) *KubernetesClientInstrumenter {
+	requestsTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "kubernetes_requests_total",
+			Help:      "Total number of requests sent to the Kubernetes API, by method (watch for watches) and status code (error when no response was received).",
+		},
+		[]string{"method", "code"},
+	)
+
+	requestDuration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "kubernetes_request_duration_seconds",
+			Help:      "Duration in seconds of the requests sent to the Kubernetes API until their response headers, watches excluded.",
+			Buckets:   []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30},
+		},
+		[]string{"method"},
+	)
+
+	throttleWaitMetric := prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "kubernetes_throttle_wait_seconds",
+			Help:      "Duration in seconds the requests to the Kubernetes API waited for the client-side rate limiter.",
+			Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30},
+		},
+	)
+
+	var limiter *rateLimiter
+	if qps > 0 {
+		limiter = newRateLimiter(qps, burst)
+	}
+
+	return &KubernetesClientInstrumenter{
+		limiter:            limiter,
+		requestsTotal:      requestsTotal,
+		requestDuration:    requestDuration,
+		throttleWaitMetric: throttleWaitMetric,
+	}
+}
+
+// Wrap returns a transport throttling and measuring the requests sent through
+// transport (http.DefaultTransport when nil).
+func (i *KubernetesClientInstrumenter) Wrap(transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &kubernetesTransport{instrumenter: i, next: transport}
+}
+
+func (i *KubernetesClientInstrumenter) Describe(ch chan<- *prometheus.Desc) {
+	i.requestsTotal.Describe(ch)
+	i.requestDuration.Describe(ch)
+	if i.limiter != nil {
+		i.throttleWaitMetric.Describe(ch)
+	}
+}
+
+func (i *KubernetesClientInstrumenter) Collect(ch chan<- prometheus.Metric) {
+	i.requestsTotal.Collect(ch)
+	i.requestDuration.Collect(ch)
+	if i.limiter != nil {
+		i.throttleWaitMetric.Collect(ch)
+	}
+}
+
+type kubernetesTransport struct {
+	instrumenter *KubernetesClientInstrumenter
+	next         http.RoundTripper
+}
+
+func (t *kubernetesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.instrumenter.limiter != nil {
+		waited, err := t.instrumenter.limiter.wait(req)
+		t.instrumenter.throttleWaitMetric.Observe(waited.Seconds())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	method := strings.ToLower(req.Method)
+	if req.URL.Query().Get("watch") == "true" {
+		method = "watch"
+	}
+
+	begun := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	t.instrumenter.requestsTotal.WithLabelValues(method, code).Inc()
+	if method != "watch" {
+		t.instrumenter.requestDuration.WithLabelValues(method).Observe(time.Since(begun).Seconds())
+	}
+
+	return resp, err
+}
+
+// rateLimiter is a token bucket refilled at qps tokens per second, holding
+// at most burst tokens.
+type rateLimiter struct {
+	qps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     *sync.Mutex
+}
+
+func newRateLimiter(qps float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		qps:    qps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		mu:     &sync.Mutex{},
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.qps
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.qps * float64(time.Second))
+}
+
+// wait blocks until the request may be sent or its context is done, and
+// returns how long it waited.
+func (l *rateLimiter) wait(req *http.Request) (time.Duration, error) {
+	delay := l.reserve()
+	if delay <= 0 {
+		return 0, nil
+	}
+
+	begun := time.Now()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return time.Since(begun), nil
+	case <-req.Context().Done():
+		return time.Since(begun), req.Context().Err()
+	}
+}
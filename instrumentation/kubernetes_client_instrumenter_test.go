@@ -0,0 +1,128 @@
+package instrumentation_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/bosh-prometheus/bosh_exporter/instrumentation"
+)
+
+var _ = Describe("KubernetesClientInstrumenter", func() {
+	var (
+		server       *httptest.Server
+		qps          float64
+		burst        int
+		instrumenter *KubernetesClientInstrumenter
+		client       *http.Client
+	)
+
+	gather := func() map[string]*dto.MetricFamily {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(instrumenter)
+		metricFamilies, err := registry.Gather()
+		Expect(err).ToNot(HaveOccurred())
+
+		byName := map[string]*dto.MetricFamily{}
+		for _, metricFamily := range metricFamilies {
+			byName[metricFamily.GetName()] = metricFamily
+		}
+		return byName
+	}
+
+	get := func(path string) {
+		resp, err := client.Get(server.URL + path)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+	}
+
+	BeforeEach(func() {
+		qps = 0
+		burst = 1
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPatch {
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+		}))
+	})
+
+	JustBeforeEach(func() {
+		instrumenter = NewKubernetesClientInstrumenter("test_exporter", qps, burst)
+		client = &http.Client{Transport: instrumenter.Wrap(nil)}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("returns an exporter_kubernetes_requests_total metric by method and code", func() {
+		get("/api/v1/namespaces/default/configmaps/bosh")
+		req, err := http.NewRequest(http.MethodPatch, server.URL+"/api/v1/namespaces/default/configmaps/bosh", nil)
+		Expect(err).ToNot(HaveOccurred())
+		resp, err := client.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+
+		metrics := gather()["test_exporter_exporter_kubernetes_requests_total"].GetMetric()
+		Expect(metrics).To(HaveLen(2))
+		Expect(metrics[0].GetLabel()[0].GetValue()).To(Equal("200"))
+		Expect(metrics[0].GetLabel()[1].GetValue()).To(Equal("get"))
+		Expect(metrics[1].GetLabel()[0].GetValue()).To(Equal("429"))
+		Expect(metrics[1].GetLabel()[1].GetValue()).To(Equal("patch"))
+	})
+
+	It("returns an exporter_kubernetes_request_duration_seconds metric excluding watches", func() {
+		get("/api/v1/namespaces/default/configmaps/bosh")
+		get("/api/v1/namespaces/default/configmaps?watch=true")
+
+		metricFamilies := gather()
+		Expect(metricFamilies["test_exporter_exporter_kubernetes_requests_total"].GetMetric()).To(HaveLen(2))
+		durations := metricFamilies["test_exporter_exporter_kubernetes_request_duration_seconds"].GetMetric()
+		Expect(durations).To(HaveLen(1))
+		Expect(durations[0].GetHistogram().GetSampleCount()).To(Equal(uint64(1)))
+	})
+
+	It("does not return an exporter_kubernetes_throttle_wait_seconds metric", func() {
+		get("/")
+		Expect(gather()).ToNot(HaveKey("test_exporter_exporter_kubernetes_throttle_wait_seconds"))
+	})
+
+	Context("when a QPS is set", func() {
+		BeforeEach(func() {
+			qps = 20
+			burst = 1
+		})
+
+		It("throttles the requests beyond the burst", func() {
+			begun := time.Now()
+			get("/")
+			get("/")
+			get("/")
+			Expect(time.Since(begun)).To(BeNumerically(">=", 90*time.Millisecond))
+
+			histogram := gather()["test_exporter_exporter_kubernetes_throttle_wait_seconds"].GetMetric()[0].GetHistogram()
+			Expect(histogram.GetSampleCount()).To(Equal(uint64(3)))
+			Expect(histogram.GetSampleSum()).To(BeNumerically(">=", 0.09))
+		})
+
+		It("stops waiting when the request is cancelled", func() {
+			get("/")
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.Do(req.WithContext(ctx))
+			Expect(err).To(HaveOccurred())
+			requests := gather()["test_exporter_exporter_kubernetes_requests_total"].GetMetric()
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].GetCounter().GetValue()).To(Equal(float64(1)))
+		})
+	})
+})
@@ -0,0 +1,138 @@
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/bosh-prometheus/bosh_exporter/filters"
+)
+
+var profileNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+type Config struct {
+	Profiles []ProfileConfig `yaml:"profiles"`
+}
+
+// ProfileConfig selects the series of a scrape profile. Empty filters select
+// every value, and series without the filtered label are always selected.
+type ProfileConfig struct {
+	Name            string   `yaml:"name"`
+	Deployments     []string `yaml:"deployments"`
+	AZs             []string `yaml:"azs"`
+	ProcessesRegexp string   `yaml:"processes_regexp"`
+}
+
+// Profile is a filtered view of the BOSH metrics, served on its own path.
+type Profile struct {
+	Name              string
+	deploymentsFilter *filters.DeploymentsFilter
+	azsFilter         *filters.AZsFilter
+	processesFilter   *filters.RegexpFilter
+}
+
+func LoadConfig(filename string) (*Config, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error reading scrape profiles config `%s`: %v", filename, err))
+	}
+
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing scrape profiles config `%s`: %v", filename, err))
+	}
+
+	return config, nil
+}
+
+// NewProfiles validates the profiles of a config, whose names must be unique
+// and usable in a URL path.
+func NewProfiles(config Config) ([]*Profile, error) {
+	profiles := []*Profile{}
+	names := map[string]bool{}
+
+	for _, profileConfig := range config.Profiles {
+		if !profileNameRegexp.MatchString(profileConfig.Name) {
+			return nil, errors.New(fmt.Sprintf("Invalid scrape profile name `%s`, must only contain letters, digits, `_` and `-`", profileConfig.Name))
+		}
+		if names[profileConfig.Name] {
+			return nil, errors.New(fmt.Sprintf("Duplicate scrape profile `%s`", profileConfig.Name))
+		}
+		names[profileConfig.Name] = true
+
+		processesRegexp := []string{}
+		if profileConfig.ProcessesRegexp != "" {
+			processesRegexp = append(processesRegexp, profileConfig.ProcessesRegexp)
+		}
+		processesFilter, err := filters.NewRegexpFilter(processesRegexp)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Error compiling the processes regexp of scrape profile `%s`: %v", profileConfig.Name, err))
+		}
+
+		profiles = append(profiles, &Profile{
+			Name:              profileConfig.Name,
+			deploymentsFilter: filters.NewDeploymentsFilter(profileConfig.Deployments, nil),
+			azsFilter:         filters.NewAZsFilter(profileConfig.AZs),
+			processesFilter:   processesFilter,
+		})
+	}
+
+	return profiles, nil
+}
+
+// Selected returns whether a series with these labels belongs to the profile.
+func (p *Profile) Selected(labels []*dto.LabelPair) bool {
+	for _, label := range labels {
+		switch label.GetName() {
+		case "bosh_deployment":
+			if !p.deploymentsFilter.Explain(label.GetValue()).Accepted {
+				return false
+			}
+		case "bosh_job_az":
+			if !p.azsFilter.Enabled(label.GetValue()) {
+				return false
+			}
+		case "bosh_job_process_name":
+			if !p.processesFilter.Enabled(label.GetValue()) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Gatherer wraps a gatherer so only the series of the profile are gathered.
+// Families left without series are dropped.
+func (p *Profile) Gatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		metricFamilies, err := gatherer.Gather()
+
+		selectedFamilies := make([]*dto.MetricFamily, 0, len(metricFamilies))
+		for _, metricFamily := range metricFamilies {
+			selectedMetrics := make([]*dto.Metric, 0, len(metricFamily.GetMetric()))
+			for _, metric := range metricFamily.GetMetric() {
+				if p.Selected(metric.GetLabel()) {
+					selectedMetrics = append(selectedMetrics, metric)
+				}
+			}
+			if len(selectedMetrics) == 0 {
+				continue
+			}
+
+			selectedFamilies = append(selectedFamilies, &dto.MetricFamily{
+				Name:   metricFamily.Name,
+				Help:   metricFamily.Help,
+				Type:   metricFamily.Type,
+				Metric: selectedMetrics,
+			})
+		}
+
+		return selectedFamilies, err
+	})
+}
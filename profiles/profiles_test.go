@@ -0,0 +1,160 @@
+package profiles_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/profiles"
+)
+
+var _ = Describe("Profiles", func() {
+	var (
+		err      error
+		config   Config
+		profiles []*Profile
+		registry *prometheus.Registry
+	)
+
+	BeforeEach(func() {
+		config = Config{
+			Profiles: []ProfileConfig{
+				{Name: "tenant-a", Deployments: []string{"cf"}, AZs: []string{"z1"}, ProcessesRegexp: "^gorouter$"},
+				{Name: "everything"},
+			},
+		}
+
+		registry = prometheus.NewRegistry()
+
+		processHealthy := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{Name: "test_job_process_healthy", Help: "Test process healthy."},
+			[]string{"bosh_deployment", "bosh_job_az", "bosh_job_process_name"},
+		)
+		processHealthy.WithLabelValues("cf", "z1", "gorouter").Set(1)
+		processHealthy.WithLabelValues("cf", "z1", "route_registrar").Set(1)
+		processHealthy.WithLabelValues("cf", "z2", "gorouter").Set(1)
+		processHealthy.WithLabelValues("redis", "z1", "gorouter").Set(1)
+		registry.MustRegister(processHealthy)
+
+		redisInstances := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{Name: "test_deployment_instances", Help: "Test instances."},
+			[]string{"bosh_deployment"},
+		)
+		redisInstances.WithLabelValues("redis").Set(3)
+		registry.MustRegister(redisInstances)
+
+		scrapes := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_scrapes_total", Help: "Test scrapes."})
+		registry.MustRegister(scrapes)
+	})
+
+	JustBeforeEach(func() {
+		profiles, err = NewProfiles(config)
+	})
+
+	Describe("Gatherer", func() {
+		It("only gathers the series of the profile", func() {
+			Expect(err).ToNot(HaveOccurred())
+
+			metricFamilies, err := profiles[0].Gatherer(registry).Gather()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(2))
+			Expect(metricFamilies[0].GetName()).To(Equal("test_job_process_healthy"))
+			Expect(metricFamilies[0].GetMetric()).To(HaveLen(1))
+			Expect(metricFamilies[0].GetMetric()[0].GetLabel()[0].GetValue()).To(Equal("cf"))
+			Expect(metricFamilies[0].GetMetric()[0].GetLabel()[1].GetValue()).To(Equal("z1"))
+			Expect(metricFamilies[0].GetMetric()[0].GetLabel()[2].GetValue()).To(Equal("gorouter"))
+			Expect(metricFamilies[1].GetName()).To(Equal("test_scrapes_total"))
+		})
+
+		It("gathers every series without filters", func() {
+			metricFamilies, err := profiles[1].Gatherer(registry).Gather()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(3))
+			Expect(metricFamilies[1].GetMetric()).To(HaveLen(4))
+		})
+
+		It("does not change the wrapped gatherer series", func() {
+			profiles[0].Gatherer(registry).Gather()
+
+			metricFamilies, err := registry.Gather()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies[1].GetMetric()).To(HaveLen(4))
+		})
+	})
+
+	Context("when a profile name is invalid", func() {
+		BeforeEach(func() {
+			config.Profiles[0].Name = "tenant/a"
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid scrape profile name `tenant/a`"))
+		})
+	})
+
+	Context("when a profile name is used twice", func() {
+		BeforeEach(func() {
+			config.Profiles[1].Name = "tenant-a"
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Duplicate scrape profile `tenant-a`"))
+		})
+	})
+
+	Context("when a processes regexp is invalid", func() {
+		BeforeEach(func() {
+			config.Profiles[0].ProcessesRegexp = "["
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Error compiling the processes regexp of scrape profile `tenant-a`"))
+		})
+	})
+})
+
+var _ = Describe("LoadConfig", func() {
+	var (
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "profiles")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	It("loads the config", func() {
+		filename := filepath.Join(tmpDir, "profiles.yml")
+		Expect(ioutil.WriteFile(filename, []byte("profiles:\n- name: tenant-a\n  deployments: [cf]\n  azs: [z1]\n  processes_regexp: gorouter\n"), 0600)).To(Succeed())
+
+		config, err := LoadConfig(filename)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*config).To(Equal(Config{
+			Profiles: []ProfileConfig{
+				{Name: "tenant-a", Deployments: []string{"cf"}, AZs: []string{"z1"}, ProcessesRegexp: "gorouter"},
+			},
+		}))
+	})
+
+	It("refuses unknown keys", func() {
+		filename := filepath.Join(tmpDir, "profiles.yml")
+		Expect(ioutil.WriteFile(filename, []byte("profiles:\n- nam: tenant-a\n"), 0600)).To(Succeed())
+
+		_, err := LoadConfig(filename)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Error parsing scrape profiles config"))
+	})
+})
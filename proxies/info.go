@@ -0,0 +1,39 @@
+package proxies
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Info reports the proxy configuration used by each HTTP client of the
+// exporter.
+type Info struct {
+	proxyInfoMetric *prometheus.GaugeVec
+}
+
+func NewInfo(namespace string) *Info {
+	proxyInfoMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "proxy_info",
+			Help:      "Proxy configuration of the exporter HTTP clients, with the passwords masked. Empty proxies mean direct connections.",
+		},
+		[]string{"client", "http_proxy", "https_proxy", "no_proxy"},
+	)
+
+	return &Info{proxyInfoMetric: proxyInfoMetric}
+}
+
+// Set records the proxy configuration of a client.
+func (i *Info) Set(client string, config Config) {
+	config = config.Redacted()
+	i.proxyInfoMetric.WithLabelValues(client, config.HTTPProxy, config.HTTPSProxy, config.NoProxy).Set(float64(1))
+}
+
+func (i *Info) Describe(ch chan<- *prometheus.Desc) {
+	i.proxyInfoMetric.Describe(ch)
+}
+
+func (i *Info) Collect(ch chan<- prometheus.Metric) {
+	i.proxyInfoMetric.Collect(ch)
+}
@@ -0,0 +1,13 @@
+package proxies_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestProxies(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Proxies Suite")
+}
@@ -0,0 +1,233 @@
+package proxies
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Direct is the proxy URL override bypassing the proxy set in the environment.
+const Direct = "direct"
+
+// Config selects the proxy of outgoing HTTP requests like
+// http.ProxyFromEnvironment, but can be overridden per client.
+type Config struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+}
+
+// FromEnvironment reads HTTP_PROXY, HTTPS_PROXY and NO_PROXY, or their
+// lowercase versions.
+func FromEnvironment() Config {
+	return Config{
+		HTTPProxy:  getenv("HTTP_PROXY", "http_proxy"),
+		HTTPSProxy: getenv("HTTPS_PROXY", "https_proxy"),
+		NoProxy:    getenv("NO_PROXY", "no_proxy"),
+	}
+}
+
+func getenv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// WithOverrides replaces the proxy of both schemes by proxyURL (`direct` to
+// disable it) and the NO_PROXY list by noProxy, unless they are empty.
+func (c Config) WithOverrides(proxyURL string, noProxy string) Config {
+	switch proxyURL {
+	case "":
+	case Direct:
+		c.HTTPProxy = ""
+		c.HTTPSProxy = ""
+	default:
+		c.HTTPProxy = proxyURL
+		c.HTTPSProxy = proxyURL
+	}
+	if noProxy != "" {
+		c.NoProxy = noProxy
+	}
+
+	return c
+}
+
+// ProxyFunc returns the proxy function of a http.Transport.
+func (c Config) ProxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	httpProxy, err := parseProxyURL(c.HTTPProxy)
+	if err != nil {
+		return nil, err
+	}
+	httpsProxy, err := parseProxyURL(c.HTTPSProxy)
+	if err != nil {
+		return nil, err
+	}
+	noProxy := parseNoProxy(c.NoProxy)
+
+	return func(req *http.Request) (*url.URL, error) {
+		proxy := httpProxy
+		if req.URL.Scheme == "https" {
+			proxy = httpsProxy
+		}
+		if proxy == nil || !noProxy.useProxy(req.URL) {
+			return nil, nil
+		}
+		return proxy, nil
+	}, nil
+}
+
+// Redacted returns the configuration with the proxy passwords masked.
+func (c Config) Redacted() Config {
+	c.HTTPProxy = redactURL(c.HTTPProxy)
+	c.HTTPSProxy = redactURL(c.HTTPSProxy)
+	return c
+}
+
+func redactURL(rawURL string) string {
+	proxyURL, err := parseProxyURL(rawURL)
+	if err != nil || proxyURL == nil || proxyURL.User == nil {
+		return rawURL
+	}
+	if _, ok := proxyURL.User.Password(); ok {
+		proxyURL.User = url.UserPassword(proxyURL.User.Username(), "xxxxx")
+	}
+
+	return proxyURL.String()
+}
+
+func parseProxyURL(rawURL string) (*url.URL, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil || proxyURL.Host == "" {
+		// Like curl, a proxy without scheme is a HTTP proxy
+		proxyURL, err = url.Parse("http://" + rawURL)
+	}
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing proxy URL `%s`: %v", rawURL, err))
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, errors.New(fmt.Sprintf("Error parsing proxy URL `%s`: unsupported scheme `%s`", rawURL, proxyURL.Scheme))
+	}
+
+	return proxyURL, nil
+}
+
+type noProxyMatcher struct {
+	all     bool
+	ips     []net.IP
+	cidrs   []*net.IPNet
+	domains []noProxyDomain
+}
+
+type noProxyDomain struct {
+	domain     string
+	port       string
+	subdomains bool
+}
+
+// parseNoProxy parses a NO_PROXY list with the Go semantics: `*` matches
+// every host, IPs and CIDRs match IP hosts, `example.com` matches the domain
+// and its subdomains, `.example.com` only the subdomains, and a `:port`
+// suffix restricts the entry to a port.
+func parseNoProxy(noProxy string) noProxyMatcher {
+	matcher := noProxyMatcher{}
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			matcher.all = true
+			continue
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			matcher.cidrs = append(matcher.cidrs, cidr)
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(entry)
+		if err != nil {
+			host, port = entry, ""
+		}
+		if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+			matcher.ips = append(matcher.ips, ip)
+			continue
+		}
+
+		domain := noProxyDomain{domain: host, port: port}
+		if strings.HasPrefix(host, "*.") {
+			domain.domain = host[1:]
+		}
+		if strings.HasPrefix(domain.domain, ".") {
+			domain.subdomains = true
+		}
+		matcher.domains = append(matcher.domains, domain)
+	}
+
+	return matcher
+}
+
+func (m noProxyMatcher) useProxy(requestURL *url.URL) bool {
+	if m.all {
+		return false
+	}
+
+	host := strings.ToLower(requestURL.Hostname())
+	port := requestURL.Port()
+	if port == "" {
+		port = "80"
+		if requestURL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	if host == "localhost" {
+		return false
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() {
+			return false
+		}
+		for _, noProxyIP := range m.ips {
+			if noProxyIP.Equal(ip) {
+				return false
+			}
+		}
+		for _, cidr := range m.cidrs {
+			if cidr.Contains(ip) {
+				return false
+			}
+		}
+	}
+
+	for _, domain := range m.domains {
+		if domain.port != "" && domain.port != port {
+			continue
+		}
+		if domain.subdomains {
+			if strings.HasSuffix(host, domain.domain) {
+				return false
+			}
+			continue
+		}
+		if host == domain.domain || strings.HasSuffix(host, "."+domain.domain) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,69 @@
+package publishers
+
+import (
+	"sync"
+
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+// AsyncPublisher publishes the snapshots in the background, so slow
+// monitoring systems do not delay the collections. Only the latest snapshot
+// of each BOSH Director not published yet is kept, replacing the older ones.
+type AsyncPublisher struct {
+	publisher Publisher
+	mu        *sync.Mutex
+	pending   map[string]fetcher.Snapshot
+	order     []string
+	wake      chan struct{}
+}
+
+func NewAsyncPublisher(publisher Publisher) *AsyncPublisher {
+	return &AsyncPublisher{
+		publisher: publisher,
+		mu:        &sync.Mutex{},
+		pending:   map[string]fetcher.Snapshot{},
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Publish queues the snapshot to be published by Run and never fails, the
+// publication errors being logged.
+func (p *AsyncPublisher) Publish(snapshot fetcher.Snapshot) error {
+	p.mu.Lock()
+	if _, found := p.pending[snapshot.Director.UUID]; !found {
+		p.order = append(p.order, snapshot.Director.UUID)
+	}
+	p.pending[snapshot.Director.UUID] = snapshot
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run publishes the queued snapshots until stop is closed.
+func (p *AsyncPublisher) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-p.wake:
+		case <-stop:
+			return
+		}
+
+		p.mu.Lock()
+		pending, order := p.pending, p.order
+		p.pending, p.order = map[string]fetcher.Snapshot{}, nil
+		p.mu.Unlock()
+
+		for _, uuid := range order {
+			if err := p.publisher.Publish(pending[uuid]); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
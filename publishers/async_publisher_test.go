@@ -0,0 +1,94 @@
+package publishers_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+
+	. "github.com/bosh-prometheus/bosh_exporter/publishers"
+)
+
+type fakePublisher struct {
+	published chan string
+	err       error
+}
+
+func (p *fakePublisher) Publish(snapshot fetcher.Snapshot) error {
+	p.published <- snapshot.Director.Name
+	return p.err
+}
+
+var _ = Describe("AsyncPublisher", func() {
+	var (
+		publisher      *fakePublisher
+		asyncPublisher *AsyncPublisher
+		stop           chan struct{}
+
+		snapshot = func(name string, uuid string) fetcher.Snapshot {
+			return fetcher.Snapshot{Director: fetcher.DirectorInfo{Name: name, UUID: uuid}}
+		}
+	)
+
+	BeforeEach(func() {
+		publisher = &fakePublisher{published: make(chan string, 10)}
+		asyncPublisher = NewAsyncPublisher(publisher)
+		stop = make(chan struct{})
+	})
+
+	AfterEach(func() {
+		close(stop)
+	})
+
+	Describe("Publish", func() {
+		It("does not publish the snapshot until it runs", func() {
+			Expect(asyncPublisher.Publish(snapshot("fake-snapshot", "fake-bosh-uuid"))).To(Succeed())
+			Consistently(publisher.published).ShouldNot(Receive())
+		})
+
+		It("only keeps the latest snapshot", func() {
+			Expect(asyncPublisher.Publish(snapshot("fake-snapshot-1", "fake-bosh-uuid"))).To(Succeed())
+			Expect(asyncPublisher.Publish(snapshot("fake-snapshot-2", "fake-bosh-uuid"))).To(Succeed())
+
+			go asyncPublisher.Run(stop)
+			Eventually(publisher.published).Should(Receive(Equal("fake-snapshot-2")))
+			Consistently(publisher.published).ShouldNot(Receive())
+		})
+
+		It("keeps the latest snapshot of each BOSH Director", func() {
+			Expect(asyncPublisher.Publish(snapshot("fake-snapshot-1", "fake-bosh-uuid-1"))).To(Succeed())
+			Expect(asyncPublisher.Publish(snapshot("fake-snapshot-2", "fake-bosh-uuid-2"))).To(Succeed())
+			Expect(asyncPublisher.Publish(snapshot("fake-snapshot-3", "fake-bosh-uuid-1"))).To(Succeed())
+
+			go asyncPublisher.Run(stop)
+			Eventually(publisher.published).Should(Receive(Equal("fake-snapshot-3")))
+			Eventually(publisher.published).Should(Receive(Equal("fake-snapshot-2")))
+			Consistently(publisher.published).ShouldNot(Receive())
+		})
+
+		Context("when the publisher fails", func() {
+			BeforeEach(func() {
+				publisher.err = errors.New("fake-error")
+			})
+
+			It("does not return an error", func() {
+				go asyncPublisher.Run(stop)
+				Expect(asyncPublisher.Publish(snapshot("fake-snapshot", "fake-bosh-uuid"))).To(Succeed())
+				Eventually(publisher.published).Should(Receive(Equal("fake-snapshot")))
+			})
+		})
+	})
+
+	Describe("Run", func() {
+		It("publishes the queued snapshots", func() {
+			go asyncPublisher.Run(stop)
+
+			Expect(asyncPublisher.Publish(snapshot("fake-snapshot-1", "fake-bosh-uuid"))).To(Succeed())
+			Eventually(publisher.published).Should(Receive(Equal("fake-snapshot-1")))
+			Expect(asyncPublisher.Publish(snapshot("fake-snapshot-2", "fake-bosh-uuid"))).To(Succeed())
+			Eventually(publisher.published).Should(Receive(Equal("fake-snapshot-2")))
+		})
+	})
+})
@@ -0,0 +1,190 @@
+package publishers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+const (
+	Icinga2StateOK       = 0
+	Icinga2StateWarning  = 1
+	Icinga2StateCritical = 2
+)
+
+var icinga2StateNames = map[int]string{
+	Icinga2StateOK:       "OK",
+	Icinga2StateWarning:  "WARNING",
+	Icinga2StateCritical: "CRITICAL",
+}
+
+type Icinga2Thresholds struct {
+	Warning  int
+	Critical int
+}
+
+type Icinga2Config struct {
+	APIURL                string
+	Username              string
+	Password              string
+	Host                  string
+	Thresholds            Icinga2Thresholds
+	DeploymentsThresholds map[string]Icinga2Thresholds
+}
+
+type icinga2CheckResult struct {
+	Type            string   `json:"type"`
+	Filter          string   `json:"filter"`
+	ExitStatus      int      `json:"exit_status"`
+	PluginOutput    string   `json:"plugin_output"`
+	PerformanceData []string `json:"performance_data"`
+	CheckSource     string   `json:"check_source"`
+}
+
+type Icinga2Publisher struct {
+	config     Icinga2Config
+	httpClient *http.Client
+}
+
+func ParseIcinga2Thresholds(thresholds string) (Icinga2Thresholds, error) {
+	parts := strings.SplitN(thresholds, ":", 2)
+	if len(parts) != 2 {
+		return Icinga2Thresholds{}, errors.New(fmt.Sprintf("Icinga2 thresholds `%s` must be formatted as <warning>:<critical>", thresholds))
+	}
+
+	warning, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return Icinga2Thresholds{}, errors.New(fmt.Sprintf("Error parsing Icinga2 warning threshold `%s`: %v", parts[0], err))
+	}
+	critical, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return Icinga2Thresholds{}, errors.New(fmt.Sprintf("Error parsing Icinga2 critical threshold `%s`: %v", parts[1], err))
+	}
+	if warning < 1 {
+		return Icinga2Thresholds{}, errors.New(fmt.Sprintf("Icinga2 warning threshold `%d` must be greater than 0", warning))
+	}
+	if warning > critical {
+		return Icinga2Thresholds{}, errors.New(fmt.Sprintf("Icinga2 warning threshold `%d` is greater than the critical threshold `%d`", warning, critical))
+	}
+
+	return Icinga2Thresholds{Warning: warning, Critical: critical}, nil
+}
+
+func ParseIcinga2DeploymentsThresholds(deploymentsThresholds []string) (map[string]Icinga2Thresholds, error) {
+	thresholds := map[string]Icinga2Thresholds{}
+
+	for _, deploymentThresholds := range deploymentsThresholds {
+		parts := strings.SplitN(deploymentThresholds, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.New(fmt.Sprintf("Icinga2 deployment thresholds `%s` must be formatted as <deployment>=<warning>:<critical>", deploymentThresholds))
+		}
+
+		deploymentThreshold, err := ParseIcinga2Thresholds(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		thresholds[strings.TrimSpace(parts[0])] = deploymentThreshold
+	}
+
+	return thresholds, nil
+}
+
+func NewIcinga2Publisher(config Icinga2Config, httpClient *http.Client) *Icinga2Publisher {
+	config.APIURL = strings.TrimSuffix(config.APIURL, "/")
+
+	return &Icinga2Publisher{config: config, httpClient: httpClient}
+}
+
+func (p *Icinga2Publisher) Publish(snapshot fetcher.Snapshot) error {
+	host := p.config.Host
+	if host == "" {
+		host = snapshot.Director.Name
+	}
+
+	var err error
+	for _, deployment := range snapshot.Deployments {
+		if publishErr := p.processCheckResult(p.checkResult(host, deployment)); publishErr != nil && err == nil {
+			err = publishErr
+		}
+	}
+
+	return err
+}
+
+func (p *Icinga2Publisher) checkResult(host string, deployment deployments.DeploymentInfo) icinga2CheckResult {
+	thresholds, ok := p.config.DeploymentsThresholds[deployment.Name]
+	if !ok {
+		thresholds = p.config.Thresholds
+	}
+
+	failingProcesses := []string{}
+	for _, instance := range deployment.Instances {
+		for _, process := range instance.Processes {
+			if !process.Healthy {
+				failingProcesses = append(failingProcesses, fmt.Sprintf("%s/%s:%s", instance.Name, instance.ID, process.Name))
+			}
+		}
+	}
+	sort.Strings(failingProcesses)
+
+	state := Icinga2StateOK
+	switch {
+	case len(failingProcesses) >= thresholds.Critical:
+		state = Icinga2StateCritical
+	case len(failingProcesses) >= thresholds.Warning:
+		state = Icinga2StateWarning
+	}
+
+	output := fmt.Sprintf("%s - %d failing processes in deployment %s", icinga2StateNames[state], len(failingProcesses), deployment.Name)
+	if len(failingProcesses) > 0 {
+		output = fmt.Sprintf("%s: %s", output, strings.Join(failingProcesses, ", "))
+	}
+
+	return icinga2CheckResult{
+		Type:            "Service",
+		Filter:          fmt.Sprintf("host.name==%s && service.name==%s", strconv.Quote(host), strconv.Quote(deployment.Name)),
+		ExitStatus:      state,
+		PluginOutput:    output,
+		PerformanceData: []string{fmt.Sprintf("failing_processes=%d;%d;%d;0", len(failingProcesses), thresholds.Warning, thresholds.Critical)},
+		CheckSource:     "bosh_exporter",
+	}
+}
+
+func (p *Icinga2Publisher) processCheckResult(checkResult icinga2CheckResult) error {
+	body, err := json.Marshal(checkResult)
+	if err != nil {
+		return fmt.Errorf("Error marshalling Icinga2 check result: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", p.config.APIURL+"/v1/actions/process-check-result", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Error creating Icinga2 request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if p.config.Username != "" {
+		req.SetBasicAuth(p.config.Username, p.config.Password)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error submitting Icinga2 check result `%s`: %v", checkResult.Filter, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Error submitting Icinga2 check result `%s`: %s: %s", checkResult.Filter, resp.Status, string(respBody))
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package publishers_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+
+	. "github.com/bosh-prometheus/bosh_exporter/publishers"
+)
+
+type icinga2Request struct {
+	Path string
+	Auth string
+	Body map[string]interface{}
+}
+
+var _ = Describe("Icinga2Publisher", func() {
+	var (
+		err                   error
+		server                *httptest.Server
+		responseCode          int
+		requests              chan icinga2Request
+		deploymentsThresholds map[string]Icinga2Thresholds
+		snapshot              fetcher.Snapshot
+		icinga2Publisher      *Icinga2Publisher
+
+		deployment = func(name string, failingProcesses int) deployments.DeploymentInfo {
+			processes := []deployments.Process{{Name: "fake-process", Healthy: true}}
+			for i := 0; i < failingProcesses; i++ {
+				processes = append(processes, deployments.Process{Name: "fake-failing-process", Healthy: false})
+			}
+
+			return deployments.DeploymentInfo{
+				Name: name,
+				Instances: []deployments.Instance{
+					{Name: "fake-job-name", ID: "fake-job-id", Processes: processes},
+				},
+			}
+		}
+
+		receiveRequest = func() icinga2Request {
+			var request icinga2Request
+			Eventually(requests).Should(Receive(&request))
+			return request
+		}
+	)
+
+	BeforeEach(func() {
+		responseCode = http.StatusOK
+		requests = make(chan icinga2Request, 10)
+		deploymentsThresholds = map[string]Icinga2Thresholds{}
+		snapshot = fetcher.Snapshot{
+			Director:    fetcher.DirectorInfo{Name: "fake-bosh-name"},
+			Deployments: []deployments.DeploymentInfo{deployment("fake-deployment-name", 0)},
+		}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			username, password, _ := r.BasicAuth()
+			request := icinga2Request{Path: r.URL.Path, Auth: username + ":" + password}
+			_ = json.Unmarshal(body, &request.Body)
+			requests <- request
+
+			w.WriteHeader(responseCode)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		icinga2Publisher = NewIcinga2Publisher(
+			Icinga2Config{
+				APIURL:                server.URL + "/",
+				Username:              "fake-username",
+				Password:              "fake-password",
+				Thresholds:            Icinga2Thresholds{Warning: 1, Critical: 3},
+				DeploymentsThresholds: deploymentsThresholds,
+			},
+			http.DefaultClient,
+		)
+		err = icinga2Publisher.Publish(snapshot)
+	})
+
+	Describe("Publish", func() {
+		It("submits an OK passive check result per deployment", func() {
+			Expect(err).ToNot(HaveOccurred())
+
+			request := receiveRequest()
+			Expect(request.Path).To(Equal("/v1/actions/process-check-result"))
+			Expect(request.Auth).To(Equal("fake-username:fake-password"))
+			Expect(request.Body["type"]).To(Equal("Service"))
+			Expect(request.Body["filter"]).To(Equal(`host.name=="fake-bosh-name" && service.name=="fake-deployment-name"`))
+			Expect(request.Body["exit_status"]).To(Equal(float64(0)))
+			Expect(request.Body["plugin_output"]).To(Equal("OK - 0 failing processes in deployment fake-deployment-name"))
+			Expect(request.Body["performance_data"]).To(Equal([]interface{}{"failing_processes=0;1;3;0"}))
+		})
+
+		Context("when processes are failing", func() {
+			BeforeEach(func() {
+				snapshot.Deployments = []deployments.DeploymentInfo{
+					deployment("fake-warning-deployment", 1),
+					deployment("fake-critical-deployment", 3),
+				}
+			})
+
+			It("submits WARNING and CRITICAL check results", func() {
+				Expect(err).ToNot(HaveOccurred())
+
+				request := receiveRequest()
+				Expect(request.Body["exit_status"]).To(Equal(float64(1)))
+				Expect(request.Body["plugin_output"]).To(Equal("WARNING - 1 failing processes in deployment fake-warning-deployment: fake-job-name/fake-job-id:fake-failing-process"))
+
+				request = receiveRequest()
+				Expect(request.Body["exit_status"]).To(Equal(float64(2)))
+			})
+
+			Context("and the deployment has its own thresholds", func() {
+				BeforeEach(func() {
+					deploymentsThresholds = map[string]Icinga2Thresholds{"fake-critical-deployment": {Warning: 5, Critical: 10}}
+				})
+
+				It("uses the deployment thresholds", func() {
+					Expect(err).ToNot(HaveOccurred())
+
+					receiveRequest()
+					request := receiveRequest()
+					Expect(request.Body["exit_status"]).To(Equal(float64(0)))
+					Expect(request.Body["performance_data"]).To(Equal([]interface{}{"failing_processes=3;5;10;0"}))
+				})
+			})
+		})
+
+		Context("when the Icinga2 API rejects the check result", func() {
+			BeforeEach(func() {
+				responseCode = http.StatusNotFound
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("404 Not Found"))
+			})
+		})
+	})
+
+	Describe("ParseIcinga2DeploymentsThresholds", func() {
+		It("parses the deployments thresholds", func() {
+			thresholds, err := ParseIcinga2DeploymentsThresholds([]string{"cf=2:5", "redis = 1:1"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(thresholds).To(Equal(map[string]Icinga2Thresholds{
+				"cf":    {Warning: 2, Critical: 5},
+				"redis": {Warning: 1, Critical: 1},
+			}))
+		})
+
+		It("returns an error when the format is invalid", func() {
+			_, err := ParseIcinga2DeploymentsThresholds([]string{"cf"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when the warning threshold is greater than the critical threshold", func() {
+			_, err := ParseIcinga2DeploymentsThresholds([]string{"cf=5:2"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,9 @@
+package publishers
+
+import (
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+type Publisher interface {
+	Publish(snapshot fetcher.Snapshot) error
+}
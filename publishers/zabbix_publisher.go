@@ -0,0 +1,173 @@
+package publishers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+const zabbixSenderRequest = "sender data"
+
+var (
+	zabbixHeader       = []byte("ZBXD\x01")
+	zabbixFailedRegexp = regexp.MustCompile(`failed: (\d+)`)
+)
+
+type ZabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+}
+
+type zabbixSenderData struct {
+	Request string       `json:"request"`
+	Data    []ZabbixItem `json:"data"`
+	Clock   int64        `json:"clock"`
+}
+
+type zabbixSenderResponse struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+type ZabbixPublisher struct {
+	address string
+	host    string
+	timeout time.Duration
+}
+
+func NewZabbixPublisher(address string, host string, timeout time.Duration) *ZabbixPublisher {
+	return &ZabbixPublisher{address: address, host: host, timeout: timeout}
+}
+
+func (p *ZabbixPublisher) Publish(snapshot fetcher.Snapshot) error {
+	host := p.host
+	if host == "" {
+		host = snapshot.Director.Name
+	}
+
+	items := ZabbixItems(host, snapshot)
+	if len(items) == 0 {
+		return nil
+	}
+
+	return p.send(items, snapshot.FetchedAt.Unix())
+}
+
+func ZabbixItems(host string, snapshot fetcher.Snapshot) []ZabbixItem {
+	items := []ZabbixItem{}
+	clock := snapshot.FetchedAt.Unix()
+
+	for _, deployment := range snapshot.Deployments {
+		for _, instance := range deployment.Instances {
+			item := func(key string, value string) ZabbixItem {
+				return ZabbixItem{
+					Host:  host,
+					Key:   fmt.Sprintf("%s[%s,%s/%s]", key, deployment.Name, instance.Name, instance.ID),
+					Value: value,
+					Clock: clock,
+				}
+			}
+
+			running := "0"
+			if instance.Healthy {
+				running = "1"
+			}
+			items = append(items, item("bosh.job.running", running))
+
+			failingProcesses := 0
+			for _, process := range instance.Processes {
+				if !process.Healthy {
+					failingProcesses++
+				}
+			}
+			items = append(items, item("bosh.job.processes.failing", strconv.Itoa(failingProcesses)))
+
+			for _, disk := range []struct {
+				key  string
+				disk deployments.Disk
+			}{
+				{key: "bosh.job.system_disk.percent", disk: instance.Vitals.SystemDisk},
+				{key: "bosh.job.ephemeral_disk.percent", disk: instance.Vitals.EphemeralDisk},
+				{key: "bosh.job.persistent_disk.percent", disk: instance.Vitals.PersistentDisk},
+			} {
+				if disk.disk.Percent != "" {
+					items = append(items, item(disk.key, disk.disk.Percent))
+				}
+			}
+		}
+	}
+
+	return items
+}
+
+func (p *ZabbixPublisher) send(items []ZabbixItem, clock int64) error {
+	data, err := json.Marshal(zabbixSenderData{Request: zabbixSenderRequest, Data: items, Clock: clock})
+	if err != nil {
+		return fmt.Errorf("Error marshalling Zabbix sender data: %v", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", p.address, p.timeout)
+	if err != nil {
+		return fmt.Errorf("Error connecting to Zabbix server `%s`: %v", p.address, err)
+	}
+	defer conn.Close()
+	if p.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(p.timeout))
+	}
+
+	if _, err := conn.Write(zabbixPacket(data)); err != nil {
+		return fmt.Errorf("Error sending data to Zabbix server `%s`: %v", p.address, err)
+	}
+
+	body, err := readZabbixPacket(conn)
+	if err != nil {
+		return fmt.Errorf("Error reading Zabbix server `%s` response: %v", p.address, err)
+	}
+
+	var response zabbixSenderResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("Error parsing Zabbix server `%s` response: %v", p.address, err)
+	}
+	if response.Response != "success" {
+		return fmt.Errorf("Error sending data to Zabbix server `%s`: %s %s", p.address, response.Response, response.Info)
+	}
+	if matches := zabbixFailedRegexp.FindStringSubmatch(response.Info); matches != nil && matches[1] != "0" {
+		return fmt.Errorf("Error sending data to Zabbix server `%s`: %s", p.address, response.Info)
+	}
+
+	return nil
+}
+
+func zabbixPacket(data []byte) []byte {
+	packet := make([]byte, len(zabbixHeader)+8, len(zabbixHeader)+8+len(data))
+	copy(packet, zabbixHeader)
+	binary.LittleEndian.PutUint64(packet[len(zabbixHeader):], uint64(len(data)))
+
+	return append(packet, data...)
+}
+
+func readZabbixPacket(reader io.Reader) ([]byte, error) {
+	header := make([]byte, len(zabbixHeader)+8)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(zabbixHeader)], zabbixHeader) {
+		return nil, errors.New("invalid Zabbix protocol header")
+	}
+
+	length := binary.LittleEndian.Uint64(header[len(zabbixHeader):])
+	return ioutil.ReadAll(io.LimitReader(reader, int64(length)))
+}
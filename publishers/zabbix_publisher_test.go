@@ -0,0 +1,158 @@
+package publishers_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/bosh_exporter/deployments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+
+	. "github.com/bosh-prometheus/bosh_exporter/publishers"
+)
+
+type zabbixRequest struct {
+	Request string       `json:"request"`
+	Data    []ZabbixItem `json:"data"`
+}
+
+func zabbixResponse(response string) []byte {
+	packet := make([]byte, 13)
+	copy(packet, "ZBXD\x01")
+	binary.LittleEndian.PutUint64(packet[5:], uint64(len(response)))
+	return append(packet, response...)
+}
+
+var _ = Describe("ZabbixPublisher", func() {
+	var (
+		err             error
+		listener        net.Listener
+		serverResponse  string
+		requests        chan zabbixRequest
+		snapshot        fetcher.Snapshot
+		zabbixPublisher *ZabbixPublisher
+
+		fetchedAt = time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	BeforeEach(func() {
+		serverResponse = `{"response":"success","info":"processed: 5; failed: 0; total: 5; seconds spent: 0.000055"}`
+		requests = make(chan zabbixRequest, 1)
+
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		snapshot = fetcher.Snapshot{
+			Director: fetcher.DirectorInfo{Name: "fake-bosh-name"},
+			Deployments: []deployments.DeploymentInfo{
+				{
+					Name: "fake-deployment-name",
+					Instances: []deployments.Instance{
+						{
+							Name:    "fake-job-name",
+							ID:      "fake-job-id",
+							Healthy: true,
+							Processes: []deployments.Process{
+								{Name: "fake-process-1", Healthy: true},
+								{Name: "fake-process-2", Healthy: false},
+							},
+							Vitals: deployments.Vitals{
+								SystemDisk:     deployments.Disk{Percent: "10"},
+								PersistentDisk: deployments.Disk{Percent: "60"},
+							},
+						},
+					},
+				},
+			},
+			FetchedAt: fetchedAt,
+		}
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	JustBeforeEach(func() {
+		go func() {
+			defer GinkgoRecover()
+
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			header := make([]byte, 13)
+			_, err = io.ReadFull(conn, header)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(header[:5])).To(Equal("ZBXD\x01"))
+
+			body := make([]byte, binary.LittleEndian.Uint64(header[5:]))
+			_, err = io.ReadFull(conn, body)
+			Expect(err).ToNot(HaveOccurred())
+
+			var request zabbixRequest
+			Expect(json.Unmarshal(body, &request)).To(Succeed())
+			requests <- request
+
+			conn.Write(zabbixResponse(serverResponse))
+		}()
+
+		zabbixPublisher = NewZabbixPublisher(listener.Addr().String(), "", 5*time.Second)
+		err = zabbixPublisher.Publish(snapshot)
+	})
+
+	Describe("Publish", func() {
+		It("sends the health indicators to the Zabbix server", func() {
+			Expect(err).ToNot(HaveOccurred())
+
+			var request zabbixRequest
+			Eventually(requests).Should(Receive(&request))
+			Expect(request.Request).To(Equal("sender data"))
+			Expect(request.Data).To(ConsistOf(
+				ZabbixItem{Host: "fake-bosh-name", Key: "bosh.job.running[fake-deployment-name,fake-job-name/fake-job-id]", Value: "1", Clock: fetchedAt.Unix()},
+				ZabbixItem{Host: "fake-bosh-name", Key: "bosh.job.processes.failing[fake-deployment-name,fake-job-name/fake-job-id]", Value: "1", Clock: fetchedAt.Unix()},
+				ZabbixItem{Host: "fake-bosh-name", Key: "bosh.job.system_disk.percent[fake-deployment-name,fake-job-name/fake-job-id]", Value: "10", Clock: fetchedAt.Unix()},
+				ZabbixItem{Host: "fake-bosh-name", Key: "bosh.job.persistent_disk.percent[fake-deployment-name,fake-job-name/fake-job-id]", Value: "60", Clock: fetchedAt.Unix()},
+			))
+		})
+
+		Context("when the Zabbix server fails to process some items", func() {
+			BeforeEach(func() {
+				serverResponse = `{"response":"success","info":"processed: 3; failed: 1; total: 4; seconds spent: 0.000055"}`
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("failed: 1"))
+			})
+		})
+
+		Context("when the Zabbix server rejects the data", func() {
+			BeforeEach(func() {
+				serverResponse = `{"response":"failed","info":"invalid request"}`
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("invalid request"))
+			})
+		})
+
+		Context("when there are no deployments", func() {
+			BeforeEach(func() {
+				snapshot.Deployments = []deployments.DeploymentInfo{}
+			})
+
+			It("does not connect to the Zabbix server", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Consistently(requests).ShouldNot(Receive())
+			})
+		})
+	})
+})
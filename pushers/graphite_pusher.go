@@ -0,0 +1,128 @@
+package pushers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	GraphiteProtocol = "graphite"
+	StatsdProtocol   = "statsd"
+
+	statsdMaxPacketSize = 1432
+)
+
+var graphiteInvalidCharsRegexp = regexp.MustCompile(`[^a-zA-Z0-9_\-]`)
+
+type GraphitePusher struct {
+	protocol string
+	address  string
+	prefix   string
+	timeout  time.Duration
+}
+
+func NewGraphitePusher(protocol string, address string, prefix string, timeout time.Duration) (*GraphitePusher, error) {
+	if protocol != GraphiteProtocol && protocol != StatsdProtocol {
+		return nil, errors.New(fmt.Sprintf("Protocol `%s` is not supported, it must be `%s` or `%s`", protocol, GraphiteProtocol, StatsdProtocol))
+	}
+
+	return &GraphitePusher{protocol: protocol, address: address, prefix: strings.TrimSuffix(prefix, "."), timeout: timeout}, nil
+}
+
+func (p *GraphitePusher) Push(metricFamilies []*dto.MetricFamily, timestamp time.Time) error {
+	samples := flattenMetricFamilies(metricFamilies)
+	if len(samples) == 0 {
+		return nil
+	}
+
+	if p.protocol == StatsdProtocol {
+		return p.pushStatsd(samples)
+	}
+
+	return p.pushGraphite(samples, timestamp)
+}
+
+func (p *GraphitePusher) pushGraphite(samples []sample, timestamp time.Time) error {
+	var buf bytes.Buffer
+	for _, s := range samples {
+		fmt.Fprintf(&buf, "%s %s %d\n", p.path(s), strconv.FormatFloat(s.value, 'f', -1, 64), timestamp.Unix())
+	}
+
+	conn, err := net.DialTimeout("tcp", p.address, p.timeout)
+	if err != nil {
+		return fmt.Errorf("Error connecting to Graphite `%s`: %v", p.address, err)
+	}
+	defer conn.Close()
+	if p.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(p.timeout))
+	}
+
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("Error writing metrics to Graphite `%s`: %v", p.address, err)
+	}
+
+	return nil
+}
+
+func (p *GraphitePusher) pushStatsd(samples []sample) error {
+	conn, err := net.DialTimeout("udp", p.address, p.timeout)
+	if err != nil {
+		return fmt.Errorf("Error connecting to statsd `%s`: %v", p.address, err)
+	}
+	defer conn.Close()
+
+	var buf bytes.Buffer
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		defer buf.Reset()
+		if _, err := conn.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("Error writing metrics to statsd `%s`: %v", p.address, err)
+		}
+		return nil
+	}
+
+	for _, s := range samples {
+		path := p.path(s)
+		lines := ""
+		if s.value < 0 {
+			// statsd treats signed gauge values as deltas, so reset the gauge first
+			lines = fmt.Sprintf("%s:0|g\n", path)
+		}
+		lines += fmt.Sprintf("%s:%s|g\n", path, strconv.FormatFloat(s.value, 'f', -1, 64))
+
+		if buf.Len()+len(lines) > statsdMaxPacketSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(lines)
+	}
+
+	return flush()
+}
+
+func (p *GraphitePusher) path(s sample) string {
+	parts := []string{}
+	if p.prefix != "" {
+		parts = append(parts, p.prefix)
+	}
+	parts = append(parts, s.name)
+	for _, label := range s.labels {
+		if label.value == "" {
+			continue
+		}
+		parts = append(parts, label.name, graphiteInvalidCharsRegexp.ReplaceAllString(label.value, "_"))
+	}
+
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,108 @@
+package pushers_test
+
+import (
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/bosh-prometheus/bosh_exporter/pushers"
+)
+
+var _ = Describe("GraphitePusher", func() {
+	var (
+		err            error
+		metricFamilies []*dto.MetricFamily
+		timestamp      = time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	BeforeEach(func() {
+		metricFamilies, err = testRegistry().Gather()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Describe("NewGraphitePusher", func() {
+		It("returns an error when the protocol is not supported", func() {
+			_, err := NewGraphitePusher("carbon", "127.0.0.1:2003", "", time.Second)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("with the Graphite protocol", func() {
+		var (
+			listener net.Listener
+			received chan string
+		)
+
+		BeforeEach(func() {
+			listener, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+
+			received = make(chan string, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				content, _ := ioutil.ReadAll(conn)
+				received <- string(content)
+			}()
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("writes the metrics as Graphite plaintext", func() {
+			graphitePusher, err := NewGraphitePusher(GraphiteProtocol, listener.Addr().String(), "legacy.", time.Second)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(graphitePusher.Push(metricFamilies, timestamp)).To(Succeed())
+
+			var content string
+			Eventually(received).Should(Receive(&content))
+			Expect(strings.Split(strings.TrimSpace(content), "\n")).To(ConsistOf(
+				"legacy.bosh_job_healthy.bosh_deployment.fake-deployment-name.bosh_job_name.fake-job-name.environment.test_environment 1 1514764800",
+				"legacy.bosh_scrapes_total 3 1514764800",
+				"legacy.other_metric -1.5 1514764800",
+			))
+		})
+	})
+
+	Context("with the statsd protocol", func() {
+		var (
+			conn net.PacketConn
+		)
+
+		BeforeEach(func() {
+			conn, err = net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			conn.Close()
+		})
+
+		It("writes the metrics as statsd gauges", func() {
+			graphitePusher, err := NewGraphitePusher(StatsdProtocol, conn.LocalAddr().String(), "", time.Second)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(graphitePusher.Push(metricFamilies, timestamp)).To(Succeed())
+
+			packet := make([]byte, 2048)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := conn.ReadFrom(packet)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(strings.Split(strings.TrimSpace(string(packet[:n])), "\n")).To(ConsistOf(
+				"bosh_job_healthy.bosh_deployment.fake-deployment-name.bosh_job_name.fake-job-name.environment.test_environment:1|g",
+				"bosh_scrapes_total:3|g",
+				"other_metric:0|g",
+				"other_metric:-1.5|g",
+			))
+		})
+	})
+})
@@ -0,0 +1,105 @@
+package pushers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	influxDBMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxDBTagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+type InfluxDBConfig struct {
+	URL          string
+	Database     string
+	Username     string
+	Password     string
+	Organization string
+	Bucket       string
+	Token        string
+}
+
+type InfluxDBPusher struct {
+	config     InfluxDBConfig
+	writeURL   string
+	httpClient *http.Client
+}
+
+func NewInfluxDBPusher(config InfluxDBConfig, httpClient *http.Client) (*InfluxDBPusher, error) {
+	config.URL = strings.TrimSuffix(config.URL, "/")
+
+	query := url.Values{}
+	query.Set("precision", "s")
+
+	var writeURL string
+	switch {
+	case config.Bucket != "":
+		if config.Organization == "" {
+			return nil, errors.New("InfluxDB organization is required when writing to a bucket")
+		}
+		query.Set("org", config.Organization)
+		query.Set("bucket", config.Bucket)
+		writeURL = config.URL + "/api/v2/write?" + query.Encode()
+	case config.Database != "":
+		query.Set("db", config.Database)
+		writeURL = config.URL + "/write?" + query.Encode()
+	default:
+		return nil, errors.New("Either an InfluxDB database (v1 API) or bucket (v2 API) is required")
+	}
+
+	return &InfluxDBPusher{config: config, writeURL: writeURL, httpClient: httpClient}, nil
+}
+
+func (p *InfluxDBPusher) Push(metricFamilies []*dto.MetricFamily, timestamp time.Time) error {
+	samples := flattenMetricFamilies(metricFamilies)
+	if len(samples) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, s := range samples {
+		buf.WriteString(influxDBMeasurementEscaper.Replace(s.name))
+		for _, label := range s.labels {
+			if label.value == "" {
+				continue
+			}
+			fmt.Fprintf(&buf, ",%s=%s", influxDBTagEscaper.Replace(label.name), influxDBTagEscaper.Replace(label.value))
+		}
+		fmt.Fprintf(&buf, " value=%s %d\n", strconv.FormatFloat(s.value, 'f', -1, 64), timestamp.Unix())
+	}
+
+	req, err := http.NewRequest("POST", p.writeURL, &buf)
+	if err != nil {
+		return fmt.Errorf("Error creating InfluxDB request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	switch {
+	case p.config.Token != "":
+		req.Header.Set("Authorization", "Token "+p.config.Token)
+	case p.config.Username != "":
+		req.SetBasicAuth(p.config.Username, p.config.Password)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error writing metrics to InfluxDB `%s`: %v", p.config.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Error writing metrics to InfluxDB `%s`: %s: %s", p.config.URL, resp.Status, string(body))
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package pushers_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/bosh-prometheus/bosh_exporter/pushers"
+)
+
+type influxDBRequest struct {
+	Path  string
+	Query map[string]string
+	Auth  string
+	Lines []string
+}
+
+var _ = Describe("InfluxDBPusher", func() {
+	var (
+		err            error
+		server         *httptest.Server
+		responseCode   int
+		requests       chan influxDBRequest
+		config         InfluxDBConfig
+		metricFamilies []*dto.MetricFamily
+		timestamp      = time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	BeforeEach(func() {
+		responseCode = http.StatusNoContent
+		requests = make(chan influxDBRequest, 1)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			request := influxDBRequest{
+				Path:  r.URL.Path,
+				Query: map[string]string{},
+				Auth:  r.Header.Get("Authorization"),
+				Lines: strings.Split(strings.TrimSpace(string(body)), "\n"),
+			}
+			for key := range r.URL.Query() {
+				request.Query[key] = r.URL.Query().Get(key)
+			}
+			requests <- request
+
+			w.WriteHeader(responseCode)
+		}))
+
+		metricFamilies, err = testRegistry().Gather()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		config.URL = server.URL
+	})
+
+	Describe("NewInfluxDBPusher", func() {
+		It("returns an error when neither a database nor a bucket is set", func() {
+			_, err := NewInfluxDBPusher(InfluxDBConfig{URL: server.URL}, http.DefaultClient)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when a bucket is set without an organization", func() {
+			_, err := NewInfluxDBPusher(InfluxDBConfig{URL: server.URL, Bucket: "bosh"}, http.DefaultClient)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Push", func() {
+		JustBeforeEach(func() {
+			var influxDBPusher *InfluxDBPusher
+			influxDBPusher, err = NewInfluxDBPusher(config, http.DefaultClient)
+			Expect(err).ToNot(HaveOccurred())
+			err = influxDBPusher.Push(metricFamilies, timestamp)
+		})
+
+		Context("with the v1 API", func() {
+			BeforeEach(func() {
+				config = InfluxDBConfig{Database: "bosh", Username: "fake-username", Password: "fake-password"}
+			})
+
+			It("writes the metrics as line protocol", func() {
+				Expect(err).ToNot(HaveOccurred())
+
+				var request influxDBRequest
+				Eventually(requests).Should(Receive(&request))
+				Expect(request.Path).To(Equal("/write"))
+				Expect(request.Query).To(Equal(map[string]string{"db": "bosh", "precision": "s"}))
+				Expect(request.Auth).To(HavePrefix("Basic "))
+				Expect(request.Lines).To(ConsistOf(
+					"bosh_job_healthy,bosh_deployment=fake-deployment-name,bosh_job_name=fake-job-name,environment=test_environment value=1 1514764800",
+					"bosh_scrapes_total value=3 1514764800",
+					"other_metric value=-1.5 1514764800",
+				))
+			})
+
+			Context("when InfluxDB rejects the write", func() {
+				BeforeEach(func() {
+					responseCode = http.StatusUnauthorized
+				})
+
+				It("returns an error", func() {
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("401 Unauthorized"))
+				})
+			})
+		})
+
+		Context("with the v2 API", func() {
+			BeforeEach(func() {
+				config = InfluxDBConfig{Organization: "fake-org", Bucket: "bosh", Token: "fake-token"}
+			})
+
+			It("writes the metrics to the bucket using token auth", func() {
+				Expect(err).ToNot(HaveOccurred())
+
+				var request influxDBRequest
+				Eventually(requests).Should(Receive(&request))
+				Expect(request.Path).To(Equal("/api/v2/write"))
+				Expect(request.Query).To(Equal(map[string]string{"org": "fake-org", "bucket": "bosh", "precision": "s"}))
+				Expect(request.Auth).To(Equal("Token fake-token"))
+			})
+		})
+	})
+})
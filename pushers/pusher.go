@@ -0,0 +1,67 @@
+package pushers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/log"
+)
+
+type Pusher interface {
+	Push(metricFamilies []*dto.MetricFamily, timestamp time.Time) error
+}
+
+type Loop struct {
+	gatherer   prometheus.Gatherer
+	pushers    []Pusher
+	interval   time.Duration
+	namePrefix string
+	now        func() time.Time
+}
+
+func NewLoop(gatherer prometheus.Gatherer, pushers []Pusher, interval time.Duration, namePrefix string) *Loop {
+	return &Loop{gatherer: gatherer, pushers: pushers, interval: interval, namePrefix: namePrefix, now: time.Now}
+}
+
+func (l *Loop) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := l.PushOnce(); err != nil {
+			log.Error(err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (l *Loop) PushOnce() error {
+	metricFamilies, err := l.gatherer.Gather()
+	if err != nil {
+		log.Errorf("Error gathering metrics to push: %v", err)
+	}
+
+	timestamp := l.now()
+	filteredMetricFamilies := []*dto.MetricFamily{}
+	for _, metricFamily := range metricFamilies {
+		if strings.HasPrefix(metricFamily.GetName(), l.namePrefix) {
+			filteredMetricFamilies = append(filteredMetricFamilies, metricFamily)
+		}
+	}
+
+	var pushErr error
+	for _, pusher := range l.pushers {
+		if err := pusher.Push(filteredMetricFamilies, timestamp); err != nil && pushErr == nil {
+			pushErr = err
+		}
+	}
+
+	return pushErr
+}
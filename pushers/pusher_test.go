@@ -0,0 +1,101 @@
+package pushers_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/bosh-prometheus/bosh_exporter/pushers"
+)
+
+type fakePusher struct {
+	metricFamilies []*dto.MetricFamily
+	err            error
+}
+
+func (p *fakePusher) Push(metricFamilies []*dto.MetricFamily, timestamp time.Time) error {
+	p.metricFamilies = metricFamilies
+	return p.err
+}
+
+func testRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+
+	jobHealthyMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   "bosh",
+			Subsystem:   "job",
+			Name:        "healthy",
+			Help:        "BOSH Job Healthy (1 for healthy, 0 for unhealthy).",
+			ConstLabels: prometheus.Labels{"environment": "test_environment"},
+		},
+		[]string{"bosh_deployment", "bosh_job_name"},
+	)
+	jobHealthyMetric.WithLabelValues("fake-deployment-name", "fake-job-name").Set(1)
+	registry.MustRegister(jobHealthyMetric)
+
+	scrapesMetric := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "bosh",
+			Name:      "scrapes_total",
+			Help:      "Total number of times BOSH was scraped for metrics.",
+		},
+	)
+	scrapesMetric.Add(3)
+	registry.MustRegister(scrapesMetric)
+
+	otherMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "other_metric",
+			Help: "Metric not exported by the BOSH collectors.",
+		},
+	)
+	otherMetric.Set(-1.5)
+	registry.MustRegister(otherMetric)
+
+	return registry
+}
+
+var _ = Describe("Loop", func() {
+	var (
+		err    error
+		pusher *fakePusher
+		loop   *Loop
+	)
+
+	BeforeEach(func() {
+		pusher = &fakePusher{}
+	})
+
+	JustBeforeEach(func() {
+		loop = NewLoop(testRegistry(), []Pusher{pusher}, time.Minute, "bosh_")
+		err = loop.PushOnce()
+	})
+
+	Describe("PushOnce", func() {
+		It("pushes the gathered metrics with the name prefix", func() {
+			Expect(err).ToNot(HaveOccurred())
+
+			names := []string{}
+			for _, metricFamily := range pusher.metricFamilies {
+				names = append(names, metricFamily.GetName())
+			}
+			Expect(names).To(ConsistOf("bosh_job_healthy", "bosh_scrapes_total"))
+		})
+
+		Context("when the pusher fails", func() {
+			BeforeEach(func() {
+				pusher.err = errors.New("push error")
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("push error"))
+			})
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package pushers_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestPushers(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Pushers Suite")
+}
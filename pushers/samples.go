@@ -0,0 +1,86 @@
+package pushers
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+type labelPair struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	name   string
+	labels []labelPair
+	value  float64
+}
+
+func flattenMetricFamilies(metricFamilies []*dto.MetricFamily) []sample {
+	samples := []sample{}
+
+	for _, metricFamily := range metricFamilies {
+		name := metricFamily.GetName()
+		for _, metric := range metricFamily.GetMetric() {
+			labels := []labelPair{}
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, labelPair{name: label.GetName(), value: label.GetValue()})
+			}
+			sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+			switch metricFamily.GetType() {
+			case dto.MetricType_GAUGE:
+				samples = append(samples, sample{name: name, labels: labels, value: metric.GetGauge().GetValue()})
+			case dto.MetricType_COUNTER:
+				samples = append(samples, sample{name: name, labels: labels, value: metric.GetCounter().GetValue()})
+			case dto.MetricType_UNTYPED:
+				samples = append(samples, sample{name: name, labels: labels, value: metric.GetUntyped().GetValue()})
+			case dto.MetricType_SUMMARY:
+				for _, quantile := range metric.GetSummary().GetQuantile() {
+					samples = append(samples, sample{
+						name:   name,
+						labels: withLabel(labels, "quantile", strconv.FormatFloat(quantile.GetQuantile(), 'g', -1, 64)),
+						value:  quantile.GetValue(),
+					})
+				}
+				samples = append(samples, sample{name: name + "_sum", labels: labels, value: metric.GetSummary().GetSampleSum()})
+				samples = append(samples, sample{name: name + "_count", labels: labels, value: float64(metric.GetSummary().GetSampleCount())})
+			case dto.MetricType_HISTOGRAM:
+				for _, bucket := range metric.GetHistogram().GetBucket() {
+					samples = append(samples, sample{
+						name:   name + "_bucket",
+						labels: withLabel(labels, "le", strconv.FormatFloat(bucket.GetUpperBound(), 'g', -1, 64)),
+						value:  float64(bucket.GetCumulativeCount()),
+					})
+				}
+				samples = append(samples, sample{
+					name:   name + "_bucket",
+					labels: withLabel(labels, "le", "+Inf"),
+					value:  float64(metric.GetHistogram().GetSampleCount()),
+				})
+				samples = append(samples, sample{name: name + "_sum", labels: labels, value: metric.GetHistogram().GetSampleSum()})
+				samples = append(samples, sample{name: name + "_count", labels: labels, value: float64(metric.GetHistogram().GetSampleCount())})
+			}
+		}
+	}
+
+	validSamples := samples[:0]
+	for _, s := range samples {
+		if !math.IsNaN(s.value) && !math.IsInf(s.value, 0) {
+			validSamples = append(validSamples, s)
+		}
+	}
+
+	return validSamples
+}
+
+func withLabel(labels []labelPair, name string, value string) []labelPair {
+	newLabels := append([]labelPair{}, labels...)
+	newLabels = append(newLabels, labelPair{name: name, value: value})
+	sort.Slice(newLabels, func(i, j int) bool { return newLabels[i].name < newLabels[j].name })
+
+	return newLabels
+}
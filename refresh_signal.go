@@ -0,0 +1,38 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/prometheus/common/log"
+)
+
+func refreshOnSignal(refresher *boshRefresher) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	go func() {
+		for range signals {
+			log.Info("Refresh requested by SIGUSR1")
+			if err := refresher.Refresh(); err != nil {
+				log.Error(err)
+			}
+		}
+	}()
+}
+
+func reloadOnSignal(reloader *configReloader) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			log.Info("Configuration reload requested by SIGHUP")
+			reloader.Reload()
+		}
+	}()
+}
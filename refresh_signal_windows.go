@@ -0,0 +1,13 @@
+package main
+
+import (
+	"github.com/prometheus/common/log"
+)
+
+func refreshOnSignal(refresher *boshRefresher) {
+	log.Warn("Refreshing on SIGUSR1 is not supported on Windows")
+}
+
+func reloadOnSignal(reloader *configReloader) {
+	log.Warn("Reloading the configuration on SIGHUP is not supported on Windows")
+}
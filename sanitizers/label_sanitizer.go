@@ -0,0 +1,127 @@
+package sanitizers
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type Rule struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+}
+
+type Config struct {
+	Lowercase bool     `yaml:"lowercase"`
+	MaxLength int      `yaml:"max_length"`
+	Rules     []Rule   `yaml:"rules"`
+	Labels    []string `yaml:"labels"`
+}
+
+type compiledRule struct {
+	regexp      *regexp.Regexp
+	replacement string
+}
+
+// LabelSanitizer normalizes label values for downstream systems stricter than
+// Prometheus: values are lowercased, rewritten by the regex rules in order and
+// truncated, in that order. A nil LabelSanitizer leaves values unchanged.
+type LabelSanitizer struct {
+	lowercase bool
+	maxLength int
+	rules     []compiledRule
+	labels    map[string]bool
+}
+
+func LoadConfig(filename string) (*Config, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error reading label sanitization config `%s`: %v", filename, err))
+	}
+
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing label sanitization config `%s`: %v", filename, err))
+	}
+
+	return config, nil
+}
+
+func NewLabelSanitizer(config Config) (*LabelSanitizer, error) {
+	if config.MaxLength < 0 {
+		return nil, errors.New(fmt.Sprintf("Label sanitization max_length must not be negative, got %d", config.MaxLength))
+	}
+
+	sanitizer := &LabelSanitizer{
+		lowercase: config.Lowercase,
+		maxLength: config.MaxLength,
+		rules:     []compiledRule{},
+	}
+
+	for _, rule := range config.Rules {
+		ruleRegexp, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Error compiling label sanitization rule `%s`: %v", rule.Regex, err))
+		}
+		sanitizer.rules = append(sanitizer.rules, compiledRule{regexp: ruleRegexp, replacement: rule.Replacement})
+	}
+
+	if len(config.Labels) > 0 {
+		sanitizer.labels = map[string]bool{}
+		for _, label := range config.Labels {
+			sanitizer.labels[label] = true
+		}
+	}
+
+	return sanitizer, nil
+}
+
+// Sanitize returns the sanitized value of a label, leaving it unchanged when
+// the sanitizer only applies to other labels.
+func (s *LabelSanitizer) Sanitize(labelName string, value string) string {
+	if s == nil || (s.labels != nil && !s.labels[labelName]) {
+		return value
+	}
+
+	if s.lowercase {
+		value = strings.ToLower(value)
+	}
+	for _, rule := range s.rules {
+		value = rule.regexp.ReplaceAllString(value, rule.replacement)
+	}
+	if s.maxLength > 0 {
+		if runes := []rune(value); len(runes) > s.maxLength {
+			value = string(runes[:s.maxLength])
+		}
+	}
+
+	return value
+}
+
+// Gatherer wraps a gatherer so the label values of every gathered metric are
+// sanitized.
+func (s *LabelSanitizer) Gatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	if s == nil {
+		return gatherer
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		metricFamilies, err := gatherer.Gather()
+		for _, metricFamily := range metricFamilies {
+			for _, metric := range metricFamily.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					label.Value = proto.String(s.Sanitize(label.GetName(), label.GetValue()))
+				}
+			}
+		}
+
+		return metricFamilies, err
+	})
+}
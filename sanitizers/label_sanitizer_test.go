@@ -0,0 +1,140 @@
+package sanitizers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/sanitizers"
+)
+
+var _ = Describe("LabelSanitizer", func() {
+	var (
+		err       error
+		config    Config
+		sanitizer *LabelSanitizer
+	)
+
+	BeforeEach(func() {
+		config = Config{
+			Lowercase: true,
+			MaxLength: 10,
+			Rules:     []Rule{{Regex: "[^a-z0-9_]+", Replacement: "_"}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		sanitizer, err = NewLabelSanitizer(config)
+	})
+
+	Describe("Sanitize", func() {
+		It("lowercases, rewrites and truncates the value", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sanitizer.Sanitize("bosh_deployment", "CF-Prod.Redis")).To(Equal("cf_prod_re"))
+		})
+
+		It("does not truncate short values", func() {
+			Expect(sanitizer.Sanitize("bosh_deployment", "cf")).To(Equal("cf"))
+		})
+
+		Context("when the sanitizer only applies to some labels", func() {
+			BeforeEach(func() {
+				config.Labels = []string{"bosh_job_name"}
+			})
+
+			It("sanitizes those labels", func() {
+				Expect(sanitizer.Sanitize("bosh_job_name", "Diego-Cell")).To(Equal("diego_cell"))
+			})
+
+			It("leaves the other labels unchanged", func() {
+				Expect(sanitizer.Sanitize("bosh_deployment", "CF-Prod")).To(Equal("CF-Prod"))
+			})
+		})
+
+		Context("when the sanitizer is nil", func() {
+			It("leaves the value unchanged", func() {
+				var nilSanitizer *LabelSanitizer
+				Expect(nilSanitizer.Sanitize("bosh_deployment", "CF-Prod")).To(Equal("CF-Prod"))
+			})
+		})
+	})
+
+	Context("when a rule regex is invalid", func() {
+		BeforeEach(func() {
+			config.Rules = []Rule{{Regex: "[", Replacement: "_"}}
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Error compiling label sanitization rule `[`"))
+		})
+	})
+
+	Context("when the max length is negative", func() {
+		BeforeEach(func() {
+			config.MaxLength = -1
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Gatherer", func() {
+		It("sanitizes the label values of the gathered metrics", func() {
+			registry := prometheus.NewRegistry()
+			gaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_gauge", Help: "Test gauge."}, []string{"bosh_deployment"})
+			gaugeVec.WithLabelValues("CF-Prod").Set(1)
+			registry.MustRegister(gaugeVec)
+
+			metricFamilies, err := sanitizer.Gatherer(registry).Gather()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(1))
+			Expect(metricFamilies[0].GetMetric()[0].GetLabel()[0].GetValue()).To(Equal("cf_prod"))
+		})
+	})
+})
+
+var _ = Describe("LoadConfig", func() {
+	var (
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "sanitizers")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	It("loads the config", func() {
+		filename := filepath.Join(tmpDir, "sanitize.yml")
+		Expect(ioutil.WriteFile(filename, []byte("lowercase: true\nmax_length: 63\nrules:\n- regex: '[^a-z0-9_.-]'\n  replacement: _\nlabels: [bosh_deployment]\n"), 0600)).To(Succeed())
+
+		config, err := LoadConfig(filename)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*config).To(Equal(Config{
+			Lowercase: true,
+			MaxLength: 63,
+			Rules:     []Rule{{Regex: "[^a-z0-9_.-]", Replacement: "_"}},
+			Labels:    []string{"bosh_deployment"},
+		}))
+	})
+
+	It("refuses unknown keys", func() {
+		filename := filepath.Join(tmpDir, "sanitize.yml")
+		Expect(ioutil.WriteFile(filename, []byte("lowercas: true\n"), 0600)).To(Succeed())
+
+		_, err := LoadConfig(filename)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Error parsing label sanitization config"))
+	})
+})
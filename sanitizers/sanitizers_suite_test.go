@@ -0,0 +1,13 @@
+package sanitizers_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSanitizers(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sanitizers Suite")
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// shutdownSignals returns the signals asking the exporter to stop.
+func shutdownSignals() <-chan os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	return signals
+}
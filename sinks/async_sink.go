@@ -0,0 +1,59 @@
+package sinks
+
+import (
+	"github.com/prometheus/common/log"
+)
+
+// AsyncSink writes the Service Discovery output to a sink in the background,
+// so slow uploads do not delay the collections. Only the latest output not
+// written yet is kept, replacing the older ones.
+type AsyncSink struct {
+	sink    Sink
+	pending chan signedContent
+}
+
+type signedContent struct {
+	content   []byte
+	signature string
+}
+
+func NewAsyncSink(sink Sink) *AsyncSink {
+	return &AsyncSink{sink: sink, pending: make(chan signedContent, 1)}
+}
+
+// Write queues the content to be written by Run and never fails, the write
+// errors being logged.
+func (s *AsyncSink) Write(content []byte) error {
+	return s.WriteSigned(content, "")
+}
+
+// WriteSigned queues the content to be written by Run along with its
+// signature, when the sink stores signatures.
+func (s *AsyncSink) WriteSigned(content []byte, signature string) error {
+	for {
+		select {
+		case s.pending <- signedContent{content: content, signature: signature}:
+			return nil
+		default:
+		}
+
+		select {
+		case <-s.pending:
+		default:
+		}
+	}
+}
+
+// Run writes the queued contents to the sink until stop is closed.
+func (s *AsyncSink) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case pending := <-s.pending:
+			if err := Write(s.sink, pending.content, pending.signature); err != nil {
+				log.Error(err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
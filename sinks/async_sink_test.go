@@ -0,0 +1,104 @@
+package sinks_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/sinks"
+)
+
+type fakeSink struct {
+	written chan string
+	err     error
+}
+
+func (s *fakeSink) Write(content []byte) error {
+	s.written <- string(content)
+	return s.err
+}
+
+type fakeSignedSink struct {
+	fakeSink
+	signatures chan string
+}
+
+func (s *fakeSignedSink) WriteSigned(content []byte, signature string) error {
+	s.signatures <- signature
+	return s.Write(content)
+}
+
+var _ = Describe("AsyncSink", func() {
+	var (
+		sink      *fakeSink
+		asyncSink *AsyncSink
+		stop      chan struct{}
+	)
+
+	BeforeEach(func() {
+		sink = &fakeSink{written: make(chan string, 10)}
+		asyncSink = NewAsyncSink(sink)
+		stop = make(chan struct{})
+	})
+
+	AfterEach(func() {
+		close(stop)
+	})
+
+	Describe("Write", func() {
+		It("does not write to the sink until it runs", func() {
+			Expect(asyncSink.Write([]byte("fake-content"))).To(Succeed())
+			Consistently(sink.written).ShouldNot(Receive())
+		})
+
+		It("only keeps the latest content", func() {
+			Expect(asyncSink.Write([]byte("fake-content-1"))).To(Succeed())
+			Expect(asyncSink.Write([]byte("fake-content-2"))).To(Succeed())
+
+			go asyncSink.Run(stop)
+			Eventually(sink.written).Should(Receive(Equal("fake-content-2")))
+			Consistently(sink.written).ShouldNot(Receive())
+		})
+
+		Context("when the sink fails", func() {
+			BeforeEach(func() {
+				sink.err = errors.New("fake-error")
+			})
+
+			It("does not return an error", func() {
+				go asyncSink.Run(stop)
+				Expect(asyncSink.Write([]byte("fake-content"))).To(Succeed())
+				Eventually(sink.written).Should(Receive(Equal("fake-content")))
+			})
+		})
+	})
+
+	Describe("Run", func() {
+		It("writes the queued contents to the sink", func() {
+			go asyncSink.Run(stop)
+
+			Expect(asyncSink.Write([]byte("fake-content-1"))).To(Succeed())
+			Eventually(sink.written).Should(Receive(Equal("fake-content-1")))
+			Expect(asyncSink.Write([]byte("fake-content-2"))).To(Succeed())
+			Eventually(sink.written).Should(Receive(Equal("fake-content-2")))
+		})
+	})
+
+	Describe("WriteSigned", func() {
+		var signedSink *fakeSignedSink
+
+		BeforeEach(func() {
+			signedSink = &fakeSignedSink{fakeSink: fakeSink{written: make(chan string, 10)}, signatures: make(chan string, 10)}
+			asyncSink = NewAsyncSink(signedSink)
+		})
+
+		It("writes the content with its signature to the sink", func() {
+			go asyncSink.Run(stop)
+
+			Expect(asyncSink.WriteSigned([]byte("fake-content"), "fake-signature")).To(Succeed())
+			Eventually(signedSink.written).Should(Receive(Equal("fake-content")))
+			Expect(signedSink.signatures).To(Receive(Equal("fake-signature")))
+		})
+	})
+})
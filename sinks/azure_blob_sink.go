@@ -0,0 +1,64 @@
+package sinks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+const azureStorageVersion = "2019-02-02"
+
+type AzureBlobSink struct {
+	blobURL    string
+	httpClient *http.Client
+}
+
+func NewAzureBlobSink(blobURL string, httpClient *http.Client) *AzureBlobSink {
+	return &AzureBlobSink{blobURL: blobURL, httpClient: httpClient}
+}
+
+func (s *AzureBlobSink) Write(content []byte) error {
+	return s.WriteSigned(content, "")
+}
+
+// WriteSigned uploads the content with its signature as the `hmac_sha256`
+// blob metadata.
+func (s *AzureBlobSink) WriteSigned(content []byte, signature string) error {
+	req, err := http.NewRequest("PUT", s.blobURL, bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("Error creating Azure Blob request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("X-Ms-Version", azureStorageVersion)
+	if signature != "" {
+		req.Header.Set("X-Ms-Meta-Hmac_sha256", signature)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("Error uploading to Azure Blob `%s`: %v", s.redactedURL(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Error uploading to Azure Blob `%s`: %s: %s", s.redactedURL(), resp.Status, string(body))
+	}
+
+	return nil
+}
+
+func (s *AzureBlobSink) redactedURL() string {
+	u, err := url.Parse(s.blobURL)
+	if err != nil {
+		return "<invalid url>"
+	}
+	u.RawQuery = ""
+	return u.String()
+}
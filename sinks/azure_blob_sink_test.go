@@ -0,0 +1,89 @@
+package sinks_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/sinks"
+)
+
+var _ = Describe("AzureBlobSink", func() {
+	var (
+		err           error
+		server        *httptest.Server
+		responseCode  int
+		requests      chan *http.Request
+		bodies        chan string
+		azureBlobSink *AzureBlobSink
+		content       = []byte(`[{"targets":["1.2.3.4"]}]`)
+	)
+
+	BeforeEach(func() {
+		responseCode = http.StatusCreated
+		requests = make(chan *http.Request, 1)
+		bodies = make(chan string, 1)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			requests <- r
+			bodies <- string(body)
+			w.WriteHeader(responseCode)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		azureBlobSink = NewAzureBlobSink(server.URL+"/fake-container/targets.json?sig=fake-signature", http.DefaultClient)
+	})
+
+	Describe("Write", func() {
+		JustBeforeEach(func() {
+			err = azureBlobSink.Write(content)
+		})
+
+		It("uploads the content as a block blob", func() {
+			Expect(err).ToNot(HaveOccurred())
+
+			var request *http.Request
+			Eventually(requests).Should(Receive(&request))
+			Expect(request.Method).To(Equal("PUT"))
+			Expect(request.URL.Path).To(Equal("/fake-container/targets.json"))
+			Expect(request.URL.Query().Get("sig")).To(Equal("fake-signature"))
+			Expect(request.Header.Get("X-Ms-Blob-Type")).To(Equal("BlockBlob"))
+			Eventually(bodies).Should(Receive(Equal(string(content))))
+		})
+
+		Context("when the upload fails", func() {
+			BeforeEach(func() {
+				responseCode = http.StatusForbidden
+			})
+
+			It("returns an error without the shared access signature", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("403 Forbidden"))
+				Expect(err.Error()).ToNot(ContainSubstring("fake-signature"))
+			})
+		})
+	})
+
+	Describe("WriteSigned", func() {
+		JustBeforeEach(func() {
+			err = azureBlobSink.WriteSigned(content, "fake-hmac-sha256")
+		})
+
+		It("uploads the signature as blob metadata", func() {
+			Expect(err).ToNot(HaveOccurred())
+
+			var request *http.Request
+			Eventually(requests).Should(Receive(&request))
+			Expect(request.Header.Get("X-Ms-Meta-Hmac_sha256")).To(Equal("fake-hmac-sha256"))
+			Eventually(bodies).Should(Receive(Equal(string(content))))
+		})
+	})
+})
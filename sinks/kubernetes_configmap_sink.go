@@ -0,0 +1,469 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+)
+
+const (
+	kubernetesServiceAccountDir                = "/var/run/secrets/kubernetes.io/serviceaccount"
+	KubernetesLastRemovedDeploymentsAnnotation = "bosh-exporter/last-removed-deployments"
+	KubernetesDeploymentsRemovedReason         = "DeploymentsRemoved"
+	boshDeploymentMetaLabel                    = "__meta_bosh_deployment"
+	kubernetesWatchRetryInterval               = 5 * time.Second
+)
+
+type KubernetesConfig struct {
+	APIURL    string
+	Token     string
+	Namespace string
+	Name      string
+	Key       string
+}
+
+type KubernetesConfigMapSink struct {
+	config              KubernetesConfig
+	httpClient          *http.Client
+	clock               clock.Clock
+	previousDeployments map[string]bool
+	lastContent         []byte
+	previousContent     []byte
+	tampered            uint64
+	writeErrors         uint64
+	mu                  *sync.Mutex
+}
+
+type kubernetesObjectMeta struct {
+	Name         string            `json:"name,omitempty"`
+	GenerateName string            `json:"generateName,omitempty"`
+	Namespace    string            `json:"namespace,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type kubernetesConfigMap struct {
+	APIVersion string               `json:"apiVersion,omitempty"`
+	Kind       string               `json:"kind,omitempty"`
+	Metadata   kubernetesObjectMeta `json:"metadata"`
+	Data       map[string]string    `json:"data"`
+}
+
+type kubernetesWatchEvent struct {
+	Type   string              `json:"type"`
+	Object kubernetesConfigMap `json:"object"`
+}
+
+type kubernetesObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+}
+
+type kubernetesEventSource struct {
+	Component string `json:"component"`
+}
+
+type kubernetesEvent struct {
+	APIVersion     string                    `json:"apiVersion"`
+	Kind           string                    `json:"kind"`
+	Metadata       kubernetesObjectMeta      `json:"metadata"`
+	InvolvedObject kubernetesObjectReference `json:"involvedObject"`
+	Reason         string                    `json:"reason"`
+	Message        string                    `json:"message"`
+	Type           string                    `json:"type"`
+	Count          int                       `json:"count"`
+	FirstTimestamp time.Time                 `json:"firstTimestamp"`
+	LastTimestamp  time.Time                 `json:"lastTimestamp"`
+	Source         kubernetesEventSource     `json:"source"`
+}
+
+func InClusterKubernetesConfig(namespace string, name string, key string, proxy func(*http.Request) (*url.URL, error)) (KubernetesConfig, *http.Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return KubernetesConfig{}, nil, errors.New("Unable to load in-cluster Kubernetes configuration, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be defined")
+	}
+
+	token, err := ioutil.ReadFile(path.Join(kubernetesServiceAccountDir, "token"))
+	if err != nil {
+		return KubernetesConfig{}, nil, fmt.Errorf("Error reading Kubernetes service account token: %v", err)
+	}
+
+	caCert, err := ioutil.ReadFile(path.Join(kubernetesServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return KubernetesConfig{}, nil, fmt.Errorf("Error reading Kubernetes service account CA certificate: %v", err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caCert) {
+		return KubernetesConfig{}, nil, errors.New("Error parsing Kubernetes service account CA certificate")
+	}
+
+	if namespace == "" {
+		podNamespace, err := ioutil.ReadFile(path.Join(kubernetesServiceAccountDir, "namespace"))
+		if err != nil {
+			return KubernetesConfig{}, nil, fmt.Errorf("Error reading Kubernetes service account namespace: %v", err)
+		}
+		namespace = strings.TrimSpace(string(podNamespace))
+	}
+
+	config := KubernetesConfig{
+		APIURL:    "https://" + net.JoinHostPort(host, port),
+		Token:     strings.TrimSpace(string(token)),
+		Namespace: namespace,
+		Name:      name,
+		Key:       key,
+	}
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			Proxy:           proxy,
+			TLSClientConfig: &tls.Config{RootCAs: rootCAs},
+		},
+	}
+
+	return config, httpClient, nil
+}
+
+func NewKubernetesConfigMapSink(config KubernetesConfig, httpClient *http.Client) *KubernetesConfigMapSink {
+	config.APIURL = strings.TrimSuffix(config.APIURL, "/")
+
+	return &KubernetesConfigMapSink{config: config, httpClient: httpClient, clock: clock.Real, mu: &sync.Mutex{}}
+}
+
+// SetClock replaces the clock dating the Events.
+func (s *KubernetesConfigMapSink) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
+func (s *KubernetesConfigMapSink) Write(content []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.write(content)
+	if err != nil {
+		atomic.AddUint64(&s.writeErrors, 1)
+	}
+
+	return err
+}
+
+func (s *KubernetesConfigMapSink) write(content []byte) error {
+	deployments := targetGroupsDeployments(content)
+
+	if s.previousDeployments == nil {
+		previousDeployments, err := s.readDeployments()
+		if err != nil {
+			return err
+		}
+		s.previousDeployments = previousDeployments
+	}
+
+	removedDeployments := []string{}
+	for deployment := range s.previousDeployments {
+		if !deployments[deployment] {
+			removedDeployments = append(removedDeployments, deployment)
+		}
+	}
+	sort.Strings(removedDeployments)
+
+	if err := s.writeConfigMap(content, removedDeployments); err != nil {
+		return err
+	}
+	s.previousDeployments = deployments
+	if !bytes.Equal(content, s.lastContent) {
+		s.previousContent = s.lastContent
+		s.lastContent = content
+	}
+
+	if len(removedDeployments) > 0 {
+		return s.createDeploymentsRemovedEvent(removedDeployments)
+	}
+
+	return nil
+}
+
+// Watch restores the last written content whenever the ConfigMap is modified
+// or deleted by someone else, until stop is closed.
+func (s *KubernetesConfigMapSink) Watch(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	for {
+		if err := s.watch(ctx); err != nil && ctx.Err() == nil {
+			log.Errorf("Error watching Kubernetes ConfigMap `%s/%s`: %v", s.config.Namespace, s.config.Name, err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(kubernetesWatchRetryInterval):
+		}
+	}
+}
+
+func (s *KubernetesConfigMapSink) Tampered() uint64 {
+	return atomic.LoadUint64(&s.tampered)
+}
+
+func (s *KubernetesConfigMapSink) WriteErrors() uint64 {
+	return atomic.LoadUint64(&s.writeErrors)
+}
+
+func (s *KubernetesConfigMapSink) Namespace() string {
+	return s.config.Namespace
+}
+
+func (s *KubernetesConfigMapSink) watch(ctx context.Context) error {
+	url := fmt.Sprintf("%s/api/v1/namespaces/%s/configmaps?watch=true&fieldSelector=metadata.name%%3D%s", s.config.APIURL, s.config.Namespace, s.config.Name)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if s.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.Token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, string(body))
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event kubernetesWatchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		switch event.Type {
+		case "ADDED", "MODIFIED", "DELETED":
+			if err := s.restore(event); err != nil {
+				log.Error(err)
+			}
+		case "ERROR":
+			return nil
+		}
+	}
+}
+
+func (s *KubernetesConfigMapSink) restore(event kubernetesWatchEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastContent == nil {
+		return nil
+	}
+
+	if event.Type != "DELETED" {
+		data, ok := event.Object.Data[s.config.Key]
+		if ok && (data == string(s.lastContent) || (s.previousContent != nil && data == string(s.previousContent))) {
+			return nil
+		}
+	}
+
+	atomic.AddUint64(&s.tampered, 1)
+	log.Warnf("Kubernetes ConfigMap `%s/%s` was %s outside of the exporter, restoring its content", s.config.Namespace, s.config.Name, strings.ToLower(event.Type))
+
+	return s.writeConfigMap(s.lastContent, nil)
+}
+
+func (s *KubernetesConfigMapSink) configMapURL() string {
+	return fmt.Sprintf("%s/api/v1/namespaces/%s/configmaps/%s", s.config.APIURL, s.config.Namespace, s.config.Name)
+}
+
+func (s *KubernetesConfigMapSink) readDeployments() (map[string]bool, error) {
+	resp, err := s.do("GET", s.configMapURL(), "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading Kubernetes ConfigMap `%s/%s`: %v", s.config.Namespace, s.config.Name, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return map[string]bool{}, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("Error reading Kubernetes ConfigMap `%s/%s`: %s: %s", s.config.Namespace, s.config.Name, resp.Status, string(body))
+	}
+
+	var configMap kubernetesConfigMap
+	if err := json.Unmarshal(body, &configMap); err != nil {
+		return nil, fmt.Errorf("Error parsing Kubernetes ConfigMap `%s/%s`: %v", s.config.Namespace, s.config.Name, err)
+	}
+
+	return targetGroupsDeployments([]byte(configMap.Data[s.config.Key])), nil
+}
+
+func (s *KubernetesConfigMapSink) writeConfigMap(content []byte, removedDeployments []string) error {
+	configMap := kubernetesConfigMap{
+		Metadata: kubernetesObjectMeta{},
+		Data:     map[string]string{s.config.Key: string(content)},
+	}
+	if len(removedDeployments) > 0 {
+		configMap.Metadata.Annotations = map[string]string{
+			KubernetesLastRemovedDeploymentsAnnotation: strings.Join(removedDeployments, ","),
+		}
+	}
+
+	patch, err := json.Marshal(configMap)
+	if err != nil {
+		return fmt.Errorf("Error marshalling Kubernetes ConfigMap `%s/%s`: %v", s.config.Namespace, s.config.Name, err)
+	}
+
+	resp, err := s.do("PATCH", s.configMapURL(), "application/merge-patch+json", patch)
+	if err != nil {
+		return fmt.Errorf("Error writing Kubernetes ConfigMap `%s/%s`: %v", s.config.Namespace, s.config.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return s.createConfigMap(configMap)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Error writing Kubernetes ConfigMap `%s/%s`: %s: %s", s.config.Namespace, s.config.Name, resp.Status, string(body))
+	}
+
+	return nil
+}
+
+func (s *KubernetesConfigMapSink) createConfigMap(configMap kubernetesConfigMap) error {
+	configMap.APIVersion = "v1"
+	configMap.Kind = "ConfigMap"
+	configMap.Metadata.Name = s.config.Name
+	configMap.Metadata.Namespace = s.config.Namespace
+
+	return s.create("configmaps", configMap, fmt.Sprintf("ConfigMap `%s/%s`", s.config.Namespace, s.config.Name))
+}
+
+func (s *KubernetesConfigMapSink) createDeploymentsRemovedEvent(removedDeployments []string) error {
+	now := s.clock.Now().UTC()
+	event := kubernetesEvent{
+		APIVersion: "v1",
+		Kind:       "Event",
+		Metadata: kubernetesObjectMeta{
+			GenerateName: s.config.Name + ".",
+			Namespace:    s.config.Namespace,
+		},
+		InvolvedObject: kubernetesObjectReference{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Name:       s.config.Name,
+			Namespace:  s.config.Namespace,
+		},
+		Reason:         KubernetesDeploymentsRemovedReason,
+		Message:        fmt.Sprintf("BOSH deployments removed from the Service Discovery output: %s", strings.Join(removedDeployments, ", ")),
+		Type:           "Warning",
+		Count:          1,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Source:         kubernetesEventSource{Component: "bosh_exporter"},
+	}
+
+	return s.create("events", event, fmt.Sprintf("Event for ConfigMap `%s/%s`", s.config.Namespace, s.config.Name))
+}
+
+func (s *KubernetesConfigMapSink) create(resource string, object interface{}, description string) error {
+	return kubernetesCreate(s.httpClient, s.config, resource, object, description)
+}
+
+func (s *KubernetesConfigMapSink) do(method string, url string, contentType string, body []byte) (*http.Response, error) {
+	return kubernetesDo(s.httpClient, s.config, method, url, contentType, body)
+}
+
+func kubernetesCreate(httpClient *http.Client, config KubernetesConfig, resource string, object interface{}, description string) error {
+	body, err := json.Marshal(object)
+	if err != nil {
+		return fmt.Errorf("Error marshalling Kubernetes %s: %v", description, err)
+	}
+
+	resp, err := kubernetesDo(httpClient, config, "POST", fmt.Sprintf("%s/api/v1/namespaces/%s/%s", config.APIURL, config.Namespace, resource), "application/json", body)
+	if err != nil {
+		return fmt.Errorf("Error creating Kubernetes %s: %v", description, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Error creating Kubernetes %s: %s: %s", description, resp.Status, string(respBody))
+	}
+
+	return nil
+}
+
+func kubernetesDo(httpClient *http.Client, config KubernetesConfig, method string, url string, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+config.Token)
+	}
+
+	return httpClient.Do(req)
+}
+
+func targetGroupsDeployments(content []byte) map[string]bool {
+	deployments := map[string]bool{}
+
+	var targetGroups []struct {
+		Labels map[string]string `json:"labels"`
+	}
+	if err := json.Unmarshal(content, &targetGroups); err != nil {
+		var targetGroupsWithMetadata struct {
+			TargetGroups json.RawMessage `json:"target_groups"`
+		}
+		if err := json.Unmarshal(content, &targetGroupsWithMetadata); err != nil {
+			return deployments
+		}
+		if err := json.Unmarshal(targetGroupsWithMetadata.TargetGroups, &targetGroups); err != nil {
+			return deployments
+		}
+	}
+
+	for _, targetGroup := range targetGroups {
+		if deployment := targetGroup.Labels[boshDeploymentMetaLabel]; deployment != "" {
+			deployments[deployment] = true
+		}
+	}
+
+	return deployments
+}
@@ -0,0 +1,248 @@
+package sinks_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/sinks"
+)
+
+type kubernetesRequest struct {
+	Method      string
+	Path        string
+	ContentType string
+	Auth        string
+	Body        map[string]interface{}
+}
+
+var _ = Describe("KubernetesConfigMapSink", func() {
+	var (
+		err                     error
+		server                  *httptest.Server
+		existingConfigMap       string
+		watchEvents             string
+		patchResponseCode       int
+		requests                chan kubernetesRequest
+		kubernetesConfigMapSink *KubernetesConfigMapSink
+		content                 = []byte(`[{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"cf"}}]`)
+	)
+
+	BeforeEach(func() {
+		existingConfigMap = ""
+		watchEvents = ""
+		patchResponseCode = http.StatusOK
+		requests = make(chan kubernetesRequest, 10)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			request := kubernetesRequest{
+				Method:      r.Method,
+				Path:        r.URL.Path,
+				ContentType: r.Header.Get("Content-Type"),
+				Auth:        r.Header.Get("Authorization"),
+			}
+			_ = json.Unmarshal(body, &request.Body)
+			requests <- request
+
+			switch {
+			case r.Method == "GET" && r.URL.Query().Get("watch") == "true":
+				w.Write([]byte(watchEvents))
+			case r.Method == "GET" && existingConfigMap == "":
+				w.WriteHeader(http.StatusNotFound)
+			case r.Method == "GET":
+				w.Write([]byte(existingConfigMap))
+			case r.Method == "PATCH":
+				w.WriteHeader(patchResponseCode)
+			default:
+				w.WriteHeader(http.StatusCreated)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		kubernetesConfigMapSink = NewKubernetesConfigMapSink(
+			KubernetesConfig{
+				APIURL:    server.URL,
+				Token:     "fake-token",
+				Namespace: "monitoring",
+				Name:      "bosh-targets",
+				Key:       "bosh_target_groups.json",
+			},
+			http.DefaultClient,
+		)
+		err = kubernetesConfigMapSink.Write(content)
+	})
+
+	Describe("Write", func() {
+		It("patches the ConfigMap data", func() {
+			Expect(err).ToNot(HaveOccurred())
+
+			var request kubernetesRequest
+			Eventually(requests).Should(Receive(&request))
+			Expect(request.Method).To(Equal("GET"))
+			Expect(request.Path).To(Equal("/api/v1/namespaces/monitoring/configmaps/bosh-targets"))
+			Expect(request.Auth).To(Equal("Bearer fake-token"))
+
+			Eventually(requests).Should(Receive(&request))
+			Expect(request.Method).To(Equal("PATCH"))
+			Expect(request.Path).To(Equal("/api/v1/namespaces/monitoring/configmaps/bosh-targets"))
+			Expect(request.ContentType).To(Equal("application/merge-patch+json"))
+			Expect(request.Body["data"]).To(Equal(map[string]interface{}{"bosh_target_groups.json": string(content)}))
+			Expect(request.Body["metadata"]).To(BeEmpty())
+
+			Consistently(requests).ShouldNot(Receive())
+		})
+
+		Context("when the ConfigMap does not exist", func() {
+			BeforeEach(func() {
+				patchResponseCode = http.StatusNotFound
+			})
+
+			It("creates the ConfigMap", func() {
+				Expect(err).ToNot(HaveOccurred())
+
+				var request kubernetesRequest
+				Eventually(requests).Should(Receive())
+				Eventually(requests).Should(Receive())
+				Eventually(requests).Should(Receive(&request))
+				Expect(request.Method).To(Equal("POST"))
+				Expect(request.Path).To(Equal("/api/v1/namespaces/monitoring/configmaps"))
+				Expect(request.Body["kind"]).To(Equal("ConfigMap"))
+				Expect(request.Body["metadata"]).To(HaveKeyWithValue("name", "bosh-targets"))
+				Expect(request.Body["data"]).To(Equal(map[string]interface{}{"bosh_target_groups.json": string(content)}))
+			})
+		})
+
+		Context("when the patch fails", func() {
+			BeforeEach(func() {
+				patchResponseCode = http.StatusForbidden
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("403 Forbidden"))
+			})
+		})
+
+		Context("when deployments disappeared since the previous write", func() {
+			BeforeEach(func() {
+				existingConfigMap = `{"data":{"bosh_target_groups.json":"[{\"targets\":[\"1.2.3.4\"],\"labels\":{\"__meta_bosh_deployment\":\"cf\"}},{\"targets\":[\"5.6.7.8\"],\"labels\":{\"__meta_bosh_deployment\":\"redis\"}}]"}}`
+			})
+
+			It("annotates the ConfigMap and creates an Event", func() {
+				Expect(err).ToNot(HaveOccurred())
+
+				var request kubernetesRequest
+				Eventually(requests).Should(Receive())
+				Eventually(requests).Should(Receive(&request))
+				Expect(request.Method).To(Equal("PATCH"))
+				Expect(request.Body["metadata"]).To(Equal(map[string]interface{}{
+					"annotations": map[string]interface{}{KubernetesLastRemovedDeploymentsAnnotation: "redis"},
+				}))
+
+				Eventually(requests).Should(Receive(&request))
+				Expect(request.Method).To(Equal("POST"))
+				Expect(request.Path).To(Equal("/api/v1/namespaces/monitoring/events"))
+				Expect(request.Body["reason"]).To(Equal(KubernetesDeploymentsRemovedReason))
+				Expect(request.Body["message"]).To(ContainSubstring("redis"))
+				Expect(request.Body["involvedObject"]).To(HaveKeyWithValue("kind", "ConfigMap"))
+			})
+
+			Context("and the content is written again", func() {
+				JustBeforeEach(func() {
+					Eventually(requests).Should(Receive())
+					Eventually(requests).Should(Receive())
+					Eventually(requests).Should(Receive())
+
+					err = kubernetesConfigMapSink.Write(content)
+				})
+
+				It("does not read the ConfigMap nor create a new Event", func() {
+					Expect(err).ToNot(HaveOccurred())
+
+					var request kubernetesRequest
+					Eventually(requests).Should(Receive(&request))
+					Expect(request.Method).To(Equal("PATCH"))
+					Expect(request.Body["metadata"]).To(BeEmpty())
+					Consistently(requests).ShouldNot(Receive())
+				})
+			})
+		})
+	})
+
+	Describe("Watch", func() {
+		var (
+			stop chan struct{}
+		)
+
+		JustBeforeEach(func() {
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(requests).Should(Receive())
+			Eventually(requests).Should(Receive())
+
+			stop = make(chan struct{})
+			go kubernetesConfigMapSink.Watch(stop)
+		})
+
+		AfterEach(func() {
+			close(stop)
+		})
+
+		Context("when the ConfigMap is deleted", func() {
+			BeforeEach(func() {
+				watchEvents = `{"type":"ADDED","object":{"metadata":{"name":"bosh-targets"},"data":{"bosh_target_groups.json":"[{\"targets\":[\"1.2.3.4\"],\"labels\":{\"__meta_bosh_deployment\":\"cf\"}}]"}}}
+{"type":"DELETED","object":{"metadata":{"name":"bosh-targets"}}}
+`
+			})
+
+			It("restores the ConfigMap content", func() {
+				var request kubernetesRequest
+				Eventually(requests).Should(Receive(&request))
+				Expect(request.Method).To(Equal("GET"))
+				Expect(request.Path).To(Equal("/api/v1/namespaces/monitoring/configmaps"))
+
+				Eventually(requests).Should(Receive(&request))
+				Expect(request.Method).To(Equal("PATCH"))
+				Expect(request.Body["data"]).To(Equal(map[string]interface{}{"bosh_target_groups.json": string(content)}))
+				Expect(kubernetesConfigMapSink.Tampered()).To(Equal(uint64(1)))
+			})
+		})
+
+		Context("when the ConfigMap content is modified", func() {
+			BeforeEach(func() {
+				watchEvents = `{"type":"MODIFIED","object":{"metadata":{"name":"bosh-targets"},"data":{"bosh_target_groups.json":"[]"}}}
+`
+			})
+
+			It("restores the ConfigMap content", func() {
+				Eventually(requests).Should(Receive())
+
+				var request kubernetesRequest
+				Eventually(requests).Should(Receive(&request))
+				Expect(request.Method).To(Equal("PATCH"))
+				Expect(kubernetesConfigMapSink.Tampered()).To(Equal(uint64(1)))
+			})
+		})
+
+		Context("when only the exporter modified the ConfigMap", func() {
+			BeforeEach(func() {
+				watchEvents = `{"type":"MODIFIED","object":{"metadata":{"name":"bosh-targets","annotations":{"foo":"bar"}},"data":{"bosh_target_groups.json":"[{\"targets\":[\"1.2.3.4\"],\"labels\":{\"__meta_bosh_deployment\":\"cf\"}}]"}}}
+`
+			})
+
+			It("does not restore the ConfigMap content", func() {
+				Eventually(requests).Should(Receive())
+				Consistently(requests).ShouldNot(Receive())
+				Expect(kubernetesConfigMapSink.Tampered()).To(BeZero())
+			})
+		})
+	})
+})
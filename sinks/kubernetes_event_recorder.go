@@ -0,0 +1,95 @@
+package sinks
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+)
+
+const (
+	KubernetesEventTypeNormal  = "Normal"
+	KubernetesEventTypeWarning = "Warning"
+)
+
+// KubernetesEventRecorder creates Kubernetes Events on an object (usually the
+// exporter Pod) when the state tracked under a key changes, so problems show
+// up in `kubectl describe` without flooding the cluster with repeated Events.
+type KubernetesEventRecorder struct {
+	config     KubernetesConfig
+	kind       string
+	httpClient *http.Client
+	clock      clock.Clock
+	states     map[string]string
+	mu         *sync.Mutex
+}
+
+func NewKubernetesEventRecorder(config KubernetesConfig, kind string, httpClient *http.Client) *KubernetesEventRecorder {
+	config.APIURL = strings.TrimSuffix(config.APIURL, "/")
+
+	return &KubernetesEventRecorder{
+		config:     config,
+		kind:       kind,
+		httpClient: httpClient,
+		clock:      clock.Real,
+		states:     map[string]string{},
+		mu:         &sync.Mutex{},
+	}
+}
+
+// SetClock replaces the clock dating the Events.
+func (r *KubernetesEventRecorder) SetClock(clk clock.Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock = clk
+}
+
+// Transition records an Event when reason differs from the previous reason
+// recorded for key. A Normal first state is only remembered, as there is no
+// problem to recover from yet.
+func (r *KubernetesEventRecorder) Transition(key string, eventType string, reason string, message string) {
+	r.mu.Lock()
+	previousReason, known := r.states[key]
+	if previousReason == reason || (!known && eventType == KubernetesEventTypeNormal) {
+		r.states[key] = reason
+		r.mu.Unlock()
+		return
+	}
+	r.states[key] = reason
+	r.mu.Unlock()
+
+	if err := r.createEvent(eventType, reason, message); err != nil {
+		log.Errorf("%v", err)
+	}
+}
+
+func (r *KubernetesEventRecorder) createEvent(eventType string, reason string, message string) error {
+	now := r.clock.Now().UTC()
+	event := kubernetesEvent{
+		APIVersion: "v1",
+		Kind:       "Event",
+		Metadata: kubernetesObjectMeta{
+			GenerateName: r.config.Name + ".",
+			Namespace:    r.config.Namespace,
+		},
+		InvolvedObject: kubernetesObjectReference{
+			APIVersion: "v1",
+			Kind:       r.kind,
+			Name:       r.config.Name,
+			Namespace:  r.config.Namespace,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Count:          1,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Source:         kubernetesEventSource{Component: "bosh_exporter"},
+	}
+
+	return kubernetesCreate(r.httpClient, r.config, "events", event, fmt.Sprintf("%s Event for %s `%s/%s`", reason, r.kind, r.config.Namespace, r.config.Name))
+}
@@ -0,0 +1,100 @@
+package sinks_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/sinks"
+)
+
+var _ = Describe("KubernetesEventRecorder", func() {
+	var (
+		server                  *httptest.Server
+		requests                chan kubernetesRequest
+		kubernetesEventRecorder *KubernetesEventRecorder
+	)
+
+	BeforeEach(func() {
+		requests = make(chan kubernetesRequest, 10)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			request := kubernetesRequest{
+				Method: r.Method,
+				Path:   r.URL.Path,
+				Auth:   r.Header.Get("Authorization"),
+			}
+			_ = json.Unmarshal(body, &request.Body)
+			requests <- request
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		kubernetesEventRecorder = NewKubernetesEventRecorder(
+			KubernetesConfig{
+				APIURL:    server.URL,
+				Token:     "fake-token",
+				Namespace: "monitoring",
+				Name:      "bosh-exporter-0",
+			},
+			"Pod",
+			http.DefaultClient,
+		)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Transition", func() {
+		It("does not record an Event for a first Normal state", func() {
+			kubernetesEventRecorder.Transition("director", KubernetesEventTypeNormal, "DirectorReachable", "reachable")
+			Consistently(requests).ShouldNot(Receive())
+		})
+
+		It("records an Event on the involved object when the state changes", func() {
+			kubernetesEventRecorder.Transition("director", KubernetesEventTypeWarning, "DirectorUnreachable", "fake-message")
+
+			var request kubernetesRequest
+			Eventually(requests).Should(Receive(&request))
+			Expect(request.Method).To(Equal("POST"))
+			Expect(request.Path).To(Equal("/api/v1/namespaces/monitoring/events"))
+			Expect(request.Auth).To(Equal("Bearer fake-token"))
+			Expect(request.Body["reason"]).To(Equal("DirectorUnreachable"))
+			Expect(request.Body["message"]).To(Equal("fake-message"))
+			Expect(request.Body["type"]).To(Equal(KubernetesEventTypeWarning))
+			Expect(request.Body["involvedObject"]).To(HaveKeyWithValue("kind", "Pod"))
+			Expect(request.Body["involvedObject"]).To(HaveKeyWithValue("name", "bosh-exporter-0"))
+		})
+
+		It("does not record the same state twice", func() {
+			kubernetesEventRecorder.Transition("director", KubernetesEventTypeWarning, "DirectorUnreachable", "fake-message")
+			kubernetesEventRecorder.Transition("director", KubernetesEventTypeWarning, "DirectorUnreachable", "fake-message")
+
+			Eventually(requests).Should(Receive())
+			Consistently(requests).ShouldNot(Receive())
+		})
+
+		It("records the recovery", func() {
+			kubernetesEventRecorder.Transition("director", KubernetesEventTypeWarning, "DirectorUnreachable", "fake-message")
+			kubernetesEventRecorder.Transition("director", KubernetesEventTypeNormal, "DirectorReachable", "fake-message")
+
+			var request kubernetesRequest
+			Eventually(requests).Should(Receive())
+			Eventually(requests).Should(Receive(&request))
+			Expect(request.Body["reason"]).To(Equal("DirectorReachable"))
+			Expect(request.Body["type"]).To(Equal(KubernetesEventTypeNormal))
+		})
+
+		It("tracks every key independently", func() {
+			kubernetesEventRecorder.Transition("director", KubernetesEventTypeWarning, "DirectorUnreachable", "fake-message")
+			kubernetesEventRecorder.Transition("service_discovery", KubernetesEventTypeWarning, "ServiceDiscoveryWriteFailed", "fake-message")
+
+			Eventually(requests).Should(Receive())
+			Eventually(requests).Should(Receive())
+		})
+	})
+})
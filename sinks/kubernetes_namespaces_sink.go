@@ -0,0 +1,47 @@
+package sinks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/common/log"
+)
+
+// KubernetesNamespacesSink writes the same ConfigMap into several namespaces.
+// A failure in one namespace does not prevent the others from being written.
+type KubernetesNamespacesSink struct {
+	sinks []*KubernetesConfigMapSink
+}
+
+func NewKubernetesNamespacesSink(config KubernetesConfig, namespaces []string, httpClient *http.Client) *KubernetesNamespacesSink {
+	sinks := make([]*KubernetesConfigMapSink, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		namespaceConfig := config
+		namespaceConfig.Namespace = namespace
+		sinks = append(sinks, NewKubernetesConfigMapSink(namespaceConfig, httpClient))
+	}
+
+	return &KubernetesNamespacesSink{sinks: sinks}
+}
+
+func (s *KubernetesNamespacesSink) Write(content []byte) error {
+	failedNamespaces := []string{}
+	for _, sink := range s.sinks {
+		if err := sink.Write(content); err != nil {
+			log.Errorf("Error writing the Service Discovery output to Kubernetes namespace `%s`: %v", sink.Namespace(), err)
+			failedNamespaces = append(failedNamespaces, sink.Namespace())
+		}
+	}
+
+	if len(failedNamespaces) > 0 {
+		return errors.New(fmt.Sprintf("Error writing Kubernetes ConfigMap in %d of %d namespaces: %s", len(failedNamespaces), len(s.sinks), strings.Join(failedNamespaces, ", ")))
+	}
+
+	return nil
+}
+
+func (s *KubernetesNamespacesSink) Sinks() []*KubernetesConfigMapSink {
+	return s.sinks
+}
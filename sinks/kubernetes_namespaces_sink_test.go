@@ -0,0 +1,95 @@
+package sinks_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/sinks"
+)
+
+var _ = Describe("KubernetesNamespacesSink", func() {
+	var (
+		err                      error
+		server                   *httptest.Server
+		failingNamespace         string
+		mu                       sync.Mutex
+		patchedPaths             []string
+		kubernetesNamespacesSink *KubernetesNamespacesSink
+		content                  = []byte(`[{"targets":["1.2.3.4"],"labels":{"__meta_bosh_deployment":"cf"}}]`)
+	)
+
+	BeforeEach(func() {
+		failingNamespace = ""
+		patchedPaths = []string{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == "GET":
+				w.WriteHeader(http.StatusNotFound)
+			case failingNamespace != "" && strings.HasPrefix(r.URL.Path, "/api/v1/namespaces/"+failingNamespace+"/"):
+				w.WriteHeader(http.StatusForbidden)
+			default:
+				mu.Lock()
+				patchedPaths = append(patchedPaths, r.URL.Path)
+				mu.Unlock()
+				w.WriteHeader(http.StatusOK)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		kubernetesNamespacesSink = NewKubernetesNamespacesSink(
+			KubernetesConfig{
+				APIURL: server.URL,
+				Token:  "fake-token",
+				Name:   "bosh-targets",
+				Key:    "bosh_target_groups.json",
+			},
+			[]string{"monitoring", "observability"},
+			http.DefaultClient,
+		)
+		err = kubernetesNamespacesSink.Write(content)
+	})
+
+	Describe("Write", func() {
+		It("writes the ConfigMap in every namespace", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(patchedPaths).To(Equal([]string{
+				"/api/v1/namespaces/monitoring/configmaps/bosh-targets",
+				"/api/v1/namespaces/observability/configmaps/bosh-targets",
+			}))
+		})
+
+		Context("when writing in one namespace fails", func() {
+			BeforeEach(func() {
+				failingNamespace = "monitoring"
+			})
+
+			It("still writes the ConfigMap in the other namespaces", func() {
+				Expect(patchedPaths).To(Equal([]string{"/api/v1/namespaces/observability/configmaps/bosh-targets"}))
+			})
+
+			It("returns an error naming the failing namespace", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("1 of 2 namespaces: monitoring"))
+			})
+
+			It("counts the write errors per namespace", func() {
+				sinks := kubernetesNamespacesSink.Sinks()
+				Expect(sinks).To(HaveLen(2))
+				Expect(sinks[0].Namespace()).To(Equal("monitoring"))
+				Expect(sinks[0].WriteErrors()).To(Equal(uint64(1)))
+				Expect(sinks[1].Namespace()).To(Equal("observability"))
+				Expect(sinks[1].WriteErrors()).To(BeZero())
+			})
+		})
+	})
+})
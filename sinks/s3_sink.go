@@ -0,0 +1,186 @@
+package sinks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+)
+
+const (
+	s3SigningAlgorithm = "AWS4-HMAC-SHA256"
+	s3DateFormat       = "20060102"
+	s3TimeFormat       = "20060102T150405Z"
+)
+
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Key             string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Versioned       bool
+}
+
+type S3Sink struct {
+	config     S3Config
+	httpClient *http.Client
+	clock      clock.Clock
+}
+
+func NewS3Sink(config S3Config, httpClient *http.Client) *S3Sink {
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	return &S3Sink{config: config, httpClient: httpClient, clock: clock.Real}
+}
+
+// SetClock replaces the clock dating the versioned keys and the request
+// signatures.
+func (s *S3Sink) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
+func (s *S3Sink) Write(content []byte) error {
+	return s.WriteSigned(content, "")
+}
+
+// WriteSigned uploads the content with its signature as the
+// `x-amz-meta-hmac-sha256` object metadata.
+func (s *S3Sink) WriteSigned(content []byte, signature string) error {
+	now := s.clock.Now().UTC()
+
+	if s.config.Versioned {
+		versionedKey := fmt.Sprintf("%s.%s", s.config.Key, now.Format(s3TimeFormat))
+		if err := s.putObject(versionedKey, content, signature, now); err != nil {
+			return err
+		}
+	}
+
+	return s.putObject(s.config.Key, content, signature, now)
+}
+
+func (s *S3Sink) putObject(key string, content []byte, signature string, now time.Time) error {
+	objectPath := "/" + s.config.Bucket + "/" + strings.TrimPrefix(key, "/")
+	objectURL, err := url.Parse(s.config.Endpoint)
+	if err != nil {
+		return fmt.Errorf("Error parsing S3 endpoint `%s`: %v", s.config.Endpoint, err)
+	}
+	objectURL.Path = objectURL.Path + objectPath
+	objectURL.RawPath = s3EscapePath(objectURL.Path)
+
+	req, err := http.NewRequest("PUT", objectURL.String(), bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("Error creating S3 request for `%s`: %v", key, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		req.Header.Set("X-Amz-Meta-Hmac-Sha256", signature)
+	}
+	s.sign(req, content, now)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error uploading `%s` to S3 bucket `%s`: %v", key, s.config.Bucket, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Error uploading `%s` to S3 bucket `%s`: %s: %s", key, s.config.Bucket, resp.Status, string(body))
+	}
+
+	return nil
+}
+
+func (s *S3Sink) sign(req *http.Request, content []byte, now time.Time) {
+	payloadHash := sha256Hex(content)
+	amzDate := now.Format(s3TimeFormat)
+	scope := strings.Join([]string{now.Format(s3DateFormat), s.config.Region, "s3", "aws4_request"}, "/")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.config.SessionToken)
+	}
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if hmacSignature := req.Header.Get("X-Amz-Meta-Hmac-Sha256"); hmacSignature != "" {
+		signedHeaders = append(signedHeaders, "x-amz-meta-hmac-sha256")
+		canonicalHeaders += "x-amz-meta-hmac-sha256:" + hmacSignature + "\n"
+	}
+	if s.config.SessionToken != "" {
+		signedHeaders = append(signedHeaders, "x-amz-security-token")
+		canonicalHeaders += "x-amz-security-token:" + s.config.SessionToken + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		s3SigningAlgorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), now.Format(s3DateFormat))
+	signingKey = hmacSHA256(signingKey, s.config.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningAlgorithm,
+		s.config.AccessKeyID,
+		scope,
+		strings.Join(signedHeaders, ";"),
+		signature,
+	))
+}
+
+func s3EscapePath(path string) string {
+	var escaped strings.Builder
+	for _, b := range []byte(path) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || b == '/' {
+			escaped.WriteByte(b)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, content string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
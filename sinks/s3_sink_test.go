@@ -0,0 +1,160 @@
+package sinks_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/bosh_exporter/clock"
+
+	. "github.com/bosh-prometheus/bosh_exporter/sinks"
+)
+
+type s3Request struct {
+	method  string
+	path    string
+	headers http.Header
+	body    string
+}
+
+var _ = Describe("S3Sink", func() {
+	var (
+		err          error
+		server       *httptest.Server
+		responseCode int
+		requests     chan s3Request
+		config       S3Config
+		s3Sink       *S3Sink
+		content      = []byte(`[{"targets":["1.2.3.4"]}]`)
+	)
+
+	BeforeEach(func() {
+		responseCode = http.StatusOK
+		requests = make(chan s3Request, 2)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			requests <- s3Request{method: r.Method, path: r.URL.EscapedPath(), headers: r.Header, body: string(body)}
+			w.WriteHeader(responseCode)
+		}))
+
+		config = S3Config{
+			Endpoint:        server.URL,
+			Region:          "fake-region",
+			Bucket:          "fake-bucket",
+			Key:             "prometheus/bosh target groups.json",
+			AccessKeyID:     "fake-access-key-id",
+			SecretAccessKey: "fake-secret-access-key",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		s3Sink = NewS3Sink(config, http.DefaultClient)
+		s3Sink.SetClock(clock.NewFakeClock(time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)))
+	})
+
+	Describe("Write", func() {
+		JustBeforeEach(func() {
+			err = s3Sink.Write(content)
+		})
+
+		It("uploads the content to the bucket key", func() {
+			Expect(err).ToNot(HaveOccurred())
+
+			var request s3Request
+			Eventually(requests).Should(Receive(&request))
+			Expect(request.method).To(Equal("PUT"))
+			Expect(request.path).To(Equal("/fake-bucket/prometheus/bosh%20target%20groups.json"))
+			Expect(request.body).To(Equal(string(content)))
+			Consistently(requests).ShouldNot(Receive())
+		})
+
+		It("signs the request", func() {
+			var request s3Request
+			Eventually(requests).Should(Receive(&request))
+			contentSHA256 := sha256.Sum256(content)
+			Expect(request.headers.Get("X-Amz-Content-Sha256")).To(Equal(hex.EncodeToString(contentSHA256[:])))
+			Expect(request.headers.Get("X-Amz-Date")).To(Equal("20261012T100000Z"))
+			Expect(request.headers.Get("Authorization")).To(MatchRegexp(
+				`^AWS4-HMAC-SHA256 Credential=fake-access-key-id/20261012/fake-region/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$`,
+			))
+		})
+
+		Context("when there is a session token", func() {
+			BeforeEach(func() {
+				config.SessionToken = "fake-session-token"
+			})
+
+			It("signs the session token header", func() {
+				var request s3Request
+				Eventually(requests).Should(Receive(&request))
+				Expect(request.headers.Get("X-Amz-Security-Token")).To(Equal("fake-session-token"))
+				Expect(request.headers.Get("Authorization")).To(ContainSubstring("SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,"))
+			})
+		})
+
+		Context("when versioned keys are enabled", func() {
+			BeforeEach(func() {
+				config.Versioned = true
+			})
+
+			It("uploads a versioned key before the latest key", func() {
+				Expect(err).ToNot(HaveOccurred())
+
+				var versioned, latest s3Request
+				Eventually(requests).Should(Receive(&versioned))
+				Expect(versioned.path).To(Equal("/fake-bucket/prometheus/bosh%20target%20groups.json.20261012T100000Z"))
+				Eventually(requests).Should(Receive(&latest))
+				Expect(latest.path).To(Equal("/fake-bucket/prometheus/bosh%20target%20groups.json"))
+			})
+		})
+
+		Context("when the upload fails", func() {
+			BeforeEach(func() {
+				responseCode = http.StatusForbidden
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("403 Forbidden"))
+			})
+		})
+	})
+
+	Describe("WriteSigned", func() {
+		JustBeforeEach(func() {
+			err = s3Sink.WriteSigned(content, "fake-hmac-sha256")
+		})
+
+		It("uploads the signature as object metadata", func() {
+			Expect(err).ToNot(HaveOccurred())
+
+			var request s3Request
+			Eventually(requests).Should(Receive(&request))
+			Expect(request.body).To(Equal(string(content)))
+			Expect(request.headers.Get("X-Amz-Meta-Hmac-Sha256")).To(Equal("fake-hmac-sha256"))
+			Expect(request.headers.Get("Authorization")).To(ContainSubstring("SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-meta-hmac-sha256,"))
+		})
+
+		Context("when there is a session token", func() {
+			BeforeEach(func() {
+				config.SessionToken = "fake-session-token"
+			})
+
+			It("signs the metadata and session token headers", func() {
+				var request s3Request
+				Eventually(requests).Should(Receive(&request))
+				Expect(request.headers.Get("Authorization")).To(ContainSubstring("SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-meta-hmac-sha256;x-amz-security-token,"))
+			})
+		})
+	})
+})
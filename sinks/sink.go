@@ -0,0 +1,22 @@
+package sinks
+
+type Sink interface {
+	Write(content []byte) error
+}
+
+// SignedSink is a Sink storing the hex encoded HMAC-SHA256 signature of the
+// content along with it, so both are replaced at once.
+type SignedSink interface {
+	Sink
+	WriteSigned(content []byte, signature string) error
+}
+
+// Write writes the content to the sink, along with its signature when the
+// sink stores signatures and the content is signed.
+func Write(sink Sink, content []byte, signature string) error {
+	if signedSink, ok := sink.(SignedSink); ok && signature != "" {
+		return signedSink.WriteSigned(content, signature)
+	}
+
+	return sink.Write(content)
+}
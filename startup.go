@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/bosh_exporter/environments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+// startupHandler serves the metrics with bosh_exporter_up 0 for the BOSH
+// Directors the exporter cannot reach yet at startup, so orchestrators do not
+// restart it during a Director maintenance, and hands over to the exporter
+// handler once every Director is reachable.
+type startupHandler struct {
+	metricsPath    string
+	upMetric       *prometheus.GaugeVec
+	metricsHandler http.Handler
+	handler        http.Handler
+	mu             *sync.RWMutex
+}
+
+func newStartupHandler(namespace string, metricsPath string, boshEnvironments []environments.Environment) *startupHandler {
+	upMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "up",
+			Help:      "Whether the last collection could fetch the BOSH Director (1 for up, 0 for down).",
+		},
+		[]string{"environment", "bosh_name", "bosh_uuid"},
+	)
+	for _, environment := range boshEnvironments {
+		upMetric.WithLabelValues(environment.Environment, "", "").Set(0)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(upMetric)
+
+	return &startupHandler{
+		metricsPath:    metricsPath,
+		upMetric:       upMetric,
+		metricsHandler: promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+		mu:             &sync.RWMutex{},
+	}
+}
+
+// connect calls connect until the BOSH Director of the environment is
+// reached, waiting the next backoff after every failure, and then removes its
+// metric. It gives up with the last error when stop is closed.
+func (h *startupHandler) connect(environment string, connect func() error, backoff *fetcher.Backoff, stop <-chan struct{}) error {
+	err := fetcher.Retry(connect, backoff, stop, func(err error, wait time.Duration) {
+		log.Errorf("%v, retrying in %s", err, wait)
+	})
+	if err != nil {
+		return err
+	}
+
+	h.connected(environment)
+	return nil
+}
+
+// connected removes the metric of a BOSH Director reached.
+func (h *startupHandler) connected(environment string) {
+	h.upMetric.DeleteLabelValues(environment, "", "")
+}
+
+// ready hands the requests over to the exporter handler.
+func (h *startupHandler) ready(handler http.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler = handler
+}
+
+func (h *startupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	handler := h.handler
+	h.mu.RUnlock()
+
+	if handler != nil {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	if r.URL.Path == h.metricsPath {
+		h.metricsHandler.ServeHTTP(w, r)
+		return
+	}
+
+	http.Error(w, "The BOSH Exporter is starting, waiting for the BOSH Directors to be reachable", http.StatusServiceUnavailable)
+}
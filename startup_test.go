@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/bosh_exporter/environments"
+	"github.com/bosh-prometheus/bosh_exporter/fetcher"
+)
+
+var _ = Describe("startupHandler", func() {
+	var (
+		startup *startupHandler
+	)
+
+	BeforeEach(func() {
+		startup = newStartupHandler("test_exporter", "/metrics", []environments.Environment{
+			{Environment: "fake-environment-1"},
+			{Environment: "fake-environment-2"},
+		})
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		startup.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	It("serves the BOSH Directors as down on the metrics path", func() {
+		recorder := serve("/metrics")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(ContainSubstring(`test_exporter_exporter_up{bosh_name="",bosh_uuid="",environment="fake-environment-1"} 0`))
+		Expect(recorder.Body.String()).To(ContainSubstring(`test_exporter_exporter_up{bosh_name="",bosh_uuid="",environment="fake-environment-2"} 0`))
+	})
+
+	It("is unavailable on the other paths", func() {
+		Expect(serve("/api/v1/status/config").Code).To(Equal(http.StatusServiceUnavailable))
+	})
+
+	Describe("connect", func() {
+		var (
+			attempts int
+			failures int
+			stop     chan struct{}
+			err      error
+		)
+
+		BeforeEach(func() {
+			attempts = 0
+			failures = 2
+			stop = make(chan struct{})
+		})
+
+		JustBeforeEach(func() {
+			connect := func() error {
+				attempts++
+				if attempts <= failures {
+					return errors.New("fake-error")
+				}
+				return nil
+			}
+			err = startup.connect("fake-environment-1", connect, fetcher.NewBackoff(time.Millisecond, time.Millisecond), stop)
+		})
+
+		It("retries until the BOSH Director is reached", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(attempts).To(Equal(3))
+		})
+
+		It("stops serving the BOSH Director as down", func() {
+			body := serve("/metrics").Body.String()
+			Expect(body).ToNot(ContainSubstring(`environment="fake-environment-1"`))
+			Expect(body).To(ContainSubstring(`environment="fake-environment-2"`))
+		})
+
+		Context("when stopped", func() {
+			BeforeEach(func() {
+				failures = 1000
+				close(stop)
+			})
+
+			It("returns the last error", func() {
+				Expect(err).To(MatchError("fake-error"))
+				Expect(attempts).To(Equal(1))
+				Expect(serve("/metrics").Body.String()).To(ContainSubstring(`environment="fake-environment-1"`))
+			})
+		})
+	})
+
+	Describe("ready", func() {
+		It("hands the requests over to the exporter handler", func() {
+			startup.ready(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}))
+
+			Expect(serve("/metrics").Code).To(Equal(http.StatusTeapot))
+			Expect(serve("/api/v1/status/config").Code).To(Equal(http.StatusTeapot))
+		})
+	})
+})
@@ -0,0 +1,115 @@
+package tlspolicy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+const (
+	PolicyDefault = "default"
+	PolicyFIPS    = "fips"
+)
+
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// Policy restricts the TLS versions, cipher suites and curves of the TLS
+// connections made and accepted by the exporter.
+type Policy struct {
+	Name             string
+	MinVersion       uint16
+	MaxVersion       uint16
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+}
+
+// New returns the named policy. The FIPS policy only allows TLS 1.2 with
+// FIPS-approved AES-GCM cipher suites and NIST curves, as the TLS 1.3 cipher
+// suites cannot be restricted.
+func New(name string) (Policy, error) {
+	switch name {
+	case PolicyDefault:
+		return Policy{Name: PolicyDefault}, nil
+	case PolicyFIPS:
+		return Policy{
+			Name:             PolicyFIPS,
+			MinVersion:       tls.VersionTLS12,
+			MaxVersion:       tls.VersionTLS12,
+			CipherSuites:     fipsCipherSuites,
+			CurvePreferences: fipsCurves,
+		}, nil
+	}
+
+	return Policy{}, errors.New(fmt.Sprintf("TLS policy `%s` is not supported", name))
+}
+
+// Validate refuses to downgrade a FIPS build to a less restrictive policy.
+func (p Policy) Validate() error {
+	if FIPSBuild && p.Name != PolicyFIPS {
+		return errors.New(fmt.Sprintf("TLS policy `%s` is not allowed by this FIPS build of the exporter", p.Name))
+	}
+
+	return nil
+}
+
+func (p Policy) Apply(tlsConfig *tls.Config) {
+	if p.Name == PolicyDefault {
+		return
+	}
+
+	tlsConfig.MinVersion = p.MinVersion
+	tlsConfig.MaxVersion = p.MaxVersion
+	tlsConfig.CipherSuites = p.CipherSuites
+	tlsConfig.CurvePreferences = p.CurvePreferences
+}
+
+func (p Policy) MinVersionName() string {
+	if p.MinVersion == 0 {
+		return "default"
+	}
+
+	return tls.VersionName(p.MinVersion)
+}
+
+// ValidateKeyPair checks that the certificate and key served by the exporter
+// can be used under the policy.
+func (p Policy) ValidateKeyPair(certFile string, keyFile string) error {
+	if p.Name == PolicyDefault {
+		return nil
+	}
+
+	keyPair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while loading the TLS key pair: %v", err))
+	}
+	certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while parsing the TLS certificate: %v", err))
+	}
+
+	switch publicKey := certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if publicKey.N.BitLen() < 2048 {
+			return errors.New(fmt.Sprintf("TLS certificate RSA key size %d is not allowed by the `%s` TLS policy, at least 2048 bits are required", publicKey.N.BitLen(), p.Name))
+		}
+	case *ecdsa.PublicKey:
+		if publicKey.Curve != elliptic.P256() && publicKey.Curve != elliptic.P384() && publicKey.Curve != elliptic.P521() {
+			return errors.New(fmt.Sprintf("TLS certificate ECDSA curve %s is not allowed by the `%s` TLS policy", publicKey.Curve.Params().Name, p.Name))
+		}
+	default:
+		return errors.New(fmt.Sprintf("TLS certificate key type %T is not allowed by the `%s` TLS policy", publicKey, p.Name))
+	}
+
+	return nil
+}
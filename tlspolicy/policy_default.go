@@ -0,0 +1,9 @@
+//go:build !fips
+// +build !fips
+
+package tlspolicy
+
+// FIPSBuild is set when the exporter is built with the `fips` build tag.
+const FIPSBuild = false
+
+const DefaultPolicy = PolicyDefault
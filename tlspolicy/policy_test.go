@@ -0,0 +1,155 @@
+package tlspolicy_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/tlspolicy"
+)
+
+func writeKeyPair(dir string, key crypto.Signer) (string, string) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "bosh-exporter"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	Expect(err).ToNot(HaveOccurred())
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	Expect(err).ToNot(HaveOccurred())
+
+	certFile := path.Join(dir, "cert.pem")
+	keyFile := path.Join(dir, "key.pem")
+	Expect(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)).To(Succeed())
+	Expect(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)).To(Succeed())
+
+	return certFile, keyFile
+}
+
+var _ = Describe("Policy", func() {
+	var (
+		err    error
+		policy Policy
+	)
+
+	Describe("New", func() {
+		It("returns an error for an unknown policy", func() {
+			_, err = New("fake-policy")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("with the default policy", func() {
+		BeforeEach(func() {
+			policy, err = New(PolicyDefault)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("leaves the TLS configuration untouched", func() {
+			tlsConfig := &tls.Config{}
+			policy.Apply(tlsConfig)
+			Expect(tlsConfig.MinVersion).To(BeZero())
+			Expect(tlsConfig.CipherSuites).To(BeEmpty())
+			Expect(policy.MinVersionName()).To(Equal("default"))
+		})
+
+		It("is only allowed by non FIPS builds", func() {
+			Expect(policy.Validate() == nil).To(Equal(!FIPSBuild))
+		})
+	})
+
+	Context("with the FIPS policy", func() {
+		var (
+			dir string
+		)
+
+		BeforeEach(func() {
+			policy, err = New(PolicyFIPS)
+			Expect(err).ToNot(HaveOccurred())
+
+			dir, err = ioutil.TempDir("", "tlspolicy_test_")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("restricts the TLS configuration to TLS 1.2 and approved cipher suites", func() {
+			tlsConfig := &tls.Config{}
+			policy.Apply(tlsConfig)
+			Expect(tlsConfig.MinVersion).To(Equal(uint16(tls.VersionTLS12)))
+			Expect(tlsConfig.MaxVersion).To(Equal(uint16(tls.VersionTLS12)))
+			Expect(tlsConfig.CipherSuites).To(ConsistOf(
+				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			))
+			Expect(tlsConfig.CurvePreferences).ToNot(ContainElement(tls.X25519))
+			Expect(policy.MinVersionName()).To(Equal("TLS 1.2"))
+		})
+
+		It("accepts an ECDSA P-256 key pair", func() {
+			key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(keyErr).ToNot(HaveOccurred())
+
+			Expect(policy.ValidateKeyPair(writeKeyPair(dir, key))).To(Succeed())
+		})
+
+		It("refuses a small RSA key pair", func() {
+			key, keyErr := rsa.GenerateKey(rand.Reader, 1024)
+			Expect(keyErr).ToNot(HaveOccurred())
+
+			err = policy.ValidateKeyPair(writeKeyPair(dir, key))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("at least 2048 bits"))
+		})
+
+		It("refuses connections to servers without an approved cipher suite", func() {
+			clientConfig := &tls.Config{InsecureSkipVerify: true}
+			policy.Apply(clientConfig)
+
+			key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(keyErr).ToNot(HaveOccurred())
+			keyPair, keyPairErr := tls.LoadX509KeyPair(writeKeyPair(dir, key))
+			Expect(keyPairErr).ToNot(HaveOccurred())
+
+			listener, listenErr := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+				Certificates: []tls.Certificate{keyPair},
+				MinVersion:   tls.VersionTLS13,
+			})
+			Expect(listenErr).ToNot(HaveOccurred())
+			defer listener.Close()
+			go func() {
+				conn, acceptErr := listener.Accept()
+				if acceptErr == nil {
+					_ = conn.(*tls.Conn).Handshake()
+					conn.Close()
+				}
+			}()
+
+			conn, dialErr := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+			if dialErr == nil {
+				conn.Close()
+			}
+			Expect(dialErr).To(HaveOccurred())
+		})
+	})
+})
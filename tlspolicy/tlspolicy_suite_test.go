@@ -0,0 +1,13 @@
+package tlspolicy_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTLSPolicy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TLS Policy Suite")
+}
@@ -0,0 +1,171 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	otlpStatusCodeOK    = 1
+	otlpStatusCodeError = 2
+)
+
+// OTLPExporter sends spans to an OpenTelemetry collector with the OTLP/HTTP
+// JSON encoding.
+type OTLPExporter struct {
+	url        string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+func NewOTLPExporter(endpoint string, headers map[string]string, timeout time.Duration) *OTLPExporter {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url = url + "/v1/traces"
+	}
+
+	return &OTLPExporter{
+		url:        url,
+		headers:    headers,
+		httpClient: &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}, Timeout: timeout},
+	}
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func (e *OTLPExporter) Export(resource map[string]string, spans []*Span) error {
+	scopeSpans := otlpScopeSpans{Scope: otlpScope{Name: "bosh_exporter"}}
+	for _, span := range spans {
+		span.mu.Lock()
+		otlpSpan := otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: otlpStatusCodeOK},
+		}
+		if span.Err != nil {
+			otlpSpan.Status = otlpStatus{Code: otlpStatusCodeError, Message: span.Err.Error()}
+		}
+		span.mu.Unlock()
+		scopeSpans.Spans = append(scopeSpans.Spans, otlpSpan)
+	}
+
+	body, err := json.Marshal(otlpTraces{
+		ResourceSpans: []otlpResourceSpans{
+			{Resource: otlpResource{Attributes: otlpAttributes(resource)}, ScopeSpans: []otlpScopeSpans{scopeSpans}},
+		},
+	})
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while encoding traces: %v", err))
+	}
+
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while exporting traces to `%s`: %v", e.url, err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while exporting traces to `%s`: %v", e.url, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return errors.New(fmt.Sprintf("Error while exporting traces to `%s`: %s: %s", e.url, resp.Status, string(respBody)))
+	}
+
+	return nil
+}
+
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	otlpAttributes := []otlpAttribute{}
+	for _, key := range keys {
+		otlpAttributes = append(otlpAttributes, otlpAttribute{Key: key, Value: otlpAttributeValue{StringValue: attributes[key]}})
+	}
+
+	return otlpAttributes
+}
+
+func ParseHeaders(headers []string) (map[string]string, error) {
+	parsedHeaders := map[string]string{}
+
+	for _, header := range headers {
+		parts := strings.SplitN(header, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.New(fmt.Sprintf("OTLP header `%s` must be formatted as <name>=<value>", header))
+		}
+		parsedHeaders[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return parsedHeaders, nil
+}
@@ -0,0 +1,99 @@
+package tracing_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/tracing"
+)
+
+var _ = Describe("OTLPExporter", func() {
+	var (
+		server     *httptest.Server
+		statusCode int
+		requests   chan *http.Request
+		bodies     chan map[string]interface{}
+		exporter   *OTLPExporter
+		tracer     *Tracer
+	)
+
+	BeforeEach(func() {
+		statusCode = http.StatusOK
+		requests = make(chan *http.Request, 1)
+		bodies = make(chan map[string]interface{}, 1)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			decoded := map[string]interface{}{}
+			json.Unmarshal(body, &decoded)
+			requests <- r
+			bodies <- decoded
+			w.WriteHeader(statusCode)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		exporter = NewOTLPExporter(server.URL, map[string]string{"Authorization": "Bearer token"}, time.Second)
+		tracer = NewTracer(exporter, map[string]string{"service.name": "bosh_exporter"})
+
+		span := tracer.Start(nil, "collect")
+		span.RecordError(errors.New("no deployments"))
+		span.Finish()
+	})
+
+	It("posts the spans as OTLP JSON", func() {
+		Expect(tracer.Flush()).To(Succeed())
+
+		request := <-requests
+		Expect(request.URL.Path).To(Equal("/v1/traces"))
+		Expect(request.Header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(request.Header.Get("Authorization")).To(Equal("Bearer token"))
+
+		resourceSpans := (<-bodies)["resourceSpans"].([]interface{})[0].(map[string]interface{})
+		Expect(resourceSpans["resource"]).To(Equal(map[string]interface{}{
+			"attributes": []interface{}{
+				map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "bosh_exporter"}},
+			},
+		}))
+		span := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+		Expect(span["name"]).To(Equal("collect"))
+		Expect(span["traceId"]).To(HaveLen(32))
+		Expect(span["spanId"]).To(HaveLen(16))
+		Expect(span["status"]).To(Equal(map[string]interface{}{"code": float64(2), "message": "no deployments"}))
+	})
+
+	Context("when the collector rejects the spans", func() {
+		BeforeEach(func() {
+			statusCode = http.StatusBadRequest
+		})
+
+		It("returns an error", func() {
+			err := tracer.Flush()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("400 Bad Request"))
+		})
+	})
+})
+
+var _ = Describe("ParseHeaders", func() {
+	It("parses the headers", func() {
+		headers, err := ParseHeaders([]string{"Authorization=Bearer token", " x-scope = bosh "})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(headers).To(Equal(map[string]string{"Authorization": "Bearer token", "x-scope": "bosh"}))
+	})
+
+	It("returns an error when a header is not formatted as <name>=<value>", func() {
+		_, err := ParseHeaders([]string{"Authorization"})
+		Expect(err).To(MatchError("OTLP header `Authorization` must be formatted as <name>=<value>"))
+	})
+})
@@ -0,0 +1,203 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+const (
+	SpanKindInternal = 1
+	SpanKindClient   = 3
+
+	maxPendingSpans = 4096
+)
+
+type Exporter interface {
+	Export(resource map[string]string, spans []*Span) error
+}
+
+// Tracer records the spans of a BOSH Director. A nil Tracer records nothing,
+// so tracing can be disabled without checks at every call site.
+type Tracer struct {
+	exporter     Exporter
+	resource     map[string]string
+	pending      []*Span
+	active       *Span
+	droppedSpans uint64
+	mu           *sync.Mutex
+}
+
+func NewTracer(exporter Exporter, resource map[string]string) *Tracer {
+	return &Tracer{exporter: exporter, resource: resource, mu: &sync.Mutex{}}
+}
+
+func (t *Tracer) Start(parent *Span, name string) *Span {
+	return t.start(parent, name, SpanKindInternal)
+}
+
+func (t *Tracer) StartClient(parent *Span, name string) *Span {
+	return t.start(parent, name, SpanKindClient)
+}
+
+func (t *Tracer) start(parent *Span, name string, kind int) *Span {
+	if t == nil {
+		return nil
+	}
+
+	span := &Span{
+		tracer:     t,
+		SpanID:     randomID(8),
+		Name:       name,
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: map[string]string{},
+		mu:         &sync.Mutex{},
+	}
+	if parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentSpanID = parent.SpanID
+	} else {
+		span.TraceID = randomID(16)
+	}
+
+	return span
+}
+
+// SetActive sets the parent of the spans started by callers that do not know
+// the current span, such as the BOSH Director HTTP session.
+func (t *Tracer) SetActive(span *Span) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.active = span
+	t.mu.Unlock()
+}
+
+func (t *Tracer) Active() *Span {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active
+}
+
+func (t *Tracer) DroppedSpans() uint64 {
+	if t == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&t.droppedSpans)
+}
+
+func (t *Tracer) record(span *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.pending) >= maxPendingSpans {
+		atomic.AddUint64(&t.droppedSpans, 1)
+		return
+	}
+	t.pending = append(t.pending, span)
+}
+
+func (t *Tracer) Flush() error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	if err := t.exporter.Export(t.resource, spans); err != nil {
+		atomic.AddUint64(&t.droppedSpans, uint64(len(spans)))
+		return err
+	}
+
+	return nil
+}
+
+func (t *Tracer) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			if err := t.Flush(); err != nil {
+				log.Errorf("Error exporting traces: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				log.Errorf("Error exporting traces: %v", err)
+			}
+		}
+	}
+}
+
+type Span struct {
+	tracer       *Tracer
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Kind         int
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]string
+	Err          error
+	mu           *sync.Mutex
+}
+
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.Err = err
+	s.mu.Unlock()
+}
+
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.End = time.Now()
+	s.mu.Unlock()
+
+	s.tracer.record(s)
+}
+
+func randomID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,89 @@
+package tracing_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/bosh_exporter/tracing"
+)
+
+type fakeExporter struct {
+	resource map[string]string
+	spans    []*Span
+	err      error
+}
+
+func (e *fakeExporter) Export(resource map[string]string, spans []*Span) error {
+	e.resource = resource
+	e.spans = append(e.spans, spans...)
+	return e.err
+}
+
+var _ = Describe("Tracer", func() {
+	var (
+		exporter *fakeExporter
+		tracer   *Tracer
+	)
+
+	BeforeEach(func() {
+		exporter = &fakeExporter{}
+		tracer = NewTracer(exporter, map[string]string{"service.name": "bosh_exporter"})
+	})
+
+	It("exports the finished spans on flush", func() {
+		parent := tracer.Start(nil, "collect")
+		child := tracer.StartClient(parent, "GET /deployments")
+		child.SetAttribute("http.status_code", "200")
+		child.Finish()
+		parent.Finish()
+
+		Expect(tracer.Flush()).To(Succeed())
+		Expect(exporter.resource).To(Equal(map[string]string{"service.name": "bosh_exporter"}))
+		Expect(exporter.spans).To(HaveLen(2))
+		Expect(exporter.spans[0].Name).To(Equal("GET /deployments"))
+		Expect(exporter.spans[0].Kind).To(Equal(SpanKindClient))
+		Expect(exporter.spans[0].TraceID).To(Equal(parent.TraceID))
+		Expect(exporter.spans[0].ParentSpanID).To(Equal(parent.SpanID))
+		Expect(exporter.spans[0].Attributes).To(HaveKeyWithValue("http.status_code", "200"))
+		Expect(exporter.spans[1].ParentSpanID).To(BeEmpty())
+	})
+
+	It("does not export unfinished spans", func() {
+		tracer.Start(nil, "collect")
+
+		Expect(tracer.Flush()).To(Succeed())
+		Expect(exporter.spans).To(BeEmpty())
+	})
+
+	It("returns the active span", func() {
+		span := tracer.Start(nil, "fetch")
+		tracer.SetActive(span)
+		Expect(tracer.Active()).To(Equal(span))
+	})
+
+	It("counts the spans it fails to export", func() {
+		exporter.err = errors.New("export error")
+		tracer.Start(nil, "collect").Finish()
+
+		Expect(tracer.Flush()).ToNot(Succeed())
+		Expect(tracer.DroppedSpans()).To(Equal(uint64(1)))
+	})
+
+	Context("when the tracer is nil", func() {
+		BeforeEach(func() {
+			tracer = nil
+		})
+
+		It("does not record anything", func() {
+			span := tracer.Start(nil, "collect")
+			span.SetAttribute("key", "value")
+			span.RecordError(errors.New("error"))
+			span.Finish()
+
+			Expect(span).To(BeNil())
+			Expect(tracer.Flush()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,159 @@
+package updates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const DefaultReleasesURL = "https://api.github.com/repos/bosh-prometheus/bosh_exporter/releases/latest"
+
+type release struct {
+	TagName string `json:"tag_name"`
+}
+
+// Checker periodically looks up the latest exporter release and reports
+// whether it is newer than the running version.
+type Checker struct {
+	url                 string
+	currentVersion      string
+	httpClient          *http.Client
+	updateAvailableDesc *prometheus.Desc
+
+	mu            sync.Mutex
+	latestVersion string
+}
+
+func NewChecker(namespace string, url string, currentVersion string, timeout time.Duration) *Checker {
+	updateAvailableDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "update_available"),
+		"Whether a newer BOSH exporter release than the running one is available (1 for yes, 0 for no).",
+		[]string{"current_version", "latest_version"},
+		nil,
+	)
+
+	return &Checker{
+		url:                 url,
+		currentVersion:      currentVersion,
+		httpClient:          &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}, Timeout: timeout},
+		updateAvailableDesc: updateAvailableDesc,
+	}
+}
+
+func (c *Checker) Check() error {
+	req, err := http.NewRequest("GET", c.url, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while checking for updates at `%s`: %v", c.url, err))
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while checking for updates at `%s`: %v", c.url, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("Error while checking for updates at `%s`: unexpected status %d", c.url, resp.StatusCode))
+	}
+
+	var latestRelease release
+	if err := json.NewDecoder(resp.Body).Decode(&latestRelease); err != nil {
+		return errors.New(fmt.Sprintf("Error while decoding the latest release from `%s`: %v", c.url, err))
+	}
+	if latestRelease.TagName == "" {
+		return errors.New(fmt.Sprintf("Error while decoding the latest release from `%s`: no tag name", c.url))
+	}
+
+	c.mu.Lock()
+	c.latestVersion = strings.TrimPrefix(latestRelease.TagName, "v")
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *Checker) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Check(); err != nil {
+			log.Errorf("%v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Checker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.updateAvailableDesc
+}
+
+func (c *Checker) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	latestVersion := c.latestVersion
+	c.mu.Unlock()
+
+	if latestVersion == "" {
+		return
+	}
+
+	var updateAvailable float64
+	if newerVersion(latestVersion, c.currentVersion) {
+		updateAvailable = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.updateAvailableDesc, prometheus.GaugeValue, updateAvailable, c.currentVersion, latestVersion)
+}
+
+// newerVersion compares dotted numeric versions, ignoring any pre-release or
+// build suffix.
+func newerVersion(version string, than string) bool {
+	versionParts := versionNumbers(version)
+	thanParts := versionNumbers(than)
+
+	for i := 0; i < len(versionParts) || i < len(thanParts); i++ {
+		var versionPart, thanPart int
+		if i < len(versionParts) {
+			versionPart = versionParts[i]
+		}
+		if i < len(thanParts) {
+			thanPart = thanParts[i]
+		}
+		if versionPart != thanPart {
+			return versionPart > thanPart
+		}
+	}
+
+	return false
+}
+
+func versionNumbers(version string) []int {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+
+	numbers := []int{}
+	for _, part := range strings.Split(version, ".") {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, number)
+	}
+
+	return numbers
+}
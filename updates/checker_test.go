@@ -0,0 +1,125 @@
+package updates_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/bosh-prometheus/bosh_exporter/updates"
+	. "github.com/bosh-prometheus/bosh_exporter/utils/test_matchers"
+)
+
+var _ = Describe("Checker", func() {
+	var (
+		server         *httptest.Server
+		statusCode     int
+		latestRelease  string
+		currentVersion string
+		checker        *Checker
+		metrics        chan prometheus.Metric
+
+		updateAvailableDesc *prometheus.Desc
+	)
+
+	BeforeEach(func() {
+		statusCode = http.StatusOK
+		latestRelease = `{"tag_name":"v3.4.0"}`
+		currentVersion = "3.3.0"
+		metrics = make(chan prometheus.Metric)
+
+		updateAvailableDesc = prometheus.NewDesc(
+			"test_exporter_exporter_update_available",
+			"Whether a newer BOSH exporter release than the running one is available (1 for yes, 0 for no).",
+			[]string{"current_version", "latest_version"},
+			nil,
+		)
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statusCode)
+			w.Write([]byte(latestRelease))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		checker = NewChecker("test_exporter", server.URL, currentVersion, time.Second)
+	})
+
+	Describe("Describe", func() {
+		It("returns an exporter_update_available description", func() {
+			descriptions := make(chan *prometheus.Desc)
+			go checker.Describe(descriptions)
+			Eventually(descriptions).Should(Receive(Equal(updateAvailableDesc)))
+		})
+	})
+
+	Describe("Collect", func() {
+		It("returns no metric before the first check", func() {
+			bufferedMetrics := make(chan prometheus.Metric, 1)
+			checker.Collect(bufferedMetrics)
+			Expect(bufferedMetrics).To(BeEmpty())
+		})
+
+		Context("when a newer release is available", func() {
+			It("returns an exporter_update_available metric set to 1", func() {
+				Expect(checker.Check()).To(Succeed())
+				go checker.Collect(metrics)
+				Eventually(metrics).Should(Receive(PrometheusMetric(prometheus.MustNewConstMetric(updateAvailableDesc, prometheus.GaugeValue, 1, "3.3.0", "3.4.0"))))
+			})
+		})
+
+		Context("when the running version is the latest", func() {
+			BeforeEach(func() {
+				latestRelease = `{"tag_name":"v3.3.0"}`
+			})
+
+			It("returns an exporter_update_available metric set to 0", func() {
+				Expect(checker.Check()).To(Succeed())
+				go checker.Collect(metrics)
+				Eventually(metrics).Should(Receive(PrometheusMetric(prometheus.MustNewConstMetric(updateAvailableDesc, prometheus.GaugeValue, 0, "3.3.0", "3.3.0"))))
+			})
+		})
+
+		Context("when the running version is a newer pre-release", func() {
+			BeforeEach(func() {
+				currentVersion = "3.10.0-rc.1"
+			})
+
+			It("returns an exporter_update_available metric set to 0", func() {
+				Expect(checker.Check()).To(Succeed())
+				go checker.Collect(metrics)
+				Eventually(metrics).Should(Receive(PrometheusMetric(prometheus.MustNewConstMetric(updateAvailableDesc, prometheus.GaugeValue, 0, "3.10.0-rc.1", "3.4.0"))))
+			})
+		})
+	})
+
+	Describe("Check", func() {
+		Context("when the releases endpoint fails", func() {
+			BeforeEach(func() {
+				statusCode = http.StatusForbidden
+			})
+
+			It("returns an error", func() {
+				Expect(checker.Check()).To(MatchError(ContainSubstring("unexpected status 403")))
+			})
+		})
+
+		Context("when the release has no tag", func() {
+			BeforeEach(func() {
+				latestRelease = `{}`
+			})
+
+			It("returns an error", func() {
+				Expect(checker.Check()).To(MatchError(ContainSubstring("no tag name")))
+			})
+		})
+	})
+})
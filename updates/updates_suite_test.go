@@ -0,0 +1,13 @@
+package updates_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestUpdates(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Updates Suite")
+}